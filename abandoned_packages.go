@@ -0,0 +1,105 @@
+package composer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// AbandonedPackage is a package which has been marked as abandoned by its
+// maintainers.
+type AbandonedPackage struct {
+	Name string
+
+	// Replacement is the package suggested as a replacement, if any
+	Replacement string
+}
+
+func (p AbandonedPackage) String() string {
+	if p.Replacement == "" {
+		return fmt.Sprintf("%s (no replacement suggested)", p.Name)
+	}
+	return fmt.Sprintf("%s (use %s instead)", p.Name, p.Replacement)
+}
+
+// FindAbandonedPackages will inspect the `composer.lock` file for packages
+// which have been marked as abandoned. Composer records this in the
+// "abandoned" field of each package, which is either `true` or the name of
+// the suggested replacement package.
+// https://getcomposer.org/doc/04-schema.md#abandoned
+//
+// Returns an empty list if `composer.lock` does not exist.
+func FindAbandonedPackages(composerLockPath string) ([]AbandonedPackage, error) {
+	content, err := readComposerFile(composerLockPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	type lockedPackage struct {
+		Name      string      `json:"name"`
+		Abandoned interface{} `json:"abandoned"`
+	}
+
+	var composerLock struct {
+		Packages    []lockedPackage `json:"packages"`
+		PackagesDev []lockedPackage `json:"packages-dev"`
+	}
+
+	err = json.Unmarshal(content, &composerLock)
+	if err != nil {
+		return nil, err
+	}
+
+	var abandoned []AbandonedPackage
+	for _, p := range append(composerLock.Packages, composerLock.PackagesDev...) {
+		switch value := p.Abandoned.(type) {
+		case bool:
+			if value {
+				abandoned = append(abandoned, AbandonedPackage{Name: p.Name})
+			}
+		case string:
+			abandoned = append(abandoned, AbandonedPackage{Name: p.Name, Replacement: value})
+		}
+	}
+
+	return abandoned, nil
+}
+
+// checkAbandonedPackages will fail the build if `BP_COMPOSER_DENY_ABANDONED`
+// is set to true and `composer.lock` contains abandoned packages.
+func checkAbandonedPackages(logger emitter, composerLockPath string) error {
+	denyAbandoned, err := lookupBoolEnv(BpComposerDenyAbandoned, false)
+	if err != nil {
+		return err
+	}
+
+	if !denyAbandoned {
+		return nil
+	}
+
+	logger.Process("Checking for abandoned packages")
+
+	abandoned, err := FindAbandonedPackages(composerLockPath)
+	if err != nil {
+		return err
+	}
+
+	if len(abandoned) == 0 {
+		logger.Subprocess("No abandoned packages found")
+		logger.Break()
+		return nil
+	}
+
+	var names []string
+	for _, p := range abandoned {
+		logger.Subprocess("- %s", p)
+		names = append(names, p.String())
+	}
+
+	return fmt.Errorf("found %d abandoned package(s) while %s is set: %s", len(abandoned), BpComposerDenyAbandoned, strings.Join(names, ", "))
+}
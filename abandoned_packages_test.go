@@ -0,0 +1,94 @@
+package composer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testAbandonedPackages(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("when composer.lock does not exist", func() {
+		it("returns no packages", func() {
+			abandoned, err := composer.FindAbandonedPackages(filepath.Join(workingDir, "composer.lock"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(abandoned).To(BeEmpty())
+		})
+	})
+
+	context("when composer.lock contains abandoned packages", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+	"packages": [
+		{
+			"name": "some/maintained"
+		},
+		{
+			"name": "some/abandoned",
+			"abandoned": true
+		},
+		{
+			"name": "some/replaced",
+			"abandoned": "some/replacement"
+		},
+		{
+			"name": "some/not-abandoned",
+			"abandoned": false
+		}
+	],
+	"packages-dev": [
+		{
+			"name": "some/abandoned-dev",
+			"abandoned": true
+		}
+	]
+}`), os.ModePerm)).To(Succeed())
+		})
+
+		it("returns the abandoned packages with their replacements", func() {
+			abandoned, err := composer.FindAbandonedPackages(filepath.Join(workingDir, "composer.lock"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(abandoned).To(Equal([]composer.AbandonedPackage{
+				{Name: "some/abandoned"},
+				{Name: "some/replaced", Replacement: "some/replacement"},
+				{Name: "some/abandoned-dev"},
+			}))
+
+			Expect(abandoned[0].String()).To(Equal("some/abandoned (no replacement suggested)"))
+			Expect(abandoned[1].String()).To(Equal("some/replaced (use some/replacement instead)"))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when composer.lock is malformed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`%%%`), os.ModePerm)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := composer.FindAbandonedPackages(filepath.Join(workingDir, "composer.lock"))
+				Expect(err).To(MatchError(ContainSubstring("invalid character")))
+			})
+		})
+	})
+}
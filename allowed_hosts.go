@@ -0,0 +1,158 @@
+package composer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// HostPolicyViolation is a URL in `composer.lock` pointing to a host which
+// is not allowed.
+type HostPolicyViolation struct {
+	Package string
+
+	// Kind is either "dist" or "source"
+	Kind string
+
+	URL string
+}
+
+func (v HostPolicyViolation) String() string {
+	return fmt.Sprintf("%s (%s %s)", v.Package, v.Kind, v.URL)
+}
+
+// scpLikeURL matches git URLs of the form "user@host:path"
+var scpLikeURL = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):`)
+
+// urlHostname returns the lower-cased hostname of the given URL, or false if
+// it does not point to a remote host, such as the URL of a `path`
+// repository.
+func urlHostname(rawURL string) (string, bool) {
+	if strings.Contains(rawURL, "://") {
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Hostname() == "" {
+			return "", false
+		}
+		return strings.ToLower(u.Hostname()), true
+	}
+
+	if match := scpLikeURL.FindStringSubmatch(rawURL); match != nil {
+		return strings.ToLower(match[1]), true
+	}
+
+	return "", false
+}
+
+// hostAllowed reports whether the hostname matches one of the allowed
+// hosts. An allowed host starting with "*." matches all of its subdomains.
+func hostAllowed(hostname string, allowedHosts []string) bool {
+	for _, allowed := range allowedHosts {
+		allowed = strings.ToLower(allowed)
+
+		if strings.HasPrefix(allowed, "*.") {
+			if strings.HasSuffix(hostname, allowed[1:]) {
+				return true
+			}
+			continue
+		}
+
+		if hostname == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FindHostPolicyViolations will inspect the dist and source URLs of all
+// packages in the `composer.lock` file, and return those which point to a
+// host not matching any of the allowed hosts.
+//
+// Returns an empty list if `composer.lock` does not exist.
+func FindHostPolicyViolations(composerLockPath string, allowedHosts []string) ([]HostPolicyViolation, error) {
+	content, err := readComposerFile(composerLockPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	type lockedPackage struct {
+		Name string `json:"name"`
+		Dist struct {
+			URL string `json:"url"`
+		} `json:"dist"`
+		Source struct {
+			URL string `json:"url"`
+		} `json:"source"`
+	}
+
+	var composerLock struct {
+		Packages    []lockedPackage `json:"packages"`
+		PackagesDev []lockedPackage `json:"packages-dev"`
+	}
+
+	err = json.Unmarshal(content, &composerLock)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []HostPolicyViolation
+	for _, p := range append(composerLock.Packages, composerLock.PackagesDev...) {
+		for _, location := range []struct{ kind, url string }{
+			{"dist", p.Dist.URL},
+			{"source", p.Source.URL},
+		} {
+			hostname, ok := urlHostname(location.url)
+			if !ok || hostAllowed(hostname, allowedHosts) {
+				continue
+			}
+
+			violations = append(violations, HostPolicyViolation{
+				Package: p.Name,
+				Kind:    location.kind,
+				URL:     location.url,
+			})
+		}
+	}
+
+	return violations, nil
+}
+
+// checkAllowedHostsIfRequired will check for env var
+// "BP_COMPOSER_ALLOWED_HOSTS". If set, the build fails before the install if
+// `composer.lock` contains a dist or source URL pointing to any other host,
+// e.g. to make sure all packages are downloaded through a proxy.
+func checkAllowedHostsIfRequired(logger emitter, composerLockPath string) error {
+	allowedHosts := strings.Fields(os.Getenv(BpComposerAllowedHosts))
+	if len(allowedHosts) == 0 {
+		return nil
+	}
+
+	logger.Process("Checking the hosts in composer.lock")
+	logger.Subprocess("Allowed hosts: %s", strings.Join(allowedHosts, ", "))
+
+	violations, err := FindHostPolicyViolations(composerLockPath, allowedHosts)
+	if err != nil {
+		return err
+	}
+
+	if len(violations) == 0 {
+		logger.Subprocess("All packages are downloaded from allowed hosts")
+		logger.Break()
+		return nil
+	}
+
+	var descriptions []string
+	for _, v := range violations {
+		logger.Subprocess("- %s", v)
+		descriptions = append(descriptions, v.String())
+	}
+
+	return fmt.Errorf("found %d URL(s) in composer.lock pointing to hosts not allowed by %s: %s", len(violations), BpComposerAllowedHosts, strings.Join(descriptions, ", "))
+}
@@ -0,0 +1,98 @@
+package composer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testAllowedHosts(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("when composer.lock does not exist", func() {
+		it("returns no violations", func() {
+			violations, err := composer.FindHostPolicyViolations(filepath.Join(workingDir, "composer.lock"), []string{"proxy.example.com"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(violations).To(BeEmpty())
+		})
+	})
+
+	context("when composer.lock contains packages from other hosts", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+	"packages": [
+		{
+			"name": "some/package",
+			"dist": {"type": "zip", "url": "https://proxy.example.com/some/package.zip"},
+			"source": {"type": "git", "url": "https://github.com/some/package.git"}
+		},
+		{
+			"name": "some/mirrored-package",
+			"dist": {"type": "zip", "url": "https://EU.Mirror.example.com:8443/some/mirrored-package.zip"}
+		},
+		{
+			"name": "some/local-package",
+			"dist": {"type": "path", "url": "packages/local-package"}
+		}
+	],
+	"packages-dev": [
+		{
+			"name": "some/dev-package",
+			"source": {"type": "git", "url": "git@gitlab.com:some/dev-package.git"}
+		}
+	]
+}`), os.ModePerm)).To(Succeed())
+		})
+
+		it("returns each URL pointing to a host which is not allowed", func() {
+			violations, err := composer.FindHostPolicyViolations(filepath.Join(workingDir, "composer.lock"), []string{"proxy.example.com", "*.mirror.example.com"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(violations).To(Equal([]composer.HostPolicyViolation{
+				{Package: "some/package", Kind: "source", URL: "https://github.com/some/package.git"},
+				{Package: "some/dev-package", Kind: "source", URL: "git@gitlab.com:some/dev-package.git"},
+			}))
+		})
+
+		it("does not match the domain of a wildcard itself", func() {
+			violations, err := composer.FindHostPolicyViolations(filepath.Join(workingDir, "composer.lock"), []string{"*.example.com", "github.com", "gitlab.com"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(violations).To(BeEmpty())
+
+			violations, err = composer.FindHostPolicyViolations(filepath.Join(workingDir, "composer.lock"), []string{"*.proxy.example.com", "eu.mirror.example.com", "github.com", "gitlab.com"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(violations).To(Equal([]composer.HostPolicyViolation{
+				{Package: "some/package", Kind: "dist", URL: "https://proxy.example.com/some/package.zip"},
+			}))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when composer.lock is not valid JSON", func() {
+			it("returns an error", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`%%%`), os.ModePerm)).To(Succeed())
+
+				_, err := composer.FindHostPolicyViolations(filepath.Join(workingDir, "composer.lock"), []string{"proxy.example.com"})
+				Expect(err).To(MatchError(ContainSubstring("invalid character")))
+			})
+		})
+	})
+}
@@ -0,0 +1,233 @@
+package composer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+const (
+	// AutoloadChecksumFileName is the name of the file in the
+	// composer-packages layer holding the checksum of the autoloaded
+	// application sources at build time.
+	AutoloadChecksumFileName = "autoload-checksum"
+
+	// AutoloadRefreshExecD is the name of the exec.d executable which
+	// regenerates the autoloader at launch.
+	AutoloadRefreshExecD = "refresh-autoloader"
+
+	// environment variables passed to the exec.d executable at launch
+	AutoloadAppDirEnv          = "BPI_COMPOSER_APP_DIR"
+	AutoloadComposerJsonEnv    = "BPI_COMPOSER_JSON_PATH"
+	AutoloadVendorDirEnv       = "BPI_COMPOSER_VENDOR_DIR"
+	AutoloadChecksumPathEnv    = "BPI_COMPOSER_AUTOLOAD_CHECKSUM_PATH"
+	AutoloadRefreshDisabledEnv = "BPL_COMPOSER_AUTOLOAD_REFRESH_DISABLED"
+)
+
+// FindAutoloadPaths returns the directories and files of the application
+// which are autoloaded according to the "autoload" section of the root
+// `composer.json`, i.e. its "psr-4", "psr-0" and "classmap" entries.
+// https://getcomposer.org/doc/04-schema.md#autoload
+//
+// Returns the existing paths, sorted, or an empty list if `composer.json`
+// does not exist.
+func FindAutoloadPaths(composerJsonPath string) ([]string, error) {
+	var composerJson struct {
+		Autoload struct {
+			Psr4     map[string]interface{} `json:"psr-4"`
+			Psr0     map[string]interface{} `json:"psr-0"`
+			Classmap []string               `json:"classmap"`
+		} `json:"autoload"`
+	}
+
+	content, err := readComposerFile(composerJsonPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	err = json.Unmarshal(content, &composerJson)
+	if err != nil {
+		return nil, err
+	}
+
+	relativePaths := composerJson.Autoload.Classmap
+	for _, namespaces := range []map[string]interface{}{composerJson.Autoload.Psr4, composerJson.Autoload.Psr0} {
+		for _, value := range namespaces {
+			switch paths := value.(type) {
+			case string:
+				relativePaths = append(relativePaths, paths)
+			case []interface{}:
+				for _, path := range paths {
+					if str, ok := path.(string); ok {
+						relativePaths = append(relativePaths, str)
+					}
+				}
+			}
+		}
+	}
+
+	projectRoot := filepath.Dir(composerJsonPath)
+	unique := map[string]bool{}
+	var paths []string
+	for _, relativePath := range relativePaths {
+		path := filepath.Join(projectRoot, normalizeComposerPath(relativePath))
+		if unique[path] {
+			continue
+		}
+		unique[path] = true
+
+		if exists, err := fs.Exists(path); err != nil {
+			return nil, err
+		} else if !exists {
+			continue
+		}
+
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// autoloadChecksum calculates the checksum of the autoloaded application
+// sources. Returns an empty string if there are none.
+func autoloadChecksum(composerJsonPath string, calculator Calculator) (string, error) {
+	paths, err := FindAutoloadPaths(composerJsonPath)
+	if err != nil {
+		return "", err
+	}
+
+	if len(paths) == 0 {
+		return "", nil
+	}
+
+	return calculator.Sum(paths...)
+}
+
+// configureAutoloadRefreshIfRequired will check for env var
+// "BP_COMPOSER_AUTOLOAD_REFRESH". If set to true, the checksum of the
+// autoloaded application sources is written to the composer-packages layer,
+// and the `refresh-autoloader` exec.d executable is added to the layer. At
+// launch, it will run `composer dump-autoload --optimize` if the
+// application sources have changed since the build, e.g. because the
+// application code has been replaced or mounted into the container.
+func configureAutoloadRefreshIfRequired(
+	logger emitter,
+	context packit.BuildContext,
+	composerPackagesLayer *packit.Layer,
+	workspaceVendorDir string,
+	calculator Calculator) error {
+	enabled, err := lookupBoolEnv(BpComposerAutoloadRefresh, false)
+	if err != nil {
+		return err
+	}
+
+	if !enabled {
+		return nil
+	}
+
+	if !composerPackagesLayer.Launch {
+		logger.Process("Skipping autoloader refresh at launch, %s is not a launch layer", composerPackagesLayer.Name)
+		logger.Break()
+		return nil
+	}
+
+	composerJsonPath, _, _, _ := FindComposerFiles(context.WorkingDir)
+
+	checksum, err := autoloadChecksum(composerJsonPath, calculator)
+	if err != nil {
+		return err
+	}
+
+	checksumPath := filepath.Join(composerPackagesLayer.Path, AutoloadChecksumFileName)
+	err = os.WriteFile(checksumPath, []byte(checksum), 0644)
+	if err != nil { // untested
+		return err
+	}
+
+	logger.Process("Configuring autoloader refresh at launch")
+	logger.Debug.Subprocess("Calculated checksum of %s for autoloaded sources", checksum)
+
+	composerPackagesLayer.ExecD = append(composerPackagesLayer.ExecD, filepath.Join(context.CNBPath, "bin", AutoloadRefreshExecD))
+	composerPackagesLayer.LaunchEnv.Default(AutoloadAppDirEnv, context.WorkingDir)
+	composerPackagesLayer.LaunchEnv.Default(AutoloadComposerJsonEnv, composerJsonPath)
+	composerPackagesLayer.LaunchEnv.Default(AutoloadVendorDirEnv, workspaceVendorDir)
+	composerPackagesLayer.LaunchEnv.Default(AutoloadChecksumPathEnv, checksumPath)
+	logger.EnvironmentVariables(*composerPackagesLayer)
+
+	return nil
+}
+
+// RefreshAutoloader compares the checksum of the autoloaded application
+// sources with the one recorded at build time, and runs `composer
+// dump-autoload --optimize` if they differ, so that the optimized classmap
+// does not refer to stale classes.
+//
+// It is run by the `refresh-autoloader` exec.d executable at launch. As
+// `composer` is not necessarily available at launch, a missing `composer` is
+// logged rather than failing the container start.
+func RefreshAutoloader(
+	logger scribe.Emitter,
+	dumpAutoloadExec Executable,
+	calculator Calculator,
+	appDir, composerJsonPath, vendorDir, checksumPath, path string) error {
+	expectedChecksum, err := os.ReadFile(checksumPath)
+	if err != nil {
+		return fmt.Errorf("failed to read autoload checksum: %w", err)
+	}
+
+	checksum, err := autoloadChecksum(composerJsonPath, calculator)
+	if err != nil {
+		return err
+	}
+
+	if checksum == strings.TrimSpace(string(expectedChecksum)) {
+		logger.Debug.Process("Autoloaded sources are unchanged, skipping autoloader refresh")
+		return nil
+	}
+
+	if _, err := lookPath("composer", path); err != nil {
+		logger.Process("Autoloaded sources have changed, but the autoloader cannot be refreshed: %s", err)
+		return nil
+	}
+
+	args := []string{"dump-autoload", "--optimize", "--no-interaction"}
+	logger.Process("Autoloaded sources have changed, running 'composer %s'", strings.Join(args, " "))
+
+	err = dumpAutoloadExec.Execute(pexec.Execution{
+		Args: args,
+		Dir:  appDir,
+		Env: append(os.Environ(),
+			fmt.Sprintf("COMPOSER=%s", composerJsonPath),
+			fmt.Sprintf("COMPOSER_VENDOR_DIR=%s", vendorDir),
+			fmt.Sprintf("PATH=%s", path),
+		),
+		Stdout: logger.ActionWriter,
+		Stderr: logger.ActionWriter,
+	})
+	if err != nil {
+		return err
+	}
+
+	// the layer may be read-only at launch, in which case the autoloader is
+	// refreshed on each start
+	err = os.WriteFile(checksumPath, []byte(checksum), 0644)
+	if err != nil {
+		logger.Debug.Subprocess("Unable to update %s: %s", checksumPath, err)
+	}
+
+	return nil
+}
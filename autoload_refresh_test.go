@@ -0,0 +1,204 @@
+package composer_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/paketo-buildpacks/composer/fakes"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testAutoloadRefresh(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir       string
+		composerJsonPath string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		composerJsonPath = filepath.Join(workingDir, "composer.json")
+
+		Expect(os.MkdirAll(filepath.Join(workingDir, "src"), os.ModePerm)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(workingDir, "lib"), os.ModePerm)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(workingDir, "legacy"), os.ModePerm)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("FindAutoloadPaths", func() {
+		context("when composer.json does not exist", func() {
+			it("returns no paths", func() {
+				paths, err := composer.FindAutoloadPaths(composerJsonPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(paths).To(BeEmpty())
+			})
+		})
+
+		context("when composer.json contains autoload rules", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(composerJsonPath, []byte(`{
+	"autoload": {
+		"psr-4": {
+			"App\\": "src/",
+			"Lib\\": ["lib/", "src/", "missing/"]
+		},
+		"psr-0": {
+			"Legacy_": "legacy/"
+		},
+		"classmap": ["legacy/"]
+	},
+	"autoload-dev": {
+		"psr-4": {"Tests\\": "tests/"}
+	}
+}`), os.ModePerm)).To(Succeed())
+			})
+
+			it("returns the existing autoloaded paths", func() {
+				paths, err := composer.FindAutoloadPaths(composerJsonPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(paths).To(Equal([]string{
+					filepath.Join(workingDir, "legacy"),
+					filepath.Join(workingDir, "lib"),
+					filepath.Join(workingDir, "src"),
+				}))
+			})
+		})
+
+		context("when composer.json is written on Windows", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(composerJsonPath, []byte("\xef\xbb\xbf{\r\n"+
+					`	"autoload": {"psr-4": {"App\\": "app\\src\\"}}`+"\r\n"+
+					"}\r\n"), os.ModePerm)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "app", "src"), os.ModePerm)).To(Succeed())
+			})
+
+			it("returns the autoloaded paths with slashes", func() {
+				paths, err := composer.FindAutoloadPaths(composerJsonPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(paths).To(Equal([]string{
+					filepath.Join(workingDir, "app", "src"),
+				}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when composer.json is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(composerJsonPath, []byte(`%%%`), os.ModePerm)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := composer.FindAutoloadPaths(composerJsonPath)
+					Expect(err).To(MatchError(ContainSubstring("invalid character")))
+				})
+			})
+		})
+	})
+
+	context("RefreshAutoloader", func() {
+		var (
+			buffer           *bytes.Buffer
+			logger           scribe.Emitter
+			dumpAutoloadExec *fakes.Executable
+			calculator       *fakes.Calculator
+			checksumPath     string
+			pathDir          string
+		)
+
+		it.Before(func() {
+			buffer = bytes.NewBuffer(nil)
+			logger = scribe.NewEmitter(buffer).WithLevel("DEBUG")
+			dumpAutoloadExec = &fakes.Executable{}
+			calculator = &fakes.Calculator{}
+			calculator.SumCall.Returns.String = "current-checksum"
+
+			checksumPath = filepath.Join(workingDir, "autoload-checksum")
+			pathDir = filepath.Join(workingDir, "bin")
+			Expect(os.MkdirAll(pathDir, os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(pathDir, "composer"), []byte(""), 0755)).To(Succeed())
+
+			Expect(os.WriteFile(composerJsonPath, []byte(`{"autoload": {"psr-4": {"App\\": "src/"}}}`), os.ModePerm)).To(Succeed())
+		})
+
+		context("when the autoloaded sources are unchanged", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(checksumPath, []byte("current-checksum"), os.ModePerm)).To(Succeed())
+			})
+
+			it("does not refresh the autoloader", func() {
+				err := composer.RefreshAutoloader(logger, dumpAutoloadExec, calculator, workingDir, composerJsonPath, filepath.Join(workingDir, "vendor"), checksumPath, pathDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(calculator.SumCall.Receives.Paths).To(Equal([]string{filepath.Join(workingDir, "src")}))
+				Expect(dumpAutoloadExec.ExecuteCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when the autoloaded sources have changed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(checksumPath, []byte("build-checksum"), os.ModePerm)).To(Succeed())
+			})
+
+			it("runs composer dump-autoload", func() {
+				err := composer.RefreshAutoloader(logger, dumpAutoloadExec, calculator, workingDir, composerJsonPath, filepath.Join(workingDir, "vendor"), checksumPath, pathDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(dumpAutoloadExec.ExecuteCall.CallCount).To(Equal(1))
+
+				execution := dumpAutoloadExec.ExecuteCall.Receives.Execution
+				Expect(execution.Args).To(Equal([]string{"dump-autoload", "--optimize", "--no-interaction"}))
+				Expect(execution.Dir).To(Equal(workingDir))
+				Expect(execution.Env).To(ContainElement("COMPOSER=" + composerJsonPath))
+				Expect(execution.Env).To(ContainElement("COMPOSER_VENDOR_DIR=" + filepath.Join(workingDir, "vendor")))
+				Expect(execution.Env).To(ContainElement("PATH=" + pathDir))
+
+				contents, err := os.ReadFile(checksumPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal("current-checksum"))
+			})
+
+			context("when composer is not on the path", func() {
+				it("skips the refresh", func() {
+					err := composer.RefreshAutoloader(logger, dumpAutoloadExec, calculator, workingDir, composerJsonPath, filepath.Join(workingDir, "vendor"), checksumPath, workingDir)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(dumpAutoloadExec.ExecuteCall.CallCount).To(Equal(0))
+					Expect(buffer.String()).To(ContainSubstring("Autoloaded sources have changed, but the autoloader cannot be refreshed"))
+				})
+			})
+
+			context("when composer dump-autoload fails", func() {
+				it.Before(func() {
+					dumpAutoloadExec.ExecuteCall.Stub = func(pexec.Execution) error {
+						return os.ErrPermission
+					}
+				})
+
+				it("returns an error", func() {
+					err := composer.RefreshAutoloader(logger, dumpAutoloadExec, calculator, workingDir, composerJsonPath, filepath.Join(workingDir, "vendor"), checksumPath, pathDir)
+					Expect(err).To(MatchError(os.ErrPermission))
+				})
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the checksum file does not exist", func() {
+				it("returns an error", func() {
+					err := composer.RefreshAutoloader(logger, dumpAutoloadExec, calculator, workingDir, composerJsonPath, filepath.Join(workingDir, "vendor"), checksumPath, pathDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to read autoload checksum")))
+				})
+			})
+		})
+	})
+}
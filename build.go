@@ -7,17 +7,17 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 
+	composersbom "github.com/paketo-buildpacks/composer/sbom"
 	"github.com/paketo-buildpacks/packit/v2"
 	"github.com/paketo-buildpacks/packit/v2/chronos"
 	"github.com/paketo-buildpacks/packit/v2/draft"
 	"github.com/paketo-buildpacks/packit/v2/fs"
 	"github.com/paketo-buildpacks/packit/v2/pexec"
 	"github.com/paketo-buildpacks/packit/v2/sbom"
-	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
 )
 
 const (
@@ -25,11 +25,83 @@ const (
 	opensslExtension             = "openssl"
 )
 
+// CacheStatus describes whether the cached composer-packages layer has been
+// reused, or why it has not. It is recorded as "cache-status" in the layer
+// metadata.
+type CacheStatus string
+
+const (
+	// CacheStatusHit means the cached layer has been reused
+	CacheStatusHit CacheStatus = "hit"
+
+	// CacheStatusMiss means there was no cached layer, or its cached
+	// workspace files have been modified
+	CacheStatusMiss CacheStatus = "miss"
+
+	// CacheStatusStaleStack means the cached layer was built on another stack
+	CacheStatusStaleStack CacheStatus = "stale-stack"
+
+	// CacheStatusRevalidatedStack means the cached layer was built on another
+	// stack, and its packages have been installed again and checked against
+	// the current stack, see BP_COMPOSER_REVALIDATE_STACK
+	CacheStatusRevalidatedStack CacheStatus = "revalidated-stack"
+
+	// CacheStatusStaleLock means the cached layer was built from another composer.lock
+	CacheStatusStaleLock CacheStatus = "stale-lock"
+
+	// CacheStatusStaleConfig means the cached layer was built with other
+	// settings of BP_COMPOSER_CONFIG
+	CacheStatusStaleConfig CacheStatus = "stale-config"
+
+	// CacheStatusStaleInputs means the cached layer was built with other
+	// layer inputs, such as the install options or BP_COMPOSER_CACHE_KEY_SALT
+	CacheStatusStaleInputs CacheStatus = "stale-inputs"
+
+	// CacheStatusStaleDevMode means the cached layer was built with the dev
+	// packages, which are excluded now with `--no-dev`, or the other way
+	// round, and composer removed or installed them in its packages
+	CacheStatusStaleDevMode CacheStatus = "stale-dev-mode"
+
+	// CacheStatusStaleTarget means the cached layer contains native binaries,
+	// which have been installed for another build target
+	CacheStatusStaleTarget CacheStatus = "stale-target"
+
+	// CacheStatusStaleComposer means the cached layer was built with another
+	// minor version of Composer
+	CacheStatusStaleComposer CacheStatus = "stale-composer"
+
+	// CacheStatusStaleLayout means the cached layer has been built with
+	// another layout, which cannot be migrated
+	CacheStatusStaleLayout CacheStatus = "stale-layout"
+
+	// CacheStatusVendorOnly means composer did not run, and the vendored
+	// packages of the application have been copied into a new layer
+	CacheStatusVendorOnly CacheStatus = "vendor-only"
+
+	// CacheStatusVendorPreserved means composer did not install any package,
+	// as the application has vendored packages but no composer.lock
+	CacheStatusVendorPreserved CacheStatus = "vendor-preserved"
+)
+
+// CacheMissStatuses are the cache statuses for which the composer-packages
+// layer has been built from scratch, i.e. there was no cached layer or it was
+// stale. The other statuses reuse the cached layer or the vendored packages.
+var CacheMissStatuses = []CacheStatus{
+	CacheStatusMiss,
+	CacheStatusStaleStack,
+	CacheStatusStaleLock,
+	CacheStatusStaleConfig,
+	CacheStatusStaleInputs,
+	CacheStatusStaleTarget,
+	CacheStatusStaleComposer,
+	CacheStatusStaleLayout,
+}
+
 // DetermineComposerInstallOptions defines the interface to get options for `composer install`
 //
 //go:generate faux --interface DetermineComposerInstallOptions --output fakes/determine_composer_install_options.go
 type DetermineComposerInstallOptions interface {
-	Determine() []string
+	Determine(plan packit.BuildpackPlan, projectConfig map[string]string) []InstallOption
 }
 
 // Executable just provides a fake for pexec.Executable for testing
@@ -44,6 +116,14 @@ type SBOMGenerator interface {
 	Generate(dir string) (sbom.SBOM, error)
 }
 
+// BindingResolver defines the interface for resolving the service bindings
+// of a given type.
+//
+//go:generate faux --interface BindingResolver --output fakes/binding_resolver.go
+type BindingResolver interface {
+	Resolve(typ, provider, platformDir string) ([]servicebindings.Binding, error)
+}
+
 // Calculator defines the interface for calculating a checksum of the given set
 // of file paths.
 //
@@ -52,26 +132,271 @@ type Calculator interface {
 	Sum(paths ...string) (string, error)
 }
 
-func Build(
-	logger scribe.Emitter,
-	composerInstallOptions DetermineComposerInstallOptions,
-	composerConfigExec Executable,
-	composerInstallExec Executable,
-	composerGlobalExec Executable,
-	checkPlatformReqsExec Executable,
-	sbomGenerator SBOMGenerator,
-	path string,
-	calculator Calculator,
-	clock chronos.Clock) packit.BuildFunc {
-	return func(context packit.BuildContext) (packit.BuildResult, error) {
+// BuildDependencies are the dependencies of Build, which embedders can
+// replace, and tests fake.
+type BuildDependencies struct {
+	// Logger receives the log output of the build, see NewEmitterLogger for
+	// the default output
+	Logger Logger
+
+	InstallOptions DetermineComposerInstallOptions
+
+	// the `composer` executables of the steps of the build, and the `php`
+	// executable checking the PHP version
+	ComposerConfigExec    Executable
+	ComposerInstallExec   Executable
+	ComposerGlobalExec    Executable
+	CheckPlatformReqsExec Executable
+	ComposerVersionExec   Executable
+	ComposerOutdatedExec  Executable
+	ComposerLicensesExec  Executable
+	PhpVersionExec        Executable
+
+	ComposerDownloader ComposerDownloader
+	BindingResolver    BindingResolver
+	Timestamper        Timestamper
+	DiskSpace          DiskSpace
+	FileSystem         FileSystem
+	SpanExporter       SpanExporter
+	SBOMGenerator      SBOMGenerator
+
+	// Path is the PATH of the `composer` and `php` executions
+	Path string
+
+	Calculator Calculator
+	Clock      chronos.Clock
+
+	// Hooks run at fixed phases of the build, in the given order, see Hook
+	Hooks []Hook
+}
+
+// Build installs the dependencies with `composer install` into the
+// composer-packages layer with the given dependencies.
+func Build(dependencies BuildDependencies) packit.BuildFunc {
+	var (
+		buildLogger            = dependencies.Logger
+		composerInstallOptions = dependencies.InstallOptions
+		composerConfigExec     = dependencies.ComposerConfigExec
+		composerInstallExec    = dependencies.ComposerInstallExec
+		composerGlobalExec     = dependencies.ComposerGlobalExec
+		checkPlatformReqsExec  = dependencies.CheckPlatformReqsExec
+		composerVersionExec    = dependencies.ComposerVersionExec
+		composerOutdatedExec   = dependencies.ComposerOutdatedExec
+		composerLicensesExec   = dependencies.ComposerLicensesExec
+		phpVersionExec         = dependencies.PhpVersionExec
+		composerDownloader     = dependencies.ComposerDownloader
+		bindingResolver        = dependencies.BindingResolver
+		timestamper            = dependencies.Timestamper
+		diskSpace              = dependencies.DiskSpace
+		fileSystem             = dependencies.FileSystem
+		spanExporter           = dependencies.SpanExporter
+		sbomGenerator          = dependencies.SBOMGenerator
+		path                   = dependencies.Path
+		calculator             = dependencies.Calculator
+		clock                  = dependencies.Clock
+		hooks                  = dependencies.Hooks
+	)
+
+	logger := newEmitter(buildLogger)
+
+	return func(context packit.BuildContext) (_ packit.BuildResult, err error) {
+		// the output held back for the build, e.g. of a failed command, may
+		// not end with a newline
+		defer logger.flush()
+
 		logger.Title("%s %s", context.BuildpackInfo.Name, context.BuildpackInfo.Version)
+		startedOn := clock.Now()
 
-		composerPhpIniPath, err := writeComposerPhpIni(logger, context)
-		if err != nil { // untested
+		tracer, err := newTracer(logger, clock, context.BuildpackInfo.ID)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		endBuildSpan := tracer.Start("build", map[string]string{
+			"buildpack.id":      context.BuildpackInfo.ID,
+			"buildpack.version": context.BuildpackInfo.Version,
+		})
+		defer func() {
+			endBuildSpan(err)
+			tracer.export(logger, spanExporter)
+		}()
+
+		projectConfig, err := applyProjectConfig(logger, context.WorkingDir)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		// the SBOM is formatted before the files are normalized, and fails on
+		// an invalid SOURCE_DATE_EPOCH with an error of its own
+		if _, _, err = lookupReproducibility(); err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		err = synthesizeComposerJsonIfRequired(logger, context.WorkingDir)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		vendorOnly, err := vendorOnlyBuildRequested(context.WorkingDir)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		if vendorOnly {
+			installOptions := composerInstallOptions.Determine(context.Plan, projectConfig)
+			return buildVendorOnly(logger, context, fileSystem, installOptions, sbomGenerator, calculator, clock, tracer, hooks)
+		} else if enabled, _ := lookupBoolEnv(BpComposerValidateVendorOnly, false); enabled {
+			logger.Process("No vendored packages found, running composer despite %s", BpComposerValidateVendorOnly)
+			logger.Break()
+		}
+
+		profile, err := determineBuildProfile(logger)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		network, err := determineNetworkSettings(logger, profile)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		verbosity, err := determineOutputVerbosity(logger)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		installStrategy, err := lookupInstallStrategy(logger)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		rootVersionEnv, err := determineComposerRootVersion(logger, context.WorkingDir)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		tls, err := prepareComposerTLSIfRequired(logger, context, bindingResolver)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		// the CA bundle is only used during the build
+		defer func() {
+			_ = tls.cleanup()
+		}()
+
+		repositories, err := resolveComposerRepositories(logger, context, bindingResolver)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		// only `composer install` runs the scripts of the packages, so it is
+		// the only command run as BP_COMPOSER_RUN_AS
+		runAs, err := lookupRunAs(logger)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		ssh, err := prepareComposerSSHIfRequired(logger, context, bindingResolver, runAs)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		// the private keys must not end up in any layer
+		defer func() {
+			_ = ssh.cleanup()
+		}()
+
+		tmpDir, err := prepareComposerTmpDir(logger, context, fileSystem)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		defer func() {
+			_ = tmpDir.cleanup()
+		}()
+
+		// record every execution, so that the exact environment of each
+		// command can be inspected after the build, and trace its duration
+		commandLog := newCommandLog(logger)
+		env := append(append(append(append(append(append([]string{}, network.env...), verbosity.env...), rootVersionEnv...), profile.env...), tls.env...), composerFileEnv(context.WorkingDir)...)
+
+		// the scoped environment variables are added last, so that they
+		// take precedence over any other environment variable
+		installEnv := lookupScopedEnv(logger, BpComposerInstallEnvPrefix, "composer install")
+		globalEnv := lookupScopedEnv(logger, BpComposerGlobalEnvPrefix, "composer global")
+
+		composerConfigExec := tmpDir.wrap(withEnv(commandLog.Wrap(tracer.Wrap(composerConfigExec)), env...))
+		composerInstallExec := tmpDir.wrap(withEnv(withEnv(withEnv(withEnv(commandLog.Wrap(tracer.Wrap(withRunAs(composerInstallExec, "composer", runAs, filepath.Join(context.CNBPath, "bin", RunAsHelper)))), installEnv...), env...), ssh.env...), repositories.env...))
+		composerGlobalExec := tmpDir.wrap(withEnv(withEnv(withEnv(commandLog.Wrap(tracer.Wrap(composerGlobalExec)), globalEnv...), env...), ssh.env...))
+		checkPlatformReqsExec := tmpDir.wrap(withEnv(commandLog.Wrap(tracer.Wrap(checkPlatformReqsExec)), env...))
+		composerVersionExec := tmpDir.wrap(withEnv(commandLog.Wrap(tracer.Wrap(composerVersionExec)), env...))
+		composerOutdatedExec := tmpDir.wrap(withEnv(withEnv(commandLog.Wrap(tracer.Wrap(composerOutdatedExec)), env...), repositories.env...))
+		composerLicensesExec := tmpDir.wrap(withEnv(commandLog.Wrap(tracer.Wrap(composerLicensesExec)), env...))
+		phpVersionExec := tmpDir.wrap(withEnv(commandLog.Wrap(tracer.Wrap(phpVersionExec)), env...))
+
+		scriptPath, err := lookupScriptPathPrepend(logger)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+		composerInstallExec = withPathPrepend(composerInstallExec, scriptPath)
+
+		// the commands downloading packages are diagnosed if they fail
+		// because of the network
+		composerInstallExec = withConnectivityDiagnosis(logger, composerInstallExec)
+		composerGlobalExec = withConnectivityDiagnosis(logger, composerGlobalExec)
+
+		// the output is held back outside of the diagnosis, which reads it
+		composerInstallExec = withQuietOutput(verbosity, composerInstallExec)
+		composerGlobalExec = withQuietOutput(verbosity, composerGlobalExec)
+
+		defer func() {
+			if err != nil {
+				// any `composer` executable can run `composer diagnose`
+				writeSupportBundleIfRequired(logger, context, commandLog, composerVersionExec, path, err)
+			}
+		}()
+
+		composerFallbackBin, composerFallbackLayer, err := provisionComposerIfRequired(logger, context, composerDownloader, path)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		if composerFallbackBin != "" {
+			path = strings.Join([]string{
+				composerFallbackBin,
+				path,
+			}, string(os.PathListSeparator))
+		}
+
+		// the composer binary is verified before it runs for the first time
+		err = verifyComposerBinaryIfRequired(logger, path)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		bootstrapExtensions := lookupBootstrapExtensions()
+
+		composerPhpIniPath, err := writeComposerPhpIni(logger, context, fileSystem, bootstrapExtensions, network.disableHTTP2, profile.phpIni, tls.phpIni)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		composerJsonPath, composerLockPath, _, _ := FindComposerFiles(context.WorkingDir)
+
+		capabilities, err := determineComposerCapabilities(logger, composerVersionExec, composerPhpIniPath, path, composerLockPath)
+		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
-		composerGlobalBin, err := runComposerGlobalIfRequired(logger, context, composerGlobalExec, path, composerPhpIniPath)
+		// a mismatching PHP version would otherwise fail `composer install`
+		// with resolution errors for each package
+		err = checkPhpVersion(logger, phpVersionExec, composerJsonPath, composerLockPath, composerPhpIniPath, path)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		composerGlobalBin, err := runComposerGlobalIfRequired(logger, context, fileSystem, composerGlobalExec, path, composerPhpIniPath)
 		if err != nil { // untested
 			return packit.BuildResult{}, err
 		}
@@ -83,25 +408,162 @@ func Build(
 			}, string(os.PathListSeparator))
 		}
 
-		workspaceVendorDir := filepath.Join(context.WorkingDir, "vendor")
+		workspaceVendorDir, err := FindVendorDir(context.WorkingDir, composerJsonPath)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		err = logWorkspaceFindings(logger, context.WorkingDir, composerJsonPath, composerLockPath, workspaceVendorDir)
+		if err != nil { // untested
+			return packit.BuildResult{}, err
+		}
+
+		err = checkAbandonedPackages(logger, composerLockPath)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		err = checkPackagePolicy(logger, context.WorkingDir, composerLockPath)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		err = checkStabilityPolicyIfRequired(logger, composerJsonPath, composerLockPath)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		err = checkAllowedHostsIfRequired(logger, composerLockPath)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		err = checkDiskSpaceIfRequired(logger, diskSpace, composerLockPath, context.WorkingDir, context.Layers.Path)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		composerHomeLayer, err := prepareComposerHomeLayer(logger, context)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		composerConfig, err := lookupComposerConfig()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
 
-		if value, found := os.LookupEnv(ComposerVendorDir); found {
-			workspaceVendorDir = filepath.Join(context.WorkingDir, value)
+		err = applyComposerConfigIfRequired(logger, composerConfigExec, &composerHomeLayer, composerConfig, composerPhpIniPath, path)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		err = repositories.apply(&composerHomeLayer)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		vcsCacheLayer, vcsCached, err := prepareVCSCacheLayerIfRequired(logger, context, composerJsonPath, repositories, composerConfig)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		vcsCacheDir := ""
+		if vcsCached {
+			vcsCacheDir = vcsCacheLayer.Path
+		}
+
+		err = applyVCSCacheDir(&composerHomeLayer, vcsCacheDir)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		packageStoreLayer, packageStored, err := preparePackageStoreLayerIfRequired(logger, context)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		packageStoreDir := ""
+		if packageStored {
+			packageStoreDir = packageStoreLayer.Path
+		}
+
+		sandbox, err := prepareComposerSandbox(logger, context, fileSystem, runAs, workspaceVendorDir)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		providedExtensions := lookupProvidedExtensions(context.Plan)
+
+		installOptions := composerInstallOptions.Determine(context.Plan, projectConfig)
+		installOptions = ignoreProvidedExtensions(installOptions, providedExtensions)
+		installOptions = capabilities.adjustInstallOptions(logger, installOptions)
+		logInstallOptions(logger, "Options for 'composer install'", installOptions)
+
+		reinstallOptions, found := determineReinstallOptions(installOptions, projectConfig)
+		if found {
+			reinstallOptions = ignoreProvidedExtensions(reinstallOptions, providedExtensions)
+			reinstallOptions = capabilities.adjustInstallOptions(logger, reinstallOptions)
+			logInstallOptions(logger, "Options for 'composer install' from cached files", reinstallOptions)
+		}
+
+		hookContext := HookContext{
+			BuildContext:       context,
+			Logger:             contextLogger{emitter: logger},
+			InstallOptions:     installOptions,
+			WorkspaceVendorDir: workspaceVendorDir,
+		}
+
+		err = runHooks(hooks, "BeforeInstall", func(hook Hook) error {
+			return hook.BeforeInstall(hookContext)
+		})
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		preserveVendored, err := vendorPreservationRequested(composerLockPath, workspaceVendorDir)
+		if err != nil {
+			return packit.BuildResult{}, err
 		}
 
 		var composerPackagesLayer packit.Layer
 		logger.Process("Executing build process")
 		duration, err := clock.Measure(func() error {
+			if preserveVendored {
+				composerPackagesLayer, err = preserveVendor(
+					logger,
+					context,
+					installOptions,
+					composerPhpIniPath,
+					path,
+					composerInstallExec,
+					workspaceVendorDir,
+					composerHomeLayer.Path,
+					tracer)
+				return err
+			}
+
 			composerPackagesLayer, err = runComposerInstall(
 				logger,
 				context,
-				composerInstallOptions,
+				fileSystem,
+				installOptions,
+				reinstallOptions,
 				composerPhpIniPath,
 				path,
 				composerConfigExec,
 				composerInstallExec,
+				installStrategy,
+				checkPlatformReqsExec,
+				capabilities,
 				workspaceVendorDir,
-				calculator)
+				composerHomeLayer.Path,
+				packageStoreDir,
+				composerConfigChecksum(composerConfig),
+				sandbox,
+				calculator,
+				clock,
+				tracer)
 			return err
 		})
 		if err != nil {
@@ -110,34 +572,199 @@ func Build(
 		logger.Action("Completed in %s", duration.Round(time.Millisecond))
 		logger.Break()
 
-		logger.GeneratingSBOM(composerPackagesLayer.Path)
+		hookContext.ComposerPackagesLayer = &composerPackagesLayer
 
-		var sbomContent sbom.SBOM
-		duration, err = clock.Measure(func() error {
-			sbomContent, err = sbomGenerator.Generate(context.WorkingDir)
-			return err
+		if composerPackagesLayer.Metadata["cache-status"] == string(CacheStatusHit) {
+			err = runHooks(hooks, "AfterCacheRestore", func(hook Hook) error {
+				return hook.AfterCacheRestore(hookContext)
+			})
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+		}
+
+		err = runHooks(hooks, "AfterInstall", func(hook Hook) error {
+			return hook.AfterInstall(hookContext)
 		})
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
-		logger.Action("Completed in %s", duration.Round(time.Millisecond))
-		logger.Break()
 
-		logger.FormattingSBOM(context.BuildpackInfo.SBOMFormats...)
+		vendorBinLayer, vendorBinInstalled, err := installVendorBinIfRequired(
+			logger,
+			context,
+			composerInstallExec,
+			composerJsonPath,
+			composerHomeLayer.Path,
+			composerPhpIniPath,
+			path,
+			calculator)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
 
-		composerPackagesLayer.SBOM, err = sbomContent.InFormats(context.BuildpackInfo.SBOMFormats...)
+		err = normalizeVendorPermissionsIfRequired(logger, workspaceVendorDir, filepath.Join(composerPackagesLayer.Path, "vendor"))
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
-		err = runCheckPlatformReqs(logger, checkPlatformReqsExec, context.WorkingDir, composerPhpIniPath, path)
+		configureLaunchEnv(logger, context, &composerPackagesLayer, workspaceVendorDir, installOptions)
+
+		processes, err := detectProcessesIfRequired(logger, context.WorkingDir, composerJsonPath, workspaceVendorDir, &composerPackagesLayer)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
+		err = configureAutoloadRefreshIfRequired(logger, context, &composerPackagesLayer, workspaceVendorDir, calculator)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		err = tracer.Trace("generate SBOM", nil, func() error {
+			return generateSBOMIfRequired(logger, context, fileSystem, sbomGenerator, clock, hooks, hookContext, &composerPackagesLayer)
+		})
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		err = checkLicensePolicyIfRequired(logger, &composerPackagesLayer)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		extensionsIniDir, err := configurePhpIniLayerIfRequired(logger, fileSystem, &composerPackagesLayer, context.WorkingDir, workspaceVendorDir)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		extensions, err := runCheckPlatformReqs(logger, fileSystem, checkPlatformReqsExec, capabilities, context.WorkingDir, extensionsIniDir, composerPhpIniPath, composerLockPath, path, bootstrapExtensions, providedExtensions)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		err = addPhpExtensionsToSBOMIfRequired(logger, &composerPackagesLayer, extensions)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		err = reportSuggestedPackagesIfRequired(logger, workspaceVendorDir, extensions)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		err = reportOutdatedPackagesIfRequired(
+			logger,
+			composerOutdatedExec,
+			context.WorkingDir,
+			composerJsonPath,
+			composerHomeLayer.Path,
+			workspaceVendorDir,
+			composerPhpIniPath,
+			path,
+			&composerPackagesLayer)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		licensesLayer, licensesWritten, err := writeLicenseReportIfRequired(
+			logger,
+			context,
+			fileSystem,
+			composerLicensesExec,
+			composerJsonPath,
+			composerHomeLayer.Path,
+			workspaceVendorDir,
+			composerPhpIniPath,
+			path,
+			installOptions)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		err = writeBuildStampIfRequired(logger, context, capabilities, workspaceVendorDir, composerPackagesLayer, clock)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		err = writeCommandLogIfRequired(logger, commandLog, composerPackagesLayer.Path)
+		if err != nil { // untested
+			return packit.BuildResult{}, err
+		}
+
+		labels, err := writeLockSnapshotIfRequired(logger, composerLockPath, &composerPackagesLayer)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		err = writeProvenanceIfRequired(
+			logger,
+			context,
+			commandLog,
+			timestamper,
+			composerPackagesLayer,
+			composerJsonPath,
+			composerLockPath,
+			installOptions,
+			calculator,
+			startedOn,
+			clock)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		// the files of the layer are written until here
+		err = normalizeForReproducibilityIfRequired(logger, composerPackagesLayer.Path, workspaceVendorDir)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		cacheStatus, _ := composerPackagesLayer.Metadata["cache-status"].(string)
+		err = writeMetricsIfRequired(logger, workspaceVendorDir, BuildMetrics{
+			Duration:        clock.Now().Sub(startedOn),
+			InstallDuration: duration,
+			CacheStatus:     CacheStatus(cacheStatus),
+			FinishedOn:      clock.Now(),
+		})
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		// the credentials must not end up in the cache
+		err = scrubComposerHomeLayer(logger, composerHomeLayer)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		layers := []packit.Layer{
+			composerPackagesLayer,
+			composerHomeLayer,
+		}
+
+		if composerFallbackBin != "" {
+			layers = append(layers, composerFallbackLayer)
+		}
+
+		if vendorBinInstalled {
+			layers = append(layers, vendorBinLayer)
+		}
+
+		if vcsCached {
+			layers = append(layers, vcsCacheLayer)
+		}
+
+		if packageStored {
+			layers = append(layers, packageStoreLayer)
+		}
+
+		if licensesWritten {
+			layers = append(layers, licensesLayer)
+		}
+
 		return packit.BuildResult{
-			Layers: []packit.Layer{
-				composerPackagesLayer,
+			Layers: layers,
+			Launch: packit.LaunchMetadata{
+				Processes: processes,
+				Labels:    labels,
 			},
 		}, nil
 	}
@@ -153,8 +780,9 @@ func Build(
 // `composer global require`: https://getcomposer.org/doc/03-cli.md#global
 // Composer scripts: https://getcomposer.org/doc/articles/scripts.md
 func runComposerGlobalIfRequired(
-	logger scribe.Emitter,
+	logger emitter,
 	context packit.BuildContext,
+	fileSystem FileSystem,
 	composerGlobalExec Executable,
 	path string,
 	composerPhpIniPath string) (composerGlobalBin string, err error) {
@@ -200,64 +828,338 @@ func runComposerGlobalIfRequired(
 
 	if os.Getenv(BpLogLevel) == "DEBUG" {
 		logger.Debug.Subprocess("Adding global Composer packages to PATH:")
-		files, err := os.ReadDir(composerGlobalBin)
-		if err != nil { // untested
+		files, err := fileSystem.ReadDir(composerGlobalBin)
+		if err != nil {
 			return "", err
 		}
-		for _, f := range files {
-			logger.Debug.Subprocess(fmt.Sprintf("- %s", f.Name()))
+		for _, f := range files {
+			logger.Debug.Subprocess(fmt.Sprintf("- %s", f.Name()))
+		}
+	}
+
+	return
+}
+
+// generateSBOMIfRequired generates the SBOM of the vendored packages into the
+// composer-packages layer, unless it is disabled with BP_DISABLE_SBOM. The SBOM
+// of the previous build is reused if composer.lock has not changed.
+func generateSBOMIfRequired(
+	logger emitter,
+	context packit.BuildContext,
+	fileSystem FileSystem,
+	sbomGenerator SBOMGenerator,
+	clock chronos.Clock,
+	hooks []Hook,
+	hookContext HookContext,
+	composerPackagesLayer *packit.Layer) error {
+	disableSBOM, err := lookupBoolEnv(BpDisableSBOM, false)
+	if err != nil {
+		return err
+	}
+
+	formats, err := determineSBOMFormats(context.BuildpackInfo.SBOMFormats)
+	if err != nil {
+		return err
+	}
+
+	if disableSBOM || len(formats) == 0 {
+		logger.Process("Skipping SBOM generation")
+		logger.Break()
+		return writeFileSBOMs(logger, sbom.SBOM{}, nil, composerPackagesLayer.Path)
+	}
+
+	layerFormats, fileFormats := composersbom.Split(formats)
+
+	err = runHooks(hooks, "BeforeSBOM", func(hook Hook) error {
+		return hook.BeforeSBOM(hookContext)
+	})
+	if err != nil {
+		return err
+	}
+
+	cached, found, err := readCachedSBOM(*composerPackagesLayer, formats)
+	if err != nil {
+		return err
+	}
+
+	// vendored packages are part of the image, even if they are dev packages,
+	// and SBOMs cached before the dev packages were excluded cannot be reused
+	cacheStatus := composerPackagesLayer.Metadata["cache-status"]
+	excludesDev := devPackagesExcluded(hookContext.InstallOptions) &&
+		cacheStatus != string(CacheStatusVendorPreserved) && cacheStatus != string(CacheStatusVendorOnly)
+	if cachedExcludesDev, _ := composerPackagesLayer.Metadata[sbomExcludesDevMetadataKey].(bool); cachedExcludesDev != excludesDev {
+		found = false
+	}
+
+	if found {
+		logger.Process("Reusing cached SBOM for unchanged composer.lock")
+		logger.Break()
+		composerPackagesLayer.SBOM = cached
+		return nil
+	}
+
+	cleanupSBOMSource, err := prepareSBOMSource(context.WorkingDir, hookContext.WorkspaceVendorDir, excludesDev)
+	if err != nil {
+		return err
+	}
+
+	logger.GeneratingSBOM(composerPackagesLayer.Path)
+	if excludesDev {
+		logger.Subprocess("Excluding the dev packages of composer.lock, as they have not been installed")
+	}
+
+	var sbomContent sbom.SBOM
+	duration, err := clock.Measure(func() error {
+		sbomContent, err = sbomGenerator.Generate(context.WorkingDir)
+		return err
+	})
+	restoreErr := cleanupSBOMSource()
+	if err != nil {
+		return err
+	}
+	if restoreErr != nil { // untested
+		return restoreErr
+	}
+	logger.Action("Completed in %s", duration.Round(time.Millisecond))
+	logger.Break()
+
+	logger.FormattingSBOM(formats...)
+
+	formatter, err := sbomContent.InFormats(layerFormats...)
+	if err != nil {
+		return err
+	}
+
+	composerPackagesLayer.SBOM, err = cacheSBOM(fileSystem, composerPackagesLayer, formatter, formats)
+	if err != nil {
+		return err
+	}
+	composerPackagesLayer.Metadata[sbomExcludesDevMetadataKey] = excludesDev
+
+	return writeFileSBOMs(logger, sbomContent, fileFormats, composerPackagesLayer.Path)
+}
+
+// runComposerInstall will run `composer install` to download dependencie into
+// the app directory, and will be copied into a layer and cached for reuse.
+//
+// Returns:
+// - composerPackagesLayer: a new layer into which the dependencies will be installed
+// - err: any error
+func runComposerInstall(
+	logger emitter,
+	context packit.BuildContext,
+	fileSystem FileSystem,
+	installOptions []InstallOption,
+	reinstallOptions []InstallOption,
+	composerPhpIniPath string,
+	path string,
+	composerConfigExec Executable,
+	composerInstallExec Executable,
+	installStrategy InstallStrategy,
+	checkPlatformReqsExec Executable,
+	capabilities composerCapabilities,
+	workspaceVendorDir string,
+	composerHome string,
+	packageStoreDir string,
+	configChecksum string,
+	sandbox composerSandbox,
+	calculator Calculator,
+	clock chronos.Clock,
+	tracer *Tracer) (composerPackagesLayer packit.Layer, err error) {
+
+	launch, build := draft.NewPlanner().MergeLayerTypes(ComposerPackagesDependency, context.Plan.Entries)
+
+	composerPackagesLayer, err = context.Layers.Get(ComposerPackagesLayerName)
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+
+	composerJsonPath, composerLockPath, _, _ := FindComposerFiles(context.WorkingDir)
+
+	layerVendorDir := filepath.Join(composerPackagesLayer.Path, "vendor")
+
+	// the cached workspace paths, such as the output of Composer plugins,
+	// may depend on other files than composer.lock, which are therefore
+	// part of the checksum
+	var checksumInputs []string
+	for _, cached := range defaultCachedWorkspacePaths() {
+		if cached.inputs == nil {
+			continue
+		}
+
+		inputs, err := cached.inputs(context.WorkingDir, composerJsonPath)
+		if err != nil {
+			return packit.Layer{}, err
+		}
+		checksumInputs = append(checksumInputs, inputs...)
+	}
+
+	// the excluded paths are missing from the cached layer, so it cannot be
+	// reused once they change
+	layerIgnore, err := LoadLayerIgnore(context.WorkingDir)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	if len(layerIgnore.Patterns()) > 0 {
+		checksumInputs = append(checksumInputs, filepath.Join(context.WorkingDir, LayerIgnoreFileName))
+	}
+
+	composerLockChecksum, err := calculator.Sum(append([]string{composerLockPath}, checksumInputs...)...)
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+
+	logger.Debug.Process("Calculated checksum of %s for composer.lock", composerLockChecksum)
+	for _, input := range checksumInputs {
+		logger.Debug.Subprocess("- including %s", input)
+	}
+
+	layoutOk, err := migrateLayer(logger, &composerPackagesLayer, ComposerPackagesMetadataVersion, composerPackagesMigrations)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	stack, stackOk := composerPackagesLayer.Metadata["stack"]
+	if stackOk {
+		logger.Debug.Process("Previous stack: %s", stack.(string))
+		logger.Debug.Process("Current stack: %s", context.Stack)
+	}
+
+	if configChecksum != "" {
+		logger.Debug.Process("Calculated checksum of %s for %s", configChecksum, BpComposerConfig)
+	}
+
+	autoloaderSuffix, err := lookupAutoloaderSuffix(context.Stack)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	if os.Getenv(BpComposerCacheKeySalt) != "" {
+		logger.Process("Segregating the composer packages cache with %s", BpComposerCacheKeySalt)
+	}
+
+	inputs := determineLayerInputs(autoloaderSuffix, installOptions)
+	logLayerInputs(logger, inputs)
+
+	autoloadInputs, err := determineAutoloadInputsIfRequired(logger, composerJsonPath, calculator)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	changedInputs := inputs.Changed(composerPackagesLayer.Metadata)
+	if len(changedInputs) > 0 {
+		logger.Debug.Process("Changed layer inputs: %s", strings.Join(changedInputs, ", "))
+	}
+
+	for _, name := range changedInputs {
+		if name == "distro" && inputs[name] != "" {
+			logger.Process("Rebuilding the layer for %s, as the cached packages may contain binaries built for another distribution", LookupBuildTarget())
+		}
+	}
+
+	cachedSHA, shaOk := composerPackagesLayer.Metadata["composer-lock-sha"].(string)
+	// layers cached without BP_COMPOSER_CONFIG have no checksum of it
+	cachedConfigSHA, _ := composerPackagesLayer.Metadata["composer-config-sha"].(string)
+	reuseLayer := layoutOk && (shaOk && cachedSHA == composerLockChecksum) && (stackOk && stack.(string) == context.Stack) && cachedConfigSHA == configChecksum && len(changedInputs) == 0
+
+	cacheStatus := CacheStatusHit
+	switch {
+	case !shaOk:
+		cacheStatus = CacheStatusMiss
+	case !layoutOk:
+		cacheStatus = CacheStatusStaleLayout
+	case cachedSHA != composerLockChecksum:
+		cacheStatus = CacheStatusStaleLock
+	case cachedConfigSHA != configChecksum:
+		cacheStatus = CacheStatusStaleConfig
+	case len(changedInputs) > 0:
+		cacheStatus = CacheStatusStaleInputs
+	case !stackOk || stack.(string) != context.Stack:
+		cacheStatus = CacheStatusStaleStack
+	}
+
+	// a layer built on another stack is rebuilt, unless it is revalidated
+	// against the current stack, see stackRevalidationRequested
+	revalidate := false
+	if cacheStatus == CacheStatusStaleStack && stackOk {
+		revalidate, err = stackRevalidationRequested(logger, layerVendorDir, stack.(string), context.Stack)
+		if err != nil {
+			return packit.Layer{}, err
+		}
+	}
+
+	// the packages of a layer built with or without the dev packages are
+	// reused, and composer only removes or installs the dev packages
+	if cacheStatus == CacheStatusStaleInputs && layoutOk && stackOk && stack.(string) == context.Stack {
+		switched, err := devModeSwitched(logger, composerPackagesLayer.Metadata, inputs, changedInputs, layerVendorDir, capabilities.version)
+		if err != nil {
+			return packit.Layer{}, err
+		}
+
+		if switched {
+			cacheStatus = CacheStatusStaleDevMode
 		}
 	}
 
-	return
-}
-
-// runComposerInstall will run `composer install` to download dependencie into
-// the app directory, and will be copied into a layer and cached for reuse.
-//
-// Returns:
-// - composerPackagesLayer: a new layer into which the dependencies will be installed
-// - err: any error
-func runComposerInstall(
-	logger scribe.Emitter,
-	context packit.BuildContext,
-	composerInstallOptions DetermineComposerInstallOptions,
-	composerPhpIniPath string,
-	path string,
-	composerConfigExec Executable,
-	composerInstallExec Executable,
-	workspaceVendorDir string,
-	calculator Calculator) (composerPackagesLayer packit.Layer, err error) {
+	// packages with native binaries only work on the target they have been
+	// installed for, even if composer.lock is unchanged
+	if reuseLayer {
+		staleTarget, err := nativeBinariesStale(logger, composerPackagesLayer.Metadata, layerVendorDir, LookupBuildTarget())
+		if err != nil {
+			return packit.Layer{}, err
+		}
 
-	launch, build := draft.NewPlanner().MergeLayerTypes(ComposerPackagesDependency, context.Plan.Entries)
+		if staleTarget {
+			reuseLayer = false
+			cacheStatus = CacheStatusStaleTarget
+		}
+	}
 
-	composerPackagesLayer, err = context.Layers.Get(ComposerPackagesLayerName)
-	if err != nil { // untested
-		return packit.Layer{}, err
+	// the autoloader and the plugins of Composer differ between its minor
+	// versions
+	if reuseLayer && composerVersionChanged(logger, composerPackagesLayer.Metadata, capabilities.version) {
+		reuseLayer = false
+		cacheStatus = CacheStatusStaleComposer
 	}
 
-	composerJsonPath, composerLockPath, _, _ := FindComposerFiles(context.WorkingDir)
+	// the cached workspace paths are part of the layer contents, so if they
+	// are missing or have been modified, the layer cannot be reused
+	for _, cached := range defaultCachedWorkspacePaths() {
+		cachedChecksum, ok := composerPackagesLayer.Metadata[cached.metadataKey()].(string)
+		if !reuseLayer || !ok {
+			continue
+		}
 
-	layerVendorDir := filepath.Join(composerPackagesLayer.Path, "vendor")
+		layerDir := filepath.Join(composerPackagesLayer.Path, cached.layerDir)
 
-	composerLockChecksum, err := calculator.Sum(composerLockPath)
-	if err != nil { // untested
-		return packit.Layer{}, err
-	}
+		checksum := ""
+		if exists, err := fs.Exists(layerDir); err != nil {
+			return packit.Layer{}, err
+		} else if exists {
+			checksum, err = calculator.Sum(layerDir)
+			if err != nil { // untested
+				return packit.Layer{}, err
+			}
+		}
 
-	logger.Debug.Process("Calculated checksum of %s for composer.lock", composerLockChecksum)
+		logger.Debug.Process("Calculated checksum of %s for cached %s files", checksum, cached.description)
+		reuseLayer = checksum == cachedChecksum
 
-	stack, stackOk := composerPackagesLayer.Metadata["stack"]
-	if stackOk {
-		logger.Debug.Process("Previous stack: %s", stack.(string))
-		logger.Debug.Process("Current stack: %s", context.Stack)
+		if !reuseLayer {
+			logger.Debug.Process("Cached %s files have been modified", cached.description)
+			cacheStatus = CacheStatusMiss
+		}
 	}
 
-	cachedSHA, shaOk := composerPackagesLayer.Metadata["composer-lock-sha"].(string)
-	if (shaOk && cachedSHA == composerLockChecksum) && (stackOk && stack.(string) == context.Stack) {
+	logger.Process("Composer packages cache: %s", cacheStatus)
+
+	if reuseLayer {
 		logger.Process("Reusing cached layer %s", composerPackagesLayer.Path)
 		logger.Break()
 
+		composerPackagesLayer.Metadata["cache-status"] = string(cacheStatus)
+
 		composerPackagesLayer.Launch, composerPackagesLayer.Build = launch, build
 		// the layer is always set to cache = true because we need it during subsequent builds to copy vendor into /workspace
 		composerPackagesLayer.Cache = true
@@ -269,8 +1171,8 @@ func runComposerInstall(
 
 		if os.Getenv(BpLogLevel) == "DEBUG" {
 			logger.Debug.Subprocess("Listing files in %s:", composerPackagesLayer)
-			files, err := os.ReadDir(composerPackagesLayer.Path)
-			if err != nil { // untested
+			files, err := fileSystem.ReadDir(composerPackagesLayer.Path)
+			if err != nil {
 				return packit.Layer{}, err
 			}
 			for _, f := range files {
@@ -282,18 +1184,26 @@ func runComposerInstall(
 		// directories other than the "vendor" directory.  See:
 		// https://getcomposer.org/doc/faqs/how-do-i-install-a-package-to-a-custom-path-for-my-framework.md
 		// for more information. This can be switched off by setting
-		// the environment variable "BP_RUN_COMPOSER_INSTALL" to false.
-		runComposerInstallOnCache := true
-		runComposerInstallStr, found := os.LookupEnv(runComposerInstallOnCacheEnv)
-		if found {
-			var err error
-			if runComposerInstallOnCache, err = strconv.ParseBool(runComposerInstallStr); err != nil {
-				return packit.Layer{}, fmt.Errorf("error when parsing env var %q: %w", runComposerInstallOnCacheEnv, err)
-			}
+		// the environment variable "BP_RUN_COMPOSER_INSTALL" to false, or
+		// to "auto" to only run it for projects with plugins or install
+		// scripts.
+		runComposerInstallOnCache, err := runComposerInstallOnCacheRequired(logger, composerJsonPath, composerLockPath)
+		if err != nil {
+			return packit.Layer{}, err
 		}
 
 		if runComposerInstallOnCache {
-			installArgs := append([]string{"install"}, composerInstallOptions.Determine()...)
+			installArgs := composerInstallArgs(reinstallOptions)
+
+			skipAutoloadDump, err := skipAutoloadDumpOnCache(logger, composerJsonPath, autoloadInputs, composerPackagesLayer.Metadata)
+			if err != nil {
+				return packit.Layer{}, err
+			}
+
+			if skipAutoloadDump {
+				installArgs = withNoAutoloader(installArgs)
+			}
+
 			logger.Process("Running 'composer %s' from cached files", strings.Join(installArgs, " "))
 
 			// install packages into /workspace/vendor because composer cannot handle symlinks easily
@@ -303,7 +1213,7 @@ func runComposerInstall(
 				Env: append(os.Environ(),
 					"COMPOSER_NO_INTERACTION=1", // https://getcomposer.org/doc/03-cli.md#composer-no-interaction
 					fmt.Sprintf("COMPOSER=%s", composerJsonPath),
-					fmt.Sprintf("COMPOSER_HOME=%s", filepath.Join(composerPackagesLayer.Path, ".composer")),
+					fmt.Sprintf("COMPOSER_HOME=%s", composerHome),
 					fmt.Sprintf("COMPOSER_VENDOR_DIR=%s", workspaceVendorDir),
 					fmt.Sprintf("PHPRC=%s", composerPhpIniPath),
 					fmt.Sprintf("PATH=%s", path),
@@ -311,50 +1221,117 @@ func runComposerInstall(
 				Stdout: logger.ActionWriter,
 				Stderr: logger.ActionWriter,
 			}
-			err = composerInstallExec.Execute(execution)
+			execution.Env = append(execution.Env, sandbox.env...)
+
+			// the cached layer contains all packages, so the refresh only
+			// needs to run the plugins and scripts again
+			offline, err := lookupBoolEnv(BpComposerOfflineRefresh, false)
+			if err != nil {
+				return packit.Layer{}, err
+			}
+
+			if offline {
+				logger.Subprocess("Network access is disabled with COMPOSER_DISABLE_NETWORK=1")
+				execution.Env = append(execution.Env, "COMPOSER_DISABLE_NETWORK=1") // https://getcomposer.org/doc/03-cli.md#composer-disable-network
+			}
+
+			warnings := NewComposerWarnings()
+			execution.Stderr = warnings.Writer(execution.Stderr)
+
+			err = sandbox.run(func() error {
+				return installStrategy.Install(InstallContext{
+					Logger:    contextLogger{emitter: logger},
+					Composer:  composerInstallExec,
+					Execution: execution,
+					FromCache: true,
+				})
+			})
 			if err != nil {
+				if offline {
+					return packit.Layer{}, fmt.Errorf("'composer install' from cached files failed without network access, unset %s if it needs to download packages: %w", BpComposerOfflineRefresh, err)
+				}
 				return packit.Layer{}, err
 			}
+
+			reportComposerWarnings(logger, warnings, composerPackagesLayer.Metadata)
 		}
 
-		if exists, err := fs.Exists(workspaceVendorDir); err != nil {
+		recordAutoloadInputs(composerPackagesLayer.Metadata, autoloadInputs)
+
+		err = restoreVendorDir(logger, fileSystem, tracer, layerVendorDir, workspaceVendorDir)
+		if err != nil {
 			return packit.Layer{}, err
-		} else if exists {
-			logger.Process("Detected existing vendored packages, replacing with cached vendored packages")
-			if err := os.RemoveAll(workspaceVendorDir); err != nil { // untested
+		}
+
+		for _, cached := range defaultCachedWorkspacePaths() {
+			layerDir := filepath.Join(composerPackagesLayer.Path, cached.layerDir)
+			if exists, err := fs.Exists(layerDir); err != nil {
 				return packit.Layer{}, err
+			} else if !exists {
+				continue
 			}
-		}
 
-		if err := fs.Copy(layerVendorDir, workspaceVendorDir); err != nil { // untested
-			return packit.Layer{}, err
+			var restored []string
+			err = tracer.Trace(fmt.Sprintf("restore %s files", cached.description), copyAttributes(layerDir, context.WorkingDir), func() error {
+				restored, err = restoreWorkspacePaths(fileSystem, layerDir, context.WorkingDir)
+				return err
+			})
+			if err != nil {
+				return packit.Layer{}, err
+			}
+
+			logger.Process("Restored %d cached %s file(s)", len(restored), cached.description)
+			for _, file := range restored {
+				logger.Debug.Subprocess("- %s", file)
+			}
 		}
 
 		return composerPackagesLayer, nil
 	}
 
+	err = runInstallDryRunIfRequired(
+		logger,
+		composerInstallExec,
+		installOptions,
+		context.WorkingDir,
+		composerJsonPath,
+		composerHome,
+		workspaceVendorDir,
+		composerPhpIniPath,
+		path)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
 	logger.Process("Building new layer %s", composerPackagesLayer.Path)
 
-	composerPackagesLayer, err = composerPackagesLayer.Reset()
-	if err != nil { // untested
+	// the new contents are staged, and only replace the contents of the layer
+	// once install, copy and autoload dump have succeeded, so that a failed
+	// build keeps the previously cached contents
+	staging, err := stageLayer(logger, fileSystem, composerPackagesLayer)
+	if err != nil {
 		return packit.Layer{}, err
 	}
+	defer staging.cleanup()
+
+	metadata := map[string]interface{}{
+		MetadataVersionKey:     ComposerPackagesMetadataVersion,
+		"stack":                context.Stack,
+		"composer-lock-sha":    composerLockChecksum,
+		"cache-status":         string(cacheStatus),
+		layerInputsMetadataKey: inputs.Metadata(),
+	}
 
-	composerPackagesLayer.Launch, composerPackagesLayer.Build = launch, build
-	// the layer is always set to cache = true because we need it during subsequent builds to copy vendor into /workspace
-	composerPackagesLayer.Cache = true
-
-	logger.Debug.Subprocess("Setting layer types: launch=[%t], build=[%t], cache=[%t]",
-		composerPackagesLayer.Launch,
-		composerPackagesLayer.Build,
-		composerPackagesLayer.Cache)
+	if configChecksum != "" {
+		metadata["composer-config-sha"] = configChecksum
+	}
 
-	composerPackagesLayer.Metadata = map[string]interface{}{
-		"stack":             context.Stack,
-		"composer-lock-sha": composerLockChecksum,
+	if capabilities.version != "" {
+		metadata[composerVersionMetadataKey] = capabilities.version
 	}
+	recordAutoloadInputs(metadata, autoloadInputs)
 
-	args := []string{"config", "autoloader-suffix", ComposerAutoloaderSuffix}
+	args := []string{"config", "autoloader-suffix", autoloaderSuffix}
 	logger.Process("Running 'composer %s'", strings.Join(args, " "))
 
 	execution := pexec.Execution{
@@ -363,7 +1340,7 @@ func runComposerInstall(
 		Env: append(os.Environ(),
 			"COMPOSER_NO_INTERACTION=1", // https://getcomposer.org/doc/03-cli.md#composer-no-interaction
 			fmt.Sprintf("COMPOSER=%s", composerJsonPath),
-			fmt.Sprintf("COMPOSER_HOME=%s", filepath.Join(composerPackagesLayer.Path, ".composer")),
+			fmt.Sprintf("COMPOSER_HOME=%s", composerHome),
 			"COMPOSER_VENDOR_DIR=vendor", // ensure default in the layer
 			fmt.Sprintf("PHPRC=%s", composerPhpIniPath),
 			fmt.Sprintf("PATH=%s", path),
@@ -384,7 +1361,7 @@ func runComposerInstall(
 	// set up, and then `composer dump-autoload` on the vendor directory from
 	// the working directory.
 
-	installArgs := append([]string{"install"}, composerInstallOptions.Determine()...)
+	installArgs := composerInstallArgs(installOptions)
 	logger.Process("Running 'composer %s'", strings.Join(installArgs, " "))
 
 	// install packages into /workspace/vendor because composer cannot handle symlinks easily
@@ -394,7 +1371,7 @@ func runComposerInstall(
 		Env: append(os.Environ(),
 			"COMPOSER_NO_INTERACTION=1", // https://getcomposer.org/doc/03-cli.md#composer-no-interaction
 			fmt.Sprintf("COMPOSER=%s", composerJsonPath),
-			fmt.Sprintf("COMPOSER_HOME=%s", filepath.Join(composerPackagesLayer.Path, ".composer")),
+			fmt.Sprintf("COMPOSER_HOME=%s", composerHome),
 			fmt.Sprintf("COMPOSER_VENDOR_DIR=%s", workspaceVendorDir),
 			fmt.Sprintf("PHPRC=%s", composerPhpIniPath),
 			fmt.Sprintf("PATH=%s", path),
@@ -402,22 +1379,167 @@ func runComposerInstall(
 		Stdout: logger.ActionWriter,
 		Stderr: logger.ActionWriter,
 	}
-	err = composerInstallExec.Execute(execution)
+	execution.Env = append(execution.Env, sandbox.env...)
+
+	downloadMetrics, err := newDownloadMetricsIfRequired(clock, composerLockPath)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	if downloadMetrics != nil {
+		execution.Env = append(execution.Env, downloadMetricsVerbosity)
+		execution.Stdout = downloadMetrics.Writer(logger.ActionWriter)
+		execution.Stderr = execution.Stdout
+	}
+
+	warnings := NewComposerWarnings()
+	execution.Stderr = warnings.Writer(execution.Stderr)
+
+	installWith, err := withDistIntegrityVerificationIfRequired(logger, fileSystem, capabilities, func(execution pexec.Execution) error {
+		return sandbox.run(func() error {
+			return installStrategy.Install(InstallContext{
+				Logger:    contextLogger{emitter: logger},
+				Composer:  composerInstallExec,
+				Execution: execution,
+			})
+		})
+	}, composerLockPath, workspaceVendorDir)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	install := func() error {
+		return installWith(execution)
+	}
+
+	if revalidate {
+		err = revalidateStack(logger, fileSystem, install, checkPlatformReqsExec, capabilities, context.WorkingDir, layerVendorDir, workspaceVendorDir, composerPhpIniPath, path)
+		if err != nil {
+			logger.Process("Revalidation failed, rebuilding the layer: %s", err)
+			logger.Break()
+			revalidate = false
+
+			err = fileSystem.RemoveAll(workspaceVendorDir)
+			if err != nil { // untested
+				return packit.Layer{}, err
+			}
+		} else {
+			metadata["cache-status"] = string(CacheStatusRevalidatedStack)
+		}
+	}
+
+	if cacheStatus == CacheStatusStaleDevMode {
+		err = restoreVendorDir(logger, fileSystem, tracer, layerVendorDir, workspaceVendorDir)
+		if err != nil {
+			return packit.Layer{}, err
+		}
+	}
+
+	// the packages of a previous composer.lock are assembled from the
+	// package store, so only the changed packages are downloaded
+	packageStore := NewPackageStore(packageStoreDir)
+	if !revalidate && packageStoreDir != "" && (cacheStatus == CacheStatusMiss || cacheStatus == CacheStatusStaleLock) {
+		_, err = packageStore.assemble(logger, composerLockPath, workspaceVendorDir)
+		if err != nil {
+			return packit.Layer{}, err
+		}
+	}
+
+	if !revalidate {
+		err = install()
+		if err != nil {
+			return packit.Layer{}, err
+		}
+	}
+
+	if packageStoreDir != "" {
+		err = packageStore.update(logger, workspaceVendorDir)
+		if err != nil {
+			return packit.Layer{}, err
+		}
+	}
+
+	reportComposerWarnings(logger, warnings, metadata)
+
+	if downloadMetrics != nil {
+		err = reportDownloadMetrics(logger, downloadMetrics, composerCacheFilesDir(execution.Env, execution.Dir), metadata)
+		if err != nil {
+			return packit.Layer{}, err
+		}
+	}
+
+	stagedVendorDir := staging.path("vendor")
+	logger.Process("Copying from %s => to %s", workspaceVendorDir, stagedVendorDir)
+	logLayerIgnore(logger, layerIgnore)
+
+	err = tracer.Trace("copy vendor", copyAttributes(workspaceVendorDir, stagedVendorDir), func() error {
+		return copyTreeExcluding(logger, workspaceVendorDir, stagedVendorDir, layerIgnore.Excludes)
+	})
 	if err != nil {
 		return packit.Layer{}, err
 	}
 
-	logger.Process("Copying from %s => to %s", workspaceVendorDir, layerVendorDir)
+	var cachedPaths []cachedWorkspacePaths
+	for _, cached := range defaultCachedWorkspacePaths() {
+		paths, err := cached.find(context.WorkingDir, composerJsonPath, workspaceVendorDir)
+		if err != nil {
+			return packit.Layer{}, err
+		}
+
+		if len(paths) == 0 {
+			continue
+		}
+
+		stagedDir := staging.path(cached.layerDir)
+
+		logger.Process("Caching %d %s path(s) in %s", len(paths), cached.description, stagedDir)
+		for _, path := range paths {
+			logger.Debug.Subprocess("- %s", path)
+		}
+
+		err = tracer.Trace(fmt.Sprintf("cache %s files", cached.description), copyAttributes(context.WorkingDir, stagedDir), func() error {
+			return cacheWorkspacePaths(fileSystem, paths, context.WorkingDir, stagedDir, layerIgnore)
+		})
+		if err != nil {
+			return packit.Layer{}, err
+		}
+
+		cachedPaths = append(cachedPaths, cached)
+	}
+
+	logger.Process("Promoting the staged contents to %s", composerPackagesLayer.Path)
+
+	composerPackagesLayer, err = staging.promote(composerPackagesLayer)
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
 
-	err = fs.Copy(workspaceVendorDir, layerVendorDir)
+	nativeBinaries, err := findNativeBinaries(layerVendorDir)
 	if err != nil {
 		return packit.Layer{}, err
 	}
 
+	if len(nativeBinaries) > 0 {
+		logger.Process("Found native binaries in %d package(s), the layer is rebuilt when the build target changes", len(nativeBinaries))
+		for _, name := range nativeBinaries {
+			logger.Subprocess("- %s", name)
+		}
+	}
+	recordNativeBinaries(metadata, nativeBinaries, LookupBuildTarget())
+
+	composerPackagesLayer.Launch, composerPackagesLayer.Build = launch, build
+	// the layer is always set to cache = true because we need it during subsequent builds to copy vendor into /workspace
+	composerPackagesLayer.Cache = true
+
+	logger.Debug.Subprocess("Setting layer types: launch=[%t], build=[%t], cache=[%t]",
+		composerPackagesLayer.Launch,
+		composerPackagesLayer.Build,
+		composerPackagesLayer.Cache)
+
 	if os.Getenv(BpLogLevel) == "DEBUG" {
 		logger.Debug.Subprocess("Listing files in %s:", layerVendorDir)
-		files, err := os.ReadDir(layerVendorDir)
-		if err != nil { // untested
+		files, err := fileSystem.ReadDir(layerVendorDir)
+		if err != nil {
 			return packit.Layer{}, err
 		}
 		for _, f := range files {
@@ -425,13 +1547,28 @@ func runComposerInstall(
 		}
 	}
 
+	for _, cached := range cachedPaths {
+		checksum, err := calculator.Sum(filepath.Join(composerPackagesLayer.Path, cached.layerDir))
+		if err != nil { // untested
+			return packit.Layer{}, err
+		}
+
+		metadata[cached.metadataKey()] = checksum
+	}
+
+	composerPackagesLayer.Metadata = metadata
+
 	return composerPackagesLayer, nil
 }
 
 // writeComposerPhpIni will create a PHP INI file used by Composer itself,
 // such as when running `composer global` and `composer install.
+// It loads the given bootstrap extensions, which are required by Composer,
+// e.g. openssl to download packages over HTTPS.
+// The directives of the build profile are added as well, followed by the
+// directives trusting additional CAs, see prepareComposerTLSIfRequired.
 // This is created in a new ignored layer.
-func writeComposerPhpIni(logger scribe.Emitter, context packit.BuildContext) (composerPhpIniPath string, err error) {
+func writeComposerPhpIni(logger emitter, context packit.BuildContext, fileSystem FileSystem, bootstrapExtensions []string, disableHTTP2 bool, profilePhpIni []string, tlsPhpIni []string) (composerPhpIniPath string, err error) {
 	composerPhpIniLayer, err := context.Layers.Get(ComposerPhpIniLayerName)
 	if err != nil { // untested
 		return "", err
@@ -448,32 +1585,83 @@ func writeComposerPhpIni(logger scribe.Emitter, context packit.BuildContext) (co
 	logger.Debug.Subprocess("Writing %s to %s", filepath.Base(composerPhpIniPath), composerPhpIniPath)
 
 	phpIni := fmt.Sprintf(`[PHP]
-extension_dir = "%s"
-extension = %s.so`, os.Getenv(PhpExtensionDir), opensslExtension)
+extension_dir = "%s"`, os.Getenv(PhpExtensionDir))
+	for _, extension := range bootstrapExtensions {
+		phpIni += fmt.Sprintf("\nextension = %s.so", extension)
+	}
+
+	// Composer only uses its curl downloader, and therefore HTTP/2, if these
+	// functions are available, otherwise it falls back to PHP streams
+	if disableHTTP2 {
+		phpIni += "\ndisable_functions = curl_multi_init,curl_multi_exec"
+	}
+
+	for _, directive := range profilePhpIni {
+		phpIni += fmt.Sprintf("\n%s", directive)
+	}
+
+	for _, directive := range tlsPhpIni {
+		phpIni += fmt.Sprintf("\n%s", directive)
+	}
 	logger.Debug.Subprocess("Writing php.ini contents:\n'%s'", phpIni)
 
-	return composerPhpIniPath, os.WriteFile(composerPhpIniPath, []byte(phpIni), os.ModePerm)
+	mode, err := lookupGeneratedFileMode()
+	if err != nil {
+		return "", err
+	}
+
+	return composerPhpIniPath, fileSystem.WriteFile(composerPhpIniPath, []byte(phpIni), mode)
 }
 
-// runCheckPlatformReqs will run Composer command `check-platform-reqs`
-// to see which platform requirements are "missing".
-// https://getcomposer.org/doc/03-cli.md#check-platform-reqs
-//
-// Any "missing" requirements will be added to an INI file that should be autoloaded via PHP_INI_SCAN_DIR,
-// when used in conjunction with the `php-dist` Paketo Buildpack
-// INI file location: {workingDir}/.php.ini.d/composer-extensions.ini
-// PHP_INI_SCAN_DIR: https://github.com/paketo-buildpacks/php-dist/blob/bfed65e9c3b59cf2c5aee3752d82470f8259f655/build.go#L219-L223
-// Requires `php-dist` 0.8.0+ (https://github.com/paketo-buildpacks/php-dist/releases/tag/v0.8.0)
-//
-// This code has been largely borrowed from the original `php-composer` buildpack
-// https://github.com/paketo-buildpacks/php-composer/blob/5e2604b74cbeb30090bf7eadb1cfc158b374efc0/composer/composer.go#L76-L100
-//
-// In case you are curious about exit code 2: https://getcomposer.org/doc/03-cli.md#process-exit-codes
-func runCheckPlatformReqs(logger scribe.Emitter, checkPlatformReqsExec Executable, workingDir, composerPhpIniPath, path string) error {
+// readPlatformRequirements runs `composer check-platform-reqs` and returns
+// the reported requirements, and whether they have been read from
+// `--format=json`, which Composer supports since 2.3. Older versions are run
+// without it, to parse the text output instead. If the version of Composer
+// is unknown, versions failing on the option are run again without it.
+func readPlatformRequirements(logger emitter, checkPlatformReqsExec Executable, capabilities composerCapabilities, workingDir, composerPhpIniPath, path string) ([]platformRequirement, bool, error) {
+	var stdout, stderr string
+	var err error
+
+	jsonFormat := capabilities.checkPlatformReqsFormat()
+	if jsonFormat {
+		stdout, stderr, err = executeCheckPlatformReqs(logger, checkPlatformReqsExec, workingDir, composerPhpIniPath, path, "--format=json")
+	}
+	if !jsonFormat || err != nil && strings.Contains(stdout+stderr, checkPlatformReqsFormatUnsupported) {
+		logger.Subprocess("Composer does not support '--format=json', parsing the text output instead")
+		jsonFormat = false
+		stdout, stderr, err = executeCheckPlatformReqs(logger, checkPlatformReqsExec, workingDir, composerPhpIniPath, path)
+	}
+	if err != nil {
+		exitError, ok := err.(*exec.ExitError)
+		if !ok || exitError.ExitCode() != 2 {
+			return nil, false, err
+		}
+	}
+
+	if jsonFormat {
+		requirements, err := parsePlatformRequirements(stdout)
+		if err == nil {
+			return requirements, true, nil
+		}
+		logger.Debug.Subprocess("Failed to parse the output as JSON, parsing the text output instead: %s", err)
+	}
 
-	args := []string{"check-platform-reqs"}
+	_, _ = io.WriteString(logger.ActionWriter, stdout)
+	return parsePlatformRequirementsText(stdout + "\n" + stderr), false, nil
+}
+
+// checkPlatformReqsFormatUnsupported is part of the error of Composer
+// versions before 2.3, which do not support `check-platform-reqs --format`.
+const checkPlatformReqsFormatUnsupported = `"--format" option does not exist`
+
+// executeCheckPlatformReqs runs `composer check-platform-reqs` with the given
+// additional arguments, and returns its stdout and stderr. The stderr is
+// logged, the stdout is left to the caller, as it may be JSON.
+func executeCheckPlatformReqs(logger emitter, checkPlatformReqsExec Executable, workingDir, composerPhpIniPath, path string, extraArgs ...string) (string, string, error) {
+	args := append([]string{"check-platform-reqs"}, extraArgs...)
 	logger.Process("Running 'composer %s'", strings.Join(args, " "))
-	buffer := bytes.NewBuffer(nil)
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
 	execution := pexec.Execution{
 		Args: args,
 		Dir:  workingDir,
@@ -482,47 +1670,69 @@ func runCheckPlatformReqs(logger scribe.Emitter, checkPlatformReqsExec Executabl
 			fmt.Sprintf("PHPRC=%s", composerPhpIniPath),
 			fmt.Sprintf("PATH=%s", path),
 		),
-		Stdout: io.MultiWriter(logger.ActionWriter, buffer),
-		Stderr: io.MultiWriter(logger.ActionWriter, buffer),
+		Stdout: stdout,
+		Stderr: io.MultiWriter(logger.ActionWriter, stderr),
 	}
 
 	err := checkPlatformReqsExec.Execute(execution)
+
+	return stdout.String(), stderr.String(), err
+}
+
+// runCheckPlatformReqs will run Composer command `check-platform-reqs`
+// to see which platform requirements are "missing".
+// https://getcomposer.org/doc/03-cli.md#check-platform-reqs
+//
+// Any "missing" requirements will be added to an INI file that should be autoloaded via PHP_INI_SCAN_DIR,
+// when used in conjunction with the `php-dist` Paketo Buildpack
+// INI file location: {workingDir}/.php.ini.d/composer-extensions.ini, or the
+// `php.ini.d` directory of the composer-packages layer, see
+// configurePhpIniLayerIfRequired
+// PHP_INI_SCAN_DIR: https://github.com/paketo-buildpacks/php-dist/blob/bfed65e9c3b59cf2c5aee3752d82470f8259f655/build.go#L219-L223
+// Requires `php-dist` 0.8.0+ (https://github.com/paketo-buildpacks/php-dist/releases/tag/v0.8.0)
+//
+// This code has been largely borrowed from the original `php-composer` buildpack
+// https://github.com/paketo-buildpacks/php-composer/blob/5e2604b74cbeb30090bf7eadb1cfc158b374efc0/composer/composer.go#L76-L100
+//
+// In case you are curious about exit code 2: https://getcomposer.org/doc/03-cli.md#process-exit-codes
+//
+// Returns the extensions required at runtime.
+func runCheckPlatformReqs(logger emitter, fileSystem FileSystem, checkPlatformReqsExec Executable, capabilities composerCapabilities, workingDir, extensionsIniDir, composerPhpIniPath, composerLockPath, path string, bootstrapExtensions, providedExtensions []string) ([]PhpExtension, error) {
+	requirements, jsonFormat, err := readPlatformRequirements(logger, checkPlatformReqsExec, capabilities, workingDir, composerPhpIniPath, path)
 	if err != nil {
-		exitError, ok := err.(*exec.ExitError)
-		if !ok || exitError.ExitCode() != 2 {
-			return err
-		}
+		return nil, err
 	}
 
-	// we always include the openssl extension as it will not be found
-	// otherwise. The reason for this is that `writeComposerPhpIni` gets
-	// executed first and already includes the openssl extension. `composer
-	// check-platform-reqs` will therefore not output a missing openssl
-	// extension (as it was already loaded).
-	var extensions = []string{opensslExtension}
-	for _, line := range strings.Split(buffer.String(), "\n") {
-		chunks := strings.Split(strings.TrimSpace(line), " ")
-		extensionName := strings.TrimPrefix(strings.TrimSpace(chunks[0]), "ext-")
-		extensionStatus := strings.TrimSpace(chunks[len(chunks)-1])
-		if extensionName != "php" && extensionName != "php-64bit" && extensionStatus == "missing" {
-			extensions = append(extensions, extensionName)
-		}
+	// we always include the bootstrap extensions (openssl by default) as they
+	// will not be found otherwise. The reason for this is that
+	// `writeComposerPhpIni` gets executed first and already includes the
+	// bootstrap extensions. `composer check-platform-reqs` will therefore not
+	// output them as missing (as they were already loaded).
+	var extensions []PhpExtension
+	for _, extension := range bootstrapExtensions {
+		extensions = append(extensions, PhpExtension{Name: extension, Source: PhpExtensionSourceBootstrap})
 	}
 
-	logger.Process("Found extensions '%s'", strings.Join(extensions, ", "))
+	if jsonFormat {
+		extensions = append(extensions, missingPlatformExtensions(logger, requirements)...)
+	} else {
+		extensions = append(extensions, missingTextExtensions(requirements)...)
+	}
 
-	buf := bytes.Buffer{}
+	err = checkPlatformPhpIfRequired(logger, requirements, composerLockPath)
+	if err != nil {
+		return nil, err
+	}
 
+	var names []string
 	for _, extension := range extensions {
-		buf.WriteString(fmt.Sprintf("extension = %s.so\n", extension))
+		names = append(names, extension.Name)
 	}
+	logger.Process("Found extensions '%s'", strings.Join(names, ", "))
 
-	iniDir := filepath.Join(workingDir, ".php.ini.d")
-
-	err = os.MkdirAll(iniDir, os.ModeDir|os.ModePerm)
-	if err != nil { // untested
-		return err
-	}
+	extensions = includeExtensions(logger, extensions)
+	extensions = excludeExtensions(logger, extensions)
+	extensions = skipProvidedExtensions(logger, extensions, providedExtensions)
 
-	return os.WriteFile(filepath.Join(iniDir, "composer-extensions.ini"), buf.Bytes(), 0666)
+	return extensions, writePhpExtensions(logger, fileSystem, workingDir, extensionsIniDir, extensions)
 }
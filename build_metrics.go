@@ -0,0 +1,143 @@
+package composer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MetricsFileName is the name of the metrics file written into the directory
+// set in BP_COMPOSER_METRICS_PATH.
+const MetricsFileName = "composer_install.prom"
+
+// metricsNamespace prefixes the names of all metrics.
+const metricsNamespace = "composer_install"
+
+// allCacheStatuses lists the statuses of the composer-packages cache, which
+// are reported as one series each, so that a status which has not occurred
+// yet is reported as 0 rather than missing.
+var allCacheStatuses = []CacheStatus{
+	CacheStatusHit,
+	CacheStatusMiss,
+	CacheStatusStaleStack,
+	CacheStatusStaleLock,
+	CacheStatusStaleConfig,
+	CacheStatusStaleInputs,
+	CacheStatusStaleLayout,
+	CacheStatusVendorOnly,
+	CacheStatusVendorPreserved,
+}
+
+// BuildMetrics are the metrics of a build, written in the text format of
+// Prometheus, as read by the textfile collector of the node exporter.
+// https://github.com/prometheus/node_exporter#textfile-collector
+type BuildMetrics struct {
+	// Duration is the duration of the whole build
+	Duration time.Duration
+
+	// InstallDuration is the duration of `composer install`, or of the
+	// restore of the cached layer
+	InstallDuration time.Duration
+
+	CacheStatus  CacheStatus
+	PackageCount int
+
+	// FinishedOn is the time the build finished
+	FinishedOn time.Time
+}
+
+// WriteTo writes the metrics in the text format of Prometheus.
+func (m BuildMetrics) WriteTo(w io.Writer) (int64, error) {
+	var builder strings.Builder
+
+	gauge := func(name, help string, samples ...string) {
+		fmt.Fprintf(&builder, "# HELP %s_%s %s\n", metricsNamespace, name, help)
+		fmt.Fprintf(&builder, "# TYPE %s_%s gauge\n", metricsNamespace, name)
+		for _, sample := range samples {
+			fmt.Fprintf(&builder, "%s_%s%s\n", metricsNamespace, name, sample)
+		}
+	}
+
+	gauge("build_duration_seconds", "Duration of the build in seconds.",
+		fmt.Sprintf(" %g", m.Duration.Seconds()))
+	gauge("install_duration_seconds", "Duration of composer install, or of the restore of the cached layer, in seconds.",
+		fmt.Sprintf(" %g", m.InstallDuration.Seconds()))
+
+	var statuses []string
+	for _, status := range allCacheStatuses {
+		value := 0
+		if status == m.CacheStatus {
+			value = 1
+		}
+		statuses = append(statuses, fmt.Sprintf("{status=%q} %d", status, value))
+	}
+	gauge("cache_status", "Status of the composer-packages cache, 1 for the status of the build.", statuses...)
+
+	gauge("packages", "Number of installed packages.",
+		fmt.Sprintf(" %d", m.PackageCount))
+	gauge("last_build_timestamp_seconds", "Time the build finished in seconds since the epoch.",
+		fmt.Sprintf(" %d", m.FinishedOn.Unix()))
+
+	n, err := io.WriteString(w, builder.String())
+	return int64(n), err
+}
+
+// writeMetricsIfRequired will check for env var "BP_COMPOSER_METRICS_PATH".
+// If set, the metrics of the build are written into the file `composer_install.prom`
+// of that directory, e.g. a volume of the platform scraped by CI, or into
+// the file itself if it is not a directory. The file is replaced atomically,
+// so that a scraper never reads a partial file. The packages are counted in
+// the `installed.json` of the given vendor directory.
+func writeMetricsIfRequired(logger emitter, workspaceVendorDir string, metrics BuildMetrics) error {
+	metricsPath, found := os.LookupEnv(BpComposerMetricsPath)
+	if !found || metricsPath == "" {
+		return nil
+	}
+
+	installedPackages, err := ReadInstalledPackages(filepath.Join(workspaceVendorDir, "composer", "installed.json"))
+	if err != nil {
+		return err
+	}
+	metrics.PackageCount = len(installedPackages)
+
+	if info, err := os.Stat(metricsPath); err == nil && info.IsDir() {
+		metricsPath = filepath.Join(metricsPath, MetricsFileName)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(metricsPath), fmt.Sprintf(".%s.*", filepath.Base(metricsPath)))
+	if err != nil {
+		return fmt.Errorf("failed to write metrics to %s: %w", metricsPath, err)
+	}
+	defer os.Remove(file.Name())
+
+	_, err = metrics.WriteTo(file)
+	if err != nil { // untested
+		_ = file.Close()
+		return fmt.Errorf("failed to write metrics to %s: %w", metricsPath, err)
+	}
+
+	err = file.Close()
+	if err != nil { // untested
+		return fmt.Errorf("failed to write metrics to %s: %w", metricsPath, err)
+	}
+
+	// the textfile collector requires the file to be readable by the node
+	// exporter, which usually runs as another user
+	err = os.Chmod(file.Name(), 0644)
+	if err != nil { // untested
+		return fmt.Errorf("failed to write metrics to %s: %w", metricsPath, err)
+	}
+
+	err = os.Rename(file.Name(), metricsPath)
+	if err != nil {
+		return fmt.Errorf("failed to write metrics to %s: %w", metricsPath, err)
+	}
+
+	logger.Process("Writing build metrics to %s", metricsPath)
+	logger.Break()
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package composer_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testBuildMetrics(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+	)
+
+	context("WriteTo", func() {
+		it("writes the metrics in the text format of Prometheus", func() {
+			buffer := bytes.NewBuffer(nil)
+			_, err := composer.BuildMetrics{
+				Duration:        12500 * time.Millisecond,
+				InstallDuration: 9 * time.Second,
+				CacheStatus:     composer.CacheStatusStaleLock,
+				PackageCount:    42,
+				FinishedOn:      time.Unix(1700000000, 0),
+			}.WriteTo(buffer)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buffer.String()).To(Equal(`# HELP composer_install_build_duration_seconds Duration of the build in seconds.
+# TYPE composer_install_build_duration_seconds gauge
+composer_install_build_duration_seconds 12.5
+# HELP composer_install_install_duration_seconds Duration of composer install, or of the restore of the cached layer, in seconds.
+# TYPE composer_install_install_duration_seconds gauge
+composer_install_install_duration_seconds 9
+# HELP composer_install_cache_status Status of the composer-packages cache, 1 for the status of the build.
+# TYPE composer_install_cache_status gauge
+composer_install_cache_status{status="hit"} 0
+composer_install_cache_status{status="miss"} 0
+composer_install_cache_status{status="stale-stack"} 0
+composer_install_cache_status{status="stale-lock"} 1
+composer_install_cache_status{status="stale-config"} 0
+composer_install_cache_status{status="stale-inputs"} 0
+composer_install_cache_status{status="stale-layout"} 0
+composer_install_cache_status{status="vendor-only"} 0
+composer_install_cache_status{status="vendor-preserved"} 0
+# HELP composer_install_packages Number of installed packages.
+# TYPE composer_install_packages gauge
+composer_install_packages 42
+# HELP composer_install_last_build_timestamp_seconds Time the build finished in seconds since the epoch.
+# TYPE composer_install_last_build_timestamp_seconds gauge
+composer_install_last_build_timestamp_seconds 1700000000
+`))
+		})
+	})
+}
@@ -0,0 +1,92 @@
+package composer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/chronos"
+)
+
+const BuildStampFileName = "composer-build.json"
+
+// BuildStamp describes the build which produced the installed dependencies.
+// It is written to `composer-build.json` so that applications can expose
+// their build provenance at runtime.
+type BuildStamp struct {
+	ComposerLockSHA  string `json:"composer-lock-sha"`
+	BuildTime        string `json:"build-time"`
+	BuildpackID      string `json:"buildpack-id"`
+	BuildpackVersion string `json:"buildpack-version"`
+	ComposerVersion  string `json:"composer-version"`
+	PackageCount     int    `json:"package-count"`
+}
+
+// writeBuildStamp writes the build stamp as `composer-build.json` into each
+// of the given directories.
+func writeBuildStamp(stamp BuildStamp, dirs ...string) error {
+	content, err := json.MarshalIndent(stamp, "", "  ")
+	if err != nil { // untested
+		return err
+	}
+
+	for _, dir := range dirs {
+		err = os.WriteFile(filepath.Join(dir, BuildStampFileName), append(content, '\n'), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeBuildStampIfRequired will check for env var "BP_COMPOSER_BUILD_STAMP".
+// If set to true, it writes a `composer-build.json` into the working directory
+// and into the composer-packages layer.
+func writeBuildStampIfRequired(
+	logger emitter,
+	context packit.BuildContext,
+	capabilities composerCapabilities,
+	workspaceVendorDir string,
+	composerPackagesLayer packit.Layer,
+	clock chronos.Clock) error {
+	enabled, err := lookupBoolEnv(BpComposerBuildStamp, false)
+	if err != nil {
+		return err
+	}
+
+	if !enabled {
+		return nil
+	}
+
+	logger.Process("Writing %s", BuildStampFileName)
+
+	// the version has been determined at the start of the build
+	if capabilities.versionErr != nil {
+		return capabilities.versionErr
+	}
+
+	installedPackages, err := ReadInstalledPackages(filepath.Join(workspaceVendorDir, "composer", "installed.json"))
+	if err != nil {
+		return err
+	}
+
+	composerLockSHA, _ := composerPackagesLayer.Metadata["composer-lock-sha"].(string)
+
+	stamp := BuildStamp{
+		ComposerLockSHA:  composerLockSHA,
+		BuildTime:        clock.Now().UTC().Format(time.RFC3339),
+		BuildpackID:      context.BuildpackInfo.ID,
+		BuildpackVersion: context.BuildpackInfo.Version,
+		ComposerVersion:  capabilities.version,
+		PackageCount:     len(installedPackages),
+	}
+
+	logger.Subprocess("Composer version: %s", stamp.ComposerVersion)
+	logger.Subprocess("Package count: %d", stamp.PackageCount)
+	logger.Break()
+
+	return writeBuildStamp(stamp, context.WorkingDir, composerPackagesLayer.Path)
+}
@@ -1,14 +1,22 @@
 package composer_test
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/BurntSushi/toml"
 	. "github.com/onsi/gomega"
 	"github.com/paketo-buildpacks/composer"
 	"github.com/paketo-buildpacks/composer/fakes"
@@ -17,7 +25,10 @@ import (
 	"github.com/paketo-buildpacks/packit/v2/pexec"
 	"github.com/paketo-buildpacks/packit/v2/sbom"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
 	"github.com/sclevine/spec"
+
+	. "github.com/paketo-buildpacks/occam/matchers"
 )
 
 func testBuild(t *testing.T, context spec.G, it spec.S) {
@@ -30,10 +41,23 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		composerInstallExecutable               *fakes.Executable
 		composerGlobalExecutable                *fakes.Executable
 		composerCheckPlatformReqsExecExecutable *fakes.Executable
+		composerVersionExecutable               *fakes.Executable
+		composerOutdatedExecutable              *fakes.Executable
+		composerLicensesExecutable              *fakes.Executable
+		phpVersionExecutable                    *fakes.Executable
 		composerConfigExecution                 pexec.Execution
+		composerConfigExecutions                []pexec.Execution
 		composerInstallExecution                pexec.Execution
 		composerGlobalExecution                 pexec.Execution
 		composerCheckPlatformReqsExecExecution  pexec.Execution
+		composerVersionExecution                pexec.Execution
+		composerOutdatedExecution               pexec.Execution
+		composerDownloader                      *fakes.ComposerDownloader
+		bindingResolver                         *fakes.BindingResolver
+		timestamper                             *fakes.Timestamper
+		diskSpace                               *fakes.DiskSpace
+		fileSystem                              composer.FileSystem
+		spanExporter                            *fakes.SpanExporter
 		sbomGenerator                           *fakes.SBOMGenerator
 		calculator                              *fakes.Calculator
 
@@ -43,7 +67,8 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		buildpackPlan packit.BuildpackPlan
 		buildpackInfo packit.BuildpackInfo
 
-		build packit.BuildFunc
+		dependencies composer.BuildDependencies
+		build        packit.BuildFunc
 	)
 
 	it.Before(func() {
@@ -57,14 +82,24 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		buffer = bytes.NewBuffer(nil)
 		installOptions = &fakes.DetermineComposerInstallOptions{}
 		composerConfigExecutable = &fakes.Executable{}
+		composerConfigExecutions = nil
 		composerInstallExecutable = &fakes.Executable{}
 		composerGlobalExecutable = &fakes.Executable{}
 		composerCheckPlatformReqsExecExecutable = &fakes.Executable{}
+		composerVersionExecutable = &fakes.Executable{}
+		composerOutdatedExecutable = &fakes.Executable{}
+		composerLicensesExecutable = &fakes.Executable{}
+		phpVersionExecutable = &fakes.Executable{}
+		phpVersionExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+			_, err := fmt.Fprint(temp.Stdout, "PHP 8.2.12 (cli) (built: Oct 27 2023 13:00:00) (NTS)\n")
+			return err
+		}
 
 		composerConfigExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
 			Expect(fmt.Fprint(temp.Stdout, "stdout from composer config\n")).To(Equal(28))
 			Expect(fmt.Fprint(temp.Stderr, "stderr from composer config\n")).To(Equal(28))
 			composerConfigExecution = temp
+			composerConfigExecutions = append(composerConfigExecutions, temp)
 			return nil
 		}
 
@@ -98,6 +133,20 @@ php       8.1.4    success
 			return nil
 		}
 
+		composerVersionExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+			composerVersionExecution = temp
+			_, err := fmt.Fprint(temp.Stdout, "Composer version 2.6.5 2023-10-06 10:11:52\n")
+			return err
+		}
+
+		composerDownloader = &fakes.ComposerDownloader{}
+		bindingResolver = &fakes.BindingResolver{}
+		timestamper = &fakes.Timestamper{}
+		diskSpace = &fakes.DiskSpace{}
+		diskSpace.AvailableCall.Returns.Available = 100 * 1024 * 1024 * 1024
+		fileSystem = composer.NewOSFileSystem()
+		spanExporter = &fakes.SpanExporter{}
+
 		sbomGenerator = &fakes.SBOMGenerator{}
 		sbomGenerator.GenerateCall.Returns.SBOM = sbom.SBOM{}
 		calculator = &fakes.Calculator{}
@@ -105,23 +154,35 @@ php       8.1.4    success
 
 		Expect(os.Setenv("PHP_EXTENSION_DIR", "php-extension-dir"))
 
-		installOptions.DetermineCall.Returns.StringSlice = []string{
-			"options",
-			"from",
-			"fake",
+		installOptions.DetermineCall.Returns.InstallOptionSlice = []composer.InstallOption{
+			{Value: "options", Source: composer.InstallOptionSourceDefault},
+			{Value: "from", Source: composer.InstallOptionSourceEnv},
+			{Value: "fake", Source: composer.InstallOptionSourcePlan},
 		}
 
-		build = composer.Build(
-			scribe.NewEmitter(buffer).WithLevel("DEBUG"),
-			installOptions,
-			composerConfigExecutable,
-			composerInstallExecutable,
-			composerGlobalExecutable,
-			composerCheckPlatformReqsExecExecutable,
-			sbomGenerator,
-			"fake-path-from-tests",
-			calculator,
-			chronos.DefaultClock)
+		dependencies = composer.BuildDependencies{
+			Logger:                composer.NewEmitterLogger(scribe.NewEmitter(buffer).WithLevel("DEBUG")),
+			InstallOptions:        installOptions,
+			ComposerConfigExec:    composerConfigExecutable,
+			ComposerInstallExec:   composerInstallExecutable,
+			ComposerGlobalExec:    composerGlobalExecutable,
+			CheckPlatformReqsExec: composerCheckPlatformReqsExecExecutable,
+			ComposerVersionExec:   composerVersionExecutable,
+			ComposerOutdatedExec:  composerOutdatedExecutable,
+			ComposerLicensesExec:  composerLicensesExecutable,
+			PhpVersionExec:        phpVersionExecutable,
+			ComposerDownloader:    composerDownloader,
+			BindingResolver:       bindingResolver,
+			Timestamper:           timestamper,
+			DiskSpace:             diskSpace,
+			FileSystem:            fileSystem,
+			SpanExporter:          spanExporter,
+			SBOMGenerator:         sbomGenerator,
+			Path:                  "fake-path-from-tests",
+			Calculator:            calculator,
+			Clock:                 chronos.DefaultClock,
+		}
+		build = composer.Build(dependencies)
 
 		buildpackInfo = packit.BuildpackInfo{
 			Name:        "Some Buildpack",
@@ -161,7 +222,7 @@ php       8.1.4    success
 			)
 			Expect(err).NotTo(HaveOccurred())
 			layers := result.Layers
-			Expect(layers).To(HaveLen(1))
+			Expect(layers).To(HaveLen(2))
 
 			packagesLayer := layers[0]
 			Expect(packagesLayer.Name).To(Equal(composer.ComposerPackagesLayerName))
@@ -172,10 +233,33 @@ php       8.1.4    success
 			Expect(packagesLayer.Cache).To(BeTrue())
 
 			Expect(packagesLayer.BuildEnv).To(BeEmpty())
-			Expect(packagesLayer.LaunchEnv).To(BeEmpty())
+			Expect(packagesLayer.LaunchEnv).To(Equal(packit.Environment{
+				"COMPOSER_VENDOR_DIR.default": filepath.Join(workingDir, "vendor"),
+				"COMPOSER_NO_DEV.default":     "0",
+				"COMPOSER_HOME.default":       "/tmp/composer-home",
+			}))
 			Expect(packagesLayer.ProcessLaunchEnv).To(BeEmpty())
+			Expect(packagesLayer.ExecD).To(Equal([]string{filepath.Join("bin", "prepare-composer-home")}))
 			Expect(packagesLayer.Metadata["composer-lock-sha"]).To(Equal("default-checksum"))
 			Expect(packagesLayer.Metadata["stack"]).To(Equal(""))
+			Expect(packagesLayer.Metadata["metadata-version"]).To(Equal(composer.ComposerPackagesMetadataVersion))
+			Expect(packagesLayer.Metadata["cache-status"]).To(Equal("miss"))
+			Expect(buffer.String()).To(ContainSubstring("Composer packages cache: miss"))
+
+			Expect(packagesLayer.Metadata["sbom-composer-lock-sha"]).To(Equal("default-checksum"))
+			Expect(packagesLayer.Metadata["sbom-formats"]).To(Equal("application/vnd.cyclonedx+json,application/spdx+json"))
+			Expect(packagesLayer.Metadata["sbom-extensions"]).To(Equal("cdx.json,spdx.json"))
+			Expect(filepath.Join(packagesLayer.Path, "sbom-cache", "cdx.json")).To(BeARegularFile())
+			Expect(filepath.Join(packagesLayer.Path, "sbom-cache", "spdx.json")).To(BeARegularFile())
+
+			composerHomeLayer := layers[1]
+			Expect(composerHomeLayer.Name).To(Equal(composer.ComposerHomeLayerName))
+			Expect(composerHomeLayer.Path).To(Equal(filepath.Join(layersDir, composer.ComposerHomeLayerName)))
+			Expect(composerHomeLayer.Path).To(BeADirectory())
+
+			Expect(composerHomeLayer.Build).To(BeFalse())
+			Expect(composerHomeLayer.Launch).To(BeFalse())
+			Expect(composerHomeLayer.Cache).To(BeFalse())
 
 			Expect(packagesLayer.SBOM.Formats()).To(HaveLen(2))
 			cdx := packagesLayer.SBOM.Formats()[0]
@@ -229,26 +313,35 @@ php       8.1.4    success
 			Expect(buffer.String()).To(ContainSubstring("Running 'composer install options from fake'"))
 
 			Expect(installOptions.DetermineCall.CallCount).To(Equal(1))
+			Expect(installOptions.DetermineCall.Receives.Plan).To(Equal(buildpackPlan))
+			Expect(installOptions.DetermineCall.Receives.ProjectConfig).To(BeEmpty())
+			Expect(buffer).To(ContainLines(
+				"  Options for 'composer install'",
+				"    options  (default)",
+				"    from     (env var BP_COMPOSER_INSTALL_OPTIONS)",
+				"    fake     (build plan metadata)",
+			))
 
 			Expect(composerConfigExecution.Args).To(Equal([]string{"config", "autoloader-suffix", composer.ComposerAutoloaderSuffix}))
 			Expect(composerConfigExecution.Stdout).ToNot(BeNil())
 			Expect(composerConfigExecution.Stderr).ToNot(BeNil())
-			Expect(len(composerConfigExecution.Env)).To(Equal(len(os.Environ()) + 6))
+			Expect(len(composerConfigExecution.Env)).To(Equal(len(os.Environ()) + 10))
 
 			Expect(composerInstallExecution.Args).To(Equal([]string{"install", "options", "from", "fake"}))
 			Expect(composerInstallExecution.Dir).To(Equal(filepath.Join(workingDir)))
 			Expect(composerInstallExecution.Stdout).ToNot(BeNil())
 			Expect(composerInstallExecution.Stderr).ToNot(BeNil())
-			Expect(len(composerInstallExecution.Env)).To(Equal(len(os.Environ()) + 6))
+			Expect(len(composerInstallExecution.Env)).To(Equal(len(os.Environ()) + 10))
 
 			Expect(sbomGenerator.GenerateCall.Receives.Dir).To(Equal(workingDir))
 			Expect(composerInstallExecution.Env).To(ContainElements(
 				"COMPOSER_NO_INTERACTION=1",
 				fmt.Sprintf("COMPOSER=%s", filepath.Join(workingDir, "composer.json")),
-				fmt.Sprintf("COMPOSER_HOME=%s", filepath.Join(layersDir, composer.ComposerPackagesLayerName, ".composer")),
+				fmt.Sprintf("COMPOSER_HOME=%s", filepath.Join(layersDir, composer.ComposerHomeLayerName)),
 				fmt.Sprintf("COMPOSER_VENDOR_DIR=%s/vendor", workingDir),
 				fmt.Sprintf("PHPRC=%s", filepath.Join(layersDir, "composer-php-ini", "composer-php.ini")),
 				"PATH=fake-path-from-tests"))
+			Expect(composerInstallExecution.Env).To(ContainElement(MatchRegexp(`^COMPOSER_MAX_PARALLEL_HTTP=([4-9]|1[0-2])$`)))
 
 			composerPhpIni := filepath.Join(layersDir, "composer-php-ini", "composer-php.ini")
 			Expect(composerPhpIni).To(BeARegularFile())
@@ -262,12 +355,21 @@ extension = openssl.so`))
 		})
 	})
 
-	context("with COMPOSER set", func() {
+	context("with Composer 2.2", func() {
 		it.Before(func() {
-			Expect(os.Setenv("COMPOSER", "./foo/bar.file")).To(Succeed())
+			composerVersionExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				_, err := fmt.Fprint(temp.Stdout, "Composer version 2.2.21 2023-02-15 13:07:40\n")
+				return err
+			}
+
+			installOptions.DetermineCall.Returns.InstallOptionSlice = []composer.InstallOption{
+				{Value: "--no-progress", Source: composer.InstallOptionSourceDefault},
+				{Value: "--no-audit", Source: composer.InstallOptionSourceEnv},
+				{Value: "--audit-format=summary", Source: composer.InstallOptionSourceEnv},
+			}
 		})
 
-		it("provides COMPOSER to composer install composerInstallExecution", func() {
+		it("leaves out the options and formats the version does not support", func() {
 			_, err := build(packit.BuildContext{
 				BuildpackInfo: buildpackInfo,
 				WorkingDir:    workingDir,
@@ -275,30 +377,89 @@ extension = openssl.so`))
 				Plan:          buildpackPlan,
 			})
 			Expect(err).NotTo(HaveOccurred())
-			Expect(composerInstallExecution.Env).To(ContainElements(
-				fmt.Sprintf("COMPOSER=%s", filepath.Join(workingDir, "foo", "bar.file"))))
-			Expect(calculator.SumCall.Receives.Paths).To(Equal([]string{filepath.Join(workingDir, "foo", "composer.lock")}))
+
+			Expect(composerVersionExecutable.ExecuteCall.CallCount).To(Equal(1))
+			Expect(composerInstallExecution.Args).To(Equal([]string{"install", "--no-progress"}))
+			Expect(composerCheckPlatformReqsExecExecutable.ExecuteCall.CallCount).To(Equal(1))
+			Expect(composerCheckPlatformReqsExecExecution.Args).To(Equal([]string{"check-platform-reqs"}))
+
+			Expect(buffer.String()).To(ContainSubstring("WARNING: Ignoring option '--no-audit' (env var BP_COMPOSER_INSTALL_OPTIONS), Composer 2.2.21 does not support it before 2.4.0"))
+			Expect(buffer.String()).To(ContainSubstring("WARNING: Ignoring option '--audit-format=summary'"))
 		})
-	})
 
-	context("with COMPOSER_VENDOR_DIR set", func() {
-		var (
-			err       error
-			customDir string
-		)
+		context("when composer.lock has been written by a newer Composer", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{"packages": [], "plugin-api-version": "2.6.0"}`), os.ModePerm)).To(Succeed())
+			})
+
+			it("reports it", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).To(ContainSubstring("WARNING: composer.lock has been written by Composer 2.6 (plugin-api-version 2.6.0), which is newer than Composer 2.2.21"))
+			})
+		})
+	})
 
+	context("when the version of Composer cannot be determined", func() {
 		it.Before(func() {
-			customDir, err = os.MkdirTemp(workingDir, "some-custom-dir")
+			composerVersionExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				return errors.New("exit status 1")
+			}
+
+			installOptions.DetermineCall.Returns.InstallOptionSlice = []composer.InstallOption{
+				{Value: "--no-audit", Source: composer.InstallOptionSourceEnv},
+			}
+		})
+
+		it("assumes the plugin-api-version of composer.lock", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{"packages": [], "plugin-api-version": "2.2.0"}`), os.ModePerm)).To(Succeed())
+
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
 			Expect(err).NotTo(HaveOccurred())
-			Expect(os.Setenv("COMPOSER_VENDOR_DIR", filepath.Base(customDir))).To(Succeed())
+
+			Expect(composerInstallExecution.Args).To(Equal([]string{"install"}))
+			Expect(composerCheckPlatformReqsExecExecution.Args).To(Equal([]string{"check-platform-reqs"}))
+			Expect(buffer.String()).To(ContainSubstring("Assuming Composer 2.2.0, the plugin-api-version of composer.lock"))
+		})
+
+		it("assumes the latest version without composer.lock", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(composerInstallExecution.Args).To(Equal([]string{"install", "--no-audit"}))
+			Expect(composerCheckPlatformReqsExecExecution.Args).To(Equal([]string{"check-platform-reqs", "--format=json"}))
+		})
+	})
+
+	context("with BP_COMPOSER_SCRIPT_PATH_PREPEND set", func() {
+		var toolsDir string
+
+		it.Before(func() {
+			toolsDir = t.TempDir()
+			Expect(os.Setenv("BP_COMPOSER_SCRIPT_PATH_PREPEND", fmt.Sprintf("%s%c/missing/tools", toolsDir, os.PathListSeparator))).To(Succeed())
 		})
 
 		it.After(func() {
-			Expect(os.RemoveAll(customDir)).To(Succeed())
-			Expect(os.Unsetenv("COMPOSER_VENDOR_DIR")).To(Succeed())
+			Expect(os.Unsetenv("BP_COMPOSER_SCRIPT_PATH_PREPEND")).To(Succeed())
 		})
 
-		it("uses custom COMPOSER_VENDOR_DIR", func() {
+		it("prepends the directories to the PATH of composer install only", func() {
 			_, err := build(packit.BuildContext{
 				BuildpackInfo: buildpackInfo,
 				WorkingDir:    workingDir,
@@ -306,93 +467,274 @@ extension = openssl.so`))
 				Plan:          buildpackPlan,
 			})
 			Expect(err).NotTo(HaveOccurred())
-			Expect(composerInstallExecution.Env).To(ContainElement(fmt.Sprintf("COMPOSER_VENDOR_DIR=%s", customDir)))
+
+			Expect(composerInstallExecution.Env).To(ContainElement(fmt.Sprintf("PATH=%s%c/missing/tools%cfake-path-from-tests", toolsDir, os.PathListSeparator, os.PathListSeparator)))
+			Expect(composerInstallExecution.Env).NotTo(ContainElement("PATH=fake-path-from-tests"))
+			Expect(composerConfigExecution.Env).To(ContainElement("PATH=fake-path-from-tests"))
+
+			Expect(buffer.String()).To(ContainSubstring("Prepending to the PATH of 'composer install' (BP_COMPOSER_SCRIPT_PATH_PREPEND)"))
+			Expect(buffer.String()).To(ContainSubstring("/missing/tools (WARNING: does not exist)"))
 		})
 
-		context("with previously existing vendor dir", func() {
+		context("with a relative path", func() {
 			it.Before(func() {
-				Expect(os.WriteFile(filepath.Join(customDir, "existing-file.text"), []byte(""), os.ModePerm)).To(Succeed())
+				Expect(os.Setenv("BP_COMPOSER_SCRIPT_PATH_PREPEND", "tools/bin")).To(Succeed())
 			})
 
-			it("existing files are in final composer packages layer", func() {
+			it("returns an error", func() {
 				_, err := build(packit.BuildContext{
 					BuildpackInfo: buildpackInfo,
 					WorkingDir:    workingDir,
 					Layers:        packit.Layers{Path: layersDir},
 					Plan:          buildpackPlan,
 				})
-				Expect(err).NotTo(HaveOccurred())
-				Expect(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor", "existing-file.text")).To(BeAnExistingFile())
+				Expect(err).To(MatchError(`BP_COMPOSER_SCRIPT_PATH_PREPEND must only contain absolute paths, found "tools/bin"`))
 			})
 		})
 	})
 
-	context("with BP_COMPOSER_INSTALL_GLOBAL", func() {
+	context("with BP_COMPOSER_RUN_AS set", func() {
+		var (
+			cnbPath string
+			shim    string
+		)
+
 		it.Before(func() {
-			Expect(os.Setenv("BP_COMPOSER_INSTALL_GLOBAL", "friendsofphp/php-cs-fixer squizlabs/php_codesniffer=*")).To(Succeed())
+			Expect(os.Setenv("BP_COMPOSER_RUN_AS", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))).To(Succeed())
+
+			toolsDir := t.TempDir()
+			Expect(os.WriteFile(filepath.Join(toolsDir, "composer"), []byte(`#!/bin/sh
+PATH=/usr/bin:/bin
+mkdir -p "$COMPOSER_VENDOR_DIR/local-package-name"
+id -u > "$COMPOSER_VENDOR_DIR/uid"
+`), 0755)).To(Succeed())
+			Expect(os.Setenv("BP_COMPOSER_SCRIPT_PATH_PREPEND", toolsDir)).To(Succeed())
+
+			// the run-as executable of the buildpack, which only root may run
+			cnbPath = t.TempDir()
+			Expect(os.MkdirAll(filepath.Join(cnbPath, "bin"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(cnbPath, "bin", "run-as"), []byte(`#!/bin/sh
+shift
+exec "$@"
+`), 0755)).To(Succeed())
+
+			composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				// the last PATH of the environment takes effect
+				var path string
+				for _, variable := range temp.Env {
+					if strings.HasPrefix(variable, "PATH=") {
+						path = strings.TrimPrefix(variable, "PATH=")
+					}
+				}
+
+				content, err := os.ReadFile(filepath.Join(filepath.SplitList(path)[0], "composer"))
+				Expect(err).NotTo(HaveOccurred())
+				shim = string(content)
+
+				return pexec.NewExecutable("composer").Execute(temp)
+			}
 		})
 
 		it.After(func() {
-			Expect(os.Unsetenv("BP_COMPOSER_INSTALL_GLOBAL")).To(Succeed())
+			Expect(os.Unsetenv("BP_COMPOSER_RUN_AS")).To(Succeed())
+			Expect(os.Unsetenv("BP_COMPOSER_SCRIPT_PATH_PREPEND")).To(Succeed())
 		})
 
-		it("runs 'composer global require'", func() {
+		it("runs composer install through the run-as executable", func() {
 			_, err := build(packit.BuildContext{
 				BuildpackInfo: buildpackInfo,
+				CNBPath:       cnbPath,
 				WorkingDir:    workingDir,
 				Layers:        packit.Layers{Path: layersDir},
 				Plan:          buildpackPlan,
 			})
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(composerGlobalExecution.Args).To(Equal([]string{"global", "require", "--no-progress", "friendsofphp/php-cs-fixer", "squizlabs/php_codesniffer=*"}))
-			Expect(composerGlobalExecution.Dir).To(Equal(filepath.Join(layersDir, "composer-global")))
-			Expect(composerGlobalExecution.Stdout).ToNot(BeNil())
-			Expect(composerGlobalExecution.Stderr).ToNot(BeNil())
-			Expect(len(composerGlobalExecution.Env)).To(Equal(len(os.Environ()) + 5))
+			Expect(composerInstallExecutable.ExecuteCall.CallCount).To(Equal(1))
+			Expect(shim).To(ContainSubstring(fmt.Sprintf("exec '%s' '%d:%d' '%s' \"$@\"", filepath.Join(cnbPath, "bin", "run-as"), os.Getuid(), os.Getgid(), filepath.Join(os.Getenv("BP_COMPOSER_SCRIPT_PATH_PREPEND"), "composer"))))
+			Expect(os.ReadFile(filepath.Join(workingDir, "vendor", "uid"))).To(Equal([]byte(fmt.Sprintf("%d\n", os.Getuid()))))
+			Expect(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor", "uid")).To(BeARegularFile())
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("Running 'composer install' as %d:%d (BP_COMPOSER_RUN_AS)", os.Getuid(), os.Getgid())))
+		})
 
-			Expect(composerGlobalExecution.Env).To(ContainElements(
-				"COMPOSER_NO_INTERACTION=1",
-				fmt.Sprintf("COMPOSER_HOME=%s", filepath.Join(layersDir, "composer-global")),
-				"COMPOSER_VENDOR_DIR=vendor",
-				fmt.Sprintf("PHPRC=%s", filepath.Join(layersDir, "composer-php-ini", "composer-php.ini")),
-				"PATH=fake-path-from-tests"))
+		context("when the user is invalid", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_RUN_AS", "cnb")).To(Succeed())
+			})
 
-			Expect(composerInstallExecution.Env).To(ContainElements(
-				fmt.Sprintf("PATH=%s:fake-path-from-tests", filepath.Join(layersDir, "composer-global", "vendor", "bin"))))
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError(`BP_COMPOSER_RUN_AS must be of the form "uid:gid", found "cnb"`))
+			})
 		})
 	})
 
-	context("when the checksum for composer.lock matches a previous layer's checksum", func() {
+	context("when composer install runs with --no-dev", func() {
 		it.Before(func() {
-			buildpackPlan.Entries[0].Metadata["launch"] = true
-			buildpackPlan.Entries[0].Metadata["build"] = true
-			calculator.SumCall.Returns.String = "sha-from-composer-lock"
+			installOptions.DetermineCall.Returns.InstallOptionSlice = []composer.InstallOption{
+				{Value: "--no-progress", Source: composer.InstallOptionSourceDefault},
+				{Value: "--no-dev", Source: composer.InstallOptionSourceDefault},
+			}
+		})
 
-			err := os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
-				[]byte(`[metadata]
-stack = ""
-composer-lock-sha = "sha-from-composer-lock"
-`), os.ModePerm)
+		it("sets COMPOSER_NO_DEV at launch", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
 			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Layers[0].LaunchEnv).To(HaveKeyWithValue("COMPOSER_NO_DEV.default", "1"))
+			Expect(buffer.String()).To(ContainSubstring("Configuring launch environment for composer"))
+		})
+	})
 
-			Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor"), os.ModePerm)).To(Succeed())
-			Expect(os.WriteFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor", "file.txt"), []byte(""), os.ModePerm)).To(Succeed())
+	context("when composer-packages is not required at launch", func() {
+		it.Before(func() {
+			buildpackPlan.Entries[0].Metadata["launch"] = false
 		})
 
-		it.After(func() {
-			Expect(os.RemoveAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName))).To(Succeed())
+		it("does not set a launch environment", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Layers[0].LaunchEnv).To(BeEmpty())
+			Expect(buffer.String()).NotTo(ContainSubstring("Configuring launch environment for composer"))
 		})
+	})
 
-		context("with BP_RUN_COMPOSER_INSTALL set to false", func() {
+	context("with COMPOSER set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("COMPOSER", "./foo/bar.file")).To(Succeed())
+		})
+
+		it("provides COMPOSER to composer install composerInstallExecution", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(composerInstallExecution.Env).To(ContainElements(
+				fmt.Sprintf("COMPOSER=%s", filepath.Join(workingDir, "foo", "bar.file"))))
+			Expect(calculator.SumCall.Receives.Paths).To(Equal([]string{filepath.Join(workingDir, "foo", "composer.lock")}))
+		})
+	})
+
+	context("when the dev packages are not installed", func() {
+		var (
+			sbomLock      string
+			sbomInstalled string
+		)
+
+		it.Before(func() {
+			installOptions.DetermineCall.Returns.InstallOptionSlice = []composer.InstallOption{
+				{Value: "--no-dev", Source: composer.InstallOptionSourceDefault},
+			}
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+	"content-hash": "some-content-hash",
+	"packages": [{"name": "some/package", "version": "1.0.0"}],
+	"packages-dev": [{"name": "phpunit/phpunit", "version": "10.0.0"}]
+}`), os.ModePerm)).To(Succeed())
+
+			composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "composer"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "composer", "installed.json"), []byte(`{
+	"packages": [{"name": "some/package", "version": "1.0.0"}, {"name": "phpunit/phpunit", "version": "10.0.0"}],
+	"dev": false,
+	"dev-package-names": ["phpunit/phpunit"]
+}`), os.ModePerm)).To(Succeed())
+				return nil
+			}
+
+			sbomLock, sbomInstalled = "", ""
+			sbomGenerator.GenerateCall.Stub = func(dir string) (sbom.SBOM, error) {
+				content, err := os.ReadFile(filepath.Join(dir, "composer.lock"))
+				Expect(err).NotTo(HaveOccurred())
+				sbomLock = string(content)
+
+				content, err = os.ReadFile(filepath.Join(dir, "vendor", "composer", "installed.json"))
+				Expect(err).NotTo(HaveOccurred())
+				sbomInstalled = string(content)
+
+				return sbom.SBOM{}, nil
+			}
+		})
+
+		it("excludes them from the SBOM", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(sbomGenerator.GenerateCall.Receives.Dir).To(Equal(workingDir))
+
+			content, err := os.ReadFile(filepath.Join(workingDir, "composer.lock"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring("phpunit/phpunit"))
+			content, err = os.ReadFile(filepath.Join(workingDir, "vendor", "composer", "installed.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring(`"dev-package-names": ["phpunit/phpunit"]`))
+
+			Expect(sbomLock).To(MatchJSON(`{
+	"content-hash": "some-content-hash",
+	"packages": [{"name": "some/package", "version": "1.0.0"}],
+	"packages-dev": []
+}`))
+			Expect(sbomInstalled).To(MatchJSON(`{
+	"packages": [{"name": "some/package", "version": "1.0.0"}],
+	"dev": false,
+	"dev-package-names": []
+}`))
+
+			Expect(result.Layers[0].Metadata["sbom-excludes-dev"]).To(BeTrue())
+			Expect(buffer.String()).To(ContainSubstring("Excluding the dev packages of composer.lock, as they have not been installed"))
+		})
+
+		context("when an SBOM including them has been cached for the same composer.lock", func() {
 			it.Before(func() {
 				Expect(os.Setenv("BP_RUN_COMPOSER_INSTALL", "false")).To(Succeed())
+				calculator.SumCall.Returns.String = "sha-from-composer-lock"
+
+				Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
+					[]byte(`[metadata]
+metadata-version = 1
+stack = ""
+composer-lock-sha = "sha-from-composer-lock"
+sbom-composer-lock-sha = "sha-from-composer-lock"
+sbom-formats = "application/vnd.cyclonedx+json,application/spdx+json"
+sbom-extensions = "cdx.json,spdx.json"
+`), os.ModePerm)).To(Succeed())
+
+				Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor", "composer"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor", "composer", "installed.json"), []byte(`{"packages": []}`), os.ModePerm)).To(Succeed())
+
+				sbomCacheDir := filepath.Join(layersDir, composer.ComposerPackagesLayerName, "sbom-cache")
+				Expect(os.MkdirAll(sbomCacheDir, os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(sbomCacheDir, "cdx.json"), []byte(`{"cached": "cdx"}`), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(sbomCacheDir, "spdx.json"), []byte(`{"cached": "spdx"}`), os.ModePerm)).To(Succeed())
 			})
+
 			it.After(func() {
 				Expect(os.Unsetenv("BP_RUN_COMPOSER_INSTALL")).To(Succeed())
 			})
 
-			it("reuses the cached version of the composer packages", func() {
+			it("generates the SBOM again", func() {
 				result, err := build(packit.BuildContext{
 					BuildpackInfo: buildpackInfo,
 					WorkingDir:    workingDir,
@@ -401,92 +743,310 @@ composer-lock-sha = "sha-from-composer-lock"
 				})
 				Expect(err).NotTo(HaveOccurred())
 
-				Expect(buffer).NotTo(ContainSubstring("Running 'composer install options from fake'"))
+				Expect(result.Layers[0].Metadata["cache-status"]).To(Equal("hit"))
+				Expect(sbomGenerator.GenerateCall.CallCount).To(Equal(1))
+				Expect(buffer.String()).NotTo(ContainSubstring("Reusing cached SBOM"))
+			})
+		})
+	})
 
-				Expect(calculator.SumCall.Receives.Paths).To(Equal([]string{filepath.Join(workingDir, "composer.lock")}))
-				layers := result.Layers
-				Expect(layers).To(HaveLen(1))
+	context("with BP_COMPOSER_FILE set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_FILE", "composer-prod.json")).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer-prod.json"), []byte(`{}`), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer-prod.lock"), []byte(`{}`), os.ModePerm)).To(Succeed())
+		})
 
-				packagesLayer := layers[0]
-				Expect(packagesLayer.Name).To(Equal(composer.ComposerPackagesLayerName))
-				Expect(packagesLayer.Path).To(Equal(filepath.Join(layersDir, composer.ComposerPackagesLayerName)))
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_FILE")).To(Succeed())
+		})
 
-				Expect(packagesLayer.Build).To(BeTrue())
-				Expect(packagesLayer.Launch).To(BeTrue())
-				Expect(packagesLayer.Cache).To(BeTrue())
+		it("exports COMPOSER and checksums the lock file of the selected composer.json", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
 
-				Expect(packagesLayer.Metadata["composer-lock-sha"]).To(Equal("sha-from-composer-lock"))
-				Expect(packagesLayer.Metadata["stack"]).To(Equal(""))
+			composerEnv := fmt.Sprintf("COMPOSER=%s", filepath.Join(workingDir, "composer-prod.json"))
+			Expect(composerInstallExecution.Env).To(ContainElement(composerEnv))
+			Expect(composerConfigExecutable.ExecuteCall.Receives.Execution.Env).To(ContainElement(composerEnv))
+			Expect(composerCheckPlatformReqsExecExecutable.ExecuteCall.Receives.Execution.Env).To(ContainElement(composerEnv))
 
-				Expect(packagesLayer.SBOM.Formats()).To(HaveLen(2))
-				cdx := packagesLayer.SBOM.Formats()[0]
-				spdx := packagesLayer.SBOM.Formats()[1]
+			Expect(calculator.SumCall.Receives.Paths).To(Equal([]string{filepath.Join(workingDir, "composer-prod.lock")}))
 
-				Expect(cdx.Extension).To(Equal("cdx.json"))
-				content, err := io.ReadAll(cdx.Content)
-				Expect(err).NotTo(HaveOccurred())
-				Expect(string(content)).To(MatchJSON(`{
-			"bomFormat": "CycloneDX",
-			"components": [],
-			"metadata": {
-				"tools": [
-					{
-						"name": "syft",
-						"vendor": "anchore",
-						"version": "[not provided]"
-					}
-				]
-			},
-			"specVersion": "1.3",
-			"version": 1
-		}`))
+			Expect(result.Layers[0].LaunchEnv).To(HaveKeyWithValue("COMPOSER.default", filepath.Join(workingDir, "composer-prod.json")))
+		})
+	})
 
-				Expect(spdx.Extension).To(Equal("spdx.json"))
-				content, err = io.ReadAll(spdx.Content)
-				Expect(err).NotTo(HaveOccurred())
-				Expect(string(content)).To(MatchJSON(`{
-			"SPDXID": "SPDXRef-DOCUMENT",
-			"creationInfo": {
-				"created": "0001-01-01T00:00:00Z",
-				"creators": [
-					"Organization: Anchore, Inc",
-					"Tool: syft-"
-				],
-				"licenseListVersion": "3.16"
-			},
-			"dataLicense": "CC0-1.0",
-			"documentNamespace": "https://paketo.io/packit/unknown-source-type/unknown-88cfa225-65e0-5755-895f-c1c8f10fde76",
-			"name": "unknown",
-			"relationships": [
-				{
-					"relatedSpdxElement": "SPDXRef-DOCUMENT",
-					"relationshipType": "DESCRIBES",
-					"spdxElementId": "SPDXRef-DOCUMENT"
+	context("with COMPOSER_VENDOR_DIR set", func() {
+		var (
+			err       error
+			customDir string
+		)
+
+		it.Before(func() {
+			customDir, err = os.MkdirTemp(workingDir, "some-custom-dir")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Setenv("COMPOSER_VENDOR_DIR", filepath.Base(customDir))).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(customDir)).To(Succeed())
+			Expect(os.Unsetenv("COMPOSER_VENDOR_DIR")).To(Succeed())
+		})
+
+		it("uses custom COMPOSER_VENDOR_DIR", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(composerInstallExecution.Env).To(ContainElement(fmt.Sprintf("COMPOSER_VENDOR_DIR=%s", customDir)))
+		})
+
+		context("with previously existing vendor dir", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(customDir, "existing-file.text"), []byte(""), os.ModePerm)).To(Succeed())
+			})
+
+			it("existing files are in final composer packages layer", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor", "existing-file.text")).To(BeAnExistingFile())
+			})
+		})
+	})
+
+	context("with config.vendor-dir set in composer.json", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{"config": {"vendor-dir": "lib/vendor"}}`), os.ModePerm)).To(Succeed())
+
+			composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				composerInstallExecution = temp
+				return os.MkdirAll(filepath.Join(workingDir, "lib", "vendor", "local-package-name"), os.ModePerm)
+			}
+		})
+
+		it("uses the vendor dir from composer.json", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(composerInstallExecution.Env).To(ContainElement(fmt.Sprintf("COMPOSER_VENDOR_DIR=%s", filepath.Join(workingDir, "lib", "vendor"))))
+			Expect(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor", "local-package-name")).To(BeADirectory())
+		})
+
+		context("when it is not underneath the project root", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{"config": {"vendor-dir": "../vendor"}}`), os.ModePerm)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError(`config.vendor-dir in composer.json must be a relative path underneath the project root, found "../vendor"`))
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_INSTALL_GLOBAL", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_INSTALL_GLOBAL", "friendsofphp/php-cs-fixer squizlabs/php_codesniffer=*")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_INSTALL_GLOBAL")).To(Succeed())
+		})
+
+		it("runs 'composer global require'", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(composerGlobalExecution.Args).To(Equal([]string{"global", "require", "--no-progress", "friendsofphp/php-cs-fixer", "squizlabs/php_codesniffer=*"}))
+			Expect(composerGlobalExecution.Dir).To(Equal(filepath.Join(layersDir, "composer-global")))
+			Expect(composerGlobalExecution.Stdout).ToNot(BeNil())
+			Expect(composerGlobalExecution.Stderr).ToNot(BeNil())
+			Expect(len(composerGlobalExecution.Env)).To(Equal(len(os.Environ()) + 9))
+
+			Expect(composerGlobalExecution.Env).To(ContainElements(
+				"COMPOSER_NO_INTERACTION=1",
+				fmt.Sprintf("COMPOSER_HOME=%s", filepath.Join(layersDir, "composer-global")),
+				"COMPOSER_VENDOR_DIR=vendor",
+				fmt.Sprintf("PHPRC=%s", filepath.Join(layersDir, "composer-php-ini", "composer-php.ini")),
+				"PATH=fake-path-from-tests"))
+
+			Expect(composerInstallExecution.Env).To(ContainElements(
+				fmt.Sprintf("PATH=%s:fake-path-from-tests", filepath.Join(layersDir, "composer-global", "vendor", "bin"))))
+		})
+
+		context("with scoped environment variables", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_GLOBAL_ENV_GITHUB_TOKEN", "global-token")).To(Succeed())
+				Expect(os.Setenv("BP_COMPOSER_INSTALL_ENV_GITHUB_TOKEN", "install-token")).To(Succeed())
+				Expect(os.Setenv("BP_COMPOSER_INSTALL_ENV_COMPOSER_AUTH", `{"http-basic": {}}`)).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_GLOBAL_ENV_GITHUB_TOKEN")).To(Succeed())
+				Expect(os.Unsetenv("BP_COMPOSER_INSTALL_ENV_GITHUB_TOKEN")).To(Succeed())
+				Expect(os.Unsetenv("BP_COMPOSER_INSTALL_ENV_COMPOSER_AUTH")).To(Succeed())
+			})
+
+			it("sets them for the respective execution only", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerGlobalExecution.Env).To(ContainElement("GITHUB_TOKEN=global-token"))
+				Expect(composerGlobalExecution.Env).NotTo(ContainElement("GITHUB_TOKEN=install-token"))
+
+				Expect(composerInstallExecution.Env[len(composerInstallExecution.Env)-2:]).To(Equal([]string{
+					`COMPOSER_AUTH={"http-basic": {}}`,
+					"GITHUB_TOKEN=install-token",
+				}))
+				Expect(composerInstallExecution.Env).NotTo(ContainElement("GITHUB_TOKEN=global-token"))
+				Expect(composerConfigExecution.Env).NotTo(ContainElement(HavePrefix("GITHUB_TOKEN=")))
+
+				Expect(buffer.String()).To(ContainSubstring("Setting environment variables for 'composer install'"))
+				Expect(buffer.String()).To(ContainSubstring("GITHUB_TOKEN (from BP_COMPOSER_INSTALL_ENV_GITHUB_TOKEN)"))
+				Expect(buffer.String()).To(ContainSubstring("Setting environment variables for 'composer global'"))
+				Expect(buffer.String()).NotTo(ContainSubstring("install-token"))
+			})
+		})
+	})
+
+	context("when the checksum for composer.lock matches a previous layer's checksum", func() {
+		it.Before(func() {
+			buildpackPlan.Entries[0].Metadata["launch"] = true
+			buildpackPlan.Entries[0].Metadata["build"] = true
+			calculator.SumCall.Returns.String = "sha-from-composer-lock"
+
+			err := os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
+				[]byte(`[metadata]
+metadata-version = 1
+stack = ""
+composer-lock-sha = "sha-from-composer-lock"
+`), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor", "file.txt"), []byte(""), os.ModePerm)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName))).To(Succeed())
+		})
+
+		context("when the cached layer has been built with another version of Composer", func() {
+			writeComposerVersion := func(version string) {
+				Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
+					[]byte(fmt.Sprintf(`[metadata]
+metadata-version = 1
+stack = ""
+composer-lock-sha = "sha-from-composer-lock"
+composer-version = %q
+`, version)), os.ModePerm)).To(Succeed())
+			}
+
+			it("rebuilds the layer for another minor version", func() {
+				writeComposerVersion("2.5.8")
+
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers[0].Metadata).To(HaveKeyWithValue("cache-status", "stale-composer"))
+				Expect(result.Layers[0].Metadata).To(HaveKeyWithValue("composer-version", "2.6.5"))
+				Expect(buffer.String()).To(ContainSubstring("Rebuilding the layer, as it has been built with Composer 2.5.8 instead of 2.6.5"))
+				Expect(buffer.String()).To(ContainSubstring("Composer packages cache: stale-composer"))
+			})
+
+			it("reuses the layer for another patch version", func() {
+				writeComposerVersion("2.6.4")
+
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers[0].Metadata).To(HaveKeyWithValue("cache-status", "hit"))
+				Expect(result.Layers[0].Metadata).To(HaveKeyWithValue("composer-version", "2.6.4"))
+				Expect(buffer.String()).To(ContainSubstring("Reusing the cached layer built with Composer 2.6.4 for Composer 2.6.5"))
+			})
+
+			it("reuses the layer if the version of Composer cannot be determined", func() {
+				writeComposerVersion("2.5.8")
+				composerVersionExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+					return errors.New("exit status 1")
 				}
-			],
-			"spdxVersion": "SPDX-2.2"
-		}`))
 
-				Expect(filepath.Join(workingDir, "vendor", "file.txt")).To(BeAnExistingFile())
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers[0].Metadata).To(HaveKeyWithValue("cache-status", "hit"))
 			})
 		})
 
-		context("when trying to reuse a layer but the stack changes", func() {
-			it("does not reuse the existing layer", func() {
+		context("with BP_RUN_COMPOSER_INSTALL set to false", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_RUN_COMPOSER_INSTALL", "false")).To(Succeed())
+			})
+			it.After(func() {
+				Expect(os.Unsetenv("BP_RUN_COMPOSER_INSTALL")).To(Succeed())
+			})
+
+			it("reuses the cached version of the composer packages", func() {
 				result, err := build(packit.BuildContext{
 					BuildpackInfo: buildpackInfo,
 					WorkingDir:    workingDir,
 					Layers:        packit.Layers{Path: layersDir},
 					Plan:          buildpackPlan,
-					Stack:         "another-stack",
 				})
 				Expect(err).NotTo(HaveOccurred())
 
-				Expect(buffer.String()).To(ContainSubstring("Running 'composer install options from fake'"))
+				Expect(buffer).NotTo(ContainSubstring("Running 'composer install options from fake'"))
 
 				Expect(calculator.SumCall.Receives.Paths).To(Equal([]string{filepath.Join(workingDir, "composer.lock")}))
 				layers := result.Layers
-				Expect(layers).To(HaveLen(1))
+				Expect(layers).To(HaveLen(2))
 
 				packagesLayer := layers[0]
 				Expect(packagesLayer.Name).To(Equal(composer.ComposerPackagesLayerName))
@@ -497,96 +1057,6893 @@ composer-lock-sha = "sha-from-composer-lock"
 				Expect(packagesLayer.Cache).To(BeTrue())
 
 				Expect(packagesLayer.Metadata["composer-lock-sha"]).To(Equal("sha-from-composer-lock"))
-				Expect(packagesLayer.Metadata["stack"]).To(Equal("another-stack"))
-			})
-		})
+				Expect(packagesLayer.Metadata["stack"]).To(Equal(""))
+				Expect(packagesLayer.Metadata["cache-status"]).To(Equal("hit"))
+				Expect(buffer.String()).To(ContainSubstring("Composer packages cache: hit"))
 
-		context("with previously existing vendor dir", func() {
-			it.Before(func() {
-				Expect(os.Mkdir(filepath.Join(workingDir, "vendor"), os.ModeDir|os.ModePerm)).To(Succeed())
-				Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "pre-existing-file.text"), []byte(""), os.ModePerm)).To(Succeed())
-			})
+				Expect(packagesLayer.SBOM.Formats()).To(HaveLen(2))
+				cdx := packagesLayer.SBOM.Formats()[0]
+				spdx := packagesLayer.SBOM.Formats()[1]
 
-			context("with BP_RUN_COMPOSER_INSTALL set to false", func() {
+				Expect(cdx.Extension).To(Equal("cdx.json"))
+				content, err := io.ReadAll(cdx.Content)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(MatchJSON(`{
+			"bomFormat": "CycloneDX",
+			"components": [],
+			"metadata": {
+				"tools": [
+					{
+						"name": "syft",
+						"vendor": "anchore",
+						"version": "[not provided]"
+					}
+				]
+			},
+			"specVersion": "1.3",
+			"version": 1
+		}`))
+
+				Expect(spdx.Extension).To(Equal("spdx.json"))
+				content, err = io.ReadAll(spdx.Content)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(MatchJSON(`{
+			"SPDXID": "SPDXRef-DOCUMENT",
+			"creationInfo": {
+				"created": "0001-01-01T00:00:00Z",
+				"creators": [
+					"Organization: Anchore, Inc",
+					"Tool: syft-"
+				],
+				"licenseListVersion": "3.16"
+			},
+			"dataLicense": "CC0-1.0",
+			"documentNamespace": "https://paketo.io/packit/unknown-source-type/unknown-88cfa225-65e0-5755-895f-c1c8f10fde76",
+			"name": "unknown",
+			"relationships": [
+				{
+					"relatedSpdxElement": "SPDXRef-DOCUMENT",
+					"relationshipType": "DESCRIBES",
+					"spdxElementId": "SPDXRef-DOCUMENT"
+				}
+			],
+			"spdxVersion": "SPDX-2.2"
+		}`))
+
+				Expect(filepath.Join(workingDir, "vendor", "file.txt")).To(BeAnExistingFile())
+			})
+
+			context("when the workspace has a vendor directory", func() {
+				it.Before(func() {
+					Expect(os.MkdirAll(filepath.Join(workingDir, "vendor"), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "stale.txt"), []byte(""), os.ModePerm)).To(Succeed())
+
+					// left by an interrupted build
+					Expect(os.MkdirAll(filepath.Join(workingDir, "vendor.new"), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, "vendor.new", "leftover.txt"), []byte(""), os.ModePerm)).To(Succeed())
+				})
+
+				it("swaps the cached vendor directory in", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(filepath.Join(workingDir, "vendor", "file.txt")).To(BeAnExistingFile())
+					Expect(filepath.Join(workingDir, "vendor", "stale.txt")).NotTo(BeAnExistingFile())
+					Expect(filepath.Join(workingDir, "vendor", "leftover.txt")).NotTo(BeAnExistingFile())
+					Expect(filepath.Join(workingDir, "vendor.new")).NotTo(BeADirectory())
+					Expect(filepath.Join(workingDir, "vendor.old")).NotTo(BeADirectory())
+
+					Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("Copying from %s => to %s",
+						filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor"), filepath.Join(workingDir, "vendor.new"))))
+					Expect(buffer.String()).To(ContainSubstring("Detected existing vendored packages, replacing with cached vendored packages"))
+				})
+			})
+		})
+
+		context("with an SBOM cached for the same composer.lock", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_RUN_COMPOSER_INSTALL", "false")).To(Succeed())
+
+				err := os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
+					[]byte(`[metadata]
+metadata-version = 1
+stack = ""
+composer-lock-sha = "sha-from-composer-lock"
+sbom-composer-lock-sha = "sha-from-composer-lock"
+sbom-formats = "application/vnd.cyclonedx+json,application/spdx+json"
+sbom-extensions = "cdx.json,spdx.json"
+`), os.ModePerm)
+				Expect(err).NotTo(HaveOccurred())
+
+				sbomCacheDir := filepath.Join(layersDir, composer.ComposerPackagesLayerName, "sbom-cache")
+				Expect(os.MkdirAll(sbomCacheDir, os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(sbomCacheDir, "cdx.json"), []byte(`{"cached": "cdx"}`), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(sbomCacheDir, "spdx.json"), []byte(`{"cached": "spdx"}`), os.ModePerm)).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_RUN_COMPOSER_INSTALL")).To(Succeed())
+			})
+
+			it("reuses the cached SBOM", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(sbomGenerator.GenerateCall.CallCount).To(Equal(0))
+				Expect(buffer.String()).To(ContainSubstring("Reusing cached SBOM for unchanged composer.lock"))
+
+				formats := result.Layers[0].SBOM.Formats()
+				Expect(formats).To(HaveLen(2))
+				Expect(formats[0].Extension).To(Equal("cdx.json"))
+				content, err := io.ReadAll(formats[0].Content)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal(`{"cached": "cdx"}`))
+				Expect(formats[1].Extension).To(Equal("spdx.json"))
+			})
+
+			context("with BP_COMPOSER_LICENSE_POLICY set", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_ALLOWED_LICENSES", "MIT, Apache-2.0")).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "sbom-cache", "spdx.json"), []byte(`{
+    "packages": [
+        {
+            "versionInfo": "2.1.0",
+            "licenseDeclared": "GPL-3.0-only",
+            "externalRefs": [{"referenceType": "purl", "referenceLocator": "pkg:composer/acme/billing@2.1.0"}]
+        },
+        {
+            "versionInfo": "3.5.0",
+            "licenseDeclared": "MIT",
+            "externalRefs": [{"referenceType": "purl", "referenceLocator": "pkg:composer/monolog/monolog@3.5.0"}]
+        }
+    ]
+}`), os.ModePerm)).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_COMPOSER_LICENSE_POLICY")).To(Succeed())
+					Expect(os.Unsetenv("BP_COMPOSER_ALLOWED_LICENSES")).To(Succeed())
+				})
+
+				context("to fail", func() {
+					it.Before(func() {
+						Expect(os.Setenv("BP_COMPOSER_LICENSE_POLICY", "fail")).To(Succeed())
+					})
+
+					it("fails the build", func() {
+						_, err := build(packit.BuildContext{
+							BuildpackInfo: buildpackInfo,
+							WorkingDir:    workingDir,
+							Layers:        packit.Layers{Path: layersDir},
+							Plan:          buildpackPlan,
+						})
+						Expect(err).To(MatchError("found 1 package(s) violating the license policy: acme/billing 2.1.0 (GPL-3.0-only)"))
+						Expect(buffer.String()).To(ContainSubstring("Checking licenses against the license policy (BP_COMPOSER_LICENSE_POLICY=fail)"))
+						Expect(buffer.String()).To(ContainSubstring("Allowed licenses: MIT, Apache-2.0"))
+					})
+				})
+
+				context("to warn", func() {
+					it.Before(func() {
+						Expect(os.Setenv("BP_COMPOSER_LICENSE_POLICY", "warn")).To(Succeed())
+					})
+
+					it("logs the violations and keeps the SBOM readable", func() {
+						result, err := build(packit.BuildContext{
+							BuildpackInfo: buildpackInfo,
+							WorkingDir:    workingDir,
+							Layers:        packit.Layers{Path: layersDir},
+							Plan:          buildpackPlan,
+						})
+						Expect(err).NotTo(HaveOccurred())
+						Expect(buffer.String()).To(ContainSubstring("- acme/billing 2.1.0 (GPL-3.0-only)"))
+						Expect(buffer.String()).To(ContainSubstring("WARNING: Found 1 package(s) violating the license policy"))
+
+						formats := result.Layers[0].SBOM.Formats()
+						Expect(formats[1].Extension).To(Equal("spdx.json"))
+						content, err := io.ReadAll(formats[1].Content)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(string(content)).To(ContainSubstring("pkg:composer/acme/billing@2.1.0"))
+					})
+				})
+
+				context("without BP_COMPOSER_ALLOWED_LICENSES", func() {
+					it.Before(func() {
+						Expect(os.Setenv("BP_COMPOSER_LICENSE_POLICY", "fail")).To(Succeed())
+						Expect(os.Unsetenv("BP_COMPOSER_ALLOWED_LICENSES")).To(Succeed())
+					})
+
+					it("returns an error", func() {
+						_, err := build(packit.BuildContext{
+							BuildpackInfo: buildpackInfo,
+							WorkingDir:    workingDir,
+							Layers:        packit.Layers{Path: layersDir},
+							Plan:          buildpackPlan,
+						})
+						Expect(err).To(MatchError(`BP_COMPOSER_ALLOWED_LICENSES must be set when BP_COMPOSER_LICENSE_POLICY is "fail"`))
+					})
+				})
+			})
+
+			context("when other SBOM formats are requested", func() {
+				it.Before(func() {
+					buildpackInfo.SBOMFormats = []string{sbom.CycloneDXFormat}
+				})
+
+				it("generates the SBOM", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(sbomGenerator.GenerateCall.CallCount).To(Equal(1))
+				})
+			})
+		})
+
+		context("when trying to reuse a layer but the stack changes", func() {
+			it("does not reuse the existing layer", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+					Stack:         "another-stack",
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).To(ContainSubstring("Running 'composer install options from fake'"))
+
+				Expect(calculator.SumCall.Receives.Paths).To(Equal([]string{filepath.Join(workingDir, "composer.lock")}))
+				layers := result.Layers
+				Expect(layers).To(HaveLen(2))
+
+				packagesLayer := layers[0]
+				Expect(packagesLayer.Name).To(Equal(composer.ComposerPackagesLayerName))
+				Expect(packagesLayer.Path).To(Equal(filepath.Join(layersDir, composer.ComposerPackagesLayerName)))
+
+				Expect(packagesLayer.Build).To(BeTrue())
+				Expect(packagesLayer.Launch).To(BeTrue())
+				Expect(packagesLayer.Cache).To(BeTrue())
+
+				Expect(packagesLayer.Metadata["composer-lock-sha"]).To(Equal("sha-from-composer-lock"))
+				Expect(packagesLayer.Metadata["stack"]).To(Equal("another-stack"))
+				Expect(packagesLayer.Metadata["cache-status"]).To(Equal("stale-stack"))
+				Expect(buffer.String()).To(ContainSubstring("Composer packages cache: stale-stack"))
+			})
+
+			context("with BP_COMPOSER_REVALIDATE_STACK set to true", func() {
+				var seeded []bool
+
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_REVALIDATE_STACK", "true")).To(Succeed())
+
+					seeded = nil
+					composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+						_, err := os.Stat(filepath.Join(workingDir, "vendor", "file.txt"))
+						seeded = append(seeded, err == nil)
+						Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "local-package-name"), os.ModeDir|os.ModePerm)).To(Succeed())
+						return nil
+					}
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_COMPOSER_REVALIDATE_STACK")).To(Succeed())
+				})
+
+				it("installs the cached packages again and reuses them", func() {
+					result, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+						Stack:         "another-stack",
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(seeded).To(Equal([]bool{true}))
+					Expect(composerCheckPlatformReqsExecExecutable.ExecuteCall.CallCount).To(Equal(2))
+
+					packagesLayer := result.Layers[0]
+					Expect(packagesLayer.Metadata["stack"]).To(Equal("another-stack"))
+					Expect(packagesLayer.Metadata["cache-status"]).To(Equal("revalidated-stack"))
+					Expect(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor", "file.txt")).To(BeARegularFile())
+
+					Expect(buffer.String()).To(ContainSubstring(`Revalidating the cached layer built on stack "" for stack "another-stack" (BP_COMPOSER_REVALIDATE_STACK)`))
+					Expect(buffer.String()).To(ContainSubstring("The cached packages satisfy the platform requirements of the stack"))
+				})
+
+				context("when the stack does not satisfy the platform requirements", func() {
+					it.Before(func() {
+						composerCheckPlatformReqsExecExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+							_, err := temp.Stdout.Write([]byte(`[
+    {"name": "php", "version": "8.1.4", "status": "failed", "failed_requirement": {"source": "some/package", "type": "requires", "target": "php", "constraint": "^8.2"}, "provider": null}
+]`))
+							return err
+						}
+					})
+
+					it("rebuilds the layer", func() {
+						result, err := build(packit.BuildContext{
+							BuildpackInfo: buildpackInfo,
+							WorkingDir:    workingDir,
+							Layers:        packit.Layers{Path: layersDir},
+							Plan:          buildpackPlan,
+							Stack:         "another-stack",
+						})
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(seeded).To(Equal([]bool{true, false}))
+
+						packagesLayer := result.Layers[0]
+						Expect(packagesLayer.Metadata["cache-status"]).To(Equal("stale-stack"))
+						Expect(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor", "file.txt")).NotTo(BeAnExistingFile())
+
+						Expect(buffer.String()).To(ContainSubstring("Revalidation failed, rebuilding the layer: the stack does not satisfy the platform requirements: php 8.1.4 (some/package requires ^8.2)"))
+					})
+				})
+
+				context("when the cached packages contain native binaries", func() {
+					it.Before(func() {
+						Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor", "acme", "native", "lib"), os.ModePerm)).To(Succeed())
+						Expect(os.WriteFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor", "acme", "native", "lib", "native.so"), []byte("\x7fELF"), os.ModePerm)).To(Succeed())
+					})
+
+					it("rebuilds the layer", func() {
+						result, err := build(packit.BuildContext{
+							BuildpackInfo: buildpackInfo,
+							WorkingDir:    workingDir,
+							Layers:        packit.Layers{Path: layersDir},
+							Plan:          buildpackPlan,
+							Stack:         "another-stack",
+						})
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(seeded).To(Equal([]bool{false}))
+						Expect(result.Layers[0].Metadata["cache-status"]).To(Equal("stale-stack"))
+						Expect(buffer.String()).To(ContainSubstring("Not revalidating the cached layer, as the cached packages contain native binaries: acme/native"))
+					})
+				})
+			})
+		})
+
+		context("when trying to reuse a layer but BP_COMPOSER_CACHE_KEY_SALT is set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_CACHE_KEY_SALT", "tenant-a")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_CACHE_KEY_SALT")).To(Succeed())
+			})
+
+			it("does not reuse the existing layer and records the hashed salt", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				packagesLayer := result.Layers[0]
+				Expect(packagesLayer.Metadata["cache-status"]).To(Equal("stale-inputs"))
+				Expect(packagesLayer.Metadata["layer-inputs"]).To(Equal(map[string]interface{}{
+					"autoloader-suffix": "PaketoDefaultAutoloaderSuffix",
+					"install-options":   "options from fake",
+					"arch":              runtime.GOARCH,
+					"cache-key-salt":    fmt.Sprintf("%x", sha256.Sum256([]byte("tenant-a"))),
+				}))
+				Expect(buffer.String()).To(ContainSubstring("Segregating the composer packages cache with BP_COMPOSER_CACHE_KEY_SALT"))
+				Expect(buffer.String()).To(ContainSubstring("Composer packages cache: stale-inputs"))
+			})
+		})
+
+		context("when trying to reuse a layer built for another distribution", func() {
+			it.Before(func() {
+				Expect(os.Setenv("CNB_TARGET_DISTRO_NAME", "ubuntu")).To(Succeed())
+				Expect(os.Setenv("CNB_TARGET_DISTRO_VERSION", "24.04")).To(Succeed())
+
+				Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
+					[]byte(fmt.Sprintf(`[metadata]
+metadata-version = 1
+stack = ""
+composer-lock-sha = "sha-from-composer-lock"
+
+[metadata.layer-inputs]
+autoloader-suffix = "PaketoDefaultAutoloaderSuffix"
+install-options = "options from fake"
+arch = %q
+distro = "ubuntu 22.04"
+`, runtime.GOARCH)), os.ModePerm)).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("CNB_TARGET_DISTRO_NAME")).To(Succeed())
+				Expect(os.Unsetenv("CNB_TARGET_DISTRO_VERSION")).To(Succeed())
+			})
+
+			it("does not reuse the existing layer and records the distribution", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				packagesLayer := result.Layers[0]
+				Expect(packagesLayer.Metadata["cache-status"]).To(Equal("stale-inputs"))
+				Expect(packagesLayer.Metadata["layer-inputs"]).To(HaveKeyWithValue("distro", "ubuntu 24.04"))
+				Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("Rebuilding the layer for ubuntu 24.04 (linux/%s), as the cached packages may contain binaries built for another distribution", runtime.GOARCH)))
+				Expect(buffer.String()).To(ContainSubstring("Running 'composer install options from fake'"))
+			})
+		})
+
+		context("when trying to reuse a layer with native binaries", func() {
+			it.Before(func() {
+				Expect(os.Setenv("CNB_TARGET_OS", "linux")).To(Succeed())
+				Expect(os.Setenv("CNB_TARGET_ARCH", "arm")).To(Succeed())
+				Expect(os.Setenv("CNB_TARGET_ARCH_VARIANT", "v7")).To(Succeed())
+
+				Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor", "acme", "ffi", "lib"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor", "acme", "ffi", "lib", "libacme.so.1"), []byte("cached"), os.ModePerm)).To(Succeed())
+
+				composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+					Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "acme", "ffi", "bin"), os.ModeDir|os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "acme", "ffi", "bin", "acme"), []byte("\x7fELF\x02\x01\x01"), os.ModePerm)).To(Succeed())
+					Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "monolog", "monolog"), os.ModeDir|os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "monolog", "monolog", "README.md"), []byte("# Monolog"), os.ModePerm)).To(Succeed())
+					composerInstallExecution = temp
+					return nil
+				}
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("CNB_TARGET_OS")).To(Succeed())
+				Expect(os.Unsetenv("CNB_TARGET_ARCH")).To(Succeed())
+				Expect(os.Unsetenv("CNB_TARGET_ARCH_VARIANT")).To(Succeed())
+			})
+
+			context("which have been installed for the same target", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
+						[]byte(`[metadata]
+metadata-version = 1
+stack = ""
+composer-lock-sha = "sha-from-composer-lock"
+native-binaries = ["acme/ffi"]
+native-target = "linux/arm/v7"
+`), os.ModePerm)).To(Succeed())
+				})
+
+				it("reuses the existing layer", func() {
+					result, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(result.Layers[0].Metadata["cache-status"]).To(Equal("hit"))
+					Expect(buffer.String()).To(ContainSubstring("Reusing cached layer"))
+				})
+			})
+
+			context("which have been installed for another target", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
+						[]byte(`[metadata]
+metadata-version = 1
+stack = ""
+composer-lock-sha = "sha-from-composer-lock"
+native-binaries = ["acme/ffi"]
+native-target = "linux/arm/v6"
+`), os.ModePerm)).To(Succeed())
+				})
+
+				it("does not reuse the existing layer and records the native binaries", func() {
+					result, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					packagesLayer := result.Layers[0]
+					Expect(packagesLayer.Metadata["cache-status"]).To(Equal("stale-target"))
+					Expect(packagesLayer.Metadata["native-binaries"]).To(Equal([]string{"acme/ffi"}))
+					Expect(packagesLayer.Metadata["native-target"]).To(Equal("linux/arm/v7"))
+					Expect(buffer.String()).To(ContainSubstring("Rebuilding the layer for linux/arm/v7, as the cached packages contain native binaries installed for linux/arm/v6: acme/ffi"))
+					Expect(buffer.String()).To(ContainSubstring("Composer packages cache: stale-target"))
+					Expect(buffer.String()).To(ContainSubstring("Running 'composer install options from fake'"))
+					Expect(buffer.String()).To(ContainSubstring("Found native binaries in 1 package(s), the layer is rebuilt when the build target changes"))
+				})
+			})
+
+			context("which have been cached before the native binaries were recorded", func() {
+				it("does not reuse the existing layer", func() {
+					result, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(result.Layers[0].Metadata["cache-status"]).To(Equal("stale-target"))
+					Expect(buffer.String()).To(ContainSubstring("Rebuilding the layer for linux/arm/v7, as the cached packages contain native binaries installed for an unknown target: acme/ffi"))
+				})
+			})
+		})
+
+		context("when reusing a layer cached before the native binaries were recorded", func() {
+			it("records that it contains none", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				packagesLayer := result.Layers[0]
+				Expect(packagesLayer.Metadata["cache-status"]).To(Equal("hit"))
+				Expect(packagesLayer.Metadata["native-binaries"]).To(BeEmpty())
+				Expect(packagesLayer.Metadata).NotTo(HaveKey("native-target"))
+			})
+		})
+
+		context("when trying to reuse a layer but the install options change", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
+					[]byte(fmt.Sprintf(`[metadata]
+metadata-version = 1
+stack = ""
+composer-lock-sha = "sha-from-composer-lock"
+
+[metadata.layer-inputs]
+autoloader-suffix = "PaketoDefaultAutoloaderSuffix"
+install-options = "--no-dev"
+arch = %q
+`, runtime.GOARCH)), os.ModePerm)).To(Succeed())
+			})
+
+			it("does not reuse the existing layer", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers[0].Metadata["cache-status"]).To(Equal("stale-inputs"))
+				Expect(buffer.String()).To(ContainSubstring("Running 'composer install options from fake'"))
+			})
+		})
+
+		context("when trying to reuse a layer but only --no-dev changes", func() {
+			var seeded bool
+
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
+					[]byte(fmt.Sprintf(`[metadata]
+metadata-version = 1
+stack = ""
+composer-lock-sha = "sha-from-composer-lock"
+
+[metadata.layer-inputs]
+autoloader-suffix = "PaketoDefaultAutoloaderSuffix"
+install-options = "options from fake --no-dev"
+arch = %q
+`, runtime.GOARCH)), os.ModePerm)).To(Succeed())
+
+				seeded = false
+				composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+					_, err := os.Stat(filepath.Join(workingDir, "vendor", "file.txt"))
+					seeded = err == nil
+					Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "local-package-name"), os.ModeDir|os.ModePerm)).To(Succeed())
+					composerInstallExecution = temp
+					return nil
+				}
+			})
+
+			it("installs the dev packages into the cached packages", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(seeded).To(BeTrue())
+
+				packagesLayer := result.Layers[0]
+				Expect(packagesLayer.Metadata["cache-status"]).To(Equal("stale-dev-mode"))
+				Expect(packagesLayer.Metadata["layer-inputs"]).To(HaveKeyWithValue("install-options", "options from fake"))
+				Expect(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor", "file.txt")).To(BeARegularFile())
+
+				Expect(buffer.String()).To(ContainSubstring("Installing the dev packages into the cached packages, as '--no-dev' has been removed"))
+				Expect(buffer.String()).To(ContainSubstring("Composer packages cache: stale-dev-mode"))
+				Expect(buffer.String()).To(ContainSubstring("Running 'composer install options from fake'"))
+			})
+
+			context("when the cached layer has no vendor directory", func() {
+				it.Before(func() {
+					Expect(os.RemoveAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor"))).To(Succeed())
+				})
+
+				it("does not reuse the existing layer", func() {
+					result, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(seeded).To(BeFalse())
+					Expect(result.Layers[0].Metadata["cache-status"]).To(Equal("stale-inputs"))
+				})
+			})
+		})
+
+		context("when trying to reuse a layer but composer.lock changes", func() {
+			it.Before(func() {
+				calculator.SumCall.Returns.String = "sha-from-new-composer-lock"
+			})
+
+			it("does not reuse the existing layer", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).To(ContainSubstring("Running 'composer install options from fake'"))
+
+				packagesLayer := result.Layers[0]
+				Expect(packagesLayer.Metadata["composer-lock-sha"]).To(Equal("sha-from-new-composer-lock"))
+				Expect(packagesLayer.Metadata["cache-status"]).To(Equal("stale-lock"))
+				Expect(buffer.String()).To(ContainSubstring("Composer packages cache: stale-lock"))
+			})
+		})
+
+		context("when trying to reuse a layer but BP_COMPOSER_CONFIG changes", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_CONFIG", "process-timeout=900")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_CONFIG")).To(Succeed())
+			})
+
+			it("does not reuse the existing layer", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).To(ContainSubstring("Running 'composer install options from fake'"))
+
+				packagesLayer := result.Layers[0]
+				Expect(packagesLayer.Metadata["composer-lock-sha"]).To(Equal("sha-from-composer-lock"))
+				Expect(packagesLayer.Metadata["composer-config-sha"]).To(Equal(fmt.Sprintf("%x", sha256.Sum256([]byte("process-timeout=900\n")))))
+				Expect(packagesLayer.Metadata["cache-status"]).To(Equal("stale-config"))
+				Expect(buffer.String()).To(ContainSubstring("Composer packages cache: stale-config"))
+			})
+		})
+
+		context("when the layer has been cached without metadata version", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
+					[]byte(`[metadata]
+stack = ""
+composer-lock-sha = "sha-from-composer-lock"
+`), os.ModePerm)).To(Succeed())
+			})
+
+			it("does not reuse the existing layer", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).To(ContainSubstring("Running 'composer install options from fake'"))
+				Expect(buffer.String()).To(ContainSubstring("has metadata version 0, which cannot be migrated to 1, rebuilding it"))
+
+				packagesLayer := result.Layers[0]
+				Expect(packagesLayer.Metadata["metadata-version"]).To(Equal(composer.ComposerPackagesMetadataVersion))
+				Expect(packagesLayer.Metadata["cache-status"]).To(Equal("stale-layout"))
+				Expect(buffer.String()).To(ContainSubstring("Composer packages cache: stale-layout"))
+				Expect(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor", "file.txt")).NotTo(BeAnExistingFile())
+			})
+		})
+
+		context("with BP_COMPOSER_INCREMENTAL_AUTOLOAD set to true", func() {
+			var autoloadInputsSHA string
+
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_INCREMENTAL_AUTOLOAD", "true")).To(Succeed())
+
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{"autoload": {"psr-4": {"App\\": "src/"}}}`), os.ModePerm)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "src"), os.ModePerm)).To(Succeed())
+
+				autoloadInputsSHA = fmt.Sprintf("%x", sha256.Sum256([]byte(
+					"autoload={\"psr-4\": {\"App\\\\\": \"src/\"}}\nautoload-dev=\nsources=sha-from-composer-lock\n")))
+
+				Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
+					[]byte(fmt.Sprintf(`[metadata]
+metadata-version = 1
+stack = ""
+composer-lock-sha = "sha-from-composer-lock"
+autoload-inputs-sha = %q
+`, autoloadInputsSHA)), os.ModePerm)).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_INCREMENTAL_AUTOLOAD")).To(Succeed())
+			})
+
+			it("skips the autoload dump from cached files", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerInstallExecution.Args).To(Equal([]string{"install", "options", "from", "fake", "--no-autoloader"}))
+				Expect(buffer.String()).To(ContainSubstring("Skipping the autoload dump from cached files, as the autoload configuration and sources are unchanged"))
+				Expect(result.Layers[0].Metadata["autoload-inputs-sha"]).To(Equal(autoloadInputsSHA))
+			})
+
+			context("when the autoload configuration has changed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{"autoload": {"psr-4": {"App\\": "lib/"}}}`), os.ModePerm)).To(Succeed())
+				})
+
+				it("dumps the autoloader and records the new checksum", func() {
+					result, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(composerInstallExecution.Args).To(Equal([]string{"install", "options", "from", "fake"}))
+					Expect(buffer.String()).To(ContainSubstring("Dumping the autoloader from cached files, as the autoload configuration or sources have changed"))
+					Expect(result.Layers[0].Metadata["autoload-inputs-sha"]).NotTo(Equal(autoloadInputsSHA))
+					Expect(result.Layers[0].Metadata["autoload-inputs-sha"]).To(MatchRegexp(`^[0-9a-f]{64}$`))
+				})
+			})
+
+			context("when composer.json has autoload dump scripts", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{
+  "autoload": {"psr-4": {"App\\": "src/"}},
+  "scripts": {"post-autoload-dump": "@php artisan package:discover"}
+}`), os.ModePerm)).To(Succeed())
+				})
+
+				it("dumps the autoloader", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(composerInstallExecution.Args).To(Equal([]string{"install", "options", "from", "fake"}))
+					Expect(buffer.String()).To(ContainSubstring("Dumping the autoloader from cached files for the script post-autoload-dump"))
+				})
+			})
+		})
+
+		context("with BP_COMPOSER_REINSTALL_OPTIONS set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_REINSTALL_OPTIONS", "--no-scripts --no-autoloader")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_REINSTALL_OPTIONS")).To(Succeed())
+			})
+
+			it("runs composer install with the reinstall options from cached files", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerInstallExecution.Args).To(Equal([]string{"install", "--no-progress", "--no-scripts", "--no-autoloader"}))
+				Expect(buffer.String()).To(ContainSubstring("Options for 'composer install' from cached files"))
+				Expect(buffer.String()).To(ContainSubstring("--no-autoloader  (env var BP_COMPOSER_REINSTALL_OPTIONS)"))
+				Expect(buffer.String()).To(ContainSubstring("Running 'composer install --no-progress --no-scripts --no-autoloader' from cached files"))
+			})
+
+			context("when composer.lock changes", func() {
+				it.Before(func() {
+					calculator.SumCall.Returns.String = "sha-from-new-composer-lock"
+				})
+
+				it("runs composer install with the install options", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(composerInstallExecution.Args).To(Equal([]string{"install", "options", "from", "fake"}))
+				})
+			})
+		})
+
+		context("with BP_RUN_COMPOSER_INSTALL set to auto", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_RUN_COMPOSER_INSTALL", "auto")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_RUN_COMPOSER_INSTALL")).To(Succeed())
+			})
+
+			it("does not run composer install from cached files without plugins or install scripts", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerInstallExecutable.ExecuteCall.CallCount).To(Equal(0))
+				Expect(buffer.String()).To(ContainSubstring("Skipping 'composer install' from cached files, as there are no plugins or install scripts (BP_RUN_COMPOSER_INSTALL=auto)"))
+			})
+
+			context("when the project has plugins", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+    "packages": [{"name": "composer/installers", "type": "composer-plugin"}]
+}`), os.ModePerm)).To(Succeed())
+				})
+
+				it("runs composer install from cached files", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(composerInstallExecutable.ExecuteCall.CallCount).To(Equal(1))
+					Expect(buffer.String()).To(ContainSubstring("Running 'composer install' from cached files for 1 plugin(s) and install script(s) (BP_RUN_COMPOSER_INSTALL=auto)"))
+					Expect(buffer.String()).To(ContainSubstring("- plugin composer/installers"))
+					Expect(buffer.String()).To(ContainSubstring("Running 'composer install options from fake' from cached files"))
+				})
+			})
+		})
+
+		context("with BP_COMPOSER_OFFLINE_REFRESH set to true", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_OFFLINE_REFRESH", "true")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_OFFLINE_REFRESH")).To(Succeed())
+			})
+
+			it("runs composer install from cached files without network access", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerInstallExecution.Env).To(ContainElement("COMPOSER_DISABLE_NETWORK=1"))
+				Expect(buffer.String()).To(ContainSubstring("Network access is disabled with COMPOSER_DISABLE_NETWORK=1"))
+			})
+
+			context("when composer.lock changes", func() {
+				it.Before(func() {
+					calculator.SumCall.Returns.String = "sha-from-new-composer-lock"
+				})
+
+				it("runs composer install with network access", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(composerInstallExecution.Env).NotTo(ContainElement("COMPOSER_DISABLE_NETWORK=1"))
+				})
+			})
+
+			context("when composer install needs network access", func() {
+				it.Before(func() {
+					composerInstallExecutable.ExecuteCall.Stub = func(pexec.Execution) error {
+						return errors.New("Network disabled, request canceled")
+					}
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError("'composer install' from cached files failed without network access, unset BP_COMPOSER_OFFLINE_REFRESH if it needs to download packages: Network disabled, request canceled"))
+				})
+			})
+
+			context("when BP_COMPOSER_OFFLINE_REFRESH is invalid", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_OFFLINE_REFRESH", "not-a-bool")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError(ContainSubstring("BP_COMPOSER_OFFLINE_REFRESH")))
+				})
+			})
+		})
+
+		context("with previously existing vendor dir", func() {
+			it.Before(func() {
+				Expect(os.Mkdir(filepath.Join(workingDir, "vendor"), os.ModeDir|os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "pre-existing-file.text"), []byte(""), os.ModePerm)).To(Succeed())
+			})
+
+			context("with BP_RUN_COMPOSER_INSTALL set to false", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_RUN_COMPOSER_INSTALL", "false")).To(Succeed())
+				})
+				it.After(func() {
+					Expect(os.Unsetenv("BP_RUN_COMPOSER_INSTALL")).To(Succeed())
+				})
+
+				it("replaces workspace vendor directory with cached vendor directory", func() {
+					result, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(buffer).NotTo(ContainSubstring("Running 'composer install options from fake'"))
+
+					Expect(calculator.SumCall.Receives.Paths).To(Equal([]string{filepath.Join(workingDir, "composer.lock")}))
+					layers := result.Layers
+					Expect(layers).To(HaveLen(2))
+
+					packagesLayer := layers[0]
+					Expect(packagesLayer.Name).To(Equal(composer.ComposerPackagesLayerName))
+					Expect(packagesLayer.Path).To(Equal(filepath.Join(layersDir, composer.ComposerPackagesLayerName)))
+
+					Expect(packagesLayer.Build).To(BeTrue())
+					Expect(packagesLayer.Launch).To(BeTrue())
+					Expect(packagesLayer.Cache).To(BeTrue())
+
+					Expect(packagesLayer.Metadata["composer-lock-sha"]).To(Equal("sha-from-composer-lock"))
+					Expect(packagesLayer.Metadata["stack"]).To(Equal(""))
+
+					Expect(filepath.Join(workingDir, "vendor", "file.txt")).To(BeAnExistingFile())
+					Expect(filepath.Join(workingDir, "vendor", "pre-existing-file.text")).NotTo(BeAnExistingFile())
+				})
+			})
+		})
+	})
+
+	context("with drupal/core-composer-scaffold", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{
+	"require": {
+		"drupal/core-composer-scaffold": "^10.1"
+	},
+	"extra": {
+		"drupal-scaffold": {
+			"locations": {
+				"web-root": "web/"
+			}
+		}
+	}
+}`), os.ModePerm)).To(Succeed())
+
+			composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "composer"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "composer", "installed.json"), []byte(`{
+	"packages": [
+		{
+			"name": "drupal/core",
+			"extra": {
+				"drupal-scaffold": {
+					"file-mapping": {
+						"[web-root]/index.php": "assets/scaffold/files/index.php"
+					}
+				}
+			}
+		}
+	]
+}`), os.ModePerm)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "web"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "web", "index.php"), []byte("scaffolded"), os.ModePerm)).To(Succeed())
+				composerInstallExecution = temp
+				return nil
+			}
+
+			calculator.SumCall.Stub = func(paths ...string) (string, error) {
+				if paths[0] == filepath.Join(layersDir, composer.ComposerPackagesLayerName, "drupal-scaffold") {
+					return "scaffold-checksum", nil
+				}
+				return "default-checksum", nil
+			}
+		})
+
+		it("caches the scaffold files in the layer", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			packagesLayer := result.Layers[0]
+			Expect(packagesLayer.Metadata["drupal-scaffold-sha"]).To(Equal("scaffold-checksum"))
+
+			content, err := os.ReadFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "drupal-scaffold", "web", "index.php"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("scaffolded"))
+
+			Expect(buffer.String()).To(ContainSubstring("Caching 1 Drupal scaffold path(s)"))
+		})
+
+		context("when reusing a cached layer", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_RUN_COMPOSER_INSTALL", "false")).To(Succeed())
+
+				Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
+					[]byte(`[metadata]
+metadata-version = 1
+stack = ""
+composer-lock-sha = "default-checksum"
+drupal-scaffold-sha = "scaffold-checksum"
+`), os.ModePerm)).To(Succeed())
+
+				Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor"), os.ModePerm)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "drupal-scaffold", "web"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "drupal-scaffold", "web", "index.php"), []byte("cached"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "drupal-scaffold", "web", "update.php"), []byte("cached"), os.ModePerm)).To(Succeed())
+
+				Expect(os.MkdirAll(filepath.Join(workingDir, "web"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "web", "update.php"), []byte("from-app"), os.ModePerm)).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_RUN_COMPOSER_INSTALL")).To(Succeed())
+			})
+
+			it("restores the missing scaffold files into the workspace", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).To(ContainSubstring("Reusing cached layer"))
+				Expect(buffer.String()).To(ContainSubstring("Restored 1 cached Drupal scaffold file(s)"))
+
+				content, err := os.ReadFile(filepath.Join(workingDir, "web", "index.php"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("cached"))
+
+				content, err = os.ReadFile(filepath.Join(workingDir, "web", "update.php"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("from-app"))
+			})
+
+			context("when the cached scaffold files have been modified", func() {
+				it.Before(func() {
+					calculator.SumCall.Stub = func(paths ...string) (string, error) {
+						if paths[0] == filepath.Join(layersDir, composer.ComposerPackagesLayerName, "drupal-scaffold") {
+							return "modified-checksum", nil
+						}
+						return "default-checksum", nil
+					}
+				})
+
+				it("does not reuse the cached layer", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(buffer.String()).NotTo(ContainSubstring("Reusing cached layer"))
+					Expect(buffer.String()).To(ContainSubstring("Building new layer"))
+				})
+			})
+		})
+	})
+
+	context("with packages installed into installer-paths", func() {
+		it.Before(func() {
+			composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "composer"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "composer", "installed.json"), []byte(`{
+	"packages": [
+		{
+			"name": "wpackagist-plugin/akismet",
+			"install-path": "../../web/app/plugins/akismet/"
+		}
+	]
+}`), os.ModePerm)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "web", "app", "plugins", "akismet"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "web", "app", "plugins", "akismet", "akismet.php"), []byte("installed"), os.ModePerm)).To(Succeed())
+				composerInstallExecution = temp
+				return nil
+			}
+
+			calculator.SumCall.Stub = func(paths ...string) (string, error) {
+				if paths[0] == filepath.Join(layersDir, composer.ComposerPackagesLayerName, "installer-paths") {
+					return "installer-paths-checksum", nil
+				}
+				return "default-checksum", nil
+			}
+		})
+
+		it("caches the install paths in the layer", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			packagesLayer := result.Layers[0]
+			Expect(packagesLayer.Metadata["installer-paths-sha"]).To(Equal("installer-paths-checksum"))
+			Expect(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "installer-paths", "web", "app", "plugins", "akismet", "akismet.php")).To(BeARegularFile())
+
+			Expect(buffer.String()).To(ContainSubstring("Caching 1 installer-paths path(s)"))
+		})
+
+		context("when reusing a cached layer", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_RUN_COMPOSER_INSTALL", "false")).To(Succeed())
+
+				Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
+					[]byte(`[metadata]
+metadata-version = 1
+stack = ""
+composer-lock-sha = "default-checksum"
+installer-paths-sha = "installer-paths-checksum"
+`), os.ModePerm)).To(Succeed())
+
+				Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor"), os.ModePerm)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "installer-paths", "web", "app", "themes", "twentytwenty"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "installer-paths", "web", "app", "themes", "twentytwenty", "style.css"), []byte("cached"), os.ModePerm)).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_RUN_COMPOSER_INSTALL")).To(Succeed())
+			})
+
+			it("restores the install paths into the workspace", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).To(ContainSubstring("Reusing cached layer"))
+				Expect(buffer.String()).To(ContainSubstring("Restored 1 cached installer-paths file(s)"))
+
+				content, err := os.ReadFile(filepath.Join(workingDir, "web", "app", "themes", "twentytwenty", "style.css"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("cached"))
+			})
+		})
+	})
+
+	context("with tools installed by bamarni/composer-bin-plugin", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+	"packages-dev": [
+		{
+			"name": "bamarni/composer-bin-plugin"
+		}
+	]
+}`), os.ModePerm)).To(Succeed())
+
+			Expect(os.MkdirAll(filepath.Join(workingDir, "vendor-bin", "phpstan"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "vendor-bin", "phpstan", "composer.json"), []byte(`{}`), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "vendor-bin", "phpstan", "composer.lock"), []byte(`{}`), os.ModePerm)).To(Succeed())
+
+			composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "vendor"), os.ModePerm)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "vendor-bin", "phpstan", "vendor", "bin"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "vendor-bin", "phpstan", "vendor", "bin", "phpstan"), []byte("installed"), os.ModePerm)).To(Succeed())
+				composerInstallExecution = temp
+				return nil
+			}
+
+			calculator.SumCall.Stub = func(paths ...string) (string, error) {
+				if paths[0] == filepath.Join(layersDir, composer.ComposerPackagesLayerName, "plugin-output") {
+					return "plugin-output-checksum", nil
+				}
+				return "default-checksum", nil
+			}
+		})
+
+		it("caches the tools in the layer", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			packagesLayer := result.Layers[0]
+			Expect(packagesLayer.Metadata["plugin-output-sha"]).To(Equal("plugin-output-checksum"))
+			Expect(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "plugin-output", "vendor-bin", "phpstan", "vendor", "bin", "phpstan")).To(BeARegularFile())
+
+			Expect(buffer.String()).To(ContainSubstring("Caching 1 plugin output path(s)"))
+		})
+
+		it("includes the composer files of the tools in the composer.lock checksum", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("- including %s", filepath.Join(workingDir, "vendor-bin", "phpstan", "composer.lock"))))
+		})
+
+		context("when reusing a cached layer", func() {
+			var sumPaths [][]string
+
+			it.Before(func() {
+				Expect(os.Setenv("BP_RUN_COMPOSER_INSTALL", "false")).To(Succeed())
+
+				Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
+					[]byte(`[metadata]
+metadata-version = 1
+stack = ""
+composer-lock-sha = "default-checksum"
+plugin-output-sha = "plugin-output-checksum"
+`), os.ModePerm)).To(Succeed())
+
+				Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor"), os.ModePerm)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "plugin-output", "vendor-bin", "phpstan", "vendor", "bin"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "plugin-output", "vendor-bin", "phpstan", "vendor", "bin", "phpstan"), []byte("cached"), os.ModePerm)).To(Succeed())
+
+				sumPaths = nil
+				stub := calculator.SumCall.Stub
+				calculator.SumCall.Stub = func(paths ...string) (string, error) {
+					sumPaths = append(sumPaths, paths)
+					return stub(paths...)
+				}
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_RUN_COMPOSER_INSTALL")).To(Succeed())
+			})
+
+			it("restores the tools into the workspace", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(sumPaths).To(ContainElement([]string{
+					filepath.Join(workingDir, "composer.lock"),
+					filepath.Join(workingDir, "vendor-bin", "phpstan", "composer.json"),
+					filepath.Join(workingDir, "vendor-bin", "phpstan", "composer.lock"),
+				}))
+
+				Expect(buffer.String()).To(ContainSubstring("Reusing cached layer"))
+				Expect(buffer.String()).To(ContainSubstring("Restored 1 cached plugin output file(s)"))
+
+				content, err := os.ReadFile(filepath.Join(workingDir, "vendor-bin", "phpstan", "vendor", "bin", "phpstan"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("cached"))
+			})
+		})
+
+		context("with BP_COMPOSER_VENDOR_BIN_INSTALL set to true", func() {
+			var executions []pexec.Execution
+
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_VENDOR_BIN_INSTALL", "true")).To(Succeed())
+
+				executions = nil
+				composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+					executions = append(executions, temp)
+					Expect(os.MkdirAll(filepath.Join(workingDir, "vendor"), os.ModePerm)).To(Succeed())
+					if temp.Args[0] == "bin" {
+						Expect(os.MkdirAll(filepath.Join(workingDir, "vendor-bin", "phpstan", "vendor", "bin"), os.ModePerm)).To(Succeed())
+						Expect(os.WriteFile(filepath.Join(workingDir, "vendor-bin", "phpstan", "vendor", "bin", "phpstan"), []byte("installed"), os.ModePerm)).To(Succeed())
+					}
+					return nil
+				}
+
+				calculator.SumCall.Stub = func(paths ...string) (string, error) {
+					if paths[0] == filepath.Join(workingDir, "vendor-bin", "phpstan", "composer.json") {
+						return "vendor-bin-checksum", nil
+					}
+					return "default-checksum", nil
+				}
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_VENDOR_BIN_INSTALL")).To(Succeed())
+			})
+
+			it("runs 'composer bin all install' and caches the tools in their own layer", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+					Stack:         "some-stack",
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(executions).To(HaveLen(2))
+				Expect(executions[1].Args).To(Equal([]string{"bin", "all", "install", "--no-progress"}))
+				Expect(executions[1].Dir).To(Equal(workingDir))
+				Expect(executions[1].Env).To(ContainElements(
+					"COMPOSER_NO_INTERACTION=1",
+					fmt.Sprintf("COMPOSER_HOME=%s", filepath.Join(layersDir, composer.ComposerHomeLayerName)),
+				))
+
+				Expect(result.Layers).To(HaveLen(3))
+				vendorBinLayer := result.Layers[2]
+				Expect(vendorBinLayer.Name).To(Equal(composer.ComposerVendorBinLayerName))
+				Expect(vendorBinLayer.Cache).To(BeTrue())
+				Expect(vendorBinLayer.Launch).To(BeFalse())
+				Expect(vendorBinLayer.Build).To(BeFalse())
+				Expect(vendorBinLayer.Metadata).To(Equal(map[string]interface{}{
+					"vendor-bin-sha": "vendor-bin-checksum",
+					"stack":          "some-stack",
+				}))
+
+				content, err := os.ReadFile(filepath.Join(layersDir, composer.ComposerVendorBinLayerName, "vendor-bin", "phpstan", "vendor", "bin", "phpstan"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("installed"))
+
+				Expect(buffer.String()).To(ContainSubstring("Running 'composer bin all install --no-progress'"))
+			})
+
+			context("when the cached layer matches the namespaces", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerVendorBinLayerName)),
+						[]byte(`[metadata]
+vendor-bin-sha = "vendor-bin-checksum"
+stack = "some-stack"
+`), os.ModePerm)).To(Succeed())
+
+					Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerVendorBinLayerName, "vendor-bin", "phpstan", "vendor", "bin"), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(layersDir, composer.ComposerVendorBinLayerName, "vendor-bin", "phpstan", "vendor", "bin", "phpstan"), []byte("cached"), os.ModePerm)).To(Succeed())
+				})
+
+				it("restores the tools into the workspace without running composer", func() {
+					result, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+						Stack:         "some-stack",
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(executions).To(HaveLen(1))
+					Expect(executions[0].Args[0]).To(Equal("install"))
+
+					Expect(result.Layers).To(HaveLen(3))
+					Expect(result.Layers[2].Cache).To(BeTrue())
+
+					content, err := os.ReadFile(filepath.Join(workingDir, "vendor-bin", "phpstan", "vendor", "bin", "phpstan"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(content)).To(Equal("cached"))
+				})
+			})
+
+			context("when the stack has changed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerVendorBinLayerName)),
+						[]byte(`[metadata]
+vendor-bin-sha = "vendor-bin-checksum"
+stack = "other-stack"
+`), os.ModePerm)).To(Succeed())
+				})
+
+				it("installs the tools again", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+						Stack:         "some-stack",
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(executions).To(HaveLen(2))
+					Expect(executions[1].Args[0]).To(Equal("bin"))
+				})
+			})
+
+			context("when bamarni/composer-bin-plugin is not locked", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{}`), os.ModePerm)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError("BP_COMPOSER_VENDOR_BIN_INSTALL requires bamarni/composer-bin-plugin in composer.lock, found namespaces vendor-bin/phpstan"))
+				})
+			})
+		})
+	})
+
+	context("with a Magento 2 project", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+	"packages": [
+		{
+			"name": "magento/product-community-edition"
+		}
+	]
+}`), os.ModePerm)).To(Succeed())
+
+			Expect(os.MkdirAll(filepath.Join(workingDir, "app", "etc"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "app", "etc", "config.php"), []byte(`<?php return [];`), os.ModePerm)).To(Succeed())
+
+			composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "vendor"), os.ModePerm)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "generated", "code"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "generated", "code", "Interceptor.php"), []byte("compiled"), os.ModePerm)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "pub", "static"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "pub", "static", "deployed_version.txt"), []byte("1700000000"), os.ModePerm)).To(Succeed())
+				composerInstallExecution = temp
+				return nil
+			}
+
+			calculator.SumCall.Stub = func(paths ...string) (string, error) {
+				if paths[0] == filepath.Join(layersDir, composer.ComposerPackagesLayerName, "magento") {
+					return "magento-checksum", nil
+				}
+				return "default-checksum", nil
+			}
+		})
+
+		it("caches the generated code and static content in the layer", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			packagesLayer := result.Layers[0]
+			Expect(packagesLayer.Metadata["magento-sha"]).To(Equal("magento-checksum"))
+			Expect(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "magento", "generated", "code", "Interceptor.php")).To(BeARegularFile())
+			Expect(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "magento", "pub", "static", "deployed_version.txt")).To(BeARegularFile())
+
+			Expect(buffer.String()).To(ContainSubstring("Caching 2 Magento generated path(s)"))
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("- including %s", filepath.Join(workingDir, "app", "etc", "config.php"))))
+		})
+	})
+
+	context("with BP_COMPOSER_EXTRA_CACHE_PATHS", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_EXTRA_CACHE_PATHS", "bootstrap/cache")).To(Succeed())
+
+			composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "vendor"), os.ModePerm)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "bootstrap", "cache"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "bootstrap", "cache", "packages.php"), []byte("generated"), os.ModePerm)).To(Succeed())
+				composerInstallExecution = temp
+				return nil
+			}
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_EXTRA_CACHE_PATHS")).To(Succeed())
+		})
+
+		it("caches the extra paths in the layer", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers[0].Metadata["extra-cache-paths-sha"]).To(Equal("default-checksum"))
+			Expect(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "extra-cache-paths", "bootstrap", "cache", "packages.php")).To(BeARegularFile())
+			Expect(buffer.String()).To(ContainSubstring("Caching 1 extra cache path(s)"))
+		})
+
+		context("with a .composer-layerignore", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".composer-layerignore"), []byte("vendor/some/package/tests\n*.map\n"), os.ModePerm)).To(Succeed())
+
+				composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+					for _, dir := range []string{filepath.Join("vendor", "some", "package", "tests"), filepath.Join("bootstrap", "cache")} {
+						Expect(os.MkdirAll(filepath.Join(workingDir, dir), os.ModePerm)).To(Succeed())
+					}
+					Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "some", "package", "tests", "Test.php"), []byte("<?php"), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "some", "package", "Package.php"), []byte("<?php"), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, "bootstrap", "cache", "packages.php"), []byte("generated"), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, "bootstrap", "cache", "app.js.map"), []byte("generated"), os.ModePerm)).To(Succeed())
+					composerInstallExecution = temp
+					return nil
+				}
+			})
+
+			it("excludes the matching paths from the layer", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				layerDir := filepath.Join(layersDir, composer.ComposerPackagesLayerName)
+				Expect(filepath.Join(layerDir, "vendor", "some", "package", "Package.php")).To(BeARegularFile())
+				Expect(filepath.Join(layerDir, "vendor", "some", "package", "tests")).NotTo(BeAnExistingFile())
+				Expect(filepath.Join(layerDir, "extra-cache-paths", "bootstrap", "cache", "packages.php")).To(BeARegularFile())
+				Expect(filepath.Join(layerDir, "extra-cache-paths", "bootstrap", "cache", "app.js.map")).NotTo(BeAnExistingFile())
+
+				Expect(filepath.Join(workingDir, "vendor", "some", "package", "tests", "Test.php")).To(BeARegularFile())
+
+				Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("- including %s", filepath.Join(workingDir, ".composer-layerignore"))))
+				Expect(buffer.String()).To(ContainSubstring("Excluding paths matching .composer-layerignore"))
+				Expect(buffer.String()).To(ContainSubstring("Excluded 1 path(s)"))
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_PACKAGE_DENYLIST set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_PACKAGE_DENYLIST", "some/*@1.*")).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{"packages": [{"name": "some/package", "version": "1.2.3"}]}`), os.ModePerm)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_PACKAGE_DENYLIST")).To(Succeed())
+		})
+
+		it("fails the build before composer install", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).To(MatchError("found 1 package(s) violating the package policy: some/package 1.2.3 (denied by some/*@1.*)"))
+			Expect(composerInstallExecutable.ExecuteCall.CallCount).To(Equal(0))
+			Expect(buffer.String()).To(ContainSubstring("Checking packages against the package policy"))
+		})
+	})
+
+	context("with BP_COMPOSER_STABILITY_POLICY set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_STABILITY_POLICY", "fail")).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{"minimum-stability": "dev", "prefer-stable": true}`), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{"packages": [
+	{"name": "some/package", "version": "1.2.3"},
+	{"name": "some/unstable-package", "version": "dev-main"}
+]}`), os.ModePerm)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_STABILITY_POLICY")).To(Succeed())
+			Expect(os.Unsetenv("BP_COMPOSER_STABILITY_THRESHOLD")).To(Succeed())
+		})
+
+		it("fails the build before composer install", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).To(MatchError(`composer.json allows packages of stability "dev" (minimum-stability), which is below "stable", prefer-stable is set, composer.lock contains 1 package(s) below "stable": some/unstable-package (dev-main) (BP_COMPOSER_STABILITY_POLICY=fail)`))
+			Expect(composerInstallExecutable.ExecuteCall.CallCount).To(Equal(0))
+			Expect(buffer.String()).To(ContainSubstring(`Checking the minimum-stability "dev" against the threshold "stable"`))
+		})
+
+		context("when the minimum-stability satisfies the threshold", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_STABILITY_THRESHOLD", "dev")).To(Succeed())
+			})
+
+			it("builds", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buffer.String()).To(ContainSubstring("The minimum-stability satisfies the threshold"))
+			})
+		})
+
+		context("when it is set to warn", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_STABILITY_POLICY", "warn")).To(Succeed())
+				Expect(os.Setenv("BP_COMPOSER_STABILITY_THRESHOLD", "rc")).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{"minimum-stability": "beta"}`), os.ModePerm)).To(Succeed())
+			})
+
+			it("logs a warning", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buffer.String()).To(ContainSubstring(`WARNING: composer.json allows packages of stability "beta" (minimum-stability), which is below "RC", composer.lock contains 1 package(s) below "RC": some/unstable-package (dev-main)`))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the policy is unknown", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_STABILITY_POLICY", "deny")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError(`BP_COMPOSER_STABILITY_POLICY must be one of "off", "warn" or "fail", found "deny"`))
+				})
+			})
+
+			context("when the threshold is unknown", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_STABILITY_THRESHOLD", "unstable")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError(`failed to parse BP_COMPOSER_STABILITY_THRESHOLD: unknown stability "unstable", expected one of stable, RC, beta, alpha, dev`))
+				})
+			})
+
+			context("when the minimum-stability is unknown", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{"minimum-stability": "nightly"}`), os.ModePerm)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError(ContainSubstring(`invalid minimum-stability of`)))
+				})
+			})
+		})
+	})
+
+	context("with a [composer-install] table in project.toml", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "project.toml"), []byte(`
+[composer-install]
+install-global = "from-project-toml"
+deny-abandoned = true
+`), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{"packages": [{"name": "some/package", "abandoned": true}]}`), os.ModePerm)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_INSTALL_GLOBAL")).To(Succeed())
+			Expect(os.Unsetenv("BP_COMPOSER_DENY_ABANDONED")).To(Succeed())
+		})
+
+		it("applies the configuration", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).To(MatchError("found 1 abandoned package(s) while BP_COMPOSER_DENY_ABANDONED is set: some/package (no replacement suggested)"))
+			Expect(composerGlobalExecution.Args).To(Equal([]string{"global", "require", "--no-progress", "from-project-toml"}))
+		})
+
+		context("when the environment variables are set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_INSTALL_GLOBAL", "from-env")).To(Succeed())
+				Expect(os.Setenv("BP_COMPOSER_DENY_ABANDONED", "false")).To(Succeed())
+			})
+
+			it("gives precedence to the environment variables", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(composerGlobalExecution.Args).To(Equal([]string{"global", "require", "--no-progress", "from-env"}))
+				Expect(installOptions.DetermineCall.Receives.ProjectConfig).To(BeEmpty())
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_ROOT_VERSION set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_ROOT_VERSION", "1.2.3")).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, ".git-version"), []byte("4.5.6\n"), os.ModePerm)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_ROOT_VERSION")).To(Succeed())
+		})
+
+		it("sets COMPOSER_ROOT_VERSION for all composer executions", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, execution := range []pexec.Execution{composerConfigExecution, composerInstallExecution, composerCheckPlatformReqsExecExecution} {
+				Expect(execution.Env).To(ContainElement("COMPOSER_ROOT_VERSION=1.2.3"))
+			}
+			Expect(buffer.String()).To(ContainSubstring(`Setting COMPOSER_ROOT_VERSION to "1.2.3" from BP_COMPOSER_ROOT_VERSION`))
+		})
+	})
+
+	context("with a .git-version file", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, ".git-version"), []byte("4.5.6\n"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "project.toml"), []byte("[_]\nversion = \"7.8.9\"\n"), os.ModePerm)).To(Succeed())
+		})
+
+		it("sets COMPOSER_ROOT_VERSION from the file", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(composerInstallExecution.Env).To(ContainElement("COMPOSER_ROOT_VERSION=4.5.6"))
+			Expect(buffer.String()).To(ContainSubstring(`Setting COMPOSER_ROOT_VERSION to "4.5.6" from .git-version`))
+		})
+	})
+
+	context("with a version in project.toml", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "project.toml"), []byte("[_]\nversion = \"7.8.9\"\n"), os.ModePerm)).To(Succeed())
+		})
+
+		it("sets COMPOSER_ROOT_VERSION from project.toml", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(composerInstallExecution.Env).To(ContainElement("COMPOSER_ROOT_VERSION=7.8.9"))
+		})
+
+		context("when COMPOSER_ROOT_VERSION is set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("COMPOSER_ROOT_VERSION", "1.0.0")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("COMPOSER_ROOT_VERSION")).To(Succeed())
+			})
+
+			it("gives precedence to COMPOSER_ROOT_VERSION", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerInstallExecution.Env).To(ContainElement("COMPOSER_ROOT_VERSION=1.0.0"))
+				Expect(composerInstallExecution.Env).NotTo(ContainElement("COMPOSER_ROOT_VERSION=7.8.9"))
+				Expect(buffer.String()).To(ContainSubstring(`Using COMPOSER_ROOT_VERSION "1.0.0" from the environment`))
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_FALLBACK_VERSION set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_FALLBACK_VERSION", "2.6.5")).To(Succeed())
+
+			composerDownloader.PublishedChecksumCall.Returns.String = "245f550617b4e9ffe51bf2c938fa2271949744b4e6615c12a5252855ee819dca"
+			composerDownloader.DownloadCall.Stub = func(version, destination string) error {
+				return os.WriteFile(destination, []byte("composer-binary"), 0644)
+			}
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_FALLBACK_VERSION")).To(Succeed())
+			Expect(os.Unsetenv("BP_COMPOSER_SHA256")).To(Succeed())
+		})
+
+		it("provisions composer into a cached layer", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(composerDownloader.PublishedChecksumCall.Receives.Version).To(Equal("2.6.5"))
+			Expect(composerDownloader.DownloadCall.Receives.Version).To(Equal("2.6.5"))
+
+			composerBin := filepath.Join(layersDir, composer.ComposerFallbackLayerName, "bin")
+			info, err := os.Stat(filepath.Join(composerBin, "composer"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0755)))
+
+			Expect(result.Layers).To(HaveLen(3))
+			layer := result.Layers[2]
+			Expect(layer.Name).To(Equal(composer.ComposerFallbackLayerName))
+			Expect(layer.Build).To(BeFalse())
+			Expect(layer.Launch).To(BeFalse())
+			Expect(layer.Cache).To(BeTrue())
+			Expect(layer.Metadata).To(Equal(map[string]interface{}{
+				"version": "2.6.5",
+				"sha256":  "245f550617b4e9ffe51bf2c938fa2271949744b4e6615c12a5252855ee819dca",
+			}))
+
+			Expect(composerInstallExecution.Env).To(ContainElement(fmt.Sprintf("PATH=%s%cfake-path-from-tests", composerBin, os.PathListSeparator)))
+			Expect(buffer.String()).To(ContainSubstring("No composer found on the path, provisioning composer 2.6.5"))
+		})
+
+		context("when the layer has been cached", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerFallbackLayerName, "bin"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(layersDir, composer.ComposerFallbackLayerName, "bin", "composer"), []byte("composer-binary"), 0755)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerFallbackLayerName)), []byte(`[metadata]
+version = "2.6.5"
+sha256 = "245f550617b4e9ffe51bf2c938fa2271949744b4e6615c12a5252855ee819dca"
+`), os.ModePerm)).To(Succeed())
+			})
+
+			it("reuses the layer", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(composerDownloader.DownloadCall.CallCount).To(Equal(0))
+				Expect(buffer.String()).To(ContainSubstring("Reusing cached composer 2.6.5"))
+			})
+		})
+
+		context("when composer is found on the path", func() {
+			var pathDir string
+
+			it.Before(func() {
+				var err error
+				pathDir, err = os.MkdirTemp("", "path")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(os.WriteFile(filepath.Join(pathDir, "composer"), []byte("composer-binary"), 0755)).To(Succeed())
+
+				dependencies.Path = pathDir
+				build = composer.Build(dependencies)
+			})
+
+			it.After(func() {
+				Expect(os.RemoveAll(pathDir)).To(Succeed())
+			})
+
+			it("does not provision composer", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Layers).To(HaveLen(2))
+				Expect(composerDownloader.DownloadCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when the checksum does not match", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_SHA256", "some-other-checksum")).To(Succeed())
+			})
+
+			it("fails the build", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError("checksum mismatch for composer 2.6.5: expected some-other-checksum, found 245f550617b4e9ffe51bf2c938fa2271949744b4e6615c12a5252855ee819dca"))
+				Expect(composerDownloader.PublishedChecksumCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when the download fails", func() {
+			it.Before(func() {
+				composerDownloader.DownloadCall.Stub = nil
+				composerDownloader.DownloadCall.Returns.Error = errors.New("some-download-error")
+			})
+
+			it("fails the build", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError("some-download-error"))
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_SANDBOX set to true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_SANDBOX", "true")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_SANDBOX")).To(Succeed())
+		})
+
+		it("runs composer install with a separate HOME and TMPDIR", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			sandboxDir := filepath.Join(layersDir, composer.ComposerSandboxLayerName)
+			Expect(composerInstallExecution.Env).To(ContainElement(fmt.Sprintf("HOME=%s", filepath.Join(sandboxDir, "home"))))
+			Expect(composerInstallExecution.Env).To(ContainElement(fmt.Sprintf("TMPDIR=%s", filepath.Join(sandboxDir, "tmp"))))
+			Expect(composerInstallExecution.Env).NotTo(ContainElement(fmt.Sprintf("TMPDIR=%s", filepath.Join(layersDir, composer.ComposerTmpLayerName, "tmp"))))
+			Expect(filepath.Join(sandboxDir, "home")).To(BeADirectory())
+			Expect(filepath.Join(sandboxDir, "tmp")).To(BeADirectory())
+			Expect(composerConfigExecution.Env).NotTo(ContainElement(fmt.Sprintf("HOME=%s", filepath.Join(sandboxDir, "home"))))
+		})
+
+		context("with BP_COMPOSER_SANDBOX_WRITABLE_PATHS", func() {
+			var modesDuringInstall map[string]os.FileMode
+
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_SANDBOX_WRITABLE_PATHS", "public/bundles")).To(Succeed())
+
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte("{}"), 0644)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "src"), 0755)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "src", "App.php"), []byte("<?php"), 0644)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "public", "bundles"), 0755)).To(Succeed())
+
+				modesDuringInstall = map[string]os.FileMode{}
+				composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+					for _, path := range []string{"composer.json", "src", filepath.Join("src", "App.php"), "public", filepath.Join("public", "bundles"), "."} {
+						info, err := os.Stat(filepath.Join(workingDir, path))
+						Expect(err).NotTo(HaveOccurred())
+						modesDuringInstall[path] = info.Mode().Perm()
+					}
+
+					Expect(os.MkdirAll(filepath.Join(workingDir, "vendor"), os.ModePerm)).To(Succeed())
+					composerInstallExecution = temp
+					return nil
+				}
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_SANDBOX_WRITABLE_PATHS")).To(Succeed())
+			})
+
+			it("makes the rest of the workspace read-only during composer install", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(modesDuringInstall).To(Equal(map[string]os.FileMode{
+					"composer.json":                    0444,
+					"src":                              0555,
+					filepath.Join("src", "App.php"):    0444,
+					"public":                           0755,
+					filepath.Join("public", "bundles"): 0755,
+					".":                                0700,
+				}))
+
+				info, err := os.Stat(filepath.Join(workingDir, "src", "App.php"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.Mode().Perm()).To(Equal(os.FileMode(0644)))
+
+				info, err = os.Stat(filepath.Join(workingDir, "src"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.Mode().Perm()).To(Equal(os.FileMode(0755)))
+
+				Expect(buffer.String()).To(ContainSubstring("Workspace is read-only, except for:"))
+			})
+
+			it("warns if composer install runs as root, which ignores the file modes", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				warning := "WARNING: 'composer install' runs as root, which can write to read-only files, set BP_COMPOSER_RUN_AS to run it as another user"
+				if os.Getuid() == 0 {
+					Expect(buffer.String()).To(ContainSubstring(warning))
+				} else {
+					Expect(buffer.String()).NotTo(ContainSubstring(warning))
+				}
+			})
+
+			context("when a writable path is outside of the project root", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_SANDBOX_WRITABLE_PATHS", "../outside")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError(`BP_COMPOSER_SANDBOX_WRITABLE_PATHS must only contain relative paths underneath the project root, found "../outside"`))
+				})
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_AUTOLOAD_REFRESH set to true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_AUTOLOAD_REFRESH", "true")).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{"autoload": {"psr-4": {"App\\": "src/"}}}`), os.ModePerm)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(workingDir, "src"), os.ModePerm)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_AUTOLOAD_REFRESH")).To(Succeed())
+		})
+
+		it("configures the exec.d executable on the composer-packages layer", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				CNBPath:       "some-cnb-path",
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			packagesLayer := result.Layers[0]
+			Expect(packagesLayer.ExecD).To(Equal([]string{
+				filepath.Join("some-cnb-path", "bin", "prepare-composer-home"),
+				filepath.Join("some-cnb-path", "bin", "refresh-autoloader"),
+			}))
+			Expect(packagesLayer.LaunchEnv).To(Equal(packit.Environment{
+				"BPI_COMPOSER_APP_DIR.default":                workingDir,
+				"BPI_COMPOSER_JSON_PATH.default":              filepath.Join(workingDir, "composer.json"),
+				"BPI_COMPOSER_VENDOR_DIR.default":             filepath.Join(workingDir, "vendor"),
+				"BPI_COMPOSER_AUTOLOAD_CHECKSUM_PATH.default": filepath.Join(packagesLayer.Path, "autoload-checksum"),
+				"COMPOSER_VENDOR_DIR.default":                 filepath.Join(workingDir, "vendor"),
+				"COMPOSER_NO_DEV.default":                     "0",
+				"COMPOSER_HOME.default":                       "/tmp/composer-home",
+			}))
+
+			Expect(calculator.SumCall.Receives.Paths).To(Equal([]string{filepath.Join(workingDir, "src")}))
+
+			contents, err := os.ReadFile(filepath.Join(packagesLayer.Path, "autoload-checksum"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal("default-checksum"))
+		})
+
+		context("when the composer-packages layer is not a launch layer", func() {
+			it.Before(func() {
+				buildpackPlan.Entries[0].Metadata["launch"] = false
+				buildpackPlan.Entries[0].Metadata["build"] = true
+			})
+
+			it("does not configure the exec.d executable", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Layers[0].ExecD).To(BeEmpty())
+				Expect(buffer.String()).To(ContainSubstring("Skipping autoloader refresh at launch"))
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_DRY_RUN_CHECK set to true", func() {
+		var installArgs [][]string
+
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_DRY_RUN_CHECK", "true")).To(Succeed())
+
+			installArgs = nil
+			stub := composerInstallExecutable.ExecuteCall.Stub
+			composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				installArgs = append(installArgs, temp.Args)
+				return stub(temp)
+			}
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_DRY_RUN_CHECK")).To(Succeed())
+		})
+
+		it("runs composer install --dry-run before composer install", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(installArgs).To(Equal([][]string{
+				{"install", "options", "from", "fake", "--dry-run"},
+				{"install", "options", "from", "fake"},
+			}))
+			Expect(buffer.String()).To(ContainSubstring("Running 'composer install options from fake --dry-run' before resetting the layer"))
+		})
+
+		context("when the cached layer is reused", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
+					[]byte(`[metadata]
+metadata-version = 1
+stack = ""
+composer-lock-sha = "default-checksum"
+`), os.ModePerm)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor"), os.ModePerm)).To(Succeed())
+			})
+
+			it("does not run composer install --dry-run", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(installArgs).To(HaveLen(1))
+				Expect(installArgs[0]).NotTo(ContainElement("--dry-run"))
+			})
+		})
+
+		context("when composer install --dry-run fails", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
+					[]byte(`[metadata]
+metadata-version = 1
+stack = ""
+composer-lock-sha = "sha-from-previous-composer-lock"
+`), os.ModePerm)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor", "autoload.php"), []byte("<?php"), os.ModePerm)).To(Succeed())
+
+				composerInstallExecutable.ExecuteCall.Stub = func(pexec.Execution) error {
+					return errors.New("some-resolution-error")
+				}
+			})
+
+			it("keeps the cached layer", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError("'composer install --dry-run' failed, the cached layer has been kept: some-resolution-error"))
+
+				Expect(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor", "autoload.php")).To(BeARegularFile())
+				Expect(buffer.String()).NotTo(ContainSubstring("Building new layer"))
+			})
+		})
+	})
+
+	context("when the cached layer is stale", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
+				[]byte(`[metadata]
+metadata-version = 1
+stack = ""
+composer-lock-sha = "sha-from-previous-composer-lock"
+`), os.ModePerm)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor", "previous-package-name"), os.ModePerm)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName, ".staging", "vendor", "interrupted-package-name"), os.ModePerm)).To(Succeed())
+		})
+
+		it("replaces the contents of the layer with the staged contents", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			layerPath := filepath.Join(layersDir, composer.ComposerPackagesLayerName)
+			Expect(filepath.Join(layerPath, "vendor", "local-package-name")).To(BeADirectory())
+			Expect(filepath.Join(layerPath, "vendor", "previous-package-name")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(layerPath, ".staging")).NotTo(BeAnExistingFile())
+			Expect(layerPath + ".staging").NotTo(BeAnExistingFile())
+
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("Promoting the staged contents to %s", layerPath)))
+		})
+
+		context("when composer install fails", func() {
+			it.Before(func() {
+				composerInstallExecutable.ExecuteCall.Stub = func(pexec.Execution) error {
+					Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "local-package-name"), os.ModePerm)).To(Succeed())
+					return errors.New("some-install-error")
+				}
+			})
+
+			it("keeps the previous contents of the layer", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError("some-install-error"))
+
+				layerPath := filepath.Join(layersDir, composer.ComposerPackagesLayerName)
+				Expect(filepath.Join(layerPath, "vendor", "previous-package-name")).To(BeADirectory())
+				Expect(filepath.Join(layerPath, "vendor", "local-package-name")).NotTo(BeAnExistingFile())
+				Expect(filepath.Join(layerPath, ".staging")).NotTo(BeAnExistingFile())
+
+				Expect(buffer.String()).To(ContainSubstring("Discarding the staged contents, the previous contents of the layer have been kept"))
+			})
+		})
+	})
+
+	context("with a Logger", func() {
+		var (
+			processes []string
+			actions   []string
+			titles    []string
+			breaks    int
+
+			logger *fakes.Logger
+		)
+
+		it.Before(func() {
+			processes, actions, titles, breaks = nil, nil, nil, 0
+
+			logger = &fakes.Logger{}
+			logger.TitleCall.Stub = func(format string, v ...interface{}) {
+				titles = append(titles, fmt.Sprintf(format, v...))
+			}
+			logger.ProcessCall.Stub = func(format string, v ...interface{}) {
+				processes = append(processes, fmt.Sprintf(format, v...))
+			}
+			logger.ActionCall.Stub = func(format string, v ...interface{}) {
+				actions = append(actions, fmt.Sprintf(format, v...))
+			}
+			logger.BreakCall.Stub = func() {
+				breaks++
+			}
+
+			dependencies.Logger = logger
+			build = composer.Build(dependencies)
+		})
+
+		it("logs each line to the Logger", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(titles).To(Equal([]string{"Some Buildpack some-version"}))
+			Expect(processes).To(ContainElement("Running 'composer install options from fake'"))
+			Expect(actions).To(ContainElements("stdout from composer config", "stderr from composer config"))
+			Expect(breaks).To(BeNumerically(">", 0))
+			Expect(buffer.String()).To(BeEmpty())
+		})
+
+		it("passes the arguments of each message to the Logger", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(logger.TitleCall.Receives.Format).To(Equal("%s %s"))
+			Expect(logger.TitleCall.Receives.V).To(Equal([]interface{}{"Some Buildpack", "some-version"}))
+		})
+
+		context("when the output of a command does not end with a newline", func() {
+			it.Before(func() {
+				composerConfigExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+					_, err := fmt.Fprint(temp.Stdout, "first line\nlast line")
+					return err
+				}
+			})
+
+			it("logs the last line as well", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(actions).To(ContainElements("first line", "last line"))
+			})
+		})
+
+		context("with a hook", func() {
+			it.Before(func() {
+				hook := &fakes.Hook{}
+				hook.BeforeInstallCall.Stub = func(context composer.HookContext) error {
+					context.Logger.Process("Running some-hook")
+					context.Logger.Debug("Some debug output")
+					return nil
+				}
+
+				dependencies.Hooks = []composer.Hook{hook}
+				build = composer.Build(dependencies)
+			})
+
+			it("passes the Logger to the hook, without the debug output", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(processes).To(ContainElement("Running some-hook"))
+				Expect(logger.DebugCall.CallCount).To(Equal(0))
+			})
+		})
+	})
+
+	context("when BP_LOG_LEVEL is DEBUG", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_LOG_LEVEL", "DEBUG")).To(Succeed())
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+    "packages": [
+        {"name": "vendor/a", "dist": {"url": "https://repo.example.com/dists/vendor/a/1.0.0.zip"}}
+    ]
+}`), os.ModePerm)).To(Succeed())
+
+			composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "local-package-name"), os.ModePerm)).To(Succeed())
+
+				archiveDir := filepath.Join(layersDir, composer.ComposerHomeLayerName, "cache", "files", "vendor", "a")
+				Expect(os.MkdirAll(archiveDir, os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(archiveDir, "archive.zip"), make([]byte, 2048), os.ModePerm)).To(Succeed())
+
+				_, err := fmt.Fprint(temp.Stdout, "Downloading https://repo.example.com/dists/vendor/a/1.0.0.zip\n")
+				Expect(err).NotTo(HaveOccurred())
+				_, err = fmt.Fprint(temp.Stderr, "[200] https://repo.example.com/dists/vendor/a/1.0.0.zip\n")
+				Expect(err).NotTo(HaveOccurred())
+
+				composerInstallExecution = temp
+				return nil
+			}
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_LOG_LEVEL")).To(Succeed())
+		})
+
+		it("logs the package downloads and records them in the layer metadata", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(composerInstallExecution.Env).To(ContainElement("SHELL_VERBOSITY=3"))
+
+			packagesLayer := result.Layers[0]
+			Expect(packagesLayer.Metadata["downloaded-packages"]).To(Equal(1))
+			Expect(packagesLayer.Metadata["downloaded-bytes"]).To(Equal(int64(2048)))
+			Expect(packagesLayer.Metadata).To(HaveKey("download-time"))
+
+			Expect(buffer.String()).To(ContainSubstring("Downloaded 1 package(s), 2.0 KiB in "))
+			Expect(buffer.String()).To(ContainSubstring("Slowest downloads:"))
+			Expect(buffer.String()).To(MatchRegexp(`- vendor/a: \S+, 2\.0 KiB`))
+		})
+	})
+
+	context("with BP_COMPOSER_REPRODUCIBLE set to true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_REPRODUCIBLE", "true")).To(Succeed())
+			Expect(os.Setenv("SOURCE_DATE_EPOCH", "1580702706")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_REPRODUCIBLE")).To(Succeed())
+			Expect(os.Unsetenv("SOURCE_DATE_EPOCH")).To(Succeed())
+		})
+
+		it("normalizes the layer and the vendor directory", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, path := range []string{
+				filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor", "local-package-name"),
+				filepath.Join(workingDir, "vendor", "local-package-name"),
+			} {
+				info, err := os.Stat(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.ModTime().Unix()).To(Equal(int64(1580702706)), path)
+				Expect(info.Mode().Perm()).To(Equal(os.FileMode(0755)), path)
+			}
+
+			Expect(buffer.String()).To(ContainSubstring("Normalizing files for reproducible layers"))
+			Expect(buffer.String()).To(ContainSubstring("Modification time: 2020-02-03T04:05:06Z"))
+		})
+
+		context("when SOURCE_DATE_EPOCH is invalid", func() {
+			it.Before(func() {
+				Expect(os.Setenv("SOURCE_DATE_EPOCH", "yesterday")).To(Succeed())
+			})
+
+			it("returns an error before composer install", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError(`SOURCE_DATE_EPOCH must be a number of seconds, found "yesterday"`))
+				Expect(composerInstallExecutable.ExecuteCall.CallCount).To(Equal(0))
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_CACHE_HOME set to true", func() {
+		var composerHomeDir string
+
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_CACHE_HOME", "true")).To(Succeed())
+
+			composerHomeDir = filepath.Join(layersDir, composer.ComposerHomeLayerName)
+
+			stub := composerInstallExecutable.ExecuteCall.Stub
+			composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				Expect(os.WriteFile(filepath.Join(composerHomeDir, "auth.json"), []byte(`{"http-basic": {"repo.example.com": {"username": "some-user", "password": "some-password"}}}`), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(composerHomeDir, "config.json"), []byte(`{"config": {"process-timeout": 900, "github-oauth": {"github.com": "some-token"}}}`), 0600)).To(Succeed())
+				return stub(temp)
+			}
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_CACHE_HOME")).To(Succeed())
+		})
+
+		it("caches COMPOSER_HOME without the credentials", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			composerHomeLayer := result.Layers[1]
+			Expect(composerHomeLayer.Name).To(Equal(composer.ComposerHomeLayerName))
+			Expect(composerHomeLayer.Launch).To(BeFalse())
+			Expect(composerHomeLayer.Cache).To(BeTrue())
+
+			Expect(filepath.Join(composerHomeDir, "auth.json")).NotTo(BeAnExistingFile())
+
+			content, err := os.ReadFile(filepath.Join(composerHomeDir, "config.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(MatchJSON(`{"config": {"process-timeout": 900}}`))
+		})
+
+		context("when it is not a boolean", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_CACHE_HOME", "sometimes")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError(ContainSubstring(`error when parsing env var "BP_COMPOSER_CACHE_HOME"`)))
+			})
+		})
+	})
+
+	context("when composer.json has VCS repositories", func() {
+		var (
+			composerHomeDir string
+			vcsCacheDir     string
+		)
+
+		it.Before(func() {
+			composerHomeDir = filepath.Join(layersDir, composer.ComposerHomeLayerName)
+			vcsCacheDir = filepath.Join(layersDir, composer.ComposerVCSCacheLayerName)
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{
+	"repositories": [
+		{"packagist.org": false},
+		{"type": "composer", "url": "https://satis.example.com"},
+		{"type": "vcs", "url": "https://github.com/acme/billing"}
+	]
+}`), os.ModePerm)).To(Succeed())
+
+			Expect(os.MkdirAll(filepath.Join(vcsCacheDir, "https---github.com-acme-billing"), os.ModePerm)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_CACHE_VCS")).To(Succeed())
+		})
+
+		it("caches them in a separate layer", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(3))
+			vcsCacheLayer := result.Layers[2]
+			Expect(vcsCacheLayer.Name).To(Equal(composer.ComposerVCSCacheLayerName))
+			Expect(vcsCacheLayer.Path).To(Equal(vcsCacheDir))
+			Expect(vcsCacheLayer.Launch).To(BeFalse())
+			Expect(vcsCacheLayer.Build).To(BeFalse())
+			Expect(vcsCacheLayer.Cache).To(BeTrue())
+
+			content, err := os.ReadFile(filepath.Join(composerHomeDir, "config.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(MatchJSON(fmt.Sprintf(`{"config": {"cache-vcs-dir": %q}}`, vcsCacheDir)))
+			Expect(result.Layers[1].Metadata).To(HaveKeyWithValue("vcs-cache-dir", vcsCacheDir))
+
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("Caching the VCS repositories in %s", vcsCacheDir)))
+			Expect(buffer.String()).To(ContainSubstring("Restored 1 cached repositories"))
+		})
+
+		context("with BP_COMPOSER_CACHE_VCS set to false", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_CACHE_VCS", "false")).To(Succeed())
+			})
+
+			it("does not cache them", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers).To(HaveLen(2))
+				Expect(filepath.Join(composerHomeDir, "config.json")).NotTo(BeAnExistingFile())
+			})
+
+			context("when a previous build cached them with COMPOSER_HOME", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_CACHE_HOME", "true")).To(Succeed())
+
+					Expect(os.MkdirAll(composerHomeDir, os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(composerHomeDir, "config.json"), []byte(fmt.Sprintf(`{"config": {"cache-vcs-dir": %q, "process-timeout": 900}}`, vcsCacheDir)), 0600)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerHomeLayerName)), []byte(fmt.Sprintf(`[metadata]
+vcs-cache-dir = %q
+`, vcsCacheDir)), os.ModePerm)).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_COMPOSER_CACHE_HOME")).To(Succeed())
+				})
+
+				it("removes the VCS cache from the configuration", func() {
+					result, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					content, err := os.ReadFile(filepath.Join(composerHomeDir, "config.json"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(content)).To(MatchJSON(`{"config": {"process-timeout": 900}}`))
+					Expect(result.Layers[1].Metadata).NotTo(HaveKey("vcs-cache-dir"))
+				})
+			})
+		})
+
+		context("when BP_COMPOSER_CONFIG sets cache-vcs-dir", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_CONFIG", "cache-vcs-dir=/tmp/vcs")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_CONFIG")).To(Succeed())
+			})
+
+			it("does not cache them", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers).To(HaveLen(2))
+				Expect(buffer.String()).To(ContainSubstring("Not caching the VCS repositories, as BP_COMPOSER_CONFIG sets cache-vcs-dir"))
+			})
+		})
+
+		context("with an invalid BP_COMPOSER_CACHE_VCS", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_CACHE_VCS", "sometimes")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError(ContainSubstring(`error when parsing env var "BP_COMPOSER_CACHE_VCS"`)))
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_CACHE_VCS set to true", func() {
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_CACHE_VCS")).To(Succeed())
+		})
+
+		it("caches the VCS repositories without any being declared", func() {
+			Expect(os.Setenv("BP_COMPOSER_CACHE_VCS", "true")).To(Succeed())
+
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(3))
+			Expect(result.Layers[2].Name).To(Equal(composer.ComposerVCSCacheLayerName))
+		})
+	})
+
+	context("with BP_COMPOSER_PACKAGE_STORE set to true", func() {
+		var storeDir string
+
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_PACKAGE_STORE", "true")).To(Succeed())
+			storeDir = filepath.Join(layersDir, composer.ComposerPackageStoreLayerName)
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+	"packages": [
+		{"name": "some/package", "version": "1.0.0", "dist": {"type": "zip", "reference": "abc123"}},
+		{"name": "some/new-package", "version": "1.0.0", "dist": {"type": "zip", "reference": "def456"}}
+	]
+}`), os.ModePerm)).To(Succeed())
+
+			composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				composerInstallExecution = temp
+				for _, name := range []string{"some/package", "some/new-package"} {
+					Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", name), os.ModePerm)).To(Succeed())
+				}
+				Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "composer"), os.ModePerm)).To(Succeed())
+				return os.WriteFile(filepath.Join(workingDir, "vendor", "composer", "installed.json"), []byte(`{
+	"packages": [
+		{"name": "some/package", "version": "1.0.0", "dist": {"type": "zip", "reference": "abc123"}, "installation-source": "dist", "install-path": "../some/package"},
+		{"name": "some/new-package", "version": "1.0.0", "dist": {"type": "zip", "reference": "def456"}, "installation-source": "dist", "install-path": "../some/new-package"}
+	]
+}`), os.ModePerm)
+			}
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_PACKAGE_STORE")).To(Succeed())
+		})
+
+		it("stores the installed packages in a cached layer", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(3))
+			storeLayer := result.Layers[2]
+			Expect(storeLayer.Name).To(Equal(composer.ComposerPackageStoreLayerName))
+			Expect(storeLayer.Cache).To(BeTrue())
+			Expect(storeLayer.Launch).To(BeFalse())
+			Expect(storeLayer.Metadata).To(HaveKeyWithValue("target", composer.LookupBuildTarget().String()))
+
+			Expect(os.ReadDir(storeDir)).To(HaveLen(2))
+			Expect(buffer.String()).To(ContainSubstring("Assembled 0 of 2 locked packages from the package store"))
+			Expect(buffer.String()).To(ContainSubstring("Updated the package store: 2 stored, 2 added, 0 removed"))
+		})
+
+		context("when composer.lock changed since the packages were stored", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "some", "package"), os.ModePerm)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "composer"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "composer", "installed.json"), []byte(`{
+	"packages": [
+		{"name": "some/package", "version": "1.0.0", "dist": {"type": "zip", "reference": "abc123"}, "installation-source": "dist", "install-path": "../some/package"}
+	]
+}`), os.ModePerm)).To(Succeed())
+				Expect(os.MkdirAll(storeDir, os.ModePerm)).To(Succeed())
+				Expect(composer.NewPackageStore(storeDir).Update(composer.NewEmitterLogger(scribe.NewEmitter(bytes.NewBuffer(nil))), filepath.Join(workingDir, "vendor"))).To(Succeed())
+				Expect(os.RemoveAll(filepath.Join(workingDir, "vendor"))).To(Succeed())
+
+				Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackageStoreLayerName)),
+					[]byte(fmt.Sprintf("[metadata]\nstack = \"\"\ntarget = %q\n", composer.LookupBuildTarget().String())), os.ModePerm)).To(Succeed())
+
+				composerInstallStub := composerInstallExecutable.ExecuteCall.Stub
+				composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+					Expect(filepath.Join(workingDir, "vendor", "some", "package")).To(BeADirectory())
+					Expect(filepath.Join(workingDir, "vendor", "some", "new-package")).NotTo(BeADirectory())
+					return composerInstallStub(temp)
+				}
+			})
+
+			it("assembles the unchanged packages before composer install", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).To(ContainSubstring("Restored 1 stored packages"))
+				Expect(buffer.String()).To(ContainSubstring("Assembled 1 of 2 locked packages from the package store"))
+				Expect(buffer.String()).To(ContainSubstring("Updated the package store: 2 stored, 1 added, 0 removed"))
+			})
+		})
+
+		context("when the packages were stored on another stack", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(storeDir, "stored-package"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackageStoreLayerName)),
+					[]byte("[metadata]\nstack = \"another-stack\"\n"), os.ModePerm)).To(Succeed())
+			})
+
+			it("removes the stored packages", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(filepath.Join(storeDir, "stored-package")).NotTo(BeADirectory())
+				Expect(buffer.String()).To(ContainSubstring("Restored 0 stored packages"))
+			})
+		})
+	})
+
+	context("when COMPOSER_HOME is not cached", func() {
+		it("keeps the credentials for the rest of the build", func() {
+			Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerHomeLayerName), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(layersDir, composer.ComposerHomeLayerName, "auth.json"), []byte(`{}`), 0600)).To(Succeed())
+
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers[1].Cache).To(BeFalse())
+			Expect(filepath.Join(layersDir, composer.ComposerHomeLayerName, "auth.json")).To(BeARegularFile())
+		})
+	})
+
+	context("with BP_COMPOSER_CONFIG set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_CONFIG", `process-timeout=900 preferred-install.foo/*=source "github-protocols=https ssh"`)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_CONFIG")).To(Succeed())
+		})
+
+		it("applies the settings in COMPOSER_HOME before composer install", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(composerConfigExecutions).To(HaveLen(4))
+
+			var args [][]string
+			for _, execution := range composerConfigExecutions[:3] {
+				args = append(args, execution.Args)
+				Expect(execution.Dir).To(Equal(filepath.Join(layersDir, composer.ComposerHomeLayerName)))
+				Expect(execution.Env).To(ContainElement(fmt.Sprintf("COMPOSER_HOME=%s", filepath.Join(layersDir, composer.ComposerHomeLayerName))))
+			}
+			Expect(args).To(Equal([][]string{
+				{"config", "--global", "github-protocols", "https ssh"},
+				{"config", "--global", "preferred-install.foo/*", "source"},
+				{"config", "--global", "process-timeout", "900"},
+			}))
+			Expect(composerConfigExecutions[3].Args).To(Equal([]string{"config", "autoloader-suffix", composer.ComposerAutoloaderSuffix}))
+
+			Expect(result.Layers[1].Metadata).To(HaveKeyWithValue("composer-config-keys", []string{"github-protocols", "preferred-install.foo/*", "process-timeout"}))
+			Expect(result.Layers[0].Metadata).To(HaveKey("composer-config-sha"))
+			Expect(buffer.String()).To(ContainSubstring("Applying BP_COMPOSER_CONFIG"))
+			Expect(buffer.String()).To(ContainSubstring("Running 'composer config --global process-timeout 900'"))
+		})
+
+		context("with a credential setting", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_CONFIG", "github-oauth.github.com=some-token process-timeout=900")).To(Succeed())
+				Expect(os.Setenv("BP_LOG_LEVEL", "DEBUG")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_LOG_LEVEL")).To(Succeed())
+			})
+
+			it("applies the credentials, but does not log them", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerConfigExecutions[0].Args).To(Equal([]string{"config", "--global", "github-oauth.github.com", "some-token"}))
+				Expect(buffer.String()).To(ContainSubstring("Running 'composer config --global github-oauth.github.com [REDACTED]'"))
+				Expect(buffer.String()).To(ContainSubstring("Running 'composer config --global process-timeout 900'"))
+				Expect(buffer.String()).NotTo(ContainSubstring("some-token"))
+
+				commandLog, err := os.ReadFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, composer.CommandLogFileName))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(commandLog)).To(ContainSubstring("$ composer config --global github-oauth.github.com [REDACTED]"))
+				Expect(string(commandLog)).NotTo(ContainSubstring("some-token"))
+			})
+		})
+
+		context("when the previous build applied other settings", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerHomeLayerName)),
+					[]byte(`[metadata]
+composer-config-keys = ["process-timeout", "secure-http"]
+`), os.ModePerm)).To(Succeed())
+			})
+
+			it("removes them", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerConfigExecutions[0].Args).To(Equal([]string{"config", "--global", "--unset", "secure-http"}))
+				Expect(composerConfigExecutions).To(HaveLen(5))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when a setting is not a key=value pair", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_CONFIG", "process-timeout")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError(`BP_COMPOSER_CONFIG must only contain key=value pairs, found "process-timeout"`))
+				})
+			})
+
+			context("when composer config fails", func() {
+				it.Before(func() {
+					composerConfigExecutable.ExecuteCall.Stub = func(pexec.Execution) error {
+						return errors.New("some-config-error")
+					}
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError("failed to apply BP_COMPOSER_CONFIG: some-config-error"))
+				})
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_MAX_PARALLEL_HTTP set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_MAX_PARALLEL_HTTP", "2")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_MAX_PARALLEL_HTTP")).To(Succeed())
+		})
+
+		it("sets COMPOSER_MAX_PARALLEL_HTTP on all executions", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(composerConfigExecution.Env).To(ContainElement("COMPOSER_MAX_PARALLEL_HTTP=2"))
+			Expect(composerInstallExecution.Env).To(ContainElement("COMPOSER_MAX_PARALLEL_HTTP=2"))
+			Expect(composerCheckPlatformReqsExecExecution.Env).To(ContainElement("COMPOSER_MAX_PARALLEL_HTTP=2"))
+			Expect(buffer.String()).To(ContainSubstring("Limiting Composer to 2 parallel download(s)"))
+		})
+
+		context("when it is not a positive integer", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_MAX_PARALLEL_HTTP", "0")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError(`error when parsing env var "BP_COMPOSER_MAX_PARALLEL_HTTP": must be a positive integer`))
+			})
+		})
+	})
+
+	context("output verbosity", func() {
+		it("hides the funding notices and the ANSI output on all executions", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(composerConfigExecution.Env).To(ContainElements("COMPOSER_FUND=0", "NO_COLOR=1"))
+			Expect(composerInstallExecution.Env).To(ContainElements("COMPOSER_FUND=0", "NO_COLOR=1"))
+			Expect(composerCheckPlatformReqsExecExecution.Env).To(ContainElements("COMPOSER_FUND=0", "NO_COLOR=1"))
+			Expect(composerInstallExecution.Env).NotTo(ContainElement(HavePrefix("COMPOSER_NO_AUDIT=")))
+		})
+
+		it("holds back the output of 'composer install' if it succeeds", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buffer.String()).To(ContainSubstring("Running 'composer install options from fake'"))
+			Expect(buffer.String()).NotTo(ContainSubstring("stdout from composer install"))
+			Expect(buffer.String()).NotTo(ContainSubstring("stderr from composer install"))
+			Expect(buffer.String()).To(ContainSubstring("stdout from composer config"))
+		})
+
+		context("when 'composer install' fails", func() {
+			it.Before(func() {
+				composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+					_, _ = fmt.Fprintln(temp.Stdout, "Installing dependencies from lock file")
+					_, _ = fmt.Fprintln(temp.Stderr, "Your lock file does not contain a compatible set of packages.")
+					return errors.New("exit status 2")
+				}
+			})
+
+			it("writes the output in full", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError(ContainSubstring("exit status 2")))
+
+				Expect(buffer).To(ContainLines(
+					"      Installing dependencies from lock file",
+					"      Your lock file does not contain a compatible set of packages.",
+				))
+			})
+		})
+
+		context("with BP_LOG_LEVEL set to DEBUG", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_LOG_LEVEL", "DEBUG")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_LOG_LEVEL")).To(Succeed())
+			})
+
+			it("writes the output of 'composer install'", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).To(ContainSubstring("stdout from composer install"))
+				Expect(buffer.String()).To(ContainSubstring("stderr from composer install"))
+			})
+		})
+
+		context("with BP_COMPOSER_FUND set to true", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_FUND", "true")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_FUND")).To(Succeed())
+			})
+
+			it("shows the funding notices", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerInstallExecution.Env).NotTo(ContainElement(HavePrefix("COMPOSER_FUND=")))
+			})
+
+			context("when it is not a boolean", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_FUND", "sometimes")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError(ContainSubstring(`error when parsing env var "BP_COMPOSER_FUND"`)))
+				})
+			})
+		})
+
+		context("with COMPOSER_FUND set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("COMPOSER_FUND", "1")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("COMPOSER_FUND")).To(Succeed())
+			})
+
+			it("passes it through unchanged", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerInstallExecution.Env).To(ContainElement("COMPOSER_FUND=1"))
+				Expect(composerInstallExecution.Env).NotTo(ContainElement("COMPOSER_FUND=0"))
+			})
+		})
+
+		context("with BP_COMPOSER_AUDIT set to false", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_AUDIT", "false")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_AUDIT")).To(Succeed())
+			})
+
+			it("skips the security audit", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerInstallExecution.Env).To(ContainElement("COMPOSER_NO_AUDIT=1"))
+				Expect(buffer.String()).To(ContainSubstring("Skipping the security audit of 'composer install'"))
+			})
+		})
+	})
+
+	context("with COMPOSER_MAX_PARALLEL_HTTP set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("COMPOSER_MAX_PARALLEL_HTTP", "3")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("COMPOSER_MAX_PARALLEL_HTTP")).To(Succeed())
+		})
+
+		it("passes it through unchanged", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			var values []string
+			for _, env := range composerInstallExecution.Env {
+				if strings.HasPrefix(env, "COMPOSER_MAX_PARALLEL_HTTP=") {
+					values = append(values, env)
+				}
+			}
+			Expect(values).To(Equal([]string{"COMPOSER_MAX_PARALLEL_HTTP=3"}))
+		})
+	})
+
+	context("with BP_COMPOSER_DISABLE_HTTP2 set to true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_DISABLE_HTTP2", "true")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_DISABLE_HTTP2")).To(Succeed())
+		})
+
+		it("disables the curl downloader in the php.ini used by composer", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			contents, err := os.ReadFile(filepath.Join(layersDir, "composer-php-ini", "composer-php.ini"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal(`[PHP]
+extension_dir = "php-extension-dir"
+extension = openssl.so
+disable_functions = curl_multi_init,curl_multi_exec`))
+			Expect(buffer.String()).To(ContainSubstring("Disabling HTTP/2 for Composer downloads"))
+		})
+	})
+
+	context("with BP_COMPOSER_PROFILE set to large", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_PROFILE", "large")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_PROFILE")).To(Succeed())
+		})
+
+		it("tunes composer for large projects", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			contents, err := os.ReadFile(filepath.Join(layersDir, "composer-php-ini", "composer-php.ini"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal(`[PHP]
+extension_dir = "php-extension-dir"
+extension = openssl.so
+memory_limit = -1`))
+
+			Expect(composerInstallExecution.Env).To(ContainElements(
+				"COMPOSER_PROCESS_TIMEOUT=1800",
+				"COMPOSER_MAX_PARALLEL_HTTP=24",
+			))
+			Expect(composerConfigExecution.Env).To(ContainElement("COMPOSER_PROCESS_TIMEOUT=1800"))
+
+			Expect(buffer.String()).To(ContainSubstring("Using build profile 'large'"))
+			Expect(buffer.String()).To(ContainSubstring("memory_limit=-1, COMPOSER_PROCESS_TIMEOUT=1800"))
+			Expect(buffer.String()).To(ContainSubstring("Allowing Composer 24 parallel download(s)"))
+		})
+
+		context("when the settings have been set explicitly", func() {
+			it.Before(func() {
+				Expect(os.Setenv("COMPOSER_PROCESS_TIMEOUT", "600")).To(Succeed())
+				Expect(os.Setenv("BP_COMPOSER_MAX_PARALLEL_HTTP", "6")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("COMPOSER_PROCESS_TIMEOUT")).To(Succeed())
+				Expect(os.Unsetenv("BP_COMPOSER_MAX_PARALLEL_HTTP")).To(Succeed())
+			})
+
+			it("keeps them", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerInstallExecution.Env).To(ContainElements(
+					"COMPOSER_PROCESS_TIMEOUT=600",
+					"COMPOSER_MAX_PARALLEL_HTTP=6",
+				))
+				Expect(composerInstallExecution.Env).NotTo(ContainElement("COMPOSER_PROCESS_TIMEOUT=1800"))
+				Expect(composerInstallExecution.Env).NotTo(ContainElement("COMPOSER_MAX_PARALLEL_HTTP=24"))
+			})
+		})
+	})
+
+	context("with an unsupported BP_COMPOSER_PROFILE", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_PROFILE", "huge")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_PROFILE")).To(Succeed())
+		})
+
+		it("returns an error", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).To(MatchError(`unsupported BP_COMPOSER_PROFILE "huge", supported are "default" and "large"`))
+		})
+	})
+
+	context("with BP_COMPOSER_SYNTHESIZE set to true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_SYNTHESIZE", "true")).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "index.php"), []byte("<?php"), os.ModePerm)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_SYNTHESIZE")).To(Succeed())
+		})
+
+		it("synthesizes composer.json", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			contents, err := os.ReadFile(filepath.Join(workingDir, "composer.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(MatchJSON(`{
+				"autoload": {"classmap": ["./"]},
+				"repositories": [{"packagist.org": false}]
+			}`))
+
+			Expect(composerInstallExecution.Env).To(ContainElement(fmt.Sprintf("COMPOSER=%s", filepath.Join(workingDir, "composer.json"))))
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("No composer.json found, synthesizing %s", filepath.Join(workingDir, "composer.json"))))
+		})
+
+		context("when composer.json exists", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte("{}"), os.ModePerm)).To(Succeed())
+			})
+
+			it("keeps composer.json", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				contents, err := os.ReadFile(filepath.Join(workingDir, "composer.json"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal("{}"))
+				Expect(buffer.String()).NotTo(ContainSubstring("synthesizing"))
+			})
+		})
+	})
+
+	context("with a ca-certificates binding", func() {
+		var (
+			bindingDir    string
+			systemCAFile  string
+			composerIni   string
+			caBundle      string
+			opensslConfig string
+		)
+
+		it.Before(func() {
+			var err error
+			bindingDir, err = os.MkdirTemp("", "binding")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.WriteFile(filepath.Join(bindingDir, "internal-ca.pem"), []byte("some-internal-ca"), 0600)).To(Succeed())
+
+			systemCAFile = filepath.Join(bindingDir, "system-ca-bundle.crt")
+			Expect(os.WriteFile(systemCAFile, []byte("some-system-ca\n"), 0600)).To(Succeed())
+			Expect(os.Setenv("SSL_CERT_FILE", systemCAFile)).To(Succeed())
+
+			bindingResolver.ResolveCall.Returns.BindingSlice = []servicebindings.Binding{
+				{
+					Name: "some-binding",
+					Path: bindingDir,
+					Type: "ca-certificates",
+					Entries: map[string]*servicebindings.Entry{
+						"internal-ca.pem": servicebindings.NewEntry(filepath.Join(bindingDir, "internal-ca.pem")),
+					},
+				},
+			}
+
+			// like the actual resolver, only return the bindings of the requested type
+			bindingResolver.ResolveCall.Stub = func(typ, _, _ string) ([]servicebindings.Binding, error) {
+				if typ != "ca-certificates" {
+					return nil, nil
+				}
+				return bindingResolver.ResolveCall.Returns.BindingSlice, bindingResolver.ResolveCall.Returns.Error
+			}
+
+			composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				composerInstallExecution = temp
+
+				content, err := os.ReadFile(filepath.Join(layersDir, "composer-php-ini", "composer-php.ini"))
+				Expect(err).NotTo(HaveOccurred())
+				composerIni = string(content)
+
+				for _, line := range strings.Split(composerIni, "\n") {
+					if strings.HasPrefix(line, "openssl.cafile = ") {
+						content, err = os.ReadFile(strings.Trim(strings.TrimPrefix(line, "openssl.cafile = "), `"`))
+						Expect(err).NotTo(HaveOccurred())
+						caBundle = string(content)
+					}
+				}
+
+				// the last OPENSSL_CONF of the environment takes effect
+				var opensslConfigPath string
+				for _, env := range temp.Env {
+					if strings.HasPrefix(env, "OPENSSL_CONF=") {
+						opensslConfigPath = strings.TrimPrefix(env, "OPENSSL_CONF=")
+					}
+				}
+				if opensslConfigPath != "" {
+					content, err = os.ReadFile(opensslConfigPath)
+					Expect(err).NotTo(HaveOccurred())
+					opensslConfig = string(content)
+				}
+
+				return os.MkdirAll(filepath.Join(workingDir, "vendor"), os.ModePerm)
+			}
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("SSL_CERT_FILE")).To(Succeed())
+			Expect(os.RemoveAll(bindingDir)).To(Succeed())
+		})
+
+		it("trusts the CA certificates in addition to the system CA bundle", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+				Platform:      packit.Platform{Path: "some-platform-dir"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(caBundle).To(Equal("some-system-ca\nsome-internal-ca\n"))
+			Expect(composerIni).To(MatchRegexp(`(?m)^openssl\.cafile = ".+/ca-bundle\.crt"$`))
+			Expect(composerIni).To(MatchRegexp(`(?m)^curl\.cainfo = ".+/ca-bundle\.crt"$`))
+			Expect(composerIni).NotTo(ContainSubstring("openssl.capath"))
+			Expect(composerInstallExecution.Env).NotTo(ContainElement(HavePrefix("OPENSSL_CONF=")))
+
+			Expect(buffer.String()).To(ContainSubstring("Configuring TLS for Composer"))
+			Expect(buffer.String()).To(ContainSubstring("Using CA certificates of binding 'some-binding'"))
+
+			content, err := os.ReadFile(filepath.Join(layersDir, "composer-php-ini", "composer-php.ini"))
+			Expect(err).NotTo(HaveOccurred())
+			for _, line := range strings.Split(string(content), "\n") {
+				if strings.HasPrefix(line, "openssl.cafile = ") {
+					Expect(filepath.Dir(strings.Trim(strings.TrimPrefix(line, "openssl.cafile = "), `"`))).NotTo(BeADirectory())
+				}
+			}
+		})
+
+		context("with BP_COMPOSER_CAFILE and BP_COMPOSER_CAPATH set", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(bindingDir, "other-ca.pem"), []byte("some-other-ca\n"), 0600)).To(Succeed())
+				Expect(os.Setenv("BP_COMPOSER_CAFILE", filepath.Join(bindingDir, "other-ca.pem"))).To(Succeed())
+				Expect(os.Setenv("BP_COMPOSER_CAPATH", "/some/ca/path")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_CAFILE")).To(Succeed())
+				Expect(os.Unsetenv("BP_COMPOSER_CAPATH")).To(Succeed())
+			})
+
+			it("trusts them as well", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(caBundle).To(Equal("some-system-ca\nsome-internal-ca\nsome-other-ca\n"))
+				Expect(composerIni).To(ContainSubstring(`openssl.capath = "/some/ca/path"`))
+			})
+
+			context("when BP_COMPOSER_CAFILE does not exist", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_CAFILE", filepath.Join(bindingDir, "missing.pem"))).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError(ContainSubstring("failed to read BP_COMPOSER_CAFILE")))
+				})
+			})
+		})
+
+		context("with BP_COMPOSER_TLS_MIN_VERSION set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_TLS_MIN_VERSION", "1.2")).To(Succeed())
+				// there is no system OpenSSL config
+				Expect(os.Setenv("OPENSSL_CONF", filepath.Join(workingDir, "missing-openssl.cnf"))).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_TLS_MIN_VERSION")).To(Succeed())
+				Expect(os.Unsetenv("OPENSSL_CONF")).To(Succeed())
+			})
+
+			it("requires the minimum TLS version in the OpenSSL config", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(opensslConfig).To(Equal(`openssl_conf = openssl_init
+
+[openssl_init]
+ssl_conf = ssl_configuration
+
+[ssl_configuration]
+system_default = tls_system_default
+
+[tls_system_default]
+MinProtocol = TLSv1.2
+`))
+				Expect(composerConfigExecution.Env).To(ContainElement(HavePrefix("OPENSSL_CONF=")))
+				Expect(buffer.String()).To(ContainSubstring("Requiring at least TLSv1.2"))
+			})
+
+			context("when there is a system OpenSSL config", func() {
+				var systemConfig string
+
+				it.Before(func() {
+					systemConfig = filepath.Join(workingDir, "openssl.cnf")
+					Expect(os.WriteFile(systemConfig, []byte(`# the system config
+openssl_conf = default_conf
+
+[default_conf]
+ssl_conf = ssl_sect # the TLS settings
+
+[ssl_sect]
+system_default = system_default_sect
+
+[system_default_sect]
+CipherString = DEFAULT:@SECLEVEL=2
+`), os.ModePerm)).To(Succeed())
+					Expect(os.Setenv("OPENSSL_CONF", systemConfig)).To(Succeed())
+				})
+
+				it("includes it and extends its sections", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(opensslConfig).To(Equal(fmt.Sprintf(`openssl_conf = default_conf
+
+.include %s
+
+[default_conf]
+ssl_conf = ssl_sect
+
+[ssl_sect]
+system_default = system_default_sect
+
+[system_default_sect]
+MinProtocol = TLSv1.2
+`, systemConfig)))
+					Expect(buffer.String()).To(ContainSubstring("Including the system OpenSSL config %s", systemConfig))
+				})
+			})
+
+			context("when it is not a TLS version", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_TLS_MIN_VERSION", "TLSv1.2")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError(`error when parsing env var "BP_COMPOSER_TLS_MIN_VERSION": must be one of 1.0, 1.1, 1.2 or 1.3, found "TLSv1.2"`))
+				})
+			})
+		})
+	})
+
+	context("with a composer-ssh binding", func() {
+		var (
+			bindingDir     string
+			sshConfig      string
+			sshKnownHosts  string
+			sshIdentityKey string
+		)
+
+		it.Before(func() {
+			var err error
+			bindingDir, err = os.MkdirTemp("", "binding")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.WriteFile(filepath.Join(bindingDir, "ssh-privatekey"), []byte("some-private-key"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(bindingDir, "known_hosts"), []byte("github.com ssh-ed25519 some-host-key"), 0600)).To(Succeed())
+
+			bindingResolver.ResolveCall.Returns.BindingSlice = []servicebindings.Binding{
+				{
+					Name: "some-binding",
+					Path: bindingDir,
+					Type: "composer-ssh",
+					Entries: map[string]*servicebindings.Entry{
+						"ssh-privatekey": servicebindings.NewEntry(filepath.Join(bindingDir, "ssh-privatekey")),
+						"known_hosts":    servicebindings.NewEntry(filepath.Join(bindingDir, "known_hosts")),
+					},
+				},
+			}
+
+			// like the actual resolver, only return the bindings of the requested type
+			bindingResolver.ResolveCall.Stub = func(typ, _, _ string) ([]servicebindings.Binding, error) {
+				if typ != "composer-ssh" {
+					return nil, nil
+				}
+				return bindingResolver.ResolveCall.Returns.BindingSlice, bindingResolver.ResolveCall.Returns.Error
+			}
+
+			composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				composerInstallExecution = temp
+				for _, env := range temp.Env {
+					if strings.HasPrefix(env, "GIT_SSH_COMMAND=ssh -F ") {
+						sshConfig = strings.TrimPrefix(env, "GIT_SSH_COMMAND=ssh -F ")
+					}
+				}
+				Expect(sshConfig).To(BeARegularFile())
+
+				content, err := os.ReadFile(filepath.Join(filepath.Dir(sshConfig), "known_hosts"))
+				Expect(err).NotTo(HaveOccurred())
+				sshKnownHosts = string(content)
+
+				content, err = os.ReadFile(filepath.Join(filepath.Dir(sshConfig), "some-binding-ssh-privatekey"))
+				Expect(err).NotTo(HaveOccurred())
+				sshIdentityKey = string(content)
+
+				return os.MkdirAll(filepath.Join(workingDir, "vendor"), os.ModePerm)
+			}
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(bindingDir)).To(Succeed())
+		})
+
+		it("configures git to use the SSH keys during composer install", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+				Platform:      packit.Platform{Path: "some-platform-dir"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(bindingResolver.ResolveCall.Receives.Typ).To(Equal("composer-ssh"))
+			Expect(bindingResolver.ResolveCall.Receives.PlatformDir).To(Equal("some-platform-dir"))
+
+			Expect(sshKnownHosts).To(Equal("github.com ssh-ed25519 some-host-key\n"))
+			Expect(sshIdentityKey).To(Equal("some-private-key\n"))
+			Expect(composerConfigExecution.Env).NotTo(ContainElement(HavePrefix("GIT_SSH_COMMAND=")))
+
+			Expect(buffer.String()).To(ContainSubstring("Configuring SSH for private VCS repositories"))
+			Expect(buffer.String()).To(ContainSubstring("Using binding 'some-binding'"))
+			Expect(buffer.String()).NotTo(ContainSubstring("some-private-key"))
+
+			Expect(filepath.Dir(sshConfig)).NotTo(BeADirectory())
+		})
+
+		context("when the binding contains no private key", func() {
+			it.Before(func() {
+				delete(bindingResolver.ResolveCall.Returns.BindingSlice[0].Entries, "ssh-privatekey")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError(`no private keys found in bindings of type "composer-ssh"`))
+			})
+		})
+
+		context("when the bindings cannot be resolved", func() {
+			it.Before(func() {
+				bindingResolver.ResolveCall.Returns.Error = errors.New("some-binding-error")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError("some-binding-error"))
+			})
+		})
+	})
+
+	context("when auth.json is part of the application", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "auth.json"), []byte("{}"), os.ModePerm)).To(Succeed())
+		})
+
+		it("logs a warning with remediation", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buffer.String()).To(ContainSubstring("Found 1 possible misconfiguration(s) of the workspace"))
+			Expect(buffer.String()).To(ContainSubstring("WARNING [auth-json-committed]: auth.json is part of the application, so its credentials end up in the image"))
+			Expect(buffer.String()).To(ContainSubstring(`Remediation: Remove auth.json from the repository, rotate its credentials, and provide them with a "composer-repositories" binding or COMPOSER_AUTH instead`))
+		})
+	})
+
+	context("with BP_COMPOSER_AUTOLOADER_SUFFIX set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_AUTOLOADER_SUFFIX", "Blue_{stack}")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_AUTOLOADER_SUFFIX")).To(Succeed())
+		})
+
+		it("configures the suffix with the stack ID", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+				Stack:         "io.buildpacks.stacks.jammy",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(composerConfigExecution.Args).To(Equal([]string{"config", "autoloader-suffix", "Blue_io_buildpacks_stacks_jammy"}))
+			Expect(result.Layers[0].Metadata["layer-inputs"]).To(HaveKeyWithValue("autoloader-suffix", "Blue_io_buildpacks_stacks_jammy"))
+		})
+
+		context("when the suffix is invalid", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_AUTOLOADER_SUFFIX", "Blue-Green")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError(`BP_COMPOSER_AUTOLOADER_SUFFIX must only contain letters, digits, underscores and "{stack}", found "Blue-Green"`))
+			})
+		})
+	})
+
+	context("with a composer-repositories binding", func() {
+		var bindingDir string
+
+		it.Before(func() {
+			var err error
+			bindingDir, err = os.MkdirTemp("", "binding")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.WriteFile(filepath.Join(bindingDir, "repositories.yaml"), []byte(`
+packagist: false
+repositories:
+  - name: satis
+    url: https://satis.example.com
+    priority: 10
+    auth:
+      username: ci
+      password: some-password
+  - name: private-packagist
+    url: https://repo.packagist.com/acme/
+    priority: 20
+`), 0600)).To(Succeed())
+
+			bindingResolver.ResolveCall.Stub = func(typ, _, _ string) ([]servicebindings.Binding, error) {
+				if typ != "composer-repositories" {
+					return nil, nil
+				}
+				return []servicebindings.Binding{
+					{
+						Name: "some-repositories",
+						Path: bindingDir,
+						Type: "composer-repositories",
+						Entries: map[string]*servicebindings.Entry{
+							"repositories.yaml": servicebindings.NewEntry(filepath.Join(bindingDir, "repositories.yaml")),
+						},
+					},
+				}, nil
+			}
+
+			composerHomeDir := filepath.Join(layersDir, composer.ComposerHomeLayerName)
+			Expect(os.MkdirAll(composerHomeDir, os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(composerHomeDir, "config.json"), []byte(`{"config": {"process-timeout": 900}}`), os.ModePerm)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(bindingDir)).To(Succeed())
+		})
+
+		it("writes the repositories into config.json and passes the credentials with COMPOSER_AUTH", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			content, err := os.ReadFile(filepath.Join(layersDir, composer.ComposerHomeLayerName, "config.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(MatchJSON(`{
+	"config": {"process-timeout": 900},
+	"repositories": [
+		{"type": "composer", "url": "https://repo.packagist.com/acme/"},
+		{"type": "composer", "url": "https://satis.example.com"},
+		{"packagist.org": false}
+	]
+}`))
+			Expect(string(content)).NotTo(ContainSubstring("some-password"))
+
+			Expect(composerInstallExecution.Env).To(ContainElement(`COMPOSER_AUTH={"http-basic":{"satis.example.com":{"password":"some-password","username":"ci"}}}`))
+			Expect(composerConfigExecution.Env).NotTo(ContainElement(HavePrefix("COMPOSER_AUTH=")))
+
+			Expect(result.Layers[1].Metadata["composer-repositories"]).To(Equal([]string{"satis", "private-packagist"}))
+
+			Expect(buffer.String()).To(ContainSubstring("Configuring Composer repositories"))
+			Expect(buffer.String()).To(ContainSubstring("Using binding 'some-repositories'"))
+			Expect(buffer.String()).To(ContainSubstring("- satis: composer https://satis.example.com (priority 10)"))
+			Expect(buffer.String()).To(ContainSubstring("packagist.org is disabled"))
+			Expect(buffer.String()).NotTo(ContainSubstring("some-password"))
+		})
+
+		context("when the binding has been removed", func() {
+			it.Before(func() {
+				bindingResolver.ResolveCall.Stub = nil
+
+				Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerHomeLayerName)), []byte(`[metadata]
+composer-repositories = ["satis"]
+`), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(layersDir, composer.ComposerHomeLayerName, "config.json"), []byte(`{"config": {"process-timeout": 900}, "repositories": [{"type": "composer", "url": "https://satis.example.com"}]}`), os.ModePerm)).To(Succeed())
+			})
+
+			it("removes the repositories of the previous build", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := os.ReadFile(filepath.Join(layersDir, composer.ComposerHomeLayerName, "config.json"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(MatchJSON(`{"config": {"process-timeout": 900}}`))
+				Expect(result.Layers[1].Metadata).NotTo(HaveKey("composer-repositories"))
+			})
+		})
+
+		context("when repositories.yaml is invalid", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(bindingDir, "repositories.yaml"), []byte("repositories: [{name: satis}]"), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError(`failed to parse repositories.yaml of binding "some-repositories": repository "satis" has no url`))
+			})
+		})
+	})
+
+	context("with BP_DISABLE_SBOM set to true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_DISABLE_SBOM", "true")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_DISABLE_SBOM")).To(Succeed())
+		})
+
+		it("does not generate an SBOM", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(sbomGenerator.GenerateCall.CallCount).To(Equal(0))
+			Expect(result.Layers[0].SBOM).To(BeNil())
+			Expect(buffer.String()).To(ContainSubstring("Skipping SBOM generation"))
+		})
+	})
+
+	context("when no SBOM formats are requested", func() {
+		it.Before(func() {
+			buildpackInfo.SBOMFormats = nil
+		})
+
+		it("does not generate an SBOM", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(sbomGenerator.GenerateCall.CallCount).To(Equal(0))
+			Expect(result.Layers[0].SBOM).To(BeNil())
+		})
+	})
+
+	context("with BP_COMPOSER_SBOM_PHP_EXTENSIONS set to true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_SBOM_PHP_EXTENSIONS", "true")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_SBOM_PHP_EXTENSIONS")).To(Succeed())
+		})
+
+		it("adds the PHP extensions to the SBOM", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			formats := result.Layers[0].SBOM.Formats()
+			Expect(formats).To(HaveLen(2))
+
+			Expect(formats[0].Extension).To(Equal("cdx.json"))
+			content, err := io.ReadAll(formats[0].Content)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring(`{"bom-ref":"pkg:generic/php-ext-bar","name":"php-ext-bar","purl":"pkg:generic/php-ext-bar","type":"library"}`))
+			Expect(string(content)).To(ContainSubstring(`"purl":"pkg:generic/php-ext-hello"`))
+			Expect(string(content)).To(ContainSubstring(`"purl":"pkg:generic/php-ext-openssl"`))
+
+			Expect(formats[1].Extension).To(Equal("spdx.json"))
+			content, err = io.ReadAll(formats[1].Content)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring(`"referenceLocator":"pkg:generic/php-ext-bar"`))
+
+			// the cached SBOM only lists the packages
+			cached, err := os.ReadFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "sbom-cache", "cdx.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(cached)).NotTo(ContainSubstring("php-ext-"))
+
+			Expect(buffer.String()).To(ContainSubstring("Listing 3 PHP extension(s) in the SBOM"))
+		})
+
+		context("when no SBOM is generated", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_DISABLE_SBOM", "true")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_DISABLE_SBOM")).To(Succeed())
+			})
+
+			it("skips the PHP extensions", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers[0].SBOM).To(BeNil())
+				Expect(buffer.String()).To(ContainSubstring("Skipping the PHP extensions in the SBOM, as no SBOM is generated"))
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_DENY_ABANDONED set to true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_DENY_ABANDONED", "true")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_DENY_ABANDONED")).To(Succeed())
+		})
+
+		context("when composer.lock contains no abandoned packages", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{"packages": [{"name": "some/package"}]}`), os.ModePerm)).To(Succeed())
+			})
+
+			it("runs the build", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buffer.String()).To(ContainSubstring("No abandoned packages found"))
+			})
+		})
+
+		context("when composer.lock contains abandoned packages", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{"packages": [{"name": "some/package", "abandoned": "some/replacement"}]}`), os.ModePerm)).To(Succeed())
+			})
+
+			it("fails the build before running composer install", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError("found 1 abandoned package(s) while BP_COMPOSER_DENY_ABANDONED is set: some/package (use some/replacement instead)"))
+				Expect(composerInstallExecutable.ExecuteCall.CallCount).To(Equal(0))
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_VERIFY_INTEGRITY set to true", func() {
+		var (
+			installArgs   [][]string
+			extracted     []bool
+			cacheFilesDir string
+		)
+
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_VERIFY_INTEGRITY", "true")).To(Succeed())
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+	"packages": [
+		{"name": "some/package", "dist": {"type": "zip", "url": "https://example.com/some/package.zip", "shasum": "b2803fafd68ed39654ad9c262ebfbb18323ed6b9"}},
+		{"name": "some/other-package", "dist": {"type": "zip", "url": "https://example.com/some/other-package.zip", "shasum": ""}}
+	]
+}`), os.ModePerm)).To(Succeed())
+
+			installArgs = nil
+			extracted = nil
+			cacheFilesDir = filepath.Join(layersDir, composer.ComposerHomeLayerName, "cache", "files")
+			composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				installArgs = append(installArgs, temp.Args)
+
+				// whether the package has been extracted before, in which
+				// case composer would not run its events again
+				packageDir := filepath.Join(workingDir, "vendor", "some", "package")
+				_, err := os.Stat(packageDir)
+				extracted = append(extracted, err == nil)
+
+				archiveDir := filepath.Join(cacheFilesDir, "some", "package")
+				Expect(os.MkdirAll(archiveDir, os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(archiveDir, "9b3d9c87424a3850263fd3b377fbd09f7f3da393.zip"), []byte("some-archive"), os.ModePerm)).To(Succeed())
+
+				if downloadOnly, _ := ContainElement("--download-only").Match(temp.Args); downloadOnly {
+					return nil
+				}
+				return os.MkdirAll(packageDir, os.ModePerm)
+			}
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_VERIFY_INTEGRITY")).To(Succeed())
+		})
+
+		it("verifies the downloaded archives before any code of the packages runs", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(installArgs).To(HaveLen(2))
+			Expect(installArgs[0]).To(ContainElements("--download-only", "--no-plugins", "--no-scripts"))
+			Expect(installArgs[1]).NotTo(ContainElements("--download-only", "--no-plugins", "--no-scripts"))
+			Expect(extracted).To(Equal([]bool{false, false}))
+
+			Expect(buffer.String()).To(ContainSubstring("to verify the downloaded archives before any code of the packages runs"))
+			Expect(buffer.String()).To(ContainSubstring("Verifying integrity of downloaded files"))
+			Expect(buffer.String()).To(ContainSubstring("Verified 1 package archive(s)"))
+			Expect(buffer.String()).To(ContainSubstring("Unable to verify 1 package(s) without a recorded checksum or downloaded archive"))
+		})
+
+		context("when Composer cannot only download the packages", func() {
+			it.Before(func() {
+				composerVersionExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+					_, err := fmt.Fprint(temp.Stdout, "Composer version 1.10.26 2022-04-13 16:39:56\n")
+					return err
+				}
+			})
+
+			it("extracts the packages again after the verification, so that their events run", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(installArgs).To(HaveLen(2))
+				Expect(installArgs[0]).To(ContainElements("--no-plugins", "--no-scripts"))
+				Expect(installArgs[0]).NotTo(ContainElement("--download-only"))
+				Expect(installArgs[1]).NotTo(ContainElements("--no-plugins", "--no-scripts"))
+				Expect(extracted).To(Equal([]bool{false, false}))
+
+				Expect(buffer.String()).To(ContainSubstring("Verified 1 package archive(s)"))
+			})
+		})
+
+		context("when the cache files dir is configured in composer.json", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{"config": {"cache-files-dir": "{$cache-dir}/custom-files"}}`), os.ModePerm)).To(Succeed())
+				cacheFilesDir = filepath.Join(layersDir, composer.ComposerHomeLayerName, "cache", "custom-files")
+			})
+
+			it("verifies the archives in the configured cache files dir", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buffer.String()).To(ContainSubstring("Verified 1 package archive(s)"))
+			})
+		})
+
+		context("when COMPOSER_CACHE_DIR is set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("COMPOSER_CACHE_DIR", filepath.Join(workingDir, "composer-cache"))).To(Succeed())
+				cacheFilesDir = filepath.Join(workingDir, "composer-cache", "files")
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("COMPOSER_CACHE_DIR")).To(Succeed())
+			})
+
+			it("verifies the archives in its files dir", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buffer.String()).To(ContainSubstring("Verified 1 package archive(s)"))
+			})
+		})
+
+		context("when an archive does not match composer.lock", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+	"packages": [
+		{"name": "some/package", "dist": {"type": "zip", "url": "https://example.com/some/package.zip", "shasum": "0000000000000000000000000000000000000000"}}
+	]
+}`), os.ModePerm)).To(Succeed())
+			})
+
+			it("fails the build before any code of the packages runs", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError("integrity verification failed for 1 file(s):\nsome/package: expected 0000000000000000000000000000000000000000, found b2803fafd68ed39654ad9c262ebfbb18323ed6b9"))
+				Expect(installArgs).To(HaveLen(1))
+				Expect(installArgs[0]).To(ContainElements("--download-only", "--no-plugins", "--no-scripts"))
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_BUILD_STAMP set to true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_BUILD_STAMP", "true")).To(Succeed())
+
+			composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "composer"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "composer", "installed.json"), []byte(`{
+	"packages": [
+		{"name": "some/package"},
+		{"name": "some/other-package"}
+	]
+}`), os.ModePerm)).To(Succeed())
+				composerInstallExecution = temp
+				return nil
+			}
+
+			dependencies.Clock = chronos.NewClock(func() time.Time {
+				return time.Date(2023, 10, 6, 10, 11, 52, 0, time.UTC)
+			})
+			build = composer.Build(dependencies)
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_BUILD_STAMP")).To(Succeed())
+		})
+
+		it("writes composer-build.json into the working directory and the layer", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					ID:          "some-buildpack-id",
+					Name:        "Some Buildpack",
+					Version:     "some-version",
+					SBOMFormats: []string{sbom.CycloneDXFormat},
+				},
+				WorkingDir: workingDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Plan:       buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(composerVersionExecution.Args).To(Equal([]string{"--version", "--no-ansi"}))
+
+			for _, dir := range []string{workingDir, filepath.Join(layersDir, composer.ComposerPackagesLayerName)} {
+				content, err := os.ReadFile(filepath.Join(dir, "composer-build.json"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(MatchJSON(`{
+					"composer-lock-sha": "default-checksum",
+					"build-time": "2023-10-06T10:11:52Z",
+					"buildpack-id": "some-buildpack-id",
+					"buildpack-version": "some-version",
+					"composer-version": "2.6.5",
+					"package-count": 2
+				}`))
+			}
+		})
+
+		context("when the composer version cannot be parsed", func() {
+			it.Before(func() {
+				composerVersionExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+					_, err := fmt.Fprint(temp.Stdout, "something unexpected\n")
+					return err
+				}
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError(`failed to parse composer version from output "something unexpected"`))
+			})
+		})
+	})
+
+	context("when composer install reports warnings", func() {
+		it.Before(func() {
+			composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "local-package-name"), os.ModeDir|os.ModePerm)).To(Succeed())
+				_, err := fmt.Fprint(temp.Stderr, "Installing dependencies from lock file\n"+
+					"Package acme/legacy is abandoned, you should avoid using it. No replacement was suggested.\n"+
+					"1 package suggestions were added by new dependencies, use `composer suggest` to see details.\n")
+				return err
+			}
+		})
+
+		it("logs the warnings and lists them in the layer metadata", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers[0].Metadata[composer.ComposerWarningsMetadataKey]).To(Equal([]map[string]interface{}{
+				{"kind": "abandoned", "message": "Package acme/legacy is abandoned, you should avoid using it. No replacement was suggested."},
+				{"kind": "suggestion", "message": "1 package suggestions were added by new dependencies, use `composer suggest` to see details."},
+			}))
+
+			Expect(buffer.String()).To(ContainSubstring("Composer reported 2 warning(s)"))
+			Expect(buffer.String()).To(ContainSubstring("WARNING: [abandoned] Package acme/legacy is abandoned, you should avoid using it. No replacement was suggested."))
+		})
+	})
+
+	context("with BP_COMPOSER_INSTALL_STRATEGY set", func() {
+		var strategy *fakes.InstallStrategy
+
+		it.Before(func() {
+			strategy = &fakes.InstallStrategy{}
+			strategy.NameCall.Returns.String = "mirror-client"
+			strategy.InstallCall.Stub = func(composer.InstallContext) error {
+				return os.MkdirAll(filepath.Join(workingDir, "vendor", "mirrored-package"), os.ModePerm)
+			}
+			composer.RegisterInstallStrategy(strategy)
+
+			Expect(os.Setenv("BP_COMPOSER_INSTALL_STRATEGY", "mirror-client")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_INSTALL_STRATEGY")).To(Succeed())
+		})
+
+		it("installs the packages with the registered strategy", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(strategy.InstallCall.CallCount).To(Equal(1))
+			context := strategy.InstallCall.Receives.Context
+			Expect(context.FromCache).To(BeFalse())
+			Expect(context.Composer).NotTo(BeNil())
+			Expect(context.Execution.Args).To(Equal([]string{"install", "options", "from", "fake"}))
+			Expect(context.Execution.Dir).To(Equal(workingDir))
+			Expect(context.Execution.Env).To(ContainElement(fmt.Sprintf("COMPOSER_VENDOR_DIR=%s", filepath.Join(workingDir, "vendor"))))
+
+			Expect(composerInstallExecutable.ExecuteCall.CallCount).To(Equal(0))
+			Expect(buffer.String()).To(ContainSubstring(`Installing packages with the "mirror-client" install strategy (BP_COMPOSER_INSTALL_STRATEGY)`))
+		})
+
+		context("when the strategy runs the Composer CLI", func() {
+			it.Before(func() {
+				strategy.InstallCall.Stub = func(context composer.InstallContext) error {
+					context.Execution.Env = append(context.Execution.Env, "COMPOSER_MIRROR_PATH_REPOS=1")
+					return context.Composer.Execute(context.Execution)
+				}
+			})
+
+			it("runs composer install with its changes", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerInstallExecution.Args).To(Equal([]string{"install", "options", "from", "fake"}))
+				Expect(composerInstallExecution.Env).To(ContainElement("COMPOSER_MIRROR_PATH_REPOS=1"))
+			})
+		})
+
+		context("when the strategy fails", func() {
+			it.Before(func() {
+				strategy.InstallCall.Stub = nil
+				strategy.InstallCall.Returns.Error = errors.New("mirror unavailable")
+			})
+
+			it("returns the error", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError(ContainSubstring("mirror unavailable")))
+			})
+		})
+
+		context("when no strategy has been registered with that name", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_INSTALL_STRATEGY", "velocita")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError(`BP_COMPOSER_INSTALL_STRATEGY must be one of composer, mirror-client, found "velocita"`))
+			})
+		})
+	})
+
+	context("with hooks", func() {
+		var (
+			hook   *fakes.Hook
+			phases []string
+		)
+
+		it.Before(func() {
+			phases = nil
+			hook = &fakes.Hook{}
+			hook.BeforeInstallCall.Stub = func(context composer.HookContext) error {
+				Expect(context.ComposerPackagesLayer).To(BeNil())
+				phases = append(phases, "BeforeInstall")
+				return nil
+			}
+			hook.AfterCacheRestoreCall.Stub = func(composer.HookContext) error {
+				phases = append(phases, "AfterCacheRestore")
+				return nil
+			}
+			hook.AfterInstallCall.Stub = func(context composer.HookContext) error {
+				context.ComposerPackagesLayer.LaunchEnv.Default("SOME_HOOK_ENV", "some-value")
+				phases = append(phases, "AfterInstall")
+				return nil
+			}
+			hook.BeforeSBOMCall.Stub = func(composer.HookContext) error {
+				phases = append(phases, "BeforeSBOM")
+				return nil
+			}
+
+			dependencies.Hooks = []composer.Hook{composer.NoopHook{}, hook}
+			build = composer.Build(dependencies)
+		})
+
+		it("runs the hooks at each phase", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(phases).To(Equal([]string{"BeforeInstall", "AfterInstall", "BeforeSBOM"}))
+			Expect(result.Layers[0].LaunchEnv).To(HaveKeyWithValue("SOME_HOOK_ENV.default", "some-value"))
+
+			hookContext := hook.BeforeInstallCall.Receives.Context
+			Expect(hookContext.BuildContext.WorkingDir).To(Equal(workingDir))
+			Expect(hookContext.WorkspaceVendorDir).To(Equal(filepath.Join(workingDir, "vendor")))
+			Expect(hookContext.InstallOptions).To(HaveLen(3))
+		})
+
+		context("when reusing a cached layer", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
+					[]byte(`[metadata]
+metadata-version = 1
+stack = ""
+composer-lock-sha = "default-checksum"
+`), os.ModePerm)).To(Succeed())
+
+				Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor"), os.ModePerm)).To(Succeed())
+			})
+
+			it("runs AfterCacheRestore before AfterInstall", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(phases).To(Equal([]string{"BeforeInstall", "AfterCacheRestore", "AfterInstall", "BeforeSBOM"}))
+			})
+		})
+
+		context("when the SBOM generation is skipped", func() {
+			it("does not run BeforeSBOM", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: packit.BuildpackInfo{Name: "Some Buildpack", Version: "some-version"},
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(hook.BeforeSBOMCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when a hook fails", func() {
+			it.Before(func() {
+				hook.BeforeInstallCall.Stub = nil
+				hook.BeforeInstallCall.Returns.Error = errors.New("some-hook-error")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError("BeforeInstall hook failed: some-hook-error"))
+				Expect(composerInstallExecutable.ExecuteCall.CallCount).To(Equal(0))
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_VENDOR_NORMALIZE_PERMISSIONS set to true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_VENDOR_NORMALIZE_PERMISSIONS", "true")).To(Succeed())
+
+			composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "some-package", "bin"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "some-package", "file.php"), nil, 0600)).To(Succeed())
+				Expect(os.Chmod(filepath.Join(workingDir, "vendor", "some-package", "file.php"), 0646)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "some-package", "bin", "tool"), nil, 0700)).To(Succeed())
+				Expect(os.Chmod(filepath.Join(workingDir, "vendor", "some-package", "bin"), 0700)).To(Succeed())
+				composerInstallExecution = temp
+				return nil
+			}
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_VENDOR_NORMALIZE_PERMISSIONS")).To(Succeed())
+		})
+
+		it("makes the vendor directories readable, but not writable by everyone", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, vendorDir := range []string{filepath.Join(workingDir, "vendor"), filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor")} {
+				for path, mode := range map[string]os.FileMode{
+					filepath.Join("some-package", "file.php"):    0644,
+					filepath.Join("some-package", "bin"):         0755,
+					filepath.Join("some-package", "bin", "tool"): 0755,
+				} {
+					info, err := os.Stat(filepath.Join(vendorDir, path))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(info.Mode().Perm()).To(Equal(mode), path)
+				}
+			}
+
+			Expect(buffer.String()).To(ContainSubstring("Normalizing vendor directory permissions"))
+		})
+	})
+
+	context("with BP_COMPOSER_VENDOR_OWNER set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_VENDOR_OWNER", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_VENDOR_OWNER")).To(Succeed())
+		})
+
+		it("changes the owner of the vendor directories", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("Owner: %d:%d", os.Getuid(), os.Getgid())))
+		})
+
+		context("when the owner is invalid", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_VENDOR_OWNER", "some-user")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError(`BP_COMPOSER_VENDOR_OWNER must be of the form "uid:gid", found "some-user"`))
+			})
+		})
+	})
+
+	context("when running composer", func() {
+		it("uses a TMPDIR in an ignored layer and removes it after the build", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			tmpDir := filepath.Join(layersDir, composer.ComposerTmpLayerName, "tmp")
+			Expect(composerConfigExecution.Env).To(ContainElement(fmt.Sprintf("TMPDIR=%s", tmpDir)))
+			Expect(composerInstallExecution.Env).To(ContainElement(fmt.Sprintf("TMPDIR=%s", tmpDir)))
+			Expect(composerCheckPlatformReqsExecExecution.Env).To(ContainElement(fmt.Sprintf("TMPDIR=%s", tmpDir)))
+			Expect(tmpDir).NotTo(BeAnExistingFile())
+		})
+
+		context("with BP_COMPOSER_HERMETIC_TMPDIR set to false", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_HERMETIC_TMPDIR", "false")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_HERMETIC_TMPDIR")).To(Succeed())
+			})
+
+			it("uses the TMPDIR of the builder", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerInstallExecution.Env).NotTo(ContainElement(HavePrefix("TMPDIR=" + layersDir)))
+				Expect(filepath.Join(layersDir, composer.ComposerTmpLayerName)).NotTo(BeAnExistingFile())
+			})
+		})
+
+		context("failure cases", func() {
+			context("when BP_COMPOSER_HERMETIC_TMPDIR is not a boolean", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_HERMETIC_TMPDIR", "sometimes")).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_COMPOSER_HERMETIC_TMPDIR")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError(ContainSubstring("BP_COMPOSER_HERMETIC_TMPDIR")))
+				})
+			})
+		})
+	})
+
+	context("with BP_SBOM_FORMATS", func() {
+		it.After(func() {
+			Expect(os.Unsetenv("BP_SBOM_FORMATS")).To(Succeed())
+		})
+
+		context("when it selects some of the declared formats and SPDX tag-value", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_SBOM_FORMATS", "cyclonedx, spdx-tag-value")).To(Succeed())
+			})
+
+			it("writes the layer SBOM and the SPDX tag-value file", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				packagesLayer := result.Layers[0]
+				formats := packagesLayer.SBOM.Formats()
+				Expect(formats).To(HaveLen(1))
+				Expect(formats[0].Extension).To(Equal("cdx.json"))
+
+				content, err := os.ReadFile(filepath.Join(packagesLayer.Path, composer.SPDXTagValueFileName))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(HavePrefix("SPDXVersion: SPDX-2."))
+				Expect(string(content)).To(ContainSubstring("SPDXID: SPDXRef-DOCUMENT\n"))
+
+				Expect(packagesLayer.Metadata["sbom-formats"]).To(Equal("application/vnd.cyclonedx+json,text/spdx"))
+			})
+		})
+
+		context("when SPDX tag-value is not requested anymore", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, composer.SPDXTagValueFileName), []byte("stale"), os.ModePerm)).To(Succeed())
+				Expect(os.Setenv("BP_SBOM_FORMATS", "application/spdx+json")).To(Succeed())
+			})
+
+			it("removes the SPDX tag-value file", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(filepath.Join(layersDir, composer.ComposerPackagesLayerName, composer.SPDXTagValueFileName)).NotTo(BeAnExistingFile())
+			})
+		})
+
+		context("failure cases", func() {
+			context("when it contains an unsupported format", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_SBOM_FORMATS", "application/xml")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError(ContainSubstring(`failed to parse BP_SBOM_FORMATS: unsupported SBOM format "application/xml"`)))
+				})
+			})
+
+			context("when it contains a layer format not declared in buildpack.toml", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_SBOM_FORMATS", "syft")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError("BP_SBOM_FORMATS contains application/vnd.syft+json, which is not declared in buildpack.toml"))
+				})
+			})
+		})
+	})
+
+	context("with OTEL_EXPORTER_OTLP_ENDPOINT", func() {
+		it.Before(func() {
+			Expect(os.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "https://otel.example.com/")).To(Succeed())
+			Expect(os.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Bearer%20some-token, X-Scope=ci")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("OTEL_EXPORTER_OTLP_ENDPOINT")).To(Succeed())
+			Expect(os.Unsetenv("OTEL_EXPORTER_OTLP_HEADERS")).To(Succeed())
+		})
+
+		it("exports the spans of the build", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(spanExporter.ExportCall.CallCount).To(Equal(1))
+			export := spanExporter.ExportCall.Receives.Export
+			Expect(export.Endpoint).To(Equal("https://otel.example.com/v1/traces"))
+			Expect(export.Headers).To(Equal(map[string]string{
+				"Authorization": "Bearer some-token",
+				"X-Scope":       "ci",
+			}))
+			Expect(export.ServiceName).To(Equal(buildpackInfo.ID))
+
+			spans := map[string]composer.Span{}
+			for _, span := range export.Spans {
+				spans[span.Name] = span
+				Expect(span.TraceID).To(Equal(export.Spans[0].TraceID))
+				Expect(span.End).NotTo(BeTemporally("<", span.Start))
+			}
+			Expect(spans).To(HaveKey("build"))
+			Expect(spans).To(HaveKey("composer config"))
+			Expect(spans).To(HaveKey("composer install"))
+			Expect(spans).To(HaveKey("copy vendor"))
+			Expect(spans).To(HaveKey("generate SBOM"))
+
+			Expect(spans["build"].ParentSpanID).To(BeEmpty())
+			Expect(spans["build"].Attributes).To(HaveKeyWithValue("buildpack.version", buildpackInfo.Version))
+			Expect(spans["composer install"].ParentSpanID).To(Equal(spans["build"].SpanID))
+			Expect(spans["composer install"].Attributes).To(HaveKeyWithValue("process.working_directory", workingDir))
+			Expect(spans["copy vendor"].Attributes).To(HaveKeyWithValue("copy.source", filepath.Join(workingDir, "vendor")))
+
+			Expect(buffer.String()).To(ContainSubstring("Exporting spans to https://otel.example.com/v1/traces"))
+		})
+
+		context("when TRACEPARENT is set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("TRACEPARENT", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("TRACEPARENT")).To(Succeed())
+			})
+
+			it("nests the build into the given span", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				spans := spanExporter.ExportCall.Receives.Export.Spans
+				buildSpan := spans[len(spans)-1]
+				Expect(buildSpan.Name).To(Equal("build"))
+				Expect(buildSpan.TraceID).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+				Expect(buildSpan.ParentSpanID).To(Equal("00f067aa0ba902b7"))
+			})
+		})
+
+		context("when the build fails", func() {
+			it.Before(func() {
+				composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+					return errors.New("some-install-error")
+				}
+			})
+
+			it("exports the spans with the error", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(HaveOccurred())
+
+				spans := spanExporter.ExportCall.Receives.Export.Spans
+				buildSpan := spans[len(spans)-1]
+				Expect(buildSpan.Name).To(Equal("build"))
+				Expect(buildSpan.Err).To(MatchError(ContainSubstring("some-install-error")))
+			})
+		})
+
+		context("when the spans cannot be exported", func() {
+			it.Before(func() {
+				spanExporter.ExportCall.Returns.Error = errors.New("some-export-error")
+			})
+
+			it("does not fail the build", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buffer.String()).To(MatchRegexp(`Failed to export \d+ span\(s\): some-export-error`))
+			})
+		})
+
+		context("when OTEL_SDK_DISABLED is set to true", func() {
+			it.Before(func() {
+				Expect(os.Setenv("OTEL_SDK_DISABLED", "true")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("OTEL_SDK_DISABLED")).To(Succeed())
+			})
+
+			it("does not export any spans", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(spanExporter.ExportCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when the protocol is not supported", func() {
+			it.Before(func() {
+				Expect(os.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "grpc")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("OTEL_EXPORTER_OTLP_PROTOCOL")).To(Succeed())
+			})
+
+			it("disables tracing with a warning", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(spanExporter.ExportCall.CallCount).To(Equal(0))
+				Expect(buffer.String()).To(ContainSubstring(`WARNING: Tracing is disabled, OTEL_EXPORTER_OTLP_PROTOCOL "grpc" is not supported, only http/json is`))
+			})
+		})
+
+		context("when the protocol of the traces is not supported", func() {
+			it.Before(func() {
+				Expect(os.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/json")).To(Succeed())
+				Expect(os.Setenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "http/protobuf")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("OTEL_EXPORTER_OTLP_PROTOCOL")).To(Succeed())
+				Expect(os.Unsetenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")).To(Succeed())
+			})
+
+			it("disables tracing with a warning", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(spanExporter.ExportCall.CallCount).To(Equal(0))
+				Expect(buffer.String()).To(ContainSubstring(`WARNING: Tracing is disabled, OTEL_EXPORTER_OTLP_TRACES_PROTOCOL "http/protobuf" is not supported, only http/json is`))
+			})
+		})
+
+		context("when only the protocol of the traces is supported", func() {
+			it.Before(func() {
+				Expect(os.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "grpc")).To(Succeed())
+				Expect(os.Setenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "http/json")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("OTEL_EXPORTER_OTLP_PROTOCOL")).To(Succeed())
+				Expect(os.Unsetenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")).To(Succeed())
+			})
+
+			it("exports the spans", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(spanExporter.ExportCall.CallCount).To(Equal(1))
+				Expect(buffer.String()).NotTo(ContainSubstring("Tracing is disabled"))
+			})
+		})
+
+		context("with a credential setting in BP_COMPOSER_CONFIG", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_CONFIG", "github-oauth.github.com=some-token")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_CONFIG")).To(Succeed())
+			})
+
+			it("does not record the credentials in the spans", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				var commandLines []string
+				for _, span := range spanExporter.ExportCall.Receives.Export.Spans {
+					if commandLine, ok := span.Attributes["process.command_line"]; ok {
+						commandLines = append(commandLines, commandLine)
+					}
+				}
+				Expect(commandLines).To(ContainElement("composer config --global github-oauth.github.com [REDACTED]"))
+				Expect(strings.Join(commandLines, "\n")).NotTo(ContainSubstring("some-token"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when TRACEPARENT is invalid", func() {
+				it.Before(func() {
+					Expect(os.Setenv("TRACEPARENT", "00-invalid-01")).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("TRACEPARENT")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError(`failed to parse TRACEPARENT: invalid traceparent "00-invalid-01"`))
+				})
+			})
+		})
+	})
+
+	context("without OTEL_EXPORTER_OTLP_ENDPOINT", func() {
+		it("does not export any spans", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spanExporter.ExportCall.CallCount).To(Equal(0))
+		})
+	})
+
+	context("with BP_COMPOSER_AUTODETECT_PROCESSES set to true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_AUTODETECT_PROCESSES", "true")).To(Succeed())
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{
+	"scripts": {
+		"start": "php -S 0.0.0.0:8080 -t public",
+		"worker": ["@php bin/console messenger:consume"],
+		"serve": "MyVendor\\Server::run",
+		"test": "phpunit"
+	}
+}`), os.ModePerm)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_AUTODETECT_PROCESSES")).To(Succeed())
+		})
+
+		it("registers the conventional scripts as processes", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.Processes).To(Equal([]packit.Process{
+				{Type: "start", Command: "php -S 0.0.0.0:8080 -t public"},
+				{Type: "worker", Command: "php bin/console messenger:consume"},
+			}))
+
+			packagesLayer := result.Layers[0]
+			Expect(packagesLayer.ProcessLaunchEnv).To(Equal(map[string]packit.Environment{
+				"start": {
+					"PATH.prepend": filepath.Join(workingDir, "vendor", "bin"),
+					"PATH.delim":   ":",
+				},
+				"worker": {
+					"PATH.prepend": filepath.Join(workingDir, "vendor", "bin"),
+					"PATH.delim":   ":",
+				},
+			}))
+
+			Expect(buffer).To(ContainLines(
+				"  Detecting processes from composer.json scripts",
+				`    Skipping serve: script "serve" calls the PHP method MyVendor\Server::run, which requires composer`,
+			))
+			Expect(buffer).To(ContainLines(
+				"  Assigning launch processes:",
+				"    start:  php -S 0.0.0.0:8080 -t public",
+				fmt.Sprintf(`      PATH -> "%s:$PATH"`, filepath.Join(workingDir, "vendor", "bin")),
+				"    worker: php bin/console messenger:consume",
+				fmt.Sprintf(`      PATH -> "%s:$PATH"`, filepath.Join(workingDir, "vendor", "bin")),
+			))
+		})
+
+		context("with config.bin-dir set in composer.json", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{"config": {"bin-dir": "bin"}, "scripts": {"start": "server"}}`), os.ModePerm)).To(Succeed())
+			})
+
+			it("prepends the bin-dir to the PATH", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers[0].ProcessLaunchEnv["start"]).To(HaveKeyWithValue("PATH.prepend", filepath.Join(workingDir, "bin")))
+			})
+		})
+
+		context("without any conventional scripts", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{"scripts": {"test": "phpunit"}}`), os.ModePerm)).To(Succeed())
+			})
+
+			it("does not register any processes", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Processes).To(BeEmpty())
+				Expect(buffer).To(ContainLines(
+					"  Detecting processes from composer.json scripts",
+					"    No processes found",
+				))
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_VALIDATE_VENDOR_ONLY set to true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_VALIDATE_VENDOR_ONLY", "true")).To(Succeed())
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+	"packages": [{"name": "some/package", "version": "1.0.0"}],
+	"packages-dev": [{"name": "some/dev-package", "version": "2.0.0"}]
+}`), os.ModePerm)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_VALIDATE_VENDOR_ONLY")).To(Succeed())
+		})
+
+		context("when the vendored packages match composer.lock", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "composer"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "composer", "installed.json"), []byte(`{"packages": [{"name": "some/package", "version": "1.0.0"}], "dev": false}`), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "autoload.php"), []byte("<?php"), os.ModePerm)).To(Succeed())
+			})
+
+			it("copies the vendored packages into the layer without running composer", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+					Stack:         "some-stack",
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerConfigExecutable.ExecuteCall.CallCount).To(Equal(0))
+				Expect(composerInstallExecutable.ExecuteCall.CallCount).To(Equal(0))
+				Expect(composerCheckPlatformReqsExecExecutable.ExecuteCall.CallCount).To(Equal(0))
+				Expect(composerVersionExecutable.ExecuteCall.CallCount).To(Equal(0))
+
+				Expect(result.Layers).To(HaveLen(1))
+				packagesLayer := result.Layers[0]
+				Expect(packagesLayer.Name).To(Equal(composer.ComposerPackagesLayerName))
+				Expect(packagesLayer.Launch).To(BeTrue())
+				Expect(packagesLayer.Build).To(BeFalse())
+				Expect(packagesLayer.Cache).To(BeTrue())
+				Expect(packagesLayer.Metadata).To(HaveKeyWithValue("stack", "some-stack"))
+				Expect(packagesLayer.Metadata).To(HaveKeyWithValue("composer-lock-sha", "default-checksum"))
+				Expect(packagesLayer.Metadata).To(HaveKeyWithValue("cache-status", "vendor-only"))
+				Expect(packagesLayer.LaunchEnv).To(HaveKeyWithValue("COMPOSER_VENDOR_DIR.default", filepath.Join(workingDir, "vendor")))
+				Expect(filepath.Join(packagesLayer.Path, "vendor", "autoload.php")).To(BeARegularFile())
+
+				Expect(sbomGenerator.GenerateCall.CallCount).To(Equal(1))
+				Expect(sbomGenerator.GenerateCall.Receives.Dir).To(Equal(workingDir))
+
+				Expect(buffer).To(ContainLines(
+					fmt.Sprintf("  Validating vendored packages in %s, skipping composer", filepath.Join(workingDir, "vendor")),
+					"    All vendored packages match composer.lock",
+				))
+			})
+		})
+
+		context("when the vendored packages do not match composer.lock", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "composer"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "composer", "installed.json"), []byte(`{"packages": [{"name": "some/package", "version": "1.1.0"}]}`), os.ModePerm)).To(Succeed())
+			})
+
+			it("fails the build", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError("found 1 vendored package(s) not matching composer.lock: some/package (locked 1.0.0, vendored 1.1.0)"))
+				Expect(composerInstallExecutable.ExecuteCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when the application does not contain its vendored packages", func() {
+			it("runs composer", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(composerInstallExecutable.ExecuteCall.CallCount).To(Equal(1))
+				Expect(buffer).To(ContainLines("  No vendored packages found, running composer despite BP_COMPOSER_VALIDATE_VENDOR_ONLY"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when composer.lock does not exist", func() {
+				it.Before(func() {
+					Expect(os.Remove(filepath.Join(workingDir, "composer.lock"))).To(Succeed())
+					Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "composer"), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "composer", "installed.json"), []byte(`{"packages": []}`), os.ModePerm)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError("BP_COMPOSER_VALIDATE_VENDOR_ONLY requires a composer.lock to verify the vendored packages against"))
+				})
+			})
+		})
+	})
+
+	context("with vendored packages but without composer.lock", func() {
+		it.Before(func() {
+			Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "composer"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "composer", "installed.json"), []byte(`{"packages": [{"name": "some/package", "version": "1.0.0"}]}`), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "autoload.php"), []byte("<?php"), os.ModePerm)).To(Succeed())
+
+			installOptions.DetermineCall.Returns.InstallOptionSlice = []composer.InstallOption{
+				{Value: "--no-progress", Source: composer.InstallOptionSourceDefault},
+				{Value: "--no-dev", Source: composer.InstallOptionSourceDefault},
+				{Value: "-o", Source: composer.InstallOptionSourceEnv},
+				{Value: "--optimize-autoloader", Source: composer.InstallOptionSourceEnv},
+			}
+		})
+
+		it("only dumps the autoloader of the vendored packages", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(composerInstallExecutable.ExecuteCall.CallCount).To(Equal(1))
+			Expect(composerInstallExecution.Args).To(Equal([]string{"dump-autoload", "--no-dev", "--optimize"}))
+			Expect(composerInstallExecution.Dir).To(Equal(workingDir))
+			Expect(composerInstallExecution.Env).To(ContainElement(fmt.Sprintf("COMPOSER_VENDOR_DIR=%s", filepath.Join(workingDir, "vendor"))))
+
+			packagesLayer := result.Layers[0]
+			Expect(packagesLayer.Cache).To(BeFalse())
+			Expect(packagesLayer.Launch).To(BeTrue())
+			Expect(packagesLayer.Metadata["cache-status"]).To(Equal("vendor-preserved"))
+			Expect(filepath.Join(packagesLayer.Path, "vendor", "composer", "installed.json")).To(BeARegularFile())
+			Expect(filepath.Join(workingDir, "vendor", "autoload.php")).To(BeARegularFile())
+
+			Expect(sbomGenerator.GenerateCall.Receives.Dir).To(Equal(workingDir))
+
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("No composer.lock found, preserving the vendored packages in %s", filepath.Join(workingDir, "vendor"))))
+			Expect(buffer.String()).To(ContainSubstring("Composer packages cache: vendor-preserved"))
+			Expect(buffer.String()).NotTo(ContainSubstring("Running 'composer install"))
+		})
+
+		context("with BP_COMPOSER_PRESERVE_VENDOR set to false", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_PRESERVE_VENDOR", "false")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_PRESERVE_VENDOR")).To(Succeed())
+			})
+
+			it("runs composer install", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerInstallExecution.Args).To(Equal([]string{"install", "--no-progress", "--no-dev", "-o", "--optimize-autoloader"}))
+				Expect(buffer.String()).NotTo(ContainSubstring("preserving the vendored packages"))
+			})
+		})
+
+		context("when composer.lock exists", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{"packages": []}`), os.ModePerm)).To(Succeed())
+			})
+
+			it("runs composer install", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerInstallExecution.Args[0]).To(Equal("install"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when composer dump-autoload fails", func() {
+				it.Before(func() {
+					composerInstallExecutable.ExecuteCall.Stub = func(pexec.Execution) error {
+						return errors.New("some-dump-autoload-error")
+					}
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError(ContainSubstring("some-dump-autoload-error")))
+				})
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_ALLOWED_HOSTS", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_ALLOWED_HOSTS", "proxy.example.com *.mirror.example.com")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_ALLOWED_HOSTS")).To(Succeed())
+		})
+
+		context("when all packages are downloaded from allowed hosts", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{"packages": [{"name": "some/package", "dist": {"url": "https://proxy.example.com/some/package.zip"}}]}`), os.ModePerm)).To(Succeed())
+			})
+
+			it("runs the build", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buffer).To(ContainLines(
+					"  Checking the hosts in composer.lock",
+					"    Allowed hosts: proxy.example.com, *.mirror.example.com",
+					"    All packages are downloaded from allowed hosts",
+				))
+			})
+		})
+
+		context("when composer.lock contains URLs pointing to other hosts", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{"packages": [{"name": "some/package", "dist": {"url": "https://api.github.com/repos/some/package/zipball/abc"}}]}`), os.ModePerm)).To(Succeed())
+			})
+
+			it("fails the build before running composer install", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError("found 1 URL(s) in composer.lock pointing to hosts not allowed by BP_COMPOSER_ALLOWED_HOSTS: some/package (dist https://api.github.com/repos/some/package/zipball/abc)"))
+				Expect(composerInstallExecutable.ExecuteCall.CallCount).To(Equal(0))
+			})
+		})
+	})
+
+	context("when composer.json requires a PHP version", func() {
+		context("when the PHP on the path satisfies the requirement", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{"require": {"php": "^8.1"}}`), os.ModePerm)).To(Succeed())
+			})
+
+			it("runs the build", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(phpVersionExecutable.ExecuteCall.Receives.Execution.Args).To(Equal([]string{"-v"}))
+				Expect(buffer.String()).To(ContainSubstring("Found PHP version 8.2.12, required by composer.json: ^8.1"))
+			})
+		})
+
+		context("when the PHP on the path does not satisfy the requirement", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{"require": {"php": "^8.3"}}`), os.ModePerm)).To(Succeed())
+			})
+
+			it("fails the build before running composer install", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError(`PHP 8.2.12 on the path does not satisfy the PHP requirement "^8.3" of composer.json, install a PHP version matching "^8.3", e.g. with BP_PHP_VERSION`))
+				Expect(composerInstallExecutable.ExecuteCall.CallCount).To(Equal(0))
+			})
+
+			context("when config.platform.php overrides the PHP version", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{"require": {"php": "^8.3"}, "config": {"platform": {"php": "8.3.0"}}}`), os.ModePerm)).To(Succeed())
+				})
+
+				it("skips the check", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(phpVersionExecutable.ExecuteCall.CallCount).To(Equal(0))
+					Expect(buffer.String()).To(ContainSubstring("Skipping the PHP version check, config.platform.php overrides the PHP version with 8.3.0"))
+				})
+			})
+		})
+
+		context("when the PHP version cannot be determined", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{"require": {"php": "^8.1"}}`), os.ModePerm)).To(Succeed())
+				phpVersionExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+					_, _ = fmt.Fprint(temp.Stdout, "php: command not found")
+					return errors.New("exit status 127")
+				}
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError("failed to determine PHP version: exit status 127: php: command not found"))
+			})
+		})
+	})
+
+	context("when checking the disk space", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+	"packages": [{"name": "some/package"}, {"name": "some/other-package"}],
+	"packages-dev": [{"name": "some/dev-package"}]
+}`), os.ModePerm)).To(Succeed())
+		})
+
+		context("when the workspace and the layers are on different filesystems", func() {
+			it.Before(func() {
+				diskSpace.AvailableCall.Stub = func(path string) (uint64, uint64, error) {
+					if path == workingDir {
+						return 1, 6 * composer.EstimatedPackageSize, nil
+					}
+					return 2, 6 * composer.EstimatedPackageSize, nil
+				}
+			})
+
+			it("checks each of them", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(diskSpace.AvailableCall.CallCount).To(Equal(2))
+			})
+		})
+
+		context("when the workspace and the layers are on the same filesystem", func() {
+			it.Before(func() {
+				diskSpace.AvailableCall.Returns.Filesystem = 1
+				diskSpace.AvailableCall.Returns.Available = 6 * composer.EstimatedPackageSize
+			})
+
+			it("fails the build before running composer install", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError(fmt.Sprintf("not enough disk space to install 3 package(s): %s and %s requires an estimated 18.0 MiB, but only 12.0 MiB is available (set BP_COMPOSER_DISK_SPACE_CHECK to false to skip this check)", workingDir, layersDir)))
+				Expect(composerInstallExecutable.ExecuteCall.CallCount).To(Equal(0))
+			})
+
+			context("with BP_COMPOSER_DISK_SPACE_CHECK set to false", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_DISK_SPACE_CHECK", "false")).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_COMPOSER_DISK_SPACE_CHECK")).To(Succeed())
+				})
+
+				it("skips the check", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(diskSpace.AvailableCall.CallCount).To(Equal(0))
+				})
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the available disk space cannot be determined", func() {
+				it.Before(func() {
+					diskSpace.AvailableCall.Returns.Err = errors.New("some-statfs-error")
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError(ContainSubstring("some-statfs-error")))
+				})
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_OUTDATED_REPORT set to true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_OUTDATED_REPORT", "true")).To(Succeed())
+
+			composerOutdatedExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				composerOutdatedExecution = temp
+				_, err := fmt.Fprint(temp.Stdout, `{
+	"installed": [
+		{"name": "some/package", "version": "1.2.0", "latest": "3.0.0", "latest-status": "update-possible"},
+		{"name": "some/other-package", "version": "v2.0.0", "latest": "v2.1.0", "latest-status": "semver-safe-update"},
+		{"name": "some/current-package", "version": "1.0.0", "latest": "1.0.0", "latest-status": "up-to-date"}
+	]
+}`)
+				return err
+			}
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_OUTDATED_REPORT")).To(Succeed())
+		})
+
+		it("records the outdated direct dependencies in the layer metadata", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(composerOutdatedExecution.Args).To(Equal([]string{"outdated", "--direct", "--format=json", "--no-ansi"}))
+			Expect(composerOutdatedExecution.Dir).To(Equal(workingDir))
+			Expect(composerOutdatedExecution.Env).To(ContainElement(fmt.Sprintf("COMPOSER_VENDOR_DIR=%s", filepath.Join(workingDir, "vendor"))))
+
+			metadata := result.Layers[0].Metadata
+			Expect(metadata["outdated-direct"]).To(Equal(2))
+			Expect(metadata["outdated-major"]).To(Equal(1))
+			Expect(metadata["outdated"]).To(Equal([]map[string]interface{}{
+				{"name": "some/package", "version": "1.2.0", "latest": "3.0.0"},
+				{"name": "some/other-package", "version": "v2.0.0", "latest": "v2.1.0"},
+			}))
+
+			Expect(buffer.String()).To(ContainSubstring("2 direct package(s) outdated, 1 of them a major version behind"))
+			Expect(buffer.String()).To(ContainSubstring("some/package 1.2.0 -> 3.0.0"))
+		})
+
+		context("when composer outdated fails", func() {
+			it.Before(func() {
+				composerOutdatedExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+					return errors.New("some-outdated-error")
+				}
+			})
+
+			it("does not fail the build", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers[0].Metadata).NotTo(HaveKey("outdated-direct"))
+				Expect(buffer.String()).To(ContainSubstring("Skipping the outdated report, 'composer outdated' failed: some-outdated-error"))
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_SHOW_SUGGESTS set to true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_SHOW_SUGGESTS", "true")).To(Succeed())
+
+			composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "composer"), os.ModeDir|os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "composer", "installed.json"), []byte(`{"packages": [
+	{"name": "predis/predis", "suggest": {"ext-redis": "Faster", "ext-hello": "Greets"}},
+	{"name": "symfony/cache", "suggest": {"ext-redis": "For the Redis adapter", "doctrine/dbal": "For the DBAL adapter"}}
+]}`), os.ModePerm)).To(Succeed())
+				composerInstallExecution = temp
+				return nil
+			}
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_SHOW_SUGGESTS")).To(Succeed())
+		})
+
+		it("logs the suggested packages, which are not installed", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buffer).To(ContainLines(
+				"  Suggested by the installed packages (BP_COMPOSER_SHOW_SUGGESTS): 1 PHP extension(s), 1 package(s)",
+				"    ext-redis (suggested by predis/predis, symfony/cache)",
+				"      Faster",
+				"      For the Redis adapter",
+				"    doctrine/dbal (suggested by symfony/cache)",
+				"      For the DBAL adapter",
+			))
+			Expect(buffer.String()).NotTo(ContainSubstring("ext-hello (suggested by"))
+		})
+	})
+
+	context("with BP_COMPOSER_LICENSE_REPORT set", func() {
+		var composerLicensesExecution pexec.Execution
+
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_LICENSE_REPORT", "true")).To(Succeed())
+
+			composerLicensesExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				composerLicensesExecution = temp
+				_, err := fmt.Fprint(temp.Stdout, `{
+	"name": "some/app",
+	"dependencies": {
+		"some/package": {"version": "1.2.0", "license": ["MIT"], "homepage": "https://example.com/package"},
+		"other/package": {"version": "2.0.0", "license": ["GPL-2.0-only", "GPL-3.0-only"]}
+	}
+}`)
+				return err
+			}
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_LICENSE_REPORT")).To(Succeed())
+		})
+
+		it("writes the license report into a launch layer", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(composerLicensesExecution.Args).To(Equal([]string{"licenses", "--format=json", "--no-ansi"}))
+			Expect(composerLicensesExecution.Dir).To(Equal(workingDir))
+			Expect(composerLicensesExecution.Env).To(ContainElement(fmt.Sprintf("COMPOSER_VENDOR_DIR=%s", filepath.Join(workingDir, "vendor"))))
+
+			licensesLayer := result.Layers[len(result.Layers)-1]
+			Expect(licensesLayer.Name).To(Equal("composer-licenses"))
+			Expect(licensesLayer.Path).To(Equal(filepath.Join(layersDir, "composer-licenses")))
+			Expect(licensesLayer.Launch).To(BeTrue())
+			Expect(licensesLayer.Build).To(BeFalse())
+			Expect(licensesLayer.Cache).To(BeFalse())
+
+			markdown, err := os.ReadFile(filepath.Join(licensesLayer.Path, "licenses.md"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(markdown)).To(ContainSubstring("| other/package | 2.0.0 | GPL-2.0-only OR GPL-3.0-only |\n| [some/package](https://example.com/package) | 1.2.0 | MIT |\n"))
+
+			report, err := os.ReadFile(filepath.Join(licensesLayer.Path, "licenses.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(report)).To(ContainSubstring(`"name": "other/package"`))
+
+			Expect(buffer.String()).To(ContainSubstring("Running 'composer licenses --format=json --no-ansi'"))
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("Wrote the licenses of 2 package(s) to %s", licensesLayer.Path)))
+		})
+
+		context("when the dev packages are not installed", func() {
+			it.Before(func() {
+				installOptions.DetermineCall.Returns.InstallOptionSlice = []composer.InstallOption{
+					{Value: "--no-dev", Source: composer.InstallOptionSourceDefault},
+				}
+			})
+
+			it("leaves the dev packages out of the report", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerLicensesExecution.Args).To(Equal([]string{"licenses", "--format=json", "--no-ansi", "--no-dev"}))
+			})
+		})
+
+		context("when it is set to a relative path", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_LICENSE_REPORT", "public/legal")).To(Succeed())
+			})
+
+			it("writes the license report into the application", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(filepath.Join(workingDir, "public", "legal", "licenses.md")).To(BeARegularFile())
+				Expect(filepath.Join(workingDir, "public", "legal", "licenses.json")).To(BeARegularFile())
+				for _, layer := range result.Layers {
+					Expect(layer.Name).NotTo(Equal("composer-licenses"))
+				}
+			})
+		})
+
+		context("when it is set to false", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_LICENSE_REPORT", "false")).To(Succeed())
+			})
+
+			it("does not run composer licenses", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerLicensesExecutable.ExecuteCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when it is set to a path outside of the application", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_LICENSE_REPORT", "../legal")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError(`BP_COMPOSER_LICENSE_REPORT must be true, false or a relative path within the application, found "../legal"`))
+				})
+			})
+
+			context("when composer licenses fails", func() {
+				it.Before(func() {
+					composerLicensesExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+						return errors.New("some-licenses-error")
+					}
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError("failed to write the license report: some-licenses-error"))
+				})
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_METRICS_PATH set", func() {
+		var metricsDir string
+
+		it.Before(func() {
+			var err error
+			metricsDir, err = os.MkdirTemp("", "metrics")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.Setenv("BP_COMPOSER_METRICS_PATH", metricsDir)).To(Succeed())
+
+			composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "composer"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "composer", "installed.json"),
+					[]byte(`{"packages": [{"name": "some/package"}, {"name": "other/package"}]}`), os.ModePerm)).To(Succeed())
+				return nil
+			}
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_METRICS_PATH")).To(Succeed())
+			Expect(os.RemoveAll(metricsDir)).To(Succeed())
+		})
+
+		it("writes the metrics into the directory", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			metricsPath := filepath.Join(metricsDir, composer.MetricsFileName)
+			content, err := os.ReadFile(metricsPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring("composer_install_packages 2\n"))
+			Expect(string(content)).To(ContainSubstring(`composer_install_cache_status{status="miss"} 1`))
+			Expect(string(content)).To(ContainSubstring("composer_install_build_duration_seconds "))
+
+			info, err := os.Stat(metricsPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0644)))
+
+			entries, err := os.ReadDir(metricsDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(HaveLen(1))
+
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("Writing build metrics to %s", metricsPath)))
+		})
+
+		context("when it points to a file", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_METRICS_PATH", filepath.Join(metricsDir, "build.prom"))).To(Succeed())
+			})
+
+			it("writes the metrics into the file", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(filepath.Join(metricsDir, "build.prom")).To(BeARegularFile())
+			})
+		})
+
+		context("when its directory does not exist", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_METRICS_PATH", filepath.Join(metricsDir, "missing", "build.prom"))).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("failed to write metrics to %s", filepath.Join(metricsDir, "missing", "build.prom")))))
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_LOCK_SNAPSHOT set to true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_LOCK_SNAPSHOT", "true")).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+	"content-hash": "some-content-hash",
+	"packages": [{"name": "some/package", "version": "1.0.0", "time": "2023-01-01T00:00:00+00:00"}]
+}`), os.ModePerm)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_LOCK_SNAPSHOT")).To(Succeed())
+		})
+
+		it("writes the snapshot into the layer and its digest as image label", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			snapshot, err := os.ReadFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, composer.LockSnapshotFileName))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(snapshot)).To(MatchJSON(`{"packages": [{"name": "some/package", "version": "1.0.0"}]}`))
+
+			digest := fmt.Sprintf("sha256:%x", sha256.Sum256(snapshot))
+			Expect(result.Launch.Labels).To(Equal(map[string]string{
+				composer.LockSnapshotDigestLabel: digest,
+			}))
+			Expect(result.Layers[0].Metadata["lock-snapshot-digest"]).To(Equal(digest))
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("Digest: %s", digest)))
+		})
+
+		context("when composer.lock is not valid JSON", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`%%%`), os.ModePerm)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("failed to normalize %s", filepath.Join(workingDir, "composer.lock")))))
+			})
+		})
+	})
+
+	context("with BP_COMPOSER_PROVENANCE set to true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_PROVENANCE", "true")).To(Succeed())
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{
+	"repositories": [
+		{"type": "vcs", "url": "https://github.com/some/private-package"}
+	]
+}`), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+	"packages": [
+		{"name": "some/package", "version": "1.0.0", "dist": {"url": "https://example.com/some-package.zip", "shasum": "some-shasum"}}
+	],
+	"packages-dev": [
+		{"name": "some/dev-package", "version": "2.0.0", "dist": {"url": "https://example.com/some-dev-package.zip", "shasum": ""}}
+	]
+}`), os.ModePerm)).To(Succeed())
+
+			dependencies.Clock = chronos.NewClock(func() time.Time {
+				return time.Date(2023, 10, 6, 10, 11, 52, 0, time.UTC)
+			})
+			build = composer.Build(dependencies)
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_PROVENANCE")).To(Succeed())
+		})
+
+		it("writes the provenance statement into the layer", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					ID:      "some-buildpack-id",
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				WorkingDir: workingDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Plan:       buildpackPlan,
+				Stack:      "some-stack",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			content, err := os.ReadFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, composer.ProvenanceFileName))
+			Expect(err).NotTo(HaveOccurred())
+			var statement composer.ProvenanceStatement
+			Expect(json.Unmarshal(content, &statement)).To(Succeed())
+
+			Expect(statement.Type).To(Equal("https://in-toto.io/Statement/v1"))
+			Expect(statement.PredicateType).To(Equal("https://slsa.dev/provenance/v1"))
+			Expect(statement.Subject).To(Equal([]composer.ResourceDescriptor{
+				{Name: "composer-packages/vendor", Digest: map[string]string{"sha256": "default-checksum"}},
+			}))
+
+			buildDefinition := statement.Predicate.BuildDefinition
+			Expect(buildDefinition.BuildType).To(Equal(composer.ProvenanceBuildType))
+			Expect(buildDefinition.ExternalParameters.InstallOptions).To(Equal([]string{"options", "from", "fake"}))
+			Expect(buildDefinition.InternalParameters.Stack).To(Equal("some-stack"))
+			Expect(buildDefinition.InternalParameters.Commands).To(ContainElements(
+				composer.ProvenanceCommand{
+					Args:   []string{"config", "autoloader-suffix", composer.ComposerAutoloaderSuffix},
+					Dir:    filepath.Join(layersDir, composer.ComposerPackagesLayerName),
+					Result: "success",
+				},
+				composer.ProvenanceCommand{
+					Args:   []string{"install", "options", "from", "fake"},
+					Dir:    workingDir,
+					Result: "success",
+				},
+			))
+			Expect(buildDefinition.ResolvedDependencies).To(Equal([]composer.ResourceDescriptor{
+				{Name: "composer.lock", Digest: map[string]string{"sha256": "default-checksum"}},
+				{URI: "https://github.com/some/private-package", Annotations: map[string]string{"type": "repository"}},
+				{URI: "https://repo.packagist.org", Annotations: map[string]string{"type": "repository"}},
+				{
+					Name:        "some/package",
+					URI:         "https://example.com/some-package.zip",
+					Digest:      map[string]string{"sha1": "some-shasum"},
+					Annotations: map[string]string{"version": "1.0.0"},
+				},
+				{
+					Name:        "some/dev-package",
+					URI:         "https://example.com/some-dev-package.zip",
+					Annotations: map[string]string{"version": "2.0.0"},
+				},
+			}))
+
+			runDetails := statement.Predicate.RunDetails
+			Expect(runDetails.Builder.ID).To(Equal("some-buildpack-id@some-version"))
+			Expect(runDetails.Metadata.StartedOn).To(Equal("2023-10-06T10:11:52Z"))
+			Expect(runDetails.Metadata.FinishedOn).To(Equal("2023-10-06T10:11:52Z"))
+
+			Expect(filepath.Join(layersDir, composer.ComposerPackagesLayerName, composer.ProvenanceTimestampFileName)).NotTo(BeAnExistingFile())
+			Expect(timestamper.TimestampCall.CallCount).To(Equal(0))
+		})
+
+		context("with a credential setting in BP_COMPOSER_CONFIG", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_CONFIG", "github-oauth.github.com=some-token")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_CONFIG")).To(Succeed())
+			})
+
+			it("does not record the credentials in the provenance statement", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+					Stack:         "some-stack",
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := os.ReadFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, composer.ProvenanceFileName))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).NotTo(ContainSubstring("some-token"))
+
+				var statement composer.ProvenanceStatement
+				Expect(json.Unmarshal(content, &statement)).To(Succeed())
+				Expect(statement.Predicate.BuildDefinition.InternalParameters.Commands).To(ContainElement(
+					composer.ProvenanceCommand{
+						Args:   []string{"config", "--global", "github-oauth.github.com", "[REDACTED]"},
+						Dir:    filepath.Join(layersDir, composer.ComposerHomeLayerName),
+						Result: "success",
+					},
+				))
+			})
+		})
+
+		context("with BP_COMPOSER_PROVENANCE_TSA_URL set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_PROVENANCE_TSA_URL", "https://tsa.example.com")).To(Succeed())
+				timestamper.TimestampCall.Returns.ByteSlice = []byte("some-timestamp-response")
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_PROVENANCE_TSA_URL")).To(Succeed())
+			})
+
+			it("timestamps the provenance statement", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				statement, err := os.ReadFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, composer.ProvenanceFileName))
+				Expect(err).NotTo(HaveOccurred())
+
+				digest := sha256.Sum256(statement)
+				Expect(timestamper.TimestampCall.Receives.Url).To(Equal("https://tsa.example.com"))
+				Expect(timestamper.TimestampCall.Receives.Digest).To(Equal(digest[:]))
+
+				content, err := os.ReadFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, composer.ProvenanceTimestampFileName))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("some-timestamp-response"))
+			})
+
+			context("when the timestamp cannot be requested", func() {
+				it.Before(func() {
+					timestamper.TimestampCall.Returns.Error = errors.New("some-timestamp-error")
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError("failed to timestamp composer-provenance.intoto.json: some-timestamp-error"))
+				})
+			})
+		})
+	})
+
+	context("invokes 'composer check-platform-reqs'", func() {
+		it("generates '.php.ini.d/composer-extensions.ini'", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(composerCheckPlatformReqsExecExecution.Args[0]).To(Equal("check-platform-reqs"))
+			Expect(composerCheckPlatformReqsExecExecution.Dir).To(Equal(workingDir))
+			Expect(len(composerCheckPlatformReqsExecExecution.Env)).To(Equal(len(os.Environ()) + 7))
+
+			Expect(composerCheckPlatformReqsExecExecution.Env).To(ContainElements(
+				"COMPOSER_NO_INTERACTION=1",
+				fmt.Sprintf("PHPRC=%s", filepath.Join(layersDir, "composer-php-ini", "composer-php.ini")),
+				"PATH=fake-path-from-tests"))
+
+			Expect(filepath.Join(workingDir, ".php.ini.d", "composer-extensions.ini")).To(BeARegularFile())
+
+			contents, err := os.ReadFile(filepath.Join(workingDir, ".php.ini.d", "composer-extensions.ini"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(string(contents)).To(Equal(`extension = openssl.so
+extension = hello.so
+extension = bar.so
+`))
+
+			var contract composer.PhpExtensionsContract
+			_, err = toml.DecodeFile(filepath.Join(workingDir, ".php.ini.d", "php-extensions.toml"), &contract)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(contract).To(Equal(composer.PhpExtensionsContract{
+				Version: 1,
+				Extensions: []composer.PhpExtension{
+					{Name: "openssl", Source: composer.PhpExtensionSourceBootstrap},
+					{Name: "hello", Source: composer.PhpExtensionSourceComposer},
+					{Name: "bar", Source: composer.PhpExtensionSourceComposer},
+				},
+			}))
+		})
+
+		context("with the distribution of the build target set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("CNB_TARGET_OS", "linux")).To(Succeed())
+				Expect(os.Setenv("CNB_TARGET_ARCH", "arm64")).To(Succeed())
+				Expect(os.Setenv("CNB_TARGET_DISTRO_NAME", "ubuntu")).To(Succeed())
+				Expect(os.Setenv("CNB_TARGET_DISTRO_VERSION", "24.04")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("CNB_TARGET_OS")).To(Succeed())
+				Expect(os.Unsetenv("CNB_TARGET_ARCH")).To(Succeed())
+				Expect(os.Unsetenv("CNB_TARGET_DISTRO_NAME")).To(Succeed())
+				Expect(os.Unsetenv("CNB_TARGET_DISTRO_VERSION")).To(Succeed())
+			})
+
+			it("records the target with the extensions", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				contents, err := os.ReadFile(filepath.Join(workingDir, ".php.ini.d", "composer-extensions.ini"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`; PHP extensions required on ubuntu 24.04 (linux/arm64)
+extension = openssl.so
+extension = hello.so
+extension = bar.so
+`))
+
+				var contract composer.PhpExtensionsContract
+				_, err = toml.DecodeFile(filepath.Join(workingDir, ".php.ini.d", "php-extensions.toml"), &contract)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(contract.Target).To(Equal(&composer.BuildTarget{
+					OS:            "linux",
+					Arch:          "arm64",
+					DistroName:    "ubuntu",
+					DistroVersion: "24.04",
+				}))
+			})
+		})
+
+		context("when the output contains the requirements", func() {
+			it.Before(func() {
+				composerCheckPlatformReqsExecExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+					_, err := temp.Stdout.Write([]byte(`Checking platform requirements for packages in the vendor dir
+ext-gd       n/a   some/package requires ext-gd (^2.0)      missing
+ext-intl     n/a   other/package requires ext-intl (*)      missing
+ext-json     8.1.4                                          success
+php          8.1.4 __root__ requires php (^8.1)             success
+`))
+					return err
+				}
+			})
+
+			it("records the version constraints in php-extensions.toml", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				var contract composer.PhpExtensionsContract
+				_, err = toml.DecodeFile(filepath.Join(workingDir, ".php.ini.d", "php-extensions.toml"), &contract)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(contract.Extensions).To(Equal([]composer.PhpExtension{
+					{Name: "openssl", Source: composer.PhpExtensionSourceBootstrap},
+					{Name: "gd", Constraint: "^2.0", Source: composer.PhpExtensionSourceComposer},
+					{Name: "intl", Constraint: "*", Source: composer.PhpExtensionSourceComposer},
+				}))
+			})
+		})
+
+		context("when the output is JSON", func() {
+			it.Before(func() {
+				composerCheckPlatformReqsExecExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+					composerCheckPlatformReqsExecExecution = temp
+
+					_, err := temp.Stdout.Write([]byte(`[
+    {"name": "ext-gd", "version": "n/a", "status": "missing", "failed_requirement": {"source": "some/package", "type": "requires", "target": "ext-gd", "constraint": "^2.0"}, "provider": null},
+    {"name": "ext-json", "version": "8.1.4", "status": "success", "failed_requirement": null, "provider": null},
+    {"name": "ext-mongodb", "version": "1.2.0", "status": "failed", "failed_requirement": {"source": "other/package", "type": "requires", "target": "ext-mongodb", "constraint": "^1.15"}, "provider": null},
+    {"name": "lib-icu", "version": "n/a", "status": "missing", "failed_requirement": {"source": "other/package", "type": "requires", "target": "lib-icu", "constraint": "*"}, "provider": null},
+    {"name": "php", "version": "8.1.4", "status": "success", "failed_requirement": null, "provider": null}
+]`))
+					return err
+				}
+			})
+
+			it("records the missing extensions with their constraints", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerCheckPlatformReqsExecExecution.Args).To(Equal([]string{"check-platform-reqs", "--format=json"}))
+
+				var contract composer.PhpExtensionsContract
+				_, err = toml.DecodeFile(filepath.Join(workingDir, ".php.ini.d", "php-extensions.toml"), &contract)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(contract.Extensions).To(Equal([]composer.PhpExtension{
+					{Name: "openssl", Source: composer.PhpExtensionSourceBootstrap},
+					{Name: "gd", Constraint: "^2.0", Source: composer.PhpExtensionSourceComposer},
+				}))
+
+				Expect(buffer.String()).To(ContainSubstring(`WARNING: ext-mongodb 1.2.0 does not satisfy the constraint "^1.15" of other/package`))
+				Expect(buffer.String()).NotTo(ContainSubstring(`"failed_requirement"`))
+			})
+		})
+
+		context("when composer does not support --format", func() {
+			it.Before(func() {
+				composerCheckPlatformReqsExecExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+					composerCheckPlatformReqsExecExecution = temp
+
+					if len(temp.Args) > 1 {
+						_, err := temp.Stderr.Write([]byte(`The "--format" option does not exist.`))
+						Expect(err).NotTo(HaveOccurred())
+						return errors.New("exit status 1")
+					}
+
+					_, err := temp.Stdout.Write([]byte("ext-gd       n/a   some/package requires ext-gd (^2.0)      missing\n"))
+					return err
+				}
+			})
+
+			it("runs it again without --format and parses the text output", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerCheckPlatformReqsExecExecutable.ExecuteCall.CallCount).To(Equal(2))
+				Expect(composerCheckPlatformReqsExecExecution.Args).To(Equal([]string{"check-platform-reqs"}))
+
+				var contract composer.PhpExtensionsContract
+				_, err = toml.DecodeFile(filepath.Join(workingDir, ".php.ini.d", "php-extensions.toml"), &contract)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(contract.Extensions).To(Equal([]composer.PhpExtension{
+					{Name: "openssl", Source: composer.PhpExtensionSourceBootstrap},
+					{Name: "gd", Constraint: "^2.0", Source: composer.PhpExtensionSourceComposer},
+				}))
+
+				Expect(buffer.String()).To(ContainSubstring("Composer does not support '--format=json', parsing the text output instead"))
+			})
+		})
+
+		context("with BP_COMPOSER_PLATFORM_PHP_POLICY set", func() {
+			it.Before(func() {
+				composerCheckPlatformReqsExecExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+					_, err := temp.Stdout.Write([]byte(`[
+    {"name": "ext-json", "version": "8.1.4", "status": "success", "failed_requirement": null, "provider": null},
+    {"name": "php", "version": "8.1.4", "status": "failed", "failed_requirement": {"source": "some/package", "type": "requires", "target": "php", "constraint": "^8.2"}, "provider": null}
+]`))
+					return err
+				}
+
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+    "packages": [
+        {"name": "some/package", "require": {"php": "^8.2"}},
+        {"name": "other/package", "require": {"php": ">=7.4"}}
+    ],
+    "packages-dev": [
+        {"name": "dev/package", "require": {"php": "~8.3.0"}}
+    ]
+}`), os.ModePerm)).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_PLATFORM_PHP_POLICY")).To(Succeed())
+			})
+
+			context("to fail", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_PLATFORM_PHP_POLICY", "fail")).To(Succeed())
+				})
+
+				it("fails the build naming the packages", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError("php 8.1.4 does not satisfy the requirements of dev/package (~8.3.0), some/package (^8.2), " +
+						"install a PHP version matching the requirements, e.g. with BP_PHP_VERSION (BP_COMPOSER_PLATFORM_PHP_POLICY=fail)"))
+				})
+			})
+
+			context("to warn", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_PLATFORM_PHP_POLICY", "warn")).To(Succeed())
+				})
+
+				it("logs the requirements", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(buffer.String()).To(ContainSubstring("WARNING: php 8.1.4 does not satisfy the requirements of dev/package (~8.3.0), some/package (^8.2)"))
+				})
+			})
+
+			context("when the requirement is not locked", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_PLATFORM_PHP_POLICY", "fail")).To(Succeed())
+					Expect(os.Remove(filepath.Join(workingDir, "composer.lock"))).To(Succeed())
+
+					composerCheckPlatformReqsExecExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+						_, err := temp.Stdout.Write([]byte("php          8.1.4 __root__ requires php (^8.2)             failed\n"))
+						return err
+					}
+				})
+
+				it("names the requirement reported by composer", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError(ContainSubstring("php 8.1.4 does not satisfy the requirements of composer.json (^8.2)")))
+				})
+			})
+
+			context("when the policy is unknown", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_PLATFORM_PHP_POLICY", "strict")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError(`BP_COMPOSER_PLATFORM_PHP_POLICY must be one of "off", "warn" or "fail", found "strict"`))
+				})
+			})
+		})
+
+		context("when the output contains CRLF line endings and progress", func() {
+			it.Before(func() {
+				composerCheckPlatformReqsExecExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+					_, err := temp.Stdout.Write([]byte("Checking platform requirements...\r" +
+						"ext-gd       n/a   some/package requires ext-gd (^2.0)      missing\r\n" +
+						"ext-json     8.1.4                                          success\r\n"))
+					return err
+				}
+			})
+
+			it("records the version constraints in php-extensions.toml", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				var contract composer.PhpExtensionsContract
+				_, err = toml.DecodeFile(filepath.Join(workingDir, ".php.ini.d", "php-extensions.toml"), &contract)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(contract.Extensions).To(Equal([]composer.PhpExtension{
+					{Name: "openssl", Source: composer.PhpExtensionSourceBootstrap},
+					{Name: "gd", Constraint: "^2.0", Source: composer.PhpExtensionSourceComposer},
+				}))
+			})
+		})
+
+		context("with BP_COMPOSER_FILE_MODE set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_FILE_MODE", "0640")).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, ".php.ini.d"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, ".php.ini.d", "composer-extensions.ini"), []byte("extension = stale.so\n"), 0777)).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_FILE_MODE")).To(Succeed())
+			})
+
+			it("writes the generated files with the mode", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				for _, path := range []string{
+					filepath.Join(layersDir, "composer-php-ini", "composer-php.ini"),
+					filepath.Join(workingDir, ".php.ini.d", "composer-extensions.ini"),
+					filepath.Join(workingDir, ".php.ini.d", "php-extensions.toml"),
+				} {
+					info, err := os.Stat(path)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(info.Mode().Perm()).To(Equal(os.FileMode(0640)), path)
+				}
+
+				contents, err := os.ReadFile(filepath.Join(workingDir, ".php.ini.d", "composer-extensions.ini"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).NotTo(ContainSubstring("stale.so"))
+			})
+
+			context("when the mode is invalid", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_FILE_MODE", "rw-r--r--")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError(`BP_COMPOSER_FILE_MODE must be an octal file mode such as "0644", found "rw-r--r--"`))
+				})
+			})
+		})
+
+		context("with BP_COMPOSER_EXTENSIONS_INI set to false", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_EXTENSIONS_INI", "false")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_EXTENSIONS_INI")).To(Succeed())
+			})
+
+			it("only writes php-extensions.toml", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(filepath.Join(workingDir, ".php.ini.d", "php-extensions.toml")).To(BeARegularFile())
+				Expect(filepath.Join(workingDir, ".php.ini.d", "composer-extensions.ini")).NotTo(BeAnExistingFile())
+				Expect(buffer.String()).To(ContainSubstring("Skipping composer-extensions.ini as BP_COMPOSER_EXTENSIONS_INI is set to false"))
+			})
+		})
+
+		context("with BP_COMPOSER_PHP_INI_LAYER set to true", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_PHP_INI_LAYER", "true")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_PHP_INI_LAYER")).To(Succeed())
+			})
+
+			it("writes the INI files into the composer-packages layer", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				iniDir := filepath.Join(layersDir, composer.ComposerPackagesLayerName, "php.ini.d")
+				Expect(result.Layers[0].LaunchEnv).To(HaveKeyWithValue("PHP_INI_SCAN_DIR.append", iniDir))
+				Expect(result.Layers[0].LaunchEnv).To(HaveKeyWithValue("PHP_INI_SCAN_DIR.delim", ":"))
+
+				contents, err := os.ReadFile(filepath.Join(iniDir, "composer-extensions.ini"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`extension = openssl.so
+extension = hello.so
+extension = bar.so
+`))
+
+				contents, err = os.ReadFile(filepath.Join(iniDir, "composer-include-path.ini"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(fmt.Sprintf("include_path = ${include_path} \":%s\"\n", filepath.Join(workingDir, "vendor"))))
+
+				Expect(filepath.Join(workingDir, ".php.ini.d", "php-extensions.toml")).To(BeARegularFile())
+				Expect(filepath.Join(workingDir, ".php.ini.d", "composer-extensions.ini")).NotTo(BeAnExistingFile())
+				Expect(buffer.String()).To(ContainSubstring("Configuring PHP INI files in the composer-packages layer"))
+			})
+
+			context("when composer-packages is not required at launch", func() {
+				it.Before(func() {
+					buildpackPlan.Entries[0].Metadata["launch"] = false
+				})
+
+				it("writes composer-extensions.ini into the working directory", func() {
+					result, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(result.Layers[0].LaunchEnv).To(BeEmpty())
+					Expect(filepath.Join(workingDir, ".php.ini.d", "composer-extensions.ini")).To(BeARegularFile())
+					Expect(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "php.ini.d")).NotTo(BeAnExistingFile())
+					Expect(buffer.String()).To(ContainSubstring("Ignoring BP_COMPOSER_PHP_INI_LAYER as the composer-packages layer is not available at launch"))
+				})
+			})
+		})
+
+		context("when a previous build wrote INI files into the composer-packages layer", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "php.ini.d"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "php.ini.d", "composer-extensions.ini"), nil, 0644)).To(Succeed())
+			})
+
+			it("removes them", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "php.ini.d")).NotTo(BeAnExistingFile())
+			})
+		})
+
+		context("with BP_COMPOSER_BOOTSTRAP_EXTENSIONS set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_BOOTSTRAP_EXTENSIONS", "openssl,curl, ext-zlib")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_BOOTSTRAP_EXTENSIONS")).To(Succeed())
+			})
+
+			it("loads the bootstrap extensions for composer and at runtime", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				contents, err := os.ReadFile(filepath.Join(layersDir, "composer-php-ini", "composer-php.ini"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`[PHP]
+extension_dir = "php-extension-dir"
+extension = openssl.so
+extension = curl.so
+extension = zlib.so`))
+
+				contents, err = os.ReadFile(filepath.Join(workingDir, ".php.ini.d", "composer-extensions.ini"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`extension = openssl.so
+extension = curl.so
+extension = zlib.so
+extension = hello.so
+extension = bar.so
+`))
+			})
+		})
+
+		context("with BP_COMPOSER_EXTENSIONS_INCLUDE set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_EXTENSIONS_INCLUDE", "ext-gd bar intl")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_EXTENSIONS_INCLUDE")).To(Succeed())
+			})
+
+			it("writes the included extensions as well", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				contents, err := os.ReadFile(filepath.Join(workingDir, ".php.ini.d", "composer-extensions.ini"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`extension = openssl.so
+extension = hello.so
+extension = bar.so
+extension = gd.so
+extension = intl.so
+`))
+
+				Expect(buffer.String()).To(ContainSubstring("Including extensions 'gd, intl' as set in BP_COMPOSER_EXTENSIONS_INCLUDE"))
+			})
+
+			context("when an included extension is excluded as well", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_EXTENSIONS_EXCLUDE", "gd")).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_COMPOSER_EXTENSIONS_EXCLUDE")).To(Succeed())
+				})
+
+				it("gives precedence to the exclusion", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					contents, err := os.ReadFile(filepath.Join(workingDir, ".php.ini.d", "composer-extensions.ini"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(contents)).NotTo(ContainSubstring("gd.so"))
+					Expect(string(contents)).To(ContainSubstring("intl.so"))
+				})
+			})
+		})
+
+		context("with BP_COMPOSER_EXTENSIONS_EXCLUDE set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_EXTENSIONS_EXCLUDE", "ext-hello openssl not-found")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_EXTENSIONS_EXCLUDE")).To(Succeed())
+			})
+
+			it("does not write the excluded extensions", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				contents, err := os.ReadFile(filepath.Join(workingDir, ".php.ini.d", "composer-extensions.ini"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal("extension = bar.so\n"))
+
+				Expect(buffer.String()).To(ContainSubstring("Excluding extensions 'openssl, hello' as set in BP_COMPOSER_EXTENSIONS_EXCLUDE"))
+			})
+		})
+
+		context("with extensions provided by other buildpacks", func() {
+			it.Before(func() {
+				buildpackPlan.Entries = append(buildpackPlan.Entries, packit.BuildpackPlanEntry{
+					Name: composer.ComposerPackagesDependency,
+					Metadata: map[string]interface{}{
+						"provides-extensions": []interface{}{"ext-hello", "gd", "hello"},
+					},
+				})
+			})
+
+			it("ignores them in 'composer install'", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(composerInstallExecution.Args).To(Equal([]string{
+					"install",
+					"options",
+					"from",
+					"fake",
+					"--ignore-platform-req=ext-gd",
+					"--ignore-platform-req=ext-hello",
+				}))
+				Expect(buffer.String()).To(ContainSubstring("--ignore-platform-req=ext-gd     (extension provided by build plan metadata)"))
+			})
+
+			it("does not write them into the extensions ini", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				contents, err := os.ReadFile(filepath.Join(workingDir, ".php.ini.d", "composer-extensions.ini"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal("extension = openssl.so\nextension = bar.so\n"))
+
+				Expect(buffer.String()).To(ContainSubstring("Skipping extensions 'hello' provided by other buildpacks"))
+			})
+
+			context("when the install options ignore all platform requirements", func() {
 				it.Before(func() {
-					Expect(os.Setenv("BP_RUN_COMPOSER_INSTALL", "false")).To(Succeed())
-				})
-				it.After(func() {
-					Expect(os.Unsetenv("BP_RUN_COMPOSER_INSTALL")).To(Succeed())
+					installOptions.DetermineCall.Returns.InstallOptionSlice = []composer.InstallOption{
+						{Value: "--ignore-platform-reqs", Source: composer.InstallOptionSourceEnv},
+					}
 				})
 
-				it("replaces workspace vendor directory with cached vendor directory", func() {
-					result, err := build(packit.BuildContext{
+				it("does not add any option", func() {
+					_, err := build(packit.BuildContext{
 						BuildpackInfo: buildpackInfo,
 						WorkingDir:    workingDir,
 						Layers:        packit.Layers{Path: layersDir},
 						Plan:          buildpackPlan,
 					})
 					Expect(err).NotTo(HaveOccurred())
-					Expect(buffer).NotTo(ContainSubstring("Running 'composer install options from fake'"))
-
-					Expect(calculator.SumCall.Receives.Paths).To(Equal([]string{filepath.Join(workingDir, "composer.lock")}))
-					layers := result.Layers
-					Expect(layers).To(HaveLen(1))
-
-					packagesLayer := layers[0]
-					Expect(packagesLayer.Name).To(Equal(composer.ComposerPackagesLayerName))
-					Expect(packagesLayer.Path).To(Equal(filepath.Join(layersDir, composer.ComposerPackagesLayerName)))
-
-					Expect(packagesLayer.Build).To(BeTrue())
-					Expect(packagesLayer.Launch).To(BeTrue())
-					Expect(packagesLayer.Cache).To(BeTrue())
-
-					Expect(packagesLayer.Metadata["composer-lock-sha"]).To(Equal("sha-from-composer-lock"))
-					Expect(packagesLayer.Metadata["stack"]).To(Equal(""))
 
-					Expect(filepath.Join(workingDir, "vendor", "file.txt")).To(BeAnExistingFile())
-					Expect(filepath.Join(workingDir, "vendor", "pre-existing-file.text")).NotTo(BeAnExistingFile())
+					Expect(composerInstallExecution.Args).To(Equal([]string{"install", "--ignore-platform-reqs"}))
 				})
 			})
 		})
 	})
 
-	context("invokes 'composer check-platform-reqs'", func() {
-		it("generates '.php.ini.d/composer-extensions.ini'", func() {
-			_, err := build(packit.BuildContext{
-				BuildpackInfo: buildpackInfo,
-				WorkingDir:    workingDir,
-				Layers:        packit.Layers{Path: layersDir},
-				Plan:          buildpackPlan,
-			})
-			Expect(err).NotTo(HaveOccurred())
-
-			Expect(composerCheckPlatformReqsExecExecution.Args[0]).To(Equal("check-platform-reqs"))
-			Expect(composerCheckPlatformReqsExecExecution.Dir).To(Equal(workingDir))
-			Expect(len(composerCheckPlatformReqsExecExecution.Env)).To(Equal(len(os.Environ()) + 3))
-
-			Expect(composerCheckPlatformReqsExecExecution.Env).To(ContainElements(
-				"COMPOSER_NO_INTERACTION=1",
-				fmt.Sprintf("PHPRC=%s", filepath.Join(layersDir, "composer-php-ini", "composer-php.ini")),
-				"PATH=fake-path-from-tests"))
-
-			Expect(filepath.Join(workingDir, ".php.ini.d", "composer-extensions.ini")).To(BeARegularFile())
-
-			contents, err := os.ReadFile(filepath.Join(workingDir, ".php.ini.d", "composer-extensions.ini"))
-			Expect(err).NotTo(HaveOccurred())
-
-			Expect(string(contents)).To(Equal(`extension = openssl.so
-extension = hello.so
-extension = bar.so
-`))
-		})
-	})
-
 	context("with debug logs", func() {
 		it.Before(func() {
 			Expect(os.Setenv(composer.BpLogLevel, "DEBUG")).To(Succeed())
 			Expect(os.Setenv(composer.BpComposerInstallGlobal, "package")).To(Succeed())
+			Expect(os.Setenv("COMPOSER_AUTH", `{"github-oauth": {"github.com": "some-token"}}`)).To(Succeed())
 		})
 
 		it.After(func() {
 			Expect(os.Unsetenv(composer.BpLogLevel)).To(Succeed())
 			Expect(os.Unsetenv(composer.BpComposerInstallGlobal)).To(Succeed())
+			Expect(os.Unsetenv("COMPOSER_AUTH")).To(Succeed())
 		})
 
 		it("prints additional information", func() {
@@ -610,12 +7967,57 @@ extension = bar.so
 
 			Expect(output).To(ContainSubstring(fmt.Sprintf("Listing files in %s:", filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor"))))
 			Expect(output).To(ContainSubstring(" Generating SBOM"))
-			Expect(output).To(ContainSubstring("Running 'composer check-platform-reqs'"))
+			Expect(output).To(ContainSubstring("Running 'composer check-platform-reqs --format=json'"))
 			Expect(output).To(ContainSubstring("Found extensions 'openssl, hello, bar'"))
+
+			Expect(output).To(ContainSubstring("Executing 'composer install options from fake'"))
+			Expect(output).To(ContainSubstring(fmt.Sprintf("Working directory: %s", workingDir)))
+			Expect(output).To(ContainSubstring("COMPOSER_AUTH=[REDACTED]"))
+			Expect(output).NotTo(ContainSubstring("some-token"))
+		})
+
+		it("writes the executed commands to the layer", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: buildpackInfo,
+				WorkingDir:    workingDir,
+				Layers:        packit.Layers{Path: layersDir},
+				Plan:          buildpackPlan,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			contents, err := os.ReadFile(filepath.Join(layersDir, composer.ComposerPackagesLayerName, composer.CommandLogFileName))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(ContainSubstring("$ composer global require --no-progress package\n"))
+			Expect(string(contents)).To(ContainSubstring("$ composer config autoloader-suffix PaketoDefaultAutoloaderSuffix\n"))
+			Expect(string(contents)).To(ContainSubstring(fmt.Sprintf("$ composer install options from fake\ndir: %s\n", workingDir)))
+			Expect(string(contents)).To(ContainSubstring("$ composer check-platform-reqs --format=json\n"))
+			Expect(string(contents)).To(ContainSubstring("  COMPOSER_AUTH=[REDACTED]\n"))
+			Expect(string(contents)).To(ContainSubstring("result: success\n"))
+			Expect(string(contents)).NotTo(ContainSubstring("some-token"))
 		})
 	})
 
 	context("failure cases", func() {
+		context("when BP_COMPOSER_DENY_ABANDONED is not a boolean", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_DENY_ABANDONED", "not-a-bool")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_DENY_ABANDONED")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError(ContainSubstring(`error when parsing env var "BP_COMPOSER_DENY_ABANDONED"`)))
+			})
+		})
+
 		context("when composerGlobalExecution fails", func() {
 			it.Before(func() {
 				Expect(os.Setenv(composer.BpComposerInstallGlobal, "anything")).To(Succeed())
@@ -710,6 +8112,118 @@ extension = bar.so
 				Expect(result).To(Equal(packit.BuildResult{}))
 
 				Expect(buffer.String()).To(ContainSubstring("error message from install"))
+				Expect(buffer.String()).NotTo(ContainSubstring("running 'composer diagnose'"))
+				Expect(filepath.Join(layersDir, composer.ComposerSupportBundleLayerName)).NotTo(BeAnExistingFile())
+			})
+
+			context("with BP_COMPOSER_SUPPORT_BUNDLE set to true", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_SUPPORT_BUNDLE", "true")).To(Succeed())
+					Expect(os.Setenv("COMPOSER_AUTH", `{"github-oauth": {"github.com": "some-token"}}`)).To(Succeed())
+
+					Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{"name": "some/app"}`), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{"packages": []}`), os.ModePerm)).To(Succeed())
+
+					composerVersionExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+						composerVersionExecution = temp
+						_, _ = fmt.Fprint(temp.Stdout, "Checking platform settings: OK\n")
+						return errors.New("exit status 1")
+					}
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_COMPOSER_SUPPORT_BUNDLE")).To(Succeed())
+					Expect(os.Unsetenv("COMPOSER_AUTH")).To(Succeed())
+				})
+
+				it("writes a support bundle", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError("some error from install"))
+
+					Expect(composerVersionExecution.Args).To(Equal([]string{"diagnose"}))
+					Expect(composerVersionExecution.Dir).To(Equal(workingDir))
+
+					bundlePath := filepath.Join(layersDir, composer.ComposerSupportBundleLayerName, "support-bundle.tar.gz")
+					Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("Wrote support bundle to %s", bundlePath)))
+
+					file, err := os.Open(bundlePath)
+					Expect(err).NotTo(HaveOccurred())
+					defer file.Close()
+
+					gzipReader, err := gzip.NewReader(file)
+					Expect(err).NotTo(HaveOccurred())
+
+					contents := map[string]string{}
+					tarReader := tar.NewReader(gzipReader)
+					for {
+						header, err := tarReader.Next()
+						if err == io.EOF {
+							break
+						}
+						Expect(err).NotTo(HaveOccurred())
+
+						content, err := io.ReadAll(tarReader)
+						Expect(err).NotTo(HaveOccurred())
+						contents[header.Name] = string(content)
+					}
+
+					Expect(contents).To(HaveKeyWithValue("composer.json", `{"name": "some/app"}`))
+					Expect(contents).To(HaveKeyWithValue("composer.lock", `{"packages": []}`))
+					Expect(contents).To(HaveKeyWithValue("composer-php.ini", ContainSubstring("extension = openssl.so")))
+					Expect(contents).To(HaveKeyWithValue("composer-diagnose.txt", "Checking platform settings: OK\n\nexit status 1\n"))
+					Expect(contents).To(HaveKeyWithValue("error.txt", "some error from install\n"))
+					Expect(contents).To(HaveKeyWithValue("commands.log", ContainSubstring("$ composer install options from fake")))
+					Expect(contents).To(HaveKeyWithValue("commands.log", ContainSubstring("COMPOSER_AUTH=[REDACTED]")))
+					Expect(contents["commands.log"]).NotTo(ContainSubstring("some-token"))
+				})
+			})
+		})
+
+		context("when composerInstallExecution fails because of the network", func() {
+			var diagnoseExecution pexec.Execution
+
+			it.Before(func() {
+				composerInstallExecutable.ExecuteCall.Stub = func(temp pexec.Execution) error {
+					if temp.Args[0] == "diagnose" {
+						diagnoseExecution = temp
+						_, _ = fmt.Fprint(temp.Stdout, `Checking composer.json: OK
+Checking http connectivity to packagist: FAIL
+Checking https connectivity to packagist: OK
+Checking github.com rate limit: WARNING
+`)
+						return errors.New("exit status 1")
+					}
+
+					composerInstallExecution = temp
+					_, _ = fmt.Fprint(temp.Stderr, `curl error 6 while downloading https://repo.packagist.org/packages.json: Could not resolve host: repo.packagist.org`)
+					return errors.New("some error from install")
+				}
+			})
+
+			it("runs composer diagnose and logs its findings", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).To(MatchError("some error from install"))
+
+				Expect(diagnoseExecution.Args).To(Equal([]string{"diagnose"}))
+				Expect(diagnoseExecution.Dir).To(Equal(workingDir))
+				Expect(diagnoseExecution.Env).To(Equal(composerInstallExecution.Env))
+
+				Expect(buffer.String()).To(ContainSubstring("The failure looks network-related, running 'composer diagnose'"))
+				Expect(buffer).To(ContainLines(
+					"    Problems found by 'composer diagnose':",
+					"      Checking http connectivity to packagist: FAIL",
+					"      Checking github.com rate limit: WARNING",
+				))
 			})
 		})
 
@@ -725,7 +8239,7 @@ extension = bar.so
 					Layers:        packit.Layers{Path: layersDir},
 					Plan:          buildpackPlan,
 				})
-				Expect(err).To(MatchError(`unsupported SBOM format: 'random-format'`))
+				Expect(err).To(MatchError(ContainSubstring(`invalid sbom-formats in buildpack.toml: unsupported SBOM format "random-format"`)))
 			})
 		})
 
@@ -744,5 +8258,220 @@ extension = bar.so
 				Expect(err).To(MatchError(ContainSubstring("failed to generate SBOM")))
 			})
 		})
+
+		context("when the file system fails", func() {
+			var memoryFileSystem *fakes.FileSystem
+
+			it.Before(func() {
+				memoryFileSystem = fakes.NewFileSystem()
+
+				dependencies.FileSystem = memoryFileSystem
+				build = composer.Build(dependencies)
+			})
+
+			it("writes the php.ini of composer to the file system", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(memoryFileSystem.Files).To(HaveKeyWithValue(
+					filepath.Join(layersDir, "composer-php-ini", "composer-php.ini"),
+					[]byte("[PHP]\nextension_dir = \"php-extension-dir\"\nextension = openssl.so"),
+				))
+			})
+
+			it("writes the generated files to the file system with their mode", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: buildpackInfo,
+					WorkingDir:    workingDir,
+					Layers:        packit.Layers{Path: layersDir},
+					Plan:          buildpackPlan,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(memoryFileSystem.Files).To(HaveKey(filepath.Join(workingDir, ".php.ini.d", composer.PhpExtensionsContractFileName)))
+				Expect(memoryFileSystem.Files).To(HaveKeyWithValue(
+					filepath.Join(workingDir, ".php.ini.d", "composer-extensions.ini"),
+					[]byte("extension = openssl.so\nextension = hello.so\nextension = bar.so\n"),
+				))
+				Expect(memoryFileSystem.ChmodCall.Receives.Name).To(Equal(filepath.Join(workingDir, ".php.ini.d", "composer-extensions.ini")))
+				Expect(memoryFileSystem.ChmodCall.Receives.Mode).To(Equal(composer.DefaultGeneratedFileMode))
+			})
+
+			context("when the mode of the generated files cannot be set", func() {
+				it.Before(func() {
+					memoryFileSystem.ChmodCall.Returns.Error = errors.New("failed to chmod")
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError("failed to chmod"))
+				})
+			})
+
+			context("when the php.ini of composer cannot be written", func() {
+				it.Before(func() {
+					memoryFileSystem.WriteFileCall.Returns.Error = errors.New("failed to write")
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError("failed to write"))
+				})
+			})
+
+			context("when the global packages cannot be listed", func() {
+				it.Before(func() {
+					Expect(os.Setenv(composer.BpComposerInstallGlobal, "anything")).To(Succeed())
+					Expect(os.Setenv("BP_LOG_LEVEL", "DEBUG")).To(Succeed())
+					memoryFileSystem.ReadDirCall.Returns.Error = errors.New("failed to read dir")
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv(composer.BpComposerInstallGlobal)).To(Succeed())
+					Expect(os.Unsetenv("BP_LOG_LEVEL")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError("failed to read dir"))
+					Expect(memoryFileSystem.ReadDirCall.Receives.Name).To(Equal(filepath.Join(layersDir, composer.ComposerGlobalLayerName, "vendor", "bin")))
+				})
+			})
+
+			context("when the workspace vendor directory cannot be replaced with the cached one", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_RUN_COMPOSER_INSTALL", "false")).To(Succeed())
+
+					Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
+						[]byte(`[metadata]
+metadata-version = 1
+stack = ""
+composer-lock-sha = "default-checksum"
+`), os.ModePerm)).To(Succeed())
+					Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor"), os.ModePerm)).To(Succeed())
+					Expect(os.MkdirAll(filepath.Join(workingDir, "vendor"), os.ModePerm)).To(Succeed())
+
+					// the temporary directory is removed through the file system as well
+					Expect(os.Setenv("BP_COMPOSER_HERMETIC_TMPDIR", "false")).To(Succeed())
+					memoryFileSystem.RemoveAllCall.Returns.Error = errors.New("failed to remove")
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_RUN_COMPOSER_INSTALL")).To(Succeed())
+					Expect(os.Unsetenv("BP_COMPOSER_HERMETIC_TMPDIR")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError("failed to remove"))
+					Expect(memoryFileSystem.RemoveAllCall.Receives.Path).To(Equal(filepath.Join(workingDir, "vendor.old")))
+
+					// the cached vendor directory has been swapped in before
+					Expect(filepath.Join(workingDir, "vendor")).To(BeADirectory())
+					Expect(filepath.Join(workingDir, "vendor.new")).NotTo(BeADirectory())
+				})
+			})
+
+			context("when the workspace vendor directory cannot be moved aside", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_RUN_COMPOSER_INSTALL", "false")).To(Succeed())
+
+					Expect(os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", composer.ComposerPackagesLayerName)),
+						[]byte(`[metadata]
+metadata-version = 1
+stack = ""
+composer-lock-sha = "default-checksum"
+`), os.ModePerm)).To(Succeed())
+					Expect(os.MkdirAll(filepath.Join(layersDir, composer.ComposerPackagesLayerName, "vendor"), os.ModePerm)).To(Succeed())
+					Expect(os.MkdirAll(filepath.Join(workingDir, "vendor"), os.ModePerm)).To(Succeed())
+
+					memoryFileSystem.RenameCall.Returns.Error = errors.New("failed to rename")
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_RUN_COMPOSER_INSTALL")).To(Succeed())
+				})
+
+				it("returns an error and keeps the workspace vendor directory", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError("failed to rename"))
+					Expect(memoryFileSystem.RenameCall.Receives.Oldpath).To(Equal(filepath.Join(workingDir, "vendor")))
+					Expect(memoryFileSystem.RenameCall.Receives.Newpath).To(Equal(filepath.Join(workingDir, "vendor.old")))
+					Expect(filepath.Join(workingDir, "vendor")).To(BeADirectory())
+				})
+			})
+
+			context("when the temporary directory cannot be created", func() {
+				it.Before(func() {
+					memoryFileSystem.MkdirAllCall.Returns.Error = errors.New("failed to create")
+				})
+
+				it("returns an error before running composer", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError("failed to create"))
+					Expect(memoryFileSystem.MkdirAllCall.Receives.Path).To(Equal(filepath.Join(layersDir, composer.ComposerTmpLayerName, "tmp")))
+					Expect(composerConfigExecutable.ExecuteCall.CallCount).To(Equal(0))
+				})
+			})
+
+			context("when the layer cannot be staged", func() {
+				it.Before(func() {
+					// the temporary directory is created through the file system as well
+					Expect(os.Setenv("BP_COMPOSER_HERMETIC_TMPDIR", "false")).To(Succeed())
+					memoryFileSystem.MkdirAllCall.Returns.Error = errors.New("failed to create")
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_COMPOSER_HERMETIC_TMPDIR")).To(Succeed())
+				})
+
+				it("returns an error before running composer install", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: buildpackInfo,
+						WorkingDir:    workingDir,
+						Layers:        packit.Layers{Path: layersDir},
+						Plan:          buildpackPlan,
+					})
+					Expect(err).To(MatchError("failed to create"))
+					Expect(memoryFileSystem.MkdirAllCall.Receives.Path).To(Equal(filepath.Join(layersDir, composer.ComposerPackagesLayerName, ".staging")))
+					Expect(composerInstallExecutable.ExecuteCall.CallCount).To(Equal(0))
+				})
+			})
+		})
 	})
 }
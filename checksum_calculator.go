@@ -0,0 +1,146 @@
+package composer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// errChecksumCancelled stops walking the given paths once a file could not be
+// hashed.
+var errChecksumCancelled = errors.New("checksum calculation cancelled")
+
+// ParallelChecksumCalculator calculates the SHA-256 checksum of the given
+// files and directories. Files are hashed by a pool of workers while the
+// directories are still being walked, so that hashing large trees, such as
+// vendored packages, does not wait for the whole tree to be listed first.
+//
+// The checksums are identical to the ones of packit's fs.ChecksumCalculator,
+// so that layers cached with either of them can be reused: the checksum of a
+// single file is the checksum of its content, otherwise it is the checksum of
+// the concatenated checksums of all regular files ordered by their path.
+type ParallelChecksumCalculator struct {
+	workers int
+}
+
+// NewParallelChecksumCalculator returns a calculator hashing with the given
+// number of workers, or one worker per CPU if workers is not positive.
+func NewParallelChecksumCalculator(workers int) ParallelChecksumCalculator {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	return ParallelChecksumCalculator{
+		workers: workers,
+	}
+}
+
+type fileChecksumResult struct {
+	path     string
+	checksum string
+	err      error
+}
+
+func (c ParallelChecksumCalculator) Sum(paths ...string) (string, error) {
+	// the queues are bounded, so that memory does not grow with the number
+	// of files if walking is faster than hashing
+	files := make(chan string, c.workers*4)
+	results := make(chan fileChecksumResult, c.workers*4)
+	cancel := make(chan struct{})
+	walked := make(chan error, 1)
+
+	go func() {
+		defer close(files)
+		for _, path := range paths {
+			err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+
+				if !info.Mode().IsRegular() {
+					return nil
+				}
+
+				select {
+				case files <- path:
+					return nil
+				case <-cancel:
+					return errChecksumCancelled
+				}
+			})
+			if err != nil {
+				walked <- err
+				return
+			}
+		}
+		walked <- nil
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range files {
+				checksum, err := fileChecksum(sha256.New(), path)
+
+				select {
+				case results <- fileChecksumResult{path: path, checksum: checksum, err: err}:
+				case <-cancel:
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var checksums []fileChecksumResult
+	var hashErr error
+	for result := range results {
+		if result.err != nil {
+			if hashErr == nil {
+				hashErr = result.err
+				close(cancel)
+			}
+			continue
+		}
+		checksums = append(checksums, result)
+	}
+
+	walkErr := <-walked
+	if hashErr != nil {
+		return "", fmt.Errorf("failed to calculate checksum: %w", hashErr)
+	}
+	if walkErr != nil {
+		return "", fmt.Errorf("failed to calculate checksum: %w", walkErr)
+	}
+
+	sort.Slice(checksums, func(i, j int) bool {
+		return checksums[i].path < checksums[j].path
+	})
+
+	if len(checksums) == 1 {
+		return checksums[0].checksum, nil
+	}
+
+	hash := sha256.New()
+	for _, result := range checksums {
+		checksum, err := hex.DecodeString(result.checksum)
+		if err != nil { // untested
+			return "", err
+		}
+		hash.Write(checksum)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,85 @@
+package composer_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testParallelChecksumCalculator(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		// "a-c" sorts before "a/b", although "a" is walked first
+		Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "a", "b"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "a", "b", "file"), []byte("a/b/file"), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "a-c"), []byte("a-c"), 0644)).To(Succeed())
+		Expect(os.Symlink("a-c", filepath.Join(workingDir, "vendor", "link"))).To(Succeed())
+
+		for i := 0; i < 100; i++ {
+			Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "a", fmt.Sprintf("file-%d", i)), []byte(fmt.Sprintf("content-%d", i)), 0644)).To(Succeed())
+		}
+
+		Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte("some-lock"), 0644)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	for _, workers := range []int{0, 1, 8} {
+		workers := workers
+
+		context(fmt.Sprintf("with %d workers", workers), func() {
+			it("calculates the same checksums as packit", func() {
+				calculator := composer.NewParallelChecksumCalculator(workers)
+
+				for _, paths := range [][]string{
+					{filepath.Join(workingDir, "composer.lock")},
+					{filepath.Join(workingDir, "vendor")},
+					{filepath.Join(workingDir, "vendor"), filepath.Join(workingDir, "composer.lock")},
+					{filepath.Join(workingDir, "vendor", "a", "b")},
+				} {
+					expected, err := fs.NewChecksumCalculator().Sum(paths...)
+					Expect(err).NotTo(HaveOccurred())
+
+					checksum, err := calculator.Sum(paths...)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(checksum).To(Equal(expected), fmt.Sprint(paths))
+				}
+			})
+		})
+	}
+
+	it("returns the checksum of the content of a single file", func() {
+		checksum, err := composer.NewParallelChecksumCalculator(0).Sum(filepath.Join(workingDir, "composer.lock"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checksum).To(Equal("410a8e77c86d5268f31886a10c172c7db749adf72f3252d4e06406efdc1b6b49"))
+	})
+
+	context("failure cases", func() {
+		context("when a path does not exist", func() {
+			it("returns an error", func() {
+				_, err := composer.NewParallelChecksumCalculator(0).Sum(filepath.Join(workingDir, "vendor"), filepath.Join(workingDir, "missing"))
+				Expect(err).To(MatchError(ContainSubstring("failed to calculate checksum: lstat")))
+				Expect(err).To(MatchError(ContainSubstring("no such file or directory")))
+			})
+		})
+
+	})
+}
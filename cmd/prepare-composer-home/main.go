@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"github.com/paketo-buildpacks/composer"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// prepare-composer-home is an exec.d executable which creates COMPOSER_HOME
+// at launch, and points COMPOSER_HOME to a writable directory if it cannot be
+// created.
+// https://github.com/buildpacks/spec/blob/main/buildpack.md#execd
+func main() {
+	logger := scribe.NewEmitter(os.Stderr).WithLevel(os.Getenv(composer.BpLogLevel))
+
+	composerHome, found := os.LookupEnv("COMPOSER_HOME")
+	if !found {
+		return
+	}
+
+	composerHome = composer.PrepareComposerHome(logger, composerHome, os.TempDir())
+	if composerHome == "" {
+		return
+	}
+
+	// exec.d executables modify the environment of the launched process by
+	// writing TOML to file descriptor 3
+	err := toml.NewEncoder(os.NewFile(3, "/dev/fd/3")).Encode(map[string]string{
+		"COMPOSER_HOME": composerHome,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// refresh-autoloader is an exec.d executable which regenerates the optimized
+// Composer autoloader at launch if the application sources have changed
+// since the build.
+// https://github.com/buildpacks/spec/blob/main/buildpack.md#execd
+func main() {
+	logger := scribe.NewEmitter(os.Stderr).WithLevel(os.Getenv(composer.BpLogLevel))
+
+	if _, found := os.LookupEnv(composer.AutoloadRefreshDisabledEnv); found {
+		return
+	}
+
+	err := composer.RefreshAutoloader(
+		logger,
+		pexec.NewExecutable("composer"),
+		composer.NewParallelChecksumCalculator(0),
+		os.Getenv(composer.AutoloadAppDirEnv),
+		os.Getenv(composer.AutoloadComposerJsonEnv),
+		os.Getenv(composer.AutoloadVendorDirEnv),
+		os.Getenv(composer.AutoloadChecksumPathEnv),
+		os.Getenv("PATH"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/paketo-buildpacks/composer"
+)
+
+// run-as runs `composer install` as the user of BP_COMPOSER_RUN_AS during the
+// build, as the build cannot switch the user of an executable it runs.
+//
+//	run-as <uid>:<gid> <executable> [<arg>...]
+func main() {
+	if len(os.Args) < 3 {
+		fmt.Fprintln(os.Stderr, "usage: run-as <uid>:<gid> <executable> [<arg>...]")
+		os.Exit(2)
+	}
+
+	err := composer.RunAs(os.Args[1], os.Args[2], os.Args[3:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
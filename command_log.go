@@ -0,0 +1,173 @@
+package composer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+// CommandLogFileName is the name of the file in the composer-packages layer
+// which records all executed commands when BP_LOG_LEVEL is set to DEBUG.
+const CommandLogFileName = "commands.log"
+
+// redactedEnvPattern matches the names of environment variables which are
+// likely to contain credentials, such as COMPOSER_AUTH or GITHUB_TOKEN.
+var redactedEnvPattern = regexp.MustCompile(`(?i)(AUTH|TOKEN|SECRET|PASSWORD|PASSWD|CREDENTIAL|PRIVATE|_KEY$)`)
+
+// CommandLogEntry records a single execution of an Executable.
+type CommandLogEntry struct {
+	// Args contains the arguments of the execution, with the credentials of
+	// `composer config` redacted
+	Args []string
+	Dir  string
+
+	// Env contains the redacted environment of the execution
+	Env []string
+
+	Err error
+}
+
+func (e CommandLogEntry) String() string {
+	builder := strings.Builder{}
+
+	builder.WriteString(fmt.Sprintf("$ composer %s\n", strings.Join(e.Args, " ")))
+	builder.WriteString(fmt.Sprintf("dir: %s\n", e.Dir))
+	builder.WriteString("env:\n")
+	for _, env := range e.Env {
+		builder.WriteString(fmt.Sprintf("  %s\n", env))
+	}
+
+	if e.Err != nil {
+		builder.WriteString(fmt.Sprintf("result: %s\n", e.Err))
+	} else {
+		builder.WriteString("result: success\n")
+	}
+
+	return builder.String()
+}
+
+// CommandLog records the working directory, environment and arguments of
+// each command executed during the build. At DEBUG level, each execution is
+// logged as well.
+type CommandLog struct {
+	logger  emitter
+	entries []CommandLogEntry
+}
+
+func NewCommandLog(logger Logger) *CommandLog {
+	return newCommandLog(newEmitter(logger))
+}
+
+func newCommandLog(logger emitter) *CommandLog {
+	return &CommandLog{
+		logger: logger,
+	}
+}
+
+// Wrap returns an Executable which records each execution in the log before
+// delegating to the given Executable.
+func (l *CommandLog) Wrap(executable Executable) Executable {
+	return loggedExecutable{
+		executable: executable,
+		log:        l,
+	}
+}
+
+// Entries returns all recorded executions in order.
+func (l *CommandLog) Entries() []CommandLogEntry {
+	return l.entries
+}
+
+// Write writes all recorded executions to the given file.
+func (l *CommandLog) Write(path string) error {
+	var blocks []string
+	for _, entry := range l.entries {
+		blocks = append(blocks, entry.String())
+	}
+
+	return os.WriteFile(path, []byte(strings.Join(blocks, "\n")), 0644)
+}
+
+func (l *CommandLog) record(execution pexec.Execution) int {
+	entry := CommandLogEntry{
+		Args: redactComposerArgs(execution.Args),
+		Dir:  execution.Dir,
+		Env:  redactEnv(execution.Env),
+	}
+
+	l.logger.Debug.Subprocess("Executing 'composer %s'", strings.Join(entry.Args, " "))
+	l.logger.Debug.Action("Working directory: %s", entry.Dir)
+	l.logger.Debug.Action("Environment:")
+	for _, env := range entry.Env {
+		l.logger.Debug.Detail("%s", env)
+	}
+
+	l.entries = append(l.entries, entry)
+	return len(l.entries) - 1
+}
+
+type loggedExecutable struct {
+	executable Executable
+	log        *CommandLog
+}
+
+func (e loggedExecutable) Execute(execution pexec.Execution) error {
+	index := e.log.record(execution)
+
+	err := e.executable.Execute(execution)
+	e.log.entries[index].Err = err
+
+	// the output of the command ends with it, even without a newline
+	e.log.logger.flush()
+
+	return err
+}
+
+// redactEnv replaces the values of environment variables which are likely to
+// contain credentials, and the credentials of BP_COMPOSER_CONFIG. Later
+// values of the same variable override earlier ones, so only the effective
+// value of each variable is kept.
+func redactEnv(env []string) []string {
+	var names []string
+	values := map[string]string{}
+
+	for _, variable := range env {
+		name, value, _ := strings.Cut(variable, "=")
+
+		if _, found := values[name]; !found {
+			names = append(names, name)
+		}
+
+		if value != "" && redactedEnvPattern.MatchString(name) {
+			value = "[REDACTED]"
+		} else if name == BpComposerConfig {
+			value = redactComposerConfig(value)
+		}
+		values[name] = value
+	}
+
+	var redacted []string
+	for _, name := range names {
+		redacted = append(redacted, fmt.Sprintf("%s=%s", name, values[name]))
+	}
+
+	return redacted
+}
+
+// writeCommandLogIfRequired writes the command log into the given directory
+// if BP_LOG_LEVEL is set to DEBUG, so that it can be retrieved from the
+// image after the build.
+func writeCommandLogIfRequired(logger emitter, commandLog *CommandLog, dir string) error {
+	if os.Getenv(BpLogLevel) != "DEBUG" {
+		return nil
+	}
+
+	path := filepath.Join(dir, CommandLogFileName)
+	logger.Debug.Process("Writing %d executed command(s) to %s", len(commandLog.Entries()), path)
+
+	return commandLog.Write(path)
+}
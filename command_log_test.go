@@ -0,0 +1,120 @@
+package composer_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/paketo-buildpacks/composer/fakes"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testCommandLog(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		buffer     *bytes.Buffer
+		executable *fakes.Executable
+		commandLog *composer.CommandLog
+	)
+
+	it.Before(func() {
+		buffer = bytes.NewBuffer(nil)
+		executable = &fakes.Executable{}
+		commandLog = composer.NewCommandLog(composer.NewEmitterLogger(scribe.NewEmitter(buffer).WithLevel("DEBUG")))
+	})
+
+	it("records and redacts each execution", func() {
+		err := commandLog.Wrap(executable).Execute(pexec.Execution{
+			Args: []string{"install", "--no-dev"},
+			Dir:  "some-dir",
+			Env: []string{
+				"PATH=some-path",
+				"COMPOSER_AUTH=some-auth",
+				"GITHUB_TOKEN=some-token",
+				"SSH_PRIVATE_KEY=some-key",
+				"EMPTY_PASSWORD=",
+				"PATH=other-path",
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(executable.ExecuteCall.CallCount).To(Equal(1))
+
+		Expect(commandLog.Entries()).To(Equal([]composer.CommandLogEntry{
+			{
+				Args: []string{"install", "--no-dev"},
+				Dir:  "some-dir",
+				Env: []string{
+					"PATH=other-path",
+					"COMPOSER_AUTH=[REDACTED]",
+					"GITHUB_TOKEN=[REDACTED]",
+					"SSH_PRIVATE_KEY=[REDACTED]",
+					"EMPTY_PASSWORD=",
+				},
+			},
+		}))
+
+		Expect(buffer.String()).To(ContainSubstring("Executing 'composer install --no-dev'"))
+		Expect(buffer.String()).To(ContainSubstring("Working directory: some-dir"))
+		Expect(buffer.String()).To(ContainSubstring("GITHUB_TOKEN=[REDACTED]"))
+		Expect(buffer.String()).NotTo(ContainSubstring("some-token"))
+	})
+
+	context("when the execution fails", func() {
+		it.Before(func() {
+			executable.ExecuteCall.Returns.Err = errors.New("some-error")
+		})
+
+		it("records the error", func() {
+			err := commandLog.Wrap(executable).Execute(pexec.Execution{Args: []string{"install"}})
+			Expect(err).To(MatchError("some-error"))
+			Expect(commandLog.Entries()).To(HaveLen(1))
+			Expect(commandLog.Entries()[0].Err).To(MatchError("some-error"))
+		})
+	})
+
+	context("Write", func() {
+		var dir string
+
+		it.Before(func() {
+			var err error
+			dir, err = os.MkdirTemp("", "command-log")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		it("writes all executions to the file", func() {
+			Expect(commandLog.Wrap(executable).Execute(pexec.Execution{Args: []string{"config"}, Dir: "layer-dir", Env: []string{"A=b"}})).To(Succeed())
+
+			executable.ExecuteCall.Returns.Err = errors.New("some-error")
+			Expect(commandLog.Wrap(executable).Execute(pexec.Execution{Args: []string{"install"}, Dir: "working-dir"})).NotTo(Succeed())
+
+			path := filepath.Join(dir, composer.CommandLogFileName)
+			Expect(commandLog.Write(path)).To(Succeed())
+
+			contents, err := os.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal(`$ composer config
+dir: layer-dir
+env:
+  A=b
+result: success
+
+$ composer install
+dir: working-dir
+env:
+result: some-error
+`))
+		})
+	})
+}
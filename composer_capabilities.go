@@ -0,0 +1,167 @@
+package composer
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+var (
+	// composerCheckPlatformReqsFormatVersion is the first version of Composer
+	// supporting `composer check-platform-reqs --format`
+	composerCheckPlatformReqsFormatVersion = semver.MustParse("2.3.0")
+
+	// composerAuditVersion is the first version of Composer supporting the
+	// security audit of `composer install`, and its options
+	composerAuditVersion = semver.MustParse("2.4.0")
+
+	// composerDownloadOnlyVersion is the first version of Composer
+	// supporting `composer install --download-only`
+	composerDownloadOnlyVersion = semver.MustParse("2.0.0")
+)
+
+// composerAuditOptions are the options of `composer install`, which Composer
+// supports since composerAuditVersion.
+var composerAuditOptions = []string{"--audit", "--no-audit", "--audit-format"}
+
+// composerCapabilities describes the features of the Composer CLI which
+// depend on its version, so that the build works with Composer 2.2 LTS as
+// well as with the latest releases.
+type composerCapabilities struct {
+	// version is the version reported by `composer --version`, or empty if
+	// it could not be determined
+	version string
+
+	// versionErr is the error of `composer --version`
+	versionErr error
+
+	// effective is the version the features are derived from, or nil if it
+	// is unknown, in which case all features are assumed to be supported
+	effective *semver.Version
+}
+
+// determineComposerCapabilities runs `composer --version` once per build to
+// determine the features of the Composer CLI. If the version cannot be
+// determined, the "plugin-api-version" of `composer.lock` is used instead, as
+// it matches the minor version of the Composer which wrote it. A
+// `composer.lock` written by a newer Composer than the Composer CLI is
+// reported, as it may rely on features the CLI lacks.
+func determineComposerCapabilities(logger emitter, composerVersionExec Executable, composerPhpIniPath, path, composerLockPath string) (composerCapabilities, error) {
+	var capabilities composerCapabilities
+
+	capabilities.version, capabilities.versionErr = determineComposerVersion(logger, composerVersionExec, composerPhpIniPath, path)
+
+	pluginAPIVersion, err := readPluginAPIVersion(composerLockPath)
+	if err != nil {
+		return composerCapabilities{}, err
+	}
+
+	if capabilities.versionErr != nil {
+		logger.Debug.Process("Failed to determine the version of Composer: %s", capabilities.versionErr)
+		if pluginAPIVersion != nil {
+			logger.Debug.Subprocess("Assuming Composer %s, the plugin-api-version of composer.lock", pluginAPIVersion)
+		}
+		capabilities.effective = pluginAPIVersion
+		return capabilities, nil
+	}
+
+	capabilities.effective, err = semver.NewVersion(capabilities.version)
+	if err != nil { // untested
+		logger.Debug.Process("Failed to parse the version of Composer %q: %s", capabilities.version, err)
+		capabilities.effective = pluginAPIVersion
+		return capabilities, nil
+	}
+
+	if pluginAPIVersion != nil && minorVersion(capabilities.effective).LessThan(minorVersion(pluginAPIVersion)) {
+		logger.Process("WARNING: composer.lock has been written by Composer %d.%d (plugin-api-version %s), which is newer than Composer %s",
+			pluginAPIVersion.Major(), pluginAPIVersion.Minor(), pluginAPIVersion, capabilities.version)
+		logger.Subprocess("Options and plugins requiring the newer Composer may fail, consider updating the Composer CLI")
+		logger.Break()
+	}
+
+	return capabilities, nil
+}
+
+// readPluginAPIVersion returns the "plugin-api-version" of the given
+// `composer.lock`, or nil if there is none, e.g. in a `composer.lock`
+// written by Composer 1.
+func readPluginAPIVersion(composerLockPath string) (*semver.Version, error) {
+	content, err := readComposerFile(composerLockPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var composerLock struct {
+		PluginAPIVersion string `json:"plugin-api-version"`
+	}
+
+	// an invalid composer.lock is reported by composer itself
+	if json.Unmarshal(content, &composerLock) != nil || composerLock.PluginAPIVersion == "" {
+		return nil, nil
+	}
+
+	version, err := semver.NewVersion(composerLock.PluginAPIVersion)
+	if err != nil {
+		return nil, nil
+	}
+
+	return version, nil
+}
+
+// minorVersion returns the given version without its patch version and
+// pre-release, e.g. 2.7.0 for 2.7.0-RC1.
+func minorVersion(version *semver.Version) *semver.Version {
+	return semver.New(version.Major(), version.Minor(), 0, "", "")
+}
+
+// supports returns whether the Composer CLI is at least the given version.
+// Unknown versions are assumed to support all features.
+func (c composerCapabilities) supports(version *semver.Version) bool {
+	return c.effective == nil || !minorVersion(c.effective).LessThan(version)
+}
+
+// checkPlatformReqsFormat returns whether the Composer CLI supports
+// `composer check-platform-reqs --format`.
+func (c composerCapabilities) checkPlatformReqsFormat() bool {
+	return c.supports(composerCheckPlatformReqsFormatVersion)
+}
+
+// downloadOnly returns whether the Composer CLI supports
+// `composer install --download-only`.
+func (c composerCapabilities) downloadOnly() bool {
+	return c.supports(composerDownloadOnlyVersion)
+}
+
+// adjustInstallOptions removes the given options of `composer install`,
+// which the Composer CLI does not support, as Composer fails on unknown
+// options, e.g. `--no-audit` before Composer 2.4.
+func (c composerCapabilities) adjustInstallOptions(logger emitter, options []InstallOption) []InstallOption {
+	if c.supports(composerAuditVersion) {
+		return options
+	}
+
+	var adjusted []InstallOption
+	for _, option := range options {
+		name := strings.SplitN(option.Value, "=", 2)[0]
+
+		unsupported := false
+		for _, auditOption := range composerAuditOptions {
+			unsupported = unsupported || name == auditOption
+		}
+
+		if unsupported {
+			logger.Process("WARNING: Ignoring option '%s' (%s), Composer %s does not support it before %s", option.Value, option.Source, c.effective, composerAuditVersion)
+			continue
+		}
+
+		adjusted = append(adjusted, option)
+	}
+
+	return adjusted
+}
@@ -0,0 +1,223 @@
+package composer
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mattn/go-shellwords"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+// composerConfigKeysMetadataKey is the key of the composer-home layer
+// metadata, which lists the settings of BP_COMPOSER_CONFIG applied by the
+// previous build.
+const composerConfigKeysMetadataKey = "composer-config-keys"
+
+// composerConfigSetting is a setting of BP_COMPOSER_CONFIG, which is applied
+// with `composer config --global <key> <value>`.
+type composerConfigSetting struct {
+	key   string
+	value string
+}
+
+// lookupComposerConfig parses "BP_COMPOSER_CONFIG" as a list of `key=value`
+// pairs, e.g. "process-timeout=900 preferred-install.foo/*=source". The pairs
+// are parsed like shell words, so values can contain spaces if quoted. If a
+// key is given more than once, the last value wins.
+//
+// Returns the settings sorted by key.
+func lookupComposerConfig() ([]composerConfigSetting, error) {
+	parsed, err := shellwords.Parse(os.Getenv(BpComposerConfig))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", BpComposerConfig, err)
+	}
+
+	values := map[string]string{}
+	for _, pair := range parsed {
+		key, value, found := strings.Cut(pair, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("%s must only contain key=value pairs, found %q", BpComposerConfig, pair)
+		}
+		values[key] = value
+	}
+
+	var settings []composerConfigSetting
+	for key, value := range values {
+		settings = append(settings, composerConfigSetting{key: key, value: value})
+	}
+	sort.Slice(settings, func(i, j int) bool {
+		return settings[i].key < settings[j].key
+	})
+
+	return settings, nil
+}
+
+// composerConfigChecksum returns the checksum of the given settings, which is
+// part of the cache key of the composer-packages layer, as settings such as
+// "preferred-install" change the installed packages. Returns an empty string
+// if there are no settings, so that layers cached without any settings
+// remain valid.
+func composerConfigChecksum(settings []composerConfigSetting) string {
+	if len(settings) == 0 {
+		return ""
+	}
+
+	hash := sha256.New()
+	for _, setting := range settings {
+		fmt.Fprintf(hash, "%s=%s\n", setting.key, setting.value)
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// redactComposerArgs returns the given arguments of a `composer` command,
+// with the values of `composer config` replaced for the settings containing
+// credentials, see composerAuthKeys, e.g. the token of
+// `composer config --global github-oauth.github.com <token>`, so that they
+// can be logged and recorded.
+func redactComposerArgs(args []string) []string {
+	if len(args) == 0 || args[0] != "config" {
+		return args
+	}
+
+	redacted := append([]string{}, args...)
+	key := ""
+	for i, arg := range redacted[1:] {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		if key == "" {
+			key = arg
+			continue
+		}
+
+		if isComposerAuthKey(key) {
+			redacted[i+1] = "[REDACTED]"
+		}
+	}
+
+	return redacted
+}
+
+// redactComposerConfig returns the given value of BP_COMPOSER_CONFIG, with
+// the values of the settings containing credentials replaced, so that it can
+// be logged and recorded.
+func redactComposerConfig(value string) string {
+	parsed, err := shellwords.Parse(value)
+	if err != nil {
+		return "[REDACTED]"
+	}
+
+	var pairs []string
+	for _, pair := range parsed {
+		if key, _, found := strings.Cut(pair, "="); found && isComposerAuthKey(key) {
+			pair = key + "=[REDACTED]"
+		}
+		pairs = append(pairs, pair)
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+// isComposerAuthKey returns whether the given setting of Composer contains
+// credentials, e.g. "github-oauth.github.com" or "http-basic".
+func isComposerAuthKey(key string) bool {
+	name, _, _ := strings.Cut(key, ".")
+	for _, authKey := range composerAuthKeys {
+		if name == authKey {
+			return true
+		}
+	}
+	return false
+}
+
+// applyComposerConfigIfRequired runs `composer config --global` for each of
+// the given settings, which writes them into `config.json` of COMPOSER_HOME
+// before `composer install`.
+//
+// The composer-home layer is cached, so the settings applied by the previous
+// build are recorded in its metadata, and the ones which are no longer given
+// are removed with `composer config --global --unset`.
+func applyComposerConfigIfRequired(
+	logger emitter,
+	composerConfigExec Executable,
+	composerHomeLayer *packit.Layer,
+	settings []composerConfigSetting,
+	composerPhpIniPath,
+	path string) error {
+
+	configured := map[string]bool{}
+	var keys []string
+	for _, setting := range settings {
+		configured[setting.key] = true
+		keys = append(keys, setting.key)
+	}
+
+	var argsList [][]string
+
+	var previousKeys []string
+	switch value := composerHomeLayer.Metadata[composerConfigKeysMetadataKey].(type) {
+	case []string:
+		previousKeys = value
+	case []interface{}:
+		for _, key := range value {
+			if key, ok := key.(string); ok {
+				previousKeys = append(previousKeys, key)
+			}
+		}
+	}
+
+	for _, key := range previousKeys {
+		if !configured[key] {
+			argsList = append(argsList, []string{"config", "--global", "--unset", key})
+		}
+	}
+
+	for _, setting := range settings {
+		argsList = append(argsList, []string{"config", "--global", setting.key, setting.value})
+	}
+
+	if len(argsList) == 0 {
+		return nil
+	}
+
+	logger.Process("Applying %s", BpComposerConfig)
+
+	for _, args := range argsList {
+		logger.Subprocess("Running 'composer %s'", strings.Join(redactComposerArgs(args), " "))
+
+		err := composerConfigExec.Execute(pexec.Execution{
+			Args: args,
+			Dir:  composerHomeLayer.Path,
+			Env: append(os.Environ(),
+				"COMPOSER_NO_INTERACTION=1", // https://getcomposer.org/doc/03-cli.md#composer-no-interaction
+				fmt.Sprintf("COMPOSER_HOME=%s", composerHomeLayer.Path),
+				fmt.Sprintf("PHPRC=%s", composerPhpIniPath),
+				fmt.Sprintf("PATH=%s", path),
+			),
+			Stdout: logger.ActionWriter,
+			Stderr: logger.ActionWriter,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply %s: %w", BpComposerConfig, err)
+		}
+	}
+	logger.Break()
+
+	if composerHomeLayer.Metadata == nil {
+		composerHomeLayer.Metadata = map[string]interface{}{}
+	}
+
+	if len(keys) == 0 {
+		delete(composerHomeLayer.Metadata, composerConfigKeysMetadataKey)
+	} else {
+		composerHomeLayer.Metadata[composerConfigKeysMetadataKey] = keys
+	}
+
+	return nil
+}
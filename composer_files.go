@@ -0,0 +1,41 @@
+package composer
+
+import (
+	"bytes"
+	"os"
+	"strings"
+)
+
+// utf8BOM is the byte order mark, which editors on Windows may write at the
+// start of UTF-8 files.
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// readComposerFile reads the given `composer.json` or `composer.lock`
+// without the UTF-8 byte order mark, which JSON decoders reject. CRLF line
+// endings need no normalization, as JSON treats CR as whitespace.
+func readComposerFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimPrefix(content, utf8BOM), nil
+}
+
+// splitOutputLines splits the output of a command into lines. Besides LF,
+// CRLF and single CRs, which progress output uses to overwrite a line,
+// end a line as well.
+func splitOutputLines(output string) []string {
+	output = strings.ReplaceAll(output, "\r\n", "\n")
+	output = strings.ReplaceAll(output, "\r", "\n")
+	output = strings.TrimPrefix(output, string(utf8BOM))
+
+	return strings.Split(output, "\n")
+}
+
+// normalizeComposerPath returns the given path of `composer.json`, e.g. an
+// autoload path or "config.vendor-dir", with the backslashes of paths written
+// on Windows replaced by slashes, like Composer does.
+func normalizeComposerPath(path string) string {
+	return strings.ReplaceAll(path, `\`, "/")
+}
@@ -0,0 +1,135 @@
+package composer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// composerAuthKeys are the settings of Composer containing credentials, which
+// are written into `auth.json`, or into `config.json` if it was edited
+// directly.
+// https://getcomposer.org/doc/articles/authentication-for-private-packages.md
+var composerAuthKeys = []string{
+	"http-basic",
+	"bearer",
+	"github-oauth",
+	"gitlab-oauth",
+	"gitlab-token",
+	"bitbucket-oauth",
+}
+
+// prepareComposerHomeLayer will provide the layer used as COMPOSER_HOME for
+// `composer config` and `composer install`.
+// https://getcomposer.org/doc/03-cli.md#composer-home
+//
+// COMPOSER_HOME contains Composer's configuration (config.json), trusted keys
+// (keys.dev.pub, keys.tags.pub) and its caches, including cloned VCS
+// repositories. It is kept in a separate layer, which is never part of the
+// image. Only if env var "BP_COMPOSER_CACHE_HOME" is set to true, the layer is
+// cached, and never reset, so that its contents survive changes to
+// `composer.lock`. See scrubComposerHomeLayer for the credentials.
+func prepareComposerHomeLayer(logger emitter, context packit.BuildContext) (packit.Layer, error) {
+	cache, err := lookupBoolEnv(BpComposerCacheHome, false)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	composerHomeLayer, err := context.Layers.Get(ComposerHomeLayerName)
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+
+	err = os.MkdirAll(composerHomeLayer.Path, 0755)
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+
+	composerHomeLayer.Launch, composerHomeLayer.Build, composerHomeLayer.Cache = false, false, cache
+
+	logger.Debug.Process("Using COMPOSER_HOME %s", composerHomeLayer.Path)
+	if !cache {
+		logger.Debug.Subprocess("It is not cached, set %s to true to cache its downloads", BpComposerCacheHome)
+	}
+	logger.Debug.Break()
+
+	return composerHomeLayer, nil
+}
+
+// scrubComposerHomeLayer removes the credentials from the given COMPOSER_HOME
+// before it is cached, i.e. `auth.json`, which `composer config --global`
+// writes for settings such as "http-basic", and the same settings in
+// `config.json`. They are applied again by the next build from
+// BP_COMPOSER_CONFIG or the bindings.
+func scrubComposerHomeLayer(logger emitter, composerHomeLayer packit.Layer) error {
+	if !composerHomeLayer.Cache {
+		return nil
+	}
+
+	authPath := filepath.Join(composerHomeLayer.Path, "auth.json")
+	if exists, err := pathExists(authPath); err != nil { // untested
+		return err
+	} else if exists {
+		logger.Debug.Process("Removing %s before caching the layer", authPath)
+		logger.Debug.Break()
+
+		err = os.Remove(authPath)
+		if err != nil { // untested
+			return err
+		}
+	}
+
+	configPath := filepath.Join(composerHomeLayer.Path, "config.json")
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err // untested
+	}
+
+	var document map[string]json.RawMessage
+	err = json.Unmarshal(content, &document)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", configPath, err)
+	}
+
+	var config map[string]json.RawMessage
+	if raw, ok := document["config"]; ok {
+		err = json.Unmarshal(raw, &config)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", configPath, err)
+		}
+	}
+
+	scrubbed := false
+	for _, key := range composerAuthKeys {
+		if _, ok := config[key]; ok {
+			delete(config, key)
+			scrubbed = true
+		}
+	}
+
+	if !scrubbed {
+		return nil
+	}
+
+	logger.Debug.Process("Removing credentials from %s before caching the layer", configPath)
+	logger.Debug.Break()
+
+	document["config"], err = json.Marshal(config)
+	if err != nil { // untested
+		return err
+	}
+
+	content, err = json.MarshalIndent(document, "", "    ")
+	if err != nil { // untested
+		return err
+	}
+
+	return os.WriteFile(configPath, content, 0644)
+}
@@ -0,0 +1,192 @@
+package composer
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+// DefaultComposerDownloadURL is the location from which composer.phar is
+// downloaded, e.g. https://getcomposer.org/download/2.6.5/composer.phar
+const DefaultComposerDownloadURL = "https://getcomposer.org/download"
+
+// ComposerDownloader downloads composer.phar, for use when no `composer`
+// executable has been provided by another buildpack.
+//
+//go:generate faux --interface ComposerDownloader --output fakes/composer_downloader.go
+type ComposerDownloader interface {
+	// Download downloads the given version of composer.phar to the destination
+	Download(version, destination string) error
+
+	// PublishedChecksum returns the published SHA-256 checksum of the given
+	// version of composer.phar
+	PublishedChecksum(version string) (string, error)
+}
+
+// PharDownloader downloads composer.phar over HTTP.
+type PharDownloader struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewPharDownloader(baseURL string) PharDownloader {
+	return PharDownloader{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  http.DefaultClient,
+	}
+}
+
+func (d PharDownloader) Download(version, destination string) error {
+	response, err := d.get(fmt.Sprintf("%s/%s/composer.phar", d.baseURL, version))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	file, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, response.Body)
+	if err != nil { // untested
+		return err
+	}
+
+	return nil
+}
+
+func (d PharDownloader) PublishedChecksum(version string) (string, error) {
+	response, err := d.get(fmt.Sprintf("%s/%s/composer.phar.sha256", d.baseURL, version))
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	content, err := io.ReadAll(response.Body)
+	if err != nil { // untested
+		return "", err
+	}
+
+	// the file contains the checksum, optionally followed by the file name
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum published for composer %s", version)
+	}
+
+	return fields[0], nil
+}
+
+func (d PharDownloader) get(url string) (*http.Response, error) {
+	response, err := d.client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("failed to download %s: unexpected status %s", url, response.Status)
+	}
+
+	return response, nil
+}
+
+// provisionComposerIfRequired will check for env var "BP_COMPOSER_FALLBACK_VERSION".
+// If set, and no `composer` executable is found on the path, the given
+// version of composer.phar is downloaded into a cached layer and verified
+// against BP_COMPOSER_SHA256, or the published checksum if that is not set.
+//
+// It will return the directory containing the `composer` executable, so that
+// it can be added to the path, or an empty string if no provisioning took place.
+func provisionComposerIfRequired(
+	logger emitter,
+	context packit.BuildContext,
+	downloader ComposerDownloader,
+	path string) (composerBin string, composerFallbackLayer packit.Layer, err error) {
+	version, found := os.LookupEnv(BpComposerFallbackVersion)
+	if !found {
+		return "", packit.Layer{}, nil
+	}
+
+	if composerPath, err := lookPath("composer", path); err == nil {
+		logger.Debug.Process("Using %s, skipping the provisioning of composer %s", composerPath, version)
+		return "", packit.Layer{}, nil
+	}
+
+	logger.Process("No composer found on the path, provisioning composer %s", version)
+
+	expectedSHA256, found := os.LookupEnv(BpComposerSHA256)
+	if !found {
+		expectedSHA256, err = downloader.PublishedChecksum(version)
+		if err != nil {
+			return "", packit.Layer{}, err
+		}
+	}
+
+	composerFallbackLayer, err = context.Layers.Get(ComposerFallbackLayerName)
+	if err != nil { // untested
+		return "", packit.Layer{}, err
+	}
+
+	composerBin = filepath.Join(composerFallbackLayer.Path, "bin")
+	composerPhar := filepath.Join(composerBin, "composer")
+
+	cachedVersion, _ := composerFallbackLayer.Metadata["version"].(string)
+	cachedSHA256, _ := composerFallbackLayer.Metadata["sha256"].(string)
+	if exists, err := fs.Exists(composerPhar); err != nil {
+		return "", packit.Layer{}, err
+	} else if exists && cachedVersion == version && strings.EqualFold(cachedSHA256, expectedSHA256) {
+		logger.Subprocess("Reusing cached composer %s", version)
+		logger.Break()
+		return composerBin, composerFallbackLayer, nil
+	}
+
+	composerFallbackLayer, err = composerFallbackLayer.Reset()
+	if err != nil { // untested
+		return "", packit.Layer{}, err
+	}
+
+	err = os.MkdirAll(composerBin, 0755)
+	if err != nil { // untested
+		return "", packit.Layer{}, err
+	}
+
+	logger.Subprocess("Downloading composer %s", version)
+	err = downloader.Download(version, composerPhar)
+	if err != nil {
+		return "", packit.Layer{}, err
+	}
+
+	checksum, err := fileChecksum(sha256.New(), composerPhar)
+	if err != nil {
+		return "", packit.Layer{}, err
+	}
+
+	if !strings.EqualFold(checksum, expectedSHA256) {
+		return "", packit.Layer{}, fmt.Errorf("checksum mismatch for composer %s: expected %s, found %s", version, expectedSHA256, checksum)
+	}
+	logger.Subprocess("Verified checksum %s", checksum)
+	logger.Break()
+
+	// composer.phar starts with a `#!/usr/bin/env php` shebang
+	err = os.Chmod(composerPhar, 0755)
+	if err != nil { // untested
+		return "", packit.Layer{}, err
+	}
+
+	composerFallbackLayer.Cache = true
+	composerFallbackLayer.Metadata = map[string]interface{}{
+		"version": version,
+		"sha256":  checksum,
+	}
+
+	return composerBin, composerFallbackLayer, nil
+}
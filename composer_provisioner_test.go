@@ -0,0 +1,91 @@
+package composer_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPharDownloader(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		server     *httptest.Server
+		downloader composer.PharDownloader
+		workingDir string
+	)
+
+	it.Before(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/download/2.6.5/composer.phar":
+				fmt.Fprint(w, "composer-binary")
+			case "/download/2.6.5/composer.phar.sha256":
+				fmt.Fprint(w, "some-checksum  composer.phar\n")
+			case "/download/0.0.0/composer.phar.sha256":
+				fmt.Fprint(w, "")
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		downloader = composer.NewPharDownloader(server.URL + "/download/")
+
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		server.Close()
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("Download", func() {
+		it("downloads composer.phar", func() {
+			destination := filepath.Join(workingDir, "composer")
+			Expect(downloader.Download("2.6.5", destination)).To(Succeed())
+
+			contents, err := os.ReadFile(destination)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal("composer-binary"))
+		})
+
+		context("when the version does not exist", func() {
+			it("returns an error", func() {
+				err := downloader.Download("1.2.3", filepath.Join(workingDir, "composer"))
+				Expect(err).To(MatchError(fmt.Sprintf("failed to download %s/download/1.2.3/composer.phar: unexpected status 404 Not Found", server.URL)))
+			})
+		})
+	})
+
+	context("PublishedChecksum", func() {
+		it("returns the published checksum", func() {
+			checksum, err := downloader.PublishedChecksum("2.6.5")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(checksum).To(Equal("some-checksum"))
+		})
+
+		context("when the published checksum is empty", func() {
+			it("returns an error", func() {
+				_, err := downloader.PublishedChecksum("0.0.0")
+				Expect(err).To(MatchError("empty checksum published for composer 0.0.0"))
+			})
+		})
+
+		context("when the version does not exist", func() {
+			it("returns an error", func() {
+				_, err := downloader.PublishedChecksum("1.2.3")
+				Expect(err).To(MatchError(ContainSubstring("unexpected status 404 Not Found")))
+			})
+		})
+	})
+}
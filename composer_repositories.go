@@ -0,0 +1,378 @@
+package composer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// RepositoriesBindingEntry is the entry of a "composer-repositories"
+	// binding describing the repositories
+	RepositoriesBindingEntry = "repositories.yaml"
+
+	// composerRepositoriesMetadataKey is the key of the composer-home layer
+	// metadata, which lists the repositories written into `config.json` by
+	// the previous build.
+	composerRepositoriesMetadataKey = "composer-repositories"
+
+	// composerAuth is the environment variable read by composer for the
+	// credentials of repositories, in the format of `auth.json`.
+	// https://getcomposer.org/doc/03-cli.md#composer-auth
+	composerAuth = "COMPOSER_AUTH"
+)
+
+// ComposerRepository is a repository of a `repositories.yaml`.
+// https://getcomposer.org/doc/05-repositories.md
+type ComposerRepository struct {
+	Name string `yaml:"name"`
+
+	// Type defaults to "composer"
+	Type string `yaml:"type"`
+	URL  string `yaml:"url"`
+
+	// Priority orders the repositories, composer looks for packages in the
+	// repositories with higher priority first
+	Priority int `yaml:"priority"`
+
+	// Canonical, Only and Exclude filter the packages of the repository.
+	// https://getcomposer.org/doc/articles/repository-priorities.md
+	Canonical *bool    `yaml:"canonical"`
+	Only      []string `yaml:"only"`
+	Exclude   []string `yaml:"exclude"`
+
+	Auth *RepositoryAuth `yaml:"auth"`
+}
+
+// RepositoryAuth are the credentials of a repository. Type is one of the
+// authentication types of composer, i.e. "http-basic", "bearer",
+// "github-oauth", "gitlab-oauth" or "gitlab-token". It defaults to
+// "http-basic" if a username is given, and to "bearer" otherwise.
+// https://getcomposer.org/doc/articles/authentication-for-private-packages.md
+type RepositoryAuth struct {
+	Type     string `yaml:"type"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Token    string `yaml:"token"`
+}
+
+// RepositoriesFile is the content of a `repositories.yaml`, e.g.
+//
+//	packagist: false
+//	repositories:
+//	  - name: satis
+//	    url: https://satis.example.com
+//	    priority: 10
+//	    auth:
+//	      username: ci
+//	      password: secret
+type RepositoriesFile struct {
+	// Packagist can be set to false to disable packagist.org
+	Packagist    *bool                `yaml:"packagist"`
+	Repositories []ComposerRepository `yaml:"repositories"`
+}
+
+// ParseRepositoriesFile parses and validates a `repositories.yaml`. Unknown
+// keys are rejected, so that typos in credentials do not go unnoticed.
+func ParseRepositoriesFile(content []byte) (RepositoriesFile, error) {
+	var file RepositoriesFile
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	err := decoder.Decode(&file)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return RepositoriesFile{}, err
+	}
+
+	for i := range file.Repositories {
+		repository := &file.Repositories[i]
+
+		if repository.Name == "" {
+			return RepositoriesFile{}, fmt.Errorf("repository %d has no name", i+1)
+		}
+
+		if repository.URL == "" {
+			return RepositoriesFile{}, fmt.Errorf("repository %q has no url", repository.Name)
+		}
+
+		if repository.Type == "" {
+			repository.Type = "composer"
+		}
+
+		if repository.Auth == nil {
+			continue
+		}
+
+		auth := repository.Auth
+		if auth.Type == "" {
+			auth.Type = "bearer"
+			if auth.Username != "" {
+				auth.Type = "http-basic"
+			}
+		}
+
+		switch auth.Type {
+		case "http-basic":
+			if auth.Username == "" || auth.Password == "" {
+				return RepositoriesFile{}, fmt.Errorf("repository %q requires a username and a password for %s", repository.Name, auth.Type)
+			}
+		case "bearer", "github-oauth", "gitlab-oauth", "gitlab-token":
+			if auth.Token == "" {
+				return RepositoriesFile{}, fmt.Errorf("repository %q requires a token for %s", repository.Name, auth.Type)
+			}
+		default:
+			return RepositoriesFile{}, fmt.Errorf("repository %q has an unsupported auth type %q", repository.Name, auth.Type)
+		}
+
+		if _, ok := urlHostname(repository.URL); !ok {
+			return RepositoriesFile{}, fmt.Errorf("repository %q requires a url with a host for its credentials", repository.Name)
+		}
+	}
+
+	return file, nil
+}
+
+// ComposerRepositories returns the repositories in the format of the
+// "repositories" of `config.json`, ordered by descending priority. Composer
+// looks for packages in the order of the repositories, with packagist.org
+// last.
+func (f RepositoriesFile) ComposerRepositories() []interface{} {
+	repositories := append([]ComposerRepository{}, f.Repositories...)
+	sort.SliceStable(repositories, func(i, j int) bool {
+		return repositories[i].Priority > repositories[j].Priority
+	})
+
+	var result []interface{}
+	for _, repository := range repositories {
+		entry := map[string]interface{}{
+			"type": repository.Type,
+			"url":  repository.URL,
+		}
+
+		if repository.Canonical != nil {
+			entry["canonical"] = *repository.Canonical
+		}
+
+		if len(repository.Only) > 0 {
+			entry["only"] = repository.Only
+		}
+
+		if len(repository.Exclude) > 0 {
+			entry["exclude"] = repository.Exclude
+		}
+
+		result = append(result, entry)
+	}
+
+	if f.Packagist != nil && !*f.Packagist {
+		result = append(result, map[string]interface{}{"packagist.org": false})
+	}
+
+	return result
+}
+
+// ComposerAuth returns the credentials of the repositories in the format of
+// `auth.json`, keyed by the hostname of the repositories.
+func (f RepositoriesFile) ComposerAuth() map[string]map[string]interface{} {
+	auth := map[string]map[string]interface{}{}
+
+	for _, repository := range f.Repositories {
+		if repository.Auth == nil {
+			continue
+		}
+
+		hostname, _ := urlHostname(repository.URL)
+
+		if auth[repository.Auth.Type] == nil {
+			auth[repository.Auth.Type] = map[string]interface{}{}
+		}
+
+		if repository.Auth.Type == "http-basic" {
+			auth[repository.Auth.Type][hostname] = map[string]string{
+				"username": repository.Auth.Username,
+				"password": repository.Auth.Password,
+			}
+		} else {
+			auth[repository.Auth.Type][hostname] = repository.Auth.Token
+		}
+	}
+
+	return auth
+}
+
+// composerRepositories are the repositories of all "composer-repositories"
+// bindings.
+type composerRepositories struct {
+	file RepositoriesFile
+
+	// env passes the credentials with COMPOSER_AUTH, so that they are never
+	// written into a layer
+	env []string
+}
+
+// resolveComposerRepositories will check for service bindings of type
+// "composer-repositories". The repositories of their `repositories.yaml`
+// entries are combined, in the order of the binding names.
+func resolveComposerRepositories(logger emitter, context packit.BuildContext, bindingResolver BindingResolver) (composerRepositories, error) {
+	bindings, err := bindingResolver.Resolve(ComposerRepositoriesBindingType, "", context.Platform.Path)
+	if err != nil {
+		return composerRepositories{}, err
+	}
+
+	if len(bindings) == 0 {
+		return composerRepositories{}, nil
+	}
+
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].Name < bindings[j].Name
+	})
+
+	logger.Process("Configuring Composer repositories")
+
+	var combined RepositoriesFile
+	names := map[string]string{}
+	for _, binding := range bindings {
+		logger.Subprocess("Using binding '%s'", binding.Name)
+
+		entry, ok := binding.Entries[RepositoriesBindingEntry]
+		if !ok {
+			return composerRepositories{}, fmt.Errorf("binding %q of type %q has no entry %q", binding.Name, ComposerRepositoriesBindingType, RepositoriesBindingEntry)
+		}
+
+		content, err := entry.ReadBytes()
+		if err != nil {
+			return composerRepositories{}, fmt.Errorf("failed to read entry %q of binding %q: %w", RepositoriesBindingEntry, binding.Name, err)
+		}
+
+		file, err := ParseRepositoriesFile(content)
+		if err != nil {
+			return composerRepositories{}, fmt.Errorf("failed to parse %s of binding %q: %w", RepositoriesBindingEntry, binding.Name, err)
+		}
+
+		for _, repository := range file.Repositories {
+			if other, found := names[repository.Name]; found {
+				return composerRepositories{}, fmt.Errorf("repository %q is defined by both binding %q and binding %q", repository.Name, other, binding.Name)
+			}
+			names[repository.Name] = binding.Name
+		}
+
+		combined.Repositories = append(combined.Repositories, file.Repositories...)
+		if file.Packagist != nil && (combined.Packagist == nil || !*file.Packagist) {
+			combined.Packagist = file.Packagist
+		}
+	}
+
+	repositories := composerRepositories{file: combined}
+
+	for _, repository := range combined.Repositories {
+		logger.Subprocess("- %s: %s %s (priority %d)", repository.Name, repository.Type, repository.URL, repository.Priority)
+	}
+
+	if combined.Packagist != nil && !*combined.Packagist {
+		logger.Subprocess("packagist.org is disabled")
+	}
+
+	auth := combined.ComposerAuth()
+	if len(auth) > 0 {
+		env, err := mergeComposerAuth(auth)
+		if err != nil {
+			return composerRepositories{}, err
+		}
+
+		repositories.env = []string{env}
+		logger.Subprocess("Passing the credentials with %s", composerAuth)
+	}
+
+	logger.Break()
+
+	return repositories, nil
+}
+
+// mergeComposerAuth adds the given credentials to the ones already given by
+// COMPOSER_AUTH, if any. The credentials of the bindings take precedence.
+func mergeComposerAuth(auth map[string]map[string]interface{}) (string, error) {
+	merged := map[string]map[string]interface{}{}
+
+	if existing, ok := os.LookupEnv(composerAuth); ok && existing != "" {
+		err := json.Unmarshal([]byte(existing), &merged)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse %s: %w", composerAuth, err)
+		}
+	}
+
+	for typ, hosts := range auth {
+		if merged[typ] == nil {
+			merged[typ] = map[string]interface{}{}
+		}
+		for hostname, credentials := range hosts {
+			merged[typ][hostname] = credentials
+		}
+	}
+
+	content, err := json.Marshal(merged)
+	if err != nil { // untested
+		return "", err
+	}
+
+	return fmt.Sprintf("%s=%s", composerAuth, content), nil
+}
+
+// apply writes the repositories into `config.json` of COMPOSER_HOME, which
+// composer merges with the repositories of `composer.json`. The composer-home
+// layer is cached, so the repositories written by a previous build are
+// removed if there are no bindings anymore.
+func (r composerRepositories) apply(composerHomeLayer *packit.Layer) error {
+	entries := r.file.ComposerRepositories()
+
+	_, previouslyApplied := composerHomeLayer.Metadata[composerRepositoriesMetadataKey]
+	if len(entries) == 0 && !previouslyApplied {
+		return nil
+	}
+
+	configPath := filepath.Join(composerHomeLayer.Path, "config.json")
+
+	config := map[string]interface{}{}
+	content, err := os.ReadFile(configPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) { // untested
+		return err
+	}
+
+	if len(content) > 0 {
+		err = json.Unmarshal(content, &config)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", configPath, err)
+		}
+	}
+
+	if composerHomeLayer.Metadata == nil {
+		composerHomeLayer.Metadata = map[string]interface{}{}
+	}
+
+	if len(entries) == 0 {
+		delete(config, "repositories")
+		delete(composerHomeLayer.Metadata, composerRepositoriesMetadataKey)
+	} else {
+		config["repositories"] = entries
+
+		names := []string{}
+		for _, repository := range r.file.Repositories {
+			names = append(names, repository.Name)
+		}
+		composerHomeLayer.Metadata[composerRepositoriesMetadataKey] = names
+	}
+
+	content, err = json.MarshalIndent(config, "", "    ")
+	if err != nil { // untested
+		return err
+	}
+
+	return os.WriteFile(configPath, append(content, '\n'), 0644)
+}
@@ -0,0 +1,104 @@
+package composer_test
+
+import (
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testComposerRepositories(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("ParseRepositoriesFile", func() {
+		it("parses the repositories and applies the defaults", func() {
+			file, err := composer.ParseRepositoriesFile([]byte(`
+packagist: false
+repositories:
+  - name: vcs
+    type: vcs
+    url: https://gitlab.example.com/acme/legacy.git
+    auth:
+      type: gitlab-token
+      token: some-gitlab-token
+  - name: satis
+    url: https://satis.example.com
+    priority: 10
+    canonical: false
+    only: ["acme/*"]
+    auth:
+      username: ci
+      password: some-password
+  - name: private-packagist
+    url: https://repo.packagist.com/acme/
+    priority: 20
+    exclude: ["acme/legacy"]
+    auth:
+      token: some-token
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(file.Repositories).To(HaveLen(3))
+			Expect(file.Repositories[1].Type).To(Equal("composer"))
+			Expect(file.Repositories[1].Auth.Type).To(Equal("http-basic"))
+			Expect(file.Repositories[2].Auth.Type).To(Equal("bearer"))
+
+			Expect(file.ComposerRepositories()).To(Equal([]interface{}{
+				map[string]interface{}{"type": "composer", "url": "https://repo.packagist.com/acme/", "exclude": []string{"acme/legacy"}},
+				map[string]interface{}{"type": "composer", "url": "https://satis.example.com", "canonical": false, "only": []string{"acme/*"}},
+				map[string]interface{}{"type": "vcs", "url": "https://gitlab.example.com/acme/legacy.git"},
+				map[string]interface{}{"packagist.org": false},
+			}))
+
+			Expect(file.ComposerAuth()).To(Equal(map[string]map[string]interface{}{
+				"gitlab-token": {"gitlab.example.com": "some-gitlab-token"},
+				"http-basic":   {"satis.example.com": map[string]string{"username": "ci", "password": "some-password"}},
+				"bearer":       {"repo.packagist.com": "some-token"},
+			}))
+		})
+
+		it("accepts an empty file", func() {
+			file, err := composer.ParseRepositoriesFile([]byte(""))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(file.ComposerRepositories()).To(BeEmpty())
+		})
+
+		context("failure cases", func() {
+			it("rejects unknown keys", func() {
+				_, err := composer.ParseRepositoriesFile([]byte(`
+repositories:
+  - name: satis
+    url: https://satis.example.com
+    auth:
+      user: ci
+`))
+				Expect(err).To(MatchError(ContainSubstring("field user not found")))
+			})
+
+			it("requires a name and a url", func() {
+				_, err := composer.ParseRepositoriesFile([]byte(`repositories: [{url: "https://satis.example.com"}]`))
+				Expect(err).To(MatchError("repository 1 has no name"))
+
+				_, err = composer.ParseRepositoriesFile([]byte(`repositories: [{name: satis}]`))
+				Expect(err).To(MatchError(`repository "satis" has no url`))
+			})
+
+			it("requires complete credentials", func() {
+				_, err := composer.ParseRepositoriesFile([]byte(`repositories: [{name: satis, url: "https://satis.example.com", auth: {username: ci}}]`))
+				Expect(err).To(MatchError(`repository "satis" requires a username and a password for http-basic`))
+
+				_, err = composer.ParseRepositoriesFile([]byte(`repositories: [{name: satis, url: "https://satis.example.com", auth: {type: github-oauth}}]`))
+				Expect(err).To(MatchError(`repository "satis" requires a token for github-oauth`))
+
+				_, err = composer.ParseRepositoriesFile([]byte(`repositories: [{name: satis, url: "https://satis.example.com", auth: {type: digest, token: x}}]`))
+				Expect(err).To(MatchError(`repository "satis" has an unsupported auth type "digest"`))
+			})
+
+			it("requires a host for credentials", func() {
+				_, err := composer.ParseRepositoriesFile([]byte(`repositories: [{name: local, type: path, url: "../packages/*", auth: {token: x}}]`))
+				Expect(err).To(MatchError(`repository "local" requires a url with a host for its credentials`))
+			})
+		})
+	})
+}
@@ -0,0 +1,327 @@
+package composer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
+)
+
+const (
+	// sslCertFile is the environment variable read by OpenSSL to locate the
+	// system CA bundle
+	sslCertFile = "SSL_CERT_FILE"
+
+	// opensslConf is the environment variable read by OpenSSL to locate its
+	// configuration file
+	opensslConf = "OPENSSL_CONF"
+)
+
+// systemCABundles are the locations of the system CA bundle on the common
+// stacks, the first one found is included in the CA bundle of Composer.
+var systemCABundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/cert.pem",
+}
+
+// systemOpenSSLConfigs are the locations of the system OpenSSL config in the
+// OPENSSLDIR of the common stacks, as reported by `openssl version -d`, the
+// first one found is included in the OpenSSL config of Composer.
+var systemOpenSSLConfigs = []string{
+	"/usr/lib/ssl/openssl.cnf",
+	"/etc/pki/tls/openssl.cnf",
+	"/etc/ssl/openssl.cnf",
+}
+
+// tlsProtocols maps the values of BP_COMPOSER_TLS_MIN_VERSION to the
+// protocols of OpenSSL.
+var tlsProtocols = map[string]string{
+	"1.0": "TLSv1",
+	"1.1": "TLSv1.1",
+	"1.2": "TLSv1.2",
+	"1.3": "TLSv1.3",
+}
+
+// composerTLS configures which CAs Composer trusts and which TLS versions it
+// negotiates.
+type composerTLS struct {
+	// phpIni are the directives added to the php.ini of Composer
+	phpIni []string
+
+	// env is added to the environment of all `composer` executions
+	env []string
+
+	// dir contains the CA bundle and the OpenSSL config, it is outside of the
+	// layers and must be removed after the build
+	dir string
+}
+
+// prepareComposerTLSIfRequired will check for service bindings of type
+// "ca-certificates" and env vars "BP_COMPOSER_CAFILE", "BP_COMPOSER_CAPATH"
+// and "BP_COMPOSER_TLS_MIN_VERSION".
+//
+// The certificates of the bindings and of BP_COMPOSER_CAFILE are written into
+// a CA bundle, together with the system CA bundle, as openssl.cafile replaces
+// the trusted CAs rather than adding to them. BP_COMPOSER_CAPATH is set as
+// openssl.capath. PHP has no directive for the minimum TLS version, so it is
+// set in an OpenSSL config used via OPENSSL_CONF, which applies to both the
+// curl downloader and the PHP streams of Composer. As OPENSSL_CONF replaces
+// the system OpenSSL config, the config includes it, see
+// writeOpenSSLConfig.
+func prepareComposerTLSIfRequired(logger emitter, context packit.BuildContext, bindingResolver BindingResolver) (composerTLS, error) {
+	bindings, err := bindingResolver.Resolve(CACertificatesBindingType, "", context.Platform.Path)
+	if err != nil {
+		return composerTLS{}, err
+	}
+
+	caFile := os.Getenv(BpComposerCAFile)
+	caPath := os.Getenv(BpComposerCAPath)
+
+	minVersion := os.Getenv(BpComposerTLSMinVersion)
+	protocol, found := tlsProtocols[minVersion]
+	if minVersion != "" && !found {
+		return composerTLS{}, fmt.Errorf("error when parsing env var %q: must be one of 1.0, 1.1, 1.2 or 1.3, found %q", BpComposerTLSMinVersion, minVersion)
+	}
+
+	if len(bindings) == 0 && caFile == "" && caPath == "" && protocol == "" {
+		return composerTLS{}, nil
+	}
+
+	dir, err := os.MkdirTemp("", "composer-tls")
+	if err != nil { // untested
+		return composerTLS{}, err
+	}
+
+	tls := composerTLS{dir: dir}
+
+	logger.Process("Configuring TLS for Composer")
+
+	err = tls.write(logger, bindings, caFile, caPath, protocol)
+	if err != nil {
+		_ = tls.cleanup()
+		return composerTLS{}, err
+	}
+
+	logger.Break()
+
+	return tls, nil
+}
+
+func (t *composerTLS) write(logger emitter, bindings []servicebindings.Binding, caFile, caPath, protocol string) error {
+	var certificates []string
+
+	for _, binding := range bindings {
+		logger.Subprocess("Using CA certificates of binding '%s'", binding.Name)
+
+		names := make([]string, 0, len(binding.Entries))
+		for name := range binding.Entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			content, err := binding.Entries[name].ReadString()
+			if err != nil {
+				return fmt.Errorf("failed to read entry %q of binding %q: %w", name, binding.Name, err)
+			}
+
+			certificates = append(certificates, content)
+		}
+	}
+
+	if caFile != "" {
+		content, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", BpComposerCAFile, err)
+		}
+
+		logger.Subprocess("Using CA certificates of %s", caFile)
+		certificates = append(certificates, string(content))
+	}
+
+	if len(certificates) > 0 {
+		if systemCABundle := findSystemCABundle(); systemCABundle != "" {
+			content, err := os.ReadFile(systemCABundle)
+			if err != nil { // untested
+				return err
+			}
+
+			certificates = append([]string{string(content)}, certificates...)
+		} else {
+			logger.Subprocess("WARNING: No system CA bundle found, only the given CA certificates are trusted")
+		}
+
+		var bundle strings.Builder
+		for _, certificate := range certificates {
+			bundle.WriteString(certificate)
+			if !strings.HasSuffix(certificate, "\n") {
+				bundle.WriteString("\n")
+			}
+		}
+
+		bundlePath := filepath.Join(t.dir, "ca-bundle.crt")
+		err := os.WriteFile(bundlePath, []byte(bundle.String()), 0644)
+		if err != nil { // untested
+			return err
+		}
+
+		// curl.cainfo is read by the curl extension of scripts, which
+		// Composer itself does not rely on
+		t.phpIni = append(t.phpIni,
+			fmt.Sprintf(`openssl.cafile = "%s"`, bundlePath),
+			fmt.Sprintf(`curl.cainfo = "%s"`, bundlePath),
+		)
+	}
+
+	if caPath != "" {
+		logger.Subprocess("Using CA certificates of %s", caPath)
+		t.phpIni = append(t.phpIni, fmt.Sprintf(`openssl.capath = "%s"`, caPath))
+	}
+
+	if protocol != "" {
+		configPath := filepath.Join(t.dir, "openssl.cnf")
+		err := writeOpenSSLConfig(logger, configPath, protocol)
+		if err != nil {
+			return err
+		}
+
+		logger.Subprocess("Requiring at least %s", protocol)
+		t.env = append(t.env, fmt.Sprintf("%s=%s", opensslConf, configPath))
+	}
+
+	return nil
+}
+
+// writeOpenSSLConfig writes an OpenSSL config requiring at least the given
+// protocol. It includes the system OpenSSL config, so that its settings, e.g.
+// the cipher strings or the providers, still apply, and extends its sections
+// for the default TLS settings, as sections which are defined again are
+// merged by OpenSSL. The sections are named as in the system config, or
+// defined if it has none.
+func writeOpenSSLConfig(logger emitter, configPath, protocol string) error {
+	initSection, sslConf, systemDefault := "openssl_init", "ssl_configuration", "tls_system_default"
+
+	var include []string
+	if systemConfig := findSystemOpenSSLConfig(); systemConfig != "" {
+		content, err := os.ReadFile(systemConfig)
+		if err != nil { // untested
+			return err
+		}
+
+		sections := parseOpenSSLConfig(string(content))
+		if name := sections[""]["openssl_conf"]; name != "" {
+			initSection = name
+		}
+		if name := sections[initSection]["ssl_conf"]; name != "" {
+			sslConf = name
+		}
+		if name := sections[sslConf]["system_default"]; name != "" {
+			systemDefault = name
+		}
+
+		logger.Subprocess("Including the system OpenSSL config %s", systemConfig)
+		include = []string{fmt.Sprintf(".include %s", systemConfig), ""}
+	}
+
+	config := append(append([]string{
+		fmt.Sprintf("openssl_conf = %s", initSection),
+		"",
+	}, include...),
+		fmt.Sprintf("[%s]", initSection),
+		fmt.Sprintf("ssl_conf = %s", sslConf),
+		"",
+		fmt.Sprintf("[%s]", sslConf),
+		fmt.Sprintf("system_default = %s", systemDefault),
+		"",
+		fmt.Sprintf("[%s]", systemDefault),
+		fmt.Sprintf("MinProtocol = %s", protocol),
+	)
+
+	err := os.WriteFile(configPath, []byte(strings.Join(config, "\n")+"\n"), 0644)
+	if err != nil { // untested
+		return err
+	}
+
+	return nil
+}
+
+// findSystemOpenSSLConfig returns the system OpenSSL config, as set in
+// OPENSSL_CONF or found at one of the usual locations, or nothing if none
+// exists.
+func findSystemOpenSSLConfig() string {
+	candidates := systemOpenSSLConfigs
+	if value, found := os.LookupEnv(opensslConf); found && value != "" {
+		candidates = []string{value}
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+// parseOpenSSLConfig returns the values of the given OpenSSL config by
+// section, the values before the first section are in the section "". Only
+// the names of sections are read from it, so directives, such as .include,
+// and variables are not expanded.
+func parseOpenSSLConfig(content string) map[string]map[string]string {
+	sections := map[string]map[string]string{"": {}}
+	section := ""
+
+	for _, line := range strings.Split(content, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, "["), "]"))
+			if sections[section] == nil {
+				sections[section] = map[string]string{}
+			}
+			continue
+		}
+
+		name, value, found := strings.Cut(line, "=")
+		if !found || strings.HasPrefix(line, ".") {
+			continue
+		}
+
+		sections[section][strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	return sections
+}
+
+// findSystemCABundle returns the system CA bundle, as set in SSL_CERT_FILE or
+// found at one of the usual locations, or nothing if none exists.
+func findSystemCABundle() string {
+	candidates := systemCABundles
+	if value, found := os.LookupEnv(sslCertFile); found && value != "" {
+		candidates = []string{value}
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+// cleanup removes the CA bundle and the OpenSSL config, which are only used
+// during the build.
+func (t composerTLS) cleanup() error {
+	if t.dir == "" {
+		return nil
+	}
+
+	return os.RemoveAll(t.dir)
+}
@@ -0,0 +1,84 @@
+package composer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+// composerVersionMetadataKey is the key of the composer-packages layer
+// metadata, which records the version of Composer the layer has been built
+// with.
+const composerVersionMetadataKey = "composer-version"
+
+var composerVersionPattern = regexp.MustCompile(`Composer (?:version )?v?(\d+\.\d+\.\d+\S*)`)
+
+// determineComposerVersion will run `composer --version` to determine the
+// version of the Composer CLI on the path.
+//
+// The output is expected to look like `Composer version 2.6.5 2023-10-06 10:11:52`,
+// some versions of Composer omit the word "version".
+func determineComposerVersion(logger emitter, composerVersionExec Executable, composerPhpIniPath, path string) (string, error) {
+	buffer := bytes.NewBuffer(nil)
+	execution := pexec.Execution{
+		Args: []string{"--version", "--no-ansi"},
+		Env: append(os.Environ(),
+			"COMPOSER_NO_INTERACTION=1", // https://getcomposer.org/doc/03-cli.md#composer-no-interaction
+			fmt.Sprintf("PHPRC=%s", composerPhpIniPath),
+			fmt.Sprintf("PATH=%s", path),
+		),
+		Stdout: buffer,
+		Stderr: buffer,
+	}
+
+	err := composerVersionExec.Execute(execution)
+	if err != nil {
+		logger.Subprocess(buffer.String())
+		return "", fmt.Errorf("failed to determine composer version: %w", err)
+	}
+
+	matches := composerVersionPattern.FindStringSubmatch(buffer.String())
+	if matches == nil {
+		return "", fmt.Errorf("failed to parse composer version from output %q", strings.TrimSpace(buffer.String()))
+	}
+
+	logger.Debug.Process("Found composer version %s", matches[1])
+
+	return matches[1], nil
+}
+
+// composerVersionChanged returns whether the given layer metadata records
+// another major or minor version of Composer than the given version, so that
+// the layer is rebuilt. Another patch version is only logged. Layers built
+// before the version has been recorded, or builds which could not determine
+// the version, are considered unchanged.
+func composerVersionChanged(logger emitter, metadata map[string]interface{}, version string) bool {
+	cached, _ := metadata[composerVersionMetadataKey].(string)
+	if cached == "" || version == "" || cached == version {
+		return false
+	}
+
+	cachedVersion, err := semver.NewVersion(cached)
+	if err != nil {
+		logger.Debug.Process("Failed to parse the Composer version %q of the cached layer: %s", cached, err)
+		return true
+	}
+
+	currentVersion, err := semver.NewVersion(version)
+	if err != nil { // untested
+		return false
+	}
+
+	if minorVersion(cachedVersion).Equal(minorVersion(currentVersion)) {
+		logger.Process("Reusing the cached layer built with Composer %s for Composer %s", cached, version)
+		return false
+	}
+
+	logger.Process("Rebuilding the layer, as it has been built with Composer %s instead of %s", cached, version)
+	return true
+}
@@ -0,0 +1,152 @@
+package composer
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ComposerWarningsMetadataKey is the key of the composer-packages layer
+// metadata, which lists the warnings of the last `composer install`, so that
+// platforms can display them apart from the build output, e.g.
+//
+//	[[metadata.composer-warnings]]
+//	kind = "abandoned"
+//	message = "Package acme/legacy is abandoned, you should avoid using it. Use acme/modern instead."
+const ComposerWarningsMetadataKey = "composer-warnings"
+
+// ComposerWarningKind describes the kind of a warning of Composer.
+type ComposerWarningKind string
+
+const (
+	// ComposerWarningAbandoned is the warning about an abandoned package
+	ComposerWarningAbandoned ComposerWarningKind = "abandoned"
+
+	// ComposerWarningSuggestion is the suggestion of a package to install
+	// another package or extension
+	ComposerWarningSuggestion ComposerWarningKind = "suggestion"
+
+	// ComposerWarningDeprecation is a deprecation reported by Composer or
+	// PHP, e.g. of the scripts of `composer.json`
+	ComposerWarningDeprecation ComposerWarningKind = "deprecation"
+
+	// ComposerWarningOther is any other warning
+	ComposerWarningOther ComposerWarningKind = "warning"
+)
+
+// ComposerWarning is a warning in the output of `composer install`.
+type ComposerWarning struct {
+	Kind    ComposerWarningKind
+	Message string
+}
+
+// composerWarningPatterns match the warnings in the output of Composer, the
+// first match determines the kind.
+var composerWarningPatterns = []struct {
+	kind    ComposerWarningKind
+	pattern *regexp.Regexp
+}{
+	{ComposerWarningAbandoned, regexp.MustCompile(`^Package \S+ is abandoned`)},
+	{ComposerWarningSuggestion, regexp.MustCompile(`^\S+ suggests installing \S+|^\d+ packages? suggestions? (?:was|were) added`)},
+	{ComposerWarningDeprecation, regexp.MustCompile(`(?i)^(?:PHP )?Deprecat(?:ed|ion)[^:]*:`)},
+	{ComposerWarningOther, regexp.MustCompile(`(?i)^(?:PHP )?Warning:`)},
+}
+
+// ComposerWarnings records the warnings from the stderr of Composer. Each
+// warning is recorded once.
+type ComposerWarnings struct {
+	pending  []byte
+	seen     map[string]bool
+	warnings []ComposerWarning
+}
+
+// NewComposerWarnings returns an empty ComposerWarnings.
+func NewComposerWarnings() *ComposerWarnings {
+	return &ComposerWarnings{seen: map[string]bool{}}
+}
+
+// Writer returns a writer which passes the output through to the given
+// writer, and records the warnings from it. The warnings are recorded even if
+// the output is held back, see withQuietOutput.
+func (w *ComposerWarnings) Writer(out io.Writer) io.Writer {
+	return warningsWriter{out: out, warnings: w}
+}
+
+type warningsWriter struct {
+	out      io.Writer
+	warnings *ComposerWarnings
+}
+
+func (w warningsWriter) Write(p []byte) (int, error) {
+	_, _ = w.warnings.Write(p)
+	return w.out.Write(p)
+}
+
+func (w *ComposerWarnings) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+
+		w.parseLine(string(w.pending[:i]))
+		w.pending = w.pending[i+1:]
+	}
+
+	return len(p), nil
+}
+
+func (w *ComposerWarnings) parseLine(line string) {
+	// progress output overwrites the line with carriage returns
+	lines := splitOutputLines(line)
+	line = strings.TrimSpace(lines[len(lines)-1])
+
+	for _, candidate := range composerWarningPatterns {
+		if !candidate.pattern.MatchString(line) {
+			continue
+		}
+
+		if !w.seen[line] {
+			w.seen[line] = true
+			w.warnings = append(w.warnings, ComposerWarning{Kind: candidate.kind, Message: line})
+		}
+		return
+	}
+}
+
+// Warnings returns the recorded warnings in the order of the output.
+func (w *ComposerWarnings) Warnings() []ComposerWarning {
+	if len(w.pending) > 0 {
+		w.parseLine(string(w.pending))
+		w.pending = nil
+	}
+
+	return w.warnings
+}
+
+// reportComposerWarnings logs the recorded warnings, and lists them in the
+// given layer metadata as ComposerWarningsMetadataKey. The warnings of a
+// previous build are replaced.
+func reportComposerWarnings(logger emitter, warnings *ComposerWarnings, metadata map[string]interface{}) {
+	recorded := warnings.Warnings()
+	if len(recorded) == 0 {
+		delete(metadata, ComposerWarningsMetadataKey)
+		return
+	}
+
+	var entries []map[string]interface{}
+	logger.Process("Composer reported %d warning(s)", len(recorded))
+	for _, warning := range recorded {
+		logger.Subprocess("WARNING: [%s] %s", warning.Kind, warning.Message)
+		entries = append(entries, map[string]interface{}{
+			"kind":    string(warning.Kind),
+			"message": warning.Message,
+		})
+	}
+	logger.Break()
+
+	metadata[ComposerWarningsMetadataKey] = entries
+}
@@ -0,0 +1,60 @@
+package composer_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testComposerWarnings(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		warnings *composer.ComposerWarnings
+		output   *bytes.Buffer
+	)
+
+	it.Before(func() {
+		warnings = composer.NewComposerWarnings()
+		output = bytes.NewBuffer(nil)
+	})
+
+	it("records the warnings and passes the output through", func() {
+		writer := warnings.Writer(output)
+
+		_, err := fmt.Fprint(writer, `Installing dependencies from lock file (including require-dev)
+Package operations: 3 installs, 0 updates, 0 removals
+  - Installing acme/legacy (1.0.0): Extracting archive
+Package acme/legacy is abandoned, you should avoid using it. Use acme/modern instead.
+Package acme/legacy is abandoned, you should avoid using it. Use acme/modern instead.
+2 package suggestions were added by new dependencies, use `+"`composer suggest`"+` to see details.
+Deprecation Notice: Composer\Script\Event::getOperation is deprecated in /tmp/vendor/acme/installer.php:12
+Warning: The lock file is not up to date with the latest changes in composer.json.
+Generating autoload files
+  3/3 [============================] 100%`+"\r"+`PHP Deprecated:  Return type of Acme\Foo::count() should be compatible`)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(output.String()).To(ContainSubstring("Generating autoload files"))
+		Expect(warnings.Warnings()).To(Equal([]composer.ComposerWarning{
+			{Kind: composer.ComposerWarningAbandoned, Message: "Package acme/legacy is abandoned, you should avoid using it. Use acme/modern instead."},
+			{Kind: composer.ComposerWarningSuggestion, Message: "2 package suggestions were added by new dependencies, use `composer suggest` to see details."},
+			{Kind: composer.ComposerWarningDeprecation, Message: `Deprecation Notice: Composer\Script\Event::getOperation is deprecated in /tmp/vendor/acme/installer.php:12`},
+			{Kind: composer.ComposerWarningOther, Message: "Warning: The lock file is not up to date with the latest changes in composer.json."},
+			{Kind: composer.ComposerWarningDeprecation, Message: `PHP Deprecated:  Return type of Acme\Foo::count() should be compatible`},
+		}))
+	})
+
+	context("when there are no warnings", func() {
+		it("records nothing", func() {
+			_, err := fmt.Fprint(warnings.Writer(output), "Nothing to install, update or remove\n")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(warnings.Warnings()).To(BeEmpty())
+		})
+	})
+}
@@ -4,6 +4,16 @@ const (
 	ComposerPackagesLayerName = "composer-packages"
 	ComposerGlobalLayerName   = "composer-global"
 	ComposerPhpIniLayerName   = "composer-php-ini"
+	ComposerHomeLayerName     = "composer-home"
+	ComposerFallbackLayerName = "composer-fallback"
+	ComposerSandboxLayerName  = "composer-sandbox"
+	ComposerTmpLayerName      = "composer-tmp"
+
+	ComposerSupportBundleLayerName = "composer-support-bundle"
+	ComposerVendorBinLayerName     = "composer-vendor-bin"
+	ComposerVCSCacheLayerName      = "composer-vcs-cache"
+	ComposerPackageStoreLayerName  = "composer-package-store"
+	ComposerLicensesLayerName      = "composer-licenses"
 
 	// Autoloader Suffix
 	ComposerAutoloaderSuffix = "PaketoDefaultAutoloaderSuffix"
@@ -12,6 +22,21 @@ const (
 	ComposerDependency         = "composer"
 	ComposerPackagesDependency = "composer-packages"
 	PhpDependency              = "php"
+	NodeDependency             = "node"
+
+	// Service Bindings
+
+	// ComposerSSHBindingType is the type of the service bindings providing SSH
+	// keys for private VCS repositories
+	ComposerSSHBindingType = "composer-ssh"
+
+	// ComposerRepositoriesBindingType is the type of the service bindings
+	// providing a `repositories.yaml` describing additional repositories
+	ComposerRepositoriesBindingType = "composer-repositories"
+
+	// CACertificatesBindingType is the type of the service bindings providing
+	// additional CA certificates, each entry being a PEM encoded certificate
+	CACertificatesBindingType = "ca-certificates"
 
 	// Files
 	DefaultComposerJsonPath = "composer.json"
@@ -27,6 +52,10 @@ const (
 	// https://getcomposer.org/doc/03-cli.md#composer-vendor-dir
 	ComposerVendorDir = "COMPOSER_VENDOR_DIR"
 
+	// BpComposerFile selects an alternate composer.json relative to the project root, such as
+	// "composer-prod.json", which is used with its lock file "composer-prod.lock" and exported as COMPOSER
+	BpComposerFile = "BP_COMPOSER_FILE"
+
 	// BpComposerInstallGlobal is a space-delimited list of packages to be installed via `composer global require`
 	// This is typically so that they will be available during `composer` scripts
 	BpComposerInstallGlobal = "BP_COMPOSER_INSTALL_GLOBAL"
@@ -35,6 +64,329 @@ const (
 	// These will be parsed using the shellwords library https://github.com/mattn/go-shellwords
 	BpComposerInstallOptions = "BP_COMPOSER_INSTALL_OPTIONS"
 
+	// BpComposerReinstallOptions is a list of options to be provided to `composer install` when
+	// the cached layer is reused, instead of those of BpComposerInstallOptions
+	BpComposerReinstallOptions = "BP_COMPOSER_REINSTALL_OPTIONS"
+
+	// BpComposerDenyAbandoned can be set to "true" to fail the build if `composer.lock`
+	// contains packages which have been marked as abandoned
+	BpComposerDenyAbandoned = "BP_COMPOSER_DENY_ABANDONED"
+
+	// BpComposerPackageDenylist can be set to a whitespace-separated list of packages, such as
+	// "acme/legacy" or "monolog/monolog@1.*", which fail the build if they are in `composer.lock`
+	BpComposerPackageDenylist = "BP_COMPOSER_PACKAGE_DENYLIST"
+
+	// BpComposerPackageAllowlist can be set to a whitespace-separated list of packages, such as
+	// "acme/*" or "symfony/*", which are the only packages allowed in `composer.lock`
+	BpComposerPackageAllowlist = "BP_COMPOSER_PACKAGE_ALLOWLIST"
+
+	// BpComposerLicensePolicy can be set to "warn" or "fail" to check the licenses of the packages
+	// in the SBOM against BpComposerAllowedLicenses, defaults to "off"
+	BpComposerLicensePolicy = "BP_COMPOSER_LICENSE_POLICY"
+
+	// BpComposerAllowedLicenses is a list of SPDX license identifiers, such as "MIT" or
+	// "Apache-2.0", separated by whitespace or commas, which are allowed by BpComposerLicensePolicy
+	BpComposerAllowedLicenses = "BP_COMPOSER_ALLOWED_LICENSES"
+
+	// BpComposerBuildStamp can be set to "true" to write a `composer-build.json` file
+	// describing the build into the working directory and the composer-packages layer
+	BpComposerBuildStamp = "BP_COMPOSER_BUILD_STAMP"
+
+	// BpComposerExtraCachePaths is a space-delimited list of paths relative to the project root
+	// which are cached and restored alongside the vendor directory
+	BpComposerExtraCachePaths = "BP_COMPOSER_EXTRA_CACHE_PATHS"
+
+	// BpComposerVerifyIntegrity can be set to "true" to verify the downloaded dist archives
+	// against the checksums recorded in `composer.lock`
+	BpComposerVerifyIntegrity = "BP_COMPOSER_VERIFY_INTEGRITY"
+
+	// BpComposerSHA256 is the expected SHA-256 checksum of the `composer` executable.
+	// It is used if BP_COMPOSER_VERIFY_INTEGRITY is set to "true", or when provisioning
+	// composer via BP_COMPOSER_FALLBACK_VERSION
+	BpComposerSHA256 = "BP_COMPOSER_SHA256"
+
+	// BpComposerFallbackVersion is the version of composer.phar to download if no `composer`
+	// executable is found on the path, e.g. because the composer buildpack has not been included
+	BpComposerFallbackVersion = "BP_COMPOSER_FALLBACK_VERSION"
+
+	// BpComposerSandbox can be set to "true" to run `composer install` with a separate HOME and TMPDIR
+	BpComposerSandbox = "BP_COMPOSER_SANDBOX"
+
+	// BpComposerSandboxWritablePaths is a space-delimited list of paths relative to the project root
+	// which remain writable during `composer install`, while the existing files of the rest of the
+	// workspace are made read-only. It is only used if BP_COMPOSER_SANDBOX is set to "true"
+	BpComposerSandboxWritablePaths = "BP_COMPOSER_SANDBOX_WRITABLE_PATHS"
+
+	// BpComposerAutoloadRefresh can be set to "true" to regenerate the optimized autoloader at launch
+	// if the application sources have changed since the build
+	BpComposerAutoloadRefresh = "BP_COMPOSER_AUTOLOAD_REFRESH"
+
+	// BpComposerMaxParallelHttp is the maximum number of parallel downloads, passed to Composer
+	// as COMPOSER_MAX_PARALLEL_HTTP
+	BpComposerMaxParallelHttp = "BP_COMPOSER_MAX_PARALLEL_HTTP"
+
+	// BpComposerDisableHTTP2 can be set to "true" to make Composer download packages over HTTP/1.1
+	BpComposerDisableHTTP2 = "BP_COMPOSER_DISABLE_HTTP2"
+
+	// BpComposerBootstrapExtensions is a comma-delimited list of PHP extensions which are loaded
+	// for Composer itself, e.g. to download packages. It defaults to "openssl"
+	BpComposerBootstrapExtensions = "BP_COMPOSER_BOOTSTRAP_EXTENSIONS"
+
+	// BpComposerExtensionsExclude is a space-delimited list of PHP extensions which are not written
+	// into `.php.ini.d/composer-extensions.ini`, e.g. because they are compiled statically into PHP
+	BpComposerExtensionsExclude = "BP_COMPOSER_EXTENSIONS_EXCLUDE"
+
+	// BpComposerExtensionsInclude is a space-delimited list of PHP extensions which are written
+	// into `.php.ini.d/composer-extensions.ini` in addition to the ones required by the dependencies
+	BpComposerExtensionsInclude = "BP_COMPOSER_EXTENSIONS_INCLUDE"
+
+	// BpComposerExtensionsIni can be set to "false" to only list the PHP extensions required at runtime
+	// in `.php.ini.d/php-extensions.toml`, instead of loading them from `.php.ini.d/composer-extensions.ini`
+	BpComposerExtensionsIni = "BP_COMPOSER_EXTENSIONS_INI"
+
+	// BpComposerSupportBundle can be set to "true" to write a support bundle if the build fails
+	BpComposerSupportBundle = "BP_COMPOSER_SUPPORT_BUNDLE"
+
+	// BpComposerRootVersion is the version of the root package, which is passed to all `composer`
+	// executions as COMPOSER_ROOT_VERSION
+	BpComposerRootVersion = "BP_COMPOSER_ROOT_VERSION"
+
+	// BpComposerProvenance can be set to "true" to write a SLSA provenance statement into the
+	// composer-packages layer
+	BpComposerProvenance = "BP_COMPOSER_PROVENANCE"
+
+	// BpComposerProvenanceTSAURL is the URL of a RFC 3161 timestamp authority, which is used to
+	// timestamp the provenance statement if BP_COMPOSER_PROVENANCE is set to "true"
+	BpComposerProvenanceTSAURL = "BP_COMPOSER_PROVENANCE_TSA_URL"
+
+	// BpComposerVendorOwner is the owner of the vendor directory in the form "uid:gid", e.g. to match
+	// the user of the run image
+	BpComposerVendorOwner = "BP_COMPOSER_VENDOR_OWNER"
+
+	// BpComposerRunAs is the user in the form "uid:gid" which runs `composer install`, and therefore the
+	// scripts of the packages, instead of the build user. Requires the build to run as root
+	BpComposerRunAs = "BP_COMPOSER_RUN_AS"
+
+	// BpComposerVendorNormalizePermissions can be set to "true" to make the vendor directory readable
+	// by everyone, but no longer writable by everyone
+	BpComposerVendorNormalizePermissions = "BP_COMPOSER_VENDOR_NORMALIZE_PERMISSIONS"
+
+	// BpComposerLockSnapshot can be set to "true" to write a normalized `composer.lock` into the
+	// composer-packages layer, and its digest as image label
+	BpComposerLockSnapshot = "BP_COMPOSER_LOCK_SNAPSHOT"
+
+	// BpComposerOutdatedReport can be set to "true" to report the outdated direct dependencies
+	// after the install, using `composer outdated --direct`
+	BpComposerOutdatedReport = "BP_COMPOSER_OUTDATED_REPORT"
+
+	// BpComposerHermeticTmpDir can be set to "false" to run `composer` with the TMPDIR of the builder,
+	// rather than a temporary directory in an ignored layer
+	BpComposerHermeticTmpDir = "BP_COMPOSER_HERMETIC_TMPDIR"
+
+	// BpComposerDiskSpaceCheck can be set to "false" to skip the check of the disk space
+	// estimated to be required by `composer install`
+	BpComposerDiskSpaceCheck = "BP_COMPOSER_DISK_SPACE_CHECK"
+
+	// BpComposerAllowedHosts can be set to a space-delimited list of hosts, to fail the build
+	// if `composer.lock` contains a dist or source URL pointing to any other host
+	BpComposerAllowedHosts = "BP_COMPOSER_ALLOWED_HOSTS"
+
+	// BpComposerValidateVendorOnly can be set to "true" to build applications containing their
+	// vendored packages without running `composer`, only verifying them against `composer.lock`
+	BpComposerValidateVendorOnly = "BP_COMPOSER_VALIDATE_VENDOR_ONLY"
+
+	// BpComposerAutodetectProcesses can be set to "true" to register the `composer.json` scripts
+	// "start", "serve" and "worker" as process types
+	BpComposerAutodetectProcesses = "BP_COMPOSER_AUTODETECT_PROCESSES"
+
+	// BpComposerProfile can be set to "large" to tune `composer` for projects with huge dependency
+	// graphs: no memory limit, a longer process timeout and more parallel downloads
+	BpComposerProfile = "BP_COMPOSER_PROFILE"
+
+	// BpComposerSynthesize can be set to "true" to build applications without a `composer.json`,
+	// for which a minimal `composer.json` autoloading all PHP files is created
+	BpComposerSynthesize = "BP_COMPOSER_SYNTHESIZE"
+
+	// BpComposerPhpIniLayer can be set to "true" to write `composer-extensions.ini` into the
+	// composer-packages layer, which is appended to PHP_INI_SCAN_DIR at launch, instead of into
+	// `.php.ini.d` of the working directory
+	BpComposerPhpIniLayer = "BP_COMPOSER_PHP_INI_LAYER"
+
+	// BpComposerConfig is a list of `key=value` pairs, which are applied with `composer config --global`
+	// in COMPOSER_HOME before `composer install`, e.g. "process-timeout=900 preferred-install.foo/*=source"
+	BpComposerConfig = "BP_COMPOSER_CONFIG"
+
+	// BpComposerPreserveVendor can be set to "false" to run `composer install` for applications with
+	// vendored packages but without `composer.lock`, instead of preserving the vendored packages
+	BpComposerPreserveVendor = "BP_COMPOSER_PRESERVE_VENDOR"
+
+	// BpComposerDryRunCheck can be set to "true" to run `composer install --dry-run` before the
+	// cached composer-packages layer is reset, so that resolution errors keep the cached layer
+	BpComposerDryRunCheck = "BP_COMPOSER_DRY_RUN_CHECK"
+
+	// BpComposerOfflineRefresh can be set to "true" to run `composer install` on a reused cached
+	// composer-packages layer with COMPOSER_DISABLE_NETWORK=1, so that it fails instead of downloading
+	BpComposerOfflineRefresh = "BP_COMPOSER_OFFLINE_REFRESH"
+
+	// BpComposerReproducible can be set to "true" to normalize the permissions, modification times
+	// and class maps of the composer-packages layer and the vendor directory, so that the same inputs
+	// result in the same layers
+	BpComposerReproducible = "BP_COMPOSER_REPRODUCIBLE"
+
+	// BpComposerAutoloaderSuffix overrides the suffix of the generated autoloader classes, which
+	// defaults to ComposerAutoloaderSuffix. "{stack}" is replaced by the ID of the stack
+	BpComposerAutoloaderSuffix = "BP_COMPOSER_AUTOLOADER_SUFFIX"
+
+	// BpComposerCacheKeySalt can be set to any value, which is part of the cache key of the
+	// composer-packages layer, so that builds with different values never share cached layers
+	BpComposerCacheKeySalt = "BP_COMPOSER_CACHE_KEY_SALT"
+
+	// BpComposerVendorBinInstall can be set to "true" to run `composer bin all install` of
+	// bamarni/composer-bin-plugin after `composer install`, caching the tools in their own layer
+	BpComposerVendorBinInstall = "BP_COMPOSER_VENDOR_BIN_INSTALL"
+
+	// BpComposerMetricsPath is a writable directory or file, e.g. a volume of the platform, into which
+	// the metrics of the build are written in the text format of Prometheus
+	BpComposerMetricsPath = "BP_COMPOSER_METRICS_PATH"
+
+	// BpComposerFund can be set to "true" to show the funding notices of `composer install`, which are
+	// hidden by default
+	BpComposerFund = "BP_COMPOSER_FUND"
+
+	// BpComposerAudit can be set to "false" to skip the security audit `composer install` runs after
+	// installing the packages
+	BpComposerAudit = "BP_COMPOSER_AUDIT"
+
+	// BpComposerCAFile is a PEM encoded bundle of additional CA certificates trusted by Composer, such as
+	// the CA of an internal package repository
+	BpComposerCAFile = "BP_COMPOSER_CAFILE"
+
+	// BpComposerCAPath is a directory of hashed CA certificates trusted by Composer, as set in openssl.capath
+	BpComposerCAPath = "BP_COMPOSER_CAPATH"
+
+	// BpComposerTLSMinVersion is the minimum TLS version Composer negotiates, one of "1.0", "1.1", "1.2"
+	// or "1.3"
+	BpComposerTLSMinVersion = "BP_COMPOSER_TLS_MIN_VERSION"
+
+	// BpComposerCacheHome can be set to "true" to cache COMPOSER_HOME, with its downloads and cloned VCS
+	// repositories, between builds. Credentials are removed from it before it is cached
+	BpComposerCacheHome = "BP_COMPOSER_CACHE_HOME"
+
+	// BpComposerIncrementalAutoload can be set to "true" to skip the autoload dump of `composer install`
+	// from cached files, as long as the autoload configuration and the autoloaded sources are unchanged
+	BpComposerIncrementalAutoload = "BP_COMPOSER_INCREMENTAL_AUTOLOAD"
+
+	// BpComposerRequireNode can be set to "true" to require "node" for the scripts of `composer install`,
+	// or to "false" to never require it. Defaults to "auto", which requires it if available, for
+	// applications with a `package.json` whose install scripts run npm, npx, yarn, pnpm or node
+	BpComposerRequireNode = "BP_COMPOSER_REQUIRE_NODE"
+
+	// BpComposerCacheVCS can be set to "true" to cache the VCS repositories cloned by Composer in a
+	// separate layer, independently of `composer.lock`, or to "false" to never cache them. Defaults to
+	// "auto", which caches them if the project has VCS repositories
+	BpComposerCacheVCS = "BP_COMPOSER_CACHE_VCS"
+
+	// BpComposerInstallStrategy selects the registered InstallStrategy installing the packages instead
+	// of `composer install`. Defaults to "composer"
+	BpComposerInstallStrategy = "BP_COMPOSER_INSTALL_STRATEGY"
+
+	// BpComposerPlatformPhpPolicy can be set to "warn" or "fail" to check the PHP requirements of the
+	// packages reported by `composer check-platform-reqs` against the PHP of the build, defaults to "off"
+	BpComposerPlatformPhpPolicy = "BP_COMPOSER_PLATFORM_PHP_POLICY"
+
+	// BpComposerStabilityPolicy can be set to "warn" or "fail" to check the minimum-stability of
+	// `composer.json` against BpComposerStabilityThreshold, defaults to "off"
+	BpComposerStabilityPolicy = "BP_COMPOSER_STABILITY_POLICY"
+
+	// BpComposerStabilityThreshold is the least stable minimum-stability allowed by
+	// BpComposerStabilityPolicy, defaults to "stable"
+	BpComposerStabilityThreshold = "BP_COMPOSER_STABILITY_THRESHOLD"
+
+	// BpComposerFileMode is the octal file mode of the files generated by the build, such as the php.ini
+	// of composer and the INI files loading the extensions, defaults to "0644"
+	BpComposerFileMode = "BP_COMPOSER_FILE_MODE"
+
+	// BpComposerRevalidateStack can be set to "true" to revalidate the composer-packages layer built on
+	// another stack, by installing the cached packages again and checking the platform requirements,
+	// instead of rebuilding it
+	BpComposerRevalidateStack = "BP_COMPOSER_REVALIDATE_STACK"
+
+	// BpComposerPackageStore can be set to "true" to store the extracted packages in a separate layer,
+	// independently of `composer.lock`, and assemble the vendor directory from it, so that a changed
+	// `composer.lock` only downloads and extracts the changed packages
+	BpComposerPackageStore = "BP_COMPOSER_PACKAGE_STORE"
+
+	// BpComposerScriptPathPrepend is a list of absolute directories, separated like PATH, which are
+	// prepended to the PATH of `composer install`, so that its scripts find the tools of other buildpacks
+	BpComposerScriptPathPrepend = "BP_COMPOSER_SCRIPT_PATH_PREPEND"
+
+	// BpComposerLicenseReport can be set to "true" to write the licenses of the installed packages as
+	// `licenses.md` and `licenses.json` into a launch layer, or to a relative path to write them into that
+	// directory of the application
+	BpComposerLicenseReport = "BP_COMPOSER_LICENSE_REPORT"
+
+	// BpComposerSBOMPhpExtensions can be set to "true" to add the PHP extensions required at runtime to the
+	// SBOM of the composer-packages layer
+	BpComposerSBOMPhpExtensions = "BP_COMPOSER_SBOM_PHP_EXTENSIONS"
+
+	// BpComposerShowSuggests can be set to "true" to log the packages and PHP extensions suggested by the
+	// installed packages, which are not installed, after the install
+	BpComposerShowSuggests = "BP_COMPOSER_SHOW_SUGGESTS"
+
+	// BpComposerGlobalEnvPrefix is the prefix of environment variables which are set without the
+	// prefix for `composer global` only, e.g. BP_COMPOSER_GLOBAL_ENV_GITHUB_TOKEN
+	BpComposerGlobalEnvPrefix = "BP_COMPOSER_GLOBAL_ENV_"
+
+	// BpComposerInstallEnvPrefix is the prefix of environment variables which are set without the
+	// prefix for `composer install` only, e.g. BP_COMPOSER_INSTALL_ENV_GITHUB_TOKEN
+	BpComposerInstallEnvPrefix = "BP_COMPOSER_INSTALL_ENV_"
+
+	// BpDisableSBOM can be set to "true" to skip the generation of the SBOM
+	BpDisableSBOM = "BP_DISABLE_SBOM"
+
+	// BpSBOMFormats can be set to a list of SBOM formats to generate instead of the formats
+	// declared in buildpack.toml
+	BpSBOMFormats = "BP_SBOM_FORMATS"
+
+	// OtelExporterOtlpEndpoint can be set to the base URL of an OTLP/HTTP endpoint, to export the
+	// spans of the build to "{endpoint}/v1/traces"
+	// https://opentelemetry.io/docs/specs/otel/protocol/exporter/
+	OtelExporterOtlpEndpoint = "OTEL_EXPORTER_OTLP_ENDPOINT"
+
+	// OtelExporterOtlpTracesEndpoint can be set to the URL the spans are exported to, it takes
+	// precedence over OtelExporterOtlpEndpoint
+	OtelExporterOtlpTracesEndpoint = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+
+	// OtelExporterOtlpHeaders can be set to a comma-delimited list of "key=value" headers, which
+	// are sent with the exported spans, such as credentials
+	OtelExporterOtlpHeaders = "OTEL_EXPORTER_OTLP_HEADERS"
+
+	// OtelExporterOtlpTracesHeaders can be set to additional headers for the exported spans
+	OtelExporterOtlpTracesHeaders = "OTEL_EXPORTER_OTLP_TRACES_HEADERS"
+
+	// OtelExporterOtlpProtocol is the OTLP protocol, only "http/json" is supported
+	OtelExporterOtlpProtocol = "OTEL_EXPORTER_OTLP_PROTOCOL"
+
+	// OtelExporterOtlpTracesProtocol is the OTLP protocol of the exported spans, it takes
+	// precedence over OtelExporterOtlpProtocol
+	OtelExporterOtlpTracesProtocol = "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"
+
+	// OtelServiceName can be set to the service name of the exported spans
+	OtelServiceName = "OTEL_SERVICE_NAME"
+
+	// OtelSDKDisabled can be set to "true" to disable tracing
+	OtelSDKDisabled = "OTEL_SDK_DISABLED"
+
+	// TraceParent can be set to the W3C trace context of a span the build belongs to, such as a
+	// CI pipeline step, in the format of the "traceparent" header
+	// https://www.w3.org/TR/trace-context/#traceparent-header
+	TraceParent = "TRACEPARENT"
+
+	// SourceDateEpoch can be set to the number of seconds since the epoch, which is used as the
+	// modification time of all files when BP_COMPOSER_REPRODUCIBLE is set to "true"
+	// https://reproducible-builds.org/docs/source-date-epoch/
+	SourceDateEpoch = "SOURCE_DATE_EPOCH"
+
 	// PhpExtensionDir is the directory containing PHP extensions.
 	// It is set by the Paketo buildpack `php-dist`
 	PhpExtensionDir = "PHP_EXTENSION_DIR"
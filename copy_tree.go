@@ -0,0 +1,222 @@
+package composer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// copyProgressSteps is the number of progress reports logged at DEBUG level
+// while copying a directory tree, i.e. one every 10% of the files.
+const copyProgressSteps = 10
+
+// CopyTree copies the directory tree at source to destination, which must
+// not exist yet. Directories and symlinks are created upfront, the files are
+// then copied concurrently by a pool of workers sized by the number of CPUs.
+// File modes are preserved and symlinks are copied as symlinks.
+//
+// As copying large vendor directories can take a while, the progress (files,
+// bytes and estimated time remaining) is logged at DEBUG level, followed by
+// a summary.
+func CopyTree(logger Logger, source, destination string) error {
+	return copyTree(newEmitter(logger), source, destination)
+}
+
+// CopyTreeExcluding copies the directory tree at source to destination like
+// CopyTree, but skips the paths for which excludes returns true, e.g. those
+// matching LayerIgnore. Directories are skipped with their contents.
+func CopyTreeExcluding(logger Logger, source, destination string, excludes func(path string) bool) error {
+	return copyTreeExcluding(newEmitter(logger), source, destination, excludes)
+}
+
+// copyTree is CopyTree for the steps of the build.
+func copyTree(logger emitter, source, destination string) error {
+	return copyTreeExcluding(logger, source, destination, nil)
+}
+
+// copyTreeExcluding is CopyTreeExcluding for the steps of the build.
+func copyTreeExcluding(logger emitter, source, destination string, excludes func(path string) bool) error {
+	type copyJob struct {
+		source      string
+		destination string
+		mode        os.FileMode
+		size        int64
+	}
+
+	var jobs []copyJob
+	var totalBytes int64
+	var excluded int
+
+	err := filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if excludes != nil && path != source && excludes(path) {
+			excluded++
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(source, path)
+		if err != nil { // untested
+			return err
+		}
+		target := filepath.Join(destination, relativePath)
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil { // untested
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		default:
+			jobs = append(jobs, copyJob{
+				source:      path,
+				destination: target,
+				mode:        info.Mode().Perm(),
+				size:        info.Size(),
+			})
+			totalBytes += info.Size()
+			return nil
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	progress := copyProgress{
+		logger:     logger,
+		start:      time.Now(),
+		totalFiles: len(jobs),
+		totalBytes: totalBytes,
+	}
+
+	queue := make(chan copyJob)
+	errs := make(chan error, len(jobs))
+
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				err := copyFile(job.source, job.destination, job.mode)
+				if err != nil {
+					errs <- err
+					continue
+				}
+				progress.add(job.size)
+			}
+		}()
+	}
+
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		return err
+	}
+
+	logger.Subprocess("Copied %d file(s) (%s) in %s", len(jobs), formatBytes(totalBytes), time.Since(progress.start).Round(time.Millisecond))
+	if excluded > 0 {
+		logger.Subprocess("Excluded %d path(s)", excluded)
+	}
+
+	return nil
+}
+
+func copyFile(source, destination string, mode os.FileMode) error {
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	destinationFile, err := os.OpenFile(destination, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if errors.Is(err, fs.ErrPermission) && os.Remove(destination) == nil {
+		// the destination is owned by another user, e.g. in a rootless
+		// build, which only allows to replace it
+		destinationFile, err = os.OpenFile(destination, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	}
+	if err != nil {
+		return err
+	}
+	defer destinationFile.Close()
+
+	_, err = io.Copy(destinationFile, sourceFile)
+	if err != nil { // untested
+		return err
+	}
+
+	return destinationFile.Close()
+}
+
+// copyProgress keeps track of the files copied by CopyTree and logs the
+// progress at DEBUG level each time another tenth of the files has been copied.
+type copyProgress struct {
+	mutex  sync.Mutex
+	logger emitter
+	start  time.Time
+
+	files      int
+	totalFiles int
+	bytes      int64
+	totalBytes int64
+	reported   int
+}
+
+func (p *copyProgress) add(size int64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.files++
+	p.bytes += size
+
+	step := p.files * copyProgressSteps / p.totalFiles
+	if step <= p.reported {
+		return
+	}
+	p.reported = step
+
+	elapsed := time.Since(p.start)
+	eta := time.Duration(0)
+	if p.bytes > 0 {
+		eta = time.Duration(float64(elapsed) * float64(p.totalBytes-p.bytes) / float64(p.bytes))
+	}
+
+	p.logger.Debug.Action("Copied %d/%d file(s), %s/%s, %s remaining",
+		p.files, p.totalFiles,
+		formatBytes(p.bytes), formatBytes(p.totalBytes),
+		eta.Round(time.Second))
+}
+
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
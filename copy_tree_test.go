@@ -0,0 +1,106 @@
+package composer_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testCopyTree(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		buffer      *bytes.Buffer
+		logger      composer.Logger
+		source      string
+		destination string
+		tmpDir      string
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "copy-tree")
+		Expect(err).NotTo(HaveOccurred())
+
+		source = filepath.Join(tmpDir, "source")
+		destination = filepath.Join(tmpDir, "some", "destination")
+
+		Expect(os.MkdirAll(filepath.Join(source, "some-package", "src"), os.ModePerm)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(source, "bin"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(source, "autoload.php"), []byte("<?php"), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(source, "some-package", "bin-file"), []byte("#!/usr/bin/env php"), 0755)).To(Succeed())
+		for i := 0; i < 20; i++ {
+			Expect(os.WriteFile(filepath.Join(source, "some-package", "src", fmt.Sprintf("Class%d.php", i)), []byte("<?php class Foo {}"), 0644)).To(Succeed())
+		}
+		Expect(os.Symlink(filepath.Join("..", "some-package", "bin-file"), filepath.Join(source, "bin", "some-bin"))).To(Succeed())
+
+		buffer = bytes.NewBuffer(nil)
+		logger = composer.NewEmitterLogger(scribe.NewEmitter(buffer).WithLevel("DEBUG"))
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	it("copies the tree", func() {
+		Expect(composer.CopyTree(logger, source, destination)).To(Succeed())
+
+		contents, err := os.ReadFile(filepath.Join(destination, "autoload.php"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(Equal("<?php"))
+
+		for i := 0; i < 20; i++ {
+			Expect(filepath.Join(destination, "some-package", "src", fmt.Sprintf("Class%d.php", i))).To(BeARegularFile())
+		}
+
+		info, err := os.Stat(filepath.Join(destination, "some-package", "bin-file"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0755)))
+
+		link, err := os.Readlink(filepath.Join(destination, "bin", "some-bin"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(link).To(Equal(filepath.Join("..", "some-package", "bin-file")))
+	})
+
+	it("logs the progress", func() {
+		Expect(composer.CopyTree(logger, source, destination)).To(Succeed())
+
+		Expect(buffer.String()).To(ContainSubstring("Copied 3/22 file(s)"))
+		Expect(buffer.String()).To(ContainSubstring("Copied 22/22 file(s), 383 B/383 B, 0s remaining"))
+		Expect(buffer.String()).To(MatchRegexp(`Copied 22 file\(s\) \(383 B\) in \d+`))
+	})
+
+	context("with excluded paths", func() {
+		it("skips them", func() {
+			excludes := func(path string) bool {
+				return path == filepath.Join(source, "some-package", "src") || path == filepath.Join(source, "autoload.php")
+			}
+
+			Expect(composer.CopyTreeExcluding(logger, source, destination, excludes)).To(Succeed())
+
+			Expect(filepath.Join(destination, "autoload.php")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(destination, "some-package", "src")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(destination, "some-package", "bin-file")).To(BeARegularFile())
+
+			Expect(buffer.String()).To(MatchRegexp(`Copied 1 file\(s\) \(18 B\) in \d+`))
+			Expect(buffer.String()).To(ContainSubstring("Excluded 2 path(s)"))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when the source does not exist", func() {
+			it("returns an error", func() {
+				err := composer.CopyTree(logger, filepath.Join(tmpDir, "missing"), destination)
+				Expect(err).To(MatchError(ContainSubstring("no such file or directory")))
+			})
+		})
+	})
+}
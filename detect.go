@@ -16,21 +16,54 @@ type PhpVersionResolverInterface interface {
 	Resolve(composerJsonPath, composerLockPath string) (version, versionSource string, err error)
 }
 
-func Detect(logEmitter scribe.Emitter, phpVersionResolver PhpVersionResolverInterface) packit.DetectFunc {
+// Detect detects applications with a `composer.json`, which it requires
+// "composer" and "php" for, and provides "composer-packages". The given
+// options add to the build plan, see DetectOption.
+func Detect(logEmitter scribe.Emitter, phpVersionResolver PhpVersionResolverInterface, options ...DetectOption) packit.DetectFunc {
+	var detectOptions detectOptions
+	for _, option := range options {
+		option(&detectOptions)
+	}
+
+	logger := newEmitter(NewEmitterLogger(logEmitter))
+
 	return func(context packit.DetectContext) (packit.DetectResult, error) {
+		// project.toml may enable the synthesis of composer.json, or select
+		// another composer.json with BP_COMPOSER_FILE
+		_, err := applyProjectConfig(logger, context.WorkingDir)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		if composerFile, found := os.LookupEnv(BpComposerFile); found && composerFile != "" {
+			if relativePath, err := filepath.Rel(context.WorkingDir, filepath.Join(context.WorkingDir, composerFile)); err != nil {
+				return packit.DetectResult{}, err
+			} else if filepath.IsAbs(composerFile) || strings.HasPrefix(relativePath, "..") {
+				return packit.DetectResult{}, packit.Fail.WithMessage("%s must be a relative path underneath the project root, found %q", BpComposerFile, composerFile)
+			}
+		}
+
 		composerJsonPath, composerLockPath, composerVar, composerVarFound := FindComposerFiles(context.WorkingDir)
 
-		if exists, err := fs.Exists(composerJsonPath); err != nil {
+		composerJsonExists, err := fs.Exists(composerJsonPath)
+		if err != nil {
 			return packit.DetectResult{}, err
-		} else if !exists && !composerVarFound {
-			return packit.DetectResult{}, packit.Fail.WithMessage("no %s found", DefaultComposerJsonPath)
-		} else if !exists && composerVarFound {
+		}
+
+		synthesize, err := composerJsonSynthesisRequested(context.WorkingDir)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		if !composerJsonExists && composerVarFound {
 			return packit.DetectResult{}, packit.Fail.WithMessage("no %s found at location '%s'", DefaultComposerJsonPath, composerVar)
+		} else if !composerJsonExists && !synthesize {
+			return packit.DetectResult{}, packit.Fail.WithMessage("no %s found", DefaultComposerJsonPath)
 		}
 
 		if exists, err := fs.Exists(composerLockPath); err != nil {
 			return packit.DetectResult{}, err
-		} else if !exists {
+		} else if !exists && !synthesize {
 			logEmitter.Title("WARNING: Include a 'composer.lock' file with your application! This will make sure the exact same version of dependencies are used when you build. It will also enable caching of your dependency layer.")
 		}
 
@@ -42,6 +75,10 @@ func Detect(logEmitter scribe.Emitter, phpVersionResolver PhpVersionResolverInte
 			}
 		}
 
+		if _, err := FindVendorDir(context.WorkingDir, composerJsonPath); err != nil {
+			return packit.DetectResult{}, packit.Fail.WithMessage("%s", err)
+		}
+
 		phpRequirement := packit.BuildPlanRequirement{
 			Name: PhpDependency,
 			Metadata: BuildPlanMetadata{
@@ -49,33 +86,71 @@ func Detect(logEmitter scribe.Emitter, phpVersionResolver PhpVersionResolverInte
 			},
 		}
 
-		if phpVersion, phpVersionSource, err := phpVersionResolver.Resolve(composerJsonPath, composerLockPath); err != nil {
-			return packit.DetectResult{}, err
-		} else if phpVersion != "" {
-			phpRequirement.Metadata = BuildPlanMetadata{
-				Build:         true,
-				Version:       phpVersion,
-				VersionSource: phpVersionSource,
+		// a synthesized composer.json does not constrain the PHP version
+		if !synthesize {
+			if phpVersion, phpVersionSource, err := phpVersionResolver.Resolve(composerJsonPath, composerLockPath); err != nil {
+				return packit.DetectResult{}, err
+			} else if phpVersion != "" {
+				phpRequirement.Metadata = BuildPlanMetadata{
+					Build:         true,
+					Version:       phpVersion,
+					VersionSource: phpVersionSource,
+				}
 			}
 		}
 
-		return packit.DetectResult{
-			Plan: packit.BuildPlan{
-				Provides: []packit.BuildPlanProvision{
-					{
-						Name: ComposerPackagesDependency,
-					},
+		requirements := []packit.BuildPlanRequirement{
+			{
+				Name: ComposerDependency,
+				Metadata: BuildPlanMetadata{
+					Build: true,
 				},
-				Requires: []packit.BuildPlanRequirement{
-					{
-						Name: ComposerDependency,
-						Metadata: BuildPlanMetadata{
-							Build: true,
-						},
-					},
-					phpRequirement,
+			},
+			phpRequirement,
+		}
+
+		// composer will be provisioned during the build if it is not provided
+		if _, found := os.LookupEnv(BpComposerFallbackVersion); found {
+			requirements = []packit.BuildPlanRequirement{phpRequirement}
+		}
+
+		// composer does not run for vendored applications
+		vendorOnly, err := vendorOnlyBuildRequested(context.WorkingDir)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		if vendorOnly {
+			requirements = []packit.BuildPlanRequirement{phpRequirement}
+		}
+
+		plan := packit.BuildPlan{
+			Provides: []packit.BuildPlanProvision{
+				{
+					Name: ComposerPackagesDependency,
 				},
 			},
+			Requires: requirements,
+		}
+
+		// the scripts of composer install may build assets with node, but
+		// composer does not run for vendored applications
+		if !vendorOnly {
+			nodeRequirement, err := determineNodeRequirement(logger, context.WorkingDir, composerJsonPath)
+			if err != nil {
+				return packit.DetectResult{}, err
+			}
+
+			plan = withNodeRequirement(plan, nodeRequirement)
+		}
+
+		plan, err = detectOptions.apply(context, plan)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		return packit.DetectResult{
+			Plan: plan,
 		}, nil
 	}
 }
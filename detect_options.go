@@ -0,0 +1,75 @@
+package composer
+
+import "github.com/paketo-buildpacks/packit/v2"
+
+// DetectOption adjusts the build plan of Detect, so that platforms building
+// on this buildpack can add requirements and provisions without replacing
+// the detection, e.g.
+//
+//	composer.Detect(logEmitter, phpVersionResolver,
+//		composer.WithRequirement(packit.BuildPlanRequirement{
+//			Name:     "node",
+//			Metadata: composer.BuildPlanMetadata{Build: true},
+//		}),
+//	)
+//
+// The options only apply if the application is detected.
+type DetectOption func(*detectOptions)
+
+// PlanModifier returns the given build plan with adjustments, which depend on
+// the application, e.g. to require "node" only if it has a `package.json`.
+type PlanModifier func(context packit.DetectContext, plan packit.BuildPlan) (packit.BuildPlan, error)
+
+type detectOptions struct {
+	requirements []packit.BuildPlanRequirement
+	provisions   []packit.BuildPlanProvision
+	modifiers    []PlanModifier
+}
+
+// WithRequirement adds the given requirement to the build plan, after the
+// requirements of "composer" and "php".
+func WithRequirement(requirement packit.BuildPlanRequirement) DetectOption {
+	return func(options *detectOptions) {
+		options.requirements = append(options.requirements, requirement)
+	}
+}
+
+// WithProvision adds the given provision to the build plan, after the
+// provision of "composer-packages".
+func WithProvision(provision packit.BuildPlanProvision) DetectOption {
+	return func(options *detectOptions) {
+		options.provisions = append(options.provisions, provision)
+	}
+}
+
+// WithPlanModifier adds the given modifier, which is applied to the build
+// plan after the requirements and provisions of the other options. Modifiers
+// are applied in the order they are given, and an error fails the detection.
+func WithPlanModifier(modifier PlanModifier) DetectOption {
+	return func(options *detectOptions) {
+		options.modifiers = append(options.modifiers, modifier)
+	}
+}
+
+// apply returns the given build plan with the requirements, provisions and
+// modifiers of the options. The requirements and provisions are added to its
+// alternatives as well.
+func (o detectOptions) apply(context packit.DetectContext, plan packit.BuildPlan) (packit.BuildPlan, error) {
+	plan.Requires = append(plan.Requires, o.requirements...)
+	plan.Provides = append(plan.Provides, o.provisions...)
+
+	for i := range plan.Or {
+		plan.Or[i].Requires = append(plan.Or[i].Requires, o.requirements...)
+		plan.Or[i].Provides = append(plan.Or[i].Provides, o.provisions...)
+	}
+
+	for _, modifier := range o.modifiers {
+		var err error
+		plan, err = modifier(context, plan)
+		if err != nil {
+			return packit.BuildPlan{}, err
+		}
+	}
+
+	return plan, nil
+}
@@ -85,6 +85,296 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 			Expect(phpVersionResolver.ResolveCall.Receives.ComposerLockPath).To(Equal(filepath.Join(workingDir, "composer.lock")))
 		})
 
+		context("with BP_COMPOSER_FALLBACK_VERSION set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_FALLBACK_VERSION", "2.6.5")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_FALLBACK_VERSION")).To(Succeed())
+			})
+
+			it(`only requires "php"`, func() {
+				detectResult, err := detect(packit.DetectContext{WorkingDir: workingDir})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(detectResult.Plan).To(Equal(packit.BuildPlan{
+					Provides: []packit.BuildPlanProvision{
+						{
+							Name: composer.ComposerPackagesDependency,
+						},
+					},
+					Requires: []packit.BuildPlanRequirement{
+						{
+							Name: "php",
+							Metadata: composer.BuildPlanMetadata{
+								Build: true,
+							},
+						},
+					},
+				}))
+			})
+		})
+
+		context("with BP_COMPOSER_VALIDATE_VENDOR_ONLY set to true", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_VALIDATE_VENDOR_ONLY", "true")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_VALIDATE_VENDOR_ONLY")).To(Succeed())
+			})
+
+			context("when the application contains its vendored packages", func() {
+				it.Before(func() {
+					Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "composer"), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "composer", "installed.json"), []byte(`{"packages": []}`), os.ModePerm)).To(Succeed())
+				})
+
+				it(`only requires "php"`, func() {
+					detectResult, err := detect(packit.DetectContext{WorkingDir: workingDir})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(detectResult.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+						{
+							Name: "php",
+							Metadata: composer.BuildPlanMetadata{
+								Build: true,
+							},
+						},
+					}))
+				})
+			})
+
+			context("when the application does not contain its vendored packages", func() {
+				it(`requires "composer"`, func() {
+					detectResult, err := detect(packit.DetectContext{WorkingDir: workingDir})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(detectResult.Plan.Requires).To(HaveLen(2))
+					Expect(detectResult.Plan.Requires[0].Name).To(Equal("composer"))
+				})
+			})
+		})
+
+		context("when the scripts of composer install build assets", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{
+	"scripts": {
+		"post-install-cmd": ["@php artisan optimize", "@build-assets"],
+		"build-assets": "npm ci && npm run build",
+		"test": "npx jest"
+	}
+}`), 0644)).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_REQUIRE_NODE")).To(Succeed())
+			})
+
+			context("when the application has a package.json", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte("{}"), 0644)).To(Succeed())
+				})
+
+				it(`requires "node" if it is provided`, func() {
+					detectResult, err := detect(packit.DetectContext{WorkingDir: workingDir})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(detectResult.Plan.Requires).To(HaveLen(3))
+					Expect(detectResult.Plan.Requires[2]).To(Equal(packit.BuildPlanRequirement{
+						Name: "node",
+						Metadata: composer.BuildPlanMetadata{
+							Build: true,
+						},
+					}))
+					Expect(detectResult.Plan.Or).To(Equal([]packit.BuildPlan{
+						{
+							Provides: detectResult.Plan.Provides,
+							Requires: detectResult.Plan.Requires[:2],
+						},
+					}))
+
+					Expect(buffer.String()).To(ContainSubstring(`Requiring "node" if available, for 1 script(s) of 'composer install' (BP_COMPOSER_REQUIRE_NODE=auto)`))
+					Expect(buffer.String()).To(ContainSubstring("- script post-install-cmd"))
+				})
+
+				context("with BP_COMPOSER_REQUIRE_NODE set to false", func() {
+					it.Before(func() {
+						Expect(os.Setenv("BP_COMPOSER_REQUIRE_NODE", "false")).To(Succeed())
+					})
+
+					it(`does not require "node"`, func() {
+						detectResult, err := detect(packit.DetectContext{WorkingDir: workingDir})
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(detectResult.Plan.Requires).To(HaveLen(2))
+						Expect(detectResult.Plan.Or).To(BeEmpty())
+					})
+				})
+
+				context("with detect options", func() {
+					it("adds them to the build plan without node as well", func() {
+						detect = composer.Detect(scribe.NewEmitter(buffer), phpVersionResolver,
+							composer.WithRequirement(packit.BuildPlanRequirement{Name: "assets"}),
+						)
+
+						detectResult, err := detect(packit.DetectContext{WorkingDir: workingDir})
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(detectResult.Plan.Requires).To(HaveLen(4))
+						Expect(detectResult.Plan.Requires[3].Name).To(Equal("assets"))
+						Expect(detectResult.Plan.Or).To(HaveLen(1))
+						Expect(detectResult.Plan.Or[0].Requires).To(HaveLen(3))
+						Expect(detectResult.Plan.Or[0].Requires[1].Name).To(Equal("php"))
+						Expect(detectResult.Plan.Or[0].Requires[2].Name).To(Equal("assets"))
+					})
+				})
+			})
+
+			context("when the application has no package.json", func() {
+				it(`does not require "node"`, func() {
+					detectResult, err := detect(packit.DetectContext{WorkingDir: workingDir})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(detectResult.Plan.Requires).To(HaveLen(2))
+					Expect(detectResult.Plan.Or).To(BeEmpty())
+				})
+			})
+		})
+
+		context("with BP_COMPOSER_REQUIRE_NODE set to true", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_REQUIRE_NODE", "true")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_REQUIRE_NODE")).To(Succeed())
+			})
+
+			it(`requires "node"`, func() {
+				detectResult, err := detect(packit.DetectContext{WorkingDir: workingDir})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(detectResult.Plan.Requires).To(HaveLen(3))
+				Expect(detectResult.Plan.Requires[2].Name).To(Equal("node"))
+				Expect(detectResult.Plan.Or).To(BeEmpty())
+				Expect(buffer.String()).To(ContainSubstring(`Requiring "node" for the scripts of 'composer install' (BP_COMPOSER_REQUIRE_NODE=true)`))
+			})
+		})
+
+		context("with an invalid BP_COMPOSER_REQUIRE_NODE", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_REQUIRE_NODE", "sometimes")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_REQUIRE_NODE")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{WorkingDir: workingDir})
+				Expect(err).To(MatchError(ContainSubstring(`error when parsing env var "BP_COMPOSER_REQUIRE_NODE"`)))
+			})
+		})
+
+		context("with detect options", func() {
+			var logEmitter scribe.Emitter
+
+			it.Before(func() {
+				logEmitter = scribe.NewEmitter(buffer)
+			})
+
+			it("adds the requirements and provisions", func() {
+				detect = composer.Detect(logEmitter, phpVersionResolver,
+					composer.WithRequirement(packit.BuildPlanRequirement{
+						Name: "node",
+						Metadata: composer.BuildPlanMetadata{
+							Build: true,
+						},
+					}),
+					composer.WithProvision(packit.BuildPlanProvision{
+						Name: "php-assets",
+					}),
+				)
+
+				detectResult, err := detect(packit.DetectContext{WorkingDir: workingDir})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(detectResult.Plan.Provides).To(Equal([]packit.BuildPlanProvision{
+					{Name: composer.ComposerPackagesDependency},
+					{Name: "php-assets"},
+				}))
+				Expect(detectResult.Plan.Requires).To(HaveLen(3))
+				Expect(detectResult.Plan.Requires[0].Name).To(Equal("composer"))
+				Expect(detectResult.Plan.Requires[1].Name).To(Equal("php"))
+				Expect(detectResult.Plan.Requires[2]).To(Equal(packit.BuildPlanRequirement{
+					Name: "node",
+					Metadata: composer.BuildPlanMetadata{
+						Build: true,
+					},
+				}))
+			})
+
+			it("applies the plan modifiers in order", func() {
+				var receivedContext packit.DetectContext
+				detect = composer.Detect(logEmitter, phpVersionResolver,
+					composer.WithPlanModifier(func(context packit.DetectContext, plan packit.BuildPlan) (packit.BuildPlan, error) {
+						receivedContext = context
+						plan.Requires = append(plan.Requires, packit.BuildPlanRequirement{Name: "node"})
+						return plan, nil
+					}),
+					composer.WithPlanModifier(func(context packit.DetectContext, plan packit.BuildPlan) (packit.BuildPlan, error) {
+						plan.Or = append(plan.Or, packit.BuildPlan{Requires: plan.Requires[2:]})
+						return plan, nil
+					}),
+				)
+
+				detectResult, err := detect(packit.DetectContext{WorkingDir: workingDir})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(receivedContext.WorkingDir).To(Equal(workingDir))
+				Expect(detectResult.Plan.Requires).To(HaveLen(3))
+				Expect(detectResult.Plan.Requires[2].Name).To(Equal("node"))
+				Expect(detectResult.Plan.Or).To(Equal([]packit.BuildPlan{
+					{Requires: []packit.BuildPlanRequirement{{Name: "node"}}},
+				}))
+			})
+
+			context("when a plan modifier fails", func() {
+				it("returns the error", func() {
+					detect = composer.Detect(logEmitter, phpVersionResolver,
+						composer.WithPlanModifier(func(packit.DetectContext, packit.BuildPlan) (packit.BuildPlan, error) {
+							return packit.BuildPlan{}, errors.New("modifier failed")
+						}),
+					)
+
+					_, err := detect(packit.DetectContext{WorkingDir: workingDir})
+					Expect(err).To(MatchError("modifier failed"))
+				})
+			})
+
+			context("when the application is not detected", func() {
+				it.Before(func() {
+					Expect(os.Remove(filepath.Join(workingDir, "composer.json"))).To(Succeed())
+				})
+
+				it("does not apply them", func() {
+					called := false
+					detect = composer.Detect(logEmitter, phpVersionResolver,
+						composer.WithPlanModifier(func(context packit.DetectContext, plan packit.BuildPlan) (packit.BuildPlan, error) {
+							called = true
+							return plan, nil
+						}),
+					)
+
+					_, err := detect(packit.DetectContext{WorkingDir: workingDir})
+					Expect(err).To(MatchError(packit.Fail.WithMessage("no composer.json found")))
+					Expect(called).To(BeFalse())
+				})
+			})
+		})
+
 		context("when PhpVersionResolver returns values", func() {
 			it.Before(func() {
 				phpVersionResolver.ResolveCall.Returns.Version = "php-version-from-resolver"
@@ -158,6 +448,17 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
+	context("when composer.json sets config.vendor-dir outside of the project root", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{"config": {"vendor-dir": "/usr/vendor"}}`), 0644)).To(Succeed())
+		})
+
+		it("does not require or provide anything", func() {
+			_, err := detect(packit.DetectContext{WorkingDir: workingDir})
+			Expect(err).To(MatchError(packit.Fail.WithMessage(`config.vendor-dir in composer.json must be a relative path underneath the project root, found "/usr/vendor"`)))
+		})
+	})
+
 	context("when $COMPOSER is set", func() {
 		it.Before(func() {
 			Expect(os.Setenv("COMPOSER", "other/location/composer.json")).ToNot(HaveOccurred())
@@ -226,11 +527,170 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
+	context("when BP_COMPOSER_FILE is set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_FILE", "composer-prod.json")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_COMPOSER_FILE")).To(Succeed())
+		})
+
+		context("when it points to an existing file", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer-prod.json"), []byte("{}"), 0644)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer-prod.lock"), []byte("{}"), 0644)).To(Succeed())
+			})
+
+			it("uses it with its lock file", func() {
+				_, err := detect(packit.DetectContext{WorkingDir: workingDir})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(phpVersionResolver.ResolveCall.Receives.ComposerJsonPath).To(Equal(filepath.Join(workingDir, "composer-prod.json")))
+				Expect(phpVersionResolver.ResolveCall.Receives.ComposerLockPath).To(Equal(filepath.Join(workingDir, "composer-prod.lock")))
+				Expect(buffer.String()).NotTo(ContainSubstring("WARNING: Include a 'composer.lock' file"))
+			})
+
+			context("when COMPOSER is set as well", func() {
+				it.Before(func() {
+					Expect(os.Setenv("COMPOSER", "other/composer.json")).To(Succeed())
+				})
+
+				it("takes precedence", func() {
+					_, err := detect(packit.DetectContext{WorkingDir: workingDir})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(phpVersionResolver.ResolveCall.Receives.ComposerJsonPath).To(Equal(filepath.Join(workingDir, "composer-prod.json")))
+				})
+			})
+		})
+
+		context("when it points to a non-existing file", func() {
+			it("does not require or provide anything", func() {
+				_, err := detect(packit.DetectContext{WorkingDir: workingDir})
+				Expect(err).To(MatchError(packit.Fail.WithMessage("no composer.json found at location 'composer-prod.json'")))
+			})
+		})
+
+		context("when it points outside of the project root", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_FILE", "../composer-prod.json")).To(Succeed())
+			})
+
+			it("does not require or provide anything", func() {
+				_, err := detect(packit.DetectContext{WorkingDir: workingDir})
+				Expect(err).To(MatchError(packit.Fail.WithMessage(`BP_COMPOSER_FILE must be a relative path underneath the project root, found "../composer-prod.json"`)))
+			})
+		})
+	})
+
 	context("when composer.json is not present", func() {
 		it(`does not require or provide anything`, func() {
 			_, err := detect(packit.DetectContext{WorkingDir: workingDir})
 			Expect(err).To(MatchError(packit.Fail.WithMessage("no composer.json found")))
 		})
+
+		context("with BP_COMPOSER_SYNTHESIZE set to true", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_SYNTHESIZE", "true")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_SYNTHESIZE")).To(Succeed())
+			})
+
+			context("when the application contains PHP files", func() {
+				it.Before(func() {
+					Expect(os.MkdirAll(filepath.Join(workingDir, "lib"), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, "lib", "functions.php"), []byte("<?php"), 0644)).To(Succeed())
+				})
+
+				it(`requires "composer" and "php" without a version`, func() {
+					detectResult, err := detect(packit.DetectContext{WorkingDir: workingDir})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(detectResult.Plan).To(Equal(packit.BuildPlan{
+						Provides: []packit.BuildPlanProvision{
+							{
+								Name: composer.ComposerPackagesDependency,
+							},
+						},
+						Requires: []packit.BuildPlanRequirement{
+							{
+								Name: "composer",
+								Metadata: composer.BuildPlanMetadata{
+									Build: true,
+								},
+							},
+							{
+								Name: "php",
+								Metadata: composer.BuildPlanMetadata{
+									Build: true,
+								},
+							},
+						},
+					}))
+
+					Expect(phpVersionResolver.ResolveCall.CallCount).To(Equal(0))
+					Expect(buffer.String()).NotTo(ContainSubstring("WARNING: Include a 'composer.lock' file"))
+					Expect(filepath.Join(workingDir, "composer.json")).NotTo(BeAnExistingFile())
+				})
+
+				context("when $COMPOSER is set", func() {
+					it.Before(func() {
+						Expect(os.Setenv("COMPOSER", "not-a-real-file")).To(Succeed())
+					})
+
+					it(`does not require or provide anything`, func() {
+						_, err := detect(packit.DetectContext{WorkingDir: workingDir})
+						Expect(err).To(MatchError(packit.Fail.WithMessage("no composer.json found at location 'not-a-real-file'")))
+					})
+				})
+			})
+
+			context("when the application contains PHP files in hidden directories only", func() {
+				it.Before(func() {
+					Expect(os.MkdirAll(filepath.Join(workingDir, ".git"), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, ".git", "hook.php"), []byte("<?php"), 0644)).To(Succeed())
+				})
+
+				it(`does not require or provide anything`, func() {
+					_, err := detect(packit.DetectContext{WorkingDir: workingDir})
+					Expect(err).To(MatchError(packit.Fail.WithMessage("no composer.json found")))
+				})
+			})
+		})
+
+		context("when project.toml enables the synthesis", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "project.toml"), []byte("[composer-install]\nsynthesize = true\n"), 0644)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "index.php"), []byte("<?php"), 0644)).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_SYNTHESIZE")).To(Succeed())
+			})
+
+			it("detects", func() {
+				_, err := detect(packit.DetectContext{WorkingDir: workingDir})
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		context("with an invalid BP_COMPOSER_SYNTHESIZE", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_SYNTHESIZE", "not-a-bool")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_COMPOSER_SYNTHESIZE")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{WorkingDir: workingDir})
+				Expect(err).To(MatchError(ContainSubstring(`error when parsing env var "BP_COMPOSER_SYNTHESIZE"`)))
+			})
+		})
 	})
 
 	context("failure cases", func() {
@@ -4,8 +4,37 @@ import (
 	"os"
 
 	"github.com/mattn/go-shellwords"
+	"github.com/paketo-buildpacks/packit/v2"
 )
 
+// InstallOptionsMetadataKey is the key of the build plan metadata, through
+// which other buildpacks requiring "composer-packages" can provide options
+// for `composer install`.
+const InstallOptionsMetadataKey = "install-options"
+
+// InstallOptionSource describes where an option for `composer install` has
+// been configured.
+type InstallOptionSource string
+
+const (
+	InstallOptionSourceDefault       InstallOptionSource = "default"
+	InstallOptionSourceEnv           InstallOptionSource = "env var " + BpComposerInstallOptions
+	InstallOptionSourceReinstallEnv  InstallOptionSource = "env var " + BpComposerReinstallOptions
+	InstallOptionSourceProjectConfig InstallOptionSource = ProjectDescriptorFileName
+	InstallOptionSourcePlan          InstallOptionSource = "build plan metadata"
+
+	// InstallOptionSourceProvidedExtension means the option ignores an
+	// extension provided by another buildpack, see ProvidedExtensionsMetadataKey
+	InstallOptionSourceProvidedExtension InstallOptionSource = "extension provided by build plan metadata"
+)
+
+// InstallOption is a single option for `composer install`, along with where
+// it has been configured.
+type InstallOption struct {
+	Value  string
+	Source InstallOptionSource
+}
+
 type InstallOptions struct{}
 
 func NewComposerInstallOptions() InstallOptions {
@@ -14,25 +43,111 @@ func NewComposerInstallOptions() InstallOptions {
 
 // Determine will generate the list of options for `composer install`
 // https://getcomposer.org/doc/03-cli.md#install-i
-func (_ InstallOptions) Determine() []string {
-	if installOptionsFromEnv, exists := os.LookupEnv(BpComposerInstallOptions); !exists {
-		return []string{
-			"--no-progress",
-			"--no-dev",
+//
+// The options are taken from the first of:
+//   - BP_COMPOSER_INSTALL_OPTIONS, set in the environment or in project.toml
+//   - the "install-options" metadata of a "composer-packages" build plan entry
+//   - the defaults, i.e. `--no-dev`
+//
+// `--no-progress` is always included.
+//
+// projectConfig holds the environment variables which have been set from
+// project.toml.
+func (_ InstallOptions) Determine(plan packit.BuildpackPlan, projectConfig map[string]string) []InstallOption {
+	options := []InstallOption{
+		{Value: "--no-progress", Source: InstallOptionSourceDefault},
+	}
+
+	if installOptionsFromEnv, exists := os.LookupEnv(BpComposerInstallOptions); exists {
+		source := InstallOptionSourceEnv
+		if _, fromProjectConfig := projectConfig[BpComposerInstallOptions]; fromProjectConfig {
+			source = InstallOptionSourceProjectConfig
 		}
-	} else if installOptionsFromEnv == "" {
-		return []string{
-			"--no-progress",
+
+		return append(options, parseInstallOptions(installOptionsFromEnv, source)...)
+	}
+
+	for _, entry := range plan.Entries {
+		if entry.Name != ComposerPackagesDependency {
+			continue
 		}
-	} else {
-		parsedOptionsFromEnv, err := shellwords.Parse(installOptionsFromEnv)
-		if err != nil {
-			return []string{
-				"--no-progress",
-				installOptionsFromEnv,
-			}
+
+		if installOptionsFromPlan, ok := entry.Metadata[InstallOptionsMetadataKey].(string); ok {
+			return append(options, parseInstallOptions(installOptionsFromPlan, InstallOptionSourcePlan)...)
 		}
+	}
+
+	return append(options, InstallOption{Value: "--no-dev", Source: InstallOptionSourceDefault})
+}
+
+func parseInstallOptions(value string, source InstallOptionSource) []InstallOption {
+	if value == "" {
+		return nil
+	}
+
+	parsed, err := shellwords.Parse(value)
+	if err != nil {
+		return []InstallOption{{Value: value, Source: source}}
+	}
+
+	var options []InstallOption
+	for _, option := range parsed {
+		options = append(options, InstallOption{Value: option, Source: source})
+	}
+
+	return options
+}
+
+// determineReinstallOptions returns the options for `composer install` when
+// the cached composer-packages layer is reused, taken from
+// BP_COMPOSER_REINSTALL_OPTIONS, set in the environment or in project.toml.
+// This allows a faster refresh pass, e.g. with `--no-scripts`, as the
+// packages have already been installed.
+//
+// Returns the given options of the cold install and false, if it is not set.
+// `--no-progress` is always included.
+func determineReinstallOptions(installOptions []InstallOption, projectConfig map[string]string) ([]InstallOption, bool) {
+	reinstallOptionsFromEnv, exists := os.LookupEnv(BpComposerReinstallOptions)
+	if !exists {
+		return installOptions, false
+	}
+
+	source := InstallOptionSourceReinstallEnv
+	if _, fromProjectConfig := projectConfig[BpComposerReinstallOptions]; fromProjectConfig {
+		source = InstallOptionSourceProjectConfig
+	}
+
+	options := []InstallOption{
+		{Value: "--no-progress", Source: InstallOptionSourceDefault},
+	}
+
+	return append(options, parseInstallOptions(reinstallOptionsFromEnv, source)...), true
+}
+
+// composerInstallArgs returns the arguments for `composer install` with the given
+// options.
+func composerInstallArgs(options []InstallOption) []string {
+	args := []string{"install"}
+	for _, option := range options {
+		args = append(args, option.Value)
+	}
+
+	return args
+}
+
+// logInstallOptions logs a table of the given options and where they have
+// been configured, below the given title.
+func logInstallOptions(logger emitter, title string, options []InstallOption) {
+	width := 0
+	for _, option := range options {
+		if len(option.Value) > width {
+			width = len(option.Value)
+		}
+	}
 
-		return append([]string{"--no-progress"}, parsedOptionsFromEnv...)
+	logger.Process("%s", title)
+	for _, option := range options {
+		logger.Subprocess("%-*s  (%s)", width, option.Value, option.Source)
 	}
+	logger.Break()
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/paketo-buildpacks/composer"
+	"github.com/paketo-buildpacks/packit/v2"
 
 	"github.com/sclevine/spec"
 
@@ -15,10 +16,16 @@ func testComposerInstallOptions(t *testing.T, context spec.G, it spec.S) {
 	var (
 		Expect  = NewWithT(t).Expect
 		options composer.InstallOptions
+
+		plan          packit.BuildpackPlan
+		projectConfig map[string]string
 	)
 
 	it.Before(func() {
 		options = composer.NewComposerInstallOptions()
+
+		plan = packit.BuildpackPlan{}
+		projectConfig = map[string]string{}
 	})
 
 	it.After(func() {
@@ -27,11 +34,32 @@ func testComposerInstallOptions(t *testing.T, context spec.G, it spec.S) {
 
 	context("when BP_COMPOSER_INSTALL_OPTIONS is not set", func() {
 		it("should return default options", func() {
-			Expect(options.Determine()).To(Equal([]string{
-				"--no-progress",
-				"--no-dev",
+			Expect(options.Determine(plan, projectConfig)).To(Equal([]composer.InstallOption{
+				{Value: "--no-progress", Source: composer.InstallOptionSourceDefault},
+				{Value: "--no-dev", Source: composer.InstallOptionSourceDefault},
 			}))
 		})
+
+		context("when the build plan contains install-options metadata", func() {
+			it.Before(func() {
+				plan.Entries = []packit.BuildpackPlanEntry{
+					{
+						Name: "composer-packages",
+						Metadata: map[string]interface{}{
+							"install-options": "--prefer-dist --no-scripts",
+						},
+					},
+				}
+			})
+
+			it("should return the options from the build plan", func() {
+				Expect(options.Determine(plan, projectConfig)).To(Equal([]composer.InstallOption{
+					{Value: "--no-progress", Source: composer.InstallOptionSourceDefault},
+					{Value: "--prefer-dist", Source: composer.InstallOptionSourcePlan},
+					{Value: "--no-scripts", Source: composer.InstallOptionSourcePlan},
+				}))
+			})
+		})
 	})
 
 	context("when BP_COMPOSER_INSTALL_OPTIONS is set to empty", func() {
@@ -40,8 +68,8 @@ func testComposerInstallOptions(t *testing.T, context spec.G, it spec.S) {
 		})
 
 		it("should return --no-progress only", func() {
-			Expect(options.Determine()).To(Equal([]string{
-				"--no-progress",
+			Expect(options.Determine(plan, projectConfig)).To(Equal([]composer.InstallOption{
+				{Value: "--no-progress", Source: composer.InstallOptionSourceDefault},
 			}))
 		})
 	})
@@ -49,16 +77,40 @@ func testComposerInstallOptions(t *testing.T, context spec.G, it spec.S) {
 	context("when BP_COMPOSER_INSTALL_OPTIONS has options", func() {
 		it.Before(func() {
 			Expect(os.Setenv("BP_COMPOSER_INSTALL_OPTIONS", "--foo=bar -v --something")).To(Succeed())
+
+			plan.Entries = []packit.BuildpackPlanEntry{
+				{
+					Name: "composer-packages",
+					Metadata: map[string]interface{}{
+						"install-options": "--prefer-dist",
+					},
+				},
+			}
 		})
 
 		it("should return those values as individual args", func() {
-			Expect(options.Determine()).To(Equal([]string{
-				"--no-progress",
-				"--foo=bar",
-				"-v",
-				"--something",
+			Expect(options.Determine(plan, projectConfig)).To(Equal([]composer.InstallOption{
+				{Value: "--no-progress", Source: composer.InstallOptionSourceDefault},
+				{Value: "--foo=bar", Source: composer.InstallOptionSourceEnv},
+				{Value: "-v", Source: composer.InstallOptionSourceEnv},
+				{Value: "--something", Source: composer.InstallOptionSourceEnv},
 			}))
 		})
+
+		context("when it has been set from project.toml", func() {
+			it.Before(func() {
+				projectConfig["BP_COMPOSER_INSTALL_OPTIONS"] = "--foo=bar -v --something"
+			})
+
+			it("should return project.toml as the source", func() {
+				Expect(options.Determine(plan, projectConfig)).To(Equal([]composer.InstallOption{
+					{Value: "--no-progress", Source: composer.InstallOptionSourceDefault},
+					{Value: "--foo=bar", Source: composer.InstallOptionSourceProjectConfig},
+					{Value: "-v", Source: composer.InstallOptionSourceProjectConfig},
+					{Value: "--something", Source: composer.InstallOptionSourceProjectConfig},
+				}))
+			})
+		})
 	})
 
 	context("when BP_COMPOSER_INSTALL_OPTIONS has invalid options", func() {
@@ -67,9 +119,9 @@ func testComposerInstallOptions(t *testing.T, context spec.G, it spec.S) {
 		})
 
 		it("should return those values as one single arg", func() {
-			Expect(options.Determine()).To(Equal([]string{
-				"--no-progress",
-				"invalid'option for composer",
+			Expect(options.Determine(plan, projectConfig)).To(Equal([]composer.InstallOption{
+				{Value: "--no-progress", Source: composer.InstallOptionSourceDefault},
+				{Value: "invalid'option for composer", Source: composer.InstallOptionSourceEnv},
 			}))
 		})
 	})
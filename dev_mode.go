@@ -0,0 +1,82 @@
+package composer
+
+import (
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+// devModeSwitched returns whether the given layer inputs only differ from the
+// ones recorded in the given layer metadata by `--no-dev`, i.e. the cached
+// layer has been built with the dev packages and they are excluded now, or
+// the other way round. Then the vendor directory of the cached layer is
+// restored before `composer install`, which only removes or installs the dev
+// packages, instead of installing all packages again.
+//
+// The cached layer must contain a vendor directory, and must have been built
+// with the same minor version of Composer, as composerVersionChanged.
+func devModeSwitched(logger emitter, metadata map[string]interface{}, inputs LayerInputs, changedInputs []string, layerVendorDir, composerVersion string) (bool, error) {
+	if len(changedInputs) != 1 || changedInputs[0] != "install-options" {
+		return false, nil
+	}
+
+	cachedOptions := strings.Fields(inputs.cached(metadata)["install-options"])
+	options := strings.Fields(inputs["install-options"])
+	if !equalWithoutNoDev(cachedOptions, options) {
+		return false, nil
+	}
+
+	if exists, err := fs.Exists(layerVendorDir); err != nil {
+		return false, err
+	} else if !exists {
+		return false, nil
+	}
+
+	if composerVersionChanged(logger, metadata, composerVersion) {
+		return false, nil
+	}
+
+	if containsNoDev(options) {
+		logger.Process("Removing the dev packages from the cached packages, as '--no-dev' has been added")
+	} else {
+		logger.Process("Installing the dev packages into the cached packages, as '--no-dev' has been removed")
+	}
+
+	return true, nil
+}
+
+// equalWithoutNoDev returns whether the given options of `composer install`
+// are equal, apart from `--no-dev`.
+func equalWithoutNoDev(a, b []string) bool {
+	a, b = withoutNoDev(a), withoutNoDev(b)
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func withoutNoDev(options []string) []string {
+	var filtered []string
+	for _, option := range options {
+		if option != "--no-dev" {
+			filtered = append(filtered, option)
+		}
+	}
+	return filtered
+}
+
+func containsNoDev(options []string) bool {
+	for _, option := range options {
+		if option == "--no-dev" {
+			return true
+		}
+	}
+	return false
+}
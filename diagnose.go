@@ -0,0 +1,93 @@
+package composer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+// connectivityFailurePattern matches the output of Composer for failures
+// which are likely caused by the network, e.g. DNS, proxy or TLS issues, or
+// rate limits of the repositories.
+var connectivityFailurePattern = regexp.MustCompile(`(?i)(curl error|could not resolve (host|proxy)|failed to open stream|could not be downloaded|connection (timed out|refused|reset)|operation timed out|network is unreachable|\bssl\b|\btls\b|certificate|proxy|rate limit|api limit|http/[0-9.]+ (403|407|429|5[0-9][0-9]))`)
+
+// diagnoseProblemPattern matches the checks of `composer diagnose` which did
+// not succeed, e.g. "Checking http connectivity to packagist: FAIL"
+var diagnoseProblemPattern = regexp.MustCompile(`^Checking .*: (FAIL|WARNING)`)
+
+// withConnectivityDiagnosis returns an Executable which runs `composer
+// diagnose` if the execution fails with an error which looks like a network
+// issue, and logs its findings before returning the original error.
+func withConnectivityDiagnosis(logger emitter, executable Executable) Executable {
+	return diagnosingExecutable{
+		logger:     logger,
+		executable: executable,
+	}
+}
+
+type diagnosingExecutable struct {
+	logger     emitter
+	executable Executable
+}
+
+func (e diagnosingExecutable) Execute(execution pexec.Execution) error {
+	stderr := bytes.NewBuffer(nil)
+
+	diagnosedExecution := execution
+	diagnosedExecution.Stderr = io.MultiWriter(writerOrDiscard(execution.Stderr), stderr)
+
+	err := e.executable.Execute(diagnosedExecution)
+	if err == nil || !connectivityFailurePattern.Match(stderr.Bytes()) {
+		return err
+	}
+
+	e.logger.Process("The failure looks network-related, running 'composer diagnose'")
+
+	output := bytes.NewBuffer(nil)
+	runComposerDiagnose(e.executable, execution.Dir, execution.Env, io.MultiWriter(e.logger.ActionWriter, output))
+
+	var problems []string
+	for _, line := range splitOutputLines(output.String()) {
+		if diagnoseProblemPattern.MatchString(strings.TrimSpace(line)) {
+			problems = append(problems, strings.TrimSpace(line))
+		}
+	}
+
+	if len(problems) > 0 {
+		e.logger.Subprocess("Problems found by 'composer diagnose':")
+		for _, problem := range problems {
+			e.logger.Action("%s", problem)
+		}
+	}
+	e.logger.Break()
+
+	return err
+}
+
+// runComposerDiagnose runs `composer diagnose` and writes its output to the
+// given writer. As `composer diagnose` exits with a non-zero code if any check
+// fails, its error is written to the output as well rather than returned.
+// https://getcomposer.org/doc/03-cli.md#diagnose
+func runComposerDiagnose(executable Executable, dir string, env []string, output io.Writer) {
+	err := executable.Execute(pexec.Execution{
+		Args:   []string{"diagnose"},
+		Dir:    dir,
+		Env:    env,
+		Stdout: output,
+		Stderr: output,
+	})
+	if err != nil {
+		_, _ = fmt.Fprintf(output, "\n%s\n", err)
+	}
+}
+
+func writerOrDiscard(writer io.Writer) io.Writer {
+	if writer == nil {
+		return io.Discard
+	}
+	return writer
+}
@@ -0,0 +1,192 @@
+package composer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// EstimatedPackageSize is the space estimated for each package which
+// `composer install` extracts into the vendor directory. `composer.lock`
+// does not record the size of the dist archives, so the estimate is based on
+// the number of packages instead.
+const EstimatedPackageSize uint64 = 2 * 1024 * 1024
+
+// DiskSpace defines the interface for determining the filesystem holding a
+// given path, and the space available on it.
+//
+//go:generate faux --interface DiskSpace --output fakes/disk_space.go
+type DiskSpace interface {
+	Available(path string) (filesystem uint64, available uint64, err error)
+}
+
+// DiskSpaceEstimate is the space estimated to be required by
+// `composer install`.
+type DiskSpaceEstimate struct {
+	// Packages is the number of packages with files to install
+	Packages int
+
+	// Workspace is the space required by the vendor directory
+	Workspace uint64
+
+	// Layers is the space required by the cached archives in the
+	// composer-home layer and the copy of the vendor directory in the
+	// composer-packages layer
+	Layers uint64
+}
+
+// EstimateDiskSpace will inspect the `composer.lock` file and estimate the
+// space required to install its packages. Metapackages and packages installed
+// from a local path are not counted, as they do not extract any archive.
+//
+// Returns an empty estimate if `composer.lock` does not exist.
+func EstimateDiskSpace(composerLockPath string) (DiskSpaceEstimate, error) {
+	content, err := readComposerFile(composerLockPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return DiskSpaceEstimate{}, nil
+		}
+		return DiskSpaceEstimate{}, err
+	}
+
+	type lockedPackage struct {
+		Type string `json:"type"`
+		Dist struct {
+			Type string `json:"type"`
+		} `json:"dist"`
+	}
+
+	var composerLock struct {
+		Packages    []lockedPackage `json:"packages"`
+		PackagesDev []lockedPackage `json:"packages-dev"`
+	}
+
+	err = json.Unmarshal(content, &composerLock)
+	if err != nil {
+		return DiskSpaceEstimate{}, err
+	}
+
+	packages := 0
+	for _, p := range append(composerLock.Packages, composerLock.PackagesDev...) {
+		if p.Type == "metapackage" || p.Dist.Type == "path" {
+			continue
+		}
+		packages++
+	}
+
+	return DiskSpaceEstimate{
+		Packages:  packages,
+		Workspace: uint64(packages) * EstimatedPackageSize,
+		Layers:    2 * uint64(packages) * EstimatedPackageSize,
+	}, nil
+}
+
+// checkDiskSpaceIfRequired will check for env var
+// "BP_COMPOSER_DISK_SPACE_CHECK". Unless set to false, the space estimated to
+// be required by `composer install` is compared to the space available for
+// the workspace and the layers, so that the build fails before the install
+// rather than leaving a partially written vendor directory or cache behind.
+// If the workspace and the layers are on the same filesystem, their
+// estimates are added up.
+func checkDiskSpaceIfRequired(logger emitter, diskSpace DiskSpace, composerLockPath, workingDir, layersDir string) error {
+	enabled, err := lookupBoolEnv(BpComposerDiskSpaceCheck, true)
+	if err != nil {
+		return err
+	}
+
+	if !enabled {
+		return nil
+	}
+
+	// an invalid composer.lock is reported by composer itself
+	estimate, err := EstimateDiskSpace(composerLockPath)
+	if err != nil {
+		logger.Debug.Process("Skipping the disk space check, failed to estimate the required disk space: %s", err)
+		logger.Debug.Break()
+		return nil
+	}
+
+	if estimate.Packages == 0 {
+		return nil
+	}
+
+	type filesystem struct {
+		paths     []string
+		required  uint64
+		available uint64
+	}
+
+	filesystems := map[uint64]*filesystem{}
+	var ids []uint64
+	for _, location := range []struct {
+		path     string
+		required uint64
+	}{
+		{workingDir, estimate.Workspace},
+		{layersDir, estimate.Layers},
+	} {
+		id, available, err := diskSpace.Available(location.path)
+		if err != nil {
+			return fmt.Errorf("failed to determine the available disk space of %s: %w", location.path, err)
+		}
+
+		if _, ok := filesystems[id]; !ok {
+			filesystems[id] = &filesystem{available: available}
+			ids = append(ids, id)
+		}
+		filesystems[id].paths = append(filesystems[id].paths, location.path)
+		filesystems[id].required += location.required
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	logger.Debug.Process("Estimated disk space for %d package(s)", estimate.Packages)
+
+	var violations []string
+	for _, id := range ids {
+		fs := filesystems[id]
+		paths := strings.Join(fs.paths, " and ")
+		logger.Debug.Subprocess("%s: %s required, %s available", paths, formatBytes(int64(fs.required)), formatBytes(int64(fs.available)))
+
+		if fs.available < fs.required {
+			violations = append(violations, fmt.Sprintf("%s requires an estimated %s, but only %s is available", paths, formatBytes(int64(fs.required)), formatBytes(int64(fs.available))))
+		}
+	}
+	logger.Debug.Break()
+
+	if len(violations) > 0 {
+		return fmt.Errorf("not enough disk space to install %d package(s): %s (set %s to false to skip this check)",
+			estimate.Packages, strings.Join(violations, "; "), BpComposerDiskSpaceCheck)
+	}
+
+	return nil
+}
+
+// StatfsDiskSpace determines the available space with statfs(2).
+type StatfsDiskSpace struct{}
+
+func NewStatfsDiskSpace() StatfsDiskSpace {
+	return StatfsDiskSpace{}
+}
+
+// Available returns the device of the given path as the filesystem, and the
+// space available to unprivileged users on it.
+func (StatfsDiskSpace) Available(path string) (uint64, uint64, error) {
+	var stat syscall.Stat_t
+	err := syscall.Stat(path, &stat)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var statfs syscall.Statfs_t
+	err = syscall.Statfs(path, &statfs)
+	if err != nil { // untested
+		return 0, 0, err
+	}
+
+	return uint64(stat.Dev), uint64(statfs.Bavail) * uint64(statfs.Bsize), nil
+}
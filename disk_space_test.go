@@ -0,0 +1,87 @@
+package composer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testDiskSpace(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		workingDir = t.TempDir()
+	})
+
+	context("EstimateDiskSpace", func() {
+		it("estimates the space for each package with files to install", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+	"packages": [
+		{"name": "some/package", "dist": {"type": "zip"}},
+		{"name": "some/meta-package", "type": "metapackage"},
+		{"name": "some/local-package", "dist": {"type": "path"}}
+	],
+	"packages-dev": [
+		{"name": "some/dev-package", "source": {"type": "git"}}
+	]
+}`), os.ModePerm)).To(Succeed())
+
+			estimate, err := composer.EstimateDiskSpace(filepath.Join(workingDir, "composer.lock"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(estimate).To(Equal(composer.DiskSpaceEstimate{
+				Packages:  2,
+				Workspace: 2 * composer.EstimatedPackageSize,
+				Layers:    4 * composer.EstimatedPackageSize,
+			}))
+		})
+
+		it("returns an empty estimate if composer.lock does not exist", func() {
+			estimate, err := composer.EstimateDiskSpace(filepath.Join(workingDir, "composer.lock"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(estimate).To(Equal(composer.DiskSpaceEstimate{}))
+		})
+
+		context("failure cases", func() {
+			context("when composer.lock is not valid JSON", func() {
+				it("returns an error", func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`%%%`), os.ModePerm)).To(Succeed())
+
+					_, err := composer.EstimateDiskSpace(filepath.Join(workingDir, "composer.lock"))
+					Expect(err).To(MatchError(ContainSubstring("invalid character")))
+				})
+			})
+		})
+	})
+
+	context("StatfsDiskSpace", func() {
+		it("returns the same filesystem for paths on the same device", func() {
+			Expect(os.Mkdir(filepath.Join(workingDir, "some-dir"), os.ModePerm)).To(Succeed())
+
+			first, available, err := composer.NewStatfsDiskSpace().Available(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(available).To(BeNumerically(">", 0))
+
+			second, _, err := composer.NewStatfsDiskSpace().Available(filepath.Join(workingDir, "some-dir"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(second).To(Equal(first))
+		})
+
+		context("failure cases", func() {
+			context("when the path does not exist", func() {
+				it("returns an error", func() {
+					_, _, err := composer.NewStatfsDiskSpace().Available(filepath.Join(workingDir, "missing"))
+					Expect(err).To(MatchError(os.ErrNotExist))
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,260 @@
+package composer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/paketo-buildpacks/packit/v2/chronos"
+)
+
+// slowestDownloadsCount is the number of the slowest package downloads which
+// are logged at DEBUG level.
+const slowestDownloadsCount = 10
+
+// downloadMetricsVerbosity makes `composer install` log each HTTP request, as
+// if it was run with `-vvv`, without changing its arguments.
+// https://symfony.com/doc/current/console/verbosity.html
+const downloadMetricsVerbosity = "SHELL_VERBOSITY=3"
+
+var (
+	downloadStartPattern    = regexp.MustCompile(`Downloading (https?://\S+)`)
+	downloadEndPattern      = regexp.MustCompile(`^\s*\[(\d{3})\] (https?://\S+)`)
+	downloadRedirectPattern = regexp.MustCompile(`Following redirect \(\d+\) (https?://\S+)`)
+)
+
+// PackageDownload is the download of the dist archive of a package by
+// `composer install`.
+type PackageDownload struct {
+	Name     string
+	Duration time.Duration
+
+	// Size is the size of the archive in Composer's files cache, or 0 if it
+	// has not been cached
+	Size int64
+}
+
+type startedDownload struct {
+	name  string
+	start time.Time
+}
+
+// DownloadMetrics records the duration of each package download from the
+// verbose output of `composer install`. Each download starts with the line
+// "Downloading <url>" and ends with "[<status>] <url>". The URLs are mapped to
+// packages by their "dist.url" in `composer.lock`, and redirects are followed.
+type DownloadMetrics struct {
+	clock     chronos.Clock
+	packages  map[string]string
+	started   map[string]startedDownload
+	redirects map[string]startedDownload
+	last      string
+	pending   []byte
+	downloads []PackageDownload
+}
+
+// NewDownloadMetrics reads the dist URLs of the packages from the given
+// `composer.lock`. Without `composer.lock`, no download is recorded.
+func NewDownloadMetrics(clock chronos.Clock, composerLockPath string) (*DownloadMetrics, error) {
+	metrics := &DownloadMetrics{
+		clock:     clock,
+		packages:  map[string]string{},
+		started:   map[string]startedDownload{},
+		redirects: map[string]startedDownload{},
+	}
+
+	content, err := readComposerFile(composerLockPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return metrics, nil
+		}
+		return nil, err
+	}
+
+	type lockedPackage struct {
+		Name string `json:"name"`
+		Dist struct {
+			URL string `json:"url"`
+		} `json:"dist"`
+	}
+
+	var composerLock struct {
+		Packages    []lockedPackage `json:"packages"`
+		PackagesDev []lockedPackage `json:"packages-dev"`
+	}
+
+	err = json.Unmarshal(content, &composerLock)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range append(composerLock.Packages, composerLock.PackagesDev...) {
+		if p.Dist.URL != "" {
+			metrics.packages[p.Dist.URL] = p.Name
+		}
+	}
+
+	return metrics, nil
+}
+
+// Writer returns a writer which passes the output through to the given
+// writer, and records the downloads from it.
+func (m *DownloadMetrics) Writer(w io.Writer) io.Writer {
+	return io.MultiWriter(w, m)
+}
+
+func (m *DownloadMetrics) Write(p []byte) (int, error) {
+	m.pending = append(m.pending, p...)
+
+	for {
+		i := bytes.IndexByte(m.pending, '\n')
+		if i < 0 {
+			break
+		}
+
+		m.parseLine(string(m.pending[:i]))
+		m.pending = m.pending[i+1:]
+	}
+
+	return len(p), nil
+}
+
+func (m *DownloadMetrics) parseLine(line string) {
+	if matches := downloadEndPattern.FindStringSubmatch(line); matches != nil {
+		url := matches[2]
+		download, ok := m.started[url]
+		if !ok {
+			return
+		}
+		delete(m.started, url)
+		m.last = ""
+
+		switch matches[1][0] {
+		case '2':
+			m.downloads = append(m.downloads, PackageDownload{
+				Name:     download.name,
+				Duration: m.clock.Now().Sub(download.start),
+			})
+		case '3':
+			m.last = url
+			m.redirects[url] = download
+		}
+		return
+	}
+
+	if matches := downloadRedirectPattern.FindStringSubmatch(line); matches != nil {
+		if download, ok := m.redirects[m.last]; ok {
+			delete(m.redirects, m.last)
+			m.redirects[matches[1]] = download
+		}
+		m.last = ""
+		return
+	}
+
+	if matches := downloadStartPattern.FindStringSubmatch(line); matches != nil {
+		url := matches[1]
+		if download, ok := m.redirects[url]; ok {
+			delete(m.redirects, url)
+			m.started[url] = download
+			return
+		}
+
+		if name, ok := m.packages[url]; ok {
+			m.started[url] = startedDownload{name: name, start: m.clock.Now()}
+		}
+	}
+}
+
+// Downloads returns the recorded downloads, the slowest first. The size of
+// each download is the size of the most recent archive of the package in
+// the given files cache of Composer.
+func (m *DownloadMetrics) Downloads(cacheFilesDir string) ([]PackageDownload, error) {
+	var downloads []PackageDownload
+	for _, download := range m.downloads {
+		archives, err := filepath.Glob(filepath.Join(cacheFilesDir, filepath.FromSlash(download.Name), "*"))
+		if err != nil { // untested
+			return nil, err
+		}
+
+		var modified time.Time
+		for _, archive := range archives {
+			info, err := os.Stat(archive)
+			if err != nil {
+				return nil, err
+			}
+
+			if info.Mode().IsRegular() && info.ModTime().After(modified) {
+				modified = info.ModTime()
+				download.Size = info.Size()
+			}
+		}
+
+		downloads = append(downloads, download)
+	}
+
+	sort.SliceStable(downloads, func(i, j int) bool {
+		return downloads[i].Duration > downloads[j].Duration
+	})
+
+	return downloads, nil
+}
+
+// newDownloadMetricsIfRequired returns DownloadMetrics if "BP_LOG_LEVEL" is
+// set to DEBUG, and nil otherwise.
+func newDownloadMetricsIfRequired(clock chronos.Clock, composerLockPath string) (*DownloadMetrics, error) {
+	if os.Getenv(BpLogLevel) != "DEBUG" {
+		return nil, nil
+	}
+
+	return NewDownloadMetrics(clock, composerLockPath)
+}
+
+// reportDownloadMetrics logs the slowest package downloads at DEBUG level,
+// and records the aggregates in the given metadata of the composer-packages
+// layer:
+//   - downloaded-packages: the number of downloaded packages
+//   - downloaded-bytes: the total size of the downloaded archives
+//   - download-time: the total duration of the downloads, which may exceed
+//     the duration of `composer install`, as packages are downloaded in
+//     parallel
+func reportDownloadMetrics(logger emitter, metrics *DownloadMetrics, cacheFilesDir string, metadata map[string]interface{}) error {
+	downloads, err := metrics.Downloads(cacheFilesDir)
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	var duration time.Duration
+	for _, download := range downloads {
+		size += download.Size
+		duration += download.Duration
+	}
+
+	count := len(downloads)
+	logger.Debug.Process("Downloaded %d package(s), %s in %s", count, formatBytes(size), duration.Round(time.Millisecond))
+
+	if len(downloads) > slowestDownloadsCount {
+		downloads = downloads[:slowestDownloadsCount]
+	}
+
+	if len(downloads) > 0 {
+		logger.Debug.Subprocess("Slowest downloads:")
+		for _, download := range downloads {
+			logger.Debug.Subprocess(fmt.Sprintf("- %s: %s, %s", download.Name, download.Duration.Round(time.Millisecond), formatBytes(download.Size)))
+		}
+	}
+	logger.Debug.Break()
+
+	metadata["downloaded-packages"] = count
+	metadata["downloaded-bytes"] = size
+	metadata["download-time"] = duration.Round(time.Millisecond).String()
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package composer_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testDownloadMetrics(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir       string
+		cacheFilesDir    string
+		composerLockPath string
+		clock            chronos.Clock
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		cacheFilesDir, err = os.MkdirTemp("", "cache-files")
+		Expect(err).NotTo(HaveOccurred())
+
+		composerLockPath = filepath.Join(workingDir, "composer.lock")
+		Expect(os.WriteFile(composerLockPath, []byte(`{
+    "packages": [
+        {"name": "vendor/a", "dist": {"url": "https://api.github.com/repos/vendor/a/zipball/aaa"}},
+        {"name": "vendor/b", "dist": {"url": "https://repo.example.com/dists/vendor/b/1.0.0.zip"}}
+    ],
+    "packages-dev": [
+        {"name": "vendor/c", "dist": {"url": "https://repo.example.com/dists/vendor/c/2.0.0.zip"}},
+        {"name": "vendor/d", "source": {"url": "https://github.com/vendor/d.git"}}
+    ]
+}`), os.ModePerm)).To(Succeed())
+
+		// each reading of the clock is one second after the previous one
+		now := time.Unix(0, 0)
+		clock = chronos.NewClock(func() time.Time {
+			now = now.Add(time.Second)
+			return now
+		})
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+		Expect(os.RemoveAll(cacheFilesDir)).To(Succeed())
+	})
+
+	it("records the duration of each package download, the slowest first", func() {
+		metrics, err := composer.NewDownloadMetrics(clock, composerLockPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		buffer := bytes.NewBuffer(nil)
+		output := metrics.Writer(buffer)
+
+		_, err = fmt.Fprint(output, `Downloading https://repo.example.com/dists/vendor/b/1.0.0.zip
+Downloading https://repo.example.com/packages.json
+[200] https://repo.example.com/dists/vendor/b/1.0.0.zip
+Downloading https://repo.example.com/dists/vendor/c/2.0.0.zip
+[200] https://repo.example.com/packages.json
+`)
+		Expect(err).NotTo(HaveOccurred())
+
+		// lines may be written in parts
+		_, err = fmt.Fprint(output, "[404] https://repo.example.com/dists/vendor/c/2.0.0")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = fmt.Fprint(output, ".zip\n  - Installing vendor/b (1.0.0): Extracting archive\n")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(buffer.String()).To(ContainSubstring("[404] https://repo.example.com/dists/vendor/c/2.0.0.zip\n"))
+
+		Expect(os.MkdirAll(filepath.Join(cacheFilesDir, "vendor", "b"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(cacheFilesDir, "vendor", "b", "old.zip"), []byte("old"), os.ModePerm)).To(Succeed())
+		Expect(os.Chtimes(filepath.Join(cacheFilesDir, "vendor", "b", "old.zip"), time.Unix(0, 0), time.Unix(0, 0))).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(cacheFilesDir, "vendor", "b", "new.zip"), []byte("new archive"), os.ModePerm)).To(Succeed())
+
+		downloads, err := metrics.Downloads(cacheFilesDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(downloads).To(Equal([]composer.PackageDownload{
+			{Name: "vendor/b", Duration: time.Second, Size: 11},
+		}))
+	})
+
+	it("follows redirects", func() {
+		metrics, err := composer.NewDownloadMetrics(clock, composerLockPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = fmt.Fprint(metrics.Writer(bytes.NewBuffer(nil)), `Downloading https://api.github.com/repos/vendor/a/zipball/aaa
+Downloading https://repo.example.com/dists/vendor/b/1.0.0.zip
+[302] https://api.github.com/repos/vendor/a/zipball/aaa
+Following redirect (1) https://codeload.github.com/vendor/a/legacy.zip/aaa
+Downloading https://codeload.github.com/vendor/a/legacy.zip/aaa
+[200] https://repo.example.com/dists/vendor/b/1.0.0.zip
+[200] https://codeload.github.com/vendor/a/legacy.zip/aaa
+`)
+		Expect(err).NotTo(HaveOccurred())
+
+		downloads, err := metrics.Downloads(cacheFilesDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(downloads).To(Equal([]composer.PackageDownload{
+			{Name: "vendor/a", Duration: 3 * time.Second},
+			{Name: "vendor/b", Duration: time.Second},
+		}))
+	})
+
+	context("when there is no composer.lock", func() {
+		it("does not record any download", func() {
+			metrics, err := composer.NewDownloadMetrics(clock, filepath.Join(workingDir, "missing.lock"))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = fmt.Fprint(metrics.Writer(bytes.NewBuffer(nil)), `Downloading https://repo.example.com/dists/vendor/b/1.0.0.zip
+[200] https://repo.example.com/dists/vendor/b/1.0.0.zip
+`)
+			Expect(err).NotTo(HaveOccurred())
+
+			downloads, err := metrics.Downloads(cacheFilesDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(downloads).To(BeEmpty())
+		})
+	})
+
+	context("failure cases", func() {
+		context("when composer.lock is invalid", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(composerLockPath, []byte("%%%"), os.ModePerm)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := composer.NewDownloadMetrics(clock, composerLockPath)
+				Expect(err).To(MatchError(ContainSubstring("invalid character")))
+			})
+		})
+	})
+}
@@ -0,0 +1,133 @@
+package composer
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+const (
+	drupalScaffoldLayerDir = "drupal-scaffold"
+	drupalScaffoldPackage  = "drupal/core-composer-scaffold"
+	drupalScaffoldWebRoot  = "[web-root]"
+	drupalScaffoldProject  = "[project-root]"
+)
+
+type drupalScaffoldExtra struct {
+	DrupalScaffold struct {
+		Locations   map[string]string      `json:"locations"`
+		FileMapping map[string]interface{} `json:"file-mapping"`
+	} `json:"drupal-scaffold"`
+}
+
+// FindDrupalScaffoldFiles determines which files have been placed into the
+// workspace by the `drupal/core-composer-scaffold` Composer plugin.
+// https://www.drupal.org/docs/develop/using-composer/using-drupals-composer-scaffold
+//
+// The scaffold plugin only runs as part of `composer install`, so these files
+// need to be cached alongside the vendor directory to be able to restore them
+// when a cached layer is reused.
+//
+// The file mappings are read from the root `composer.json` and from all
+// installed packages listed in `{vendorDir}/composer/installed.json`.
+// Mappings which have been disabled (set to `false`) are skipped, as are
+// files which do not exist in the workspace.
+//
+// Returns the paths relative to the working directory, or an empty list if
+// the project does not require `drupal/core-composer-scaffold`.
+func FindDrupalScaffoldFiles(workingDir, composerJsonPath, vendorDir string) ([]string, error) {
+	var composerJson struct {
+		Require    map[string]string   `json:"require"`
+		RequireDev map[string]string   `json:"require-dev"`
+		Extra      drupalScaffoldExtra `json:"extra"`
+	}
+
+	content, err := readComposerFile(composerJsonPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	err = json.Unmarshal(content, &composerJson)
+	if err != nil {
+		return nil, err
+	}
+
+	_, required := composerJson.Require[drupalScaffoldPackage]
+	_, requiredDev := composerJson.RequireDev[drupalScaffoldPackage]
+	if !required && !requiredDev {
+		return nil, nil
+	}
+
+	projectRoot := filepath.Dir(composerJsonPath)
+
+	webRoot := projectRoot
+	if location, ok := composerJson.Extra.DrupalScaffold.Locations["web-root"]; ok {
+		webRoot = filepath.Join(projectRoot, location)
+	}
+
+	installedPackages, err := ReadInstalledPackages(filepath.Join(vendorDir, "composer", "installed.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	// the file mapping of the root package takes precedence over the file
+	// mappings of the installed packages, so it is applied last
+	var mappings []map[string]interface{}
+	for _, installedPackage := range installedPackages {
+		mappings = append(mappings, installedPackage.Extra.DrupalScaffold.FileMapping)
+	}
+	mappings = append(mappings, composerJson.Extra.DrupalScaffold.FileMapping)
+
+	destinations := map[string]bool{}
+	for _, mapping := range mappings {
+		for destination, source := range mapping {
+			if enabled, ok := source.(bool); ok && !enabled {
+				delete(destinations, destination)
+				continue
+			}
+			destinations[destination] = true
+		}
+	}
+
+	var files []string
+	for destination := range destinations {
+		var path string
+		switch {
+		case strings.HasPrefix(destination, drupalScaffoldWebRoot):
+			path = filepath.Join(webRoot, strings.TrimPrefix(destination, drupalScaffoldWebRoot))
+		case strings.HasPrefix(destination, drupalScaffoldProject):
+			path = filepath.Join(projectRoot, strings.TrimPrefix(destination, drupalScaffoldProject))
+		default:
+			continue
+		}
+
+		if exists, err := fs.Exists(path); err != nil {
+			return nil, err
+		} else if !exists {
+			continue
+		}
+
+		relativePath, err := filepath.Rel(workingDir, path)
+		if err != nil { // untested
+			return nil, err
+		}
+
+		if strings.HasPrefix(relativePath, "..") {
+			continue
+		}
+
+		files = append(files, relativePath)
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
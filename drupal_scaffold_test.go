@@ -0,0 +1,166 @@
+package composer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testDrupalScaffold(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+		vendorDir  string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		vendorDir = filepath.Join(workingDir, "vendor")
+		Expect(os.MkdirAll(filepath.Join(vendorDir, "composer"), os.ModePerm)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("when drupal/core-composer-scaffold is not required", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{
+	"require": {
+		"some/package": "^1.0"
+	}
+}`), os.ModePerm)).To(Succeed())
+		})
+
+		it("returns no files", func() {
+			files, err := composer.FindDrupalScaffoldFiles(workingDir, filepath.Join(workingDir, "composer.json"), vendorDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files).To(BeEmpty())
+		})
+	})
+
+	context("when drupal/core-composer-scaffold is required", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{
+	"require": {
+		"drupal/core-composer-scaffold": "^10.1"
+	},
+	"extra": {
+		"drupal-scaffold": {
+			"locations": {
+				"web-root": "web/"
+			},
+			"file-mapping": {
+				"[web-root]/robots.txt": false,
+				"[project-root]/.custom": "assets/custom"
+			}
+		}
+	}
+}`), os.ModePerm)).To(Succeed())
+
+			Expect(os.WriteFile(filepath.Join(vendorDir, "composer", "installed.json"), []byte(`{
+	"packages": [
+		{
+			"name": "drupal/core",
+			"extra": {
+				"drupal-scaffold": {
+					"file-mapping": {
+						"[project-root]/.editorconfig": "assets/scaffold/files/editorconfig",
+						"[web-root]/index.php": "assets/scaffold/files/index.php",
+						"[web-root]/robots.txt": "assets/scaffold/files/robots.txt",
+						"[web-root]/sites/default/default.settings.php": {
+							"path": "assets/scaffold/files/default.settings.php",
+							"overwrite": false
+						},
+						"[web-root]/update.php": "assets/scaffold/files/update.php"
+					}
+				}
+			}
+		}
+	]
+}`), os.ModePerm)).To(Succeed())
+
+			Expect(os.MkdirAll(filepath.Join(workingDir, "web", "sites", "default"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, ".editorconfig"), []byte(""), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, ".custom"), []byte(""), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "web", "index.php"), []byte(""), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "web", "robots.txt"), []byte(""), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "web", "sites", "default", "default.settings.php"), []byte(""), os.ModePerm)).To(Succeed())
+		})
+
+		it("returns the existing scaffold files which have not been disabled", func() {
+			files, err := composer.FindDrupalScaffoldFiles(workingDir, filepath.Join(workingDir, "composer.json"), vendorDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files).To(Equal([]string{
+				".custom",
+				".editorconfig",
+				filepath.Join("web", "index.php"),
+				filepath.Join("web", "sites", "default", "default.settings.php"),
+			}))
+		})
+
+		context("when installed.json uses the Composer 1 format", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(vendorDir, "composer", "installed.json"), []byte(`[
+	{
+		"name": "drupal/core",
+		"extra": {
+			"drupal-scaffold": {
+				"file-mapping": {
+					"[web-root]/index.php": "assets/scaffold/files/index.php"
+				}
+			}
+		}
+	}
+]`), os.ModePerm)).To(Succeed())
+			})
+
+			it("returns the existing scaffold files", func() {
+				files, err := composer.FindDrupalScaffoldFiles(workingDir, filepath.Join(workingDir, "composer.json"), vendorDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(files).To(Equal([]string{
+					".custom",
+					filepath.Join("web", "index.php"),
+				}))
+			})
+		})
+	})
+
+	context("failure cases", func() {
+		context("when composer.json is malformed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`%%%`), os.ModePerm)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := composer.FindDrupalScaffoldFiles(workingDir, filepath.Join(workingDir, "composer.json"), vendorDir)
+				Expect(err).To(MatchError(ContainSubstring("invalid character")))
+			})
+		})
+
+		context("when installed.json is malformed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{
+	"require": {
+		"drupal/core-composer-scaffold": "^10.1"
+	}
+}`), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(vendorDir, "composer", "installed.json"), []byte(`%%%`), os.ModePerm)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := composer.FindDrupalScaffoldFiles(workingDir, filepath.Join(workingDir, "composer.json"), vendorDir)
+				Expect(err).To(MatchError(ContainSubstring("invalid character")))
+			})
+		})
+	})
+}
@@ -0,0 +1,58 @@
+package composer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+// runInstallDryRunIfRequired will check for env var
+// "BP_COMPOSER_DRY_RUN_CHECK". If set to true, `composer install --dry-run`
+// runs with the given options before the composer-packages layer is reset,
+// so that dependencies which cannot be resolved or downloaded fail the build
+// while the previously cached layer is still intact. It neither writes the
+// vendor directory nor runs any scripts.
+func runInstallDryRunIfRequired(
+	logger emitter,
+	composerInstallExec Executable,
+	installOptions []InstallOption,
+	workingDir,
+	composerJsonPath,
+	composerHome,
+	workspaceVendorDir,
+	composerPhpIniPath,
+	path string) error {
+	enabled, err := lookupBoolEnv(BpComposerDryRunCheck, false)
+	if err != nil {
+		return err
+	}
+
+	if !enabled {
+		return nil
+	}
+
+	args := append(composerInstallArgs(installOptions), "--dry-run")
+	logger.Process("Running 'composer %s' before resetting the layer", strings.Join(args, " "))
+
+	err = composerInstallExec.Execute(pexec.Execution{
+		Args: args,
+		Dir:  workingDir,
+		Env: append(os.Environ(),
+			"COMPOSER_NO_INTERACTION=1", // https://getcomposer.org/doc/03-cli.md#composer-no-interaction
+			fmt.Sprintf("COMPOSER=%s", composerJsonPath),
+			fmt.Sprintf("COMPOSER_HOME=%s", composerHome),
+			fmt.Sprintf("COMPOSER_VENDOR_DIR=%s", workspaceVendorDir),
+			fmt.Sprintf("PHPRC=%s", composerPhpIniPath),
+			fmt.Sprintf("PATH=%s", path),
+		),
+		Stdout: logger.ActionWriter,
+		Stderr: logger.ActionWriter,
+	})
+	if err != nil {
+		return fmt.Errorf("'composer install --dry-run' failed, the cached layer has been kept: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package composer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// lookupBoolEnv parses the environment variable with the given name as a
+// boolean. If the variable is not set, defaultValue is returned.
+func lookupBoolEnv(name string, defaultValue bool) (bool, error) {
+	valueStr, found := os.LookupEnv(name)
+	if !found {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		return false, fmt.Errorf("error when parsing env var %q: %w", name, err)
+	}
+
+	return value, nil
+}
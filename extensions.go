@@ -0,0 +1,469 @@
+package composer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+const (
+	// PhpExtensionsContractFileName is the name of the file in the
+	// `.php.ini.d` directory of the working directory, which lists the PHP
+	// extensions required at runtime in a machine-readable format.
+	PhpExtensionsContractFileName = "php-extensions.toml"
+
+	// PhpExtensionsContractVersion is the version of the format of
+	// php-extensions.toml. It is incremented for incompatible changes only.
+	PhpExtensionsContractVersion = 1
+
+	// ProvidedExtensionsMetadataKey is the key of the build plan metadata,
+	// through which other buildpacks requiring "composer-packages", such as
+	// buildpacks building PHP extensions, declare the extensions they provide.
+	ProvidedExtensionsMetadataKey = "provides-extensions"
+
+	// composerExtensionsIniFileName is the name of the INI file in the
+	// `.php.ini.d` directory of the working directory, which loads the PHP
+	// extensions required at runtime.
+	composerExtensionsIniFileName = "composer-extensions.ini"
+)
+
+// PhpExtensionSource describes why a PHP extension is required.
+type PhpExtensionSource string
+
+const (
+	// PhpExtensionSourceComposer means the extension is required by the
+	// dependencies, as reported by `composer check-platform-reqs`
+	PhpExtensionSourceComposer PhpExtensionSource = "composer"
+
+	// PhpExtensionSourceBootstrap means the extension is required by
+	// Composer itself, see BP_COMPOSER_BOOTSTRAP_EXTENSIONS
+	PhpExtensionSourceBootstrap PhpExtensionSource = "bootstrap"
+
+	// PhpExtensionSourceUser means the extension has been listed in
+	// BP_COMPOSER_EXTENSIONS_INCLUDE
+	PhpExtensionSourceUser PhpExtensionSource = "user"
+)
+
+// PhpExtension is a PHP extension required at runtime.
+type PhpExtension struct {
+	// Name is the name of the extension, without the "ext-" prefix
+	Name string `toml:"name"`
+
+	// Constraint is the version constraint of the requirement, if known
+	Constraint string `toml:"constraint,omitempty"`
+
+	Source PhpExtensionSource `toml:"source"`
+}
+
+// PhpExtensionsContract is the content of php-extensions.toml, e.g.
+//
+//	version = 1
+//
+//	[[extensions]]
+//	name = "gd"
+//	constraint = "*"
+//	source = "composer"
+//
+//	[target]
+//	os = "linux"
+//	arch = "amd64"
+//	distro-name = "ubuntu"
+//	distro-version = "24.04"
+type PhpExtensionsContract struct {
+	Version    int            `toml:"version"`
+	Extensions []PhpExtension `toml:"extensions"`
+
+	// Target is the target the extensions are required for, if the platform
+	// provides its distribution
+	Target *BuildTarget `toml:"target,omitempty"`
+}
+
+// requirementConstraintPattern matches the requirement in the output of
+// `composer check-platform-reqs`, e.g. "vendor/package requires ext-gd (^2.0)"
+var requirementConstraintPattern = regexp.MustCompile(`(\S+) requires \S+ \(([^)]*)\)`)
+
+// parsePlatformRequirementsText parses the text output of
+// `composer check-platform-reqs`, e.g.
+//
+//	ext-gd       n/a   vendor/package requires ext-gd (^2.0)      missing
+func parsePlatformRequirementsText(output string) []platformRequirement {
+	var requirements []platformRequirement
+	for _, line := range splitOutputLines(output) {
+		chunks := strings.Fields(line)
+		if len(chunks) == 0 {
+			continue
+		}
+
+		requirement := platformRequirement{Name: chunks[0], Status: chunks[len(chunks)-1]}
+		if len(chunks) > 2 {
+			requirement.Version = chunks[1]
+		}
+
+		if matches := requirementConstraintPattern.FindStringSubmatch(line); matches != nil {
+			requirement.FailedRequirement = &failedPlatformRequirement{Source: matches[1], Constraint: matches[2]}
+		}
+
+		requirements = append(requirements, requirement)
+	}
+
+	return requirements
+}
+
+// missingTextExtensions returns the extensions reported as "missing" in the
+// text output of `composer check-platform-reqs`, see
+// parsePlatformRequirementsText.
+func missingTextExtensions(requirements []platformRequirement) []PhpExtension {
+	var extensions []PhpExtension
+	for _, requirement := range requirements {
+		extensionName := strings.TrimPrefix(requirement.Name, "ext-")
+		if extensionName == "php" || extensionName == "php-64bit" || requirement.Status != "missing" {
+			continue
+		}
+
+		extension := PhpExtension{Name: extensionName, Source: PhpExtensionSourceComposer}
+		if requirement.FailedRequirement != nil {
+			extension.Constraint = requirement.FailedRequirement.Constraint
+		}
+
+		extensions = append(extensions, extension)
+	}
+
+	return extensions
+}
+
+// platformRequirement is a requirement in the output of
+// `composer check-platform-reqs --format=json`, e.g.
+//
+//	{
+//	    "name": "ext-gd",
+//	    "version": "n/a",
+//	    "status": "missing",
+//	    "failed_requirement": {
+//	        "source": "vendor/package",
+//	        "type": "requires",
+//	        "target": "ext-gd",
+//	        "constraint": "^2.0"
+//	    },
+//	    "provider": null
+//	}
+type platformRequirement struct {
+	Name              string                     `json:"name"`
+	Version           string                     `json:"version"`
+	Status            string                     `json:"status"`
+	FailedRequirement *failedPlatformRequirement `json:"failed_requirement"`
+}
+
+// failedPlatformRequirement is the requirement of a package, which the
+// platform does not satisfy.
+type failedPlatformRequirement struct {
+	Source     string `json:"source"`
+	Constraint string `json:"constraint"`
+}
+
+// parsePlatformRequirements parses the output of
+// `composer check-platform-reqs --format=json`.
+func parsePlatformRequirements(output string) ([]platformRequirement, error) {
+	var requirements []platformRequirement
+	err := json.Unmarshal(bytes.TrimPrefix([]byte(output), utf8BOM), &requirements)
+	if err != nil {
+		return nil, err
+	}
+
+	return requirements, nil
+}
+
+// missingPlatformExtensions returns the extensions of the given requirements
+// with status "missing", with the constraint of the failed requirement.
+// Extensions with status "failed" are loaded already, but in a version not
+// satisfying the constraint, which loading them cannot fix, so they are only
+// reported. Requirements other than extensions, e.g. "php" or "lib-icu", are
+// not provided by loading extensions and are ignored.
+func missingPlatformExtensions(logger emitter, requirements []platformRequirement) []PhpExtension {
+	var extensions []PhpExtension
+	for _, requirement := range requirements {
+		logger.Action("%-24s %-12s %s", requirement.Name, requirement.Version, requirement.Status)
+
+		if !strings.HasPrefix(requirement.Name, "ext-") {
+			continue
+		}
+
+		var source, constraint string
+		if requirement.FailedRequirement != nil {
+			source, constraint = requirement.FailedRequirement.Source, requirement.FailedRequirement.Constraint
+		}
+
+		switch requirement.Status {
+		case "missing":
+			extensions = append(extensions, PhpExtension{
+				Name:       strings.TrimPrefix(requirement.Name, "ext-"),
+				Constraint: constraint,
+				Source:     PhpExtensionSourceComposer,
+			})
+		case "failed":
+			logger.Subprocess("WARNING: %s %s does not satisfy the constraint %q of %s", requirement.Name, requirement.Version, constraint, source)
+		}
+	}
+
+	return extensions
+}
+
+// lookupExtensionsEnv parses the environment variable with the given name as
+// a space-delimited list of PHP extensions. The extensions can be given with
+// or without the "ext-" prefix used by Composer, e.g. "ext-gd" or "gd".
+func lookupExtensionsEnv(name string) []string {
+	var extensions []string
+	for _, extension := range strings.Fields(os.Getenv(name)) {
+		extensions = append(extensions, strings.TrimPrefix(extension, "ext-"))
+	}
+
+	return extensions
+}
+
+// lookupBootstrapExtensions returns the PHP extensions which are required by
+// Composer itself, as set in "BP_COMPOSER_BOOTSTRAP_EXTENSIONS". The
+// extensions can be separated by commas or spaces. Defaults to openssl.
+func lookupBootstrapExtensions() []string {
+	var extensions []string
+	for _, extension := range strings.FieldsFunc(os.Getenv(BpComposerBootstrapExtensions), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		extensions = append(extensions, strings.TrimPrefix(extension, "ext-"))
+	}
+
+	if len(extensions) == 0 {
+		return []string{opensslExtension}
+	}
+
+	return extensions
+}
+
+// includeExtensions adds the extensions listed in
+// "BP_COMPOSER_EXTENSIONS_INCLUDE" to the given extensions, e.g. because they
+// are only needed by code paths which Composer does not know about, such as
+// suggested packages.
+func includeExtensions(logger emitter, extensions []PhpExtension) []PhpExtension {
+	found := map[string]bool{}
+	for _, extension := range extensions {
+		found[extension.Name] = true
+	}
+
+	var added []string
+	for _, name := range lookupExtensionsEnv(BpComposerExtensionsInclude) {
+		if found[name] {
+			continue
+		}
+		found[name] = true
+		added = append(added, name)
+		extensions = append(extensions, PhpExtension{Name: name, Source: PhpExtensionSourceUser})
+	}
+
+	if len(added) > 0 {
+		logger.Subprocess("Including extensions '%s' as set in %s", strings.Join(added, ", "), BpComposerExtensionsInclude)
+	}
+
+	return extensions
+}
+
+// excludeExtensions removes the extensions listed in
+// "BP_COMPOSER_EXTENSIONS_EXCLUDE" from the given extensions, e.g. because
+// they are compiled statically into PHP and loading them again would result
+// in warnings.
+func excludeExtensions(logger emitter, extensions []PhpExtension) []PhpExtension {
+	excluded := map[string]bool{}
+	for _, name := range lookupExtensionsEnv(BpComposerExtensionsExclude) {
+		excluded[name] = true
+	}
+
+	if len(excluded) == 0 {
+		return extensions
+	}
+
+	var kept []PhpExtension
+	var removed []string
+	for _, extension := range extensions {
+		if excluded[extension.Name] {
+			removed = append(removed, extension.Name)
+			continue
+		}
+		kept = append(kept, extension)
+	}
+
+	if len(removed) > 0 {
+		logger.Subprocess("Excluding extensions '%s' as set in %s", strings.Join(removed, ", "), BpComposerExtensionsExclude)
+	}
+
+	return kept
+}
+
+// lookupProvidedExtensions returns the PHP extensions which other buildpacks
+// provide, as declared in the "provides-extensions" metadata of their
+// "composer-packages" build plan requirements, e.g.
+//
+//	[requires.metadata]
+//	provides-extensions = ["ext-foo", "bar"]
+//
+// The extensions can be given with or without the "ext-" prefix used by
+// Composer. Returns the extensions without the prefix, sorted by name.
+func lookupProvidedExtensions(plan packit.BuildpackPlan) []string {
+	found := map[string]bool{}
+	for _, entry := range plan.Entries {
+		if entry.Name != ComposerPackagesDependency {
+			continue
+		}
+
+		var values []string
+		switch provided := entry.Metadata[ProvidedExtensionsMetadataKey].(type) {
+		case string:
+			values = strings.Fields(provided)
+		case []string:
+			values = provided
+		case []interface{}:
+			for _, value := range provided {
+				if value, ok := value.(string); ok {
+					values = append(values, value)
+				}
+			}
+		}
+
+		for _, value := range values {
+			found[strings.TrimPrefix(strings.TrimSpace(value), "ext-")] = true
+		}
+	}
+
+	var extensions []string
+	for extension := range found {
+		if extension != "" {
+			extensions = append(extensions, extension)
+		}
+	}
+	sort.Strings(extensions)
+
+	return extensions
+}
+
+// ignoreProvidedExtensions adds an `--ignore-platform-req` option for each
+// of the given extensions, as they are provided by other buildpacks and may
+// not be available to `composer install` yet. Options which are already
+// present, or `--ignore-platform-reqs` ignoring all requirements, take
+// precedence.
+func ignoreProvidedExtensions(options []InstallOption, providedExtensions []string) []InstallOption {
+	present := map[string]bool{}
+	for _, option := range options {
+		present[option.Value] = true
+	}
+
+	if present["--ignore-platform-reqs"] {
+		return options
+	}
+
+	for _, extension := range providedExtensions {
+		value := fmt.Sprintf("--ignore-platform-req=ext-%s", extension)
+		if present[value] {
+			continue
+		}
+
+		options = append(options, InstallOption{Value: value, Source: InstallOptionSourceProvidedExtension})
+	}
+
+	return options
+}
+
+// skipProvidedExtensions removes the given extensions, which are loaded by
+// the buildpacks providing them, from the given extensions.
+func skipProvidedExtensions(logger emitter, extensions []PhpExtension, providedExtensions []string) []PhpExtension {
+	provided := map[string]bool{}
+	for _, name := range providedExtensions {
+		provided[name] = true
+	}
+
+	if len(provided) == 0 {
+		return extensions
+	}
+
+	var kept []PhpExtension
+	var skipped []string
+	for _, extension := range extensions {
+		if provided[extension.Name] {
+			skipped = append(skipped, extension.Name)
+			continue
+		}
+		kept = append(kept, extension)
+	}
+
+	if len(skipped) > 0 {
+		logger.Subprocess("Skipping extensions '%s' provided by other buildpacks", strings.Join(skipped, ", "))
+	}
+
+	return kept
+}
+
+// writePhpExtensions writes the given extensions into the `.php.ini.d`
+// directory of the working directory as php-extensions.toml and, unless
+// "BP_COMPOSER_EXTENSIONS_INI" is set to false, into the given directory as
+// composer-extensions.ini.
+func writePhpExtensions(logger emitter, fileSystem FileSystem, workingDir, extensionsIniDir string, extensions []PhpExtension) error {
+	writeIni, err := lookupBoolEnv(BpComposerExtensionsIni, true)
+	if err != nil {
+		return err
+	}
+
+	iniDir := filepath.Join(workingDir, ".php.ini.d")
+
+	err = fileSystem.MkdirAll(iniDir, 0755)
+	if err != nil { // untested
+		return err
+	}
+
+	var target *BuildTarget
+	if buildTarget := LookupBuildTarget(); buildTarget.Distro() != "" {
+		target = &buildTarget
+	}
+
+	contract := bytes.NewBufferString("# PHP extensions required at runtime, see https://github.com/ninech/buildpack-composer-install#php-extensionstoml\n")
+	err = toml.NewEncoder(contract).Encode(PhpExtensionsContract{
+		Version:    PhpExtensionsContractVersion,
+		Extensions: extensions,
+		Target:     target,
+	})
+	if err != nil { // untested
+		return err
+	}
+
+	logger.Debug.Subprocess("Writing %s", filepath.Join(iniDir, PhpExtensionsContractFileName))
+	err = writeGeneratedFile(fileSystem, filepath.Join(iniDir, PhpExtensionsContractFileName), contract.Bytes())
+	if err != nil { // untested
+		return err
+	}
+
+	if !writeIni {
+		logger.Subprocess("Skipping %s as %s is set to false", composerExtensionsIniFileName, BpComposerExtensionsIni)
+		return nil
+	}
+
+	buf := bytes.Buffer{}
+
+	if target != nil {
+		buf.WriteString(fmt.Sprintf("; PHP extensions required on %s\n", target))
+	}
+
+	for _, extension := range extensions {
+		buf.WriteString(fmt.Sprintf("extension = %s.so\n", extension.Name))
+	}
+
+	err = fileSystem.MkdirAll(extensionsIniDir, 0755)
+	if err != nil { // untested
+		return err
+	}
+
+	logger.Debug.Subprocess("Writing %s", filepath.Join(extensionsIniDir, composerExtensionsIniFileName))
+	return writeGeneratedFile(fileSystem, filepath.Join(extensionsIniDir, composerExtensionsIniFileName), buf.Bytes())
+}
@@ -0,0 +1,50 @@
+package composer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+const extraCachePathsLayerDir = "extra-cache-paths"
+
+// FindExtraCachePaths returns the paths listed in env var
+// "BP_COMPOSER_EXTRA_CACHE_PATHS" which exist in the working directory.
+//
+// The env var is a space-delimited list of paths relative to the project root,
+// such as directories written by Composer plugins or scripts during
+// `composer install` (e.g. `public/bundles` or `bootstrap/cache`).
+func FindExtraCachePaths(workingDir, _, _ string) ([]string, error) {
+	extraCachePaths, found := os.LookupEnv(BpComposerExtraCachePaths)
+	if !found {
+		return nil, nil
+	}
+
+	var paths []string
+	for _, path := range strings.Fields(extraCachePaths) {
+		relativePath, err := filepath.Rel(workingDir, filepath.Join(workingDir, path))
+		if err != nil { // untested
+			return nil, err
+		}
+
+		if filepath.IsAbs(path) || relativePath == "." || strings.HasPrefix(relativePath, "..") {
+			return nil, fmt.Errorf("%s must only contain relative paths underneath the project root, found %q", BpComposerExtraCachePaths, path)
+		}
+
+		if exists, err := fs.Exists(filepath.Join(workingDir, relativePath)); err != nil {
+			return nil, err
+		} else if !exists {
+			continue
+		}
+
+		paths = append(paths, relativePath)
+	}
+
+	sort.Strings(paths)
+
+	return paths, nil
+}
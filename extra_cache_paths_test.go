@@ -0,0 +1,81 @@
+package composer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testExtraCachePaths(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.MkdirAll(filepath.Join(workingDir, "public", "bundles"), os.ModePerm)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(workingDir, "bootstrap", "cache"), os.ModePerm)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+		Expect(os.Unsetenv("BP_COMPOSER_EXTRA_CACHE_PATHS")).To(Succeed())
+	})
+
+	context("when BP_COMPOSER_EXTRA_CACHE_PATHS is not set", func() {
+		it("returns no paths", func() {
+			paths, err := composer.FindExtraCachePaths(workingDir, "", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(BeEmpty())
+		})
+	})
+
+	context("when BP_COMPOSER_EXTRA_CACHE_PATHS is set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_COMPOSER_EXTRA_CACHE_PATHS", "public/bundles ./bootstrap/cache does/not/exist")).To(Succeed())
+		})
+
+		it("returns the existing paths", func() {
+			paths, err := composer.FindExtraCachePaths(workingDir, "", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(Equal([]string{
+				filepath.Join("bootstrap", "cache"),
+				filepath.Join("public", "bundles"),
+			}))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when BP_COMPOSER_EXTRA_CACHE_PATHS contains a path outside of the project root", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_EXTRA_CACHE_PATHS", "public/bundles ../outside")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := composer.FindExtraCachePaths(workingDir, "", "")
+				Expect(err).To(MatchError(`BP_COMPOSER_EXTRA_CACHE_PATHS must only contain relative paths underneath the project root, found "../outside"`))
+			})
+		})
+
+		context("when BP_COMPOSER_EXTRA_CACHE_PATHS contains an absolute path", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_EXTRA_CACHE_PATHS", "/etc")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := composer.FindExtraCachePaths(workingDir, "", "")
+				Expect(err).To(MatchError(`BP_COMPOSER_EXTRA_CACHE_PATHS must only contain relative paths underneath the project root, found "/etc"`))
+			})
+		})
+	})
+}
@@ -0,0 +1,54 @@
+package fakes
+
+import (
+	"sync"
+)
+
+type ComposerDownloader struct {
+	DownloadCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Version     string
+			Destination string
+		}
+		Returns struct {
+			Error error
+		}
+		Stub func(string, string) error
+	}
+	PublishedChecksumCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Version string
+		}
+		Returns struct {
+			String string
+			Error  error
+		}
+		Stub func(string) (string, error)
+	}
+}
+
+func (f *ComposerDownloader) Download(param1 string, param2 string) error {
+	f.DownloadCall.mutex.Lock()
+	defer f.DownloadCall.mutex.Unlock()
+	f.DownloadCall.CallCount++
+	f.DownloadCall.Receives.Version = param1
+	f.DownloadCall.Receives.Destination = param2
+	if f.DownloadCall.Stub != nil {
+		return f.DownloadCall.Stub(param1, param2)
+	}
+	return f.DownloadCall.Returns.Error
+}
+func (f *ComposerDownloader) PublishedChecksum(param1 string) (string, error) {
+	f.PublishedChecksumCall.mutex.Lock()
+	defer f.PublishedChecksumCall.mutex.Unlock()
+	f.PublishedChecksumCall.CallCount++
+	f.PublishedChecksumCall.Receives.Version = param1
+	if f.PublishedChecksumCall.Stub != nil {
+		return f.PublishedChecksumCall.Stub(param1)
+	}
+	return f.PublishedChecksumCall.Returns.String, f.PublishedChecksumCall.Returns.Error
+}
@@ -2,25 +2,34 @@ package fakes
 
 import (
 	"sync"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/paketo-buildpacks/packit/v2"
 )
 
 type DetermineComposerInstallOptions struct {
 	DetermineCall struct {
 		mutex     sync.Mutex
 		CallCount int
-		Returns   struct {
-			StringSlice []string
+		Receives  struct {
+			Plan          packit.BuildpackPlan
+			ProjectConfig map[string]string
+		}
+		Returns struct {
+			InstallOptionSlice []composer.InstallOption
 		}
-		Stub func() []string
+		Stub func(packit.BuildpackPlan, map[string]string) []composer.InstallOption
 	}
 }
 
-func (f *DetermineComposerInstallOptions) Determine() []string {
+func (f *DetermineComposerInstallOptions) Determine(param1 packit.BuildpackPlan, param2 map[string]string) []composer.InstallOption {
 	f.DetermineCall.mutex.Lock()
 	defer f.DetermineCall.mutex.Unlock()
 	f.DetermineCall.CallCount++
+	f.DetermineCall.Receives.Plan = param1
+	f.DetermineCall.Receives.ProjectConfig = param2
 	if f.DetermineCall.Stub != nil {
-		return f.DetermineCall.Stub()
+		return f.DetermineCall.Stub(param1, param2)
 	}
-	return f.DetermineCall.Returns.StringSlice
+	return f.DetermineCall.Returns.InstallOptionSlice
 }
@@ -0,0 +1,32 @@
+package fakes
+
+import (
+	"sync"
+)
+
+type DiskSpace struct {
+	AvailableCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Path string
+		}
+		Returns struct {
+			Filesystem uint64
+			Available  uint64
+			Err        error
+		}
+		Stub func(string) (uint64, uint64, error)
+	}
+}
+
+func (f *DiskSpace) Available(param1 string) (uint64, uint64, error) {
+	f.AvailableCall.mutex.Lock()
+	defer f.AvailableCall.mutex.Unlock()
+	f.AvailableCall.CallCount++
+	f.AvailableCall.Receives.Path = param1
+	if f.AvailableCall.Stub != nil {
+		return f.AvailableCall.Stub(param1)
+	}
+	return f.AvailableCall.Returns.Filesystem, f.AvailableCall.Returns.Available, f.AvailableCall.Returns.Err
+}
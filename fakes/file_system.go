@@ -0,0 +1,247 @@
+package fakes
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileSystem is a memory-backed file system. Files written with WriteFile
+// are kept in Files, keyed by their cleaned path. Paths which are not kept in
+// Files are passed through to the disk, as the build copies the contents of
+// its layers without FileSystem. Each operation returns the error of its
+// call, if set, without changing the files.
+type FileSystem struct {
+	mutex sync.Mutex
+	Files map[string][]byte
+
+	WriteFileCall struct {
+		CallCount int
+		Receives  struct {
+			Name string
+			Data []byte
+			Perm os.FileMode
+		}
+		Returns struct {
+			Error error
+		}
+	}
+	ReadDirCall struct {
+		CallCount int
+		Receives  struct {
+			Name string
+		}
+		Returns struct {
+			Error error
+		}
+	}
+	RemoveAllCall struct {
+		CallCount int
+		Receives  struct {
+			Path string
+		}
+		Returns struct {
+			Error error
+		}
+	}
+	MkdirAllCall struct {
+		CallCount int
+		Receives  struct {
+			Path string
+			Perm os.FileMode
+		}
+		Returns struct {
+			Error error
+		}
+	}
+	RenameCall struct {
+		CallCount int
+		Receives  struct {
+			Oldpath string
+			Newpath string
+		}
+		Returns struct {
+			Error error
+		}
+	}
+	ChmodCall struct {
+		CallCount int
+		Receives  struct {
+			Name string
+			Mode os.FileMode
+		}
+		Returns struct {
+			Error error
+		}
+	}
+}
+
+func NewFileSystem() *FileSystem {
+	return &FileSystem{Files: map[string][]byte{}}
+}
+
+func (f *FileSystem) WriteFile(param1 string, param2 []byte, param3 os.FileMode) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.WriteFileCall.CallCount++
+	f.WriteFileCall.Receives.Name = param1
+	f.WriteFileCall.Receives.Data = param2
+	f.WriteFileCall.Receives.Perm = param3
+	if f.WriteFileCall.Returns.Error != nil {
+		return f.WriteFileCall.Returns.Error
+	}
+
+	f.Files[filepath.Clean(param1)] = append([]byte{}, param2...)
+	return nil
+}
+
+// ReadDir returns the files and directories directly inside the given
+// directory, sorted by name.
+func (f *FileSystem) ReadDir(param1 string) ([]os.DirEntry, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.ReadDirCall.CallCount++
+	f.ReadDirCall.Receives.Name = param1
+	if f.ReadDirCall.Returns.Error != nil {
+		return nil, f.ReadDirCall.Returns.Error
+	}
+
+	prefix := filepath.Clean(param1) + string(filepath.Separator)
+	entries := map[string]memoryDirEntry{}
+	for path, data := range f.Files {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+
+		name, rest, isDir := strings.Cut(strings.TrimPrefix(path, prefix), string(filepath.Separator))
+		if isDir {
+			entries[name] = memoryDirEntry{name: name, dir: true}
+		} else if rest == "" {
+			entries[name] = memoryDirEntry{name: name, size: int64(len(data))}
+		}
+	}
+
+	if len(entries) == 0 {
+		return os.ReadDir(param1)
+	}
+
+	var result []os.DirEntry
+	for _, entry := range entries {
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name() < result[j].Name() })
+
+	return result, nil
+}
+
+func (f *FileSystem) RemoveAll(param1 string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.RemoveAllCall.CallCount++
+	f.RemoveAllCall.Receives.Path = param1
+	if f.RemoveAllCall.Returns.Error != nil {
+		return f.RemoveAllCall.Returns.Error
+	}
+
+	path := filepath.Clean(param1)
+	for name := range f.Files {
+		if name == path || strings.HasPrefix(name, path+string(filepath.Separator)) {
+			delete(f.Files, name)
+		}
+	}
+	return os.RemoveAll(param1)
+}
+
+func (f *FileSystem) MkdirAll(param1 string, param2 os.FileMode) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.MkdirAllCall.CallCount++
+	f.MkdirAllCall.Receives.Path = param1
+	f.MkdirAllCall.Receives.Perm = param2
+	if f.MkdirAllCall.Returns.Error != nil {
+		return f.MkdirAllCall.Returns.Error
+	}
+
+	return os.MkdirAll(param1, param2)
+}
+
+func (f *FileSystem) Rename(param1 string, param2 string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.RenameCall.CallCount++
+	f.RenameCall.Receives.Oldpath = param1
+	f.RenameCall.Receives.Newpath = param2
+	if f.RenameCall.Returns.Error != nil {
+		return f.RenameCall.Returns.Error
+	}
+
+	oldpath, newpath := filepath.Clean(param1), filepath.Clean(param2)
+	renamed := map[string][]byte{}
+	for name, data := range f.Files {
+		if name == oldpath || strings.HasPrefix(name, oldpath+string(filepath.Separator)) {
+			delete(f.Files, name)
+			renamed[newpath+strings.TrimPrefix(name, oldpath)] = data
+		}
+	}
+	if len(renamed) > 0 {
+		for name, data := range renamed {
+			f.Files[name] = data
+		}
+		return nil
+	}
+
+	return os.Rename(param1, param2)
+}
+
+// Chmod changes the mode of the given file on disk, unless it is kept in
+// Files, which do not record their mode.
+func (f *FileSystem) Chmod(param1 string, param2 os.FileMode) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.ChmodCall.CallCount++
+	f.ChmodCall.Receives.Name = param1
+	f.ChmodCall.Receives.Mode = param2
+	if f.ChmodCall.Returns.Error != nil {
+		return f.ChmodCall.Returns.Error
+	}
+
+	if _, ok := f.Files[filepath.Clean(param1)]; ok {
+		return nil
+	}
+
+	return os.Chmod(param1, param2)
+}
+
+type memoryDirEntry struct {
+	name string
+	dir  bool
+	size int64
+}
+
+func (e memoryDirEntry) Name() string { return e.name }
+func (e memoryDirEntry) IsDir() bool  { return e.dir }
+
+func (e memoryDirEntry) Type() fs.FileMode {
+	if e.dir {
+		return fs.ModeDir
+	}
+	return 0
+}
+
+func (e memoryDirEntry) Info() (fs.FileInfo, error) { return e, nil }
+
+func (e memoryDirEntry) Size() int64 { return e.size }
+
+func (e memoryDirEntry) Mode() fs.FileMode {
+	if e.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+func (e memoryDirEntry) ModTime() time.Time { return time.Time{} }
+func (e memoryDirEntry) Sys() interface{}   { return nil }
@@ -0,0 +1,95 @@
+package fakes
+
+import (
+	"sync"
+
+	"github.com/paketo-buildpacks/composer"
+)
+
+type Hook struct {
+	BeforeInstallCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Context composer.HookContext
+		}
+		Returns struct {
+			Error error
+		}
+		Stub func(composer.HookContext) error
+	}
+	AfterCacheRestoreCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Context composer.HookContext
+		}
+		Returns struct {
+			Error error
+		}
+		Stub func(composer.HookContext) error
+	}
+	AfterInstallCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Context composer.HookContext
+		}
+		Returns struct {
+			Error error
+		}
+		Stub func(composer.HookContext) error
+	}
+	BeforeSBOMCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Context composer.HookContext
+		}
+		Returns struct {
+			Error error
+		}
+		Stub func(composer.HookContext) error
+	}
+}
+
+func (f *Hook) BeforeInstall(param1 composer.HookContext) error {
+	f.BeforeInstallCall.mutex.Lock()
+	defer f.BeforeInstallCall.mutex.Unlock()
+	f.BeforeInstallCall.CallCount++
+	f.BeforeInstallCall.Receives.Context = param1
+	if f.BeforeInstallCall.Stub != nil {
+		return f.BeforeInstallCall.Stub(param1)
+	}
+	return f.BeforeInstallCall.Returns.Error
+}
+func (f *Hook) AfterCacheRestore(param1 composer.HookContext) error {
+	f.AfterCacheRestoreCall.mutex.Lock()
+	defer f.AfterCacheRestoreCall.mutex.Unlock()
+	f.AfterCacheRestoreCall.CallCount++
+	f.AfterCacheRestoreCall.Receives.Context = param1
+	if f.AfterCacheRestoreCall.Stub != nil {
+		return f.AfterCacheRestoreCall.Stub(param1)
+	}
+	return f.AfterCacheRestoreCall.Returns.Error
+}
+func (f *Hook) AfterInstall(param1 composer.HookContext) error {
+	f.AfterInstallCall.mutex.Lock()
+	defer f.AfterInstallCall.mutex.Unlock()
+	f.AfterInstallCall.CallCount++
+	f.AfterInstallCall.Receives.Context = param1
+	if f.AfterInstallCall.Stub != nil {
+		return f.AfterInstallCall.Stub(param1)
+	}
+	return f.AfterInstallCall.Returns.Error
+}
+func (f *Hook) BeforeSBOM(param1 composer.HookContext) error {
+	f.BeforeSBOMCall.mutex.Lock()
+	defer f.BeforeSBOMCall.mutex.Unlock()
+	f.BeforeSBOMCall.CallCount++
+	f.BeforeSBOMCall.Receives.Context = param1
+	if f.BeforeSBOMCall.Stub != nil {
+		return f.BeforeSBOMCall.Stub(param1)
+	}
+	return f.BeforeSBOMCall.Returns.Error
+}
@@ -0,0 +1,49 @@
+package fakes
+
+import (
+	"sync"
+
+	"github.com/paketo-buildpacks/composer"
+)
+
+type InstallStrategy struct {
+	InstallCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Context composer.InstallContext
+		}
+		Returns struct {
+			Error error
+		}
+		Stub func(composer.InstallContext) error
+	}
+	NameCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Returns   struct {
+			String string
+		}
+		Stub func() string
+	}
+}
+
+func (f *InstallStrategy) Install(param1 composer.InstallContext) error {
+	f.InstallCall.mutex.Lock()
+	defer f.InstallCall.mutex.Unlock()
+	f.InstallCall.CallCount++
+	f.InstallCall.Receives.Context = param1
+	if f.InstallCall.Stub != nil {
+		return f.InstallCall.Stub(param1)
+	}
+	return f.InstallCall.Returns.Error
+}
+func (f *InstallStrategy) Name() string {
+	f.NameCall.mutex.Lock()
+	defer f.NameCall.mutex.Unlock()
+	f.NameCall.CallCount++
+	if f.NameCall.Stub != nil {
+		return f.NameCall.Stub()
+	}
+	return f.NameCall.Returns.String
+}
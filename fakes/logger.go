@@ -0,0 +1,142 @@
+package fakes
+
+import (
+	"sync"
+)
+
+type Logger struct {
+	ActionCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Format string
+			V      []interface{}
+		}
+		Stub func(string, ...interface{})
+	}
+	BreakCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Stub      func()
+	}
+	DebugCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Format string
+			V      []interface{}
+		}
+		Stub func(string, ...interface{})
+	}
+	DetailCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Format string
+			V      []interface{}
+		}
+		Stub func(string, ...interface{})
+	}
+	ProcessCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Format string
+			V      []interface{}
+		}
+		Stub func(string, ...interface{})
+	}
+	SubprocessCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Format string
+			V      []interface{}
+		}
+		Stub func(string, ...interface{})
+	}
+	TitleCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Format string
+			V      []interface{}
+		}
+		Stub func(string, ...interface{})
+	}
+}
+
+func (f *Logger) Action(param1 string, param2 ...interface{}) {
+	f.ActionCall.mutex.Lock()
+	defer f.ActionCall.mutex.Unlock()
+	f.ActionCall.CallCount++
+	f.ActionCall.Receives.Format = param1
+	f.ActionCall.Receives.V = param2
+	if f.ActionCall.Stub != nil {
+		f.ActionCall.Stub(param1, param2...)
+	}
+}
+
+func (f *Logger) Break() {
+	f.BreakCall.mutex.Lock()
+	defer f.BreakCall.mutex.Unlock()
+	f.BreakCall.CallCount++
+	if f.BreakCall.Stub != nil {
+		f.BreakCall.Stub()
+	}
+}
+
+func (f *Logger) Debug(param1 string, param2 ...interface{}) {
+	f.DebugCall.mutex.Lock()
+	defer f.DebugCall.mutex.Unlock()
+	f.DebugCall.CallCount++
+	f.DebugCall.Receives.Format = param1
+	f.DebugCall.Receives.V = param2
+	if f.DebugCall.Stub != nil {
+		f.DebugCall.Stub(param1, param2...)
+	}
+}
+
+func (f *Logger) Detail(param1 string, param2 ...interface{}) {
+	f.DetailCall.mutex.Lock()
+	defer f.DetailCall.mutex.Unlock()
+	f.DetailCall.CallCount++
+	f.DetailCall.Receives.Format = param1
+	f.DetailCall.Receives.V = param2
+	if f.DetailCall.Stub != nil {
+		f.DetailCall.Stub(param1, param2...)
+	}
+}
+
+func (f *Logger) Process(param1 string, param2 ...interface{}) {
+	f.ProcessCall.mutex.Lock()
+	defer f.ProcessCall.mutex.Unlock()
+	f.ProcessCall.CallCount++
+	f.ProcessCall.Receives.Format = param1
+	f.ProcessCall.Receives.V = param2
+	if f.ProcessCall.Stub != nil {
+		f.ProcessCall.Stub(param1, param2...)
+	}
+}
+
+func (f *Logger) Subprocess(param1 string, param2 ...interface{}) {
+	f.SubprocessCall.mutex.Lock()
+	defer f.SubprocessCall.mutex.Unlock()
+	f.SubprocessCall.CallCount++
+	f.SubprocessCall.Receives.Format = param1
+	f.SubprocessCall.Receives.V = param2
+	if f.SubprocessCall.Stub != nil {
+		f.SubprocessCall.Stub(param1, param2...)
+	}
+}
+
+func (f *Logger) Title(param1 string, param2 ...interface{}) {
+	f.TitleCall.mutex.Lock()
+	defer f.TitleCall.mutex.Unlock()
+	f.TitleCall.CallCount++
+	f.TitleCall.Receives.Format = param1
+	f.TitleCall.Receives.V = param2
+	if f.TitleCall.Stub != nil {
+		f.TitleCall.Stub(param1, param2...)
+	}
+}
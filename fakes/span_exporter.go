@@ -0,0 +1,32 @@
+package fakes
+
+import (
+	"sync"
+
+	"github.com/paketo-buildpacks/composer"
+)
+
+type SpanExporter struct {
+	ExportCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Export composer.TraceExport
+		}
+		Returns struct {
+			Error error
+		}
+		Stub func(composer.TraceExport) error
+	}
+}
+
+func (f *SpanExporter) Export(param1 composer.TraceExport) error {
+	f.ExportCall.mutex.Lock()
+	defer f.ExportCall.mutex.Unlock()
+	f.ExportCall.CallCount++
+	f.ExportCall.Receives.Export = param1
+	if f.ExportCall.Stub != nil {
+		return f.ExportCall.Stub(param1)
+	}
+	return f.ExportCall.Returns.Error
+}
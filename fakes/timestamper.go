@@ -0,0 +1,33 @@
+package fakes
+
+import (
+	"sync"
+)
+
+type Timestamper struct {
+	TimestampCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Url    string
+			Digest []byte
+		}
+		Returns struct {
+			ByteSlice []byte
+			Error     error
+		}
+		Stub func(string, []byte) ([]byte, error)
+	}
+}
+
+func (f *Timestamper) Timestamp(param1 string, param2 []byte) ([]byte, error) {
+	f.TimestampCall.mutex.Lock()
+	defer f.TimestampCall.mutex.Unlock()
+	f.TimestampCall.CallCount++
+	f.TimestampCall.Receives.Url = param1
+	f.TimestampCall.Receives.Digest = param2
+	if f.TimestampCall.Stub != nil {
+		return f.TimestampCall.Stub(param1, param2)
+	}
+	return f.TimestampCall.Returns.ByteSlice, f.TimestampCall.Returns.Error
+}
@@ -0,0 +1,119 @@
+package composer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// DefaultGeneratedFileMode is the mode of the files generated by the build,
+// such as the php.ini of composer and the INI files loading the extensions,
+// unless BP_COMPOSER_FILE_MODE sets another one.
+const DefaultGeneratedFileMode os.FileMode = 0644
+
+// FileSystem defines the interface for the file system operations of the
+// build, so that their failures can be tested.
+type FileSystem interface {
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	ReadDir(name string) ([]os.DirEntry, error)
+	RemoveAll(path string) error
+	MkdirAll(path string, perm os.FileMode) error
+	Rename(oldpath, newpath string) error
+	Chmod(name string, mode os.FileMode) error
+}
+
+// OSFileSystem performs the file system operations with the os package.
+type OSFileSystem struct{}
+
+func NewOSFileSystem() OSFileSystem {
+	return OSFileSystem{}
+}
+
+func (OSFileSystem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+func (OSFileSystem) ReadDir(name string) ([]os.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+// RemoveAll removes the given path like os.RemoveAll. Without privileges, the
+// entries of read-only directories, e.g. extracted from package archives,
+// cannot be removed, so the directories are made writable if that fails.
+func (OSFileSystem) RemoveAll(path string) error {
+	err := os.RemoveAll(path)
+	if !errors.Is(err, fs.ErrPermission) {
+		return err
+	}
+
+	_ = filepath.WalkDir(path, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err == nil && info.Mode().Perm()&0700 != 0700 {
+			_ = os.Chmod(path, info.Mode().Perm()|0700)
+		}
+
+		return nil
+	})
+
+	return os.RemoveAll(path)
+}
+
+func (OSFileSystem) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (OSFileSystem) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (OSFileSystem) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+// lookupGeneratedFileMode will check for env var "BP_COMPOSER_FILE_MODE", an
+// octal file mode such as "0640", and return it, or DefaultGeneratedFileMode
+// if it is not set.
+func lookupGeneratedFileMode() (os.FileMode, error) {
+	value := os.Getenv(BpComposerFileMode)
+	if value == "" {
+		return DefaultGeneratedFileMode, nil
+	}
+
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("%s must be an octal file mode such as \"0644\", found %q", BpComposerFileMode, value)
+	}
+
+	return os.FileMode(mode), nil
+}
+
+// writeGeneratedFile writes the given file with the mode of
+// BP_COMPOSER_FILE_MODE. An existing file is replaced instead of written in
+// place, as it may be owned by another user, e.g. in the working directory of
+// a rootless build, and os.WriteFile would keep its mode. The mode is set
+// explicitly, so that it does not depend on the umask.
+func writeGeneratedFile(fileSystem FileSystem, path string, content []byte) error {
+	mode, err := lookupGeneratedFileMode()
+	if err != nil {
+		return err
+	}
+
+	err = fileSystem.RemoveAll(path)
+	if err != nil {
+		return err
+	}
+
+	err = fileSystem.WriteFile(path, content, mode)
+	if err != nil {
+		return err
+	}
+
+	return fileSystem.Chmod(path, mode)
+}
@@ -1,13 +1,18 @@
 package composer
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // FindComposerFiles exists to determine where the composer.json and composer.lock files are
 // Note that a composer.lock file is not required to exist, but must be a sibling of composer.json
 //
+// BP_COMPOSER_FILE takes precedence over COMPOSER, and selects a composer.json with a lock file
+// named like composer does, e.g. `composer-prod.lock` for `composer-prod.json`.
+//
 // Because it can be helpful during the Detect phase to log why this buildpack will not participate,
 // this function will also indicate whether the COMPOSER env var was set.
 func FindComposerFiles(workingDir string) (composerJsonPath string, composerLockPath string, composerVar string, composerVarFound bool) {
@@ -15,10 +20,39 @@ func FindComposerFiles(workingDir string) (composerJsonPath string, composerLock
 	composerLockPath = filepath.Join(workingDir, DefaultComposerLockPath)
 
 	composerVar, composerVarFound = os.LookupEnv(Composer)
+	if composerFile, found := os.LookupEnv(BpComposerFile); found && composerFile != "" {
+		composerVar, composerVarFound = composerFile, true
+	}
+
 	if composerVarFound {
 		composerJsonPath = filepath.Join(workingDir, composerVar)
-		composerLockPath = filepath.Join(filepath.Dir(composerJsonPath), DefaultComposerLockPath)
+		composerLockPath = composerLockPathOf(composerJsonPath)
 	}
 
 	return
 }
+
+// composerLockPathOf returns the lock file of the given composer.json. Composer
+// replaces the extension `.json` of the file selected with BP_COMPOSER_FILE by
+// `.lock`. Otherwise, it is the composer.lock next to composer.json.
+// https://getcomposer.org/doc/03-cli.md#composer
+func composerLockPathOf(composerJsonPath string) string {
+	if composerFile, found := os.LookupEnv(BpComposerFile); found && composerFile != "" {
+		return strings.TrimSuffix(composerJsonPath, ".json") + ".lock"
+	}
+
+	return filepath.Join(filepath.Dir(composerJsonPath), DefaultComposerLockPath)
+}
+
+// composerFileEnv returns COMPOSER set to the composer.json selected with
+// BP_COMPOSER_FILE, so that every `composer` command uses the same pair of
+// composer.json and lock file, or nothing if BP_COMPOSER_FILE is not set.
+func composerFileEnv(workingDir string) []string {
+	if composerFile, found := os.LookupEnv(BpComposerFile); !found || composerFile == "" {
+		return nil
+	}
+
+	composerJsonPath, _, _, _ := FindComposerFiles(workingDir)
+
+	return []string{fmt.Sprintf("%s=%s", Composer, composerJsonPath)}
+}
@@ -0,0 +1,71 @@
+package composer
+
+import (
+	"fmt"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// HookContext is passed to each Hook, describing the state of the build at
+// the given phase.
+type HookContext struct {
+	BuildContext packit.BuildContext
+	Logger       Logger
+
+	// InstallOptions are the options for `composer install`
+	InstallOptions []InstallOption
+
+	// WorkspaceVendorDir is the vendor directory in the working directory
+	WorkspaceVendorDir string
+
+	// ComposerPackagesLayer is the composer-packages layer. It is nil before
+	// `composer install` has run, and can be modified afterwards, e.g. to
+	// set additional environment variables.
+	ComposerPackagesLayer *packit.Layer
+}
+
+// Hook allows to extend the build at fixed phases without copying Build,
+// e.g. in a fork of this buildpack. Hooks are passed to Build as
+// BuildDependencies.Hooks and run in the given order. An error returned by a
+// hook fails the build.
+//
+// Embed NoopHook to only implement some of the phases.
+//
+//go:generate faux --interface Hook --output fakes/hook.go
+type Hook interface {
+	// BeforeInstall runs before `composer install`
+	BeforeInstall(context HookContext) error
+
+	// AfterCacheRestore runs after the vendor directory has been restored
+	// from the cached composer-packages layer, before AfterInstall
+	AfterCacheRestore(context HookContext) error
+
+	// AfterInstall runs after `composer install`, whether the cached layer
+	// has been reused or not
+	AfterInstall(context HookContext) error
+
+	// BeforeSBOM runs before the SBOM is generated or reused from the cache.
+	// It does not run if the SBOM generation is skipped.
+	BeforeSBOM(context HookContext) error
+}
+
+// NoopHook implements all phases of Hook without doing anything.
+type NoopHook struct{}
+
+func (NoopHook) BeforeInstall(HookContext) error     { return nil }
+func (NoopHook) AfterCacheRestore(HookContext) error { return nil }
+func (NoopHook) AfterInstall(HookContext) error      { return nil }
+func (NoopHook) BeforeSBOM(HookContext) error        { return nil }
+
+// runHooks runs the given phase of each hook in order, and stops at the
+// first error.
+func runHooks(hooks []Hook, phase string, run func(Hook) error) error {
+	for _, hook := range hooks {
+		err := run(hook)
+		if err != nil {
+			return fmt.Errorf("%s hook failed: %w", phase, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package composer
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// autoloadInputsMetadataKey is the key of the composer-packages layer
+// metadata, which records the checksum of the autoload configuration and of
+// the autoloaded sources the layer has been built or reused with.
+const autoloadInputsMetadataKey = "autoload-inputs-sha"
+
+// autoloadDumpScriptEvents are the script events dispatched by the autoload
+// dump, which are skipped together with it.
+// https://getcomposer.org/doc/articles/scripts.md#event-names
+var autoloadDumpScriptEvents = []string{
+	"pre-autoload-dump",
+	"post-autoload-dump",
+}
+
+// autoloadInputsChecksum calculates the checksum of the "autoload" and
+// "autoload-dev" sections of the given `composer.json`, and of the autoloaded
+// application sources, see FindAutoloadPaths.
+func autoloadInputsChecksum(composerJsonPath string, calculator Calculator) (string, error) {
+	var composerJson struct {
+		Autoload    json.RawMessage `json:"autoload"`
+		AutoloadDev json.RawMessage `json:"autoload-dev"`
+	}
+
+	content, err := readComposerFile(composerJsonPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	} else if err == nil {
+		err = json.Unmarshal(content, &composerJson)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse %s: %w", composerJsonPath, err)
+		}
+	}
+
+	sourcesChecksum, err := autoloadChecksum(composerJsonPath, calculator)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "autoload=%s\n", string(composerJson.Autoload))
+	fmt.Fprintf(hash, "autoload-dev=%s\n", string(composerJson.AutoloadDev))
+	fmt.Fprintf(hash, "sources=%s\n", sourcesChecksum)
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// determineAutoloadInputsIfRequired will check for env var
+// "BP_COMPOSER_INCREMENTAL_AUTOLOAD". If set to true, the checksum of the
+// autoload inputs is returned, see autoloadInputsChecksum, which is recorded
+// in the composer-packages layer. Otherwise, an empty string is returned.
+func determineAutoloadInputsIfRequired(logger emitter, composerJsonPath string, calculator Calculator) (string, error) {
+	enabled, err := lookupBoolEnv(BpComposerIncrementalAutoload, false)
+	if err != nil {
+		return "", err
+	}
+
+	if !enabled {
+		return "", nil
+	}
+
+	checksum, err := autoloadInputsChecksum(composerJsonPath, calculator)
+	if err != nil {
+		return "", err
+	}
+
+	logger.Debug.Process("Calculated checksum of %s for the autoload configuration and sources", checksum)
+
+	return checksum, nil
+}
+
+// skipAutoloadDumpOnCache returns whether `composer install` from cached
+// files can run with `--no-autoloader`, i.e. whether the autoload inputs are
+// the ones recorded in the cached layer. The cached vendor directory, with
+// the autoloader dumped by the build which created it, replaces the one of
+// this `composer install`, so dumping it again only takes time. As the
+// autoload dump scripts would be skipped as well, the dump is never skipped
+// if `composer.json` has any. The decision is logged.
+func skipAutoloadDumpOnCache(logger emitter, composerJsonPath, checksum string, metadata map[string]interface{}) (bool, error) {
+	if checksum == "" {
+		return false, nil
+	}
+
+	cachedChecksum, _ := metadata[autoloadInputsMetadataKey].(string)
+	if cachedChecksum != checksum {
+		logger.Process("Dumping the autoloader from cached files, as the autoload configuration or sources have changed")
+		return false, nil
+	}
+
+	var composerJson struct {
+		Scripts map[string]interface{} `json:"scripts"`
+	}
+
+	content, err := readComposerFile(composerJsonPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	} else if err == nil {
+		err = json.Unmarshal(content, &composerJson)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse %s: %w", composerJsonPath, err)
+		}
+	}
+
+	for _, event := range autoloadDumpScriptEvents {
+		if _, ok := composerJson.Scripts[event]; ok {
+			logger.Process("Dumping the autoloader from cached files for the script %s, although the autoload configuration and sources are unchanged", event)
+			return false, nil
+		}
+	}
+
+	logger.Process("Skipping the autoload dump from cached files, as the autoload configuration and sources are unchanged")
+
+	return true, nil
+}
+
+// withNoAutoloader returns the given arguments of `composer install` with
+// `--no-autoloader`.
+func withNoAutoloader(args []string) []string {
+	for _, arg := range args {
+		if arg == "--no-autoloader" {
+			return args
+		}
+	}
+
+	return append(args, "--no-autoloader")
+}
+
+// recordAutoloadInputs records the given checksum of the autoload inputs in
+// the given layer metadata, or removes it if there is none, so that a later
+// build never compares against the inputs of an older build.
+func recordAutoloadInputs(metadata map[string]interface{}, checksum string) {
+	if checksum == "" {
+		delete(metadata, autoloadInputsMetadataKey)
+		return
+	}
+
+	metadata[autoloadInputsMetadataKey] = checksum
+}
@@ -13,5 +13,46 @@ func TestUnitComposer(t *testing.T) {
 	suite("Build", testBuild, spec.Sequential())
 	suite("InstallOptions", testComposerInstallOptions)
 	suite("PhpVersionResolver", testPhpVersionResolver, spec.Sequential())
+	suite("DrupalScaffold", testDrupalScaffold)
+	suite("InstallerPaths", testInstallerPaths)
+	suite("AbandonedPackages", testAbandonedPackages)
+	suite("ExtraCachePaths", testExtraCachePaths, spec.Sequential())
+	suite("Integrity", testIntegrity)
+	suite("CommandLog", testCommandLog)
+	suite("ProjectConfig", testProjectConfig)
+	suite("PharDownloader", testPharDownloader)
+	suite("CopyTree", testCopyTree)
+	suite("AutoloadRefresh", testAutoloadRefresh)
+	suite("VendorDir", testVendorDir, spec.Sequential())
+	suite("RFC3161Timestamper", testRFC3161Timestamper)
+	suite("ParallelChecksumCalculator", testParallelChecksumCalculator)
+	suite("LockSnapshot", testLockSnapshot)
+	suite("Outdated", testOutdated)
+	suite("DiskSpace", testDiskSpace)
+	suite("AllowedHosts", testAllowedHosts)
+	suite("VendorOnly", testVendorOnly)
+	suite("ComposerScripts", testComposerScripts)
+	suite("PluginOutput", testPluginOutput)
+	suite("Magento", testMagento)
+	suite("OTLPSpanExporter", testOTLPSpanExporter)
+	suite("LayerMigration", testLayerMigration)
+	suite("LayerIgnore", testLayerIgnore)
+	suite("LaunchEnv", testLaunchEnv)
+	suite("DownloadMetrics", testDownloadMetrics)
+	suite("Reproducible", testReproducible)
+	suite("RunOnCache", testRunOnCache)
+	suite("PackagePolicy", testPackagePolicy)
+	suite("LicensePolicy", testLicensePolicy)
+	suite("ComposerRepositories", testComposerRepositories)
+	suite("LayerInputs", testLayerInputs)
+	suite("WorkspaceSanity", testWorkspaceSanity)
+	suite("PhpVersionCheck", testPhpVersionCheck)
+	suite("BuildMetrics", testBuildMetrics)
+	suite("BuildTarget", testBuildTarget)
+	suite("ComposerWarnings", testComposerWarnings)
+	suite("PackageStore", testPackageStore)
+	suite("LicenseReport", testLicenseReport)
+	suite("StabilityPolicy", testStabilityPolicy)
+	suite("Suggests", testSuggests)
 	suite.Run(t)
 }
@@ -0,0 +1,102 @@
+package composer
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+// DefaultInstallStrategy is the name of ComposerCLIStrategy, which is used
+// unless BP_COMPOSER_INSTALL_STRATEGY selects another one.
+const DefaultInstallStrategy = "composer"
+
+// InstallContext is passed to an InstallStrategy for each `composer install`.
+type InstallContext struct {
+	Logger Logger
+
+	// Composer is the executable of the Composer CLI, with the environment of
+	// the build
+	Composer Executable
+
+	// Execution is the execution of `composer install`, with its arguments,
+	// working directory, environment and output. COMPOSER, COMPOSER_HOME and
+	// COMPOSER_VENDOR_DIR are set in its environment.
+	Execution pexec.Execution
+
+	// FromCache is true if the packages are installed again on top of the
+	// cached composer-packages layer, see BP_RUN_COMPOSER_INSTALL
+	FromCache bool
+}
+
+// InstallStrategy installs the packages of `composer.lock` into the vendor
+// directory, instead of running `composer install` as is, e.g. through a
+// proxy accelerating the downloads or with a client of an internal mirror.
+// Strategies are registered with RegisterInstallStrategy, and selected with
+// BP_COMPOSER_INSTALL_STRATEGY, so that forks of this buildpack do not need
+// to change Build.
+//
+// The vendor directory and the files written outside of it must be the same
+// as the ones of `composer install`, as they are cached and restored as such.
+//
+//go:generate faux --interface InstallStrategy --output fakes/install_strategy.go
+type InstallStrategy interface {
+	// Name is the value of BP_COMPOSER_INSTALL_STRATEGY selecting the strategy
+	Name() string
+
+	// Install installs the packages, an error fails the build
+	Install(context InstallContext) error
+}
+
+// ComposerCLIStrategy runs `composer install` with the Composer CLI.
+type ComposerCLIStrategy struct{}
+
+func (ComposerCLIStrategy) Name() string {
+	return DefaultInstallStrategy
+}
+
+func (ComposerCLIStrategy) Install(context InstallContext) error {
+	return context.Composer.Execute(context.Execution)
+}
+
+// installStrategies are the registered strategies by name.
+var installStrategies = map[string]InstallStrategy{
+	DefaultInstallStrategy: ComposerCLIStrategy{},
+}
+
+// RegisterInstallStrategy makes the given strategy available to
+// BP_COMPOSER_INSTALL_STRATEGY, replacing any strategy with the same name. It
+// must be called before the build runs, e.g. in `run/main.go`.
+func RegisterInstallStrategy(strategy InstallStrategy) {
+	installStrategies[strategy.Name()] = strategy
+}
+
+// lookupInstallStrategy will check for env var "BP_COMPOSER_INSTALL_STRATEGY",
+// and return the registered strategy with that name, or ComposerCLIStrategy
+// if it is not set.
+func lookupInstallStrategy(logger emitter) (InstallStrategy, error) {
+	name := os.Getenv(BpComposerInstallStrategy)
+	if name == "" {
+		name = DefaultInstallStrategy
+	}
+
+	strategy, found := installStrategies[name]
+	if !found {
+		var names []string
+		for name := range installStrategies {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		return nil, fmt.Errorf("%s must be one of %s, found %q", BpComposerInstallStrategy, strings.Join(names, ", "), name)
+	}
+
+	if name != DefaultInstallStrategy {
+		logger.Process("Installing packages with the %q install strategy (%s)", name, BpComposerInstallStrategy)
+		logger.Break()
+	}
+
+	return strategy, nil
+}
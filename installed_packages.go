@@ -0,0 +1,49 @@
+package composer
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// InstalledPackage is the subset of a package entry in Composer's
+// `installed.json` which is used by this buildpack.
+type InstalledPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+
+	// InstallPath is relative to the directory containing `installed.json`.
+	// It is only written by Composer 2.
+	InstallPath string `json:"install-path"`
+
+	Extra drupalScaffoldExtra `json:"extra"`
+}
+
+// ReadInstalledPackages reads all packages listed in Composer's
+// `installed.json`. Composer 1 writes the packages as a list, whereas
+// Composer 2 nests them under the "packages" key.
+// Returns an empty list if `installed.json` does not exist.
+func ReadInstalledPackages(installedJsonPath string) ([]InstalledPackage, error) {
+	content, err := os.ReadFile(installedJsonPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var installedJson struct {
+		Packages []InstalledPackage `json:"packages"`
+	}
+
+	if err = json.Unmarshal(content, &installedJson); err == nil {
+		return installedJson.Packages, nil
+	}
+
+	var packages []InstalledPackage
+	if err = json.Unmarshal(content, &packages); err != nil {
+		return nil, err
+	}
+
+	return packages, nil
+}
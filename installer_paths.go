@@ -0,0 +1,68 @@
+package composer
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+const installerPathsLayerDir = "installer-paths"
+
+// FindInstallerPaths determines which packages have been installed into the
+// workspace outside of the vendor directory. This is typically done by
+// `composer/installers` and its `extra.installer-paths` setting, e.g. for
+// WordPress (Bedrock) plugins and themes in `web/app/plugins` and
+// `web/app/themes`, or by custom installers such as the WordPress core
+// installer placing WordPress into `web/wp`.
+// https://github.com/composer/installers#custom-install-paths
+//
+// The install paths are read from `{vendorDir}/composer/installed.json`,
+// which contains the actual location of each package as resolved by Composer.
+//
+// Returns the paths relative to the working directory.
+func FindInstallerPaths(workingDir, _, vendorDir string) ([]string, error) {
+	installedJsonDir := filepath.Join(vendorDir, "composer")
+
+	installedPackages, err := ReadInstalledPackages(filepath.Join(installedJsonDir, "installed.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, installedPackage := range installedPackages {
+		if installedPackage.InstallPath == "" {
+			continue
+		}
+
+		path := filepath.Join(installedJsonDir, filepath.FromSlash(installedPackage.InstallPath))
+
+		if relativeToVendor, err := filepath.Rel(vendorDir, path); err != nil { // untested
+			return nil, err
+		} else if !strings.HasPrefix(relativeToVendor, "..") {
+			continue
+		}
+
+		relativePath, err := filepath.Rel(workingDir, path)
+		if err != nil { // untested
+			return nil, err
+		}
+
+		if relativePath == "." || strings.HasPrefix(relativePath, "..") {
+			continue
+		}
+
+		if exists, err := fs.Exists(path); err != nil {
+			return nil, err
+		} else if !exists {
+			continue
+		}
+
+		paths = append(paths, relativePath)
+	}
+
+	sort.Strings(paths)
+
+	return paths, nil
+}
@@ -0,0 +1,107 @@
+package composer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testInstallerPaths(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+		vendorDir  string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		vendorDir = filepath.Join(workingDir, "vendor")
+		Expect(os.MkdirAll(filepath.Join(vendorDir, "composer"), os.ModePerm)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("when installed.json does not exist", func() {
+		it("returns no paths", func() {
+			paths, err := composer.FindInstallerPaths(workingDir, filepath.Join(workingDir, "composer.json"), vendorDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(BeEmpty())
+		})
+	})
+
+	context("when packages are installed outside of the vendor directory", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(vendorDir, "composer", "installed.json"), []byte(`{
+	"packages": [
+		{
+			"name": "composer/installers",
+			"install-path": "./installers"
+		},
+		{
+			"name": "roots/wordpress-no-content",
+			"install-path": "../../web/wp"
+		},
+		{
+			"name": "wpackagist-plugin/akismet",
+			"install-path": "../../web/app/plugins/akismet/"
+		},
+		{
+			"name": "wpackagist-theme/twentytwenty",
+			"install-path": "../../web/app/themes/twentytwenty/"
+		},
+		{
+			"name": "some/not-installed",
+			"install-path": "../../web/app/plugins/not-installed/"
+		},
+		{
+			"name": "some/outside-workspace",
+			"install-path": "../../../outside"
+		},
+		{
+			"name": "some/metapackage",
+			"install-path": null
+		}
+	]
+}`), os.ModePerm)).To(Succeed())
+
+			Expect(os.MkdirAll(filepath.Join(vendorDir, "composer", "installers"), os.ModePerm)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(workingDir, "web", "wp"), os.ModePerm)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(workingDir, "web", "app", "plugins", "akismet"), os.ModePerm)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(workingDir, "web", "app", "themes", "twentytwenty"), os.ModePerm)).To(Succeed())
+		})
+
+		it("returns the existing install paths within the workspace", func() {
+			paths, err := composer.FindInstallerPaths(workingDir, filepath.Join(workingDir, "composer.json"), vendorDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(Equal([]string{
+				filepath.Join("web", "app", "plugins", "akismet"),
+				filepath.Join("web", "app", "themes", "twentytwenty"),
+				filepath.Join("web", "wp"),
+			}))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when installed.json is malformed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(vendorDir, "composer", "installed.json"), []byte(`%%%`), os.ModePerm)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := composer.FindInstallerPaths(workingDir, filepath.Join(workingDir, "composer.json"), vendorDir)
+				Expect(err).To(MatchError(ContainSubstring("invalid character")))
+			})
+		})
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/paketo-buildpacks/composer/testpkg"
 	"github.com/paketo-buildpacks/occam"
 	"github.com/sclevine/spec"
 
@@ -41,7 +42,7 @@ func testDefaultApp(t *testing.T, context spec.G, it spec.S) {
 			var err error
 			name, err = occam.RandomName()
 			Expect(err).NotTo(HaveOccurred())
-			source, err = occam.Source(filepath.Join("testdata", "default_app"))
+			source, err = testpkg.NewApp(testpkg.DefaultApp)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
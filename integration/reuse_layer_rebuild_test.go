@@ -7,8 +7,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/paketo-buildpacks/composer/testpkg"
 	"github.com/paketo-buildpacks/occam"
-	"github.com/paketo-buildpacks/packit/v2/fs"
 	"github.com/sclevine/spec"
 
 	. "github.com/onsi/gomega"
@@ -56,7 +56,7 @@ func testReusingLayerRebuild(t *testing.T, context spec.G, it spec.S) {
 				thirdImage  occam.Image
 			)
 
-			source, err = occam.Source(filepath.Join("testdata", "default_app"))
+			source, err = testpkg.NewApp(testpkg.DefaultApp)
 			Expect(err).NotTo(HaveOccurred())
 
 			build := pack.WithNoColor().Build.
@@ -100,6 +100,7 @@ func testReusingLayerRebuild(t *testing.T, context spec.G, it spec.S) {
 					Expect(logs.String()).NotTo(ContainSubstring("Running 'composer install --no-progress --no-dev'"))
 				})
 				Expect(logs.String()).To(ContainSubstring(fmt.Sprintf("Reusing cached layer /layers/%s/composer-packages", strings.ReplaceAll(buildpackInfo.Buildpack.ID, "/", "_"))))
+				Expect(logs.String()).To(ContainSubstring("Composer packages cache: hit"))
 				Expect(secondImage.Buildpacks[2].Layers["composer-packages"].SHA).To(Equal(firstImage.Buildpacks[2].Layers["composer-packages"].SHA))
 			})
 
@@ -125,6 +126,7 @@ func testReusingLayerRebuild(t *testing.T, context spec.G, it spec.S) {
 					Expect(logs.String()).To(ContainSubstring("Running 'composer install --no-progress --no-dev'"))
 				})
 				Expect(logs.String()).To(ContainSubstring(fmt.Sprintf("Reusing cached layer /layers/%s/composer-packages", strings.ReplaceAll(buildpackInfo.Buildpack.ID, "/", "_"))))
+				Expect(logs.String()).To(ContainSubstring("Composer packages cache: hit"))
 
 				Expect(thirdImage.Buildpacks[2].Layers["composer-packages"].SHA).To(Equal(firstImage.Buildpacks[2].Layers["composer-packages"].SHA))
 			})
@@ -140,7 +142,7 @@ func testReusingLayerRebuild(t *testing.T, context spec.G, it spec.S) {
 				secondImage occam.Image
 			)
 
-			source, err = occam.Source(filepath.Join("testdata", "default_app"))
+			source, err = testpkg.NewApp(testpkg.DefaultApp)
 			Expect(err).NotTo(HaveOccurred())
 
 			build := pack.WithNoColor().Build.
@@ -163,8 +165,7 @@ func testReusingLayerRebuild(t *testing.T, context spec.G, it spec.S) {
 			Expect(logs.String()).To(ContainSubstring("Running 'composer install --no-progress --no-dev'"))
 
 			// Second pack build
-			Expect(fs.Copy(filepath.Join("testdata", "app_with_no_deps", "composer.json"), filepath.Join(source, "composer.json"))).To(Succeed())
-			Expect(fs.Copy(filepath.Join("testdata", "app_with_no_deps", "composer.lock"), filepath.Join(source, "composer.lock"))).To(Succeed())
+			Expect(testpkg.WriteApp(testpkg.AppWithNoDeps, source)).To(Succeed())
 
 			secondImage, logs, err = build.
 				Execute(name, source)
@@ -179,6 +180,7 @@ func testReusingLayerRebuild(t *testing.T, context spec.G, it spec.S) {
 
 			Expect(logs.String()).To(ContainSubstring("Running 'composer install --no-progress --no-dev'"))
 			Expect(logs.String()).NotTo(ContainSubstring(fmt.Sprintf("Reusing cached layer /layers/%s/composer-packages", strings.ReplaceAll(buildpackInfo.Buildpack.ID, "/", "_"))))
+			Expect(logs.String()).To(ContainSubstring("Composer packages cache: stale-lock"))
 
 			Expect(secondImage.Buildpacks[2].Layers["composer-packages"].SHA).NotTo(Equal(firstImage.Buildpacks[2].Layers["composer-packages"].SHA))
 		})
@@ -239,6 +241,7 @@ func testReusingLayerRebuild(t *testing.T, context spec.G, it spec.S) {
 				})
 				Expect(logs.String()).To(ContainSubstring("Detected existing vendored packages, replacing with cached vendored packages"))
 				Expect(logs.String()).To(ContainSubstring(fmt.Sprintf("Reusing cached layer /layers/%s/composer-packages", strings.ReplaceAll(buildpackInfo.Buildpack.ID, "/", "_"))))
+				Expect(logs.String()).To(ContainSubstring("Composer packages cache: hit"))
 
 				Expect(secondImage.Buildpacks[2].Layers["composer-packages"].SHA).To(Equal(firstImage.Buildpacks[2].Layers["composer-packages"].SHA))
 			})
@@ -266,6 +269,7 @@ func testReusingLayerRebuild(t *testing.T, context spec.G, it spec.S) {
 				})
 				Expect(logs.String()).To(ContainSubstring("Detected existing vendored packages, replacing with cached vendored packages"))
 				Expect(logs.String()).To(ContainSubstring(fmt.Sprintf("Reusing cached layer /layers/%s/composer-packages", strings.ReplaceAll(buildpackInfo.Buildpack.ID, "/", "_"))))
+				Expect(logs.String()).To(ContainSubstring("Composer packages cache: hit"))
 
 				Expect(thirdImage.Buildpacks[2].Layers["composer-packages"].SHA).To(Equal(firstImage.Buildpacks[2].Layers["composer-packages"].SHA))
 			})
@@ -3,10 +3,10 @@ package integration_test
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/paketo-buildpacks/composer/testpkg"
 	"github.com/paketo-buildpacks/occam"
 	"github.com/sclevine/spec"
 
@@ -77,7 +77,7 @@ func testStackUpgrade(t *testing.T, context spec.G, it spec.S) {
 				secondContainer occam.Container
 			)
 
-			source, err = occam.Source(filepath.Join("testdata", "default_app"))
+			source, err = testpkg.NewApp(testpkg.DefaultApp)
 			Expect(err).NotTo(HaveOccurred())
 
 			build := pack.WithNoColor().Build.
@@ -109,6 +109,7 @@ func testStackUpgrade(t *testing.T, context spec.G, it spec.S) {
 			imageIDs[secondImage.ID] = struct{}{}
 			Expect(logs).To(ContainSubstring("Running 'composer install --no-progress --no-dev'"))
 			Expect(logs.String()).NotTo(ContainSubstring(fmt.Sprintf("Reusing cached layer /layers/%s/composer-packages", strings.ReplaceAll(buildpackInfo.Buildpack.ID, "/", "_"))))
+			Expect(logs.String()).To(ContainSubstring("Composer packages cache: stale-stack"))
 
 			imageIDs[secondImage.ID] = struct{}{}
 
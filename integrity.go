@@ -0,0 +1,402 @@
+package composer
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+// IntegrityMismatch describes a file whose checksum does not match the
+// expected checksum.
+type IntegrityMismatch struct {
+	Name     string
+	Expected string
+	Actual   []string
+}
+
+func (m IntegrityMismatch) String() string {
+	return fmt.Sprintf("%s: expected %s, found %s", m.Name, m.Expected, strings.Join(m.Actual, ", "))
+}
+
+// IntegrityReport is the result of verifying the downloaded dist archives.
+type IntegrityReport struct {
+	// Verified lists the packages whose archive matched the recorded checksum
+	Verified []string
+
+	// Unverifiable lists the packages which could not be verified, either
+	// because composer.lock does not record a checksum, or because their
+	// archive has not been downloaded, e.g. as they have been assembled from
+	// the package store
+	Unverifiable []string
+
+	Mismatches []IntegrityMismatch
+}
+
+// VerifyDistIntegrity verifies the dist archives downloaded into Composer's
+// files cache against the SHA-1 checksums recorded as "dist.shasum" in
+// `composer.lock`. Composer stores the archive of a dist URL in
+// `{cacheFilesDir}/{vendor}/{package}/{sha1 of the URL}.{dist type}`, so
+// only the archive of the locked dist is verified, rather than archives of
+// other versions downloaded by previous builds.
+// https://getcomposer.org/doc/06-config.md#cache-files-dir
+//
+// A package is considered a mismatch if its archive does not match the
+// recorded checksum.
+func VerifyDistIntegrity(composerLockPath, cacheFilesDir string) (IntegrityReport, error) {
+	var report IntegrityReport
+
+	content, err := readComposerFile(composerLockPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return report, nil
+		}
+		return report, err
+	}
+
+	type lockedPackage struct {
+		Name string `json:"name"`
+		Dist struct {
+			Type   string `json:"type"`
+			URL    string `json:"url"`
+			Shasum string `json:"shasum"`
+		} `json:"dist"`
+	}
+
+	var composerLock struct {
+		Packages    []lockedPackage `json:"packages"`
+		PackagesDev []lockedPackage `json:"packages-dev"`
+	}
+
+	err = json.Unmarshal(content, &composerLock)
+	if err != nil {
+		return report, err
+	}
+
+	for _, p := range append(composerLock.Packages, composerLock.PackagesDev...) {
+		if p.Dist.Shasum == "" || p.Dist.URL == "" || p.Dist.Type == "" {
+			report.Unverifiable = append(report.Unverifiable, p.Name)
+			continue
+		}
+
+		archive := filepath.Join(cacheFilesDir, filepath.FromSlash(p.Name), fmt.Sprintf("%x.%s", sha1.Sum([]byte(p.Dist.URL)), p.Dist.Type))
+		checksum, err := fileChecksum(sha1.New(), archive)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				report.Unverifiable = append(report.Unverifiable, p.Name)
+				continue
+			}
+			return report, err
+		}
+
+		if strings.EqualFold(checksum, p.Dist.Shasum) {
+			report.Verified = append(report.Verified, p.Name)
+		} else {
+			report.Mismatches = append(report.Mismatches, IntegrityMismatch{
+				Name:     p.Name,
+				Expected: p.Dist.Shasum,
+				Actual:   []string{checksum},
+			})
+		}
+	}
+
+	return report, nil
+}
+
+// composerCacheFilesDir returns the directory into which Composer downloads
+// the dist archives with the given environment, resolved as Composer does:
+// "COMPOSER_CACHE_FILES_DIR", the "cache-files-dir" of the config of the
+// project or of the global config in COMPOSER_HOME, or "files" in the cache
+// dir, which is resolved likewise from "COMPOSER_CACHE_DIR" and "cache-dir",
+// and defaults to "cache" in COMPOSER_HOME.
+// https://getcomposer.org/doc/06-config.md#cache-files-dir
+func composerCacheFilesDir(env []string, workingDir string) string {
+	composerHome := lookupExecutionEnv(env, "COMPOSER_HOME")
+
+	composerJsonPath := lookupExecutionEnv(env, "COMPOSER")
+	if composerJsonPath == "" {
+		composerJsonPath = filepath.Join(workingDir, "composer.json")
+	}
+
+	// the config of the project takes precedence over the global config,
+	// relative paths are relative to the directory of the config
+	configs := []struct {
+		path    string
+		baseDir string
+	}{
+		{path: composerJsonPath, baseDir: filepath.Dir(composerJsonPath)},
+		{path: filepath.Join(composerHome, "config.json"), baseDir: composerHome},
+	}
+
+	resolve := func(key, fallback string, replacements map[string]string) string {
+		value, baseDir := lookupExecutionEnv(env, "COMPOSER_"+strings.ToUpper(strings.ReplaceAll(key, "-", "_"))), workingDir
+		for _, config := range configs {
+			if value != "" {
+				break
+			}
+			value, baseDir = readComposerConfigValue(config.path, key), config.baseDir
+		}
+		if value == "" {
+			return fallback
+		}
+
+		for placeholder, replacement := range replacements {
+			value = strings.ReplaceAll(value, placeholder, replacement)
+		}
+		if home, err := os.UserHomeDir(); err == nil && (value == "~" || strings.HasPrefix(value, "~/")) {
+			value = home + strings.TrimPrefix(value, "~")
+		}
+		if !filepath.IsAbs(value) {
+			value = filepath.Join(baseDir, value)
+		}
+
+		return filepath.Clean(value)
+	}
+
+	cacheDir := resolve("cache-dir", filepath.Join(composerHome, "cache"), map[string]string{"{$home}": composerHome})
+
+	return resolve("cache-files-dir", filepath.Join(cacheDir, "files"), map[string]string{"{$home}": composerHome, "{$cache-dir}": cacheDir})
+}
+
+// readComposerConfigValue returns the given string setting of the "config"
+// of the given composer.json or global config.json, or nothing if it is not
+// set or cannot be read, in which case Composer reports the error itself.
+func readComposerConfigValue(path, key string) string {
+	content, err := readComposerFile(path)
+	if err != nil {
+		return ""
+	}
+
+	var composerJson struct {
+		Config map[string]interface{} `json:"config"`
+	}
+	if json.Unmarshal(content, &composerJson) != nil {
+		return ""
+	}
+
+	value, _ := composerJson.Config[key].(string)
+	return value
+}
+
+// lookupExecutionEnv returns the value of the given variable in the given
+// environment of an execution, where the last value takes effect, or in the
+// environment of the build if the execution has none.
+func lookupExecutionEnv(env []string, name string) string {
+	if env == nil {
+		return os.Getenv(name)
+	}
+
+	value := ""
+	for _, variable := range env {
+		if n, v, found := strings.Cut(variable, "="); found && n == name {
+			value = v
+		}
+	}
+	return value
+}
+
+// VerifyComposerBinary compares the SHA-256 checksum of the `composer`
+// executable found on the given path with the expected checksum, such as the
+// one published at https://getcomposer.org/download/.
+// Returns nil if the checksums match.
+func VerifyComposerBinary(path, expectedSHA256 string) (*IntegrityMismatch, error) {
+	composerBinary, err := lookPath("composer", path)
+	if err != nil {
+		return nil, err
+	}
+
+	checksum, err := fileChecksum(sha256.New(), composerBinary)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(checksum, expectedSHA256) {
+		return nil, nil
+	}
+
+	return &IntegrityMismatch{
+		Name:     composerBinary,
+		Expected: expectedSHA256,
+		Actual:   []string{checksum},
+	}, nil
+}
+
+// verifyComposerBinaryIfRequired will check for env var
+// "BP_COMPOSER_VERIFY_INTEGRITY". If set to true, and "BP_COMPOSER_SHA256" is
+// set, the composer binary is verified before it runs. A mismatch will fail
+// the build.
+func verifyComposerBinaryIfRequired(logger emitter, path string) error {
+	enabled, err := lookupBoolEnv(BpComposerVerifyIntegrity, false)
+	if err != nil || !enabled {
+		return err
+	}
+
+	expectedSHA256, found := os.LookupEnv(BpComposerSHA256)
+	if !found {
+		return nil
+	}
+
+	logger.Process("Verifying integrity of the composer binary")
+
+	mismatch, err := VerifyComposerBinary(path, expectedSHA256)
+	if err != nil {
+		return err
+	}
+
+	if mismatch != nil {
+		logger.Subprocess("Checksum mismatch for %s", mismatch)
+		return fmt.Errorf("integrity verification failed for 1 file(s):\n%s", mismatch)
+	}
+
+	logger.Subprocess("Verified composer binary")
+	logger.Break()
+
+	return nil
+}
+
+// withDistIntegrityVerificationIfRequired will check for env var
+// "BP_COMPOSER_VERIFY_INTEGRITY". If set to true, the given install, which
+// installs the packages with the given execution of `composer install`,
+// first only downloads them with `--download-only`, and with `--no-plugins`
+// and `--no-scripts`, so that no code of the downloaded packages runs, and
+// verifies the downloaded dist archives against `composer.lock`. Only then
+// are they installed as given, which runs the plugins and scripts. Any
+// mismatch will fail the build before any code of the packages has run.
+//
+// Composer without `--download-only` extracts the packages in the first
+// pass, and the install as given would not run the events of the root
+// package, such as "post-package-install", for the packages it finds
+// installed. So the workspace vendor directory is removed before both
+// passes, so that all archives are downloaded and verified, and the install
+// as given extracts them again from the verified archives.
+func withDistIntegrityVerificationIfRequired(logger emitter, fileSystem FileSystem, capabilities composerCapabilities, install func(execution pexec.Execution) error, composerLockPath, workspaceVendorDir string) (func(execution pexec.Execution) error, error) {
+	enabled, err := lookupBoolEnv(BpComposerVerifyIntegrity, false)
+	if err != nil || !enabled {
+		return install, err
+	}
+
+	downloadOnly := capabilities.downloadOnly()
+
+	return func(execution pexec.Execution) error {
+		verification := execution
+		verification.Args = append([]string{}, execution.Args...)
+
+		options := []string{"--no-plugins", "--no-scripts"}
+		if downloadOnly {
+			options = append([]string{"--download-only"}, options...)
+		} else {
+			logger.Subprocess("Removing %s, as Composer %s cannot only download the packages", workspaceVendorDir, capabilities.version)
+			err := fileSystem.RemoveAll(workspaceVendorDir)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, option := range options {
+			if !containsArg(verification.Args, option) {
+				verification.Args = append(verification.Args, option)
+			}
+		}
+
+		logger.Subprocess("Running 'composer %s' to verify the downloaded archives before any code of the packages runs", strings.Join(verification.Args, " "))
+		err := install(verification)
+		if err != nil {
+			return err
+		}
+
+		err = verifyDistIntegrity(logger, composerLockPath, composerCacheFilesDir(execution.Env, execution.Dir))
+		if err != nil {
+			return err
+		}
+
+		if !downloadOnly {
+			err = fileSystem.RemoveAll(workspaceVendorDir)
+			if err != nil { // untested
+				return err
+			}
+		}
+
+		return install(execution)
+	}, nil
+}
+
+func verifyDistIntegrity(logger emitter, composerLockPath, cacheFilesDir string) error {
+	logger.Process("Verifying integrity of downloaded files")
+
+	report, err := VerifyDistIntegrity(composerLockPath, cacheFilesDir)
+	if err != nil {
+		return err
+	}
+
+	logger.Subprocess("Verified %d package archive(s)", len(report.Verified))
+	if len(report.Unverifiable) > 0 {
+		logger.Subprocess("Unable to verify %d package(s) without a recorded checksum or downloaded archive", len(report.Unverifiable))
+		for _, name := range report.Unverifiable {
+			logger.Debug.Subprocess("- %s", name)
+		}
+	}
+
+	if len(report.Mismatches) == 0 {
+		logger.Break()
+		return nil
+	}
+
+	var details []string
+	for _, mismatch := range report.Mismatches {
+		logger.Subprocess("Checksum mismatch for %s", mismatch)
+		details = append(details, mismatch.String())
+	}
+
+	return fmt.Errorf("integrity verification failed for %d file(s):\n%s", len(report.Mismatches), strings.Join(details, "\n"))
+}
+
+func containsArg(args []string, arg string) bool {
+	for _, a := range args {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
+
+func fileChecksum(h hash.Hash, path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(h, file)
+	if err != nil { // untested
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// lookPath searches for an executable with the given name in the directories
+// of the given path, rather than the path of the current process.
+func lookPath(name, path string) (string, error) {
+	for _, dir := range filepath.SplitList(path) {
+		candidate := filepath.Join(dir, name)
+		info, err := os.Stat(candidate)
+		if err != nil {
+			continue
+		}
+
+		if !info.IsDir() && info.Mode()&0111 != 0 {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("executable %q not found in path %q", name, path)
+}
@@ -0,0 +1,141 @@
+package composer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testIntegrity(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("VerifyDistIntegrity", func() {
+		var (
+			composerLockPath string
+			cacheFilesDir    string
+		)
+
+		it.Before(func() {
+			composerLockPath = filepath.Join(workingDir, "composer.lock")
+			cacheFilesDir = filepath.Join(workingDir, "cache", "files")
+
+			Expect(os.MkdirAll(filepath.Join(cacheFilesDir, "some", "verified"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(cacheFilesDir, "some", "verified", "37ad518261d2763cd727a723532382ea9f2e729b.zip"), []byte("some-archive"), os.ModePerm)).To(Succeed())
+			// the archive of a previous version, which is not verified
+			Expect(os.WriteFile(filepath.Join(cacheFilesDir, "some", "verified", "abc.zip"), []byte("some-other-archive"), os.ModePerm)).To(Succeed())
+
+			Expect(os.MkdirAll(filepath.Join(cacheFilesDir, "some", "tampered"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(cacheFilesDir, "some", "tampered", "a30702dce90941923fc97bace7616bdfd225f798.zip"), []byte("tampered-archive"), os.ModePerm)).To(Succeed())
+		})
+
+		context("when composer.lock does not exist", func() {
+			it("returns an empty report", func() {
+				report, err := composer.VerifyDistIntegrity(composerLockPath, cacheFilesDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report).To(Equal(composer.IntegrityReport{}))
+			})
+		})
+
+		context("when composer.lock records dist checksums", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(composerLockPath, []byte(`{
+	"packages": [
+		{"name": "some/verified", "dist": {"type": "zip", "url": "https://example.com/some/verified.zip", "shasum": "B2803FAFD68ED39654AD9C262EBFBB18323ED6B9"}},
+		{"name": "some/tampered", "dist": {"type": "zip", "url": "https://example.com/some/tampered.zip", "shasum": "0000000000000000000000000000000000000000"}},
+		{"name": "some/without-shasum", "dist": {"type": "zip", "url": "https://example.com/some/without-shasum.zip", "shasum": ""}},
+		{"name": "some/not-downloaded", "dist": {"type": "zip", "url": "https://example.com/some/not-downloaded.zip", "shasum": "1111111111111111111111111111111111111111"}}
+	],
+	"packages-dev": [
+		{"name": "some/source-only"}
+	]
+}`), os.ModePerm)).To(Succeed())
+			})
+
+			it("reports verified, unverifiable and mismatching packages", func() {
+				report, err := composer.VerifyDistIntegrity(composerLockPath, cacheFilesDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.Verified).To(Equal([]string{"some/verified"}))
+				Expect(report.Unverifiable).To(Equal([]string{"some/without-shasum", "some/not-downloaded", "some/source-only"}))
+				Expect(report.Mismatches).To(Equal([]composer.IntegrityMismatch{
+					{
+						Name:     "some/tampered",
+						Expected: "0000000000000000000000000000000000000000",
+						Actual:   []string{"ca001f466ca1b19c42bbfea7060245ffd330c474"},
+					},
+				}))
+				Expect(report.Mismatches[0].String()).To(Equal("some/tampered: expected 0000000000000000000000000000000000000000, found ca001f466ca1b19c42bbfea7060245ffd330c474"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when composer.lock is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(composerLockPath, []byte(`%%%`), os.ModePerm)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := composer.VerifyDistIntegrity(composerLockPath, cacheFilesDir)
+					Expect(err).To(MatchError(ContainSubstring("invalid character")))
+				})
+			})
+		})
+	})
+
+	context("VerifyComposerBinary", func() {
+		var binDir string
+
+		it.Before(func() {
+			binDir = filepath.Join(workingDir, "bin")
+			Expect(os.MkdirAll(binDir, os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(binDir, "composer"), []byte("composer-binary"), 0755)).To(Succeed())
+		})
+
+		context("when the checksum matches", func() {
+			it("returns no mismatch", func() {
+				mismatch, err := composer.VerifyComposerBinary(filepath.Join(workingDir, "empty")+string(os.PathListSeparator)+binDir, "245f550617b4e9ffe51bf2c938fa2271949744b4e6615c12a5252855ee819dca")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mismatch).To(BeNil())
+			})
+		})
+
+		context("when the checksum does not match", func() {
+			it("returns the mismatch", func() {
+				mismatch, err := composer.VerifyComposerBinary(binDir, "some-checksum")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mismatch).To(Equal(&composer.IntegrityMismatch{
+					Name:     filepath.Join(binDir, "composer"),
+					Expected: "some-checksum",
+					Actual:   []string{"245f550617b4e9ffe51bf2c938fa2271949744b4e6615c12a5252855ee819dca"},
+				}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when composer is not on the path", func() {
+				it("returns an error", func() {
+					_, err := composer.VerifyComposerBinary(workingDir, "some-checksum")
+					Expect(err).To(MatchError(ContainSubstring(`executable "composer" not found in path`)))
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,119 @@
+package composer
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+const (
+	// composerNoDev is the environment variable read by Composer to skip
+	// the dev dependencies
+	// https://getcomposer.org/doc/03-cli.md#composer-no-dev
+	composerNoDev = "COMPOSER_NO_DEV"
+
+	// composerHomeEnv is the environment variable read by Composer to determine
+	// its home directory
+	// https://getcomposer.org/doc/03-cli.md#composer-home
+	composerHomeEnv = "COMPOSER_HOME"
+
+	// LaunchComposerHome is the COMPOSER_HOME at launch. The layers are not
+	// writable at launch, so it points to a temporary directory instead.
+	LaunchComposerHome = "/tmp/composer-home"
+
+	// LaunchComposerHomeExecD is the name of the exec.d executable which
+	// creates COMPOSER_HOME at launch.
+	LaunchComposerHomeExecD = "prepare-composer-home"
+)
+
+// configureLaunchEnv sets the environment for invocations of `composer` at
+// launch, so that they behave consistently with the build: the same vendor
+// directory and composer.json are used, dev dependencies are only installed if
+// they have been installed during the build, and COMPOSER_HOME is writable.
+// All of them can be overridden at launch. The `prepare-composer-home` exec.d
+// executable creates COMPOSER_HOME at launch, see PrepareComposerHome.
+func configureLaunchEnv(logger emitter, context packit.BuildContext, composerPackagesLayer *packit.Layer, workspaceVendorDir string, installOptions []InstallOption) {
+	if !composerPackagesLayer.Launch {
+		return
+	}
+
+	noDev := "0"
+	if devPackagesExcluded(installOptions) {
+		noDev = "1"
+	}
+
+	env := [][2]string{
+		{ComposerVendorDir, workspaceVendorDir},
+		{composerNoDev, noDev},
+		{composerHomeEnv, LaunchComposerHome},
+	}
+
+	// the composer.json selected with BP_COMPOSER_FILE is used at launch as well
+	if composerFile, found := os.LookupEnv(BpComposerFile); found && composerFile != "" {
+		composerJsonPath, _, _, _ := FindComposerFiles(context.WorkingDir)
+		env = append(env, [2]string{Composer, composerJsonPath})
+	}
+
+	logger.Process("Configuring launch environment for composer")
+	for _, variable := range env {
+		composerPackagesLayer.LaunchEnv.Default(variable[0], variable[1])
+		logger.Subprocess("%s -> %q", variable[0], variable[1])
+	}
+	logger.Break()
+
+	composerPackagesLayer.ExecD = append(composerPackagesLayer.ExecD, filepath.Join(context.CNBPath, "bin", LaunchComposerHomeExecD))
+}
+
+// PrepareComposerHome creates the given COMPOSER_HOME, so that `composer` can
+// be run at launch by users other than root, and with a read-only root file
+// system. If it cannot be created or is not writable, e.g. because `/tmp` is
+// read-only, a `composer-home` directory in the given temporary directory is
+// used instead.
+//
+// It is run by the `prepare-composer-home` exec.d executable at launch.
+// Returns the COMPOSER_HOME to be set, or an empty string if the given one is
+// usable. As `composer` is not necessarily run at launch, a COMPOSER_HOME
+// which cannot be created is logged rather than failing the container start.
+func PrepareComposerHome(logger scribe.Emitter, composerHome, tempDir string) string {
+	err := createWritableDir(composerHome)
+	if err == nil {
+		logger.Debug.Process("Using %s as COMPOSER_HOME", composerHome)
+		return ""
+	}
+
+	fallback := filepath.Join(tempDir, "composer-home")
+	if fallback == composerHome {
+		logger.Process("COMPOSER_HOME %s is not writable: %s", composerHome, err)
+		return ""
+	}
+
+	fallbackErr := createWritableDir(fallback)
+	if fallbackErr != nil {
+		logger.Process("COMPOSER_HOME %s is not writable: %s", composerHome, err)
+		logger.Subprocess("%s is not writable either: %s", fallback, fallbackErr)
+		return ""
+	}
+
+	logger.Process("COMPOSER_HOME %s is not writable, using %s instead: %s", composerHome, fallback, err)
+
+	return fallback
+}
+
+// createWritableDir creates the given directory, and verifies that a file
+// can be created in it.
+func createWritableDir(dir string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(dir, ".writable")
+	if err != nil {
+		return err
+	}
+	file.Close()
+
+	return os.Remove(file.Name())
+}
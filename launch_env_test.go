@@ -0,0 +1,77 @@
+package composer_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLaunchEnv(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		buffer *bytes.Buffer
+		logger scribe.Emitter
+
+		tempDir string
+	)
+
+	it.Before(func() {
+		buffer = bytes.NewBuffer(nil)
+		logger = scribe.NewEmitter(buffer).WithLevel("DEBUG")
+
+		var err error
+		tempDir, err = os.MkdirTemp("", "temp-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		// a regular file, so that no directory can be created underneath it,
+		// even when running as root
+		Expect(os.WriteFile(filepath.Join(tempDir, "read-only"), nil, 0644)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(tempDir)).To(Succeed())
+	})
+
+	context("PrepareComposerHome", func() {
+		it("creates COMPOSER_HOME", func() {
+			composerHome := filepath.Join(tempDir, "some", "composer-home")
+
+			Expect(composer.PrepareComposerHome(logger, composerHome, tempDir)).To(BeEmpty())
+			Expect(composerHome).To(BeADirectory())
+
+			entries, err := os.ReadDir(composerHome)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+
+			Expect(buffer.String()).To(ContainSubstring("Using %s as COMPOSER_HOME", composerHome))
+		})
+
+		context("when COMPOSER_HOME cannot be created", func() {
+			it("falls back to the temporary directory", func() {
+				composerHome := filepath.Join(tempDir, "read-only", "composer-home")
+
+				Expect(composer.PrepareComposerHome(logger, composerHome, tempDir)).To(Equal(filepath.Join(tempDir, "composer-home")))
+				Expect(filepath.Join(tempDir, "composer-home")).To(BeADirectory())
+
+				Expect(buffer.String()).To(ContainSubstring("COMPOSER_HOME %s is not writable, using %s instead", composerHome, filepath.Join(tempDir, "composer-home")))
+			})
+
+			it("logs if the temporary directory is not writable either", func() {
+				composerHome := filepath.Join(tempDir, "read-only", "some-home")
+
+				Expect(composer.PrepareComposerHome(logger, composerHome, filepath.Join(tempDir, "read-only"))).To(BeEmpty())
+
+				Expect(buffer.String()).To(ContainSubstring("COMPOSER_HOME %s is not writable", composerHome))
+				Expect(buffer.String()).To(ContainSubstring("is not writable either"))
+			})
+		})
+	})
+}
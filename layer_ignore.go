@@ -0,0 +1,109 @@
+package composer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LayerIgnoreFileName is the name of the file in the application root, which
+// lists glob patterns of paths excluded from the composer-packages layer.
+const LayerIgnoreFileName = ".composer-layerignore"
+
+// LayerIgnore excludes paths of the workspace from being copied into the
+// composer-packages layer, e.g. to avoid caching huge generated assets.
+type LayerIgnore struct {
+	root     string
+	patterns []string
+}
+
+// LoadLayerIgnore reads the patterns of `.composer-layerignore` in the given
+// working directory, one per line. Empty lines and lines starting with `#`
+// are skipped. Returns a LayerIgnore without patterns if the file does not
+// exist.
+//
+// The patterns use the syntax of filepath.Match and are matched against the
+// paths relative to the working directory, e.g. `vendor/*/*/tests`. Patterns
+// without a slash are matched against the name of each file or directory, at
+// any depth, e.g. `*.map`. Directories which match are excluded with their
+// contents.
+func LoadLayerIgnore(workingDir string) (LayerIgnore, error) {
+	ignore := LayerIgnore{root: workingDir}
+
+	file, err := os.Open(filepath.Join(workingDir, LayerIgnoreFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ignore, nil
+		}
+		return LayerIgnore{}, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		pattern := strings.TrimSpace(scanner.Text())
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+
+		pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "/"), "/")
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return LayerIgnore{}, fmt.Errorf("invalid pattern %q in %s: %w", pattern, LayerIgnoreFileName, err)
+		}
+
+		ignore.patterns = append(ignore.patterns, pattern)
+	}
+
+	if err := scanner.Err(); err != nil { // untested
+		return LayerIgnore{}, err
+	}
+
+	return ignore, nil
+}
+
+// Patterns returns the patterns of the ignore file.
+func (i LayerIgnore) Patterns() []string {
+	return i.patterns
+}
+
+// Excludes returns whether the given absolute path matches any of the
+// patterns. Paths outside of the working directory are never excluded.
+func (i LayerIgnore) Excludes(path string) bool {
+	if len(i.patterns) == 0 {
+		return false
+	}
+
+	relativePath, err := filepath.Rel(i.root, path)
+	if err != nil || relativePath == "." || strings.HasPrefix(relativePath, "..") {
+		return false
+	}
+	relativePath = filepath.ToSlash(relativePath)
+
+	for _, pattern := range i.patterns {
+		subject := relativePath
+		if !strings.Contains(pattern, "/") {
+			subject = filepath.Base(relativePath)
+		}
+
+		if matched, _ := filepath.Match(pattern, subject); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// logLayerIgnore logs the patterns of the given LayerIgnore, if there are any.
+func logLayerIgnore(logger emitter, ignore LayerIgnore) {
+	if len(ignore.patterns) == 0 {
+		return
+	}
+
+	logger.Subprocess("Excluding paths matching %s", LayerIgnoreFileName)
+	for _, pattern := range ignore.patterns {
+		logger.Debug.Subprocess("- %s", pattern)
+	}
+}
@@ -0,0 +1,89 @@
+package composer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLayerIgnore(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("when .composer-layerignore exists", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, ".composer-layerignore"), []byte(`# generated assets
+vendor/*/*/tests
+
+*.map
+/public/build/
+`), os.ModePerm)).To(Succeed())
+		})
+
+		it("reads the patterns", func() {
+			ignore, err := composer.LoadLayerIgnore(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ignore.Patterns()).To(Equal([]string{"vendor/*/*/tests", "*.map", "public/build"}))
+		})
+
+		it("excludes the matching paths", func() {
+			ignore, err := composer.LoadLayerIgnore(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ignore.Excludes(filepath.Join(workingDir, "vendor", "some", "package", "tests"))).To(BeTrue())
+			Expect(ignore.Excludes(filepath.Join(workingDir, "vendor", "some", "package", "src"))).To(BeFalse())
+			Expect(ignore.Excludes(filepath.Join(workingDir, "vendor", "some", "package", "dist", "app.js.map"))).To(BeTrue())
+			Expect(ignore.Excludes(filepath.Join(workingDir, "vendor", "some", "package", "dist", "app.js"))).To(BeFalse())
+			Expect(ignore.Excludes(filepath.Join(workingDir, "public", "build"))).To(BeTrue())
+			Expect(ignore.Excludes(filepath.Join(workingDir, "web", "public", "build"))).To(BeFalse())
+		})
+
+		it("does not exclude paths outside of the working directory", func() {
+			ignore, err := composer.LoadLayerIgnore(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ignore.Excludes(workingDir)).To(BeFalse())
+			Expect(ignore.Excludes(filepath.Join(filepath.Dir(workingDir), "app.js.map"))).To(BeFalse())
+		})
+	})
+
+	context("when .composer-layerignore does not exist", func() {
+		it("excludes nothing", func() {
+			ignore, err := composer.LoadLayerIgnore(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ignore.Patterns()).To(BeEmpty())
+			Expect(ignore.Excludes(filepath.Join(workingDir, "vendor"))).To(BeFalse())
+		})
+	})
+
+	context("failure cases", func() {
+		context("when a pattern is invalid", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".composer-layerignore"), []byte("vendor/[a-"), os.ModePerm)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := composer.LoadLayerIgnore(workingDir)
+				Expect(err).To(MatchError(`invalid pattern "vendor/[a-" in .composer-layerignore: syntax error in pattern`))
+			})
+		})
+	})
+}
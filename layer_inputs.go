@@ -0,0 +1,164 @@
+package composer
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// layerInputsMetadataKey is the key of the composer-packages layer metadata,
+// which records the inputs the layer has been built with, besides
+// `composer.lock`, the stack and BP_COMPOSER_CONFIG.
+const layerInputsMetadataKey = "layer-inputs"
+
+// autoloaderSuffixStackPlaceholder is replaced by the stack ID in
+// BP_COMPOSER_AUTOLOADER_SUFFIX.
+const autoloaderSuffixStackPlaceholder = "{stack}"
+
+// autoloaderSuffixPattern matches the suffixes which are valid in the names
+// of the generated autoloader classes, e.g. `ComposerAutoloaderInit<suffix>`.
+var autoloaderSuffixPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// nonIdentifierChars matches the characters of a stack ID, which are not
+// allowed in an autoloader suffix.
+var nonIdentifierChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// LayerInputs are the inputs the composer-packages layer depends on, which
+// are not part of the checksum of `composer.lock`:
+//   - "autoloader-suffix": the suffix of the generated autoloader classes
+//   - "install-options": the options of `composer install`
+//   - "arch": the architecture of the build target
+//   - "distro": the distribution of the build target, e.g. "ubuntu 24.04",
+//     if known, as PHP extensions with native dependencies, which packages
+//     may install, only work on the distribution they have been built for
+//   - "cache-key-salt": the SHA-256 of BP_COMPOSER_CACHE_KEY_SALT, if set
+type LayerInputs map[string]string
+
+// lookupAutoloaderSuffix returns the suffix of the generated autoloader
+// classes, which is the value of "BP_COMPOSER_AUTOLOADER_SUFFIX" with
+// "{stack}" replaced by the given stack ID, or ComposerAutoloaderSuffix.
+// Characters of the stack ID which are not allowed in class names, such as
+// dots, are replaced by underscores.
+func lookupAutoloaderSuffix(stack string) (string, error) {
+	value, ok := os.LookupEnv(BpComposerAutoloaderSuffix)
+	if !ok || value == "" {
+		return ComposerAutoloaderSuffix, nil
+	}
+
+	suffix := strings.ReplaceAll(value, autoloaderSuffixStackPlaceholder, nonIdentifierChars.ReplaceAllString(stack, "_"))
+	if !autoloaderSuffixPattern.MatchString(suffix) {
+		return "", fmt.Errorf("%s must only contain letters, digits, underscores and %q, found %q", BpComposerAutoloaderSuffix, autoloaderSuffixStackPlaceholder, value)
+	}
+
+	return suffix, nil
+}
+
+// determineLayerInputs returns the inputs of a composer-packages layer built
+// with the given autoloader suffix and install options.
+func determineLayerInputs(autoloaderSuffix string, installOptions []InstallOption) LayerInputs {
+	target := LookupBuildTarget()
+
+	var options []string
+	for _, option := range installOptions {
+		options = append(options, option.Value)
+	}
+
+	inputs := LayerInputs{
+		"autoloader-suffix": autoloaderSuffix,
+		"install-options":   strings.Join(options, " "),
+		"arch":              target.Arch,
+	}
+
+	if distro := target.Distro(); distro != "" {
+		inputs["distro"] = distro
+	}
+
+	if salt := os.Getenv(BpComposerCacheKeySalt); salt != "" {
+		// the salt may identify a tenant, and the metadata of launch layers is
+		// visible in the image
+		inputs["cache-key-salt"] = fmt.Sprintf("%x", sha256.Sum256([]byte(salt)))
+	}
+
+	return inputs
+}
+
+// cached returns the inputs recorded in the given layer metadata. Layers
+// cached before the inputs have been recorded have been built with the
+// default autoloader suffix and without salt, their other inputs are unknown
+// and therefore assumed to match the given inputs.
+func (i LayerInputs) cached(metadata map[string]interface{}) map[string]string {
+	cached := map[string]string{}
+	switch value := metadata[layerInputsMetadataKey].(type) {
+	case map[string]interface{}:
+		for name, input := range value {
+			cached[name], _ = input.(string)
+		}
+	case map[string]string:
+		cached = value
+	case LayerInputs:
+		cached = value
+	default:
+		cached["autoloader-suffix"] = ComposerAutoloaderSuffix
+		cached["cache-key-salt"] = ""
+		for name := range i {
+			if _, ok := cached[name]; !ok {
+				cached[name] = i[name]
+			}
+		}
+	}
+
+	return cached
+}
+
+// Changed returns the names of the inputs, which differ from the ones
+// recorded in the given layer metadata, sorted by name. Layers cached before
+// the inputs have been recorded have been built with the default autoloader
+// suffix and without salt, their other inputs are unknown and therefore
+// considered unchanged.
+func (i LayerInputs) Changed(metadata map[string]interface{}) []string {
+	cached := i.cached(metadata)
+
+	names := map[string]bool{}
+	for name := range i {
+		names[name] = true
+	}
+	for name := range cached {
+		names[name] = true
+	}
+
+	var changed []string
+	for name := range names {
+		if i[name] != cached[name] {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+
+	return changed
+}
+
+// Metadata returns the inputs in the format of the layer metadata.
+func (i LayerInputs) Metadata() map[string]interface{} {
+	metadata := map[string]interface{}{}
+	for name, input := range i {
+		metadata[name] = input
+	}
+	return metadata
+}
+
+// logLayerInputs logs the inputs sorted by name.
+func logLayerInputs(logger emitter, inputs LayerInputs) {
+	var names []string
+	for name := range inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	logger.Debug.Process("Layer inputs:")
+	for _, name := range names {
+		logger.Debug.Subprocess("- %s: %s", name, inputs[name])
+	}
+}
@@ -0,0 +1,54 @@
+package composer_test
+
+import (
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLayerInputs(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		inputs composer.LayerInputs
+	)
+
+	it.Before(func() {
+		inputs = composer.LayerInputs{
+			"autoloader-suffix": "PaketoDefaultAutoloaderSuffix",
+			"install-options":   "--no-dev",
+			"arch":              "arm64",
+		}
+	})
+
+	context("Changed", func() {
+		it("returns the inputs which differ from the recorded ones", func() {
+			changed := inputs.Changed(map[string]interface{}{
+				"layer-inputs": map[string]interface{}{
+					"autoloader-suffix": "PaketoDefaultAutoloaderSuffix",
+					"install-options":   "--no-dev --optimize-autoloader",
+					"arch":              "amd64",
+					"cache-key-salt":    "some-sha",
+				},
+			})
+			Expect(changed).To(Equal([]string{"arch", "cache-key-salt", "install-options"}))
+		})
+
+		it("returns no inputs if all are unchanged", func() {
+			Expect(inputs.Changed(map[string]interface{}{"layer-inputs": inputs.Metadata()})).To(BeEmpty())
+		})
+
+		context("when the layer has been cached without inputs", func() {
+			it("only compares the autoloader suffix and the salt with their defaults", func() {
+				Expect(inputs.Changed(map[string]interface{}{})).To(BeEmpty())
+
+				inputs["autoloader-suffix"] = "Blue"
+				inputs["cache-key-salt"] = "some-sha"
+				Expect(inputs.Changed(map[string]interface{}{})).To(Equal([]string{"autoloader-suffix", "cache-key-salt"}))
+			})
+		})
+	})
+}
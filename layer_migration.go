@@ -0,0 +1,84 @@
+package composer
+
+import (
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// MetadataVersionKey is the key of the layout version in the metadata of a
+// cached layer.
+const MetadataVersionKey = "metadata-version"
+
+// ComposerPackagesMetadataVersion is the version of the layout of the
+// composer-packages layer, i.e. its directories and metadata keys. It must be
+// incremented whenever the layout changes, and a migration from the previous
+// version must be appended to composerPackagesMigrations. The migration can
+// be nil, if the cached layer has to be rebuilt instead.
+const ComposerPackagesMetadataVersion = 1
+
+// LayerMigration migrates the contents and metadata of a cached layer from
+// one metadata version to the next.
+type LayerMigration func(layer *packit.Layer) error
+
+// composerPackagesMigrations migrates the composer-packages layer: the
+// migration at index i migrates from version i to version i+1.
+var composerPackagesMigrations = []LayerMigration{
+	// layers built before the metadata version was introduced are rebuilt,
+	// as their layout depends on the release of this buildpack which built
+	// them
+	nil,
+}
+
+// MigrateLayer migrates the given cached layer to the given metadata version,
+// by applying the migrations from its recorded version on. Layers without
+// recorded version have version 0.
+//
+// Returns false if the layer cannot be reused and has to be rebuilt, because
+// it has been built by a newer release of this buildpack, or there is no
+// migration from its version. Returns false for layers without metadata, i.e.
+// layers which have not been cached.
+func MigrateLayer(logger Logger, layer *packit.Layer, version int, migrations []LayerMigration) (bool, error) {
+	return migrateLayer(newEmitter(logger), layer, version, migrations)
+}
+
+// migrateLayer is MigrateLayer for the steps of the build.
+func migrateLayer(logger emitter, layer *packit.Layer, version int, migrations []LayerMigration) (bool, error) {
+	if len(layer.Metadata) == 0 {
+		return false, nil
+	}
+
+	cachedVersion := 0
+	switch value := layer.Metadata[MetadataVersionKey].(type) {
+	case int:
+		cachedVersion = value
+	case int64:
+		cachedVersion = int(value)
+	case float64:
+		cachedVersion = int(value)
+	}
+
+	if cachedVersion == version {
+		return true, nil
+	}
+
+	if cachedVersion > version {
+		logger.Process("Cached layer %s has metadata version %d, which is newer than %d, rebuilding it", layer.Name, cachedVersion, version)
+		return false, nil
+	}
+
+	for v := cachedVersion; v < version; v++ {
+		if v >= len(migrations) || migrations[v] == nil {
+			logger.Process("Cached layer %s has metadata version %d, which cannot be migrated to %d, rebuilding it", layer.Name, cachedVersion, version)
+			return false, nil
+		}
+
+		logger.Debug.Process("Migrating cached layer %s from metadata version %d to %d", layer.Name, v, v+1)
+		err := migrations[v](layer)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	layer.Metadata[MetadataVersionKey] = version
+
+	return true, nil
+}
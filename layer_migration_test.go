@@ -0,0 +1,131 @@
+package composer_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLayerMigration(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		buffer *bytes.Buffer
+		logger composer.Logger
+
+		migrated   []int
+		migrations []composer.LayerMigration
+	)
+
+	it.Before(func() {
+		buffer = bytes.NewBuffer(nil)
+		logger = composer.NewEmitterLogger(scribe.NewEmitter(buffer))
+
+		migrated = nil
+		migration := func(from int) composer.LayerMigration {
+			return func(layer *packit.Layer) error {
+				migrated = append(migrated, from)
+				layer.Metadata["migrated-from"] = from
+				return nil
+			}
+		}
+
+		migrations = []composer.LayerMigration{nil, migration(1), migration(2)}
+	})
+
+	context("when the layer has the current metadata version", func() {
+		it("reuses the layer as it is", func() {
+			layer := packit.Layer{Metadata: map[string]interface{}{"metadata-version": int64(3)}}
+
+			ok, err := composer.MigrateLayer(logger, &layer, 3, migrations)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(migrated).To(BeEmpty())
+			Expect(layer.Metadata).To(Equal(map[string]interface{}{"metadata-version": int64(3)}))
+		})
+	})
+
+	context("when the layer has an older metadata version", func() {
+		it("applies the migrations in order", func() {
+			layer := packit.Layer{Metadata: map[string]interface{}{"metadata-version": int64(1)}}
+
+			ok, err := composer.MigrateLayer(logger, &layer, 3, migrations)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(migrated).To(Equal([]int{1, 2}))
+			Expect(layer.Metadata).To(Equal(map[string]interface{}{
+				"metadata-version": 3,
+				"migrated-from":    2,
+			}))
+		})
+	})
+
+	context("when there is no migration from the metadata version", func() {
+		it("rebuilds the layer", func() {
+			layer := packit.Layer{Name: "some-layer", Metadata: map[string]interface{}{"stack": "some-stack"}}
+
+			ok, err := composer.MigrateLayer(logger, &layer, 3, migrations)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+			Expect(migrated).To(BeEmpty())
+			Expect(buffer.String()).To(ContainSubstring("Cached layer some-layer has metadata version 0, which cannot be migrated to 3, rebuilding it"))
+		})
+
+		it("rebuilds the layer if the migrations end before the current version", func() {
+			layer := packit.Layer{Name: "some-layer", Metadata: map[string]interface{}{"metadata-version": int64(2)}}
+
+			ok, err := composer.MigrateLayer(logger, &layer, 4, migrations)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+			Expect(buffer.String()).To(ContainSubstring("has metadata version 2, which cannot be migrated to 4"))
+		})
+	})
+
+	context("when the layer has a newer metadata version", func() {
+		it("rebuilds the layer", func() {
+			layer := packit.Layer{Name: "some-layer", Metadata: map[string]interface{}{"metadata-version": int64(4)}}
+
+			ok, err := composer.MigrateLayer(logger, &layer, 3, migrations)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+			Expect(migrated).To(BeEmpty())
+			Expect(buffer.String()).To(ContainSubstring("Cached layer some-layer has metadata version 4, which is newer than 3, rebuilding it"))
+		})
+	})
+
+	context("when the layer has not been cached", func() {
+		it("rebuilds the layer silently", func() {
+			layer := packit.Layer{Metadata: map[string]interface{}{}}
+
+			ok, err := composer.MigrateLayer(logger, &layer, 3, migrations)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+			Expect(buffer.String()).To(BeEmpty())
+		})
+	})
+
+	context("failure cases", func() {
+		context("when a migration fails", func() {
+			it.Before(func() {
+				migrations[2] = func(*packit.Layer) error {
+					return errors.New("failed to migrate")
+				}
+			})
+
+			it("returns an error", func() {
+				layer := packit.Layer{Metadata: map[string]interface{}{"metadata-version": int64(1)}}
+
+				_, err := composer.MigrateLayer(logger, &layer, 3, migrations)
+				Expect(err).To(MatchError("failed to migrate"))
+				Expect(migrated).To(Equal([]int{1}))
+			})
+		})
+	})
+}
@@ -0,0 +1,103 @@
+package composer
+
+import (
+	"path/filepath"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// layerStagingDir is the directory inside a layer, into which its new
+// contents are written before they replace the previous contents.
+const layerStagingDir = ".staging"
+
+// layerStaging collects the new contents of a cached layer, so that its
+// previous contents are only replaced once the new contents are complete.
+// If the build fails before, the previous contents are kept, and can be
+// reused by the next build.
+type layerStaging struct {
+	logger     emitter
+	fileSystem FileSystem
+	dir        string
+	promoted   bool
+}
+
+// stageLayer creates the staging directory inside the given layer. Leftovers
+// of an interrupted build are removed.
+func stageLayer(logger emitter, fileSystem FileSystem, layer packit.Layer) (*layerStaging, error) {
+	staging := &layerStaging{
+		logger:     logger,
+		fileSystem: fileSystem,
+		dir:        filepath.Join(layer.Path, layerStagingDir),
+	}
+
+	err := fileSystem.RemoveAll(staging.dir)
+	if err != nil { // untested
+		return nil, err
+	}
+
+	err = fileSystem.MkdirAll(staging.dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return staging, nil
+}
+
+// path returns the path of the given directory of the layer in the staging
+// directory.
+func (s *layerStaging) path(elem ...string) string {
+	return filepath.Join(append([]string{s.dir}, elem...)...)
+}
+
+// promote resets the given layer, and moves the staged contents into it.
+// The staging directory is moved next to the layer first, as resetting the
+// layer removes all of its contents. All moves are renames within the layers
+// directory, so no file is copied.
+func (s *layerStaging) promote(layer packit.Layer) (packit.Layer, error) {
+	promotedDir := layer.Path + layerStagingDir
+
+	err := s.fileSystem.Rename(s.dir, promotedDir)
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+	s.dir = promotedDir
+
+	layer, err = layer.Reset()
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+
+	entries, err := s.fileSystem.ReadDir(promotedDir)
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+
+	for _, entry := range entries {
+		err = s.fileSystem.Rename(filepath.Join(promotedDir, entry.Name()), filepath.Join(layer.Path, entry.Name()))
+		if err != nil { // untested
+			return packit.Layer{}, err
+		}
+	}
+
+	s.promoted = true
+	s.logger.Debug.Subprocess("Replaced the contents of %s with %d staged path(s)", layer.Path, len(entries))
+
+	return layer, s.fileSystem.RemoveAll(promotedDir)
+}
+
+// cleanup removes the staged contents, unless they have been promoted, so
+// that the previous contents of the layer are kept. It is deferred, so a
+// failure to remove them is only logged, and they are removed by the next
+// build.
+func (s *layerStaging) cleanup() {
+	if s.promoted {
+		return
+	}
+
+	s.logger.Process("Discarding the staged contents, the previous contents of the layer have been kept")
+
+	err := s.fileSystem.RemoveAll(s.dir)
+	if err != nil { // untested
+		s.logger.Subprocess("Failed to remove %s: %s", s.dir, err)
+	}
+}
@@ -0,0 +1,278 @@
+package composer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	composersbom "github.com/paketo-buildpacks/composer/sbom"
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+const (
+	// LicensePolicyOff disables the license policy
+	LicensePolicyOff = "off"
+
+	// LicensePolicyWarn logs the packages whose license is not allowed
+	LicensePolicyWarn = "warn"
+
+	// LicensePolicyFail fails the build if the license of any package is not
+	// allowed
+	LicensePolicyFail = "fail"
+)
+
+// LicenseAllowed returns whether the given SPDX license expression is
+// allowed by the given license identifiers, which are compared case
+// insensitively:
+//   - `A OR B` is allowed if either of A and B is allowed
+//   - `A AND B` is allowed if both A and B are allowed
+//   - `A WITH E` is allowed if either `A WITH E` or A is allowed
+//
+// An empty expression, i.e. an unknown license, is never allowed.
+// https://spdx.github.io/spdx-spec/v2.3/SPDX-license-expressions/
+func LicenseAllowed(expression string, allowed []string) (bool, error) {
+	allowedSet := map[string]bool{}
+	for _, license := range allowed {
+		allowedSet[strings.ToLower(license)] = true
+	}
+
+	parser := licenseExpressionParser{
+		tokens:  strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expression)),
+		allowed: allowedSet,
+	}
+
+	if len(parser.tokens) == 0 {
+		return false, nil
+	}
+
+	result, err := parser.or()
+	if err != nil {
+		return false, fmt.Errorf("invalid license expression %q: %w", expression, err)
+	}
+
+	if parser.position < len(parser.tokens) {
+		return false, fmt.Errorf("invalid license expression %q: unexpected %q", expression, parser.tokens[parser.position])
+	}
+
+	return result, nil
+}
+
+// licenseExpressionParser evaluates an SPDX license expression by recursive
+// descent, with WITH binding stronger than AND, and AND stronger than OR.
+type licenseExpressionParser struct {
+	tokens   []string
+	position int
+	allowed  map[string]bool
+}
+
+func (p *licenseExpressionParser) peek() string {
+	if p.position < len(p.tokens) {
+		return p.tokens[p.position]
+	}
+	return ""
+}
+
+func (p *licenseExpressionParser) next() string {
+	token := p.peek()
+	p.position++
+	return token
+}
+
+func (p *licenseExpressionParser) or() (bool, error) {
+	result, err := p.and()
+	if err != nil {
+		return false, err
+	}
+
+	for strings.EqualFold(p.peek(), "OR") {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return false, err
+		}
+		result = result || right
+	}
+
+	return result, nil
+}
+
+func (p *licenseExpressionParser) and() (bool, error) {
+	result, err := p.with()
+	if err != nil {
+		return false, err
+	}
+
+	for strings.EqualFold(p.peek(), "AND") {
+		p.next()
+		right, err := p.with()
+		if err != nil {
+			return false, err
+		}
+		result = result && right
+	}
+
+	return result, nil
+}
+
+func (p *licenseExpressionParser) with() (bool, error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return false, fmt.Errorf("unexpected end")
+	case token == "(":
+		result, err := p.or()
+		if err != nil {
+			return false, err
+		}
+		if p.next() != ")" {
+			return false, fmt.Errorf("missing )")
+		}
+		return result, nil
+	case token == ")" || isLicenseOperator(token):
+		return false, fmt.Errorf("unexpected %q", token)
+	}
+
+	license := strings.ToLower(token)
+	if !strings.EqualFold(p.peek(), "WITH") {
+		return p.allowed[license], nil
+	}
+
+	p.next()
+	exception := p.next()
+	if exception == "" || exception == "(" || exception == ")" || isLicenseOperator(exception) {
+		return false, fmt.Errorf("missing exception after WITH")
+	}
+
+	return p.allowed[fmt.Sprintf("%s with %s", license, strings.ToLower(exception))] || p.allowed[license], nil
+}
+
+func isLicenseOperator(token string) bool {
+	return strings.EqualFold(token, "OR") || strings.EqualFold(token, "AND") || strings.EqualFold(token, "WITH")
+}
+
+// licenseSBOMExtensions are the SBOM formats the licenses are read from, in
+// order of preference.
+var licenseSBOMExtensions = []string{"spdx.json", "cdx.json"}
+
+// readLicensesFromSBOM returns the licenses of the packages in the SBOM of the
+// given layer. The content of the SBOM is read again when it is exported, so
+// it is rewound if possible, and replaced otherwise.
+func readLicensesFromSBOM(composerPackagesLayer *packit.Layer) ([]composersbom.PackageLicense, bool, error) {
+	if composerPackagesLayer.SBOM == nil {
+		return nil, false, nil
+	}
+
+	formats := composerPackagesLayer.SBOM.Formats()
+	for _, extension := range licenseSBOMExtensions {
+		for i, format := range formats {
+			if format.Extension != extension {
+				continue
+			}
+
+			content, err := io.ReadAll(format.Content)
+			if err != nil { // untested
+				return nil, false, err
+			}
+
+			if seeker, ok := format.Content.(io.Seeker); ok {
+				_, err = seeker.Seek(0, io.SeekStart)
+				if err != nil { // untested
+					return nil, false, err
+				}
+			} else {
+				formats[i].Content = bytes.NewReader(content)
+				composerPackagesLayer.SBOM = cachedSBOM(formats)
+			}
+
+			licenses, err := composersbom.ParseLicenses(content, extension)
+			if err != nil {
+				return nil, false, fmt.Errorf("failed to read licenses from the %s SBOM: %w", extension, err)
+			}
+
+			return licenses, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+// checkLicensePolicyIfRequired will check for env var
+// "BP_COMPOSER_LICENSE_POLICY". If set to "warn" or "fail", the licenses of
+// the packages in the SBOM of the composer-packages layer are evaluated
+// against the SPDX license identifiers of "BP_COMPOSER_ALLOWED_LICENSES".
+// Packages without a known license are not allowed. With "warn", they are
+// logged, with "fail", the build fails.
+func checkLicensePolicyIfRequired(logger emitter, composerPackagesLayer *packit.Layer) error {
+	policy := strings.ToLower(os.Getenv(BpComposerLicensePolicy))
+	switch policy {
+	case "", LicensePolicyOff:
+		return nil
+	case LicensePolicyWarn, LicensePolicyFail:
+	default:
+		return fmt.Errorf("%s must be one of %q, %q or %q, found %q", BpComposerLicensePolicy, LicensePolicyOff, LicensePolicyWarn, LicensePolicyFail, policy)
+	}
+
+	allowed := strings.FieldsFunc(os.Getenv(BpComposerAllowedLicenses), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+	if len(allowed) == 0 {
+		return fmt.Errorf("%s must be set when %s is %q", BpComposerAllowedLicenses, BpComposerLicensePolicy, policy)
+	}
+
+	logger.Process("Checking licenses against the license policy (%s=%s)", BpComposerLicensePolicy, policy)
+	logger.Subprocess("Allowed licenses: %s", strings.Join(allowed, ", "))
+
+	licenses, found, err := readLicensesFromSBOM(composerPackagesLayer)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		if policy == LicensePolicyFail {
+			return fmt.Errorf("%s requires an SBOM in SPDX or CycloneDX JSON format", BpComposerLicensePolicy)
+		}
+
+		logger.Subprocess("WARNING: Skipping the license policy, there is no SBOM in SPDX or CycloneDX JSON format")
+		logger.Break()
+		return nil
+	}
+
+	var violations []string
+	for _, p := range licenses {
+		ok, err := LicenseAllowed(p.License, allowed)
+		if err != nil {
+			return fmt.Errorf("failed to check the license of %s: %w", p.Name, err)
+		}
+
+		if ok {
+			continue
+		}
+
+		license := p.License
+		if license == "" {
+			license = "unknown license"
+		}
+		violations = append(violations, fmt.Sprintf("%s %s (%s)", p.Name, p.Version, license))
+	}
+
+	if len(violations) == 0 {
+		logger.Subprocess("The licenses of all %d package(s) are allowed", len(licenses))
+		logger.Break()
+		return nil
+	}
+
+	for _, violation := range violations {
+		logger.Subprocess("- %s", violation)
+	}
+
+	if policy == LicensePolicyFail {
+		return fmt.Errorf("found %d package(s) violating the license policy: %s", len(violations), strings.Join(violations, ", "))
+	}
+
+	logger.Subprocess("WARNING: Found %d package(s) violating the license policy", len(violations))
+	logger.Break()
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package composer_test
+
+import (
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLicensePolicy(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("LicenseAllowed", func() {
+		allowed := []string{"MIT", "apache-2.0", "GPL-2.0-only WITH Classpath-exception-2.0"}
+
+		it("evaluates SPDX license expressions", func() {
+			for expression, expected := range map[string]bool{
+				"MIT":                                       true,
+				"mit":                                       true,
+				"GPL-3.0-only":                              false,
+				"MIT OR GPL-3.0-only":                       true,
+				"MIT AND GPL-3.0-only":                      false,
+				"MIT AND Apache-2.0":                        true,
+				"GPL-3.0-only OR MIT AND BSD-3-Clause":      false,
+				"(GPL-3.0-only OR MIT) AND Apache-2.0":      true,
+				"(GPL-3.0-only) OR (Proprietary)":           false,
+				"MIT WITH Some-exception":                   true,
+				"GPL-2.0-only WITH Classpath-exception-2.0": true,
+				"GPL-2.0-only WITH LLVM-exception":          false,
+			} {
+				result, err := composer.LicenseAllowed(expression, allowed)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).To(Equal(expected), expression)
+			}
+		})
+
+		it("does not allow unknown licenses", func() {
+			result, err := composer.LicenseAllowed(" ", allowed)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(BeFalse())
+		})
+
+		context("failure cases", func() {
+			it("returns an error for invalid expressions", func() {
+				for _, expression := range []string{"MIT OR", "(MIT", "MIT)", "AND MIT", "MIT WITH", "MIT Apache-2.0"} {
+					_, err := composer.LicenseAllowed(expression, allowed)
+					Expect(err).To(MatchError(ContainSubstring("invalid license expression %q", expression)))
+				}
+			})
+		})
+	})
+}
@@ -0,0 +1,208 @@
+package composer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+const (
+	// LicenseReportMarkdownFileName is the human-readable license report
+	LicenseReportMarkdownFileName = "licenses.md"
+
+	// LicenseReportJsonFileName is the machine-readable license report
+	LicenseReportJsonFileName = "licenses.json"
+)
+
+// LicenseReportPackage is a package listed in the license report.
+type LicenseReportPackage struct {
+	Name        string   `json:"name"`
+	Version     string   `json:"version"`
+	Licenses    []string `json:"licenses"`
+	Description string   `json:"description,omitempty"`
+	Homepage    string   `json:"homepage,omitempty"`
+}
+
+// ParseLicensesReport parses the output of `composer licenses --format=json`
+// and returns the installed packages sorted by name. Packages without a
+// license are listed with an empty list of licenses.
+// https://getcomposer.org/doc/03-cli.md#licenses
+func ParseLicensesReport(output []byte) ([]LicenseReportPackage, error) {
+	var report struct {
+		Dependencies map[string]struct {
+			Version     string   `json:"version"`
+			License     []string `json:"license"`
+			Description string   `json:"description"`
+			Homepage    string   `json:"homepage"`
+		} `json:"dependencies"`
+	}
+
+	err := json.Unmarshal(bytes.TrimPrefix(output, utf8BOM), &report)
+	if err != nil {
+		return nil, err
+	}
+
+	packages := []LicenseReportPackage{}
+	for name, dependency := range report.Dependencies {
+		licenses := dependency.License
+		if licenses == nil {
+			licenses = []string{}
+		}
+
+		packages = append(packages, LicenseReportPackage{
+			Name:        name,
+			Version:     dependency.Version,
+			Licenses:    licenses,
+			Description: dependency.Description,
+			Homepage:    dependency.Homepage,
+		})
+	}
+
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Name < packages[j].Name
+	})
+
+	return packages, nil
+}
+
+// RenderLicensesMarkdown renders the given packages as a Markdown table.
+func RenderLicensesMarkdown(packages []LicenseReportPackage) string {
+	escape := strings.NewReplacer("|", `\|`, "\n", " ").Replace
+
+	var builder strings.Builder
+	builder.WriteString("# Third-party licenses\n\n")
+	builder.WriteString("| Package | Version | License |\n")
+	builder.WriteString("| --- | --- | --- |\n")
+	for _, p := range packages {
+		license := strings.Join(p.Licenses, " OR ")
+		if license == "" {
+			license = "none"
+		}
+
+		name := escape(p.Name)
+		if p.Homepage != "" {
+			name = fmt.Sprintf("[%s](%s)", name, escape(p.Homepage))
+		}
+
+		fmt.Fprintf(&builder, "| %s | %s | %s |\n", name, escape(p.Version), escape(license))
+	}
+
+	return builder.String()
+}
+
+// writeLicenseReportIfRequired will check for env var
+// "BP_COMPOSER_LICENSE_REPORT". If set to true, `composer licenses` is run
+// after the install, and the licenses of the installed packages are written
+// as `licenses.md` and `licenses.json` into the composer-licenses launch
+// layer. If set to a relative path, the reports are written into that
+// directory of the application instead, e.g. to serve them. The dev packages
+// are left out if `composer install` ran with `--no-dev`. Returns whether the
+// layer is used.
+func writeLicenseReportIfRequired(
+	logger emitter,
+	context packit.BuildContext,
+	fileSystem FileSystem,
+	composerLicensesExec Executable,
+	composerJsonPath string,
+	composerHome string,
+	workspaceVendorDir string,
+	composerPhpIniPath string,
+	path string,
+	installOptions []InstallOption) (packit.Layer, bool, error) {
+	value := strings.TrimSpace(os.Getenv(BpComposerLicenseReport))
+	if value == "" {
+		return packit.Layer{}, false, nil
+	}
+
+	var reportDir string
+	if enabled, err := strconv.ParseBool(value); err == nil {
+		if !enabled {
+			return packit.Layer{}, false, nil
+		}
+	} else {
+		reportDir = filepath.Join(context.WorkingDir, filepath.Clean(value))
+		relativeDir, err := filepath.Rel(context.WorkingDir, reportDir)
+		if filepath.IsAbs(value) || err != nil || relativeDir == ".." || strings.HasPrefix(relativeDir, ".."+string(filepath.Separator)) {
+			return packit.Layer{}, false, fmt.Errorf("%s must be true, false or a relative path within the application, found %q", BpComposerLicenseReport, value)
+		}
+	}
+
+	args := []string{"licenses", "--format=json", "--no-ansi"}
+	if devPackagesExcluded(installOptions) {
+		args = append(args, "--no-dev")
+	}
+	logger.Process("Running 'composer %s'", strings.Join(args, " "))
+
+	stdout := bytes.NewBuffer(nil)
+	err := composerLicensesExec.Execute(pexec.Execution{
+		Args: args,
+		Dir:  context.WorkingDir,
+		Env: append(os.Environ(),
+			"COMPOSER_NO_INTERACTION=1", // https://getcomposer.org/doc/03-cli.md#composer-no-interaction
+			fmt.Sprintf("COMPOSER=%s", composerJsonPath),
+			fmt.Sprintf("COMPOSER_HOME=%s", composerHome),
+			fmt.Sprintf("COMPOSER_VENDOR_DIR=%s", workspaceVendorDir),
+			fmt.Sprintf("PHPRC=%s", composerPhpIniPath),
+			fmt.Sprintf("PATH=%s", path),
+		),
+		Stdout: stdout,
+		Stderr: logger.ActionWriter,
+	})
+	if err != nil {
+		return packit.Layer{}, false, fmt.Errorf("failed to write the license report: %w", err)
+	}
+
+	packages, err := ParseLicensesReport(stdout.Bytes())
+	if err != nil {
+		return packit.Layer{}, false, fmt.Errorf("failed to parse the output of 'composer licenses': %w", err)
+	}
+
+	var licensesLayer packit.Layer
+	if reportDir == "" {
+		licensesLayer, err = context.Layers.Get(ComposerLicensesLayerName)
+		if err != nil { // untested
+			return packit.Layer{}, false, err
+		}
+
+		licensesLayer, err = licensesLayer.Reset()
+		if err != nil { // untested
+			return packit.Layer{}, false, err
+		}
+
+		licensesLayer.Launch = true
+		reportDir = licensesLayer.Path
+	}
+
+	err = fileSystem.MkdirAll(reportDir, 0755)
+	if err != nil {
+		return packit.Layer{}, false, err
+	}
+
+	content, err := json.MarshalIndent(map[string]interface{}{"packages": packages}, "", "  ")
+	if err != nil { // untested
+		return packit.Layer{}, false, err
+	}
+
+	err = writeGeneratedFile(fileSystem, filepath.Join(reportDir, LicenseReportJsonFileName), append(content, '\n'))
+	if err != nil {
+		return packit.Layer{}, false, err
+	}
+
+	err = writeGeneratedFile(fileSystem, filepath.Join(reportDir, LicenseReportMarkdownFileName), []byte(RenderLicensesMarkdown(packages)))
+	if err != nil {
+		return packit.Layer{}, false, err
+	}
+
+	logger.Subprocess("Wrote the licenses of %d package(s) to %s", len(packages), reportDir)
+	logger.Break()
+
+	return licensesLayer, licensesLayer.Path != "", nil
+}
@@ -0,0 +1,56 @@
+package composer_test
+
+import (
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLicenseReport(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("ParseLicensesReport", func() {
+		it("returns the installed packages sorted by name", func() {
+			packages, err := composer.ParseLicensesReport([]byte(`{
+	"name": "some/app",
+	"license": ["proprietary"],
+	"dependencies": {
+		"some/package": {"version": "1.2.0", "license": ["MIT"], "description": "Some package", "homepage": "https://example.com"},
+		"other/package": {"version": "2.0.0", "license": []}
+	}
+}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(packages).To(Equal([]composer.LicenseReportPackage{
+				{Name: "other/package", Version: "2.0.0", Licenses: []string{}},
+				{Name: "some/package", Version: "1.2.0", Licenses: []string{"MIT"}, Description: "Some package", Homepage: "https://example.com"},
+			}))
+		})
+
+		context("when the output is not valid JSON", func() {
+			it("returns an error", func() {
+				_, err := composer.ParseLicensesReport([]byte(`Name: some/app`))
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	context("RenderLicensesMarkdown", func() {
+		it("renders the packages as a table", func() {
+			Expect(composer.RenderLicensesMarkdown([]composer.LicenseReportPackage{
+				{Name: "other/package", Version: "2.0.0", Licenses: []string{}},
+				{Name: "some/package", Version: "1.2.0", Licenses: []string{"GPL-2.0-only", "GPL-3.0-only"}, Homepage: "https://example.com"},
+				{Name: "odd/package", Version: "1.0.0", Licenses: []string{"MIT|Apache-2.0"}},
+			})).To(Equal(`# Third-party licenses
+
+| Package | Version | License |
+| --- | --- | --- |
+| other/package | 2.0.0 | none |
+| [some/package](https://example.com) | 1.2.0 | GPL-2.0-only OR GPL-3.0-only |
+| odd/package | 1.0.0 | MIT\|Apache-2.0 |
+`))
+		})
+	})
+}
@@ -0,0 +1,148 @@
+package composer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+const (
+	// LockSnapshotFileName is the name of the normalized `composer.lock` in
+	// the composer-packages layer.
+	LockSnapshotFileName = "composer.lock.snapshot"
+
+	// LockSnapshotDigestLabel is the image label holding the digest of the
+	// normalized `composer.lock`.
+	LockSnapshotDigestLabel = "ch.nine.composer-install.lock-snapshot-digest"
+)
+
+// lockSnapshotIgnoredKeys are the keys of `composer.lock` which do not
+// describe the installed dependencies, but when and by which version of
+// Composer the lock file has been written.
+var lockSnapshotIgnoredKeys = []string{"_readme", "content-hash", "plugin-api-version"}
+
+// NormalizeComposerLock returns the dependency set of the given
+// `composer.lock` in a stable format: the packages are sorted by name, their
+// release time and the keys which do not describe the dependencies are
+// removed, and all objects are written with sorted keys. Two lock files
+// resolving the same dependencies result in the same snapshot, regardless of
+// their formatting.
+func NormalizeComposerLock(content []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(bytes.TrimPrefix(content, utf8BOM)))
+	decoder.UseNumber()
+
+	var lock map[string]interface{}
+	err := decoder.Decode(&lock)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range lockSnapshotIgnoredKeys {
+		delete(lock, key)
+	}
+
+	for _, key := range []string{"packages", "packages-dev"} {
+		packages, ok := lock[key].([]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, p := range packages {
+			if p, ok := p.(map[string]interface{}); ok {
+				delete(p, "time")
+			}
+		}
+
+		sort.SliceStable(packages, func(i, j int) bool {
+			return packageName(packages[i]) < packageName(packages[j])
+		})
+	}
+
+	buf := bytes.NewBuffer(nil)
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	// maps are encoded with sorted keys
+	err = encoder.Encode(lock)
+	if err != nil { // untested
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func packageName(p interface{}) string {
+	if p, ok := p.(map[string]interface{}); ok {
+		name, _ := p["name"].(string)
+		return name
+	}
+	return ""
+}
+
+// writeLockSnapshotIfRequired will check for env var
+// "BP_COMPOSER_LOCK_SNAPSHOT". If set to true, the normalized
+// `composer.lock` is written into the composer-packages layer, and its
+// digest is returned as image label, so that images can be compared for
+// drift of their dependencies without inspecting their layers.
+func writeLockSnapshotIfRequired(logger emitter, composerLockPath string, composerPackagesLayer *packit.Layer) (map[string]string, error) {
+	snapshotPath := filepath.Join(composerPackagesLayer.Path, LockSnapshotFileName)
+
+	// a cached layer may contain the snapshot of a previous build
+	err := os.RemoveAll(snapshotPath)
+	if err != nil { // untested
+		return nil, err
+	}
+
+	enabled, err := lookupBoolEnv(BpComposerLockSnapshot, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if !enabled {
+		return nil, nil
+	}
+
+	content, err := readComposerFile(composerLockPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			logger.Process("Skipping %s as no %s was found", LockSnapshotFileName, filepath.Base(composerLockPath))
+			logger.Break()
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	snapshot, err := NormalizeComposerLock(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize %s: %w", composerLockPath, err)
+	}
+
+	err = os.WriteFile(snapshotPath, snapshot, 0644)
+	if err != nil { // untested
+		return nil, err
+	}
+
+	sum := sha256.Sum256(snapshot)
+	digest := fmt.Sprintf("sha256:%s", hex.EncodeToString(sum[:]))
+
+	if composerPackagesLayer.Metadata == nil {
+		composerPackagesLayer.Metadata = map[string]interface{}{}
+	}
+	composerPackagesLayer.Metadata["lock-snapshot-digest"] = digest
+
+	logger.Process("Writing %s", LockSnapshotFileName)
+	logger.Subprocess("Digest: %s", digest)
+	logger.Subprocess("Label: %s", LockSnapshotDigestLabel)
+	logger.Break()
+
+	return map[string]string{LockSnapshotDigestLabel: digest}, nil
+}
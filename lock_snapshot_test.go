@@ -0,0 +1,74 @@
+package composer_test
+
+import (
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLockSnapshot(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("NormalizeComposerLock", func() {
+		it("sorts the packages and removes the keys which do not describe the dependencies", func() {
+			snapshot, err := composer.NormalizeComposerLock([]byte(`{
+    "_readme": ["This file locks the dependencies of your project to a known state"],
+    "content-hash": "some-content-hash",
+    "packages": [
+        {"name": "vendor/b", "version": "2.0.0", "time": "2023-01-01T00:00:00+00:00", "dist": {"url": "https://example.com/b.zip?a=1&b=2"}},
+        {"name": "vendor/a", "version": "1.0.0", "time": "2023-01-02T00:00:00+00:00"}
+    ],
+    "packages-dev": [],
+    "prefer-stable": false,
+    "plugin-api-version": "2.6.0"
+}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(snapshot)).To(Equal(`{
+  "packages": [
+    {
+      "name": "vendor/a",
+      "version": "1.0.0"
+    },
+    {
+      "dist": {
+        "url": "https://example.com/b.zip?a=1&b=2"
+      },
+      "name": "vendor/b",
+      "version": "2.0.0"
+    }
+  ],
+  "packages-dev": [],
+  "prefer-stable": false
+}
+`))
+		})
+
+		it("results in the same snapshot regardless of the formatting", func() {
+			first, err := composer.NormalizeComposerLock([]byte(`{"packages": [{"name": "vendor/a", "version": "1.0.0", "time": "2023-01-01"}, {"name": "vendor/b", "version": "2.0.0"}], "content-hash": "first"}`))
+			Expect(err).NotTo(HaveOccurred())
+
+			second, err := composer.NormalizeComposerLock([]byte(`{
+	"content-hash": "second",
+	"packages": [
+		{"version": "2.0.0", "name": "vendor/b"},
+		{"version": "1.0.0", "name": "vendor/a", "time": "2024-01-01"}
+	]
+}`))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(string(first)).To(Equal(string(second)))
+		})
+
+		context("failure cases", func() {
+			context("when the lock file is not valid JSON", func() {
+				it("returns an error", func() {
+					_, err := composer.NormalizeComposerLock([]byte(`%%%`))
+					Expect(err).To(MatchError(ContainSubstring("invalid character")))
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,323 @@
+package composer
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// Logger receives the log output of the build, so that embedders can route
+// it to their own systems, and tests can assert on the logged calls rather
+// than on the formatted output. Each call receives the format and arguments
+// of a message as they are. The levels match the indentation levels of
+// scribe.Emitter. Debug receives the debug output of all levels, which is
+// only logged if BP_LOG_LEVEL is DEBUG.
+//
+//go:generate faux --interface Logger --output fakes/logger.go
+type Logger interface {
+	Title(format string, v ...interface{})
+	Process(format string, v ...interface{})
+	Subprocess(format string, v ...interface{})
+	Action(format string, v ...interface{})
+	Detail(format string, v ...interface{})
+	Debug(format string, v ...interface{})
+	Break()
+}
+
+// NewEmitterLogger returns a Logger which logs to the given scribe.Emitter,
+// as the buildpack does by default.
+func NewEmitterLogger(emitter scribe.Emitter) Logger {
+	return emitterLogger{emitter: emitter}
+}
+
+type emitterLogger struct {
+	emitter scribe.Emitter
+}
+
+func (l emitterLogger) Title(format string, v ...interface{}) {
+	l.emitter.Title(format, v...)
+}
+
+func (l emitterLogger) Process(format string, v ...interface{}) {
+	l.emitter.Process(format, v...)
+}
+
+func (l emitterLogger) Subprocess(format string, v ...interface{}) {
+	l.emitter.Subprocess(format, v...)
+}
+
+func (l emitterLogger) Action(format string, v ...interface{}) {
+	l.emitter.Action(format, v...)
+}
+
+func (l emitterLogger) Detail(format string, v ...interface{}) {
+	l.emitter.Detail(format, v...)
+}
+
+func (l emitterLogger) Debug(format string, v ...interface{}) {
+	l.emitter.Debug.Process(format, v...)
+}
+
+func (l emitterLogger) Break() {
+	l.emitter.Break()
+}
+
+// emitter is what the steps of the build log to. It has the methods of
+// scribe.Emitter the steps use, and passes the format and arguments of each
+// call on to the Logger of the build, so that structured loggers receive
+// them. The output of commands, and the output packit formats itself, e.g.
+// the launch processes, are logged line by line.
+type emitter struct {
+	logger Logger
+
+	// Debug logs to Logger.Debug, only if BP_LOG_LEVEL is DEBUG
+	Debug debugEmitter
+
+	// ActionWriter logs each line of the output of commands with
+	// Logger.Action
+	ActionWriter io.Writer
+
+	// rendered formats the output of packit, e.g. the launch processes
+	rendered scribe.Emitter
+
+	lineWriters []*lineWriter
+}
+
+// newEmitter returns an emitter which logs to the given Logger. The
+// scribe.Emitter of a Logger returned by NewEmitterLogger is logged to as
+// is, so that the default output is unchanged.
+func newEmitter(logger Logger) emitter {
+	if l, ok := logger.(emitterLogger); ok {
+		return emitter{
+			logger:       l,
+			Debug:        debugEmitter{leveled: &l.emitter.Debug},
+			ActionWriter: l.emitter.ActionWriter,
+			rendered:     l.emitter,
+		}
+	}
+
+	e := emitter{logger: logger}
+	writer := func(log func(format string, v ...interface{})) io.Writer {
+		w := &lineWriter{log: log, blank: logger.Break}
+		e.lineWriters = append(e.lineWriters, w)
+		return w
+	}
+
+	// as scribe.Logger.WithLevel, the debug output is only logged at the
+	// DEBUG level
+	var debug io.Writer = io.Discard
+	if strings.ToUpper(os.Getenv(BpLogLevel)) == "DEBUG" {
+		e.Debug = debugEmitter{logger: logger}
+		debug = writer(logger.Debug)
+	}
+
+	e.ActionWriter = writer(logger.Action)
+	e.rendered = scribe.Emitter{
+		Logger: scribe.Logger{
+			LeveledLogger: scribe.LeveledLogger{
+				TitleWriter:      writer(logger.Title),
+				ProcessWriter:    writer(logger.Process),
+				SubprocessWriter: writer(logger.Subprocess),
+				ActionWriter:     e.ActionWriter,
+				DetailWriter:     writer(logger.Detail),
+				SubdetailWriter:  writer(logger.Detail),
+			},
+			Debug: scribe.LeveledLogger{
+				TitleWriter:      debug,
+				ProcessWriter:    debug,
+				SubprocessWriter: debug,
+				ActionWriter:     debug,
+				DetailWriter:     debug,
+				SubdetailWriter:  debug,
+			},
+		},
+	}
+
+	return e
+}
+
+func (e emitter) Title(format string, v ...interface{}) {
+	e.logger.Title(format, v...)
+}
+
+func (e emitter) Process(format string, v ...interface{}) {
+	e.logger.Process(format, v...)
+}
+
+func (e emitter) Subprocess(format string, v ...interface{}) {
+	e.logger.Subprocess(format, v...)
+}
+
+func (e emitter) Action(format string, v ...interface{}) {
+	e.logger.Action(format, v...)
+}
+
+func (e emitter) Detail(format string, v ...interface{}) {
+	e.logger.Detail(format, v...)
+}
+
+func (e emitter) Break() {
+	e.logger.Break()
+}
+
+func (e emitter) LaunchProcesses(processes []packit.Process, processEnvs ...map[string]packit.Environment) {
+	e.rendered.LaunchProcesses(processes, processEnvs...)
+}
+
+func (e emitter) EnvironmentVariables(layer packit.Layer) {
+	e.rendered.EnvironmentVariables(layer)
+}
+
+func (e emitter) GeneratingSBOM(path string) {
+	e.rendered.GeneratingSBOM(path)
+}
+
+func (e emitter) FormattingSBOM(formats ...string) {
+	e.rendered.FormattingSBOM(formats...)
+}
+
+// contextLogger is the Logger passed to hooks and install strategies. As
+// for the steps of the build, its debug output is only logged if
+// BP_LOG_LEVEL is DEBUG.
+type contextLogger struct {
+	emitter emitter
+}
+
+func (l contextLogger) Title(format string, v ...interface{}) {
+	l.emitter.Title(format, v...)
+}
+
+func (l contextLogger) Process(format string, v ...interface{}) {
+	l.emitter.Process(format, v...)
+}
+
+func (l contextLogger) Subprocess(format string, v ...interface{}) {
+	l.emitter.Subprocess(format, v...)
+}
+
+func (l contextLogger) Action(format string, v ...interface{}) {
+	l.emitter.Action(format, v...)
+}
+
+func (l contextLogger) Detail(format string, v ...interface{}) {
+	l.emitter.Detail(format, v...)
+}
+
+func (l contextLogger) Debug(format string, v ...interface{}) {
+	l.emitter.Debug.Process(format, v...)
+}
+
+func (l contextLogger) Break() {
+	l.emitter.Break()
+}
+
+// flush logs the output written without a trailing newline, e.g. the last
+// line of a command, which is otherwise held back until the line is
+// complete.
+func (e emitter) flush() {
+	for _, w := range e.lineWriters {
+		w.flush()
+	}
+}
+
+// debugEmitter logs the debug output of the build.
+type debugEmitter struct {
+	// leveled is the debug logger of a Logger returned by NewEmitterLogger,
+	// which keeps the indentation of each level
+	leveled *scribe.LeveledLogger
+
+	// logger is nil unless BP_LOG_LEVEL is DEBUG
+	logger Logger
+}
+
+func (d debugEmitter) Process(format string, v ...interface{}) {
+	if d.leveled != nil {
+		d.leveled.Process(format, v...)
+	} else if d.logger != nil {
+		d.logger.Debug(format, v...)
+	}
+}
+
+func (d debugEmitter) Subprocess(format string, v ...interface{}) {
+	if d.leveled != nil {
+		d.leveled.Subprocess(format, v...)
+	} else if d.logger != nil {
+		d.logger.Debug(format, v...)
+	}
+}
+
+func (d debugEmitter) Action(format string, v ...interface{}) {
+	if d.leveled != nil {
+		d.leveled.Action(format, v...)
+	} else if d.logger != nil {
+		d.logger.Debug(format, v...)
+	}
+}
+
+func (d debugEmitter) Detail(format string, v ...interface{}) {
+	if d.leveled != nil {
+		d.leveled.Detail(format, v...)
+	} else if d.logger != nil {
+		d.logger.Debug(format, v...)
+	}
+}
+
+func (d debugEmitter) Break() {
+	if d.leveled != nil {
+		d.leveled.Break()
+	} else if d.logger != nil {
+		d.logger.Break()
+	}
+}
+
+// lineWriter logs each complete line written to it. The output of commands,
+// such as `composer install`, is written in chunks, which do not end with
+// complete lines, so an incomplete line is held back until it is complete or
+// flushed.
+type lineWriter struct {
+	mutex sync.Mutex
+	log   func(format string, v ...interface{})
+	blank func()
+	line  bytes.Buffer
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.line.Write(p)
+	for {
+		i := bytes.IndexByte(w.line.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+
+		w.logLine(string(w.line.Next(i + 1)))
+	}
+
+	return len(p), nil
+}
+
+func (w *lineWriter) flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.line.Len() > 0 {
+		w.logLine(w.line.String())
+		w.line.Reset()
+	}
+}
+
+func (w *lineWriter) logLine(line string) {
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		w.blank()
+		return
+	}
+	w.log("%s", line)
+}
@@ -0,0 +1,98 @@
+package composer
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+const magentoLayerDir = "magento"
+
+// MagentoPackageNames are the root packages of Magento 2 projects, as created
+// with `composer create-project`.
+var MagentoPackageNames = []string{
+	"magento/product-community-edition",
+	"magento/product-enterprise-edition",
+}
+
+// magentoGeneratedPaths are the directories written by
+// `bin/magento setup:di:compile` and `bin/magento setup:static-content:deploy`,
+// relative to the Magento root.
+var magentoGeneratedPaths = []string{
+	"generated",
+	filepath.Join("pub", "static"),
+}
+
+// magentoRoot returns the directory of the Magento project relative to the
+// working directory, i.e. the directory of its `composer.json`, or false if
+// `composer.lock` does not contain any of MagentoPackageNames.
+func magentoRoot(workingDir, composerJsonPath string) (string, bool, error) {
+	locked, err := readLockedPackageNames(composerJsonPath)
+	if err != nil {
+		return "", false, err
+	}
+
+	found := false
+	for _, name := range MagentoPackageNames {
+		found = found || locked[name]
+	}
+
+	if !found {
+		return "", false, nil
+	}
+
+	root, err := filepath.Rel(workingDir, filepath.Dir(composerJsonPath))
+	if err != nil || strings.HasPrefix(root, "..") { // untested
+		return "", false, err
+	}
+
+	return root, true, nil
+}
+
+// FindMagentoPaths returns the generated code and the static content of a
+// Magento 2 project, i.e. `generated` and `pub/static`. Compiling them takes
+// several minutes, so they are cached alongside the vendor directory if they
+// have been created during `composer install`, e.g. by the scripts of
+// `composer.json`.
+//
+// Returns the paths relative to the working directory.
+func FindMagentoPaths(workingDir, composerJsonPath, _ string) ([]string, error) {
+	root, found, err := magentoRoot(workingDir, composerJsonPath)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	var paths []string
+	for _, path := range magentoGeneratedPaths {
+		path = filepath.Join(root, path)
+		if exists, err := fs.Exists(filepath.Join(workingDir, path)); err != nil {
+			return nil, err
+		} else if !exists {
+			continue
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// FindMagentoChecksumInputs returns `app/etc/config.php` of a Magento 2
+// project, as the enabled modules listed in it determine the generated code
+// and the static content.
+func FindMagentoChecksumInputs(workingDir, composerJsonPath string) ([]string, error) {
+	root, found, err := magentoRoot(workingDir, composerJsonPath)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	configPath := filepath.Join(workingDir, root, "app", "etc", "config.php")
+	if exists, err := fs.Exists(configPath); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, nil
+	}
+
+	return []string{configPath}, nil
+}
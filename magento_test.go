@@ -0,0 +1,108 @@
+package composer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testMagento(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir       string
+		composerJsonPath string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		composerJsonPath = filepath.Join(workingDir, "composer.json")
+		Expect(os.WriteFile(composerJsonPath, []byte(`{}`), os.ModePerm)).To(Succeed())
+
+		Expect(os.MkdirAll(filepath.Join(workingDir, "generated", "code"), os.ModePerm)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(workingDir, "app", "etc"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(workingDir, "app", "etc", "config.php"), []byte(`<?php return [];`), os.ModePerm)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("when composer.lock contains Magento", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+	"packages": [
+		{
+			"name": "magento/product-community-edition"
+		}
+	]
+}`), os.ModePerm)).To(Succeed())
+		})
+
+		it("returns the existing generated paths", func() {
+			paths, err := composer.FindMagentoPaths(workingDir, composerJsonPath, filepath.Join(workingDir, "vendor"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(Equal([]string{"generated"}))
+
+			Expect(os.MkdirAll(filepath.Join(workingDir, "pub", "static", "frontend"), os.ModePerm)).To(Succeed())
+
+			paths, err = composer.FindMagentoPaths(workingDir, composerJsonPath, filepath.Join(workingDir, "vendor"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(Equal([]string{"generated", filepath.Join("pub", "static")}))
+		})
+
+		it("returns app/etc/config.php as checksum input", func() {
+			inputs, err := composer.FindMagentoChecksumInputs(workingDir, composerJsonPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inputs).To(Equal([]string{filepath.Join(workingDir, "app", "etc", "config.php")}))
+		})
+
+		context("when composer.json is in a subdirectory", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "shop", "generated"), os.ModePerm)).To(Succeed())
+				Expect(os.Rename(filepath.Join(workingDir, "composer.lock"), filepath.Join(workingDir, "shop", "composer.lock"))).To(Succeed())
+				composerJsonPath = filepath.Join(workingDir, "shop", "composer.json")
+			})
+
+			it("returns the paths within that directory", func() {
+				paths, err := composer.FindMagentoPaths(workingDir, composerJsonPath, filepath.Join(workingDir, "vendor"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(paths).To(Equal([]string{filepath.Join("shop", "generated")}))
+
+				inputs, err := composer.FindMagentoChecksumInputs(workingDir, composerJsonPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(inputs).To(BeEmpty())
+			})
+		})
+	})
+
+	context("when composer.lock does not contain Magento", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+	"packages": [
+		{
+			"name": "drupal/core"
+		}
+	]
+}`), os.ModePerm)).To(Succeed())
+		})
+
+		it("returns no paths", func() {
+			paths, err := composer.FindMagentoPaths(workingDir, composerJsonPath, filepath.Join(workingDir, "vendor"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(BeEmpty())
+
+			inputs, err := composer.FindMagentoChecksumInputs(workingDir, composerJsonPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inputs).To(BeEmpty())
+		})
+	})
+}
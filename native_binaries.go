@@ -0,0 +1,202 @@
+package composer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// nativeBinariesMetadataKey is the key of the composer-packages layer
+	// metadata, which lists the packages containing native binaries, see
+	// findNativeBinaries. Layers cached before it was recorded have not been
+	// scanned yet.
+	nativeBinariesMetadataKey = "native-binaries"
+
+	// nativeTargetMetadataKey is the key of the composer-packages layer
+	// metadata, which records the build target the native binaries have been
+	// installed for, see BuildTarget.String.
+	nativeTargetMetadataKey = "native-target"
+)
+
+// nativeBinaryMagics are the leading bytes of compiled artifacts: ELF, and
+// the 32 and 64 bit Mach-O in both byte orders.
+var nativeBinaryMagics = [][]byte{
+	{0x7f, 'E', 'L', 'F'},
+	{0xfe, 0xed, 0xfa, 0xce},
+	{0xfe, 0xed, 0xfa, 0xcf},
+	{0xce, 0xfa, 0xed, 0xfe},
+	{0xcf, 0xfa, 0xed, 0xfe},
+}
+
+// nativeBinaryNames matches the names of shared libraries, including
+// versioned ones such as `libffi.so.8`, which are detected even if their
+// contents are not recognized, e.g. for the libraries of other platforms.
+var nativeBinaryNames = regexp.MustCompile(`\.(so(\.[0-9]+)*|dylib|dll)$`)
+
+// findNativeBinaries returns the packages of the given vendor directory,
+// which contain compiled artifacts, sorted by name, e.g. the bundled
+// libraries of FFI bindings or prebuilt executables. Files outside of a
+// package, e.g. in `vendor/bin`, are reported by their directory.
+func findNativeBinaries(vendorDir string) ([]string, error) {
+	found := map[string]bool{}
+
+	err := filepath.WalkDir(vendorDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && path == vendorDir {
+				return fs.SkipDir
+			}
+			return err
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(vendorDir, path)
+		if err != nil { // untested
+			return err
+		}
+
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if len(parts) < 2 {
+			return nil
+		}
+
+		name := parts[0]
+		if len(parts) > 2 {
+			name = strings.Join(parts[:2], "/")
+		}
+
+		if found[name] {
+			return nil
+		}
+
+		native, err := isNativeBinary(path)
+		if err != nil {
+			return err
+		}
+
+		if native {
+			found[name] = true
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s for native binaries: %w", vendorDir, err)
+	}
+
+	packages := []string{}
+	for name := range found {
+		packages = append(packages, name)
+	}
+	sort.Strings(packages)
+
+	return packages, nil
+}
+
+// isNativeBinary returns whether the given file is a compiled artifact,
+// either by its name or by its leading bytes.
+func isNativeBinary(path string) (bool, error) {
+	if nativeBinaryNames.MatchString(filepath.Base(path)) {
+		return true, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	magic := make([]byte, 4)
+	_, err = io.ReadFull(file, magic)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return false, nil
+	} else if err != nil { // untested
+		return false, err
+	}
+
+	for _, candidate := range nativeBinaryMagics {
+		if bytes.Equal(magic, candidate) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// recordNativeBinaries records the given packages containing native binaries
+// in the given layer metadata, together with the given build target, which
+// is only recorded if there are any.
+func recordNativeBinaries(metadata map[string]interface{}, packages []string, target BuildTarget) {
+	metadata[nativeBinariesMetadataKey] = packages
+
+	if len(packages) == 0 {
+		delete(metadata, nativeTargetMetadataKey)
+		return
+	}
+
+	metadata[nativeTargetMetadataKey] = target.String()
+}
+
+// nativeBinariesStale returns whether the cached packages of the given layer
+// metadata contain native binaries, which have been installed for another
+// build target than the given one. This includes changes of the OS, the
+// architecture variant and the distribution, even if they are not part of
+// the layer inputs, e.g. as the platform does not provide the distribution.
+//
+// Layers cached before the native binaries were recorded are scanned, and
+// considered stale if they contain any, as the target they have been
+// installed for is unknown. Otherwise the result of the scan is recorded in
+// the given metadata, so that the layer is only scanned once.
+func nativeBinariesStale(logger emitter, metadata map[string]interface{}, layerVendorDir string, target BuildTarget) (bool, error) {
+	var packages []string
+	switch value := metadata[nativeBinariesMetadataKey].(type) {
+	case []interface{}:
+		for _, name := range value {
+			if name, ok := name.(string); ok {
+				packages = append(packages, name)
+			}
+		}
+	case []string:
+		packages = value
+	default:
+		found, err := findNativeBinaries(layerVendorDir)
+		if err != nil {
+			return false, err
+		}
+
+		if len(found) == 0 {
+			recordNativeBinaries(metadata, found, target)
+			return false, nil
+		}
+
+		logger.Process("Rebuilding the layer for %s, as the cached packages contain native binaries installed for an unknown target: %s", target, strings.Join(found, ", "))
+		return true, nil
+	}
+
+	if len(packages) == 0 {
+		return false, nil
+	}
+
+	cachedTarget, _ := metadata[nativeTargetMetadataKey].(string)
+	if cachedTarget == "" {
+		cachedTarget = "an unknown target"
+	}
+	if cachedTarget == target.String() {
+		logger.Debug.Process("Cached packages contain native binaries installed for %s: %s", cachedTarget, strings.Join(packages, ", "))
+		return false, nil
+	}
+
+	logger.Process("Rebuilding the layer for %s, as the cached packages contain native binaries installed for %s: %s", target, cachedTarget, strings.Join(packages, ", "))
+
+	return true, nil
+}
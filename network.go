@@ -0,0 +1,115 @@
+package composer
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+const (
+	// composerMaxParallelHttp is the environment variable read by Composer
+	// to limit the number of parallel downloads
+	// https://getcomposer.org/doc/03-cli.md#composer-max-parallel-http
+	composerMaxParallelHttp = "COMPOSER_MAX_PARALLEL_HTTP"
+
+	// composerDefaultMaxParallelHttp is Composer's own default
+	composerDefaultMaxParallelHttp = 12
+)
+
+// networkSettings configures how Composer downloads packages.
+type networkSettings struct {
+	// env is added to the environment of all `composer` executions
+	env []string
+
+	// disableHTTP2 disables Composer's curl downloader, which is the only one
+	// using HTTP/2
+	disableHTTP2 bool
+}
+
+// determineNetworkSettings will check for env vars "BP_COMPOSER_MAX_PARALLEL_HTTP"
+// and "BP_COMPOSER_DISABLE_HTTP2".
+//
+// The number of parallel downloads is taken from BP_COMPOSER_MAX_PARALLEL_HTTP,
+// or COMPOSER_MAX_PARALLEL_HTTP if set. Otherwise it defaults to the number of
+// the build profile, or to 4 per CPU, capped at Composer's default of 12, so
+// that builders with few CPUs are not overwhelmed.
+func determineNetworkSettings(logger emitter, profile buildProfile) (networkSettings, error) {
+	var settings networkSettings
+
+	maxParallelHttp := defaultMaxParallelHttp(runtime.NumCPU())
+	if profile.maxParallelHttp > 0 {
+		maxParallelHttp = profile.maxParallelHttp
+	}
+
+	if value, found := os.LookupEnv(BpComposerMaxParallelHttp); found {
+		parsed, err := strconv.Atoi(value)
+		if err == nil && parsed < 1 {
+			err = fmt.Errorf("must be a positive integer")
+		}
+		if err != nil {
+			return networkSettings{}, fmt.Errorf("error when parsing env var %q: %w", BpComposerMaxParallelHttp, err)
+		}
+		maxParallelHttp = parsed
+	} else if _, found := os.LookupEnv(composerMaxParallelHttp); found {
+		// already part of the environment of each execution
+		maxParallelHttp = 0
+	}
+
+	if maxParallelHttp > 0 {
+		settings.env = append(settings.env, fmt.Sprintf("%s=%d", composerMaxParallelHttp, maxParallelHttp))
+
+		if maxParallelHttp < composerDefaultMaxParallelHttp {
+			logger.Process("Limiting Composer to %d parallel download(s)", maxParallelHttp)
+			logger.Subprocess("This reduces the load on proxies and the builder, but downloads may take longer than with Composer's default of %d", composerDefaultMaxParallelHttp)
+			logger.Break()
+		} else if maxParallelHttp > composerDefaultMaxParallelHttp {
+			logger.Process("Allowing Composer %d parallel download(s)", maxParallelHttp)
+			logger.Break()
+		}
+	}
+
+	disableHTTP2, err := lookupBoolEnv(BpComposerDisableHTTP2, false)
+	if err != nil {
+		return networkSettings{}, err
+	}
+
+	if disableHTTP2 {
+		settings.disableHTTP2 = true
+
+		logger.Process("Disabling HTTP/2 for Composer downloads")
+		logger.Subprocess("Packages are downloaded one at a time over HTTP/1.1, which is slower, especially for projects with many dependencies")
+		logger.Break()
+	}
+
+	return settings, nil
+}
+
+func defaultMaxParallelHttp(cpus int) int {
+	maxParallelHttp := 4 * cpus
+	if maxParallelHttp > composerDefaultMaxParallelHttp {
+		return composerDefaultMaxParallelHttp
+	}
+	return maxParallelHttp
+}
+
+// withEnv returns an Executable which adds the given environment variables to
+// each execution before delegating to the given Executable.
+func withEnv(executable Executable, env ...string) Executable {
+	return envExecutable{
+		executable: executable,
+		env:        env,
+	}
+}
+
+type envExecutable struct {
+	executable Executable
+	env        []string
+}
+
+func (e envExecutable) Execute(execution pexec.Execution) error {
+	execution.Env = append(execution.Env, e.env...)
+	return e.executable.Execute(execution)
+}
@@ -0,0 +1,188 @@
+package composer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+// requireNodeAuto is the value of "BP_COMPOSER_REQUIRE_NODE" which only
+// requires "node" if the scripts of `composer install` build assets.
+const requireNodeAuto = "auto"
+
+// nodeCommands matches the commands of scripts which need Node.js.
+var nodeCommands = regexp.MustCompile(`(^|[\s;&|(])(npm|npx|yarn|pnpm|node)(\s|$)`)
+
+// composerScriptCommands are the commands of scripts starting with "@", which
+// are not references to other scripts.
+// https://getcomposer.org/doc/articles/scripts.md#referencing-scripts
+var composerScriptCommands = map[string]bool{
+	"@php":      true,
+	"@composer": true,
+	"@putenv":   true,
+}
+
+// nodeRequirement is how "node" is required by the build plan.
+type nodeRequirement int
+
+const (
+	nodeNotRequired nodeRequirement = iota
+
+	// nodeRequired fails the detection if no buildpack provides "node"
+	nodeRequired
+
+	// nodeRequiredIfProvided falls back to a build plan without "node" if no
+	// buildpack provides it
+	nodeRequiredIfProvided
+)
+
+// determineNodeRequirement will check for env var "BP_COMPOSER_REQUIRE_NODE",
+// which defaults to "auto". If set to true, "node" is required, so that it is
+// on the PATH of the scripts run by `composer install`. If set to "auto", it
+// is only required if the application has a `package.json`, and the scripts of
+// `composer install` run npm, npx, yarn, pnpm or node, see
+// FindNodeScripts. As buildpack groups without Node.js must still detect, the
+// build plan then falls back to one without "node". The decision is logged.
+func determineNodeRequirement(logger emitter, workingDir, composerJsonPath string) (nodeRequirement, error) {
+	value, found := os.LookupEnv(BpComposerRequireNode)
+	if !found || value == "" {
+		value = requireNodeAuto
+	}
+
+	if !strings.EqualFold(value, requireNodeAuto) {
+		required, err := lookupBoolEnv(BpComposerRequireNode, false)
+		if err != nil {
+			return nodeNotRequired, err
+		}
+
+		if !required {
+			return nodeNotRequired, nil
+		}
+
+		logger.Process("Requiring %q for the scripts of 'composer install' (%s=%s)", NodeDependency, BpComposerRequireNode, value)
+		logger.Break()
+
+		return nodeRequired, nil
+	}
+
+	if exists, err := fs.Exists(filepath.Join(workingDir, "package.json")); err != nil {
+		return nodeNotRequired, err
+	} else if !exists {
+		return nodeNotRequired, nil
+	}
+
+	scripts, err := FindNodeScripts(composerJsonPath)
+	if err != nil {
+		return nodeNotRequired, err
+	}
+
+	if len(scripts) == 0 {
+		return nodeNotRequired, nil
+	}
+
+	logger.Process("Requiring %q if available, for %d script(s) of 'composer install' (%s=%s)", NodeDependency, len(scripts), BpComposerRequireNode, requireNodeAuto)
+	for _, script := range scripts {
+		logger.Subprocess("- script %s", script)
+	}
+	logger.Break()
+
+	return nodeRequiredIfProvided, nil
+}
+
+// FindNodeScripts returns the names of the scripts of the given
+// `composer.json`, which run as part of `composer install`, see
+// installTimeScriptEvents, and run npm, npx, yarn, pnpm or node, in the
+// order of the events. Scripts referenced as "@name" are followed.
+func FindNodeScripts(composerJsonPath string) ([]string, error) {
+	var composerJson struct {
+		Scripts map[string]json.RawMessage `json:"scripts"`
+	}
+
+	content, err := readComposerFile(composerJsonPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	err = json.Unmarshal(content, &composerJson)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", composerJsonPath, err)
+	}
+
+	// a script is either a single command or a list of them
+	commands := func(name string) []string {
+		var command string
+		if json.Unmarshal(composerJson.Scripts[name], &command) == nil {
+			return []string{command}
+		}
+
+		var list []string
+		_ = json.Unmarshal(composerJson.Scripts[name], &list)
+		return list
+	}
+
+	visited := map[string]bool{}
+	found := map[string]bool{}
+
+	var visit func(name string) bool
+	visit = func(name string) bool {
+		if visited[name] {
+			return found[name]
+		}
+		visited[name] = true
+
+		for _, command := range commands(name) {
+			command = strings.TrimSpace(command)
+			if fields := strings.Fields(command); len(fields) > 0 && strings.HasPrefix(fields[0], "@") && !composerScriptCommands[fields[0]] {
+				if visit(strings.TrimPrefix(fields[0], "@")) {
+					found[name] = true
+				}
+			} else if nodeCommands.MatchString(command) {
+				found[name] = true
+			}
+		}
+
+		return found[name]
+	}
+
+	var scripts []string
+	for _, event := range installTimeScriptEvents {
+		if _, ok := composerJson.Scripts[event]; ok && visit(event) {
+			scripts = append(scripts, event)
+		}
+	}
+
+	return scripts, nil
+}
+
+// withNodeRequirement returns the given build plan with the given
+// requirement of "node".
+func withNodeRequirement(plan packit.BuildPlan, requirement nodeRequirement) packit.BuildPlan {
+	if requirement == nodeNotRequired {
+		return plan
+	}
+
+	fallback := plan
+
+	plan.Requires = append(append([]packit.BuildPlanRequirement{}, plan.Requires...), packit.BuildPlanRequirement{
+		Name: NodeDependency,
+		Metadata: BuildPlanMetadata{
+			Build: true,
+		},
+	})
+
+	if requirement == nodeRequiredIfProvided {
+		plan.Or = append(plan.Or, fallback)
+	}
+
+	return plan
+}
@@ -0,0 +1,168 @@
+package composer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+// OutdatedPackage is a direct dependency for which a newer version is
+// available, as reported by `composer outdated`.
+type OutdatedPackage struct {
+	Name         string `json:"name"`
+	Version      string `json:"version"`
+	Latest       string `json:"latest"`
+	LatestStatus string `json:"latest-status"`
+}
+
+// MajorVersionsBehind returns by how many major versions the installed
+// version is behind the latest version, or 0 if either of them is not a
+// tagged version, such as "dev-main".
+func (p OutdatedPackage) MajorVersionsBehind() int {
+	installed, ok := majorVersion(p.Version)
+	if !ok {
+		return 0
+	}
+
+	latest, ok := majorVersion(p.Latest)
+	if !ok || latest < installed {
+		return 0
+	}
+
+	return latest - installed
+}
+
+func majorVersion(version string) (int, bool) {
+	major, _, _ := strings.Cut(strings.TrimPrefix(version, "v"), ".")
+	value, err := strconv.Atoi(major)
+	return value, err == nil
+}
+
+// ParseOutdatedReport parses the output of `composer outdated --format=json`
+// and returns the packages which are not up to date.
+// https://getcomposer.org/doc/03-cli.md#outdated
+func ParseOutdatedReport(output []byte) ([]OutdatedPackage, error) {
+	var report struct {
+		Installed []OutdatedPackage `json:"installed"`
+	}
+
+	err := json.Unmarshal(output, &report)
+	if err != nil {
+		return nil, err
+	}
+
+	var outdated []OutdatedPackage
+	for _, p := range report.Installed {
+		if p.LatestStatus == "up-to-date" || p.Version == p.Latest {
+			continue
+		}
+		outdated = append(outdated, p)
+	}
+
+	return outdated, nil
+}
+
+// reportOutdatedPackagesIfRequired will check for env var
+// "BP_COMPOSER_OUTDATED_REPORT". If set to true, `composer outdated --direct`
+// is run after the install, and the outdated direct dependencies are logged
+// and recorded in the metadata of the composer-packages layer:
+//   - outdated-direct: the number of outdated direct dependencies
+//   - outdated-major: the number of those which are a major version behind
+//   - outdated: the name, installed and latest version of each of them
+//
+// As `composer outdated` needs to reach the repositories, a failure is
+// logged, but does not fail the build.
+func reportOutdatedPackagesIfRequired(
+	logger emitter,
+	composerOutdatedExec Executable,
+	workingDir string,
+	composerJsonPath string,
+	composerHome string,
+	workspaceVendorDir string,
+	composerPhpIniPath string,
+	path string,
+	composerPackagesLayer *packit.Layer) error {
+	enabled, err := lookupBoolEnv(BpComposerOutdatedReport, false)
+	if err != nil {
+		return err
+	}
+
+	// a cached layer may contain the report of a previous build
+	for _, key := range []string{"outdated-direct", "outdated-major", "outdated"} {
+		delete(composerPackagesLayer.Metadata, key)
+	}
+
+	if !enabled {
+		return nil
+	}
+
+	args := []string{"outdated", "--direct", "--format=json", "--no-ansi"}
+	logger.Process("Running 'composer %s'", strings.Join(args, " "))
+
+	stdout := bytes.NewBuffer(nil)
+	execution := pexec.Execution{
+		Args: args,
+		Dir:  workingDir,
+		Env: append(os.Environ(),
+			"COMPOSER_NO_INTERACTION=1", // https://getcomposer.org/doc/03-cli.md#composer-no-interaction
+			fmt.Sprintf("COMPOSER=%s", composerJsonPath),
+			fmt.Sprintf("COMPOSER_HOME=%s", composerHome),
+			fmt.Sprintf("COMPOSER_VENDOR_DIR=%s", workspaceVendorDir),
+			fmt.Sprintf("PHPRC=%s", composerPhpIniPath),
+			fmt.Sprintf("PATH=%s", path),
+		),
+		Stdout: stdout,
+		Stderr: logger.ActionWriter,
+	}
+
+	err = composerOutdatedExec.Execute(execution)
+	if err != nil {
+		logger.Subprocess("Skipping the outdated report, 'composer outdated' failed: %s", err)
+		logger.Break()
+		return nil
+	}
+
+	outdated, err := ParseOutdatedReport(stdout.Bytes())
+	if err != nil {
+		logger.Subprocess("Skipping the outdated report, failed to parse the output of 'composer outdated': %s", err)
+		logger.Break()
+		return nil
+	}
+
+	major := 0
+	var packages []map[string]interface{}
+	for _, p := range outdated {
+		if p.MajorVersionsBehind() > 0 {
+			major++
+		}
+
+		packages = append(packages, map[string]interface{}{
+			"name":    p.Name,
+			"version": p.Version,
+			"latest":  p.Latest,
+		})
+	}
+
+	logger.Subprocess("%d direct package(s) outdated, %d of them a major version behind", len(outdated), major)
+	for _, p := range outdated {
+		logger.Action("%s %s -> %s", p.Name, p.Version, p.Latest)
+	}
+	logger.Break()
+
+	if composerPackagesLayer.Metadata == nil {
+		composerPackagesLayer.Metadata = map[string]interface{}{}
+	}
+	composerPackagesLayer.Metadata["outdated-direct"] = len(outdated)
+	composerPackagesLayer.Metadata["outdated-major"] = major
+	if len(packages) > 0 {
+		composerPackagesLayer.Metadata["outdated"] = packages
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package composer_test
+
+import (
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testOutdated(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("ParseOutdatedReport", func() {
+		it("returns the packages which are not up to date", func() {
+			outdated, err := composer.ParseOutdatedReport([]byte(`{
+	"installed": [
+		{"name": "some/package", "version": "1.2.0", "latest": "3.0.0", "latest-status": "update-possible"},
+		{"name": "some/current-package", "version": "1.0.0", "latest": "1.0.0", "latest-status": "up-to-date"}
+	]
+}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(outdated).To(Equal([]composer.OutdatedPackage{
+				{Name: "some/package", Version: "1.2.0", Latest: "3.0.0", LatestStatus: "update-possible"},
+			}))
+		})
+
+		context("when the output is not valid JSON", func() {
+			it("returns an error", func() {
+				_, err := composer.ParseOutdatedReport([]byte(`No dependencies`))
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	context("MajorVersionsBehind", func() {
+		it("returns the difference of the major versions", func() {
+			for _, example := range []struct {
+				version string
+				latest  string
+				behind  int
+			}{
+				{"1.2.0", "3.0.0", 2},
+				{"v2.0.0", "v2.1.0", 0},
+				{"2.0.0", "1.0.0", 0},
+				{"dev-main", "1.0.0", 0},
+				{"1.0.0", "dev-main 1234abc", 0},
+			} {
+				p := composer.OutdatedPackage{Version: example.version, Latest: example.latest}
+				Expect(p.MajorVersionsBehind()).To(Equal(example.behind), example.version+" -> "+example.latest)
+			}
+		})
+	})
+}
@@ -0,0 +1,118 @@
+package composer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+const (
+	// composerFund is the environment variable read by Composer to hide the
+	// funding notices with a value of 0
+	// https://getcomposer.org/doc/03-cli.md#composer-fund
+	composerFund = "COMPOSER_FUND"
+
+	// composerNoAudit is the environment variable read by Composer to skip
+	// the security audit of `composer install` with a value of 1
+	// https://getcomposer.org/doc/03-cli.md#composer-no-audit
+	composerNoAudit = "COMPOSER_NO_AUDIT"
+
+	// noColor disables the ANSI output of Composer, like `--no-ansi`
+	// https://no-color.org/
+	noColor = "NO_COLOR"
+)
+
+// outputVerbosity configures how much output Composer writes into the build
+// log.
+type outputVerbosity struct {
+	// env is added to the environment of all `composer` executions
+	env []string
+
+	// quiet holds back the output of `composer install` and `composer
+	// global`, unless they fail
+	quiet bool
+}
+
+// determineOutputVerbosity will check for env vars "BP_COMPOSER_FUND",
+// "BP_COMPOSER_AUDIT" and "BP_LOG_LEVEL".
+//
+// The funding notices are hidden, unless BP_COMPOSER_FUND is set to true, and
+// the security audit is skipped if BP_COMPOSER_AUDIT is set to false. Values
+// of COMPOSER_FUND and COMPOSER_NO_AUDIT set by the user are kept. The ANSI
+// escape sequences are always disabled, as they clutter the build log.
+//
+// Unless BP_LOG_LEVEL is set to DEBUG, the output of the commands installing
+// packages is only written if they fail, so that the build log stays concise
+// while errors are still shown in full.
+func determineOutputVerbosity(logger emitter) (outputVerbosity, error) {
+	var verbosity outputVerbosity
+
+	fund, err := lookupBoolEnv(BpComposerFund, false)
+	if err != nil {
+		return outputVerbosity{}, err
+	}
+
+	if _, found := os.LookupEnv(composerFund); !found && !fund {
+		verbosity.env = append(verbosity.env, fmt.Sprintf("%s=0", composerFund))
+	}
+
+	audit, err := lookupBoolEnv(BpComposerAudit, true)
+	if err != nil {
+		return outputVerbosity{}, err
+	}
+
+	if _, found := os.LookupEnv(composerNoAudit); !found && !audit {
+		verbosity.env = append(verbosity.env, fmt.Sprintf("%s=1", composerNoAudit))
+
+		logger.Process("Skipping the security audit of 'composer install'")
+		logger.Break()
+	}
+
+	if _, found := os.LookupEnv(noColor); !found {
+		verbosity.env = append(verbosity.env, fmt.Sprintf("%s=1", noColor))
+	}
+
+	verbosity.quiet = os.Getenv(BpLogLevel) != "DEBUG"
+
+	return verbosity, nil
+}
+
+// withQuietOutput returns an Executable which holds back the output of each
+// execution and only writes it if the execution fails, or the given Executable
+// itself if the output is not quiet.
+func withQuietOutput(verbosity outputVerbosity, executable Executable) Executable {
+	if !verbosity.quiet {
+		return executable
+	}
+
+	return quietExecutable{
+		executable: executable,
+	}
+}
+
+type quietExecutable struct {
+	executable Executable
+}
+
+func (e quietExecutable) Execute(execution pexec.Execution) error {
+	output := bytes.NewBuffer(nil)
+
+	quietExecution := execution
+	quietExecution.Stdout = output
+	quietExecution.Stderr = output
+
+	// the warnings of Composer are recorded, although the output is held back
+	if recorder, ok := execution.Stderr.(warningsWriter); ok {
+		quietExecution.Stderr = io.MultiWriter(output, recorder.warnings)
+	}
+
+	err := e.executable.Execute(quietExecution)
+	if err != nil {
+		_, _ = io.Copy(writerOrDiscard(execution.Stderr), output)
+	}
+
+	return err
+}
@@ -0,0 +1,259 @@
+package composer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// PackagePolicyFileName is the name of the file in the application root,
+// which lists packages denied or allowed in `composer.lock`.
+const PackagePolicyFileName = ".composer-package-policy"
+
+// PackageRule matches packages by name and optionally by version, e.g.
+// "monolog/monolog", "acme/*" or "symfony/http-kernel@4.*".
+type PackageRule struct {
+	Name    string
+	Version string
+}
+
+// ParsePackageRule parses a rule of the form `<name>[@<version>]`. Both parts
+// use the syntax of path.Match, e.g. "acme/*@1.*".
+func ParsePackageRule(value string) (PackageRule, error) {
+	name, version, _ := strings.Cut(value, "@")
+	rule := PackageRule{Name: strings.ToLower(name), Version: version}
+
+	if rule.Name == "" {
+		return PackageRule{}, fmt.Errorf("invalid package rule %q: the package name is missing", value)
+	}
+
+	if _, err := path.Match(rule.Name, ""); err != nil {
+		return PackageRule{}, fmt.Errorf("invalid package rule %q: %w", value, err)
+	}
+
+	if _, err := path.Match(rule.Version, ""); err != nil {
+		return PackageRule{}, fmt.Errorf("invalid package rule %q: %w", value, err)
+	}
+
+	return rule, nil
+}
+
+// Matches returns whether the rule matches the given package. A version is
+// matched with and without its "v" prefix, e.g. "1.*" matches "v1.2.0".
+func (r PackageRule) Matches(name, version string) bool {
+	if matched, _ := path.Match(r.Name, strings.ToLower(name)); !matched {
+		return false
+	}
+
+	if r.Version == "" {
+		return true
+	}
+
+	for _, candidate := range []string{version, strings.TrimPrefix(version, "v")} {
+		if matched, _ := path.Match(r.Version, candidate); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r PackageRule) String() string {
+	if r.Version == "" {
+		return r.Name
+	}
+	return fmt.Sprintf("%s@%s", r.Name, r.Version)
+}
+
+// PackagePolicy denies packages in `composer.lock`. A package violates the
+// policy if it matches any of the denied rules, or if there are allowed rules
+// and it matches none of them.
+type PackagePolicy struct {
+	Denied  []PackageRule
+	Allowed []PackageRule
+}
+
+// PolicyViolation is a package in `composer.lock` which violates the policy.
+type PolicyViolation struct {
+	Name    string
+	Version string
+
+	// DeniedBy is the rule which denies the package, or nil if the package is
+	// not allowed by any rule
+	DeniedBy *PackageRule
+}
+
+func (v PolicyViolation) String() string {
+	if v.DeniedBy == nil {
+		return fmt.Sprintf("%s %s (not allowed)", v.Name, v.Version)
+	}
+	return fmt.Sprintf("%s %s (denied by %s)", v.Name, v.Version, v.DeniedBy)
+}
+
+// LoadPackagePolicy reads the policy from the env vars
+// "BP_COMPOSER_PACKAGE_DENYLIST" and "BP_COMPOSER_PACKAGE_ALLOWLIST", which
+// are lists of rules separated by whitespace, and from
+// `.composer-package-policy` in the given working directory. Each line of the
+// file is either `deny <rule>` or `allow <rule>`. Empty lines and lines
+// starting with `#` are skipped.
+func LoadPackagePolicy(workingDir string) (PackagePolicy, error) {
+	var policy PackagePolicy
+
+	for _, env := range []struct {
+		name  string
+		rules *[]PackageRule
+	}{
+		{name: BpComposerPackageDenylist, rules: &policy.Denied},
+		{name: BpComposerPackageAllowlist, rules: &policy.Allowed},
+	} {
+		for _, value := range strings.Fields(os.Getenv(env.name)) {
+			rule, err := ParsePackageRule(value)
+			if err != nil {
+				return PackagePolicy{}, fmt.Errorf("failed to parse %s: %w", env.name, err)
+			}
+			*env.rules = append(*env.rules, rule)
+		}
+	}
+
+	file, err := os.Open(filepath.Join(workingDir, PackagePolicyFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return policy, nil
+		}
+		return PackagePolicy{}, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 || (fields[0] != "deny" && fields[0] != "allow") {
+			return PackagePolicy{}, fmt.Errorf("invalid line %q in %s: expected \"deny <package>\" or \"allow <package>\"", line, PackagePolicyFileName)
+		}
+
+		rule, err := ParsePackageRule(fields[1])
+		if err != nil {
+			return PackagePolicy{}, fmt.Errorf("failed to parse %s: %w", PackagePolicyFileName, err)
+		}
+
+		if fields[0] == "deny" {
+			policy.Denied = append(policy.Denied, rule)
+		} else {
+			policy.Allowed = append(policy.Allowed, rule)
+		}
+	}
+
+	if err := scanner.Err(); err != nil { // untested
+		return PackagePolicy{}, err
+	}
+
+	return policy, nil
+}
+
+// Empty returns whether the policy has no rules.
+func (p PackagePolicy) Empty() bool {
+	return len(p.Denied) == 0 && len(p.Allowed) == 0
+}
+
+// Check returns the packages in `composer.lock` which violate the policy.
+// Returns no violations if `composer.lock` does not exist.
+func (p PackagePolicy) Check(composerLockPath string) ([]PolicyViolation, error) {
+	content, err := readComposerFile(composerLockPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	type lockedPackage struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+
+	var composerLock struct {
+		Packages    []lockedPackage `json:"packages"`
+		PackagesDev []lockedPackage `json:"packages-dev"`
+	}
+
+	err = json.Unmarshal(content, &composerLock)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []PolicyViolation
+	for _, pkg := range append(composerLock.Packages, composerLock.PackagesDev...) {
+		violation := PolicyViolation{Name: pkg.Name, Version: pkg.Version}
+
+		denied := false
+		for i, rule := range p.Denied {
+			if rule.Matches(pkg.Name, pkg.Version) {
+				violation.DeniedBy = &p.Denied[i]
+				denied = true
+				break
+			}
+		}
+
+		if !denied && len(p.Allowed) > 0 {
+			denied = true
+			for _, rule := range p.Allowed {
+				if rule.Matches(pkg.Name, pkg.Version) {
+					denied = false
+					break
+				}
+			}
+		}
+
+		if denied {
+			violations = append(violations, violation)
+		}
+	}
+
+	return violations, nil
+}
+
+// checkPackagePolicy will fail the build if `composer.lock` contains packages
+// which violate the policy of LoadPackagePolicy. It runs before
+// `composer install`, so that no denied package is downloaded.
+func checkPackagePolicy(logger emitter, workingDir, composerLockPath string) error {
+	policy, err := LoadPackagePolicy(workingDir)
+	if err != nil {
+		return err
+	}
+
+	if policy.Empty() {
+		return nil
+	}
+
+	logger.Process("Checking packages against the package policy")
+	logger.Subprocess("%d denied and %d allowed rule(s)", len(policy.Denied), len(policy.Allowed))
+
+	violations, err := policy.Check(composerLockPath)
+	if err != nil {
+		return err
+	}
+
+	if len(violations) == 0 {
+		logger.Subprocess("No package violates the policy")
+		logger.Break()
+		return nil
+	}
+
+	var details []string
+	for _, violation := range violations {
+		logger.Subprocess("- %s", violation)
+		details = append(details, violation.String())
+	}
+
+	return fmt.Errorf("found %d package(s) violating the package policy: %s", len(violations), strings.Join(details, ", "))
+}
@@ -0,0 +1,160 @@
+package composer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPackagePolicy(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir       string
+		composerLockPath string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		composerLockPath = filepath.Join(workingDir, "composer.lock")
+		Expect(os.WriteFile(composerLockPath, []byte(`{
+    "packages": [
+        {"name": "acme/billing", "version": "2.1.0"},
+        {"name": "monolog/monolog", "version": "1.27.1"},
+        {"name": "symfony/console", "version": "v6.4.1"}
+    ],
+    "packages-dev": [
+        {"name": "phpunit/phpunit", "version": "10.5.0"}
+    ]
+}`), os.ModePerm)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.Unsetenv("BP_COMPOSER_PACKAGE_DENYLIST")).To(Succeed())
+		Expect(os.Unsetenv("BP_COMPOSER_PACKAGE_ALLOWLIST")).To(Succeed())
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("ParsePackageRule", func() {
+		it("parses the name and the version", func() {
+			rule, err := composer.ParsePackageRule("Monolog/*@1.*")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rule).To(Equal(composer.PackageRule{Name: "monolog/*", Version: "1.*"}))
+			Expect(rule.String()).To(Equal("monolog/*@1.*"))
+
+			Expect(rule.Matches("monolog/monolog", "1.27.1")).To(BeTrue())
+			Expect(rule.Matches("monolog/monolog", "v1.27.1")).To(BeTrue())
+			Expect(rule.Matches("monolog/monolog", "2.0.0")).To(BeFalse())
+			Expect(rule.Matches("acme/monolog", "1.0.0")).To(BeFalse())
+		})
+
+		context("failure cases", func() {
+			it("returns an error for a rule without name", func() {
+				_, err := composer.ParsePackageRule("@1.0.0")
+				Expect(err).To(MatchError(`invalid package rule "@1.0.0": the package name is missing`))
+			})
+
+			it("returns an error for an invalid pattern", func() {
+				_, err := composer.ParsePackageRule("acme/[")
+				Expect(err).To(MatchError(ContainSubstring(`invalid package rule "acme/["`)))
+			})
+		})
+	})
+
+	context("LoadPackagePolicy", func() {
+		it("reads the rules of the env vars and the policy file", func() {
+			Expect(os.Setenv("BP_COMPOSER_PACKAGE_DENYLIST", "monolog/monolog@1.*  acme/legacy")).To(Succeed())
+			Expect(os.Setenv("BP_COMPOSER_PACKAGE_ALLOWLIST", "acme/*")).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, ".composer-package-policy"), []byte(`
+# reviewed by legal
+allow symfony/*
+deny  phpunit/phpunit@9.*
+`), os.ModePerm)).To(Succeed())
+
+			policy, err := composer.LoadPackagePolicy(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy).To(Equal(composer.PackagePolicy{
+				Denied: []composer.PackageRule{
+					{Name: "monolog/monolog", Version: "1.*"},
+					{Name: "acme/legacy"},
+					{Name: "phpunit/phpunit", Version: "9.*"},
+				},
+				Allowed: []composer.PackageRule{
+					{Name: "acme/*"},
+					{Name: "symfony/*"},
+				},
+			}))
+		})
+
+		context("failure cases", func() {
+			context("when an env var contains an invalid rule", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_COMPOSER_PACKAGE_ALLOWLIST", "acme/[")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := composer.LoadPackagePolicy(workingDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse BP_COMPOSER_PACKAGE_ALLOWLIST")))
+				})
+			})
+
+			context("when the policy file contains an invalid line", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, ".composer-package-policy"), []byte("block acme/legacy\n"), os.ModePerm)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := composer.LoadPackagePolicy(workingDir)
+					Expect(err).To(MatchError(`invalid line "block acme/legacy" in .composer-package-policy: expected "deny <package>" or "allow <package>"`))
+				})
+			})
+		})
+	})
+
+	context("Check", func() {
+		it("returns the denied packages and those which are not allowed", func() {
+			policy := composer.PackagePolicy{
+				Denied: []composer.PackageRule{
+					{Name: "monolog/monolog", Version: "1.*"},
+				},
+				Allowed: []composer.PackageRule{
+					{Name: "acme/*"},
+					{Name: "monolog/*"},
+					{Name: "symfony/*"},
+				},
+			}
+
+			violations, err := policy.Check(composerLockPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(violations).To(HaveLen(2))
+			Expect(violations[0].String()).To(Equal("monolog/monolog 1.27.1 (denied by monolog/monolog@1.*)"))
+			Expect(violations[1].String()).To(Equal("phpunit/phpunit 10.5.0 (not allowed)"))
+		})
+
+		it("allows all packages without allowed rules", func() {
+			policy := composer.PackagePolicy{
+				Denied: []composer.PackageRule{{Name: "acme/legacy"}},
+			}
+
+			violations, err := policy.Check(composerLockPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(violations).To(BeEmpty())
+		})
+
+		context("when composer.lock does not exist", func() {
+			it("returns no violations", func() {
+				violations, err := composer.PackagePolicy{Allowed: []composer.PackageRule{{Name: "acme/*"}}}.Check(filepath.Join(workingDir, "missing.lock"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(violations).To(BeEmpty())
+			})
+		})
+	})
+}
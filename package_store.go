@@ -0,0 +1,325 @@
+package composer
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+const (
+	// packageStoreEntryFile is the file of a stored package, which holds its
+	// entry of `installed.json`
+	packageStoreEntryFile = "package.json"
+
+	// packageStoreFilesDir is the directory of a stored package, which holds
+	// its extracted files
+	packageStoreFilesDir = "files"
+)
+
+// preparePackageStoreLayerIfRequired will check for env var
+// "BP_COMPOSER_PACKAGE_STORE". If set to true, the composer-package-store
+// layer is provided as the PackageStore, which is cached independently of
+// `composer.lock`, so that a changed `composer.lock` only downloads and
+// extracts the changed packages. Returns whether the layer is used.
+//
+// The packages may contain native binaries, so the stored packages are
+// removed once the stack or the build target changes.
+func preparePackageStoreLayerIfRequired(logger emitter, context packit.BuildContext) (packit.Layer, bool, error) {
+	enabled, err := lookupBoolEnv(BpComposerPackageStore, false)
+	if err != nil || !enabled {
+		return packit.Layer{}, false, err
+	}
+
+	packageStoreLayer, err := context.Layers.Get(ComposerPackageStoreLayerName)
+	if err != nil { // untested
+		return packit.Layer{}, false, err
+	}
+
+	target := LookupBuildTarget().String()
+	if packageStoreLayer.Metadata["stack"] != context.Stack || packageStoreLayer.Metadata["target"] != target {
+		packageStoreLayer, err = packageStoreLayer.Reset()
+		if err != nil { // untested
+			return packit.Layer{}, false, err
+		}
+	}
+
+	err = os.MkdirAll(packageStoreLayer.Path, 0755)
+	if err != nil { // untested
+		return packit.Layer{}, false, err
+	}
+
+	packageStoreLayer.Launch, packageStoreLayer.Build, packageStoreLayer.Cache = false, false, true
+	packageStoreLayer.Metadata = map[string]interface{}{
+		"stack":  context.Stack,
+		"target": target,
+	}
+
+	entries, err := os.ReadDir(packageStoreLayer.Path)
+	if err != nil { // untested
+		return packit.Layer{}, false, err
+	}
+
+	logger.Process("Storing the installed packages in %s", packageStoreLayer.Path)
+	logger.Subprocess("Restored %d stored packages", len(entries))
+	logger.Break()
+
+	return packageStoreLayer, true, nil
+}
+
+// PackageStore is a content-addressed store of the extracted packages, each
+// stored in a directory named by its key, see packageStoreKey. The vendor
+// directory is assembled from the store before `composer install`, which
+// then only downloads and extracts the packages missing from the store.
+type PackageStore struct {
+	Dir string
+}
+
+// NewPackageStore returns the PackageStore in the given directory.
+func NewPackageStore(dir string) PackageStore {
+	return PackageStore{Dir: dir}
+}
+
+// storablePackage is the subset of a package of `composer.lock` or
+// `installed.json`, which determines whether and where it is stored.
+type storablePackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	Dist    struct {
+		Type      string `json:"type"`
+		Reference string `json:"reference"`
+		Shasum    string `json:"shasum"`
+	} `json:"dist"`
+
+	// InstallationSource and InstallPath are only written into
+	// `installed.json`
+	InstallationSource string `json:"installation-source"`
+	InstallPath        string `json:"install-path"`
+}
+
+// packageStoreKey returns the key of the given package in the store, the
+// SHA-256 of its name, version and the hash of its dist, i.e. its shasum or,
+// as most repositories leave it empty, its reference. Returns an empty key
+// for packages which cannot be stored: metapackages, packages installed from
+// a local path, and packages without a dist hash, whose contents may change
+// without a change of `composer.lock`.
+func packageStoreKey(p storablePackage) string {
+	if p.Name == "" || p.Type == "metapackage" || p.Dist.Type == "" || p.Dist.Type == "path" {
+		return ""
+	}
+
+	hash := p.Dist.Shasum
+	if hash == "" {
+		hash = p.Dist.Reference
+	}
+
+	if hash == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s", p.Name, p.Version, hash))))
+}
+
+// Assemble copies the packages of the given `composer.lock`, which are in
+// the store, into the given vendor directory, and lists them in its
+// `installed.json`, so that `composer install` considers them installed.
+// Nothing is assembled into an existing vendor directory. Returns the number
+// of assembled packages.
+func (s PackageStore) Assemble(logger Logger, composerLockPath, vendorDir string) (int, error) {
+	return s.assemble(newEmitter(logger), composerLockPath, vendorDir)
+}
+
+func (s PackageStore) assemble(logger emitter, composerLockPath, vendorDir string) (int, error) {
+	if exists, err := fs.Exists(vendorDir); err != nil {
+		return 0, err
+	} else if exists {
+		logger.Debug.Process("Not assembling the packages from the package store, as %s exists", vendorDir)
+		return 0, nil
+	}
+
+	content, err := readComposerFile(composerLockPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var composerLock struct {
+		Packages    []storablePackage `json:"packages"`
+		PackagesDev []storablePackage `json:"packages-dev"`
+	}
+
+	err = json.Unmarshal(content, &composerLock)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", composerLockPath, err)
+	}
+
+	locked := append(composerLock.Packages, composerLock.PackagesDev...)
+
+	// the copies of the packages are not logged one by one
+	quiet := newEmitter(NewEmitterLogger(scribe.NewEmitter(io.Discard)))
+
+	entries := []json.RawMessage{}
+	for _, p := range locked {
+		key := packageStoreKey(p)
+		if key == "" {
+			continue
+		}
+
+		entry, err := os.ReadFile(filepath.Join(s.Dir, key, packageStoreEntryFile))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return 0, err
+		}
+
+		err = copyTree(quiet, filepath.Join(s.Dir, key, packageStoreFilesDir), filepath.Join(vendorDir, filepath.FromSlash(p.Name)))
+		if err != nil {
+			return 0, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	logger.Process("Assembled %d of %d locked packages from the package store", len(entries), len(locked))
+	logger.Break()
+
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	installedJson, err := json.MarshalIndent(map[string]interface{}{
+		"packages":          entries,
+		"dev":               true,
+		"dev-package-names": []string{},
+	}, "", "    ")
+	if err != nil { // untested
+		return 0, err
+	}
+
+	err = os.MkdirAll(filepath.Join(vendorDir, "composer"), 0755)
+	if err != nil { // untested
+		return 0, err
+	}
+
+	err = os.WriteFile(filepath.Join(vendorDir, "composer", "installed.json"), append(installedJson, '\n'), 0644)
+	if err != nil { // untested
+		return 0, err
+	}
+
+	return len(entries), nil
+}
+
+// Update stores the packages installed into the given vendor directory,
+// which are not in the store yet, and removes the stored packages which are
+// no longer installed, so that the store holds the packages of the last
+// `composer.lock` only. Packages installed from source, or by installers into
+// other directories than the vendor directory, are not stored.
+func (s PackageStore) Update(logger Logger, vendorDir string) error {
+	return s.update(newEmitter(logger), vendorDir)
+}
+
+func (s PackageStore) update(logger emitter, vendorDir string) error {
+	content, err := os.ReadFile(filepath.Join(vendorDir, "composer", "installed.json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	// Composer 1 writes the packages as a list without their install path,
+	// so they are not stored
+	var installedJson struct {
+		Packages []json.RawMessage `json:"packages"`
+	}
+
+	if json.Unmarshal(content, &installedJson) != nil {
+		logger.Debug.Process("Not updating the package store, as the installed packages have no install path")
+		return nil
+	}
+
+	quiet := newEmitter(NewEmitterLogger(scribe.NewEmitter(io.Discard)))
+
+	installed := map[string]bool{}
+	added := 0
+	for _, entry := range installedJson.Packages {
+		var p storablePackage
+		err = json.Unmarshal(entry, &p)
+		if err != nil {
+			return fmt.Errorf("failed to parse the installed packages: %w", err)
+		}
+
+		key := packageStoreKey(p)
+		if key == "" || p.InstallationSource != "dist" || p.InstallPath != "../"+p.Name {
+			continue
+		}
+
+		installed[key] = true
+
+		storedDir := filepath.Join(s.Dir, key)
+		if exists, err := fs.Exists(storedDir); err != nil {
+			return err
+		} else if exists {
+			continue
+		}
+
+		// the package is staged, so that a failed copy does not leave an
+		// incomplete package in the store
+		stagingDir := storedDir + ".tmp"
+		err = os.RemoveAll(stagingDir)
+		if err != nil { // untested
+			return err
+		}
+
+		err = copyTree(quiet, filepath.Join(vendorDir, filepath.FromSlash(p.Name)), filepath.Join(stagingDir, packageStoreFilesDir))
+		if err != nil {
+			return err
+		}
+
+		err = os.WriteFile(filepath.Join(stagingDir, packageStoreEntryFile), entry, 0644)
+		if err != nil { // untested
+			return err
+		}
+
+		err = os.Rename(stagingDir, storedDir)
+		if err != nil { // untested
+			return err
+		}
+
+		added++
+	}
+
+	stored, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return err
+	}
+
+	removed := 0
+	for _, dir := range stored {
+		if installed[dir.Name()] {
+			continue
+		}
+
+		err = os.RemoveAll(filepath.Join(s.Dir, dir.Name()))
+		if err != nil { // untested
+			return err
+		}
+		removed++
+	}
+
+	logger.Process("Updated the package store: %d stored, %d added, %d removed", len(installed), added, removed)
+	logger.Break()
+
+	return nil
+}
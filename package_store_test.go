@@ -0,0 +1,191 @@
+package composer_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPackageStore(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+		storeDir   string
+		vendorDir  string
+		buffer     *bytes.Buffer
+		logger     composer.Logger
+		store      composer.PackageStore
+	)
+
+	installedJson := `{
+    "packages": [
+        {
+            "name": "some/package",
+            "version": "1.0.0",
+            "dist": {"type": "zip", "reference": "abc123", "shasum": ""},
+            "installation-source": "dist",
+            "install-path": "../some/package"
+        },
+        {
+            "name": "some/installer-package",
+            "version": "1.0.0",
+            "dist": {"type": "zip", "reference": "def456", "shasum": ""},
+            "installation-source": "dist",
+            "install-path": "../../web/modules/installer-package"
+        },
+        {
+            "name": "some/source-package",
+            "version": "1.0.0",
+            "dist": {"type": "zip", "reference": "789abc", "shasum": ""},
+            "installation-source": "source",
+            "install-path": "../some/source-package"
+        },
+        {
+            "name": "some/local-package",
+            "version": "dev-main",
+            "dist": {"type": "path", "url": "packages/local-package", "reference": "0123ab"},
+            "installation-source": "dist",
+            "install-path": "../some/local-package"
+        }
+    ],
+    "dev": true,
+    "dev-package-names": []
+}`
+
+	it.Before(func() {
+		workingDir = t.TempDir()
+		storeDir = t.TempDir()
+		vendorDir = filepath.Join(workingDir, "vendor")
+
+		buffer = bytes.NewBuffer(nil)
+		logger = composer.NewEmitterLogger(scribe.NewEmitter(buffer))
+		store = composer.NewPackageStore(storeDir)
+
+		for _, name := range []string{"some/package", "some/source-package", "some/local-package"} {
+			Expect(os.MkdirAll(filepath.Join(vendorDir, name, "src"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(vendorDir, name, "src", "Class.php"), []byte(name), 0644)).To(Succeed())
+		}
+
+		Expect(os.MkdirAll(filepath.Join(vendorDir, "composer"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(vendorDir, "composer", "installed.json"), []byte(installedJson), 0644)).To(Succeed())
+	})
+
+	context("Update", func() {
+		it("stores the packages installed from dist into the vendor directory", func() {
+			Expect(store.Update(logger, vendorDir)).To(Succeed())
+
+			entries, err := os.ReadDir(storeDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(HaveLen(1))
+
+			storedDir := filepath.Join(storeDir, entries[0].Name())
+			Expect(filepath.Join(storedDir, "files", "src", "Class.php")).To(BeARegularFile())
+			Expect(os.ReadFile(filepath.Join(storedDir, "package.json"))).To(ContainSubstring(`"name": "some/package"`))
+
+			Expect(buffer.String()).To(ContainSubstring("Updated the package store: 1 stored, 1 added, 0 removed"))
+		})
+
+		it("removes the packages which are no longer installed", func() {
+			Expect(os.MkdirAll(filepath.Join(storeDir, "unused", "files"), os.ModePerm)).To(Succeed())
+
+			Expect(store.Update(logger, vendorDir)).To(Succeed())
+			Expect(store.Update(logger, vendorDir)).To(Succeed())
+
+			Expect(filepath.Join(storeDir, "unused")).NotTo(BeADirectory())
+			Expect(buffer.String()).To(ContainSubstring("Updated the package store: 1 stored, 1 added, 1 removed"))
+			Expect(buffer.String()).To(ContainSubstring("Updated the package store: 1 stored, 0 added, 0 removed"))
+		})
+
+		it("does not store the packages of Composer 1", func() {
+			Expect(os.WriteFile(filepath.Join(vendorDir, "composer", "installed.json"), []byte(`[{"name": "some/package"}]`), 0644)).To(Succeed())
+
+			Expect(store.Update(logger, vendorDir)).To(Succeed())
+			Expect(os.ReadDir(storeDir)).To(BeEmpty())
+		})
+	})
+
+	context("Assemble", func() {
+		var composerLockPath string
+
+		it.Before(func() {
+			Expect(store.Update(logger, vendorDir)).To(Succeed())
+			Expect(os.RemoveAll(vendorDir)).To(Succeed())
+
+			composerLockPath = filepath.Join(workingDir, "composer.lock")
+			Expect(os.WriteFile(composerLockPath, []byte(`{
+	"packages": [
+		{"name": "some/package", "version": "1.0.0", "dist": {"type": "zip", "reference": "abc123", "shasum": ""}},
+		{"name": "some/updated-package", "version": "2.0.0", "dist": {"type": "zip", "reference": "fed987", "shasum": ""}}
+	],
+	"packages-dev": [
+		{"name": "some/local-package", "version": "dev-main", "dist": {"type": "path", "reference": "0123ab"}}
+	]
+}`), 0644)).To(Succeed())
+		})
+
+		it("copies the stored packages of composer.lock into the vendor directory", func() {
+			assembled, err := store.Assemble(logger, composerLockPath, vendorDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(assembled).To(Equal(1))
+
+			Expect(os.ReadFile(filepath.Join(vendorDir, "some", "package", "src", "Class.php"))).To(Equal([]byte("some/package")))
+			Expect(filepath.Join(vendorDir, "some", "updated-package")).NotTo(BeADirectory())
+			Expect(filepath.Join(vendorDir, "some", "local-package")).NotTo(BeADirectory())
+
+			installed, err := composer.ReadInstalledPackages(filepath.Join(vendorDir, "composer", "installed.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(installed).To(HaveLen(1))
+			Expect(installed[0].Name).To(Equal("some/package"))
+			Expect(installed[0].InstallPath).To(Equal("../some/package"))
+
+			Expect(buffer.String()).To(ContainSubstring("Assembled 1 of 3 locked packages from the package store"))
+		})
+
+		it("does not assemble a package whose reference changed", func() {
+			Expect(os.WriteFile(composerLockPath, []byte(`{
+	"packages": [
+		{"name": "some/package", "version": "1.0.0", "dist": {"type": "zip", "reference": "cba321", "shasum": ""}}
+	]
+}`), 0644)).To(Succeed())
+
+			assembled, err := store.Assemble(logger, composerLockPath, vendorDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(assembled).To(Equal(0))
+			Expect(vendorDir).NotTo(BeADirectory())
+		})
+
+		it("does not assemble into an existing vendor directory", func() {
+			Expect(os.MkdirAll(vendorDir, os.ModePerm)).To(Succeed())
+
+			assembled, err := store.Assemble(logger, composerLockPath, vendorDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(assembled).To(Equal(0))
+			Expect(os.ReadDir(vendorDir)).To(BeEmpty())
+		})
+
+		it("does not assemble without composer.lock", func() {
+			assembled, err := store.Assemble(logger, filepath.Join(workingDir, "missing.lock"), vendorDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(assembled).To(Equal(0))
+		})
+
+		context("failure cases", func() {
+			context("when composer.lock is not valid JSON", func() {
+				it("returns an error", func() {
+					Expect(os.WriteFile(composerLockPath, []byte(`%%%`), 0644)).To(Succeed())
+
+					_, err := store.Assemble(logger, composerLockPath, vendorDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse")))
+				})
+			})
+		})
+	})
+}
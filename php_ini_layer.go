@@ -0,0 +1,85 @@
+package composer
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+const (
+	// phpIniScanDirEnv is the environment variable read by PHP to determine
+	// the directories of additional INI files, delimited by colons
+	// https://www.php.net/manual/en/configuration.file.php#configuration.file.scan
+	phpIniScanDirEnv = "PHP_INI_SCAN_DIR"
+
+	// phpIniLayerDir is the directory of the composer-packages layer, which
+	// contains the INI files loaded at launch if BP_COMPOSER_PHP_INI_LAYER is
+	// set to true.
+	phpIniLayerDir = "php.ini.d"
+
+	// composerIncludePathIniFileName is the name of the INI file in
+	// phpIniLayerDir, which appends the vendor directory to include_path.
+	composerIncludePathIniFileName = "composer-include-path.ini"
+)
+
+// configurePhpIniLayerIfRequired will check for env var
+// "BP_COMPOSER_PHP_INI_LAYER". If set to true, the INI files loaded at launch
+// are written into the `php.ini.d` directory of the composer-packages layer,
+// instead of the `.php.ini.d` directory of the working directory, and the
+// directory is appended to PHP_INI_SCAN_DIR of the launch environment.
+// Besides composer-extensions.ini, the directory contains
+// composer-include-path.ini, which appends the vendor directory to the
+// include_path configured by other buildpacks.
+//
+// Returns the directory into which composer-extensions.ini is written.
+func configurePhpIniLayerIfRequired(logger emitter, fileSystem FileSystem, composerPackagesLayer *packit.Layer, workingDir, workspaceVendorDir string) (string, error) {
+	workspaceIniDir := filepath.Join(workingDir, ".php.ini.d")
+
+	enabled, err := lookupBoolEnv(BpComposerPhpIniLayer, false)
+	if err != nil {
+		return "", err
+	}
+
+	iniDir := filepath.Join(composerPackagesLayer.Path, phpIniLayerDir)
+
+	// the directory is rewritten on every build, so that a cached layer does
+	// not keep INI files of previous builds
+	err = fileSystem.RemoveAll(iniDir)
+	if err != nil { // untested
+		return "", err
+	}
+
+	if !enabled {
+		return workspaceIniDir, nil
+	}
+
+	if !composerPackagesLayer.Launch {
+		logger.Subprocess("Ignoring %s as the composer-packages layer is not available at launch", BpComposerPhpIniLayer)
+		return workspaceIniDir, nil
+	}
+
+	err = fileSystem.MkdirAll(iniDir, 0755)
+	if err != nil { // untested
+		return "", err
+	}
+
+	// INI files can refer to the value of a setting, so the include_path set
+	// by other buildpacks, e.g. php-dist, is extended instead of replaced
+	// https://www.php.net/manual/en/configuration.file.php
+	includePathIni := fmt.Sprintf("include_path = ${include_path} \":%s\"\n", workspaceVendorDir)
+
+	logger.Debug.Subprocess("Writing %s", filepath.Join(iniDir, composerIncludePathIniFileName))
+	err = writeGeneratedFile(fileSystem, filepath.Join(iniDir, composerIncludePathIniFileName), []byte(includePathIni))
+	if err != nil { // untested
+		return "", err
+	}
+
+	composerPackagesLayer.LaunchEnv.Append(phpIniScanDirEnv, iniDir, ":")
+
+	logger.Process("Configuring PHP INI files in the composer-packages layer")
+	logger.Subprocess("%s -> %q", phpIniScanDirEnv, iniDir)
+	logger.Break()
+
+	return iniDir, nil
+}
@@ -0,0 +1,138 @@
+package composer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+// phpVersionPattern matches the version in the output of `php -v`, e.g.
+// `PHP 8.2.12 (cli) (built: Oct 27 2023 13:00:00) (NTS)`.
+var phpVersionPattern = regexp.MustCompile(`PHP (\d+\.\d+\.\d+)`)
+
+// composerTildePattern matches tilde constraints with exactly two parts,
+// e.g. `~8.1`, which composer treats as `>=8.1 <9.0`.
+var composerTildePattern = regexp.MustCompile(`(^|[\s,|])~\s*v?(\d+)\.(\d+)($|[\s,|])`)
+
+// composerStabilityFlagPattern matches stability flags, e.g. `@dev`.
+var composerStabilityFlagPattern = regexp.MustCompile(`@[a-zA-Z]+`)
+
+// ParseComposerConstraint parses a version constraint of composer, such as
+// `^8.1`, `>=7.4 <8.3` or `~8.1 || ^7.4`. The syntax of composer differs from
+// semantic versioning in the meaning of `~X.Y`, and in allowing a single `|`
+// as separator of alternatives.
+// https://getcomposer.org/doc/articles/versions.md#writing-version-constraints
+func ParseComposerConstraint(value string) (*semver.Constraints, error) {
+	constraint := composerStabilityFlagPattern.ReplaceAllString(value, "")
+
+	constraint = strings.ReplaceAll(constraint, "||", "|")
+	constraint = strings.ReplaceAll(constraint, "|", "||")
+
+	constraint = composerTildePattern.ReplaceAllStringFunc(constraint, func(match string) string {
+		groups := composerTildePattern.FindStringSubmatch(match)
+		major, _ := strconv.Atoi(groups[2])
+		return fmt.Sprintf("%s>=%s.%s, <%d.0%s", groups[1], groups[2], groups[3], major+1, groups[4])
+	})
+
+	return semver.NewConstraint(constraint)
+}
+
+// determinePhpVersion will run `php -v` to determine the version of PHP on the
+// path.
+func determinePhpVersion(phpVersionExec Executable, composerPhpIniPath, path string) (string, error) {
+	buffer := bytes.NewBuffer(nil)
+	err := phpVersionExec.Execute(pexec.Execution{
+		Args: []string{"-v"},
+		Env: append(os.Environ(),
+			fmt.Sprintf("PHPRC=%s", composerPhpIniPath),
+			fmt.Sprintf("PATH=%s", path),
+		),
+		Stdout: buffer,
+		Stderr: buffer,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to determine PHP version: %w: %s", err, strings.TrimSpace(buffer.String()))
+	}
+
+	matches := phpVersionPattern.FindStringSubmatch(buffer.String())
+	if matches == nil {
+		return "", fmt.Errorf("failed to parse PHP version from output %q", strings.TrimSpace(buffer.String()))
+	}
+
+	return matches[1], nil
+}
+
+// checkPhpVersion will fail the build early if the PHP on the path does not
+// satisfy the PHP requirement of the application, as determined by the
+// PhpVersionResolver, instead of failing `composer install` with resolution
+// errors for each package. The check is skipped if `composer.json` overrides
+// the PHP version with `config.platform.php`, as composer then resolves the
+// packages against that version.
+func checkPhpVersion(logger emitter, phpVersionExec Executable, composerJsonPath, composerLockPath, composerPhpIniPath, path string) error {
+	if exists, err := pathExists(composerJsonPath); err != nil {
+		return err
+	} else if !exists {
+		return nil
+	}
+
+	content, err := readComposerFile(composerJsonPath)
+	if err != nil { // untested
+		return err
+	}
+
+	var composerJson struct {
+		Config struct {
+			Platform map[string]interface{} `json:"platform"`
+		} `json:"config"`
+	}
+
+	err = json.Unmarshal(content, &composerJson)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", composerJsonPath, err)
+	}
+
+	if platformPhp, ok := composerJson.Config.Platform["php"].(string); ok {
+		logger.Debug.Process("Skipping the PHP version check, config.platform.php overrides the PHP version with %s", platformPhp)
+		logger.Debug.Break()
+		return nil
+	}
+
+	requirement, source, err := NewPhpVersionResolver().Resolve(composerJsonPath, composerLockPath)
+	if err != nil {
+		return err
+	}
+
+	if requirement == "" {
+		return nil
+	}
+
+	constraint, err := ParseComposerConstraint(requirement)
+	if err != nil {
+		// leave unusual constraints to composer
+		logger.Debug.Process("Skipping the PHP version check, failed to parse the PHP requirement %q of %s: %s", requirement, source, err)
+		logger.Debug.Break()
+		return nil
+	}
+
+	version, err := determinePhpVersion(phpVersionExec, composerPhpIniPath, path)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug.Process("Found PHP version %s, required by %s: %s", version, source, requirement)
+	logger.Debug.Break()
+
+	if constraint.Check(semver.MustParse(version)) {
+		return nil
+	}
+
+	return fmt.Errorf("PHP %s on the path does not satisfy the PHP requirement %q of %s, "+
+		"install a PHP version matching %q, e.g. with BP_PHP_VERSION", version, requirement, source, requirement)
+}
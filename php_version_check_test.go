@@ -0,0 +1,61 @@
+package composer_test
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPhpVersionCheck(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+	)
+
+	context("ParseComposerConstraint", func() {
+		var check = func(constraint, version string) bool {
+			constraints, err := composer.ParseComposerConstraint(constraint)
+			Expect(err).NotTo(HaveOccurred())
+			return constraints.Check(semver.MustParse(version))
+		}
+
+		it("parses caret and range constraints", func() {
+			Expect(check("^8.1", "8.3.0")).To(BeTrue())
+			Expect(check("^8.1", "8.0.30")).To(BeFalse())
+			Expect(check("^8.1", "9.0.0")).To(BeFalse())
+			Expect(check(">=7.4 <8.3", "8.2.12")).To(BeTrue())
+			Expect(check(">=7.4 <8.3", "8.3.0")).To(BeFalse())
+		})
+
+		it("treats ~X.Y as composer does", func() {
+			Expect(check("~8.1", "8.3.0")).To(BeTrue())
+			Expect(check("~8.1", "8.0.30")).To(BeFalse())
+			Expect(check("~8.1", "9.0.0")).To(BeFalse())
+			Expect(check("~8.1.2", "8.1.9")).To(BeTrue())
+			Expect(check("~8.1.2", "8.2.0")).To(BeFalse())
+		})
+
+		it("accepts a single | as separator of alternatives", func() {
+			Expect(check("^7.4|^8.0", "7.4.33")).To(BeTrue())
+			Expect(check("^7.4 | ~8.1", "8.2.12")).To(BeTrue())
+			Expect(check("^7.4 || ^8.0", "8.2.12")).To(BeTrue())
+			Expect(check("^7.4|^8.0", "7.3.0")).To(BeFalse())
+		})
+
+		it("ignores stability flags", func() {
+			Expect(check("^8.1@dev", "8.2.12")).To(BeTrue())
+		})
+
+		context("failure cases", func() {
+			context("when the constraint is invalid", func() {
+				it("returns an error", func() {
+					_, err := composer.ParseComposerConstraint("not-a-version")
+					Expect(err).To(HaveOccurred())
+				})
+			})
+		})
+	})
+}
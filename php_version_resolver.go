@@ -1,8 +1,8 @@
 package composer
 
 import (
+	"bytes"
 	"encoding/json"
-	"os"
 
 	"github.com/paketo-buildpacks/packit/v2/fs"
 )
@@ -27,16 +27,14 @@ func (_ PhpVersionResolver) Resolve(composerJsonPath, composerLockPath string) (
 	if exists, err := fs.Exists(composerLockPath); err != nil {
 		return "", "", err
 	} else if exists {
-		file, err := os.Open(composerLockPath)
+		content, err := readComposerFile(composerLockPath)
 		if err != nil {
 			return "", "", err
 		}
 
-		defer file.Close()
-
 		var unknownJson map[string]interface{}
 
-		err = json.NewDecoder(file).Decode(&unknownJson)
+		err = json.NewDecoder(bytes.NewReader(content)).Decode(&unknownJson)
 		if err != nil {
 			return "", "", err
 		}
@@ -57,11 +55,10 @@ func (_ PhpVersionResolver) Resolve(composerJsonPath, composerLockPath string) (
 			}
 		}
 	} else {
-		file, err := os.Open(composerJsonPath)
+		content, err := readComposerFile(composerJsonPath)
 		if err != nil {
 			return "", "", err
 		}
-		defer file.Close()
 
 		var composerJson struct {
 			Require struct {
@@ -70,7 +67,7 @@ func (_ PhpVersionResolver) Resolve(composerJsonPath, composerLockPath string) (
 			}
 		}
 
-		err = json.NewDecoder(file).Decode(&composerJson)
+		err = json.NewDecoder(bytes.NewReader(content)).Decode(&composerJson)
 		if err != nil {
 			return "", "", err
 		}
@@ -118,6 +118,26 @@ func testPhpVersionResolver(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
+	context("when composer.json and composer.lock are written on Windows", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte("\xef\xbb\xbf{\r\n"+
+				`	"require": {"php": "php.version.from-composer-json"}`+"\r\n"+
+				"}\r\n"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte("\xef\xbb\xbf{\r\n"+
+				`	"platform": {"php": "php.version.from-composer-lock"}`+"\r\n"+
+				"}\r\n"), os.ModePerm)).To(Succeed())
+		})
+
+		it(`requires "php" with version metadata from composer.lock`, func() {
+			version, versionSource, err := phpVersionResolver.Resolve(
+				filepath.Join(workingDir, "composer.json"),
+				filepath.Join(workingDir, "composer.lock"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal("php.version.from-composer-lock"))
+			Expect(versionSource).To(Equal("composer.lock"))
+		})
+	})
+
 	context("when composer.lock is not present", func() {
 		context("when composer.json contains the 64bit PHP version", func() {
 			it.Before(func() {
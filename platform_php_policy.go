@@ -0,0 +1,141 @@
+package composer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+const (
+	// PlatformPhpPolicyOff ignores the PHP requirements reported by
+	// `composer check-platform-reqs`
+	PlatformPhpPolicyOff = "off"
+
+	// PlatformPhpPolicyWarn logs the PHP requirements which the PHP of the
+	// build does not satisfy
+	PlatformPhpPolicyWarn = "warn"
+
+	// PlatformPhpPolicyFail fails the build if the PHP of the build does not
+	// satisfy the PHP requirements
+	PlatformPhpPolicyFail = "fail"
+)
+
+// rootPackageName is the name composer reports for requirements of a
+// `composer.json` without a name.
+const rootPackageName = "__root__"
+
+// checkPlatformPhpIfRequired will check for env var
+// "BP_COMPOSER_PLATFORM_PHP_POLICY". If set to "warn" or "fail", the "php" and
+// "php-64bit" requirements reported as not satisfied by
+// `composer check-platform-reqs` are logged, or fail the build. The message
+// names the packages of `composer.lock` whose constraint the PHP version does
+// not satisfy, as composer only reports the first of them.
+//
+// Unlike checkPhpVersion, which compares the PHP requirement of the
+// application before `composer install`, this check includes the
+// requirements of all installed packages.
+func checkPlatformPhpIfRequired(logger emitter, requirements []platformRequirement, composerLockPath string) error {
+	policy := strings.ToLower(os.Getenv(BpComposerPlatformPhpPolicy))
+	switch policy {
+	case "", PlatformPhpPolicyOff:
+		return nil
+	case PlatformPhpPolicyWarn, PlatformPhpPolicyFail:
+	default:
+		return fmt.Errorf("%s must be one of %q, %q or %q, found %q", BpComposerPlatformPhpPolicy, PlatformPhpPolicyOff, PlatformPhpPolicyWarn, PlatformPhpPolicyFail, policy)
+	}
+
+	var violations []string
+	for _, requirement := range requirements {
+		if (requirement.Name != "php" && requirement.Name != "php-64bit") || requirement.Status == "success" {
+			continue
+		}
+
+		sources, err := findUnsatisfiedPhpConstraints(composerLockPath, requirement.Name, requirement.Version)
+		if err != nil {
+			return err
+		}
+
+		if len(sources) == 0 && requirement.FailedRequirement != nil {
+			source := requirement.FailedRequirement.Source
+			if source == rootPackageName {
+				source = "composer.json"
+			}
+			sources = []string{fmt.Sprintf("%s (%s)", source, requirement.FailedRequirement.Constraint)}
+		}
+
+		violations = append(violations, fmt.Sprintf("%s %s does not satisfy the requirements of %s", requirement.Name, requirement.Version, strings.Join(sources, ", ")))
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	if policy == PlatformPhpPolicyFail {
+		return fmt.Errorf("%s, install a PHP version matching the requirements, e.g. with BP_PHP_VERSION (%s=%s)", strings.Join(violations, "; "), BpComposerPlatformPhpPolicy, policy)
+	}
+
+	for _, violation := range violations {
+		logger.Subprocess("WARNING: %s", violation)
+	}
+	logger.Break()
+
+	return nil
+}
+
+// findUnsatisfiedPhpConstraints returns the packages of the given
+// `composer.lock`, whose requirement of the given platform package, i.e.
+// "php" or "php-64bit", the given version does not satisfy, e.g.
+// "vendor/package (^8.2)". Constraints which cannot be parsed are left out.
+func findUnsatisfiedPhpConstraints(composerLockPath, name, version string) ([]string, error) {
+	parsedVersion, err := semver.NewVersion(version)
+	if err != nil {
+		// e.g. "n/a" if php-64bit is required on 32bit PHP
+		return nil, nil
+	}
+
+	content, err := readComposerFile(composerLockPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	type lockedPackage struct {
+		Name    string            `json:"name"`
+		Require map[string]string `json:"require"`
+	}
+
+	var composerLock struct {
+		Packages    []lockedPackage `json:"packages"`
+		PackagesDev []lockedPackage `json:"packages-dev"`
+	}
+
+	err = json.Unmarshal(content, &composerLock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", composerLockPath, err)
+	}
+
+	var sources []string
+	for _, p := range append(composerLock.Packages, composerLock.PackagesDev...) {
+		requirement, ok := p.Require[name]
+		if !ok {
+			continue
+		}
+
+		constraint, err := ParseComposerConstraint(requirement)
+		if err != nil || constraint.Check(parsedVersion) {
+			continue
+		}
+
+		sources = append(sources, fmt.Sprintf("%s (%s)", p.Name, requirement))
+	}
+	sort.Strings(sources)
+
+	return sources, nil
+}
@@ -0,0 +1,256 @@
+package composer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+const pluginOutputLayerDir = "plugin-output"
+
+// BamarniBinPluginName is the package name of the Composer plugin installing
+// tools into isolated namespaces of `vendor-bin`.
+// https://github.com/bamarni/composer-bin-plugin
+const BamarniBinPluginName = "bamarni/composer-bin-plugin"
+
+// pluginOutput describes where a Composer plugin places its output in the
+// workspace outside of the vendor directory. Plugins writing into the vendor
+// directory only, such as `phpstan/extension-installer`, are already cached
+// with the vendor directory.
+type pluginOutput struct {
+	// paths returns the output of the plugin, relative to the working directory
+	paths func(workingDir, composerJsonPath string) ([]string, error)
+
+	// inputs returns the files determining the output of the plugin, which
+	// are checksummed alongside `composer.lock`
+	inputs func(workingDir, composerJsonPath string) ([]string, error)
+}
+
+// knownPluginOutputs lists the supported plugins by package name.
+var knownPluginOutputs = map[string]pluginOutput{
+	BamarniBinPluginName: {
+		paths:  findBamarniBinVendorDirs,
+		inputs: findBamarniBinComposerFiles,
+	},
+}
+
+// FindPluginOutputPaths returns the output of the known Composer plugins
+// locked in `composer.lock`, such as the `vendor-bin/*/vendor` trees of
+// `bamarni/composer-bin-plugin`.
+//
+// Returns the paths relative to the working directory.
+func FindPluginOutputPaths(workingDir, composerJsonPath, _ string) ([]string, error) {
+	var paths []string
+	err := forEachLockedPlugin(composerJsonPath, func(plugin pluginOutput) error {
+		pluginPaths, err := plugin.paths(workingDir, composerJsonPath)
+		paths = append(paths, pluginPaths...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// FindPluginChecksumInputs returns the files determining the output of the
+// known Composer plugins locked in `composer.lock`, such as the
+// `composer.json` and `composer.lock` of each `vendor-bin` namespace. If any
+// of them changes, the cached composer-packages layer cannot be reused.
+func FindPluginChecksumInputs(workingDir, composerJsonPath string) ([]string, error) {
+	var inputs []string
+	err := forEachLockedPlugin(composerJsonPath, func(plugin pluginOutput) error {
+		pluginInputs, err := plugin.inputs(workingDir, composerJsonPath)
+		inputs = append(inputs, pluginInputs...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(inputs)
+
+	return inputs, nil
+}
+
+// readLockedPackageNames returns the names of all packages in the lock file
+// of the given `composer.json`. Returns no packages if
+// `composer.lock` does not exist or is invalid, as an invalid
+// `composer.lock` is reported by `composer install`.
+func readLockedPackageNames(composerJsonPath string) (map[string]bool, error) {
+	content, err := readComposerFile(composerLockPathOf(composerJsonPath))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	type lockedPackage struct {
+		Name string `json:"name"`
+	}
+
+	var composerLock struct {
+		Packages    []lockedPackage `json:"packages"`
+		PackagesDev []lockedPackage `json:"packages-dev"`
+	}
+
+	if json.Unmarshal(content, &composerLock) != nil {
+		return nil, nil
+	}
+
+	names := map[string]bool{}
+	for _, p := range append(composerLock.Packages, composerLock.PackagesDev...) {
+		names[p.Name] = true
+	}
+
+	return names, nil
+}
+
+// forEachLockedPlugin calls f for each known plugin in the lock file of the
+// given `composer.json`, in the order of the package names.
+func forEachLockedPlugin(composerJsonPath string, f func(pluginOutput) error) error {
+	locked, err := readLockedPackageNames(composerJsonPath)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range knownPluginOutputs {
+		if locked[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		err = f(knownPluginOutputs[name])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findBamarniBinNamespaces returns the namespaces of
+// `bamarni/composer-bin-plugin`, i.e. the directories containing a
+// `composer.json` in its target directory. The target directory defaults to
+// `vendor-bin` and can be changed with `extra.bamarni-bin.target-directory`.
+//
+// Returns the namespace directories relative to the working directory.
+func findBamarniBinNamespaces(workingDir, composerJsonPath string) ([]string, error) {
+	var composerJson struct {
+		Extra struct {
+			BamarniBin struct {
+				TargetDirectory string `json:"target-directory"`
+			} `json:"bamarni-bin"`
+		} `json:"extra"`
+	}
+
+	content, err := readComposerFile(composerJsonPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	} else if err == nil {
+		err = json.Unmarshal(content, &composerJson)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", composerJsonPath, err)
+		}
+	}
+
+	targetDirectory := composerJson.Extra.BamarniBin.TargetDirectory
+	if targetDirectory == "" {
+		targetDirectory = "vendor-bin"
+	}
+
+	relativeTargetDirectory, err := filepath.Rel(workingDir, filepath.Join(workingDir, targetDirectory))
+	if err != nil { // untested
+		return nil, err
+	}
+
+	if filepath.IsAbs(targetDirectory) || relativeTargetDirectory == "." || strings.HasPrefix(relativeTargetDirectory, "..") {
+		return nil, fmt.Errorf("extra.bamarni-bin.target-directory must be a relative path underneath the project root, found %q", targetDirectory)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(workingDir, relativeTargetDirectory))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var namespaces []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		namespace := filepath.Join(relativeTargetDirectory, entry.Name())
+		if exists, err := fs.Exists(filepath.Join(workingDir, namespace, DefaultComposerJsonPath)); err != nil {
+			return nil, err
+		} else if !exists {
+			continue
+		}
+
+		namespaces = append(namespaces, namespace)
+	}
+
+	return namespaces, nil
+}
+
+// findBamarniBinVendorDirs returns the vendor directories of the
+// `bamarni/composer-bin-plugin` namespaces which have been installed.
+func findBamarniBinVendorDirs(workingDir, composerJsonPath string) ([]string, error) {
+	namespaces, err := findBamarniBinNamespaces(workingDir, composerJsonPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, namespace := range namespaces {
+		vendorDir := filepath.Join(namespace, "vendor")
+		if exists, err := fs.Exists(filepath.Join(workingDir, vendorDir)); err != nil {
+			return nil, err
+		} else if !exists {
+			continue
+		}
+
+		paths = append(paths, vendorDir)
+	}
+
+	return paths, nil
+}
+
+// findBamarniBinComposerFiles returns the `composer.json` and `composer.lock`
+// files of the `bamarni/composer-bin-plugin` namespaces.
+func findBamarniBinComposerFiles(workingDir, composerJsonPath string) ([]string, error) {
+	namespaces, err := findBamarniBinNamespaces(workingDir, composerJsonPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var inputs []string
+	for _, namespace := range namespaces {
+		for _, name := range []string{DefaultComposerJsonPath, DefaultComposerLockPath} {
+			path := filepath.Join(workingDir, namespace, name)
+			if exists, err := fs.Exists(path); err != nil {
+				return nil, err
+			} else if !exists {
+				continue
+			}
+
+			inputs = append(inputs, path)
+		}
+	}
+
+	return inputs, nil
+}
@@ -0,0 +1,157 @@
+package composer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPluginOutput(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir       string
+		composerJsonPath string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		composerJsonPath = filepath.Join(workingDir, "composer.json")
+		Expect(os.WriteFile(composerJsonPath, []byte(`{}`), os.ModePerm)).To(Succeed())
+
+		for _, namespace := range []string{"phpstan", "php-cs-fixer", "not-installed"} {
+			Expect(os.MkdirAll(filepath.Join(workingDir, "vendor-bin", namespace), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "vendor-bin", namespace, "composer.json"), []byte(`{}`), os.ModePerm)).To(Succeed())
+		}
+		Expect(os.WriteFile(filepath.Join(workingDir, "vendor-bin", "phpstan", "composer.lock"), []byte(`{}`), os.ModePerm)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(workingDir, "vendor-bin", "phpstan", "vendor"), os.ModePerm)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(workingDir, "vendor-bin", "php-cs-fixer", "vendor"), os.ModePerm)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(workingDir, "vendor-bin", "no-composer-json", "vendor"), os.ModePerm)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("when bamarni/composer-bin-plugin is locked", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+	"packages": [],
+	"packages-dev": [
+		{
+			"name": "bamarni/composer-bin-plugin"
+		}
+	]
+}`), os.ModePerm)).To(Succeed())
+		})
+
+		it("returns the vendor directories of the installed namespaces", func() {
+			paths, err := composer.FindPluginOutputPaths(workingDir, composerJsonPath, filepath.Join(workingDir, "vendor"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(Equal([]string{
+				filepath.Join("vendor-bin", "php-cs-fixer", "vendor"),
+				filepath.Join("vendor-bin", "phpstan", "vendor"),
+			}))
+		})
+
+		it("returns the composer files of the namespaces as checksum inputs", func() {
+			inputs, err := composer.FindPluginChecksumInputs(workingDir, composerJsonPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inputs).To(Equal([]string{
+				filepath.Join(workingDir, "vendor-bin", "not-installed", "composer.json"),
+				filepath.Join(workingDir, "vendor-bin", "php-cs-fixer", "composer.json"),
+				filepath.Join(workingDir, "vendor-bin", "phpstan", "composer.json"),
+				filepath.Join(workingDir, "vendor-bin", "phpstan", "composer.lock"),
+			}))
+		})
+
+		context("when composer.json sets a target directory", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(composerJsonPath, []byte(`{
+	"extra": {
+		"bamarni-bin": {
+			"target-directory": "tools"
+		}
+	}
+}`), os.ModePerm)).To(Succeed())
+
+				Expect(os.MkdirAll(filepath.Join(workingDir, "tools", "psalm", "vendor"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "tools", "psalm", "composer.json"), []byte(`{}`), os.ModePerm)).To(Succeed())
+			})
+
+			it("returns the vendor directories in the target directory", func() {
+				paths, err := composer.FindPluginOutputPaths(workingDir, composerJsonPath, filepath.Join(workingDir, "vendor"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(paths).To(Equal([]string{filepath.Join("tools", "psalm", "vendor")}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the target directory is outside of the project root", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(composerJsonPath, []byte(`{
+	"extra": {
+		"bamarni-bin": {
+			"target-directory": "../tools"
+		}
+	}
+}`), os.ModePerm)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := composer.FindPluginOutputPaths(workingDir, composerJsonPath, filepath.Join(workingDir, "vendor"))
+					Expect(err).To(MatchError(`extra.bamarni-bin.target-directory must be a relative path underneath the project root, found "../tools"`))
+				})
+			})
+
+			context("when composer.json is invalid", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(composerJsonPath, []byte(`%%%`), os.ModePerm)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := composer.FindPluginChecksumInputs(workingDir, composerJsonPath)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse")))
+				})
+			})
+		})
+	})
+
+	context("when no known plugin is locked", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+	"packages": [
+		{
+			"name": "monolog/monolog"
+		}
+	]
+}`), os.ModePerm)).To(Succeed())
+		})
+
+		it("returns no paths", func() {
+			paths, err := composer.FindPluginOutputPaths(workingDir, composerJsonPath, filepath.Join(workingDir, "vendor"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(BeEmpty())
+
+			inputs, err := composer.FindPluginChecksumInputs(workingDir, composerJsonPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inputs).To(BeEmpty())
+		})
+	})
+
+	context("when composer.lock does not exist", func() {
+		it("returns no paths", func() {
+			paths, err := composer.FindPluginOutputPaths(workingDir, composerJsonPath, filepath.Join(workingDir, "vendor"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(BeEmpty())
+		})
+	})
+}
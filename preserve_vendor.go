@@ -0,0 +1,148 @@
+package composer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/draft"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+// dumpAutoloadOptions maps the options of `composer install`, which affect
+// the autoloader, to the corresponding options of `composer dump-autoload`.
+var dumpAutoloadOptions = map[string]string{
+	"--no-dev":                 "--no-dev",
+	"--optimize-autoloader":    "--optimize",
+	"-o":                       "--optimize",
+	"--classmap-authoritative": "--classmap-authoritative",
+	"-a":                       "--classmap-authoritative",
+	"--apcu-autoloader":        "--apcu",
+	"--ignore-platform-reqs":   "--ignore-platform-reqs",
+}
+
+// vendorPreservationRequested returns whether the application contains its
+// vendored packages, i.e. `{vendorDir}/composer/installed.json`, but no
+// `composer.lock`. Running `composer install` without `composer.lock` would
+// resolve the dependencies again, and replace the vendored packages with
+// possibly newer versions. Unless "BP_COMPOSER_PRESERVE_VENDOR" is set to
+// false, the vendored packages are preserved instead.
+func vendorPreservationRequested(composerLockPath, workspaceVendorDir string) (bool, error) {
+	enabled, err := lookupBoolEnv(BpComposerPreserveVendor, true)
+	if err != nil {
+		return false, err
+	}
+
+	if !enabled {
+		return false, nil
+	}
+
+	if exists, err := fs.Exists(composerLockPath); err != nil {
+		return false, err
+	} else if exists {
+		return false, nil
+	}
+
+	return fs.Exists(filepath.Join(workspaceVendorDir, "composer", "installed.json"))
+}
+
+// composerDumpAutoloadArgs returns the arguments of `composer dump-autoload`
+// for the given options of `composer install`.
+func composerDumpAutoloadArgs(installOptions []InstallOption) []string {
+	args := []string{"dump-autoload"}
+	added := map[string]bool{}
+	for _, option := range installOptions {
+		arg, ok := dumpAutoloadOptions[option.Value]
+		if !ok || added[arg] {
+			continue
+		}
+		added[arg] = true
+		args = append(args, arg)
+	}
+
+	return args
+}
+
+// preserveVendor regenerates the autoloader of the vendored packages with
+// `composer dump-autoload`, and copies them into the composer-packages layer,
+// without installing any package. The layer is not cached, as it is built
+// from the vendored packages of each build.
+func preserveVendor(
+	logger emitter,
+	context packit.BuildContext,
+	installOptions []InstallOption,
+	composerPhpIniPath string,
+	path string,
+	composerInstallExec Executable,
+	workspaceVendorDir string,
+	composerHome string,
+	tracer *Tracer) (packit.Layer, error) {
+
+	composerJsonPath, _, _, _ := FindComposerFiles(context.WorkingDir)
+
+	logger.Process("No composer.lock found, preserving the vendored packages in %s", workspaceVendorDir)
+	logger.Subprocess("Set %s to false to resolve the dependencies with 'composer install' instead", BpComposerPreserveVendor)
+
+	composerPackagesLayer, err := context.Layers.Get(ComposerPackagesLayerName)
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+
+	composerPackagesLayer, err = composerPackagesLayer.Reset()
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+
+	composerPackagesLayer.Launch, composerPackagesLayer.Build = draft.NewPlanner().MergeLayerTypes(ComposerPackagesDependency, context.Plan.Entries)
+	composerPackagesLayer.Cache = false
+
+	composerPackagesLayer.Metadata = map[string]interface{}{
+		MetadataVersionKey: ComposerPackagesMetadataVersion,
+		"stack":            context.Stack,
+		"cache-status":     string(CacheStatusVendorPreserved),
+	}
+
+	logger.Process("Composer packages cache: %s", CacheStatusVendorPreserved)
+
+	args := composerDumpAutoloadArgs(installOptions)
+	logger.Process("Running 'composer %s'", strings.Join(args, " "))
+
+	err = composerInstallExec.Execute(pexec.Execution{
+		Args: args,
+		Dir:  context.WorkingDir,
+		Env: append(os.Environ(),
+			"COMPOSER_NO_INTERACTION=1", // https://getcomposer.org/doc/03-cli.md#composer-no-interaction
+			fmt.Sprintf("COMPOSER=%s", composerJsonPath),
+			fmt.Sprintf("COMPOSER_HOME=%s", composerHome),
+			fmt.Sprintf("COMPOSER_VENDOR_DIR=%s", workspaceVendorDir),
+			fmt.Sprintf("PHPRC=%s", composerPhpIniPath),
+			fmt.Sprintf("PATH=%s", path),
+		),
+		Stdout: logger.ActionWriter,
+		Stderr: logger.ActionWriter,
+	})
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	layerIgnore, err := LoadLayerIgnore(context.WorkingDir)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	layerVendorDir := filepath.Join(composerPackagesLayer.Path, "vendor")
+	logger.Process("Copying from %s => to %s", workspaceVendorDir, layerVendorDir)
+	logLayerIgnore(logger, layerIgnore)
+
+	err = tracer.Trace("copy vendor", copyAttributes(workspaceVendorDir, layerVendorDir), func() error {
+		return copyTreeExcluding(logger, workspaceVendorDir, layerVendorDir, layerIgnore.Excludes)
+	})
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+
+	return composerPackagesLayer, nil
+}
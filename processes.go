@@ -0,0 +1,216 @@
+package composer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// AutodetectedScripts are the conventional `composer.json` scripts which are
+// registered as process types of the same name.
+var AutodetectedScripts = []string{"start", "serve", "worker"}
+
+// phpCallback matches scripts calling a static PHP method, such as
+// "MyVendor\\MyClass::postInstall", which can only run from within composer.
+var phpCallback = regexp.MustCompile(`^[A-Za-z_\\][\w\\]*::\w+$`)
+
+// ComposerScripts are the scripts of a `composer.json`. Each script is either a
+// single command or a list of commands.
+// https://getcomposer.org/doc/articles/scripts.md
+type ComposerScripts map[string]interface{}
+
+// ReadComposerScripts reads the "scripts" of the given `composer.json`.
+// Returns no scripts if `composer.json` does not exist.
+func ReadComposerScripts(composerJsonPath string) (ComposerScripts, error) {
+	content, err := readComposerFile(composerJsonPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var composerJson struct {
+		Scripts ComposerScripts `json:"scripts"`
+	}
+
+	err = json.Unmarshal(content, &composerJson)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", composerJsonPath, err)
+	}
+
+	return composerJson.Scripts, nil
+}
+
+// Command returns a shell command running the given script without composer.
+// The commands of a list are chained with "&&", references to other scripts
+// are inlined, and "@php" and "@putenv" are replaced with their shell
+// equivalents. Scripts running composer itself or calling PHP methods are
+// not supported, as composer is not available at launch.
+func (s ComposerScripts) Command(name string) (string, error) {
+	return s.command(name, map[string]bool{})
+}
+
+func (s ComposerScripts) command(name string, referencing map[string]bool) (string, error) {
+	if referencing[name] {
+		return "", fmt.Errorf("script %q references itself", name)
+	}
+	referencing[name] = true
+	defer delete(referencing, name)
+
+	var entries []string
+	switch script := s[name].(type) {
+	case string:
+		entries = []string{script}
+	case []interface{}:
+		for _, entry := range script {
+			entry, ok := entry.(string)
+			if !ok {
+				return "", fmt.Errorf("script %q must only contain strings", name)
+			}
+			entries = append(entries, entry)
+		}
+	case nil:
+		return "", fmt.Errorf("script %q not found", name)
+	default:
+		return "", fmt.Errorf("script %q must be a string or a list of strings", name)
+	}
+
+	var commands []string
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+
+		switch {
+		case strings.HasPrefix(entry, "@php "):
+			commands = append(commands, "php "+strings.TrimPrefix(entry, "@php "))
+		case strings.HasPrefix(entry, "@putenv "):
+			commands = append(commands, "export "+strings.TrimPrefix(entry, "@putenv "))
+		case entry == "@composer" || strings.HasPrefix(entry, "@composer "):
+			return "", fmt.Errorf("script %q runs composer, which is not available at launch", name)
+		case strings.HasPrefix(entry, "@"):
+			fields := strings.Fields(strings.TrimPrefix(entry, "@"))
+			if len(fields) != 1 {
+				return "", fmt.Errorf("script %q passes arguments to %q, which is not supported", name, entry)
+			}
+
+			command, err := s.command(fields[0], referencing)
+			if err != nil {
+				return "", err
+			}
+			commands = append(commands, command)
+		case phpCallback.MatchString(entry):
+			return "", fmt.Errorf("script %q calls the PHP method %s, which requires composer", name, entry)
+		default:
+			commands = append(commands, entry)
+		}
+	}
+
+	return strings.Join(commands, " && "), nil
+}
+
+// findBinDir returns the directory into which composer links the binaries of
+// the packages, see https://getcomposer.org/doc/06-config.md#bin-dir
+func findBinDir(workingDir, composerJsonPath, workspaceVendorDir string) (string, error) {
+	content, err := readComposerFile(composerJsonPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return filepath.Join(workspaceVendorDir, "bin"), nil
+		}
+		return "", err
+	}
+
+	var composerJson struct {
+		Config struct {
+			BinDir string `json:"bin-dir"`
+		} `json:"config"`
+	}
+
+	err = json.Unmarshal(content, &composerJson)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", composerJsonPath, err)
+	}
+
+	if composerJson.Config.BinDir == "" {
+		return filepath.Join(workspaceVendorDir, "bin"), nil
+	}
+
+	return filepath.Join(workingDir, composerJson.Config.BinDir), nil
+}
+
+// detectProcessesIfRequired will check for env var
+// "BP_COMPOSER_AUTODETECT_PROCESSES". If set to true, the conventional
+// scripts "start", "serve" and "worker" of `composer.json` are registered as
+// process types of the same name. As composer does when running scripts, the
+// bin-dir is prepended to the PATH of these processes.
+//
+// Scripts which cannot run without composer are skipped.
+func detectProcessesIfRequired(
+	logger emitter,
+	workingDir string,
+	composerJsonPath string,
+	workspaceVendorDir string,
+	composerPackagesLayer *packit.Layer) ([]packit.Process, error) {
+	enabled, err := lookupBoolEnv(BpComposerAutodetectProcesses, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if !enabled {
+		return nil, nil
+	}
+
+	scripts, err := ReadComposerScripts(composerJsonPath)
+	if err != nil {
+		return nil, err
+	}
+
+	binDir, err := findBinDir(workingDir, composerJsonPath, workspaceVendorDir)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Process("Detecting processes from composer.json scripts")
+
+	var processes []packit.Process
+	for _, name := range AutodetectedScripts {
+		if _, ok := scripts[name]; !ok {
+			continue
+		}
+
+		command, err := scripts.Command(name)
+		if err != nil {
+			logger.Subprocess("Skipping %s: %s", name, err)
+			continue
+		}
+
+		processes = append(processes, packit.Process{
+			Type:    name,
+			Command: command,
+		})
+
+		if composerPackagesLayer.ProcessLaunchEnv == nil {
+			composerPackagesLayer.ProcessLaunchEnv = map[string]packit.Environment{}
+		}
+		if composerPackagesLayer.ProcessLaunchEnv[name] == nil {
+			composerPackagesLayer.ProcessLaunchEnv[name] = packit.Environment{}
+		}
+		composerPackagesLayer.ProcessLaunchEnv[name].Prepend("PATH", binDir, string(os.PathListSeparator))
+	}
+
+	if len(processes) == 0 {
+		logger.Subprocess("No processes found")
+		logger.Break()
+		return nil, nil
+	}
+	logger.Break()
+
+	logger.LaunchProcesses(processes, composerPackagesLayer.ProcessLaunchEnv)
+
+	return processes, nil
+}
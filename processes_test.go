@@ -0,0 +1,112 @@
+package composer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testComposerScripts(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("ReadComposerScripts", func() {
+		it("reads the scripts of composer.json", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`{"scripts": {"start": "php -S 0.0.0.0:8080", "test": ["phpunit"]}}`), os.ModePerm)).To(Succeed())
+
+			scripts, err := composer.ReadComposerScripts(filepath.Join(workingDir, "composer.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(scripts).To(Equal(composer.ComposerScripts{
+				"start": "php -S 0.0.0.0:8080",
+				"test":  []interface{}{"phpunit"},
+			}))
+		})
+
+		it("returns no scripts if composer.json does not exist", func() {
+			scripts, err := composer.ReadComposerScripts(filepath.Join(workingDir, "composer.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(scripts).To(BeEmpty())
+		})
+
+		context("failure cases", func() {
+			context("when composer.json is not valid JSON", func() {
+				it("returns an error", func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte(`%%%`), os.ModePerm)).To(Succeed())
+
+					_, err := composer.ReadComposerScripts(filepath.Join(workingDir, "composer.json"))
+					Expect(err).To(MatchError(ContainSubstring("failed to parse")))
+				})
+			})
+		})
+	})
+
+	context("Command", func() {
+		var scripts composer.ComposerScripts
+
+		it.Before(func() {
+			scripts = composer.ComposerScripts{
+				"start":          "php -S 0.0.0.0:8080 -t public",
+				"serve":          []interface{}{"@putenv APP_ENV=prod", "@migrate", "@php bin/server.php"},
+				"migrate":        "@php bin/console doctrine:migrations:migrate",
+				"composer":       "@composer dump-autoload",
+				"callback":       `MyVendor\\MyClass::run`,
+				"with-arguments": "@migrate --dry-run",
+				"cycle":          "@other-cycle",
+				"other-cycle":    []interface{}{"@cycle"},
+				"invalid":        []interface{}{1},
+			}
+		})
+
+		it("returns the command of a single script", func() {
+			Expect(scripts.Command("start")).To(Equal("php -S 0.0.0.0:8080 -t public"))
+		})
+
+		it("chains the commands of a list and inlines the referenced scripts", func() {
+			Expect(scripts.Command("serve")).To(Equal("export APP_ENV=prod && php bin/console doctrine:migrations:migrate && php bin/server.php"))
+		})
+
+		context("failure cases", func() {
+			it("returns an error for scripts which require composer", func() {
+				_, err := scripts.Command("composer")
+				Expect(err).To(MatchError(`script "composer" runs composer, which is not available at launch`))
+
+				_, err = scripts.Command("callback")
+				Expect(err).To(MatchError(`script "callback" calls the PHP method MyVendor\\MyClass::run, which requires composer`))
+
+				_, err = scripts.Command("with-arguments")
+				Expect(err).To(MatchError(`script "with-arguments" passes arguments to "@migrate --dry-run", which is not supported`))
+			})
+
+			it("returns an error for scripts referencing themselves", func() {
+				_, err := scripts.Command("cycle")
+				Expect(err).To(MatchError(`script "cycle" references itself`))
+			})
+
+			it("returns an error for missing or invalid scripts", func() {
+				_, err := scripts.Command("missing")
+				Expect(err).To(MatchError(`script "missing" not found`))
+
+				_, err = scripts.Command("invalid")
+				Expect(err).To(MatchError(`script "invalid" must only contain strings`))
+			})
+		})
+	})
+}
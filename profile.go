@@ -0,0 +1,84 @@
+package composer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// BuildProfileDefault runs Composer with its own defaults
+	BuildProfileDefault = "default"
+
+	// BuildProfileLarge tunes Composer for projects with huge dependency
+	// graphs, such as Magento or Drupal
+	BuildProfileLarge = "large"
+
+	// composerProcessTimeout is the environment variable read by Composer to
+	// limit the duration of processes such as git clones or scripts
+	// https://getcomposer.org/doc/06-config.md#process-timeout
+	composerProcessTimeout = "COMPOSER_PROCESS_TIMEOUT"
+
+	// largeProfileProcessTimeout is the process timeout of the large
+	// profile in seconds, instead of Composer's default of 300
+	largeProfileProcessTimeout = 1800
+
+	// largeProfileMaxParallelHttp is the number of parallel downloads of the
+	// large profile, instead of Composer's default of 12
+	largeProfileMaxParallelHttp = 24
+)
+
+// buildProfile is a set of settings for Composer, which are switched on at
+// once with "BP_COMPOSER_PROFILE".
+type buildProfile struct {
+	name string
+
+	// phpIni contains additional directives for the php.ini of Composer
+	phpIni []string
+
+	// env is added to the environment of all `composer` executions
+	env []string
+
+	// maxParallelHttp is the default number of parallel downloads, if it is
+	// not set explicitly. The default of networkSettings applies if it is 0.
+	maxParallelHttp int
+}
+
+// determineBuildProfile will check for env var "BP_COMPOSER_PROFILE". The
+// "large" profile removes the memory limit of Composer, raises its process
+// timeout and the number of parallel downloads. Any of these settings which
+// has been set explicitly, such as COMPOSER_PROCESS_TIMEOUT, takes
+// precedence.
+func determineBuildProfile(logger emitter) (buildProfile, error) {
+	name, found := os.LookupEnv(BpComposerProfile)
+	if !found || name == "" {
+		return buildProfile{name: BuildProfileDefault}, nil
+	}
+
+	switch name {
+	case BuildProfileDefault:
+		return buildProfile{name: BuildProfileDefault}, nil
+	case BuildProfileLarge:
+	default:
+		return buildProfile{}, fmt.Errorf("unsupported %s %q, supported are %q and %q", BpComposerProfile, name, BuildProfileDefault, BuildProfileLarge)
+	}
+
+	profile := buildProfile{
+		name:            BuildProfileLarge,
+		phpIni:          []string{"memory_limit = -1"},
+		maxParallelHttp: largeProfileMaxParallelHttp,
+	}
+
+	settings := []string{"memory_limit=-1"}
+
+	if _, found := os.LookupEnv(composerProcessTimeout); !found {
+		profile.env = append(profile.env, fmt.Sprintf("%s=%d", composerProcessTimeout, largeProfileProcessTimeout))
+		settings = append(settings, fmt.Sprintf("%s=%d", composerProcessTimeout, largeProfileProcessTimeout))
+	}
+
+	logger.Process("Using build profile '%s'", profile.name)
+	logger.Subprocess("%s", strings.Join(settings, ", "))
+	logger.Break()
+
+	return profile, nil
+}
@@ -0,0 +1,202 @@
+package composer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ProjectDescriptorFileName is the name of the project descriptor in the
+// application directory.
+// https://github.com/buildpacks/spec/blob/main/extensions/project-descriptor.md
+const ProjectDescriptorFileName = "project.toml"
+
+// projectConfigTable is the table in the project descriptor from which the
+// configuration of this buildpack is read.
+const projectConfigTable = "composer-install"
+
+// projectConfigSettings maps the keys of the `[composer-install]` table to
+// the environment variables they configure.
+var projectConfigSettings = map[string]string{
+	"install-options":              BpComposerInstallOptions,
+	"reinstall-options":            BpComposerReinstallOptions,
+	"install-global":               BpComposerInstallGlobal,
+	"run-composer-install":         runComposerInstallOnCacheEnv,
+	"deny-abandoned":               BpComposerDenyAbandoned,
+	"build-stamp":                  BpComposerBuildStamp,
+	"extra-cache-paths":            BpComposerExtraCachePaths,
+	"verify-integrity":             BpComposerVerifyIntegrity,
+	"sha256":                       BpComposerSHA256,
+	"fallback-version":             BpComposerFallbackVersion,
+	"sandbox":                      BpComposerSandbox,
+	"sandbox-writable-paths":       BpComposerSandboxWritablePaths,
+	"autoload-refresh":             BpComposerAutoloadRefresh,
+	"max-parallel-http":            BpComposerMaxParallelHttp,
+	"disable-http2":                BpComposerDisableHTTP2,
+	"disable-sbom":                 BpDisableSBOM,
+	"extensions-exclude":           BpComposerExtensionsExclude,
+	"extensions-include":           BpComposerExtensionsInclude,
+	"bootstrap-extensions":         BpComposerBootstrapExtensions,
+	"extensions-ini":               BpComposerExtensionsIni,
+	"support-bundle":               BpComposerSupportBundle,
+	"root-version":                 BpComposerRootVersion,
+	"provenance":                   BpComposerProvenance,
+	"provenance-tsa-url":           BpComposerProvenanceTSAURL,
+	"vendor-owner":                 BpComposerVendorOwner,
+	"vendor-normalize-permissions": BpComposerVendorNormalizePermissions,
+	"lock-snapshot":                BpComposerLockSnapshot,
+	"outdated-report":              BpComposerOutdatedReport,
+	"hermetic-tmpdir":              BpComposerHermeticTmpDir,
+	"disk-space-check":             BpComposerDiskSpaceCheck,
+	"allowed-hosts":                BpComposerAllowedHosts,
+	"validate-vendor-only":         BpComposerValidateVendorOnly,
+	"autodetect-processes":         BpComposerAutodetectProcesses,
+	"sbom-formats":                 BpSBOMFormats,
+	"profile":                      BpComposerProfile,
+	"synthesize":                   BpComposerSynthesize,
+	"php-ini-layer":                BpComposerPhpIniLayer,
+	"config":                       BpComposerConfig,
+	"preserve-vendor":              BpComposerPreserveVendor,
+	"dry-run-check":                BpComposerDryRunCheck,
+	"offline-refresh":              BpComposerOfflineRefresh,
+	"reproducible":                 BpComposerReproducible,
+	"package-denylist":             BpComposerPackageDenylist,
+	"package-allowlist":            BpComposerPackageAllowlist,
+	"license-policy":               BpComposerLicensePolicy,
+	"allowed-licenses":             BpComposerAllowedLicenses,
+	"autoloader-suffix":            BpComposerAutoloaderSuffix,
+	"cache-key-salt":               BpComposerCacheKeySalt,
+	"vendor-bin-install":           BpComposerVendorBinInstall,
+	"file":                         BpComposerFile,
+	"metrics-path":                 BpComposerMetricsPath,
+	"fund":                         BpComposerFund,
+	"audit":                        BpComposerAudit,
+	"cafile":                       BpComposerCAFile,
+	"capath":                       BpComposerCAPath,
+	"tls-min-version":              BpComposerTLSMinVersion,
+	"cache-home":                   BpComposerCacheHome,
+	"incremental-autoload":         BpComposerIncrementalAutoload,
+	"require-node":                 BpComposerRequireNode,
+	"cache-vcs":                    BpComposerCacheVCS,
+	"install-strategy":             BpComposerInstallStrategy,
+	"platform-php-policy":          BpComposerPlatformPhpPolicy,
+	"stability-policy":             BpComposerStabilityPolicy,
+	"stability-threshold":          BpComposerStabilityThreshold,
+	"file-mode":                    BpComposerFileMode,
+	"revalidate-stack":             BpComposerRevalidateStack,
+	"package-store":                BpComposerPackageStore,
+	"script-path-prepend":          BpComposerScriptPathPrepend,
+	"license-report":               BpComposerLicenseReport,
+	"sbom-php-extensions":          BpComposerSBOMPhpExtensions,
+	"show-suggests":                BpComposerShowSuggests,
+	"run-as":                       BpComposerRunAs,
+}
+
+// LoadProjectConfig reads the `[composer-install]` table from the project
+// descriptor in the given directory, e.g.
+//
+//	[composer-install]
+//	install-options = ["--no-dev", "--prefer-dist"]
+//	deny-abandoned = true
+//
+// Returns the configured values keyed by the name of the environment
+// variable they correspond to. Arrays are joined with spaces.
+// Returns an empty map if the project descriptor does not exist.
+func LoadProjectConfig(workingDir string) (map[string]string, error) {
+	var descriptor struct {
+		ComposerInstall map[string]interface{} `toml:"composer-install"`
+	}
+
+	_, err := toml.DecodeFile(filepath.Join(workingDir, ProjectDescriptorFileName), &descriptor)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]string{}, nil
+		}
+		return nil, fmt.Errorf("failed to parse %s: %w", ProjectDescriptorFileName, err)
+	}
+
+	config := map[string]string{}
+	for key, rawValue := range descriptor.ComposerInstall {
+		name, ok := projectConfigSettings[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown setting %q in [%s] of %s", key, projectConfigTable, ProjectDescriptorFileName)
+		}
+
+		value, err := projectConfigValue(rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %q in [%s] of %s: %w", key, projectConfigTable, ProjectDescriptorFileName, err)
+		}
+
+		config[name] = value
+	}
+
+	return config, nil
+}
+
+func projectConfigValue(rawValue interface{}) (string, error) {
+	switch value := rawValue.(type) {
+	case string:
+		return value, nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case int64:
+		return strconv.FormatInt(value, 10), nil
+	case []interface{}:
+		var values []string
+		for _, element := range value {
+			str, err := projectConfigValue(element)
+			if err != nil {
+				return "", err
+			}
+			values = append(values, str)
+		}
+		return strings.Join(values, " "), nil
+	default:
+		return "", fmt.Errorf("unsupported type %T", rawValue)
+	}
+}
+
+// applyProjectConfig sets the environment variables configured in the
+// project descriptor. Environment variables which have already been set,
+// e.g. by `pack build --env`, take precedence.
+// Returns the environment variables which have been set.
+func applyProjectConfig(logger emitter, workingDir string) (map[string]string, error) {
+	config, err := LoadProjectConfig(workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := map[string]string{}
+	if len(config) == 0 {
+		return applied, nil
+	}
+
+	var names []string
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	logger.Debug.Process("Reading configuration from %s", ProjectDescriptorFileName)
+	for _, name := range names {
+		if _, found := os.LookupEnv(name); found {
+			logger.Debug.Subprocess("Ignoring %s, it is set in the environment", name)
+			continue
+		}
+
+		logger.Debug.Subprocess("Setting %s=%q", name, config[name])
+		err = os.Setenv(name, config[name])
+		if err != nil { // untested
+			return nil, err
+		}
+		applied[name] = config[name]
+	}
+
+	return applied, nil
+}
@@ -0,0 +1,124 @@
+package composer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testProjectConfig(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("when project.toml does not exist", func() {
+		it("returns an empty config", func() {
+			config, err := composer.LoadProjectConfig(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config).To(BeEmpty())
+		})
+	})
+
+	context("when project.toml contains a [composer-install] table", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "project.toml"), []byte(`
+[_]
+schema-version = "0.2"
+
+[[io.buildpacks.build.env]]
+name = "BP_LOG_LEVEL"
+value = "DEBUG"
+
+[composer-install]
+install-options = ["--no-dev", "--prefer-dist"]
+install-global = "friendsofphp/php-cs-fixer"
+run-composer-install = false
+deny-abandoned = true
+`), os.ModePerm)).To(Succeed())
+		})
+
+		it("returns the settings keyed by environment variable", func() {
+			config, err := composer.LoadProjectConfig(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config).To(Equal(map[string]string{
+				"BP_COMPOSER_INSTALL_OPTIONS": "--no-dev --prefer-dist",
+				"BP_COMPOSER_INSTALL_GLOBAL":  "friendsofphp/php-cs-fixer",
+				"BP_RUN_COMPOSER_INSTALL":     "false",
+				"BP_COMPOSER_DENY_ABANDONED":  "true",
+			}))
+		})
+	})
+
+	context("when project.toml does not contain a [composer-install] table", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "project.toml"), []byte(`
+[_]
+schema-version = "0.2"
+`), os.ModePerm)).To(Succeed())
+		})
+
+		it("returns an empty config", func() {
+			config, err := composer.LoadProjectConfig(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config).To(BeEmpty())
+		})
+	})
+
+	context("failure cases", func() {
+		context("when project.toml is malformed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "project.toml"), []byte(`%%%`), os.ModePerm)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := composer.LoadProjectConfig(workingDir)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse project.toml")))
+			})
+		})
+
+		context("when a setting is unknown", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "project.toml"), []byte(`
+[composer-install]
+unknown-setting = true
+`), os.ModePerm)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := composer.LoadProjectConfig(workingDir)
+				Expect(err).To(MatchError(`unknown setting "unknown-setting" in [composer-install] of project.toml`))
+			})
+		})
+
+		context("when a setting has an unsupported type", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "project.toml"), []byte(`
+[composer-install]
+install-options = { no-dev = true }
+`), os.ModePerm)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := composer.LoadProjectConfig(workingDir)
+				Expect(err).To(MatchError(`invalid value for "install-options" in [composer-install] of project.toml: unsupported type map[string]interface {}`))
+			})
+		})
+	})
+}
@@ -0,0 +1,527 @@
+package composer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+const (
+	// ProvenanceFileName is the name of the file in the composer-packages
+	// layer containing the in-toto statement with the SLSA provenance of the
+	// installed dependencies.
+	ProvenanceFileName = "composer-provenance.intoto.json"
+
+	// ProvenanceTimestampFileName is the name of the file in the
+	// composer-packages layer containing the RFC 3161 timestamp response for
+	// the provenance statement.
+	ProvenanceTimestampFileName = ProvenanceFileName + ".tsr"
+
+	// ProvenanceBuildType identifies the format of the build definition in
+	// the provenance statement.
+	ProvenanceBuildType = "https://github.com/ninech/buildpack-composer-install/provenance/v1"
+
+	// DefaultPackagistURL is the repository used by Composer unless it has
+	// been disabled in composer.json
+	DefaultPackagistURL = "https://repo.packagist.org"
+
+	inTotoStatementType    = "https://in-toto.io/Statement/v1"
+	slsaProvenancePredType = "https://slsa.dev/provenance/v1"
+)
+
+// Timestamper requests RFC 3161 timestamps from a timestamp authority.
+//
+//go:generate faux --interface Timestamper --output fakes/timestamper.go
+type Timestamper interface {
+	// Timestamp requests a timestamp for the given SHA-256 digest from the
+	// timestamp authority at the given URL, and returns the DER-encoded
+	// timestamp response.
+	Timestamp(url string, digest []byte) ([]byte, error)
+}
+
+// ResourceDescriptor describes an artifact in the provenance statement.
+// https://github.com/in-toto/attestation/blob/main/spec/v1/resource_descriptor.md
+type ResourceDescriptor struct {
+	Name        string            `json:"name,omitempty"`
+	URI         string            `json:"uri,omitempty"`
+	Digest      map[string]string `json:"digest,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ProvenanceCommand is a `composer` command executed during the build.
+type ProvenanceCommand struct {
+	Args   []string `json:"args"`
+	Dir    string   `json:"dir"`
+	Result string   `json:"result"`
+}
+
+// ProvenanceStatement is an in-toto statement with a SLSA provenance
+// predicate, describing how the installed dependencies have been built.
+// https://slsa.dev/spec/v1.0/provenance
+type ProvenanceStatement struct {
+	Type          string               `json:"_type"`
+	Subject       []ResourceDescriptor `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     struct {
+		BuildDefinition struct {
+			BuildType          string `json:"buildType"`
+			ExternalParameters struct {
+				InstallOptions []string `json:"installOptions"`
+			} `json:"externalParameters"`
+			InternalParameters struct {
+				Stack    string              `json:"stack,omitempty"`
+				Commands []ProvenanceCommand `json:"commands"`
+			} `json:"internalParameters"`
+			ResolvedDependencies []ResourceDescriptor `json:"resolvedDependencies"`
+		} `json:"buildDefinition"`
+		RunDetails struct {
+			Builder struct {
+				ID string `json:"id"`
+			} `json:"builder"`
+			Metadata struct {
+				StartedOn  string `json:"startedOn"`
+				FinishedOn string `json:"finishedOn"`
+			} `json:"metadata"`
+		} `json:"runDetails"`
+	} `json:"predicate"`
+}
+
+// writeProvenanceIfRequired will check for env var "BP_COMPOSER_PROVENANCE".
+// If set to true, a provenance statement describing the inputs of the build,
+// the executed commands and the digest of the installed dependencies is
+// written into the composer-packages layer, next to its SBOM.
+//
+// If "BP_COMPOSER_PROVENANCE_TSA_URL" is set as well, the statement is
+// timestamped by the given RFC 3161 timestamp authority, so that it can be
+// proven that the statement existed at that time.
+func writeProvenanceIfRequired(
+	logger emitter,
+	context packit.BuildContext,
+	commandLog *CommandLog,
+	timestamper Timestamper,
+	composerPackagesLayer packit.Layer,
+	composerJsonPath string,
+	composerLockPath string,
+	installOptions []InstallOption,
+	calculator Calculator,
+	startedOn time.Time,
+	clock chronos.Clock) error {
+	enabled, err := lookupBoolEnv(BpComposerProvenance, false)
+	if err != nil {
+		return err
+	}
+
+	// a cached layer may contain the statement of a previous build, which
+	// does not describe this build
+	for _, file := range []string{ProvenanceFileName, ProvenanceTimestampFileName} {
+		err = os.RemoveAll(filepath.Join(composerPackagesLayer.Path, file))
+		if err != nil { // untested
+			return err
+		}
+	}
+
+	if !enabled {
+		return nil
+	}
+
+	logger.Process("Writing %s", ProvenanceFileName)
+
+	var statement ProvenanceStatement
+	statement.Type = inTotoStatementType
+	statement.PredicateType = slsaProvenancePredType
+
+	layerVendorDir := filepath.Join(composerPackagesLayer.Path, "vendor")
+	if exists, err := fs.Exists(layerVendorDir); err != nil {
+		return err
+	} else if exists {
+		checksum, err := calculator.Sum(layerVendorDir)
+		if err != nil { // untested
+			return err
+		}
+
+		statement.Subject = append(statement.Subject, ResourceDescriptor{
+			Name:   filepath.Join(ComposerPackagesLayerName, "vendor"),
+			Digest: map[string]string{"sha256": checksum},
+		})
+	}
+
+	buildDefinition := &statement.Predicate.BuildDefinition
+	buildDefinition.BuildType = ProvenanceBuildType
+	buildDefinition.ExternalParameters.InstallOptions = []string{}
+	for _, option := range installOptions {
+		buildDefinition.ExternalParameters.InstallOptions = append(buildDefinition.ExternalParameters.InstallOptions, option.Value)
+	}
+
+	buildDefinition.InternalParameters.Stack = context.Stack
+	buildDefinition.InternalParameters.Commands = []ProvenanceCommand{}
+	for _, entry := range commandLog.Entries() {
+		result := "success"
+		if entry.Err != nil {
+			result = entry.Err.Error()
+		}
+
+		buildDefinition.InternalParameters.Commands = append(buildDefinition.InternalParameters.Commands, ProvenanceCommand{
+			Args:   redactComposerArgs(entry.Args),
+			Dir:    entry.Dir,
+			Result: result,
+		})
+	}
+
+	buildDefinition.ResolvedDependencies, err = resolvedDependencies(composerJsonPath, composerLockPath, composerPackagesLayer)
+	if err != nil {
+		return err
+	}
+
+	runDetails := &statement.Predicate.RunDetails
+	runDetails.Builder.ID = fmt.Sprintf("%s@%s", context.BuildpackInfo.ID, context.BuildpackInfo.Version)
+	runDetails.Metadata.StartedOn = startedOn.UTC().Format(time.RFC3339)
+	runDetails.Metadata.FinishedOn = clock.Now().UTC().Format(time.RFC3339)
+
+	content, err := json.MarshalIndent(statement, "", "  ")
+	if err != nil { // untested
+		return err
+	}
+	content = append(content, '\n')
+
+	err = os.WriteFile(filepath.Join(composerPackagesLayer.Path, ProvenanceFileName), content, 0644)
+	if err != nil { // untested
+		return err
+	}
+
+	logger.Subprocess("Subjects: %d", len(statement.Subject))
+	logger.Subprocess("Resolved dependencies: %d", len(buildDefinition.ResolvedDependencies))
+
+	tsaURL, found := os.LookupEnv(BpComposerProvenanceTSAURL)
+	if !found || tsaURL == "" {
+		logger.Break()
+		return nil
+	}
+
+	logger.Subprocess("Requesting timestamp from %s", tsaURL)
+	digest := sha256.Sum256(content)
+	response, err := timestamper.Timestamp(tsaURL, digest[:])
+	if err != nil {
+		return fmt.Errorf("failed to timestamp %s: %w", ProvenanceFileName, err)
+	}
+
+	err = os.WriteFile(filepath.Join(composerPackagesLayer.Path, ProvenanceTimestampFileName), response, 0644)
+	if err != nil { // untested
+		return err
+	}
+
+	logger.Subprocess("Wrote %s", ProvenanceTimestampFileName)
+	logger.Break()
+
+	return nil
+}
+
+// resolvedDependencies returns the inputs of `composer install`: the
+// `composer.lock`, the repositories configured in `composer.json` and the
+// locked packages with the URL and checksum of their dist archive.
+func resolvedDependencies(composerJsonPath, composerLockPath string, composerPackagesLayer packit.Layer) ([]ResourceDescriptor, error) {
+	dependencies := []ResourceDescriptor{}
+
+	if composerLockSHA, _ := composerPackagesLayer.Metadata["composer-lock-sha"].(string); composerLockSHA != "" {
+		dependencies = append(dependencies, ResourceDescriptor{
+			Name:   filepath.Base(composerLockPath),
+			Digest: map[string]string{"sha256": composerLockSHA},
+		})
+	}
+
+	repositories, err := composerRepositoryURLs(composerJsonPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, url := range repositories {
+		dependencies = append(dependencies, ResourceDescriptor{
+			URI:         url,
+			Annotations: map[string]string{"type": "repository"},
+		})
+	}
+
+	content, err := readComposerFile(composerLockPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return dependencies, nil
+		}
+		return nil, err
+	}
+
+	type lockedPackage struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+		Dist    struct {
+			URL    string `json:"url"`
+			Shasum string `json:"shasum"`
+		} `json:"dist"`
+	}
+
+	var composerLock struct {
+		Packages    []lockedPackage `json:"packages"`
+		PackagesDev []lockedPackage `json:"packages-dev"`
+	}
+
+	err = json.Unmarshal(content, &composerLock)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range append(composerLock.Packages, composerLock.PackagesDev...) {
+		dependency := ResourceDescriptor{
+			Name:        p.Name,
+			URI:         p.Dist.URL,
+			Annotations: map[string]string{"version": p.Version},
+		}
+
+		if p.Dist.Shasum != "" {
+			dependency.Digest = map[string]string{"sha1": p.Dist.Shasum}
+		}
+
+		dependencies = append(dependencies, dependency)
+	}
+
+	return dependencies, nil
+}
+
+// composerRepositoryURLs returns the URLs of the repositories configured in
+// `composer.json`, followed by packagist.org unless it has been disabled.
+// https://getcomposer.org/doc/05-repositories.md
+func composerRepositoryURLs(composerJsonPath string) ([]string, error) {
+	content, err := readComposerFile(composerJsonPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []string{DefaultPackagistURL}, nil
+		}
+		return nil, err
+	}
+
+	var composerJson struct {
+		Repositories json.RawMessage `json:"repositories"`
+	}
+
+	err = json.Unmarshal(content, &composerJson)
+	if err != nil {
+		return nil, err
+	}
+
+	// repositories can be given as a list, or as an object keyed by name
+	var repositories []interface{}
+	var namedRepositories map[string]interface{}
+	if json.Unmarshal(composerJson.Repositories, &repositories) != nil {
+		if json.Unmarshal(composerJson.Repositories, &namedRepositories) == nil {
+			for _, repository := range namedRepositories {
+				repositories = append(repositories, repository)
+			}
+		}
+	}
+
+	packagist := true
+	var urls []string
+	for _, repository := range repositories {
+		switch value := repository.(type) {
+		case map[string]interface{}:
+			if disabled, ok := value["packagist.org"].(bool); ok && !disabled {
+				packagist = false
+			}
+			if url, ok := value["url"].(string); ok && url != "" {
+				urls = append(urls, url)
+			}
+		case bool:
+			// {"packagist.org": false} in the object notation
+			if !value {
+				packagist = false
+			}
+		}
+	}
+
+	if packagist {
+		urls = append(urls, DefaultPackagistURL)
+	}
+
+	return urls, nil
+}
+
+// RFC3161Timestamper requests timestamps over HTTP as described in
+// https://www.rfc-editor.org/rfc/rfc3161#section-3.4. The response is only
+// checked against the request, neither the signature of the token nor the
+// certificate of the timestamp authority are verified.
+type RFC3161Timestamper struct {
+	client *http.Client
+}
+
+func NewRFC3161Timestamper() RFC3161Timestamper {
+	return RFC3161Timestamper{
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	Nonce          *big.Int `asn1:"optional"`
+	CertReq        bool     `asn1:"optional"`
+}
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []string       `asn1:"optional"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+// the timestamp token is a CMS SignedData, which contains the TSTInfo, see
+// https://www.rfc-editor.org/rfc/rfc3161#section-2.4.2
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+
+	// Content is explicitly tagged, its Bytes are the content itself
+	Content asn1.RawValue
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContentInfo struct {
+		EContentType asn1.ObjectIdentifier
+		EContent     []byte `asn1:"explicit,optional,tag:0"`
+	}
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+	Accuracy       struct {
+		Seconds int `asn1:"optional"`
+		Millis  int `asn1:"optional,tag:0"`
+		Micros  int `asn1:"optional,tag:1"`
+	} `asn1:"optional"`
+	Ordering bool     `asn1:"optional"`
+	Nonce    *big.Int `asn1:"optional"`
+}
+
+// oidSHA256 identifies SHA-256 in the timestamp request
+var oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+
+func (t RFC3161Timestamper) Timestamp(url string, digest []byte) ([]byte, error) {
+	// the nonce ties the response to this request
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil { // untested
+		return nil, err
+	}
+
+	request, err := asn1.Marshal(timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{
+				Algorithm:  oidSHA256,
+				Parameters: asn1.NullRawValue,
+			},
+			HashedMessage: digest,
+		},
+		Nonce: nonce,
+		// include the certificate of the timestamp authority, so that the
+		// response can be verified on its own
+		CertReq: true,
+	})
+	if err != nil { // untested
+		return nil, err
+	}
+
+	response, err := t.client.Post(url, "application/timestamp-query", bytes.NewReader(request))
+	if err != nil {
+		return nil, fmt.Errorf("failed to request timestamp from %s: %w", url, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to request timestamp from %s: unexpected status %s", url, response.Status)
+	}
+
+	content, err := io.ReadAll(response.Body)
+	if err != nil { // untested
+		return nil, err
+	}
+
+	var resp timeStampResp
+	_, err = asn1.Unmarshal(content, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timestamp response from %s: %w", url, err)
+	}
+
+	// 0 is "granted", 1 is "grantedWithMods"
+	if resp.Status.Status > 1 {
+		return nil, fmt.Errorf("timestamp request rejected by %s with status %d: %s", url, resp.Status.Status, strings.Join(resp.Status.StatusString, ", "))
+	}
+
+	if len(resp.TimeStampToken.FullBytes) == 0 {
+		return nil, fmt.Errorf("timestamp response from %s does not contain a timestamp token", url)
+	}
+
+	info, err := parseTSTInfo(resp.TimeStampToken.FullBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timestamp token from %s: %w", url, err)
+	}
+
+	if info.Nonce == nil || info.Nonce.Cmp(nonce) != 0 {
+		return nil, fmt.Errorf("timestamp token from %s does not match the nonce of the request", url)
+	}
+
+	if !info.MessageImprint.HashAlgorithm.Algorithm.Equal(oidSHA256) || !bytes.Equal(info.MessageImprint.HashedMessage, digest) {
+		return nil, fmt.Errorf("timestamp token from %s does not match the requested digest", url)
+	}
+
+	return content, nil
+}
+
+// parseTSTInfo returns the TSTInfo of the given timestamp token. The
+// signature of the token is not verified.
+func parseTSTInfo(token []byte) (tstInfo, error) {
+	var info contentInfo
+	_, err := asn1.Unmarshal(token, &info)
+	if err != nil {
+		return tstInfo{}, err
+	}
+
+	var signed signedData
+	_, err = asn1.Unmarshal(info.Content.Bytes, &signed)
+	if err != nil {
+		return tstInfo{}, err
+	}
+
+	var tst tstInfo
+	_, err = asn1.Unmarshal(signed.EncapContentInfo.EContent, &tst)
+	if err != nil {
+		return tstInfo{}, err
+	}
+
+	return tst, nil
+}
@@ -0,0 +1,234 @@
+package composer_test
+
+import (
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+type timestampStatusInfo struct {
+	Status       int
+	StatusString []string `asn1:"optional"`
+}
+
+type timestampMessageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+// timestampToken returns a timestamp token without signature, whose TSTInfo
+// contains the given message imprint and nonce.
+func timestampToken(imprint timestampMessageImprint, nonce *big.Int) (asn1.RawValue, error) {
+	tstInfo, err := asn1.Marshal(struct {
+		Version        int
+		Policy         asn1.ObjectIdentifier
+		MessageImprint timestampMessageImprint
+		SerialNumber   *big.Int
+		GenTime        time.Time `asn1:"generalized"`
+		Nonce          *big.Int
+	}{
+		Version:        1,
+		Policy:         asn1.ObjectIdentifier{1, 2, 3, 4},
+		MessageImprint: imprint,
+		SerialNumber:   big.NewInt(42),
+		GenTime:        time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Nonce:          nonce,
+	})
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+
+	type encapContentInfo struct {
+		EContentType asn1.ObjectIdentifier
+		EContent     []byte `asn1:"explicit,tag:0"`
+	}
+
+	signedData, err := asn1.Marshal(struct {
+		Version          int
+		DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+		EncapContentInfo encapContentInfo
+		SignerInfos      []asn1.RawValue `asn1:"set"`
+	}{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{imprint.HashAlgorithm},
+		EncapContentInfo: encapContentInfo{
+			EContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4},
+			EContent:     tstInfo,
+		},
+	})
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+
+	token, err := asn1.Marshal(struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}{
+		ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2},
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedData},
+	})
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+
+	return asn1.RawValue{FullBytes: token}, nil
+}
+
+func testRFC3161Timestamper(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		server      *httptest.Server
+		timestamper composer.RFC3161Timestamper
+		digest      [32]byte
+
+		request struct {
+			Version        int
+			MessageImprint timestampMessageImprint
+			Nonce          *big.Int `asn1:"optional"`
+			CertReq        bool     `asn1:"optional"`
+		}
+		contentType string
+
+		granted      []byte
+		rejected     []byte
+		missingToken []byte
+	)
+
+	// grant returns a granted response with a token for the given message
+	// imprint and nonce
+	grant := func(imprint timestampMessageImprint, nonce *big.Int) []byte {
+		token, err := timestampToken(imprint, nonce)
+		Expect(err).NotTo(HaveOccurred())
+
+		response, err := asn1.Marshal(struct {
+			Status timestampStatusInfo
+			Token  asn1.RawValue
+		}{
+			Status: timestampStatusInfo{Status: 0},
+			Token:  token,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		return response
+	}
+
+	it.Before(func() {
+		var err error
+		rejected, err = asn1.Marshal(struct {
+			Status timestampStatusInfo
+		}{
+			Status: timestampStatusInfo{Status: 2, StatusString: []string{"bad request"}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		missingToken, err = asn1.Marshal(struct {
+			Status timestampStatusInfo
+		}{
+			Status: timestampStatusInfo{Status: 0},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			contentType = req.Header.Get("Content-Type")
+
+			body, err := io.ReadAll(req.Body)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = asn1.Unmarshal(body, &request)
+			Expect(err).NotTo(HaveOccurred())
+
+			switch req.URL.Path {
+			case "/granted":
+				granted = grant(request.MessageImprint, request.Nonce)
+				_, _ = w.Write(granted)
+			case "/other-nonce":
+				_, _ = w.Write(grant(request.MessageImprint, new(big.Int).Add(request.Nonce, big.NewInt(1))))
+			case "/other-digest":
+				otherDigest := sha256.Sum256([]byte("other-statement"))
+				_, _ = w.Write(grant(timestampMessageImprint{HashAlgorithm: request.MessageImprint.HashAlgorithm, HashedMessage: otherDigest[:]}, request.Nonce))
+			case "/rejected":
+				_, _ = w.Write(rejected)
+			case "/missing-token":
+				_, _ = w.Write(missingToken)
+			case "/invalid":
+				fmt.Fprint(w, "not a timestamp response")
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		timestamper = composer.NewRFC3161Timestamper()
+		digest = sha256.Sum256([]byte("some-statement"))
+	})
+
+	it.After(func() {
+		server.Close()
+	})
+
+	it("requests a timestamp for the SHA-256 digest", func() {
+		response, err := timestamper.Timestamp(server.URL+"/granted", digest[:])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response).To(Equal(granted))
+
+		Expect(contentType).To(Equal("application/timestamp-query"))
+		Expect(request.Version).To(Equal(1))
+		Expect(request.MessageImprint.HashAlgorithm.Algorithm).To(Equal(asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}))
+		Expect(request.MessageImprint.HashedMessage).To(Equal(digest[:]))
+		Expect(request.Nonce).NotTo(BeNil())
+		Expect(request.CertReq).To(BeTrue())
+	})
+
+	context("when the timestamp token does not match the nonce", func() {
+		it("returns an error", func() {
+			_, err := timestamper.Timestamp(server.URL+"/other-nonce", digest[:])
+			Expect(err).To(MatchError(fmt.Sprintf("timestamp token from %s/other-nonce does not match the nonce of the request", server.URL)))
+		})
+	})
+
+	context("when the timestamp token does not match the digest", func() {
+		it("returns an error", func() {
+			_, err := timestamper.Timestamp(server.URL+"/other-digest", digest[:])
+			Expect(err).To(MatchError(fmt.Sprintf("timestamp token from %s/other-digest does not match the requested digest", server.URL)))
+		})
+	})
+
+	context("when the request is rejected", func() {
+		it("returns an error", func() {
+			_, err := timestamper.Timestamp(server.URL+"/rejected", digest[:])
+			Expect(err).To(MatchError(fmt.Sprintf("timestamp request rejected by %s/rejected with status 2: bad request", server.URL)))
+		})
+	})
+
+	context("when the response does not contain a timestamp token", func() {
+		it("returns an error", func() {
+			_, err := timestamper.Timestamp(server.URL+"/missing-token", digest[:])
+			Expect(err).To(MatchError(fmt.Sprintf("timestamp response from %s/missing-token does not contain a timestamp token", server.URL)))
+		})
+	})
+
+	context("when the response cannot be parsed", func() {
+		it("returns an error", func() {
+			_, err := timestamper.Timestamp(server.URL+"/invalid", digest[:])
+			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("failed to parse timestamp response from %s/invalid", server.URL))))
+		})
+	})
+
+	context("when the timestamp authority returns an unexpected status", func() {
+		it("returns an error", func() {
+			_, err := timestamper.Timestamp(server.URL+"/unknown", digest[:])
+			Expect(err).To(MatchError(fmt.Sprintf("failed to request timestamp from %s/unknown: unexpected status 404 Not Found", server.URL)))
+		})
+	})
+}
@@ -0,0 +1,179 @@
+package composer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// reproducibleModTime is the modification time of all files, unless
+// "SOURCE_DATE_EPOCH" is set. It is the same time the lifecycle uses for the
+// files of exported layers.
+var reproducibleModTime = time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC)
+
+// classMapStartPattern matches the start of the class map in the autoload
+// files generated by Composer, i.e. `vendor/composer/autoload_classmap.php`
+// and `vendor/composer/autoload_static.php`.
+var classMapStartPattern = regexp.MustCompile(`^\s*(return array\(|public static \$classMap = array \()$`)
+
+// classMapEntryPattern matches a single entry of a class map, e.g.
+// `'Foo\\Bar' => $vendorDir . '/foo/bar/src/Bar.php',`
+var classMapEntryPattern = regexp.MustCompile(`^\s*'[^']*' => .*,$`)
+
+// SortClassMaps sorts the entries of the class maps in the given autoload
+// file, which only depend on the classes, and not on the order in which the
+// files have been scanned. Class maps whose entries span multiple lines are
+// left as they are.
+func SortClassMaps(content []byte) []byte {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+
+	for i := 0; i < len(lines); i++ {
+		if !classMapStartPattern.Match(bytes.TrimRight(lines[i], "\n")) {
+			continue
+		}
+
+		start := i + 1
+		end := start
+		for end < len(lines) && classMapEntryPattern.Match(bytes.TrimRight(lines[end], "\n")) {
+			end++
+		}
+
+		if end == len(lines) || !bytes.HasPrefix(bytes.TrimSpace(lines[end]), []byte(")")) {
+			continue
+		}
+
+		entries := lines[start:end]
+		sort.SliceStable(entries, func(a, b int) bool {
+			return bytes.Compare(entries[a], entries[b]) < 0
+		})
+		i = end
+	}
+
+	return bytes.Join(lines, nil)
+}
+
+// NormalizeForReproducibility sets the permissions of all files and
+// directories in the given directory to 0755 for directories and executable
+// files and to 0644 for all other files, and their modification time to the
+// given time. The class maps of Composer's autoload files are sorted.
+// Symlinks are left as they are.
+//
+// Returns the number of normalized files and directories.
+func NormalizeForReproducibility(dir string, modTime time.Time) (int, error) {
+	var normalized []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil { // untested
+			return err
+		}
+
+		mode := fs.FileMode(0644)
+		if info.IsDir() || info.Mode().Perm()&0111 != 0 {
+			mode = 0755
+		}
+
+		err = os.Chmod(path, mode)
+		if err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() && filepath.Base(filepath.Dir(path)) == "composer" &&
+			(entry.Name() == "autoload_classmap.php" || entry.Name() == "autoload_static.php") {
+			content, err := os.ReadFile(path)
+			if err != nil { // untested
+				return err
+			}
+
+			err = os.WriteFile(path, SortClassMaps(content), mode)
+			if err != nil { // untested
+				return err
+			}
+		}
+
+		normalized = append(normalized, path)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// directories are changed once all files in them have been written
+	for i := len(normalized) - 1; i >= 0; i-- {
+		err = os.Chtimes(normalized[i], modTime, modTime)
+		if err != nil { // untested
+			return 0, err
+		}
+	}
+
+	return len(normalized), nil
+}
+
+// lookupReproducibility will check for env var "BP_COMPOSER_REPRODUCIBLE",
+// and returns whether it is set to true, and the modification time of the
+// normalized files, which is taken from "SOURCE_DATE_EPOCH" if set. It is
+// checked at the start of the build, as the SBOM formatter fails on an
+// invalid "SOURCE_DATE_EPOCH" with an error of its own.
+// https://reproducible-builds.org/docs/source-date-epoch/
+func lookupReproducibility() (bool, time.Time, error) {
+	enabled, err := lookupBoolEnv(BpComposerReproducible, false)
+	if err != nil || !enabled {
+		return false, time.Time{}, err
+	}
+
+	modTime := reproducibleModTime
+	if value, found := os.LookupEnv(SourceDateEpoch); found && value != "" {
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false, time.Time{}, fmt.Errorf("%s must be a number of seconds, found %q", SourceDateEpoch, value)
+		}
+		modTime = time.Unix(seconds, 0).UTC()
+	}
+
+	return true, modTime, nil
+}
+
+// normalizeForReproducibilityIfRequired will check for env var
+// "BP_COMPOSER_REPRODUCIBLE". If set to true, the given directories are
+// normalized, so that the same inputs result in the same layers on every
+// machine, see lookupReproducibility.
+func normalizeForReproducibilityIfRequired(logger emitter, dirs ...string) error {
+	enabled, modTime, err := lookupReproducibility()
+	if err != nil || !enabled {
+		return err
+	}
+
+	logger.Process("Normalizing files for reproducible layers")
+	logger.Subprocess("Modification time: %s", modTime.Format(time.RFC3339))
+
+	for _, dir := range dirs {
+		if _, err := os.Lstat(dir); errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil { // untested
+			return err
+		}
+
+		count, err := NormalizeForReproducibility(dir, modTime)
+		if err != nil {
+			return err
+		}
+		logger.Subprocess("Normalized %d file(s) in %s", count, dir)
+	}
+	logger.Break()
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package composer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testReproducible(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("SortClassMaps", func() {
+		it("sorts the entries of the class maps", func() {
+			Expect(string(composer.SortClassMaps([]byte(`<?php
+
+// autoload_classmap.php @generated by Composer
+
+$vendorDir = dirname(__DIR__);
+$baseDir = dirname($vendorDir);
+
+return array(
+    'Foo\\Qux' => $vendorDir . '/foo/qux/src/Qux.php',
+    'Composer\\InstalledVersions' => $vendorDir . '/composer/InstalledVersions.php',
+    'Foo\\Bar' => $vendorDir . '/foo/bar/src/Bar.php',
+);
+`)))).To(Equal(`<?php
+
+// autoload_classmap.php @generated by Composer
+
+$vendorDir = dirname(__DIR__);
+$baseDir = dirname($vendorDir);
+
+return array(
+    'Composer\\InstalledVersions' => $vendorDir . '/composer/InstalledVersions.php',
+    'Foo\\Bar' => $vendorDir . '/foo/bar/src/Bar.php',
+    'Foo\\Qux' => $vendorDir . '/foo/qux/src/Qux.php',
+);
+`))
+		})
+
+		it("only sorts the class map of the static autoloader", func() {
+			Expect(string(composer.SortClassMaps([]byte(`<?php
+class ComposerStaticInit
+{
+    public static $files = array (
+        'b' => __DIR__ . '/..' . '/b/bootstrap.php',
+        'a' => __DIR__ . '/..' . '/a/bootstrap.php',
+    );
+
+    public static $classMap = array (
+        'Foo\\Qux' => __DIR__ . '/..' . '/foo/qux/src/Qux.php',
+        'Foo\\Bar' => __DIR__ . '/..' . '/foo/bar/src/Bar.php',
+    );
+}
+`)))).To(Equal(`<?php
+class ComposerStaticInit
+{
+    public static $files = array (
+        'b' => __DIR__ . '/..' . '/b/bootstrap.php',
+        'a' => __DIR__ . '/..' . '/a/bootstrap.php',
+    );
+
+    public static $classMap = array (
+        'Foo\\Bar' => __DIR__ . '/..' . '/foo/bar/src/Bar.php',
+        'Foo\\Qux' => __DIR__ . '/..' . '/foo/qux/src/Qux.php',
+    );
+}
+`))
+		})
+
+		it("leaves class maps with entries spanning multiple lines as they are", func() {
+			content := `return array(
+    'Foo\\Qux' => $vendorDir . '/foo/qux/src/Qux.php',
+    'Foo\\Bar' => array(
+        $vendorDir . '/foo/bar/src/Bar.php',
+    ),
+);
+`
+			Expect(string(composer.SortClassMaps([]byte(content)))).To(Equal(content))
+		})
+	})
+
+	context("NormalizeForReproducibility", func() {
+		var dir string
+
+		it.Before(func() {
+			var err error
+			dir, err = os.MkdirTemp("", "layer")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.MkdirAll(filepath.Join(dir, "vendor", "composer"), 0700)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(dir, "vendor", "bin"), 0775)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "vendor", "autoload.php"), []byte("<?php"), 0664)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "vendor", "bin", "phpunit"), []byte("#!/usr/bin/env php"), 0700)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "vendor", "composer", "autoload_classmap.php"), []byte(`return array(
+    'B' => $vendorDir . '/b.php',
+    'A' => $vendorDir . '/a.php',
+);
+`), 0600)).To(Succeed())
+			Expect(os.Symlink("../autoload.php", filepath.Join(dir, "vendor", "bin", "link"))).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		it("normalizes the permissions, modification times and class maps", func() {
+			modTime := time.Date(2020, time.February, 3, 4, 5, 6, 0, time.UTC)
+
+			count, err := composer.NormalizeForReproducibility(dir, modTime)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(Equal(7))
+
+			for path, mode := range map[string]os.FileMode{
+				"":                                      0755,
+				"vendor":                                0755,
+				"vendor/bin":                            0755,
+				"vendor/composer":                       0755,
+				"vendor/autoload.php":                   0644,
+				"vendor/bin/phpunit":                    0755,
+				"vendor/composer/autoload_classmap.php": 0644,
+			} {
+				info, err := os.Stat(filepath.Join(dir, path))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.Mode().Perm()).To(Equal(mode), path)
+				Expect(info.ModTime().UTC()).To(Equal(modTime), path)
+			}
+
+			info, err := os.Lstat(filepath.Join(dir, "vendor", "bin", "link"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.ModTime().UTC()).NotTo(Equal(modTime))
+
+			content, err := os.ReadFile(filepath.Join(dir, "vendor", "composer", "autoload_classmap.php"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal(`return array(
+    'A' => $vendorDir . '/a.php',
+    'B' => $vendorDir . '/b.php',
+);
+`))
+		})
+	})
+}
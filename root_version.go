@@ -0,0 +1,96 @@
+package composer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	// composerRootVersion is the environment variable read by Composer to
+	// determine the version of the root package, if it cannot be guessed,
+	// e.g. because the `.git` directory is not part of the build
+	// https://getcomposer.org/doc/03-cli.md#composer-root-version
+	composerRootVersion = "COMPOSER_ROOT_VERSION"
+
+	// GitVersionFileName is the name of a file in the working directory
+	// containing the version of the application, e.g. written by CI
+	GitVersionFileName = ".git-version"
+)
+
+// determineComposerRootVersion will check for env var
+// "BP_COMPOSER_ROOT_VERSION". The version of the root package is taken from
+// the first of:
+//   - COMPOSER_ROOT_VERSION, which is already part of the environment
+//   - BP_COMPOSER_ROOT_VERSION
+//   - the `.git-version` file in the working directory
+//   - the version of the project in project.toml
+//
+// Returns the environment to be added to all `composer` executions.
+func determineComposerRootVersion(logger emitter, workingDir string) ([]string, error) {
+	if version, found := os.LookupEnv(composerRootVersion); found {
+		logger.Process("Using %s %q from the environment", composerRootVersion, version)
+		logger.Break()
+		return nil, nil
+	}
+
+	version, source, err := findComposerRootVersion(workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if version == "" {
+		return nil, nil
+	}
+
+	logger.Process("Setting %s to %q from %s", composerRootVersion, version, source)
+	logger.Break()
+
+	return []string{fmt.Sprintf("%s=%s", composerRootVersion, version)}, nil
+}
+
+func findComposerRootVersion(workingDir string) (version string, source string, err error) {
+	if version, found := os.LookupEnv(BpComposerRootVersion); found && version != "" {
+		return version, BpComposerRootVersion, nil
+	}
+
+	content, err := os.ReadFile(filepath.Join(workingDir, GitVersionFileName))
+	if err == nil {
+		if version := strings.TrimSpace(string(content)); version != "" {
+			return version, GitVersionFileName, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", "", err
+	}
+
+	// the project version is "[_] version" since version 0.2 of the project
+	// descriptor and "[project] version" before
+	var descriptor struct {
+		Underscore struct {
+			Version string `toml:"version"`
+		} `toml:"_"`
+		Project struct {
+			Version string `toml:"version"`
+		} `toml:"project"`
+	}
+
+	_, err = toml.DecodeFile(filepath.Join(workingDir, ProjectDescriptorFileName), &descriptor)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", "", nil
+		}
+		return "", "", fmt.Errorf("failed to parse %s: %w", ProjectDescriptorFileName, err)
+	}
+
+	for _, version := range []string{descriptor.Underscore.Version, descriptor.Project.Version} {
+		if version != "" {
+			return version, ProjectDescriptorFileName, nil
+		}
+	}
+
+	return "", "", nil
+}
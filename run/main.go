@@ -6,10 +6,10 @@ import (
 	"github.com/paketo-buildpacks/composer"
 	"github.com/paketo-buildpacks/packit/v2"
 	"github.com/paketo-buildpacks/packit/v2/chronos"
-	"github.com/paketo-buildpacks/packit/v2/fs"
 	"github.com/paketo-buildpacks/packit/v2/pexec"
 	"github.com/paketo-buildpacks/packit/v2/sbom"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
 )
 
 type Generator struct{}
@@ -27,19 +27,34 @@ func main() {
 	installExec := pexec.NewExecutable("composer")
 	globalExec := pexec.NewExecutable("composer")
 	checkPlatformReqsExec := pexec.NewExecutable("composer")
+	versionExec := pexec.NewExecutable("composer")
+	outdatedExec := pexec.NewExecutable("composer")
+	licensesExec := pexec.NewExecutable("composer")
+	phpVersionExec := pexec.NewExecutable("php")
 
 	packit.Run(
 		composer.Detect(logEmitter, phpVersionResolver),
-		composer.Build(
-			logEmitter,
-			options,
-			configExec,
-			installExec,
-			globalExec,
-			checkPlatformReqsExec,
-			Generator{},
-			os.Getenv("PATH"),
-			fs.NewChecksumCalculator(),
-			chronos.DefaultClock),
+		composer.Build(composer.BuildDependencies{
+			Logger:                composer.NewEmitterLogger(logEmitter),
+			InstallOptions:        options,
+			ComposerConfigExec:    configExec,
+			ComposerInstallExec:   installExec,
+			ComposerGlobalExec:    globalExec,
+			CheckPlatformReqsExec: checkPlatformReqsExec,
+			ComposerVersionExec:   versionExec,
+			ComposerOutdatedExec:  outdatedExec,
+			ComposerLicensesExec:  licensesExec,
+			PhpVersionExec:        phpVersionExec,
+			ComposerDownloader:    composer.NewPharDownloader(composer.DefaultComposerDownloadURL),
+			BindingResolver:       servicebindings.NewResolver(),
+			Timestamper:           composer.NewRFC3161Timestamper(),
+			DiskSpace:             composer.NewStatfsDiskSpace(),
+			FileSystem:            composer.NewOSFileSystem(),
+			SpanExporter:          composer.NewOTLPSpanExporter(),
+			SBOMGenerator:         Generator{},
+			Path:                  os.Getenv("PATH"),
+			Calculator:            composer.NewParallelChecksumCalculator(0),
+			Clock:                 chronos.DefaultClock,
+		}),
 	)
 }
@@ -0,0 +1,284 @@
+package composer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+// RunAsHelper is the executable of the buildpack, which runs an executable as
+// another user, see RunAs.
+const RunAsHelper = "run-as"
+
+// runAsOwnedEnv are the env vars of an execution naming the directories,
+// besides the vendor directory, which composer writes to, and which are
+// therefore handed over to the user running it, unless they are the ones of
+// the buildpack.
+var runAsOwnedEnv = []string{"COMPOSER_HOME", "HOME", "TMPDIR"}
+
+// lookupRunAs will check for env var "BP_COMPOSER_RUN_AS". If set to a user
+// in the form "uid:gid", it is returned, so that `composer install`, and
+// therefore the scripts of the packages, run as that user rather than the
+// build user, e.g. for platforms whose security policy forbids running
+// package scripts as the build user. Only root may run composer as another
+// user.
+func lookupRunAs(logger emitter) (*VendorOwner, error) {
+	value := strings.TrimSpace(os.Getenv(BpComposerRunAs))
+	if value == "" {
+		return nil, nil
+	}
+
+	user, err := parseOwner(BpComposerRunAs, value)
+	if err != nil {
+		return nil, err
+	}
+
+	if os.Getuid() != 0 && (user.UID != os.Getuid() || user.GID != os.Getgid()) {
+		return nil, fmt.Errorf("%s requires the build to run as root to run composer as %d:%d, it runs as %d:%d", BpComposerRunAs, user.UID, user.GID, os.Getuid(), os.Getgid())
+	}
+
+	logger.Process("Running 'composer install' as %d:%d (%s)", user.UID, user.GID, BpComposerRunAs)
+	logger.Break()
+
+	return &user, nil
+}
+
+// withRunAs returns an Executable which runs the executable of the given
+// name as the given user by delegating to the given Executable, or the given
+// Executable itself if there is none. As pexec.Executable cannot switch the
+// user, the executable is replaced on the PATH of the execution by a script
+// running it through the given run-as helper, see RunAs.
+func withRunAs(executable Executable, name string, user *VendorOwner, helper string) Executable {
+	if user == nil {
+		return executable
+	}
+
+	return runAsExecutable{
+		executable: executable,
+		name:       name,
+		user:       *user,
+		helper:     helper,
+	}
+}
+
+type runAsExecutable struct {
+	executable Executable
+	name       string
+	user       VendorOwner
+	helper     string
+}
+
+// Execute hands the paths composer writes to over to the user, runs the
+// executable as the user, and restores their owners afterwards, so that the
+// build can copy and modify the installed files, e.g. into the
+// composer-packages layer. Files created by the executable are handed to the
+// build user.
+func (e runAsExecutable) Execute(execution pexec.Execution) error {
+	path := os.Getenv("PATH")
+	values := map[string]string{}
+	for _, variable := range execution.Env {
+		name, value, _ := strings.Cut(variable, "=")
+		values[name] = value
+		if name == "PATH" {
+			path = value
+		}
+	}
+
+	executable, err := lookPath(e.name, path)
+	if err != nil {
+		return err
+	}
+
+	shimDir, err := os.MkdirTemp("", "composer-run-as")
+	if err != nil { // untested
+		return err
+	}
+	defer os.RemoveAll(shimDir)
+
+	shim := fmt.Sprintf("#!/bin/sh\nexec %s %s %s \"$@\"\n", shellQuote(e.helper), shellQuote(fmt.Sprintf("%d:%d", e.user.UID, e.user.GID)), shellQuote(executable))
+	err = os.WriteFile(filepath.Join(shimDir, e.name), []byte(shim), 0755)
+	if err != nil { // untested
+		return err
+	}
+
+	// the last PATH of the environment takes effect
+	execution.Env = append(append([]string{}, execution.Env...), fmt.Sprintf("PATH=%s%c%s", shimDir, os.PathListSeparator, path))
+
+	paths := runAsOwnedPaths(execution.Dir, values)
+
+	owners, err := handOver(paths, e.user)
+	if err != nil {
+		_ = restoreOwners(paths, owners, nil)
+		return err
+	}
+
+	err = e.executable.Execute(execution)
+
+	buildUser := VendorOwner{UID: os.Getuid(), GID: os.Getgid()}
+	if restoreErr := restoreOwners(paths, owners, &buildUser); restoreErr != nil && err == nil {
+		return restoreErr
+	}
+
+	return err
+}
+
+// ownedPath is a path handed over to the user running composer, with its
+// contents if tree is set.
+type ownedPath struct {
+	path string
+	tree bool
+}
+
+// runAsOwnedPaths returns the paths composer writes to: the working directory
+// itself, for the vendor directory and composer.lock, the lock file, the
+// vendor directory, and the directories of runAsOwnedEnv. The environment of
+// the execution usually starts with os.Environ(), so only directories
+// different from the ones of the buildpack are its own, e.g. the HOME of the
+// sandbox.
+func runAsOwnedPaths(workingDir string, env map[string]string) []ownedPath {
+	vendorDir := env[ComposerVendorDir]
+	if vendorDir == "" {
+		vendorDir = "vendor"
+	}
+	if !filepath.IsAbs(vendorDir) {
+		vendorDir = filepath.Join(workingDir, vendorDir)
+	}
+
+	paths := []ownedPath{
+		{path: workingDir},
+		{path: filepath.Join(workingDir, "composer.lock")},
+		{path: vendorDir, tree: true},
+	}
+	for _, name := range runAsOwnedEnv {
+		if value := env[name]; value != "" && value != os.Getenv(name) {
+			paths = append(paths, ownedPath{path: value, tree: true})
+		}
+	}
+
+	return paths
+}
+
+// walkOwnedPaths calls fn for each of the given paths, and the contents of
+// the trees among them. Paths which do not exist are skipped, and symlinks
+// are not followed.
+func walkOwnedPaths(paths []ownedPath, fn func(path string, info fs.FileInfo) error) error {
+	for _, p := range paths {
+		err := filepath.WalkDir(p.path, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			info, err := entry.Info()
+			if err != nil { // untested
+				return err
+			}
+
+			err = fn(path, info)
+			if err != nil {
+				return err
+			}
+
+			if entry.IsDir() && !p.tree {
+				return fs.SkipDir
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handOver changes the owner of the given paths to the given user. It
+// returns the previous owners of all paths it has seen, also if it fails, so
+// that they can be restored.
+func handOver(paths []ownedPath, user VendorOwner) (map[string]VendorOwner, error) {
+	owners := map[string]VendorOwner{}
+	err := walkOwnedPaths(paths, func(path string, info fs.FileInfo) error {
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		if !ok { // untested
+			return fmt.Errorf("failed to read the owner of %s", path)
+		}
+		owner := VendorOwner{UID: int(stat.Uid), GID: int(stat.Gid)}
+
+		if owner != user {
+			err := os.Lchown(path, user.UID, user.GID)
+			if err != nil {
+				return fmt.Errorf("failed to change owner of %s to %d:%d: %w", path, user.UID, user.GID, err)
+			}
+		}
+
+		owners[path] = owner
+		return nil
+	})
+
+	return owners, err
+}
+
+// restoreOwners restores the owners recorded by handOver. If newOwner is
+// given, the paths created since then are handed to it, otherwise they are
+// left as they are.
+func restoreOwners(paths []ownedPath, owners map[string]VendorOwner, newOwner *VendorOwner) error {
+	return walkOwnedPaths(paths, func(path string, info fs.FileInfo) error {
+		owner, ok := owners[path]
+		if !ok {
+			if newOwner == nil {
+				return nil
+			}
+			owner = *newOwner
+		}
+
+		if ownedBy(info, owner) {
+			return nil
+		}
+
+		err := os.Lchown(path, owner.UID, owner.GID)
+		if err != nil {
+			return fmt.Errorf("failed to restore owner of %s to %d:%d: %w", path, owner.UID, owner.GID, err)
+		}
+		return nil
+	})
+}
+
+// shellQuote quotes the given value for a POSIX shell.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// RunAs replaces the current process by the given executable run as the
+// given user in the form "uid:gid", with the supplementary groups dropped.
+// It is run by the `run-as` executable of the buildpack for
+// BP_COMPOSER_RUN_AS, as only root may switch the user.
+func RunAs(user string, executable string, args []string) error {
+	owner, err := parseOwner(BpComposerRunAs, user)
+	if err != nil {
+		return err
+	}
+
+	if owner.UID != os.Getuid() || owner.GID != os.Getgid() {
+		err = syscall.Setgroups([]int{})
+		if err != nil {
+			return fmt.Errorf("failed to drop the supplementary groups: %w", err)
+		}
+
+		err = syscall.Setgid(owner.GID)
+		if err != nil {
+			return fmt.Errorf("failed to switch to group %d: %w", owner.GID, err)
+		}
+
+		err = syscall.Setuid(owner.UID)
+		if err != nil {
+			return fmt.Errorf("failed to switch to user %d: %w", owner.UID, err)
+		}
+	}
+
+	return syscall.Exec(executable, append([]string{executable}, args...), os.Environ())
+}
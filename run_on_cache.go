@@ -0,0 +1,169 @@
+package composer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// runComposerInstallOnCacheAuto is the value of "BP_RUN_COMPOSER_INSTALL"
+// which only runs `composer install` on a reused cached layer if the project
+// has install-time hooks.
+const runComposerInstallOnCacheAuto = "auto"
+
+// installTimePackageTypes are the types of packages which hook into
+// `composer install`, such as `composer/installers`,
+// `drupal/core-composer-scaffold` or `cweagans/composer-patches`.
+// https://getcomposer.org/doc/articles/plugins.md
+var installTimePackageTypes = map[string]bool{
+	"composer-plugin":    true,
+	"composer-installer": true,
+}
+
+// installTimeScriptEvents are the script events dispatched by
+// `composer install`.
+// https://getcomposer.org/doc/articles/scripts.md#event-names
+var installTimeScriptEvents = []string{
+	"pre-install-cmd",
+	"post-install-cmd",
+	"pre-package-install",
+	"post-package-install",
+	"pre-autoload-dump",
+	"post-autoload-dump",
+}
+
+// FindInstallTimeHooks returns the hooks of the project which run as part of
+// `composer install`, and may write files outside of the vendor directory:
+//   - the plugins and installers locked in `composer.lock`, unless they are
+//     disallowed in "config.allow-plugins" of `composer.json`
+//   - the scripts of `composer.json` for the events of `composer install`
+//
+// Returns a description of each hook, e.g. "plugin composer/installers" or
+// "script post-install-cmd".
+func FindInstallTimeHooks(composerJsonPath, composerLockPath string) ([]string, error) {
+	var composerJson struct {
+		Scripts map[string]interface{} `json:"scripts"`
+		Config  struct {
+			AllowPlugins interface{} `json:"allow-plugins"`
+		} `json:"config"`
+	}
+
+	content, err := readComposerFile(composerJsonPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	} else if err == nil {
+		err = json.Unmarshal(content, &composerJson)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", composerJsonPath, err)
+		}
+	}
+
+	type lockedPackage struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}
+
+	var composerLock struct {
+		Packages    []lockedPackage `json:"packages"`
+		PackagesDev []lockedPackage `json:"packages-dev"`
+	}
+
+	content, err = readComposerFile(composerLockPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	} else if err == nil {
+		err = json.Unmarshal(content, &composerLock)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", composerLockPath, err)
+		}
+	}
+
+	var plugins []string
+	for _, p := range append(composerLock.Packages, composerLock.PackagesDev...) {
+		if installTimePackageTypes[p.Type] && pluginAllowed(composerJson.Config.AllowPlugins, p.Name) {
+			plugins = append(plugins, p.Name)
+		}
+	}
+	sort.Strings(plugins)
+
+	var hooks []string
+	for _, name := range plugins {
+		hooks = append(hooks, fmt.Sprintf("plugin %s", name))
+	}
+
+	for _, event := range installTimeScriptEvents {
+		if _, ok := composerJson.Scripts[event]; ok {
+			hooks = append(hooks, fmt.Sprintf("script %s", event))
+		}
+	}
+
+	return hooks, nil
+}
+
+// pluginAllowed returns whether the plugin with the given name is allowed by
+// the value of "config.allow-plugins", which is either a boolean, or an
+// object mapping package names or patterns such as "vendor/*" to booleans.
+// Plugins which are not listed are considered to be allowed, as Composer
+// asks whether to allow them, or fails.
+// https://getcomposer.org/doc/06-config.md#allow-plugins
+func pluginAllowed(allowPlugins interface{}, name string) bool {
+	switch value := allowPlugins.(type) {
+	case bool:
+		return value
+	case map[string]interface{}:
+		if allowed, ok := value[name].(bool); ok {
+			return allowed
+		}
+
+		var patterns []string
+		for pattern := range value {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, name); !matched {
+				continue
+			}
+
+			if allowed, ok := value[pattern].(bool); ok {
+				return allowed
+			}
+		}
+	}
+
+	return true
+}
+
+// runComposerInstallOnCacheRequired will check for env var
+// "BP_RUN_COMPOSER_INSTALL", which defaults to true. If set to "auto",
+// `composer install` only runs on a reused cached layer if the project has
+// install-time hooks, see FindInstallTimeHooks. The decision is logged.
+func runComposerInstallOnCacheRequired(logger emitter, composerJsonPath, composerLockPath string) (bool, error) {
+	if !strings.EqualFold(os.Getenv(runComposerInstallOnCacheEnv), runComposerInstallOnCacheAuto) {
+		return lookupBoolEnv(runComposerInstallOnCacheEnv, true)
+	}
+
+	hooks, err := FindInstallTimeHooks(composerJsonPath, composerLockPath)
+	if err != nil {
+		return false, err
+	}
+
+	if len(hooks) == 0 {
+		logger.Process("Skipping 'composer install' from cached files, as there are no plugins or install scripts (%s=%s)",
+			runComposerInstallOnCacheEnv, runComposerInstallOnCacheAuto)
+		return false, nil
+	}
+
+	logger.Process("Running 'composer install' from cached files for %d plugin(s) and install script(s) (%s=%s)",
+		len(hooks), runComposerInstallOnCacheEnv, runComposerInstallOnCacheAuto)
+	for _, hook := range hooks {
+		logger.Subprocess("- %s", hook)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,132 @@
+package composer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testRunOnCache(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir       string
+		composerJsonPath string
+		composerLockPath string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		composerJsonPath = filepath.Join(workingDir, "composer.json")
+		composerLockPath = filepath.Join(workingDir, "composer.lock")
+
+		Expect(os.WriteFile(composerLockPath, []byte(`{
+    "packages": [
+        {"name": "monolog/monolog", "type": "library"},
+        {"name": "drupal/core-composer-scaffold", "type": "composer-plugin"},
+        {"name": "composer/installers", "type": "composer-plugin"}
+    ],
+    "packages-dev": [
+        {"name": "phpstan/extension-installer", "type": "composer-plugin"}
+    ]
+}`), os.ModePerm)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("FindInstallTimeHooks", func() {
+		it("returns the locked plugins and the install scripts", func() {
+			Expect(os.WriteFile(composerJsonPath, []byte(`{
+    "scripts": {
+        "test": "phpunit",
+        "post-autoload-dump": "@php artisan package:discover",
+        "pre-install-cmd": ["@check"]
+    }
+}`), os.ModePerm)).To(Succeed())
+
+			hooks, err := composer.FindInstallTimeHooks(composerJsonPath, composerLockPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hooks).To(Equal([]string{
+				"plugin composer/installers",
+				"plugin drupal/core-composer-scaffold",
+				"plugin phpstan/extension-installer",
+				"script pre-install-cmd",
+				"script post-autoload-dump",
+			}))
+		})
+
+		it("skips the plugins which are not allowed", func() {
+			Expect(os.WriteFile(composerJsonPath, []byte(`{
+    "config": {
+        "allow-plugins": {
+            "composer/installers": true,
+            "drupal/*": false,
+            "phpstan/extension-installer": false
+        }
+    }
+}`), os.ModePerm)).To(Succeed())
+
+			hooks, err := composer.FindInstallTimeHooks(composerJsonPath, composerLockPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hooks).To(Equal([]string{"plugin composer/installers"}))
+		})
+
+		context("when all plugins are disallowed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(composerJsonPath, []byte(`{"config": {"allow-plugins": false}}`), os.ModePerm)).To(Succeed())
+			})
+
+			it("returns no hooks", func() {
+				hooks, err := composer.FindInstallTimeHooks(composerJsonPath, composerLockPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(hooks).To(BeEmpty())
+			})
+		})
+
+		context("when there is neither composer.json nor composer.lock", func() {
+			it.Before(func() {
+				Expect(os.Remove(composerLockPath)).To(Succeed())
+			})
+
+			it("returns no hooks", func() {
+				hooks, err := composer.FindInstallTimeHooks(composerJsonPath, composerLockPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(hooks).To(BeEmpty())
+			})
+		})
+
+		context("failure cases", func() {
+			context("when composer.json is invalid", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(composerJsonPath, []byte("%%%"), os.ModePerm)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := composer.FindInstallTimeHooks(composerJsonPath, composerLockPath)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse %s", composerJsonPath)))
+				})
+			})
+
+			context("when composer.lock is invalid", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(composerLockPath, []byte("%%%"), os.ModePerm)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := composer.FindInstallTimeHooks(composerJsonPath, composerLockPath)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse %s", composerLockPath)))
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,181 @@
+package composer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// composerSandbox restricts the environment in which `composer install`, and
+// therefore the scripts of the installed packages, are run.
+type composerSandbox struct {
+	// env is appended to the environment of the execution, it provides a
+	// separate HOME and TMPDIR
+	env []string
+
+	// readOnly makes the working directory read-only during the execution,
+	// except for the writable paths
+	readOnly      bool
+	workingDir    string
+	writablePaths []string
+	fileSystem    FileSystem
+}
+
+// prepareComposerSandbox will check for env var "BP_COMPOSER_SANDBOX".
+// If set to true, `composer install` is run with HOME and TMPDIR pointing to
+// an ignored layer. If "BP_COMPOSER_SANDBOX_WRITABLE_PATHS" is set as well,
+// the existing files in the working directory are made read-only during
+// `composer install`, except for the vendor directory and the listed paths.
+//
+// The read-only workspace only guards against accidental writes by package
+// scripts, it is not a security boundary: the directories containing the
+// writable paths, such as the working directory itself, stay writable, so
+// files can be created in them, and root ignores the file modes. A warning is
+// logged if `composer install` runs as root, see BP_COMPOSER_RUN_AS.
+func prepareComposerSandbox(logger emitter, context packit.BuildContext, fileSystem FileSystem, runAs *VendorOwner, workspaceVendorDir string) (composerSandbox, error) {
+	enabled, err := lookupBoolEnv(BpComposerSandbox, false)
+	if err != nil {
+		return composerSandbox{}, err
+	}
+
+	if !enabled {
+		return composerSandbox{}, nil
+	}
+
+	composerSandboxLayer, err := context.Layers.Get(ComposerSandboxLayerName)
+	if err != nil { // untested
+		return composerSandbox{}, err
+	}
+
+	composerSandboxLayer, err = composerSandboxLayer.Reset()
+	if err != nil { // untested
+		return composerSandbox{}, err
+	}
+
+	home := filepath.Join(composerSandboxLayer.Path, "home")
+	tmp := filepath.Join(composerSandboxLayer.Path, "tmp")
+	for _, dir := range []string{home, tmp} {
+		err = fileSystem.MkdirAll(dir, 0755)
+		if err != nil { // untested
+			return composerSandbox{}, err
+		}
+	}
+
+	sandbox := composerSandbox{
+		env: []string{
+			fmt.Sprintf("HOME=%s", home),
+			fmt.Sprintf("TMPDIR=%s", tmp),
+		},
+		workingDir: context.WorkingDir,
+		fileSystem: fileSystem,
+	}
+
+	logger.Process("Running 'composer install' in a sandbox")
+	logger.Subprocess("HOME=%s", home)
+	logger.Subprocess("TMPDIR=%s", tmp)
+
+	writablePaths, found := os.LookupEnv(BpComposerSandboxWritablePaths)
+	if !found {
+		logger.Break()
+		return sandbox, nil
+	}
+
+	sandbox.readOnly = true
+	sandbox.writablePaths = []string{workspaceVendorDir}
+
+	for _, path := range strings.Fields(writablePaths) {
+		relativePath, err := filepath.Rel(context.WorkingDir, filepath.Join(context.WorkingDir, path))
+		if err != nil { // untested
+			return composerSandbox{}, err
+		}
+
+		if filepath.IsAbs(path) || relativePath == "." || strings.HasPrefix(relativePath, "..") {
+			return composerSandbox{}, fmt.Errorf("%s must only contain relative paths underneath the project root, found %q", BpComposerSandboxWritablePaths, path)
+		}
+
+		sandbox.writablePaths = append(sandbox.writablePaths, filepath.Join(context.WorkingDir, relativePath))
+	}
+
+	logger.Subprocess("Workspace is read-only, except for:")
+	for _, path := range sandbox.writablePaths {
+		logger.Subprocess("- %s", path)
+	}
+
+	uid := os.Getuid()
+	if runAs != nil {
+		uid = runAs.UID
+	}
+	if uid == 0 {
+		logger.Subprocess("WARNING: 'composer install' runs as root, which can write to read-only files, set %s to run it as another user", BpComposerRunAs)
+	}
+	logger.Break()
+
+	return sandbox, nil
+}
+
+// run runs the given function, with the working directory being read-only
+// if required. The original file modes are restored afterwards.
+func (s composerSandbox) run(f func() error) error {
+	if !s.readOnly {
+		return f()
+	}
+
+	modes := map[string]os.FileMode{}
+	restore := func() error {
+		for path, mode := range modes {
+			err := s.fileSystem.Chmod(path, mode)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := filepath.Walk(s.workingDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// chmod follows symlinks, which may point outside of the working directory
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+
+		for _, writablePath := range s.writablePaths {
+			if path == writablePath {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			// directories containing writable paths need to stay writable,
+			// so that the writable paths can be created
+			if strings.HasPrefix(writablePath, path+string(filepath.Separator)) {
+				return nil
+			}
+		}
+
+		if info.Mode().Perm()&0222 == 0 {
+			return nil
+		}
+
+		modes[path] = info.Mode().Perm()
+		return s.fileSystem.Chmod(path, info.Mode().Perm()&^0222)
+	})
+	if err != nil {
+		_ = restore()
+		return err
+	}
+
+	err = f()
+
+	if restoreErr := restore(); restoreErr != nil && err == nil { // untested
+		return restoreErr
+	}
+
+	return err
+}
@@ -0,0 +1,113 @@
+// Package sbom provides the SBOM formats supported by this buildpack in
+// addition to the formats of packit, and the conversion between them.
+package sbom
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/sbom"
+)
+
+const (
+	// CycloneDXFormat is the media type of CycloneDX JSON
+	CycloneDXFormat = sbom.CycloneDXFormat
+
+	// SPDXFormat is the media type of SPDX JSON
+	SPDXFormat = sbom.SPDXFormat
+
+	// SyftFormat is the media type of the raw Syft JSON
+	SyftFormat = sbom.SyftFormat
+
+	// SPDXTagValueFormat is the media type of SPDX tag-value. It is not
+	// supported as layer SBOM by the lifecycle, so it is converted from SPDX
+	// JSON and written as a file instead.
+	SPDXTagValueFormat = "text/spdx"
+)
+
+// aliases are the short names accepted in addition to the media types.
+var aliases = map[string]string{
+	"cyclonedx":      CycloneDXFormat,
+	"cdx":            CycloneDXFormat,
+	"spdx":           SPDXFormat,
+	"spdx-json":      SPDXFormat,
+	"syft":           SyftFormat,
+	"syft-json":      SyftFormat,
+	"spdx-tag-value": SPDXTagValueFormat,
+	"spdx-tv":        SPDXTagValueFormat,
+}
+
+// ParseFormats parses a comma- or space-delimited list of SBOM formats, each
+// either a media type, optionally with a version parameter such as
+// "application/vnd.cyclonedx+json;version=1.4", or one of the short names
+// "cyclonedx", "spdx", "syft" and "spdx-tag-value". The short names are
+// replaced with their media types, and duplicates are removed.
+func ParseFormats(value string) ([]string, error) {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+
+	var formats []string
+	seen := map[string]bool{}
+	for _, field := range fields {
+		format, ok := aliases[strings.ToLower(field)]
+		if !ok {
+			format = field
+		}
+
+		err := Validate(format)
+		if err != nil {
+			return nil, err
+		}
+
+		if !seen[format] {
+			seen[format] = true
+			formats = append(formats, format)
+		}
+	}
+
+	return formats, nil
+}
+
+// Validate returns an error if the given media type is not a supported SBOM
+// format.
+func Validate(format string) error {
+	baseType, _, err := mime.ParseMediaType(format)
+	if err != nil {
+		return fmt.Errorf("invalid SBOM format %q: %w", format, err)
+	}
+
+	switch baseType {
+	case CycloneDXFormat, SPDXFormat, SyftFormat, SPDXTagValueFormat:
+		return nil
+	default:
+		return fmt.Errorf("unsupported SBOM format %q, supported are %s, %s, %s and %s",
+			format, CycloneDXFormat, SPDXFormat, SyftFormat, SPDXTagValueFormat)
+	}
+}
+
+// IsLayerFormat reports whether the given format can be written as layer
+// SBOM, i.e. is supported by the lifecycle.
+func IsLayerFormat(format string) bool {
+	baseType, _, err := mime.ParseMediaType(format)
+	if err != nil {
+		return false
+	}
+
+	return baseType != SPDXTagValueFormat
+}
+
+// Split splits the given formats into those written as layer SBOM, and
+// those written as files by this buildpack.
+func Split(formats []string) (layerFormats []string, fileFormats []string) {
+	for _, format := range formats {
+		if IsLayerFormat(format) {
+			layerFormats = append(layerFormats, format)
+		} else {
+			fileFormats = append(fileFormats, format)
+		}
+	}
+
+	return layerFormats, fileFormats
+}
@@ -0,0 +1,73 @@
+package sbom_test
+
+import (
+	"testing"
+
+	"github.com/paketo-buildpacks/composer/sbom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testFormats(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("ParseFormats", func() {
+		it("replaces the short names with their media types", func() {
+			formats, err := sbom.ParseFormats("cyclonedx,spdx syft, spdx-tag-value")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(formats).To(Equal([]string{
+				sbom.CycloneDXFormat,
+				sbom.SPDXFormat,
+				sbom.SyftFormat,
+				sbom.SPDXTagValueFormat,
+			}))
+		})
+
+		it("accepts media types with a version", func() {
+			formats, err := sbom.ParseFormats("application/vnd.cyclonedx+json;version=1.4")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(formats).To(Equal([]string{"application/vnd.cyclonedx+json;version=1.4"}))
+		})
+
+		it("removes duplicates", func() {
+			formats, err := sbom.ParseFormats("CDX, application/vnd.cyclonedx+json, spdx-tv, text/spdx")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(formats).To(Equal([]string{sbom.CycloneDXFormat, sbom.SPDXTagValueFormat}))
+		})
+
+		it("returns no formats for an empty value", func() {
+			formats, err := sbom.ParseFormats(" , ")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(formats).To(BeEmpty())
+		})
+
+		context("failure cases", func() {
+			context("when a format is not supported", func() {
+				it("returns an error", func() {
+					_, err := sbom.ParseFormats("cyclonedx,application/xml")
+					Expect(err).To(MatchError(ContainSubstring(`unsupported SBOM format "application/xml"`)))
+				})
+			})
+
+			context("when a format is not a media type", func() {
+				it("returns an error", func() {
+					_, err := sbom.ParseFormats("application/json;=")
+					Expect(err).To(MatchError(ContainSubstring(`invalid SBOM format "application/json;="`)))
+				})
+			})
+		})
+	})
+
+	context("Split", func() {
+		it("separates the formats written as files", func() {
+			layerFormats, fileFormats := sbom.Split([]string{
+				sbom.SPDXTagValueFormat,
+				sbom.CycloneDXFormat,
+				"application/spdx+json;version=2.3",
+			})
+			Expect(layerFormats).To(Equal([]string{sbom.CycloneDXFormat, "application/spdx+json;version=2.3"}))
+			Expect(fileFormats).To(Equal([]string{sbom.SPDXTagValueFormat}))
+		})
+	})
+}
@@ -0,0 +1,17 @@
+package sbom_test
+
+import (
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitSBOM(t *testing.T) {
+	suite := spec.New("sbom", spec.Report(report.Terminal{}))
+	suite("Formats", testFormats)
+	suite("Licenses", testLicenses)
+	suite("PhpExtensions", testPhpExtensions)
+	suite("TagValue", testTagValue)
+	suite.Run(t)
+}
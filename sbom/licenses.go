@@ -0,0 +1,174 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// composerPurlPrefix is the prefix of the package URLs of Composer packages.
+// https://github.com/package-url/purl-spec/blob/master/PURL-TYPES.rst#composer
+const composerPurlPrefix = "pkg:composer/"
+
+// PackageLicense is the license of a Composer package listed in an SBOM.
+type PackageLicense struct {
+	Name    string
+	Version string
+
+	// License is an SPDX license expression, or empty if the license of the
+	// package is unknown
+	License string
+}
+
+// ParseLicenses returns the licenses of the Composer packages in the given
+// SBOM, which is either SPDX JSON or CycloneDX JSON, as indicated by its
+// extension "spdx.json" or "cdx.json". Packages are identified by their
+// package URL, so that other packages, such as the scanned directory itself,
+// are skipped.
+//
+// Returns the packages sorted by name.
+func ParseLicenses(content []byte, extension string) ([]PackageLicense, error) {
+	var licenses []PackageLicense
+	var err error
+
+	switch extension {
+	case "spdx.json":
+		licenses, err = parseSPDXLicenses(content)
+	case "cdx.json":
+		licenses, err = parseCycloneDXLicenses(content)
+	default:
+		return nil, fmt.Errorf("unsupported SBOM format %q, expected spdx.json or cdx.json", extension)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(licenses, func(i, j int) bool {
+		return licenses[i].Name < licenses[j].Name
+	})
+
+	return licenses, nil
+}
+
+func parseSPDXLicenses(content []byte) ([]PackageLicense, error) {
+	var document struct {
+		Packages []struct {
+			VersionInfo      string `json:"versionInfo"`
+			LicenseDeclared  string `json:"licenseDeclared"`
+			LicenseConcluded string `json:"licenseConcluded"`
+			ExternalRefs     []struct {
+				ReferenceType    string `json:"referenceType"`
+				ReferenceLocator string `json:"referenceLocator"`
+			} `json:"externalRefs"`
+		} `json:"packages"`
+	}
+
+	err := json.Unmarshal(content, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	var licenses []PackageLicense
+	for _, p := range document.Packages {
+		for _, ref := range p.ExternalRefs {
+			name, ok := composerPackageName(ref.ReferenceLocator)
+			if ref.ReferenceType != "purl" || !ok {
+				continue
+			}
+
+			license := spdxLicense(p.LicenseDeclared)
+			if license == "" {
+				license = spdxLicense(p.LicenseConcluded)
+			}
+
+			licenses = append(licenses, PackageLicense{Name: name, Version: p.VersionInfo, License: license})
+			break
+		}
+	}
+
+	return licenses, nil
+}
+
+func parseCycloneDXLicenses(content []byte) ([]PackageLicense, error) {
+	var document struct {
+		Components []struct {
+			Version  string `json:"version"`
+			Purl     string `json:"purl"`
+			Licenses []struct {
+				Expression string `json:"expression"`
+				License    struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+				} `json:"license"`
+			} `json:"licenses"`
+		} `json:"components"`
+	}
+
+	err := json.Unmarshal(content, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	var licenses []PackageLicense
+	for _, c := range document.Components {
+		name, ok := composerPackageName(c.Purl)
+		if !ok {
+			continue
+		}
+
+		// packages with more than one license can be used under any of them
+		// https://getcomposer.org/doc/04-schema.md#license
+		var choices []string
+		for _, l := range c.Licenses {
+			for _, value := range []string{l.Expression, l.License.ID, l.License.Name} {
+				if value = spdxLicense(value); value != "" {
+					choices = append(choices, value)
+					break
+				}
+			}
+		}
+
+		license := strings.Join(choices, " OR ")
+		if len(choices) > 1 {
+			license = fmt.Sprintf("(%s)", strings.Join(choices, ") OR ("))
+		}
+
+		licenses = append(licenses, PackageLicense{Name: name, Version: c.Version, License: license})
+	}
+
+	return licenses, nil
+}
+
+// composerPackageName returns the name of the Composer package of the given
+// package URL, e.g. "monolog/monolog" for
+// "pkg:composer/monolog/monolog@3.5.0".
+func composerPackageName(purl string) (string, bool) {
+	if !strings.HasPrefix(purl, composerPurlPrefix) {
+		return "", false
+	}
+
+	name := strings.TrimPrefix(purl, composerPurlPrefix)
+	name, _, _ = strings.Cut(name, "?")
+	name, _, _ = strings.Cut(name, "#")
+	name, _, _ = strings.Cut(name, "@")
+
+	unescaped, err := url.PathUnescape(name)
+	if err != nil {
+		return "", false
+	}
+
+	return unescaped, unescaped != ""
+}
+
+// spdxLicense returns the given license, or an empty string if it is one of
+// the values SPDX uses for unknown licenses.
+func spdxLicense(value string) string {
+	value = strings.TrimSpace(value)
+	switch strings.ToUpper(value) {
+	case "", "NOASSERTION", "NONE":
+		return ""
+	}
+	return value
+}
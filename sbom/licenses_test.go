@@ -0,0 +1,90 @@
+package sbom_test
+
+import (
+	"testing"
+
+	"github.com/paketo-buildpacks/composer/sbom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLicenses(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("ParseLicenses", func() {
+		it("reads the licenses of the Composer packages in SPDX JSON", func() {
+			licenses, err := sbom.ParseLicenses([]byte(`{
+    "packages": [
+        {
+            "name": "/workspace",
+            "licenseDeclared": "NOASSERTION"
+        },
+        {
+            "versionInfo": "3.5.0",
+            "licenseDeclared": "MIT",
+            "externalRefs": [
+                {"referenceType": "cpe23Type", "referenceLocator": "cpe:2.3:a:monolog:monolog:3.5.0:*:*:*:*:*:*:*"},
+                {"referenceType": "purl", "referenceLocator": "pkg:composer/monolog/monolog@3.5.0"}
+            ]
+        },
+        {
+            "versionInfo": "2.1.0",
+            "licenseDeclared": "NOASSERTION",
+            "licenseConcluded": "NOASSERTION",
+            "externalRefs": [{"referenceType": "purl", "referenceLocator": "pkg:composer/acme/billing@2.1.0"}]
+        }
+    ]
+}`), "spdx.json")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(licenses).To(Equal([]sbom.PackageLicense{
+				{Name: "acme/billing", Version: "2.1.0"},
+				{Name: "monolog/monolog", Version: "3.5.0", License: "MIT"},
+			}))
+		})
+
+		it("reads the licenses of the Composer packages in CycloneDX JSON", func() {
+			licenses, err := sbom.ParseLicenses([]byte(`{
+    "components": [
+        {
+            "version": "v6.4.1",
+            "purl": "pkg:composer/symfony/console@v6.4.1",
+            "licenses": [{"license": {"id": "MIT"}}]
+        },
+        {
+            "version": "1.0.0",
+            "purl": "pkg:composer/acme/dual@1.0.0",
+            "licenses": [{"license": {"id": "GPL-3.0-only"}}, {"license": {"name": "Proprietary"}}]
+        },
+        {
+            "version": "2.0.0",
+            "purl": "pkg:composer/acme/expression@2.0.0",
+            "licenses": [{"expression": "Apache-2.0 AND MIT"}]
+        },
+        {
+            "version": "8.2.0",
+            "purl": "pkg:deb/debian/php@8.2.0"
+        }
+    ]
+}`), "cdx.json")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(licenses).To(Equal([]sbom.PackageLicense{
+				{Name: "acme/dual", Version: "1.0.0", License: "(GPL-3.0-only) OR (Proprietary)"},
+				{Name: "acme/expression", Version: "2.0.0", License: "Apache-2.0 AND MIT"},
+				{Name: "symfony/console", Version: "v6.4.1", License: "MIT"},
+			}))
+		})
+
+		context("failure cases", func() {
+			it("returns an error for other formats", func() {
+				_, err := sbom.ParseLicenses([]byte(`{}`), "syft.json")
+				Expect(err).To(MatchError(`unsupported SBOM format "syft.json", expected spdx.json or cdx.json`))
+			})
+
+			it("returns an error for invalid JSON", func() {
+				_, err := sbom.ParseLicenses([]byte(`%%%`), "spdx.json")
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+}
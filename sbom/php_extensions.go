@@ -0,0 +1,165 @@
+package sbom
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// phpExtensionPurlPrefix is the prefix of the package URLs of PHP
+// extensions, which have no package URL type of their own.
+// https://github.com/package-url/purl-spec/blob/master/PURL-TYPES.rst#generic
+const phpExtensionPurlPrefix = "pkg:generic/php-ext-"
+
+// spdxIDInvalidCharacters matches the characters which are not allowed in an
+// SPDX identifier, e.g. the underscore of "pdo_mysql".
+var spdxIDInvalidCharacters = regexp.MustCompile(`[^A-Za-z0-9.-]`)
+
+// AddPhpExtensions adds the PHP extensions with the given names, without the
+// "ext-" prefix, to the given SBOM, which is either SPDX JSON, CycloneDX JSON
+// or Syft JSON, as indicated by its extension "spdx.json", "cdx.json" or
+// "syft.json". The extensions are listed as libraries with the package URL
+// "pkg:generic/php-ext-<name>" and without a version, as they are provided
+// by other buildpacks. Extensions already listed are skipped.
+func AddPhpExtensions(content []byte, extension string, names []string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+
+	var document map[string]interface{}
+	err := decoder.Decode(&document)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+
+	var key string
+	var entry func(name, purl string) map[string]interface{}
+	switch extension {
+	case "spdx.json":
+		key, entry = "packages", spdxPhpExtension(document)
+	case "cdx.json":
+		key, entry = "components", cycloneDXPhpExtension
+	case "syft.json":
+		key, entry = "artifacts", syftPhpExtension
+	default:
+		return nil, fmt.Errorf("unsupported SBOM format %q, expected spdx.json, cdx.json or syft.json", extension)
+	}
+
+	entries, _ := document[key].([]interface{})
+
+	listed := map[string]bool{}
+	for _, e := range entries {
+		if purl, ok := purlOf(e); ok {
+			listed[purl] = true
+		}
+	}
+
+	for _, name := range sorted {
+		purl := phpExtensionPurlPrefix + name
+		if listed[purl] {
+			continue
+		}
+		listed[purl] = true
+		entries = append(entries, entry(name, purl))
+	}
+
+	if entries == nil {
+		entries = []interface{}{}
+	}
+	document[key] = entries
+
+	buffer := bytes.NewBuffer(nil)
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	err = encoder.Encode(document)
+	if err != nil { // untested
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+}
+
+// purlOf returns the package URL of the given package of an SBOM, either
+// the "purl" of CycloneDX JSON and Syft JSON, or the "purl" external
+// reference of SPDX JSON.
+func purlOf(entry interface{}) (string, bool) {
+	object, ok := entry.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+
+	if purl, ok := object["purl"].(string); ok {
+		return purl, true
+	}
+
+	refs, _ := object["externalRefs"].([]interface{})
+	for _, ref := range refs {
+		ref, _ := ref.(map[string]interface{})
+		if ref["referenceType"] == "purl" {
+			purl, ok := ref["referenceLocator"].(string)
+			return purl, ok
+		}
+	}
+
+	return "", false
+}
+
+// spdxPhpExtension returns a function creating the package of a PHP
+// extension in the given SPDX document. The category of the package URL
+// has been renamed in SPDX 2.3.
+func spdxPhpExtension(document map[string]interface{}) func(name, purl string) map[string]interface{} {
+	category := "PACKAGE_MANAGER"
+	if document["spdxVersion"] == "SPDX-2.3" {
+		category = "PACKAGE-MANAGER"
+	}
+
+	return func(name, purl string) map[string]interface{} {
+		return map[string]interface{}{
+			"name":             "php-ext-" + name,
+			"SPDXID":           "SPDXRef-Package-generic-php-ext-" + spdxIDInvalidCharacters.ReplaceAllString(name, "-"),
+			"downloadLocation": "NOASSERTION",
+			"filesAnalyzed":    false,
+			"licenseConcluded": "NOASSERTION",
+			"licenseDeclared":  "NOASSERTION",
+			"copyrightText":    "NOASSERTION",
+			"externalRefs": []interface{}{
+				map[string]interface{}{
+					"referenceCategory": category,
+					"referenceType":     "purl",
+					"referenceLocator":  purl,
+				},
+			},
+		}
+	}
+}
+
+func cycloneDXPhpExtension(name, purl string) map[string]interface{} {
+	return map[string]interface{}{
+		"bom-ref": purl,
+		"type":    "library",
+		"name":    "php-ext-" + name,
+		"purl":    purl,
+	}
+}
+
+func syftPhpExtension(name, purl string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           fmt.Sprintf("%x", sha256.Sum256([]byte(purl)))[:16],
+		"name":         "php-ext-" + name,
+		"version":      "",
+		"type":         "UnknownPackage",
+		"foundBy":      "",
+		"locations":    []interface{}{},
+		"licenses":     []interface{}{},
+		"language":     "",
+		"cpes":         []interface{}{},
+		"purl":         purl,
+		"metadataType": "",
+		"metadata":     nil,
+	}
+}
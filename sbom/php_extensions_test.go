@@ -0,0 +1,111 @@
+package sbom_test
+
+import (
+	"testing"
+
+	"github.com/paketo-buildpacks/composer/sbom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPhpExtensions(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("AddPhpExtensions", func() {
+		it("adds the extensions to CycloneDX JSON", func() {
+			content, err := sbom.AddPhpExtensions([]byte(`{
+    "bomFormat": "CycloneDX",
+    "specVersion": "1.3",
+    "version": 1,
+    "components": [
+        {"type": "library", "name": "monolog/monolog", "version": "3.5.0", "purl": "pkg:composer/monolog/monolog@3.5.0"}
+    ]
+}`), "cdx.json", []string{"openssl", "gd"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(MatchJSON(`{
+    "bomFormat": "CycloneDX",
+    "specVersion": "1.3",
+    "version": 1,
+    "components": [
+        {"type": "library", "name": "monolog/monolog", "version": "3.5.0", "purl": "pkg:composer/monolog/monolog@3.5.0"},
+        {"bom-ref": "pkg:generic/php-ext-gd", "type": "library", "name": "php-ext-gd", "purl": "pkg:generic/php-ext-gd"},
+        {"bom-ref": "pkg:generic/php-ext-openssl", "type": "library", "name": "php-ext-openssl", "purl": "pkg:generic/php-ext-openssl"}
+    ]
+}`))
+		})
+
+		it("adds the extensions to SPDX JSON", func() {
+			content, err := sbom.AddPhpExtensions([]byte(`{
+    "spdxVersion": "SPDX-2.3",
+    "packages": [
+        {"name": "/workspace", "SPDXID": "SPDXRef-DocumentRoot-Directory-workspace"}
+    ]
+}`), "spdx.json", []string{"pdo_mysql"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(MatchJSON(`{
+    "spdxVersion": "SPDX-2.3",
+    "packages": [
+        {"name": "/workspace", "SPDXID": "SPDXRef-DocumentRoot-Directory-workspace"},
+        {
+            "name": "php-ext-pdo_mysql",
+            "SPDXID": "SPDXRef-Package-generic-php-ext-pdo-mysql",
+            "downloadLocation": "NOASSERTION",
+            "filesAnalyzed": false,
+            "licenseConcluded": "NOASSERTION",
+            "licenseDeclared": "NOASSERTION",
+            "copyrightText": "NOASSERTION",
+            "externalRefs": [
+                {"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:generic/php-ext-pdo_mysql"}
+            ]
+        }
+    ]
+}`))
+		})
+
+		it("uses the package URL category of SPDX 2.2", func() {
+			content, err := sbom.AddPhpExtensions([]byte(`{"spdxVersion": "SPDX-2.2"}`), "spdx.json", []string{"gd"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring(`"referenceCategory":"PACKAGE_MANAGER"`))
+		})
+
+		it("adds the extensions to Syft JSON", func() {
+			content, err := sbom.AddPhpExtensions([]byte(`{"artifacts": []}`), "syft.json", []string{"gd"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring(`"name":"php-ext-gd"`))
+			Expect(string(content)).To(ContainSubstring(`"purl":"pkg:generic/php-ext-gd"`))
+		})
+
+		it("skips the extensions already listed", func() {
+			content, err := sbom.AddPhpExtensions([]byte(`{
+    "components": [{"type": "library", "name": "gd", "purl": "pkg:generic/php-ext-gd"}]
+}`), "cdx.json", []string{"gd"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(MatchJSON(`{
+    "components": [{"type": "library", "name": "gd", "purl": "pkg:generic/php-ext-gd"}]
+}`))
+		})
+
+		it("keeps large numbers", func() {
+			content, err := sbom.AddPhpExtensions([]byte(`{"version": 12345678901234567890}`), "cdx.json", nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal(`{"components":[],"version":12345678901234567890}`))
+		})
+
+		context("failure cases", func() {
+			context("when the format is not supported", func() {
+				it("returns an error", func() {
+					_, err := sbom.AddPhpExtensions([]byte(`{}`), "spdx", []string{"gd"})
+					Expect(err).To(MatchError(`unsupported SBOM format "spdx", expected spdx.json, cdx.json or syft.json`))
+				})
+			})
+
+			context("when the SBOM is not valid JSON", func() {
+				it("returns an error", func() {
+					_, err := sbom.AddPhpExtensions([]byte(`SPDXVersion: SPDX-2.2`), "spdx.json", []string{"gd"})
+					Expect(err).To(HaveOccurred())
+				})
+			})
+		})
+	})
+}
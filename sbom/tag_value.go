@@ -0,0 +1,157 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxDocument struct {
+	SPDXVersion       string `json:"spdxVersion"`
+	DataLicense       string `json:"dataLicense"`
+	SPDXID            string `json:"SPDXID"`
+	Name              string `json:"name"`
+	DocumentNamespace string `json:"documentNamespace"`
+	CreationInfo      struct {
+		LicenseListVersion string   `json:"licenseListVersion"`
+		Creators           []string `json:"creators"`
+		Created            string   `json:"created"`
+	} `json:"creationInfo"`
+	Packages []struct {
+		Name             string         `json:"name"`
+		SPDXID           string         `json:"SPDXID"`
+		VersionInfo      string         `json:"versionInfo"`
+		Supplier         string         `json:"supplier"`
+		Originator       string         `json:"originator"`
+		DownloadLocation string         `json:"downloadLocation"`
+		FilesAnalyzed    bool           `json:"filesAnalyzed"`
+		Checksums        []spdxChecksum `json:"checksums"`
+		Homepage         string         `json:"homepage"`
+		SourceInfo       string         `json:"sourceInfo"`
+		LicenseConcluded string         `json:"licenseConcluded"`
+		LicenseDeclared  string         `json:"licenseDeclared"`
+		CopyrightText    string         `json:"copyrightText"`
+		Description      string         `json:"description"`
+		ExternalRefs     []struct {
+			ReferenceCategory string `json:"referenceCategory"`
+			ReferenceType     string `json:"referenceType"`
+			ReferenceLocator  string `json:"referenceLocator"`
+		} `json:"externalRefs"`
+	} `json:"packages"`
+	Files []struct {
+		FileName         string         `json:"fileName"`
+		SPDXID           string         `json:"SPDXID"`
+		FileTypes        []string       `json:"fileTypes"`
+		Checksums        []spdxChecksum `json:"checksums"`
+		LicenseConcluded string         `json:"licenseConcluded"`
+		CopyrightText    string         `json:"copyrightText"`
+	} `json:"files"`
+	Relationships []struct {
+		SPDXElementID      string `json:"spdxElementId"`
+		RelationshipType   string `json:"relationshipType"`
+		RelatedSPDXElement string `json:"relatedSpdxElement"`
+	} `json:"relationships"`
+}
+
+// tagValueWriter writes "Tag: value" lines, omitting empty values.
+type tagValueWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *tagValueWriter) tag(tag, value string) {
+	if value == "" {
+		return
+	}
+
+	// values spanning multiple lines must be wrapped in <text>
+	if strings.Contains(value, "\n") {
+		value = fmt.Sprintf("<text>%s</text>", value)
+	}
+
+	fmt.Fprintf(&w.buf, "%s: %s\n", tag, value)
+}
+
+func (w *tagValueWriter) section(title string) {
+	fmt.Fprintf(&w.buf, "\n##### %s\n\n", title)
+}
+
+// ConvertToSPDXTagValue converts an SPDX 2.x JSON document into the SPDX
+// tag-value format, as described in
+// https://spdx.github.io/spdx-spec/v2.3/conformance/. The document
+// information is followed by the packages, the files and the relationships.
+func ConvertToSPDXTagValue(spdxJSON io.Reader) ([]byte, error) {
+	var document spdxDocument
+	err := json.NewDecoder(spdxJSON).Decode(&document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SPDX JSON: %w", err)
+	}
+
+	if !strings.HasPrefix(document.SPDXVersion, "SPDX-2.") {
+		return nil, fmt.Errorf("unsupported SPDX version %q", document.SPDXVersion)
+	}
+
+	w := &tagValueWriter{}
+	w.tag("SPDXVersion", document.SPDXVersion)
+	w.tag("DataLicense", document.DataLicense)
+	w.tag("SPDXID", document.SPDXID)
+	w.tag("DocumentName", document.Name)
+	w.tag("DocumentNamespace", document.DocumentNamespace)
+	w.tag("LicenseListVersion", document.CreationInfo.LicenseListVersion)
+	for _, creator := range document.CreationInfo.Creators {
+		w.tag("Creator", creator)
+	}
+	w.tag("Created", document.CreationInfo.Created)
+
+	for _, p := range document.Packages {
+		w.section(fmt.Sprintf("Package: %s", p.Name))
+		w.tag("PackageName", p.Name)
+		w.tag("SPDXID", p.SPDXID)
+		w.tag("PackageVersion", p.VersionInfo)
+		w.tag("PackageSupplier", p.Supplier)
+		w.tag("PackageOriginator", p.Originator)
+		w.tag("PackageDownloadLocation", p.DownloadLocation)
+		w.tag("FilesAnalyzed", fmt.Sprintf("%t", p.FilesAnalyzed))
+		for _, checksum := range p.Checksums {
+			w.tag("PackageChecksum", fmt.Sprintf("%s: %s", checksum.Algorithm, checksum.ChecksumValue))
+		}
+		w.tag("PackageHomePage", p.Homepage)
+		w.tag("PackageSourceInfo", p.SourceInfo)
+		w.tag("PackageLicenseConcluded", p.LicenseConcluded)
+		w.tag("PackageLicenseDeclared", p.LicenseDeclared)
+		w.tag("PackageCopyrightText", p.CopyrightText)
+		w.tag("PackageDescription", p.Description)
+		for _, ref := range p.ExternalRefs {
+			w.tag("ExternalRef", fmt.Sprintf("%s %s %s", ref.ReferenceCategory, ref.ReferenceType, ref.ReferenceLocator))
+		}
+	}
+
+	for _, f := range document.Files {
+		w.section(fmt.Sprintf("File: %s", f.FileName))
+		w.tag("FileName", f.FileName)
+		w.tag("SPDXID", f.SPDXID)
+		for _, fileType := range f.FileTypes {
+			w.tag("FileType", fileType)
+		}
+		for _, checksum := range f.Checksums {
+			w.tag("FileChecksum", fmt.Sprintf("%s: %s", checksum.Algorithm, checksum.ChecksumValue))
+		}
+		w.tag("LicenseConcluded", f.LicenseConcluded)
+		w.tag("FileCopyrightText", f.CopyrightText)
+	}
+
+	if len(document.Relationships) > 0 {
+		w.section("Relationships")
+		for _, r := range document.Relationships {
+			w.tag("Relationship", fmt.Sprintf("%s %s %s", r.SPDXElementID, r.RelationshipType, r.RelatedSPDXElement))
+		}
+	}
+
+	return w.buf.Bytes(), nil
+}
@@ -0,0 +1,104 @@
+package sbom_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer/sbom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testTagValue(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("ConvertToSPDXTagValue", func() {
+		it("converts SPDX JSON", func() {
+			tagValue, err := sbom.ConvertToSPDXTagValue(strings.NewReader(`{
+				"spdxVersion": "SPDX-2.2",
+				"dataLicense": "CC0-1.0",
+				"SPDXID": "SPDXRef-DOCUMENT",
+				"name": "composer-packages",
+				"documentNamespace": "https://paketo.io/packit/unknown-source-type/composer-packages",
+				"creationInfo": {
+					"licenseListVersion": "3.16",
+					"creators": ["Organization: Anchore, Inc", "Tool: syft-"],
+					"created": "2022-01-01T00:00:00Z"
+				},
+				"packages": [
+					{
+						"name": "monolog/monolog",
+						"SPDXID": "SPDXRef-monolog",
+						"versionInfo": "2.3.5",
+						"downloadLocation": "NOASSERTION",
+						"filesAnalyzed": false,
+						"licenseConcluded": "MIT",
+						"licenseDeclared": "MIT",
+						"copyrightText": "NOASSERTION",
+						"description": "Sends your logs\nto files",
+						"externalRefs": [
+							{
+								"referenceCategory": "PACKAGE_MANAGER",
+								"referenceType": "purl",
+								"referenceLocator": "pkg:composer/monolog/monolog@2.3.5"
+							}
+						]
+					}
+				],
+				"relationships": [
+					{
+						"spdxElementId": "SPDXRef-DOCUMENT",
+						"relationshipType": "DESCRIBES",
+						"relatedSpdxElement": "SPDXRef-monolog"
+					}
+				]
+			}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(tagValue)).To(Equal(`SPDXVersion: SPDX-2.2
+DataLicense: CC0-1.0
+SPDXID: SPDXRef-DOCUMENT
+DocumentName: composer-packages
+DocumentNamespace: https://paketo.io/packit/unknown-source-type/composer-packages
+LicenseListVersion: 3.16
+Creator: Organization: Anchore, Inc
+Creator: Tool: syft-
+Created: 2022-01-01T00:00:00Z
+
+##### Package: monolog/monolog
+
+PackageName: monolog/monolog
+SPDXID: SPDXRef-monolog
+PackageVersion: 2.3.5
+PackageDownloadLocation: NOASSERTION
+FilesAnalyzed: false
+PackageLicenseConcluded: MIT
+PackageLicenseDeclared: MIT
+PackageCopyrightText: NOASSERTION
+PackageDescription: <text>Sends your logs
+to files</text>
+ExternalRef: PACKAGE_MANAGER purl pkg:composer/monolog/monolog@2.3.5
+
+##### Relationships
+
+Relationship: SPDXRef-DOCUMENT DESCRIBES SPDXRef-monolog
+`))
+		})
+
+		context("failure cases", func() {
+			context("when the document is not JSON", func() {
+				it("returns an error", func() {
+					_, err := sbom.ConvertToSPDXTagValue(strings.NewReader("%%%"))
+					Expect(err).To(MatchError(ContainSubstring("failed to parse SPDX JSON")))
+				})
+			})
+
+			context("when the document is not SPDX 2", func() {
+				it("returns an error", func() {
+					_, err := sbom.ConvertToSPDXTagValue(strings.NewReader(`{"spdxVersion": "SPDX-3.0"}`))
+					Expect(err).To(MatchError(`unsupported SPDX version "SPDX-3.0"`))
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,120 @@
+package composer
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+// sbomCacheLayerDir is the directory in the composer-packages layer holding
+// the formatted SBOM of the previous build.
+const sbomCacheLayerDir = "sbom-cache"
+
+// cachedSBOM provides the SBOM formats read from the sbom-cache directory.
+type cachedSBOM []packit.SBOMFormat
+
+func (c cachedSBOM) Formats() []packit.SBOMFormat {
+	return c
+}
+
+// readCachedSBOM returns the SBOM cached in the composer-packages layer, if
+// the layer has been reused and the SBOM has been generated from the same
+// `composer.lock` in the same formats. Generating the SBOM scans the whole
+// working directory, which is the slowest step of a build with a cached layer.
+func readCachedSBOM(composerPackagesLayer packit.Layer, formats []string) (sbom packit.SBOMFormatter, found bool, err error) {
+	metadata := composerPackagesLayer.Metadata
+
+	if metadata["cache-status"] != string(CacheStatusHit) {
+		return nil, false, nil
+	}
+
+	composerLockSHA, _ := metadata["composer-lock-sha"].(string)
+	if sha, _ := metadata["sbom-composer-lock-sha"].(string); sha == "" || sha != composerLockSHA {
+		return nil, false, nil
+	}
+
+	if cachedFormats, _ := metadata["sbom-formats"].(string); cachedFormats != strings.Join(formats, ",") {
+		return nil, false, nil
+	}
+
+	extensions, _ := metadata["sbom-extensions"].(string)
+
+	var cached cachedSBOM
+	for _, extension := range strings.Split(extensions, ",") {
+		// only file formats have been requested
+		if extension == "" {
+			continue
+		}
+
+		path := filepath.Join(composerPackagesLayer.Path, sbomCacheLayerDir, extension)
+		if exists, err := fs.Exists(path); err != nil {
+			return nil, false, err
+		} else if !exists {
+			return nil, false, nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil { // untested
+			return nil, false, err
+		}
+
+		cached = append(cached, packit.SBOMFormat{
+			Extension: extension,
+			Content:   bytes.NewReader(content),
+		})
+	}
+
+	return cached, true, nil
+}
+
+// cacheSBOM writes the given SBOM into the composer-packages layer, so that
+// it can be reused by the next build if `composer.lock` does not change. As
+// the contents of the given SBOM can only be read once, an equivalent SBOM is
+// returned.
+func cacheSBOM(fileSystem FileSystem, composerPackagesLayer *packit.Layer, sbom packit.SBOMFormatter, formats []string) (packit.SBOMFormatter, error) {
+	dir := filepath.Join(composerPackagesLayer.Path, sbomCacheLayerDir)
+
+	err := fileSystem.RemoveAll(dir)
+	if err != nil { // untested
+		return nil, err
+	}
+
+	err = fileSystem.MkdirAll(dir, 0755)
+	if err != nil { // untested
+		return nil, err
+	}
+
+	var cached cachedSBOM
+	var extensions []string
+	for _, format := range sbom.Formats() {
+		content, err := io.ReadAll(format.Content)
+		if err != nil { // untested
+			return nil, err
+		}
+
+		err = fileSystem.WriteFile(filepath.Join(dir, format.Extension), content, 0644)
+		if err != nil { // untested
+			return nil, err
+		}
+
+		extensions = append(extensions, format.Extension)
+		cached = append(cached, packit.SBOMFormat{
+			Extension: format.Extension,
+			Content:   bytes.NewReader(content),
+		})
+	}
+
+	if composerPackagesLayer.Metadata == nil {
+		composerPackagesLayer.Metadata = map[string]interface{}{}
+	}
+	composerPackagesLayer.Metadata["sbom-composer-lock-sha"] = composerPackagesLayer.Metadata["composer-lock-sha"]
+	composerPackagesLayer.Metadata["sbom-formats"] = strings.Join(formats, ",")
+	composerPackagesLayer.Metadata["sbom-extensions"] = strings.Join(extensions, ",")
+
+	return cached, nil
+}
@@ -0,0 +1,88 @@
+package composer
+
+import (
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+
+	composersbom "github.com/paketo-buildpacks/composer/sbom"
+	"github.com/paketo-buildpacks/packit/v2/sbom"
+)
+
+// SPDXTagValueFileName is the name of the SBOM in SPDX tag-value format in
+// the composer-packages layer.
+const SPDXTagValueFileName = "sbom.spdx"
+
+// determineSBOMFormats returns the SBOM formats from env var
+// "BP_SBOM_FORMATS", or the formats declared in `buildpack.toml` if it is not
+// set. The formats written as layer SBOM must be declared in
+// `buildpack.toml`, as the lifecycle rejects any other format.
+func determineSBOMFormats(declared []string) ([]string, error) {
+	for _, format := range declared {
+		err := composersbom.Validate(format)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sbom-formats in buildpack.toml: %w", err)
+		}
+	}
+
+	value := os.Getenv(BpSBOMFormats)
+	if value == "" {
+		return declared, nil
+	}
+
+	formats, err := composersbom.ParseFormats(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", BpSBOMFormats, err)
+	}
+
+	declaredBaseTypes := map[string]bool{}
+	for _, format := range declared {
+		baseType, _, _ := mime.ParseMediaType(format)
+		declaredBaseTypes[baseType] = true
+	}
+
+	layerFormats, _ := composersbom.Split(formats)
+	for _, format := range layerFormats {
+		baseType, _, _ := mime.ParseMediaType(format)
+		if !declaredBaseTypes[baseType] {
+			return nil, fmt.Errorf("%s contains %s, which is not declared in buildpack.toml", BpSBOMFormats, format)
+		}
+	}
+
+	return formats, nil
+}
+
+// writeFileSBOMs writes the SBOM in the given formats which are not
+// supported as layer SBOM into the composer-packages layer. A file of a
+// format which has not been requested is removed, as it may be left from a
+// previous build.
+func writeFileSBOMs(logger emitter, content sbom.SBOM, fileFormats []string, layerPath string) error {
+	tagValuePath := filepath.Join(layerPath, SPDXTagValueFileName)
+
+	tagValueRequested := false
+	for _, format := range fileFormats {
+		if format == composersbom.SPDXTagValueFormat {
+			tagValueRequested = true
+		}
+	}
+
+	if !tagValueRequested {
+		return os.RemoveAll(tagValuePath)
+	}
+
+	formatter, err := content.InFormats(sbom.SPDXFormat)
+	if err != nil {
+		return err
+	}
+
+	tagValue, err := composersbom.ConvertToSPDXTagValue(formatter.Formats()[0].Content)
+	if err != nil {
+		return err
+	}
+
+	logger.Subprocess("Writing %s", tagValuePath)
+	logger.Break()
+
+	return os.WriteFile(tagValuePath, tagValue, 0644)
+}
@@ -0,0 +1,59 @@
+package composer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	composersbom "github.com/paketo-buildpacks/composer/sbom"
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// addPhpExtensionsToSBOMIfRequired will check for env var
+// "BP_COMPOSER_SBOM_PHP_EXTENSIONS". If set to true, the given PHP extensions
+// required at runtime, i.e. the extensions listed in php-extensions.toml,
+// are added as libraries to the SBOM of the composer-packages layer, so that
+// the SBOM documents the extensions the application relies on, not only its
+// Composer packages. The SBOM cached in the layer is left unchanged, as the
+// extensions are determined by every build.
+func addPhpExtensionsToSBOMIfRequired(logger emitter, composerPackagesLayer *packit.Layer, extensions []PhpExtension) error {
+	enabled, err := lookupBoolEnv(BpComposerSBOMPhpExtensions, false)
+	if err != nil || !enabled {
+		return err
+	}
+
+	if composerPackagesLayer.SBOM == nil {
+		logger.Process("Skipping the PHP extensions in the SBOM, as no SBOM is generated")
+		logger.Break()
+		return nil
+	}
+
+	var names []string
+	for _, extension := range extensions {
+		names = append(names, extension.Name)
+	}
+
+	formats := composerPackagesLayer.SBOM.Formats()
+	for i, format := range formats {
+		content, err := io.ReadAll(format.Content)
+		if err != nil { // untested
+			return err
+		}
+
+		content, err = composersbom.AddPhpExtensions(content, format.Extension, names)
+		if err != nil {
+			return fmt.Errorf("failed to add the PHP extensions to the %s SBOM: %w", format.Extension, err)
+		}
+
+		formats[i].Content = bytes.NewReader(content)
+	}
+	composerPackagesLayer.SBOM = cachedSBOM(formats)
+
+	logger.Process("Listing %d PHP extension(s) in the SBOM", len(names))
+	for _, name := range names {
+		logger.Subprocess("ext-%s", name)
+	}
+	logger.Break()
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package composer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// sbomExcludesDevMetadataKey is the key of the composer-packages layer
+// metadata, which records whether the dev packages have been excluded from
+// the cached SBOM.
+const sbomExcludesDevMetadataKey = "sbom-excludes-dev"
+
+// devPackagesExcluded returns whether `composer install` has been run without
+// the dev packages.
+func devPackagesExcluded(installOptions []InstallOption) bool {
+	for _, option := range installOptions {
+		if option.Value == "--no-dev" {
+			return true
+		}
+	}
+	return false
+}
+
+// prepareSBOMSource prepares the working directory for the SBOM generation.
+// If the dev packages are excluded, `composer.lock` still lists them in
+// "packages-dev", and `vendor/composer/installed.json` in "packages". Both
+// are overlaid with copies without the dev packages while the SBOM is
+// generated, so that it describes the installed packages and all other
+// artifacts of the working directory. The returned cleanup restores the
+// originals.
+func prepareSBOMSource(workingDir, workspaceVendorDir string, excludeDev bool) (cleanup func() error, err error) {
+	var restores []func() error
+	cleanup = func() error {
+		var firstErr error
+		for _, restore := range restores {
+			err := restore()
+			if err != nil && firstErr == nil { // untested
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+
+	if !excludeDev {
+		return cleanup, nil
+	}
+
+	_, composerLockPath, _, _ := FindComposerFiles(workingDir)
+
+	restore, err := overlayDevPackages(composerLockPath, func(document map[string]json.RawMessage) error {
+		document["packages-dev"] = json.RawMessage("[]")
+		return nil
+	})
+	if err != nil {
+		return cleanup, err
+	}
+	restores = append(restores, restore)
+
+	restore, err = overlayDevPackages(filepath.Join(workspaceVendorDir, "composer", "installed.json"), pruneInstalledDevPackages)
+	if err != nil {
+		_ = cleanup()
+		return cleanup, err
+	}
+	restores = append(restores, restore)
+
+	return cleanup, nil
+}
+
+// overlayDevPackages overwrites the JSON document at path with the result of
+// applying prune, keeping all other keys, and returns a function restoring
+// the original. Nothing is overwritten if the document does not exist, or if
+// it is a list, such as the `installed.json` of Composer 1, which does not
+// record the dev packages.
+func overlayDevPackages(path string, prune func(map[string]json.RawMessage) error) (restore func() error, err error) {
+	restore = func() error { return nil }
+
+	original, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return restore, nil
+		}
+		return restore, err
+	}
+
+	var document map[string]json.RawMessage
+	err = json.Unmarshal(original, &document)
+	if err != nil {
+		var list []json.RawMessage
+		if json.Unmarshal(original, &list) == nil {
+			return restore, nil
+		}
+		return restore, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	err = prune(document)
+	if err != nil {
+		return restore, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	content, err := json.Marshal(document)
+	if err != nil { // untested
+		return restore, err
+	}
+
+	err = os.WriteFile(path, content, 0644)
+	if err != nil { // untested
+		return restore, err
+	}
+
+	return func() error {
+		return os.WriteFile(path, original, 0644)
+	}, nil
+}
+
+// pruneInstalledDevPackages removes the packages listed in
+// "dev-package-names" from the "packages" of an `installed.json` written by
+// Composer 2.
+func pruneInstalledDevPackages(document map[string]json.RawMessage) error {
+	var devPackageNames []string
+	if raw, ok := document["dev-package-names"]; ok {
+		err := json.Unmarshal(raw, &devPackageNames)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(devPackageNames) == 0 {
+		return nil
+	}
+
+	dev := map[string]bool{}
+	for _, name := range devPackageNames {
+		dev[name] = true
+	}
+
+	var packages []json.RawMessage
+	if raw, ok := document["packages"]; ok {
+		err := json.Unmarshal(raw, &packages)
+		if err != nil {
+			return err
+		}
+	}
+
+	kept := []json.RawMessage{}
+	for _, raw := range packages {
+		var p struct {
+			Name string `json:"name"`
+		}
+		err := json.Unmarshal(raw, &p)
+		if err != nil {
+			return err
+		}
+
+		if !dev[p.Name] {
+			kept = append(kept, raw)
+		}
+	}
+
+	content, err := json.Marshal(kept)
+	if err != nil { // untested
+		return err
+	}
+
+	document["packages"] = content
+	document["dev-package-names"] = json.RawMessage("[]")
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package composer
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// lookupScopedEnv returns the environment variables with the given prefix,
+// such as "BP_COMPOSER_INSTALL_ENV_", with the prefix removed. They are set
+// for a single `composer` subcommand only, e.g. to use another GitHub token
+// for `composer global` than for `composer install`.
+//
+// Only the names of the variables are logged, as their values are likely to
+// contain credentials.
+func lookupScopedEnv(logger emitter, prefix string, command string) []string {
+	var env []string
+	for _, variable := range os.Environ() {
+		if !strings.HasPrefix(variable, prefix) {
+			continue
+		}
+
+		variable = strings.TrimPrefix(variable, prefix)
+		if name, _, _ := strings.Cut(variable, "="); name == "" {
+			continue
+		}
+
+		env = append(env, variable)
+	}
+
+	if len(env) == 0 {
+		return nil
+	}
+
+	sort.Strings(env)
+
+	logger.Process("Setting environment variables for '%s'", command)
+	for _, variable := range env {
+		name, _, _ := strings.Cut(variable, "=")
+		logger.Subprocess("%s (from %s%s)", name, prefix, name)
+	}
+	logger.Break()
+
+	return env
+}
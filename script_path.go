@@ -0,0 +1,94 @@
+package composer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+// lookupScriptPathPrepend will check for env var
+// "BP_COMPOSER_SCRIPT_PATH_PREPEND", a list of absolute directories separated
+// like PATH, and return them. The directories are prepended to the PATH of
+// `composer install`, so that its scripts find the tools of other buildpacks,
+// which are not on the PATH of the build, e.g. in a layer of another
+// buildpack without a `bin` directory. Directories which do not exist are
+// reported, as they are likely a typo.
+func lookupScriptPathPrepend(logger emitter) ([]string, error) {
+	value := os.Getenv(BpComposerScriptPathPrepend)
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var entries []string
+	for _, entry := range filepath.SplitList(value) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !filepath.IsAbs(entry) {
+			return nil, fmt.Errorf("%s must only contain absolute paths, found %q", BpComposerScriptPathPrepend, entry)
+		}
+
+		entries = append(entries, filepath.Clean(entry))
+	}
+
+	logger.Process("Prepending to the PATH of 'composer install' (%s)", BpComposerScriptPathPrepend)
+	for _, entry := range entries {
+		if exists, err := fs.Exists(entry); err != nil {
+			return nil, err
+		} else if !exists {
+			logger.Subprocess("%s (WARNING: does not exist)", entry)
+			continue
+		}
+		logger.Subprocess("%s", entry)
+	}
+	logger.Break()
+
+	return entries, nil
+}
+
+// withPathPrepend returns an Executable which prepends the given directories
+// to the PATH of each execution before delegating to the given Executable, or
+// the given Executable itself if there are none.
+func withPathPrepend(executable Executable, entries []string) Executable {
+	if len(entries) == 0 {
+		return executable
+	}
+
+	return pathPrependExecutable{
+		executable: executable,
+		entries:    entries,
+	}
+}
+
+type pathPrependExecutable struct {
+	executable Executable
+	entries    []string
+}
+
+func (e pathPrependExecutable) Execute(execution pexec.Execution) error {
+	prefix := strings.Join(e.entries, string(os.PathListSeparator))
+
+	// the last PATH of the environment takes effect, so only it is changed
+	env := append([]string{}, execution.Env...)
+	found := false
+	for i := len(env) - 1; i >= 0; i-- {
+		if strings.HasPrefix(env[i], "PATH=") {
+			env[i] = fmt.Sprintf("PATH=%s%c%s", prefix, os.PathListSeparator, strings.TrimPrefix(env[i], "PATH="))
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		env = append(env, fmt.Sprintf("PATH=%s", prefix))
+	}
+
+	execution.Env = env
+	return e.executable.Execute(execution)
+}
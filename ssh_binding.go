@@ -0,0 +1,172 @@
+package composer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
+)
+
+const (
+	// sshKnownHostsEntry is the entry of a "composer-ssh" binding containing
+	// the known host keys, all other entries are treated as private keys
+	sshKnownHostsEntry = "known_hosts"
+
+	// gitSSHCommand is the environment variable read by git to determine the
+	// ssh command to use
+	gitSSHCommand = "GIT_SSH_COMMAND"
+)
+
+// composerSSH provides access to private VCS repositories over SSH.
+type composerSSH struct {
+	// env is added to the environment of the executions which may clone
+	// VCS repositories
+	env []string
+
+	// dir contains the SSH config, private keys and known hosts, it is
+	// outside of the layers and must be removed after the build
+	dir string
+}
+
+// prepareComposerSSHIfRequired will check for service bindings of type
+// "composer-ssh". If any are found, their private keys and known hosts are
+// written to a temporary directory, alongside an SSH config which is used by
+// git via GIT_SSH_COMMAND. If composer runs as another user, see
+// lookupRunAs, the private keys and known hosts are handed over to it, as
+// they are only readable by their owner.
+func prepareComposerSSHIfRequired(logger emitter, context packit.BuildContext, bindingResolver BindingResolver, runAs *VendorOwner) (composerSSH, error) {
+	bindings, err := bindingResolver.Resolve(ComposerSSHBindingType, "", context.Platform.Path)
+	if err != nil {
+		return composerSSH{}, err
+	}
+
+	if len(bindings) == 0 {
+		return composerSSH{}, nil
+	}
+
+	dir, err := os.MkdirTemp("", "composer-ssh")
+	if err != nil { // untested
+		return composerSSH{}, err
+	}
+
+	ssh := composerSSH{dir: dir}
+
+	err = ssh.write(logger, bindings)
+	if err != nil {
+		_ = ssh.cleanup()
+		return composerSSH{}, err
+	}
+
+	// ssh refuses configs owned by other users than root and itself, so the
+	// config is left to the build user, and made readable by everyone
+	if runAs != nil {
+		_, err = handOver([]ownedPath{{path: dir, tree: true}}, *runAs)
+		if err == nil {
+			err = os.Lchown(filepath.Join(dir, "config"), os.Getuid(), os.Getgid())
+		}
+		if err == nil {
+			err = os.Chmod(filepath.Join(dir, "config"), 0644)
+		}
+		if err != nil {
+			_ = ssh.cleanup()
+			return composerSSH{}, err
+		}
+	}
+
+	return ssh, nil
+}
+
+func (s *composerSSH) write(logger emitter, bindings []servicebindings.Binding) error {
+	var identityFiles []string
+	var knownHosts []string
+
+	logger.Process("Configuring SSH for private VCS repositories")
+
+	for _, binding := range bindings {
+		logger.Subprocess("Using binding '%s'", binding.Name)
+
+		names := make([]string, 0, len(binding.Entries))
+		for name := range binding.Entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			content, err := binding.Entries[name].ReadString()
+			if err != nil {
+				return fmt.Errorf("failed to read entry %q of binding %q: %w", name, binding.Name, err)
+			}
+
+			if !strings.HasSuffix(content, "\n") {
+				content += "\n"
+			}
+
+			if name == sshKnownHostsEntry {
+				knownHosts = append(knownHosts, content)
+				continue
+			}
+
+			// ssh refuses to use private keys which are readable by others
+			identityFile := filepath.Join(s.dir, fmt.Sprintf("%s-%s", binding.Name, name))
+			err = os.WriteFile(identityFile, []byte(content), 0600)
+			if err != nil { // untested
+				return err
+			}
+
+			identityFiles = append(identityFiles, identityFile)
+		}
+	}
+
+	if len(identityFiles) == 0 {
+		return fmt.Errorf("no private keys found in bindings of type %q", ComposerSSHBindingType)
+	}
+
+	knownHostsFile := filepath.Join(s.dir, sshKnownHostsEntry)
+	err := os.WriteFile(knownHostsFile, []byte(strings.Join(knownHosts, "")), 0600)
+	if err != nil { // untested
+		return err
+	}
+
+	strictHostKeyChecking := "yes"
+	if len(knownHosts) == 0 {
+		strictHostKeyChecking = "accept-new"
+		logger.Subprocess("WARNING: No '%s' found in the bindings, host keys will not be verified", sshKnownHostsEntry)
+	}
+
+	config := []string{
+		"Host *",
+		"  IdentitiesOnly yes",
+		fmt.Sprintf("  UserKnownHostsFile %s", knownHostsFile),
+		fmt.Sprintf("  StrictHostKeyChecking %s", strictHostKeyChecking),
+	}
+	for _, identityFile := range identityFiles {
+		config = append(config, fmt.Sprintf("  IdentityFile %s", identityFile))
+	}
+
+	configFile := filepath.Join(s.dir, "config")
+	err = os.WriteFile(configFile, []byte(strings.Join(config, "\n")+"\n"), 0600)
+	if err != nil { // untested
+		return err
+	}
+
+	s.env = []string{fmt.Sprintf("%s=ssh -F %s", gitSSHCommand, configFile)}
+
+	logger.Subprocess("%s", s.env[0])
+	logger.Break()
+
+	return nil
+}
+
+// cleanup removes the private keys, so that they are not part of any layer
+// or image.
+func (s composerSSH) cleanup() error {
+	if s.dir == "" {
+		return nil
+	}
+
+	return os.RemoveAll(s.dir)
+}
@@ -0,0 +1,208 @@
+package composer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// StabilityPolicyOff ignores the minimum-stability of `composer.json`
+	StabilityPolicyOff = "off"
+
+	// StabilityPolicyWarn logs a minimum-stability below the threshold
+	StabilityPolicyWarn = "warn"
+
+	// StabilityPolicyFail fails the build if the minimum-stability is below
+	// the threshold
+	StabilityPolicyFail = "fail"
+)
+
+// composerStabilities are the stabilities of Composer, from the most to the
+// least stable.
+// https://getcomposer.org/doc/04-schema.md#minimum-stability
+var composerStabilities = []string{"stable", "RC", "beta", "alpha", "dev"}
+
+// ParseStability returns the given stability as spelled by Composer, e.g.
+// "RC" for "rc".
+func ParseStability(value string) (string, error) {
+	for _, stability := range composerStabilities {
+		if strings.EqualFold(value, stability) {
+			return stability, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown stability %q, expected one of %s", value, strings.Join(composerStabilities, ", "))
+}
+
+// stabilityRank returns the position of the given stability in
+// composerStabilities, the higher the less stable.
+func stabilityRank(stability string) int {
+	for i, s := range composerStabilities {
+		if s == stability {
+			return i
+		}
+	}
+	return len(composerStabilities)
+}
+
+// versionStabilityPattern matches the modifier of a version, like Composer's
+// VersionParser::parseStability, e.g. "-beta2" of "1.0.0-beta2".
+var versionStabilityPattern = regexp.MustCompile(`(?i)[._-]?(?:(stable|beta|b|rc|alpha|a|patch|pl|p)(?:[.-]?\d+)*)?([.-]?dev)?(?:\+.*)?$`)
+
+// VersionStability returns the stability of the given version of a package,
+// e.g. "beta" for "1.0.0-beta2" and "dev" for "dev-main".
+func VersionStability(version string) string {
+	version, _, _ = strings.Cut(version, "#")
+
+	if strings.HasPrefix(version, "dev-") || strings.HasSuffix(version, "-dev") {
+		return "dev"
+	}
+
+	matches := versionStabilityPattern.FindStringSubmatch(version)
+	if matches == nil { // untested
+		return "stable"
+	}
+
+	switch {
+	case matches[2] != "":
+		return "dev"
+	case strings.EqualFold(matches[1], "beta") || strings.EqualFold(matches[1], "b"):
+		return "beta"
+	case strings.EqualFold(matches[1], "alpha") || strings.EqualFold(matches[1], "a"):
+		return "alpha"
+	case strings.EqualFold(matches[1], "rc"):
+		return "RC"
+	}
+
+	return "stable"
+}
+
+// checkStabilityPolicyIfRequired will check for env var
+// "BP_COMPOSER_STABILITY_POLICY". If set to "warn" or "fail", a
+// "minimum-stability" of `composer.json` below the stability of
+// "BP_COMPOSER_STABILITY_THRESHOLD", which defaults to "stable", is logged,
+// or fails the build. The message names the packages of `composer.lock`
+// below the threshold, and whether "prefer-stable" is set, which makes
+// Composer prefer stable packages, but does not prevent unstable ones. It
+// runs before `composer install`, so that no unstable package is downloaded.
+func checkStabilityPolicyIfRequired(logger emitter, composerJsonPath, composerLockPath string) error {
+	policy := strings.ToLower(os.Getenv(BpComposerStabilityPolicy))
+	switch policy {
+	case "", StabilityPolicyOff:
+		return nil
+	case StabilityPolicyWarn, StabilityPolicyFail:
+	default:
+		return fmt.Errorf("%s must be one of %q, %q or %q, found %q", BpComposerStabilityPolicy, StabilityPolicyOff, StabilityPolicyWarn, StabilityPolicyFail, policy)
+	}
+
+	threshold := "stable"
+	if value, ok := os.LookupEnv(BpComposerStabilityThreshold); ok {
+		var err error
+		threshold, err = ParseStability(value)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", BpComposerStabilityThreshold, err)
+		}
+	}
+
+	if exists, err := pathExists(composerJsonPath); err != nil {
+		return err
+	} else if !exists {
+		return nil
+	}
+
+	content, err := readComposerFile(composerJsonPath)
+	if err != nil { // untested
+		return err
+	}
+
+	var composerJson struct {
+		MinimumStability string `json:"minimum-stability"`
+		PreferStable     bool   `json:"prefer-stable"`
+	}
+
+	err = json.Unmarshal(content, &composerJson)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", composerJsonPath, err)
+	}
+
+	minimumStability := "stable"
+	if composerJson.MinimumStability != "" {
+		minimumStability, err = ParseStability(composerJson.MinimumStability)
+		if err != nil {
+			return fmt.Errorf("invalid minimum-stability of %s: %w", composerJsonPath, err)
+		}
+	}
+
+	logger.Process("Checking the minimum-stability %q against the threshold %q", minimumStability, threshold)
+
+	if stabilityRank(minimumStability) <= stabilityRank(threshold) {
+		logger.Subprocess("The minimum-stability satisfies the threshold")
+		logger.Break()
+		return nil
+	}
+
+	unstable, err := findUnstablePackages(composerLockPath, threshold)
+	if err != nil {
+		return err
+	}
+
+	violation := fmt.Sprintf("composer.json allows packages of stability %q (minimum-stability), which is below %q", minimumStability, threshold)
+	if composerJson.PreferStable {
+		violation += ", prefer-stable is set"
+	}
+	if len(unstable) > 0 {
+		violation += fmt.Sprintf(", composer.lock contains %d package(s) below %q: %s", len(unstable), threshold, strings.Join(unstable, ", "))
+	}
+
+	if policy == StabilityPolicyFail {
+		return fmt.Errorf("%s (%s=%s)", violation, BpComposerStabilityPolicy, policy)
+	}
+
+	logger.Subprocess("WARNING: %s", violation)
+	logger.Break()
+
+	return nil
+}
+
+// findUnstablePackages returns the packages of the given `composer.lock`,
+// whose version is less stable than the given stability, e.g.
+// "vendor/package (dev-main)".
+func findUnstablePackages(composerLockPath, threshold string) ([]string, error) {
+	content, err := readComposerFile(composerLockPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	type lockedPackage struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+
+	var composerLock struct {
+		Packages    []lockedPackage `json:"packages"`
+		PackagesDev []lockedPackage `json:"packages-dev"`
+	}
+
+	err = json.Unmarshal(content, &composerLock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", composerLockPath, err)
+	}
+
+	var unstable []string
+	for _, p := range append(composerLock.Packages, composerLock.PackagesDev...) {
+		if stabilityRank(VersionStability(p.Version)) > stabilityRank(threshold) {
+			unstable = append(unstable, fmt.Sprintf("%s (%s)", p.Name, p.Version))
+		}
+	}
+	sort.Strings(unstable)
+
+	return unstable, nil
+}
@@ -0,0 +1,52 @@
+package composer_test
+
+import (
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testStabilityPolicy(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("ParseStability", func() {
+		it("returns the stability as spelled by Composer", func() {
+			Expect(composer.ParseStability("rc")).To(Equal("RC"))
+			Expect(composer.ParseStability("Dev")).To(Equal("dev"))
+			Expect(composer.ParseStability("stable")).To(Equal("stable"))
+		})
+
+		context("when the stability is unknown", func() {
+			it("returns an error", func() {
+				_, err := composer.ParseStability("unstable")
+				Expect(err).To(MatchError(`unknown stability "unstable", expected one of stable, RC, beta, alpha, dev`))
+			})
+		})
+	})
+
+	context("VersionStability", func() {
+		it("returns the stability of the version", func() {
+			for _, example := range []struct {
+				version   string
+				stability string
+			}{
+				{version: "1.2.0", stability: "stable"},
+				{version: "v2.0.0", stability: "stable"},
+				{version: "1.0.0-patch1", stability: "stable"},
+				{version: "1.0.0+build.5", stability: "stable"},
+				{version: "v1.0.0-RC2", stability: "RC"},
+				{version: "1.0.0-beta.1", stability: "beta"},
+				{version: "1.0.0b2", stability: "beta"},
+				{version: "2.0.0-alpha", stability: "alpha"},
+				{version: "dev-main", stability: "dev"},
+				{version: "1.x-dev", stability: "dev"},
+				{version: "dev-feature#abc123", stability: "dev"},
+			} {
+				Expect(composer.VersionStability(example.version)).To(Equal(example.stability), example.version)
+			}
+		})
+	})
+}
@@ -0,0 +1,98 @@
+package composer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+// stackRevalidationRequested will check for env var
+// "BP_COMPOSER_REVALIDATE_STACK". If set to true, the composer-packages layer
+// built on another stack, but otherwise unchanged, is revalidated against the
+// current stack instead of rebuilt from scratch, see revalidateStack. Layers
+// with native binaries are always rebuilt, as these may be linked against the
+// libraries of the previous stack.
+func stackRevalidationRequested(logger emitter, layerVendorDir, previousStack, stack string) (bool, error) {
+	enabled, err := lookupBoolEnv(BpComposerRevalidateStack, false)
+	if err != nil || !enabled {
+		return false, err
+	}
+
+	if exists, err := fs.Exists(layerVendorDir); err != nil {
+		return false, err
+	} else if !exists {
+		return false, nil
+	}
+
+	packages, err := findNativeBinaries(layerVendorDir)
+	if err != nil {
+		return false, err
+	}
+
+	if len(packages) > 0 {
+		logger.Process("Not revalidating the cached layer, as the cached packages contain native binaries: %s", strings.Join(packages, ", "))
+		return false, nil
+	}
+
+	logger.Process("Revalidating the cached layer built on stack %q for stack %q (%s)", previousStack, stack, BpComposerRevalidateStack)
+	logger.Break()
+
+	return true, nil
+}
+
+// revalidateStack copies the vendor directory of the cached layer into the
+// working directory, and runs the given install on it, which only verifies
+// the installed packages and runs the scripts on the current stack. The
+// packages are accepted, unless `composer check-platform-reqs` then reports
+// any requirement as "failed", i.e. the PHP or an extension of the stack has a
+// version not satisfying them. Missing extensions are accepted, as they are
+// loaded at runtime, see runCheckPlatformReqs.
+func revalidateStack(logger emitter, fileSystem FileSystem, install func() error, checkPlatformReqsExec Executable, capabilities composerCapabilities, workingDir, layerVendorDir, workspaceVendorDir, composerPhpIniPath, path string) error {
+	if exists, err := fs.Exists(workspaceVendorDir); err != nil {
+		return err
+	} else if exists {
+		err = fileSystem.RemoveAll(workspaceVendorDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	logger.Process("Copying from %s => to %s", layerVendorDir, workspaceVendorDir)
+	err := copyTree(logger, layerVendorDir, workspaceVendorDir)
+	if err != nil { // untested
+		return err
+	}
+
+	err = install()
+	if err != nil {
+		return err
+	}
+
+	requirements, _, err := readPlatformRequirements(logger, checkPlatformReqsExec, capabilities, workingDir, composerPhpIniPath, path)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, requirement := range requirements {
+		if requirement.Status != "failed" {
+			continue
+		}
+
+		if requirement.FailedRequirement != nil {
+			failed = append(failed, fmt.Sprintf("%s %s (%s requires %s)", requirement.Name, requirement.Version, requirement.FailedRequirement.Source, requirement.FailedRequirement.Constraint))
+		} else {
+			failed = append(failed, fmt.Sprintf("%s %s", requirement.Name, requirement.Version))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("the stack does not satisfy the platform requirements: %s", strings.Join(failed, ", "))
+	}
+
+	logger.Subprocess("The cached packages satisfy the platform requirements of the stack")
+	logger.Break()
+
+	return nil
+}
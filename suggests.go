@@ -0,0 +1,166 @@
+package composer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SuggestedPackage is a package or PHP extension, which installed packages
+// suggest, but which is not installed.
+type SuggestedPackage struct {
+	Name string
+
+	// SuggestedBy are the names of the installed packages suggesting it,
+	// sorted by name
+	SuggestedBy []string
+
+	// Reasons are the distinct reasons given by the installed packages
+	Reasons []string
+}
+
+// Extension returns whether the suggested package is a PHP extension, e.g.
+// "ext-redis".
+func (p SuggestedPackage) Extension() bool {
+	return strings.HasPrefix(p.Name, "ext-")
+}
+
+// FindSuggestedPackages returns the packages and PHP extensions suggested by
+// the packages of the given `installed.json`, which are neither installed
+// nor one of the given PHP extensions, deduplicated across the suggesting
+// packages. The PHP extensions come first, as they are often relevant for
+// the performance, followed by the packages, each sorted by name.
+func FindSuggestedPackages(installedJsonPath string, extensions []PhpExtension) ([]SuggestedPackage, error) {
+	content, err := os.ReadFile(installedJsonPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	type suggestingPackage struct {
+		Name    string          `json:"name"`
+		Suggest json.RawMessage `json:"suggest"`
+		Replace json.RawMessage `json:"replace"`
+		Provide json.RawMessage `json:"provide"`
+	}
+
+	var installedJson struct {
+		Packages []suggestingPackage `json:"packages"`
+	}
+
+	if err = json.Unmarshal(content, &installedJson); err != nil {
+		// Composer 1 writes the packages as a list
+		if err = json.Unmarshal(content, &installedJson.Packages); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", installedJsonPath, err)
+		}
+	}
+
+	// an empty map is written as a list by PHP, so the maps are parsed
+	// leniently
+	links := func(raw json.RawMessage) map[string]string {
+		var m map[string]string
+		_ = json.Unmarshal(raw, &m)
+		return m
+	}
+
+	installed := map[string]bool{}
+	for _, p := range installedJson.Packages {
+		installed[strings.ToLower(p.Name)] = true
+		for name := range links(p.Replace) {
+			installed[strings.ToLower(name)] = true
+		}
+		for name := range links(p.Provide) {
+			installed[strings.ToLower(name)] = true
+		}
+	}
+
+	for _, extension := range extensions {
+		installed["ext-"+strings.ToLower(extension.Name)] = true
+	}
+
+	suggested := map[string]*SuggestedPackage{}
+	for _, p := range installedJson.Packages {
+		for name, reason := range links(p.Suggest) {
+			key := strings.ToLower(name)
+			if installed[key] {
+				continue
+			}
+
+			s, ok := suggested[key]
+			if !ok {
+				s = &SuggestedPackage{Name: key}
+				suggested[key] = s
+			}
+
+			s.SuggestedBy = appendUnique(s.SuggestedBy, p.Name)
+			if reason = strings.TrimSpace(reason); reason != "" {
+				s.Reasons = appendUnique(s.Reasons, reason)
+			}
+		}
+	}
+
+	var result []SuggestedPackage
+	for _, s := range suggested {
+		sort.Strings(s.SuggestedBy)
+		result = append(result, *s)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Extension() != result[j].Extension() {
+			return result[i].Extension()
+		}
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// reportSuggestedPackagesIfRequired will check for env var
+// "BP_COMPOSER_SHOW_SUGGESTS". If set to true, the packages and PHP
+// extensions suggested by the installed packages, which are not installed,
+// are logged after the install, see FindSuggestedPackages. The reasons given
+// by the suggesting packages are logged at the DEBUG level.
+func reportSuggestedPackagesIfRequired(logger emitter, workspaceVendorDir string, extensions []PhpExtension) error {
+	enabled, err := lookupBoolEnv(BpComposerShowSuggests, false)
+	if err != nil || !enabled {
+		return err
+	}
+
+	suggested, err := FindSuggestedPackages(filepath.Join(workspaceVendorDir, "composer", "installed.json"), extensions)
+	if err != nil {
+		return err
+	}
+
+	extensionCount := 0
+	for _, s := range suggested {
+		if s.Extension() {
+			extensionCount++
+		}
+	}
+
+	logger.Process("Suggested by the installed packages (%s): %d PHP extension(s), %d package(s)", BpComposerShowSuggests, extensionCount, len(suggested)-extensionCount)
+	for _, s := range suggested {
+		logger.Subprocess("%s (suggested by %s)", s.Name, strings.Join(s.SuggestedBy, ", "))
+		for _, reason := range s.Reasons {
+			logger.Debug.Action("%s", reason)
+		}
+	}
+	logger.Break()
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package composer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testSuggests(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		installedJsonPath string
+	)
+
+	it.Before(func() {
+		installedJsonPath = filepath.Join(t.TempDir(), "installed.json")
+		Expect(os.WriteFile(installedJsonPath, []byte(`{
+    "packages": [
+        {
+            "name": "predis/predis",
+            "suggest": {"ext-redis": "Faster than the PHP implementation", "ext-curl": "Allows access to Webdis"}
+        },
+        {
+            "name": "symfony/cache",
+            "suggest": {"ext-redis": "For the Redis adapter", "ext-APCu": "For the APCu adapter", "doctrine/dbal": "For the DBAL adapter", "psr/log": ""},
+            "provide": {"psr/cache-implementation": "1.0"}
+        },
+        {
+            "name": "monolog/monolog",
+            "suggest": [],
+            "replace": {"psr/log": "*"}
+        }
+    ]
+}`), 0644)).To(Succeed())
+	})
+
+	context("FindSuggestedPackages", func() {
+		it("returns the suggested extensions and packages, which are not installed", func() {
+			suggested, err := composer.FindSuggestedPackages(installedJsonPath, []composer.PhpExtension{{Name: "curl"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(suggested).To(Equal([]composer.SuggestedPackage{
+				{Name: "ext-apcu", SuggestedBy: []string{"symfony/cache"}, Reasons: []string{"For the APCu adapter"}},
+				{Name: "ext-redis", SuggestedBy: []string{"predis/predis", "symfony/cache"}, Reasons: []string{"Faster than the PHP implementation", "For the Redis adapter"}},
+				{Name: "doctrine/dbal", SuggestedBy: []string{"symfony/cache"}, Reasons: []string{"For the DBAL adapter"}},
+			}))
+		})
+
+		it("returns nothing without installed.json", func() {
+			suggested, err := composer.FindSuggestedPackages(filepath.Join(t.TempDir(), "installed.json"), nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(suggested).To(BeEmpty())
+		})
+
+		it("reads the packages of Composer 1", func() {
+			Expect(os.WriteFile(installedJsonPath, []byte(`[{"name": "predis/predis", "suggest": {"ext-redis": ""}}]`), 0644)).To(Succeed())
+
+			suggested, err := composer.FindSuggestedPackages(installedJsonPath, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(suggested).To(Equal([]composer.SuggestedPackage{
+				{Name: "ext-redis", SuggestedBy: []string{"predis/predis"}},
+			}))
+		})
+
+		context("failure cases", func() {
+			context("when installed.json is not valid JSON", func() {
+				it("returns an error", func() {
+					Expect(os.WriteFile(installedJsonPath, []byte(`%%%`), 0644)).To(Succeed())
+
+					_, err := composer.FindSuggestedPackages(installedJsonPath, nil)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse")))
+				})
+			})
+		})
+	})
+}
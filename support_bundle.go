@@ -0,0 +1,156 @@
+package composer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// SupportBundleFileName is the name of the support bundle in the
+// composer-support-bundle layer.
+const SupportBundleFileName = "support-bundle.tar.gz"
+
+type supportBundleFile struct {
+	name    string
+	content []byte
+}
+
+// writeSupportBundleIfRequired will check for env var
+// "BP_COMPOSER_SUPPORT_BUNDLE". If set to true, the information needed to
+// investigate the failed build is collected into a tarball in the
+// composer-support-bundle layer:
+//   - composer.json and composer.lock
+//   - the executed commands, with secrets redacted
+//   - the php.ini used by Composer
+//   - the output of `composer diagnose`
+//   - the error which failed the build
+//
+// Failing to write the support bundle is logged, but does not replace the
+// error which failed the build.
+func writeSupportBundleIfRequired(
+	logger emitter,
+	context packit.BuildContext,
+	commandLog *CommandLog,
+	composerDiagnoseExec Executable,
+	path string,
+	buildErr error) {
+	enabled, err := lookupBoolEnv(BpComposerSupportBundle, false)
+	if err != nil || !enabled {
+		return
+	}
+
+	bundlePath, err := writeSupportBundle(logger, context, commandLog, composerDiagnoseExec, path, buildErr)
+	if err != nil {
+		logger.Process("Failed to write support bundle: %s", err)
+		logger.Break()
+		return
+	}
+
+	logger.Process("Wrote support bundle to %s", bundlePath)
+	logger.Subprocess("Please attach it when reporting the failed build. Secrets in the environment have been redacted,")
+	logger.Subprocess("but composer.json and composer.lock are included as they are.")
+	logger.Break()
+}
+
+func writeSupportBundle(
+	logger emitter,
+	context packit.BuildContext,
+	commandLog *CommandLog,
+	composerDiagnoseExec Executable,
+	path string,
+	buildErr error) (string, error) {
+	var files []supportBundleFile
+
+	composerJsonPath, composerLockPath, _, _ := FindComposerFiles(context.WorkingDir)
+	composerPhpIniPath := filepath.Join(context.Layers.Path, ComposerPhpIniLayerName, "composer-php.ini")
+
+	for _, file := range []string{composerJsonPath, composerLockPath, composerPhpIniPath} {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return "", err
+		}
+
+		name := filepath.Base(file)
+		if relativePath, err := filepath.Rel(context.WorkingDir, file); err == nil && !strings.HasPrefix(relativePath, "..") {
+			name = relativePath
+		}
+
+		files = append(files, supportBundleFile{name: name, content: content})
+	}
+
+	var commands []string
+	for _, entry := range commandLog.Entries() {
+		commands = append(commands, entry.String())
+	}
+	files = append(files, supportBundleFile{name: CommandLogFileName, content: []byte(strings.Join(commands, "\n"))})
+
+	logger.Process("Running 'composer diagnose' for the support bundle")
+	diagnose := bytes.NewBuffer(nil)
+	runComposerDiagnose(composerDiagnoseExec, context.WorkingDir, append(os.Environ(),
+		"COMPOSER_NO_INTERACTION=1", // https://getcomposer.org/doc/03-cli.md#composer-no-interaction
+		fmt.Sprintf("COMPOSER=%s", composerJsonPath),
+		fmt.Sprintf("PHPRC=%s", composerPhpIniPath),
+		fmt.Sprintf("PATH=%s", path),
+	), diagnose)
+	files = append(files, supportBundleFile{name: "composer-diagnose.txt", content: diagnose.Bytes()})
+
+	files = append(files, supportBundleFile{name: "error.txt", content: []byte(buildErr.Error() + "\n")})
+
+	supportBundleLayer, err := context.Layers.Get(ComposerSupportBundleLayerName)
+	if err != nil { // untested
+		return "", err
+	}
+
+	supportBundleLayer, err = supportBundleLayer.Reset()
+	if err != nil { // untested
+		return "", err
+	}
+
+	bundlePath := filepath.Join(supportBundleLayer.Path, SupportBundleFileName)
+	return bundlePath, writeTarGz(bundlePath, files)
+}
+
+func writeTarGz(path string, files []supportBundleFile) error {
+	file, err := os.Create(path)
+	if err != nil { // untested
+		return err
+	}
+	defer file.Close()
+
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for _, f := range files {
+		err = tarWriter.WriteHeader(&tar.Header{
+			Name:    f.name,
+			Mode:    0644,
+			Size:    int64(len(f.content)),
+			ModTime: time.Now(),
+		})
+		if err != nil { // untested
+			return err
+		}
+
+		_, err = tarWriter.Write(f.content)
+		if err != nil { // untested
+			return err
+		}
+	}
+
+	err = tarWriter.Close()
+	if err != nil { // untested
+		return err
+	}
+
+	return gzipWriter.Close()
+}
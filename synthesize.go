@@ -0,0 +1,110 @@
+package composer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+// synthesizedComposerJson is written for applications without a
+// `composer.json`. It autoloads all classes found in the application with a
+// classmap, and disables Packagist, as the application has no dependencies.
+// Composer excludes the vendor directory from the classmap of the project
+// root by itself.
+const synthesizedComposerJson = `{
+    "autoload": {
+        "classmap": ["./"]
+    },
+    "repositories": [
+        {
+            "packagist.org": false
+        }
+    ]
+}
+`
+
+// errPhpFileFound stops walking the working directory at the first PHP file.
+var errPhpFileFound = errors.New("PHP file found")
+
+// composerJsonSynthesisRequested will check for env var
+// "BP_COMPOSER_SYNTHESIZE". If set to true, a `composer.json` is synthesized
+// for applications which contain PHP files, but no `composer.json`. It is
+// never synthesized if COMPOSER or BP_COMPOSER_FILE is set, as the application
+// explicitly points to its own `composer.json` then.
+func composerJsonSynthesisRequested(workingDir string) (bool, error) {
+	enabled, err := lookupBoolEnv(BpComposerSynthesize, false)
+	if err != nil {
+		return false, err
+	}
+
+	if !enabled {
+		return false, nil
+	}
+
+	if _, _, _, found := FindComposerFiles(workingDir); found {
+		return false, nil
+	}
+
+	if exists, err := fs.Exists(filepath.Join(workingDir, DefaultComposerJsonPath)); err != nil {
+		return false, err
+	} else if exists {
+		return false, nil
+	}
+
+	return containsPhpFiles(workingDir)
+}
+
+// containsPhpFiles returns whether there is any `*.php` file in the given
+// directory or its subdirectories. Hidden directories, such as `.git`, are
+// skipped.
+func containsPhpFiles(dir string) (bool, error) {
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if path != dir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if filepath.Ext(entry.Name()) == ".php" {
+			return errPhpFileFound
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, errPhpFileFound) {
+		return true, nil
+	}
+
+	return false, err
+}
+
+// synthesizeComposerJsonIfRequired writes a minimal `composer.json` into the
+// working directory, see composerJsonSynthesisRequested. `composer install`
+// then generates `vendor/autoload.php` for the classes of the application.
+func synthesizeComposerJsonIfRequired(logger emitter, workingDir string) error {
+	requested, err := composerJsonSynthesisRequested(workingDir)
+	if err != nil {
+		return err
+	}
+
+	if !requested {
+		return nil
+	}
+
+	composerJsonPath := filepath.Join(workingDir, DefaultComposerJsonPath)
+
+	logger.Process("No %s found, synthesizing %s", DefaultComposerJsonPath, composerJsonPath)
+	logger.Subprocess("Autoloading all classes of the application with a classmap")
+	logger.Break()
+
+	return os.WriteFile(composerJsonPath, []byte(synthesizedComposerJson), 0644)
+}
@@ -0,0 +1,79 @@
+package composer
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// environment variables provided by the lifecycle to describe the target of
+// the build, i.e. the run image
+// https://github.com/buildpacks/spec/blob/main/buildpack.md#targets
+const (
+	CnbTargetOS            = "CNB_TARGET_OS"
+	CnbTargetArch          = "CNB_TARGET_ARCH"
+	CnbTargetArchVariant   = "CNB_TARGET_ARCH_VARIANT"
+	CnbTargetDistroName    = "CNB_TARGET_DISTRO_NAME"
+	CnbTargetDistroVersion = "CNB_TARGET_DISTRO_VERSION"
+)
+
+// BuildTarget is the target of the build, which PHP extensions with native
+// dependencies, e.g. the binaries of vendored packages, must match.
+type BuildTarget struct {
+	OS            string `toml:"os"`
+	Arch          string `toml:"arch"`
+	ArchVariant   string `toml:"arch-variant,omitempty"`
+	DistroName    string `toml:"distro-name,omitempty"`
+	DistroVersion string `toml:"distro-version,omitempty"`
+}
+
+// LookupBuildTarget returns the target of the build. Platforms before API
+// 0.12 do not provide it, in which case the OS and architecture of the build
+// are used, and the distribution is unknown.
+func LookupBuildTarget() BuildTarget {
+	target := BuildTarget{
+		OS:            os.Getenv(CnbTargetOS),
+		Arch:          os.Getenv(CnbTargetArch),
+		ArchVariant:   os.Getenv(CnbTargetArchVariant),
+		DistroName:    os.Getenv(CnbTargetDistroName),
+		DistroVersion: os.Getenv(CnbTargetDistroVersion),
+	}
+
+	if target.OS == "" {
+		target.OS = runtime.GOOS
+	}
+
+	if target.Arch == "" {
+		target.Arch = runtime.GOARCH
+	}
+
+	return target
+}
+
+// Distro returns the name and version of the distribution, e.g.
+// "ubuntu 24.04", or an empty string if it is unknown.
+func (t BuildTarget) Distro() string {
+	if t.DistroName == "" {
+		return ""
+	}
+
+	if t.DistroVersion == "" {
+		return t.DistroName
+	}
+
+	return fmt.Sprintf("%s %s", t.DistroName, t.DistroVersion)
+}
+
+// String returns the target as logged, e.g. "ubuntu 24.04 (linux/amd64)".
+func (t BuildTarget) String() string {
+	platform := fmt.Sprintf("%s/%s", t.OS, t.Arch)
+	if t.ArchVariant != "" {
+		platform = fmt.Sprintf("%s/%s", platform, t.ArchVariant)
+	}
+
+	if t.Distro() == "" {
+		return platform
+	}
+
+	return fmt.Sprintf("%s (%s)", t.Distro(), platform)
+}
@@ -0,0 +1,62 @@
+package composer_test
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testBuildTarget(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("LookupBuildTarget", func() {
+		it("defaults to the OS and architecture of the build", func() {
+			target := composer.LookupBuildTarget()
+			Expect(target).To(Equal(composer.BuildTarget{OS: runtime.GOOS, Arch: runtime.GOARCH}))
+			Expect(target.Distro()).To(BeEmpty())
+			Expect(target.String()).To(Equal(runtime.GOOS + "/" + runtime.GOARCH))
+		})
+
+		context("with the target provided by the platform", func() {
+			it.Before(func() {
+				Expect(os.Setenv("CNB_TARGET_OS", "linux")).To(Succeed())
+				Expect(os.Setenv("CNB_TARGET_ARCH", "arm")).To(Succeed())
+				Expect(os.Setenv("CNB_TARGET_ARCH_VARIANT", "v7")).To(Succeed())
+				Expect(os.Setenv("CNB_TARGET_DISTRO_NAME", "ubuntu")).To(Succeed())
+				Expect(os.Setenv("CNB_TARGET_DISTRO_VERSION", "22.04")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("CNB_TARGET_OS")).To(Succeed())
+				Expect(os.Unsetenv("CNB_TARGET_ARCH")).To(Succeed())
+				Expect(os.Unsetenv("CNB_TARGET_ARCH_VARIANT")).To(Succeed())
+				Expect(os.Unsetenv("CNB_TARGET_DISTRO_NAME")).To(Succeed())
+				Expect(os.Unsetenv("CNB_TARGET_DISTRO_VERSION")).To(Succeed())
+			})
+
+			it("returns it", func() {
+				target := composer.LookupBuildTarget()
+				Expect(target).To(Equal(composer.BuildTarget{
+					OS:            "linux",
+					Arch:          "arm",
+					ArchVariant:   "v7",
+					DistroName:    "ubuntu",
+					DistroVersion: "22.04",
+				}))
+				Expect(target.Distro()).To(Equal("ubuntu 22.04"))
+				Expect(target.String()).To(Equal("ubuntu 22.04 (linux/arm/v7)"))
+			})
+		})
+	})
+
+	context("Distro", func() {
+		it("returns the name only if the version is unknown", func() {
+			Expect(composer.BuildTarget{DistroName: "alpine"}.Distro()).To(Equal("alpine"))
+		})
+	})
+}
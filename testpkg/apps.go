@@ -0,0 +1,74 @@
+// Package testpkg contains helpers for the integration suites of buildpacks
+// building on top of this buildpack, e.g. buildpacks requiring
+// "composer-packages". It provides applications to build, helpers to mutate
+// their `composer.lock`, and matchers for the build logs.
+package testpkg
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+//go:embed all:testdata
+var apps embed.FS
+
+// App is the name of an application provided by this package.
+type App string
+
+const (
+	// DefaultApp requires a single package, `vlucas/phpdotenv`, and its
+	// dependencies, and serves `htdocs/index.php`.
+	DefaultApp App = "default_app"
+
+	// AppWithNoDeps has a `composer.json` and `composer.lock` without any
+	// dependencies.
+	AppWithNoDeps App = "app_with_no_deps"
+)
+
+// WriteApp writes the files of the given application into the given
+// directory, which is created if it does not exist. Existing files are
+// overwritten, so that e.g. the `composer.json` and `composer.lock` of
+// AppWithNoDeps can replace the ones of DefaultApp between two builds.
+func WriteApp(app App, dir string) error {
+	root := path.Join("testdata", string(app))
+
+	return fs.WalkDir(apps, root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(strings.TrimPrefix(name, root), "/")))
+
+		if entry.IsDir() {
+			return os.MkdirAll(target, os.ModePerm)
+		}
+
+		content, err := apps.ReadFile(name)
+		if err != nil { // untested
+			return err
+		}
+
+		return os.WriteFile(target, content, 0644)
+	})
+}
+
+// NewApp writes the files of the given application into a new temporary
+// directory, and returns its path. The caller is responsible for removing it.
+func NewApp(app App) (string, error) {
+	dir, err := os.MkdirTemp("", string(app))
+	if err != nil { // untested
+		return "", err
+	}
+
+	err = WriteApp(app, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	return dir, nil
+}
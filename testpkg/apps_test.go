@@ -0,0 +1,59 @@
+package testpkg_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer/testpkg"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testApps(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		dir string
+	)
+
+	it.After(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	context("NewApp", func() {
+		it("writes the application into a temporary directory", func() {
+			var err error
+			dir, err = testpkg.NewApp(testpkg.DefaultApp)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(filepath.Join(dir, "composer.json")).To(BeARegularFile())
+			Expect(filepath.Join(dir, "composer.lock")).To(BeARegularFile())
+			Expect(filepath.Join(dir, "htdocs", "index.php")).To(BeARegularFile())
+			Expect(filepath.Join(dir, "htdocs", ".env")).To(BeARegularFile())
+		})
+
+		context("when the application does not exist", func() {
+			it("returns an error", func() {
+				_, err := testpkg.NewApp("unknown_app")
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	context("WriteApp", func() {
+		it("replaces the files of another application", func() {
+			var err error
+			dir, err = testpkg.NewApp(testpkg.DefaultApp)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(testpkg.WriteApp(testpkg.AppWithNoDeps, dir)).To(Succeed())
+
+			lock, err := testpkg.ReadLock(filepath.Join(dir, "composer.lock"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lock.PackageNames()).To(BeEmpty())
+			Expect(filepath.Join(dir, "htdocs", "index.php")).To(BeARegularFile())
+		})
+	})
+}
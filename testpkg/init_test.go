@@ -0,0 +1,16 @@
+package testpkg_test
+
+import (
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitTestpkg(t *testing.T) {
+	suite := spec.New("testpkg", spec.Report(report.Terminal{}))
+	suite("Apps", testApps)
+	suite("Lock", testLock)
+	suite("Matchers", testMatchers)
+	suite.Run(t)
+}
@@ -0,0 +1,155 @@
+package testpkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Lock is a `composer.lock` which is mutated by integration tests, e.g. to
+// verify that a changed `composer.lock` invalidates the cached
+// composer-packages layer.
+type Lock struct {
+	path    string
+	content map[string]interface{}
+}
+
+// ReadLock parses the `composer.lock` at the given path.
+func ReadLock(path string) (*Lock, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := Lock{path: path}
+	err = json.Unmarshal(content, &lock.content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return &lock, nil
+}
+
+// MutateLock reads the `composer.lock` at the given path, applies the given
+// mutation and writes it back.
+func MutateLock(path string, mutate func(lock *Lock) error) error {
+	lock, err := ReadLock(path)
+	if err != nil {
+		return err
+	}
+
+	err = mutate(lock)
+	if err != nil {
+		return err
+	}
+
+	return lock.Write()
+}
+
+// Write writes the lock back to the path it has been read from. The keys are
+// written in alphabetical order, so the written file differs from the one
+// generated by Composer even without mutations.
+func (l *Lock) Write() error {
+	buffer := bytes.NewBuffer(nil)
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "    ")
+
+	err := encoder.Encode(l.content)
+	if err != nil { // untested
+		return err
+	}
+
+	return os.WriteFile(l.path, buffer.Bytes(), 0644)
+}
+
+// PackageNames returns the names of the locked packages, including the dev
+// packages, in the order of the lock.
+func (l *Lock) PackageNames() []string {
+	var names []string
+	for _, key := range []string{"packages", "packages-dev"} {
+		for _, pkg := range l.packages(key) {
+			if name, ok := pkg["name"].(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names
+}
+
+// SetPackageVersion changes the locked version of the given package. Only the
+// lock is changed, so `composer install` fails to download a version which
+// does not exist.
+func (l *Lock) SetPackageVersion(name, version string) error {
+	pkg, err := l.findPackage(name)
+	if err != nil {
+		return err
+	}
+
+	pkg["version"] = version
+
+	return nil
+}
+
+// RemovePackage removes the given package from the lock.
+func (l *Lock) RemovePackage(name string) error {
+	for _, key := range []string{"packages", "packages-dev"} {
+		packages := l.packages(key)
+		for i, pkg := range packages {
+			if pkg["name"] != name {
+				continue
+			}
+
+			var kept []interface{}
+			for j, other := range packages {
+				if j != i {
+					kept = append(kept, other)
+				}
+			}
+			if kept == nil {
+				kept = []interface{}{}
+			}
+			l.content[key] = kept
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("package %s is not locked in %s", name, l.path)
+}
+
+// SetContentHash changes the content hash of the lock, e.g. to change the
+// checksum of `composer.lock` without changing the locked packages.
+func (l *Lock) SetContentHash(hash string) {
+	l.content["content-hash"] = hash
+}
+
+// findPackage returns the locked package with the given name.
+func (l *Lock) findPackage(name string) (map[string]interface{}, error) {
+	for _, key := range []string{"packages", "packages-dev"} {
+		for _, pkg := range l.packages(key) {
+			if pkg["name"] == name {
+				return pkg, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("package %s is not locked in %s", name, l.path)
+}
+
+// packages returns the packages listed under the given key, i.e. "packages"
+// or "packages-dev".
+func (l *Lock) packages(key string) []map[string]interface{} {
+	values, _ := l.content[key].([]interface{})
+
+	var packages []map[string]interface{}
+	for _, value := range values {
+		if pkg, ok := value.(map[string]interface{}); ok {
+			packages = append(packages, pkg)
+		}
+	}
+
+	return packages
+}
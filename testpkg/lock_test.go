@@ -0,0 +1,117 @@
+package testpkg_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer/testpkg"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLock(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		dir      string
+		lockPath string
+	)
+
+	it.Before(func() {
+		var err error
+		dir, err = testpkg.NewApp(testpkg.DefaultApp)
+		Expect(err).NotTo(HaveOccurred())
+
+		lockPath = filepath.Join(dir, "composer.lock")
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	it("lists the locked packages", func() {
+		lock, err := testpkg.ReadLock(lockPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lock.PackageNames()).To(ContainElements("vlucas/phpdotenv", "graham-campbell/result-type"))
+	})
+
+	it("changes the version of a package", func() {
+		Expect(testpkg.MutateLock(lockPath, func(lock *testpkg.Lock) error {
+			return lock.SetPackageVersion("vlucas/phpdotenv", "v5.4.0")
+		})).To(Succeed())
+
+		content, err := os.ReadFile(lockPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(ContainSubstring(`"version": "v5.4.0"`))
+		Expect(string(content)).To(ContainSubstring(`"url": "https://github.com/vlucas/phpdotenv.git"`))
+	})
+
+	it("removes a package", func() {
+		Expect(testpkg.MutateLock(lockPath, func(lock *testpkg.Lock) error {
+			return lock.RemovePackage("vlucas/phpdotenv")
+		})).To(Succeed())
+
+		lock, err := testpkg.ReadLock(lockPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lock.PackageNames()).NotTo(ContainElement("vlucas/phpdotenv"))
+		Expect(lock.PackageNames()).To(ContainElement("graham-campbell/result-type"))
+	})
+
+	it("changes the content hash", func() {
+		Expect(testpkg.MutateLock(lockPath, func(lock *testpkg.Lock) error {
+			lock.SetContentHash("some-content-hash")
+			return nil
+		})).To(Succeed())
+
+		content, err := os.ReadFile(lockPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(ContainSubstring(`"content-hash": "some-content-hash"`))
+	})
+
+	context("failure cases", func() {
+		context("when the package is not locked", func() {
+			it("returns an error", func() {
+				err := testpkg.MutateLock(lockPath, func(lock *testpkg.Lock) error {
+					return lock.SetPackageVersion("some/package", "1.0.0")
+				})
+				Expect(err).To(MatchError(ContainSubstring("package some/package is not locked in")))
+
+				err = testpkg.MutateLock(lockPath, func(lock *testpkg.Lock) error {
+					return lock.RemovePackage("some/package")
+				})
+				Expect(err).To(MatchError(ContainSubstring("package some/package is not locked in")))
+			})
+		})
+
+		context("when the mutation fails", func() {
+			it("does not write the lock", func() {
+				before, err := os.ReadFile(lockPath)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = testpkg.MutateLock(lockPath, func(lock *testpkg.Lock) error {
+					lock.SetContentHash("some-content-hash")
+					return errors.New("some-error")
+				})
+				Expect(err).To(MatchError("some-error"))
+
+				after, err := os.ReadFile(lockPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(after).To(Equal(before))
+			})
+		})
+
+		context("when the lock cannot be parsed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(lockPath, []byte("%%%"), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := testpkg.ReadLock(lockPath)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse %s", lockPath)))
+			})
+		})
+	})
+}
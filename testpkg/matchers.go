@@ -0,0 +1,45 @@
+package testpkg
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/onsi/gomega"
+	"github.com/onsi/gomega/types"
+	"github.com/paketo-buildpacks/composer"
+)
+
+// HaveCacheStatus matches build logs in which the composer-packages layer
+// has the given cache status, e.g. composer.CacheStatusStaleLock.
+func HaveCacheStatus(status composer.CacheStatus) types.GomegaMatcher {
+	return gomega.ContainSubstring("Composer packages cache: %s", status)
+}
+
+// HaveCacheHit matches build logs in which the cached composer-packages
+// layer has been reused.
+func HaveCacheHit() types.GomegaMatcher {
+	return HaveCacheStatus(composer.CacheStatusHit)
+}
+
+// HaveCacheMiss matches build logs in which the composer-packages layer has
+// been built from scratch, i.e. with one of composer.CacheMissStatuses.
+func HaveCacheMiss() types.GomegaMatcher {
+	var statuses []string
+	for _, status := range composer.CacheMissStatuses {
+		statuses = append(statuses, regexp.QuoteMeta(string(status)))
+	}
+
+	return gomega.MatchRegexp(`Composer packages cache: (%s)\b`, strings.Join(statuses, "|"))
+}
+
+// HaveReusedLayer matches build logs in which the composer-packages layer
+// of the buildpack with the given ID has been restored from the cache.
+func HaveReusedLayer(buildpackID string) types.GomegaMatcher {
+	return gomega.ContainSubstring("Reusing cached layer /layers/%s/%s", strings.ReplaceAll(buildpackID, "/", "_"), composer.ComposerPackagesLayerName)
+}
+
+// HaveRunComposerInstall matches build logs in which `composer install` has
+// been run with the given options, e.g. "--no-progress", "--no-dev".
+func HaveRunComposerInstall(options ...string) types.GomegaMatcher {
+	return gomega.ContainSubstring("Running '%s'", strings.Join(append([]string{"composer", "install"}, options...), " "))
+}
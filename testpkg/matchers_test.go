@@ -0,0 +1,104 @@
+package testpkg_test
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/paketo-buildpacks/composer/testpkg"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testMatchers(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		logs *bytes.Buffer
+	)
+
+	it.Before(func() {
+		logs = bytes.NewBufferString(`Paketo Buildpack for Composer Install some-version
+  Reusing cached layer /layers/ninech_buildpack-composer-install/composer-packages
+  Running 'composer install --no-progress --no-dev' from cached files
+  Composer packages cache: hit
+`)
+	})
+
+	it("matches the cache status", func() {
+		Expect(logs).To(testpkg.HaveCacheHit())
+		Expect(logs).NotTo(testpkg.HaveCacheMiss())
+		Expect(logs).To(testpkg.HaveCacheStatus(composer.CacheStatusHit))
+		Expect(logs).NotTo(testpkg.HaveCacheStatus(composer.CacheStatusStaleLock))
+	})
+
+	it("matches stale layers as cache misses", func() {
+		logs = bytes.NewBufferString("  Composer packages cache: stale-lock\n")
+
+		Expect(logs).To(testpkg.HaveCacheMiss())
+		Expect(logs).NotTo(testpkg.HaveCacheHit())
+	})
+
+	it("classifies every cache status as a cache miss or not", func() {
+		// the statuses which reuse the cached layer or the vendored packages
+		reused := []composer.CacheStatus{
+			composer.CacheStatusHit,
+			composer.CacheStatusRevalidatedStack,
+			composer.CacheStatusStaleDevMode,
+			composer.CacheStatusVendorOnly,
+			composer.CacheStatusVendorPreserved,
+		}
+
+		file, err := parser.ParseFile(token.NewFileSet(), "../build.go", nil, 0)
+		Expect(err).NotTo(HaveOccurred())
+
+		var statuses []composer.CacheStatus
+		ast.Inspect(file, func(node ast.Node) bool {
+			spec, ok := node.(*ast.ValueSpec)
+			if !ok || spec.Type == nil || fmt.Sprint(spec.Type) != "CacheStatus" {
+				return true
+			}
+
+			for _, value := range spec.Values {
+				status, err := strconv.Unquote(value.(*ast.BasicLit).Value)
+				Expect(err).NotTo(HaveOccurred())
+				statuses = append(statuses, composer.CacheStatus(status))
+			}
+			return true
+		})
+		Expect(statuses).NotTo(BeEmpty())
+
+		for _, status := range statuses {
+			logs = bytes.NewBufferString(fmt.Sprintf("  Composer packages cache: %s\n", status))
+
+			miss := false
+			for _, missStatus := range composer.CacheMissStatuses {
+				miss = miss || missStatus == status
+			}
+
+			if miss {
+				Expect(reused).NotTo(ContainElement(status))
+				Expect(logs).To(testpkg.HaveCacheMiss())
+			} else {
+				Expect(reused).To(ContainElement(status), "cache status %q is neither in composer.CacheMissStatuses nor reuses the cached layer", status)
+				Expect(logs).NotTo(testpkg.HaveCacheMiss())
+			}
+		}
+	})
+
+	it("matches the reused layer", func() {
+		Expect(logs).To(testpkg.HaveReusedLayer("ninech/buildpack-composer-install"))
+		Expect(logs).NotTo(testpkg.HaveReusedLayer("some/buildpack"))
+	})
+
+	it("matches the options of composer install", func() {
+		Expect(logs).To(testpkg.HaveRunComposerInstall("--no-progress", "--no-dev"))
+		Expect(logs).NotTo(testpkg.HaveRunComposerInstall("--no-progress", "--prefer-dist"))
+	})
+}
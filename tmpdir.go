@@ -0,0 +1,100 @@
+package composer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+// composerTmpDir is the temporary directory of all `composer` executions.
+type composerTmpDir struct {
+	fileSystem FileSystem
+	dir        string
+}
+
+// prepareComposerTmpDir will check for env var "BP_COMPOSER_HERMETIC_TMPDIR".
+// Unless set to false, all `composer` executions use a temporary directory in
+// an ignored layer rather than the /tmp of the builder, which is often much
+// smaller than the volume holding the layers. Composer extracts downloaded
+// archives into the temporary directory, so large installs can otherwise fail
+// with errors about disk space which do not point to /tmp.
+func prepareComposerTmpDir(logger emitter, context packit.BuildContext, fileSystem FileSystem) (composerTmpDir, error) {
+	enabled, err := lookupBoolEnv(BpComposerHermeticTmpDir, true)
+	if err != nil {
+		return composerTmpDir{}, err
+	}
+
+	if !enabled {
+		return composerTmpDir{}, nil
+	}
+
+	composerTmpLayer, err := context.Layers.Get(ComposerTmpLayerName)
+	if err != nil { // untested
+		return composerTmpDir{}, err
+	}
+
+	composerTmpLayer, err = composerTmpLayer.Reset()
+	if err != nil { // untested
+		return composerTmpDir{}, err
+	}
+
+	dir := filepath.Join(composerTmpLayer.Path, "tmp")
+	err = fileSystem.MkdirAll(dir, 0755)
+	if err != nil { // untested
+		return composerTmpDir{}, err
+	}
+
+	logger.Debug.Process("Using TMPDIR=%s for all composer executions", dir)
+	logger.Debug.Break()
+
+	return composerTmpDir{fileSystem: fileSystem, dir: dir}, nil
+}
+
+// cleanup removes the temporary directory, so that its contents do not take
+// up space during the builds of the following buildpacks.
+func (t composerTmpDir) cleanup() error {
+	if t.dir == "" {
+		return nil
+	}
+
+	return t.fileSystem.RemoveAll(t.dir)
+}
+
+// wrap returns an Executable which sets TMPDIR to the temporary directory,
+// unless the execution sets its own, such as the sandbox does.
+func (t composerTmpDir) wrap(executable Executable) Executable {
+	if t.dir == "" {
+		return executable
+	}
+
+	return tmpDirExecutable{
+		executable: executable,
+		dir:        t.dir,
+	}
+}
+
+type tmpDirExecutable struct {
+	executable Executable
+	dir        string
+}
+
+func (e tmpDirExecutable) Execute(execution pexec.Execution) error {
+	// the environment of the execution usually starts with os.Environ(),
+	// so only a TMPDIR different from the one of the buildpack is its own
+	tmpDir, found := "", false
+	for _, variable := range execution.Env {
+		if strings.HasPrefix(variable, "TMPDIR=") {
+			tmpDir, found = strings.TrimPrefix(variable, "TMPDIR="), true
+		}
+	}
+
+	if !found || tmpDir == os.Getenv("TMPDIR") {
+		execution.Env = append(execution.Env, fmt.Sprintf("TMPDIR=%s", e.dir))
+	}
+
+	return e.executable.Execute(execution)
+}
@@ -0,0 +1,430 @@
+package composer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+// Span is a finished unit of work of the build.
+type Span struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Name         string
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]string
+
+	// Err is the error the unit of work failed with, if any
+	Err error
+}
+
+// TraceExport contains all spans of a build and the OTLP endpoint they are
+// exported to.
+type TraceExport struct {
+	Endpoint    string
+	Headers     map[string]string
+	ServiceName string
+	Spans       []Span
+}
+
+// SpanExporter exports the spans of a build.
+//
+//go:generate faux --interface SpanExporter --output fakes/span_exporter.go
+type SpanExporter interface {
+	Export(export TraceExport) error
+}
+
+// Tracer records the spans of a build. The spans are nested in the order
+// they are started, as the build runs sequentially. A Tracer which is not
+// enabled does not record anything.
+type Tracer struct {
+	enabled  bool
+	config   TraceExport
+	clock    chronos.Clock
+	traceID  string
+	parentID string
+	active   []*Span
+	spans    []Span
+}
+
+// newTracer configures tracing from the OpenTelemetry environment variables.
+// Tracing is enabled as soon as an OTLP endpoint is set, unless
+// "OTEL_SDK_DISABLED" is set to true. Only the http/json protocol is
+// supported, tracing is disabled with a warning for any other.
+func newTracer(logger emitter, clock chronos.Clock, defaultServiceName string) (*Tracer, error) {
+	tracer := &Tracer{clock: clock}
+
+	disabled, err := lookupBoolEnv(OtelSDKDisabled, false)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := os.Getenv(OtelExporterOtlpTracesEndpoint)
+	if endpoint == "" && os.Getenv(OtelExporterOtlpEndpoint) != "" {
+		endpoint = strings.TrimSuffix(os.Getenv(OtelExporterOtlpEndpoint), "/") + "/v1/traces"
+	}
+
+	if disabled || endpoint == "" {
+		return tracer, nil
+	}
+
+	// the protocol is usually set for all OpenTelemetry SDKs of the
+	// environment, so another one does not fail the build. The protocol of
+	// the traces takes precedence.
+	for _, name := range []string{OtelExporterOtlpTracesProtocol, OtelExporterOtlpProtocol} {
+		protocol, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		if protocol != "http/json" {
+			logger.Process("WARNING: Tracing is disabled, %s %q is not supported, only http/json is", name, protocol)
+			logger.Break()
+			return tracer, nil
+		}
+
+		break
+	}
+
+	headers := map[string]string{}
+	for _, name := range []string{OtelExporterOtlpHeaders, OtelExporterOtlpTracesHeaders} {
+		err = parseOtlpHeaders(os.Getenv(name), headers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+	}
+
+	serviceName := os.Getenv(OtelServiceName)
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+
+	tracer.traceID = newTraceID()
+	if traceParent, ok := os.LookupEnv(TraceParent); ok {
+		tracer.traceID, tracer.parentID, err = parseTraceParent(traceParent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", TraceParent, err)
+		}
+	}
+
+	tracer.enabled = true
+	tracer.config = TraceExport{
+		Endpoint:    endpoint,
+		Headers:     headers,
+		ServiceName: serviceName,
+	}
+
+	logger.Process("Tracing the build")
+	logger.Subprocess("Exporting spans to %s", endpoint)
+	if tracer.parentID != "" {
+		logger.Subprocess("Parent span: %s", tracer.parentID)
+	}
+	logger.Break()
+
+	return tracer, nil
+}
+
+// parseOtlpHeaders parses a list of comma-separated "key=value" pairs, with
+// URL-encoded values, into the given headers.
+func parseOtlpHeaders(value string, headers map[string]string) error {
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		key, encodedValue, found := strings.Cut(pair, "=")
+		if !found {
+			return fmt.Errorf("header %q must be in the format key=value", strings.TrimSpace(pair))
+		}
+
+		decodedValue, err := url.QueryUnescape(strings.TrimSpace(encodedValue))
+		if err != nil {
+			return err
+		}
+
+		headers[strings.TrimSpace(key)] = decodedValue
+	}
+
+	return nil
+}
+
+// parseTraceParent returns the trace ID and the parent span ID of a W3C
+// "traceparent", such as "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func parseTraceParent(value string) (traceID string, parentID string, err error) {
+	fields := strings.Split(strings.TrimSpace(value), "-")
+	if len(fields) < 4 || len(fields[1]) != 32 || len(fields[2]) != 16 {
+		return "", "", fmt.Errorf("invalid traceparent %q", value)
+	}
+
+	for _, field := range fields[1:3] {
+		if _, err := hex.DecodeString(field); err != nil || strings.Trim(field, "0") == "" {
+			return "", "", fmt.Errorf("invalid traceparent %q", value)
+		}
+	}
+
+	return strings.ToLower(fields[1]), strings.ToLower(fields[2]), nil
+}
+
+func newTraceID() string {
+	return randomHex(16)
+}
+
+func newSpanID() string {
+	return randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Start starts a span nested into the currently active span. The returned
+// function ends the span with the given error.
+func (t *Tracer) Start(name string, attributes map[string]string) func(err error) {
+	if !t.enabled {
+		return func(error) {}
+	}
+
+	parentID := t.parentID
+	if len(t.active) > 0 {
+		parentID = t.active[len(t.active)-1].SpanID
+	}
+
+	span := &Span{
+		TraceID:      t.traceID,
+		SpanID:       newSpanID(),
+		ParentSpanID: parentID,
+		Name:         name,
+		Start:        t.clock.Now(),
+		Attributes:   attributes,
+	}
+	t.active = append(t.active, span)
+
+	return func(err error) {
+		span.End = t.clock.Now()
+		span.Err = err
+
+		for i := len(t.active) - 1; i >= 0; i-- {
+			if t.active[i] == span {
+				t.active = append(t.active[:i], t.active[i+1:]...)
+				break
+			}
+		}
+
+		t.spans = append(t.spans, *span)
+	}
+}
+
+// Trace runs f within a span.
+func (t *Tracer) Trace(name string, attributes map[string]string, f func() error) error {
+	end := t.Start(name, attributes)
+	err := f()
+	end(err)
+	return err
+}
+
+// Wrap returns an Executable which runs each execution within a span.
+func (t *Tracer) Wrap(executable Executable) Executable {
+	if !t.enabled {
+		return executable
+	}
+
+	return tracedExecutable{
+		executable: executable,
+		tracer:     t,
+	}
+}
+
+// Export exports all finished spans. A failed export is logged, but does not
+// fail the build.
+func (t *Tracer) Export(logger Logger, exporter SpanExporter) {
+	t.export(newEmitter(logger), exporter)
+}
+
+func (t *Tracer) export(logger emitter, exporter SpanExporter) {
+	if !t.enabled || len(t.spans) == 0 {
+		return
+	}
+
+	export := t.config
+	export.Spans = t.spans
+
+	err := exporter.Export(export)
+	if err != nil {
+		logger.Process("Failed to export %d span(s): %s", len(t.spans), err)
+		logger.Break()
+		return
+	}
+
+	logger.Debug.Process("Exported %d span(s) of trace %s", len(t.spans), t.traceID)
+	logger.Debug.Break()
+}
+
+// copyAttributes are the attributes of a span copying files.
+func copyAttributes(source, destination string) map[string]string {
+	return map[string]string{
+		"copy.source":      source,
+		"copy.destination": destination,
+	}
+}
+
+type tracedExecutable struct {
+	executable Executable
+	tracer     *Tracer
+}
+
+func (e tracedExecutable) Execute(execution pexec.Execution) error {
+	name := "composer"
+	if len(execution.Args) > 0 {
+		name = fmt.Sprintf("composer %s", execution.Args[0])
+	}
+
+	return e.tracer.Trace(name, map[string]string{
+		"process.command_line":      strings.Join(append([]string{"composer"}, redactComposerArgs(execution.Args)...), " "),
+		"process.working_directory": execution.Dir,
+	}, func() error {
+		return e.executable.Execute(execution)
+	})
+}
+
+// OTLPSpanExporter exports spans with the OTLP/HTTP protocol in its JSON
+// encoding.
+// https://opentelemetry.io/docs/specs/otlp/#otlphttp
+type OTLPSpanExporter struct {
+	client *http.Client
+}
+
+func NewOTLPSpanExporter() OTLPSpanExporter {
+	return OTLPSpanExporter{
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type otlpKeyValue struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTracesRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func otlpAttributes(attributes map[string]string) []otlpKeyValue {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var keyValues []otlpKeyValue
+	for _, key := range keys {
+		keyValue := otlpKeyValue{Key: key}
+		keyValue.Value.StringValue = attributes[key]
+		keyValues = append(keyValues, keyValue)
+	}
+
+	return keyValues
+}
+
+func (e OTLPSpanExporter) Export(export TraceExport) error {
+	scopeSpans := otlpScopeSpans{}
+	scopeSpans.Scope.Name = "github.com/paketo-buildpacks/composer"
+	for _, span := range export.Spans {
+		status := otlpStatus{Code: 1}
+		if span.Err != nil {
+			status = otlpStatus{Code: 2, Message: span.Err.Error()}
+		}
+
+		scopeSpans.Spans = append(scopeSpans.Spans, otlpSpan{
+			TraceID:           span.TraceID,
+			SpanID:            span.SpanID,
+			ParentSpanID:      span.ParentSpanID,
+			Name:              span.Name,
+			Kind:              1, // SPAN_KIND_INTERNAL
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+			Status:            status,
+		})
+	}
+
+	resourceSpans := otlpResourceSpans{ScopeSpans: []otlpScopeSpans{scopeSpans}}
+	resourceSpans.Resource.Attributes = otlpAttributes(map[string]string{"service.name": export.ServiceName})
+
+	body, err := json.Marshal(otlpTracesRequest{ResourceSpans: []otlpResourceSpans{resourceSpans}})
+	if err != nil { // untested
+		return err
+	}
+
+	httpRequest, err := http.NewRequest(http.MethodPost, export.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	httpRequest.Header.Set("Content-Type", "application/json")
+	for key, value := range export.Headers {
+		httpRequest.Header.Set(key, value)
+	}
+
+	response, err := e.client.Do(httpRequest)
+	if err != nil {
+		return fmt.Errorf("failed to export spans to %s: %w", export.Endpoint, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("failed to export spans to %s: %s %s", export.Endpoint, response.Status, strings.TrimSpace(string(message)))
+	}
+
+	return nil
+}
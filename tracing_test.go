@@ -0,0 +1,141 @@
+package composer_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testOTLPSpanExporter(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		server   *httptest.Server
+		exporter composer.OTLPSpanExporter
+		export   composer.TraceExport
+
+		contentType   string
+		authorization string
+		request       map[string]interface{}
+	)
+
+	it.Before(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			contentType = req.Header.Get("Content-Type")
+			authorization = req.Header.Get("Authorization")
+
+			body, err := io.ReadAll(req.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.Unmarshal(body, &request)).To(Succeed())
+
+			switch req.URL.Path {
+			case "/v1/traces":
+				w.WriteHeader(http.StatusOK)
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, "invalid request")
+			}
+		}))
+
+		start := time.Unix(1700000000, 0)
+		export = composer.TraceExport{
+			Endpoint:    server.URL + "/v1/traces",
+			Headers:     map[string]string{"Authorization": "Bearer some-token"},
+			ServiceName: "some-service",
+			Spans: []composer.Span{
+				{
+					TraceID:      "4bf92f3577b34da6a3ce929d0e0e4736",
+					SpanID:       "00f067aa0ba902b7",
+					ParentSpanID: "a3ce929d0e0e4736",
+					Name:         "composer install",
+					Start:        start,
+					End:          start.Add(time.Second),
+					Attributes:   map[string]string{"process.working_directory": "/workspace"},
+					Err:          errors.New("some-error"),
+				},
+			},
+		}
+
+		exporter = composer.NewOTLPSpanExporter()
+	})
+
+	it.After(func() {
+		server.Close()
+	})
+
+	it("exports the spans as OTLP JSON", func() {
+		Expect(exporter.Export(export)).To(Succeed())
+
+		Expect(contentType).To(Equal("application/json"))
+		Expect(authorization).To(Equal("Bearer some-token"))
+
+		content, err := json.Marshal(request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(MatchJSON(`{
+	"resourceSpans": [
+		{
+			"resource": {
+				"attributes": [
+					{"key": "service.name", "value": {"stringValue": "some-service"}}
+				]
+			},
+			"scopeSpans": [
+				{
+					"scope": {"name": "github.com/paketo-buildpacks/composer"},
+					"spans": [
+						{
+							"traceId": "4bf92f3577b34da6a3ce929d0e0e4736",
+							"spanId": "00f067aa0ba902b7",
+							"parentSpanId": "a3ce929d0e0e4736",
+							"name": "composer install",
+							"kind": 1,
+							"startTimeUnixNano": "1700000000000000000",
+							"endTimeUnixNano": "1700000001000000000",
+							"attributes": [
+								{"key": "process.working_directory", "value": {"stringValue": "/workspace"}}
+							],
+							"status": {"code": 2, "message": "some-error"}
+						}
+					]
+				}
+			]
+		}
+	]
+}`))
+	})
+
+	context("failure cases", func() {
+		context("when the endpoint rejects the spans", func() {
+			it.Before(func() {
+				export.Endpoint = server.URL + "/invalid"
+			})
+
+			it("returns an error", func() {
+				err := exporter.Export(export)
+				Expect(err).To(MatchError(fmt.Sprintf("failed to export spans to %s/invalid: 400 Bad Request invalid request", server.URL)))
+			})
+		})
+
+		context("when the endpoint cannot be reached", func() {
+			it.Before(func() {
+				export.Endpoint = server.URL + "/v1/traces"
+				server.Close()
+			})
+
+			it("returns an error", func() {
+				err := exporter.Export(export)
+				Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("failed to export spans to %s/v1/traces", server.URL))))
+			})
+		})
+	})
+}
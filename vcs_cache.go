@@ -0,0 +1,227 @@
+package composer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+const (
+	// cacheVCSAuto is the value of "BP_COMPOSER_CACHE_VCS" which only caches
+	// the VCS repositories if the project has any.
+	cacheVCSAuto = "auto"
+
+	// vcsCacheDirMetadataKey is the key of the composer-home layer metadata,
+	// which records that the previous build wrote "cache-vcs-dir" into
+	// `config.json`.
+	vcsCacheDirMetadataKey = "vcs-cache-dir"
+)
+
+// vcsRepositoryTypes are the types of repositories, which Composer clones
+// into its VCS cache, "cache-vcs-dir".
+// https://getcomposer.org/doc/05-repositories.md#vcs
+var vcsRepositoryTypes = map[string]bool{
+	"vcs":           true,
+	"git":           true,
+	"github":        true,
+	"gitlab":        true,
+	"bitbucket":     true,
+	"git-bitbucket": true,
+	"hg":            true,
+	"fossil":        true,
+	"svn":           true,
+	"perforce":      true,
+}
+
+// prepareVCSCacheLayerIfRequired will check for env var
+// "BP_COMPOSER_CACHE_VCS", which defaults to "auto". If set to true, or if
+// set to "auto" and the project has VCS repositories, see
+// FindVCSRepositories, the composer-vcs-cache layer is provided as the VCS
+// cache of Composer. It is cached independently of `composer.lock`, so that
+// the repositories are only updated instead of cloned again. Returns whether
+// the layer is used.
+//
+// The VCS cache is not used if BP_COMPOSER_CONFIG sets "cache-vcs-dir".
+func prepareVCSCacheLayerIfRequired(logger emitter, context packit.BuildContext, composerJsonPath string, repositories composerRepositories, settings []composerConfigSetting) (packit.Layer, bool, error) {
+	value := os.Getenv(BpComposerCacheVCS)
+	if value == "" {
+		value = cacheVCSAuto
+	}
+
+	if !strings.EqualFold(value, cacheVCSAuto) {
+		enabled, err := lookupBoolEnv(BpComposerCacheVCS, false)
+		if err != nil {
+			return packit.Layer{}, false, err
+		}
+
+		if !enabled {
+			return packit.Layer{}, false, nil
+		}
+	} else {
+		found, err := FindVCSRepositories(composerJsonPath)
+		if err != nil {
+			return packit.Layer{}, false, err
+		}
+
+		for _, repository := range repositories.file.Repositories {
+			if vcsRepositoryTypes[repository.Type] {
+				found = append(found, repository.URL)
+			}
+		}
+
+		if len(found) == 0 {
+			return packit.Layer{}, false, nil
+		}
+
+		logger.Debug.Process("Found %d VCS repositories (%s=%s)", len(found), BpComposerCacheVCS, cacheVCSAuto)
+		for _, url := range found {
+			logger.Debug.Subprocess("- %s", url)
+		}
+	}
+
+	for _, setting := range settings {
+		if setting.key == "cache-vcs-dir" {
+			logger.Process("Not caching the VCS repositories, as %s sets cache-vcs-dir", BpComposerConfig)
+			logger.Break()
+			return packit.Layer{}, false, nil
+		}
+	}
+
+	vcsCacheLayer, err := context.Layers.Get(ComposerVCSCacheLayerName)
+	if err != nil { // untested
+		return packit.Layer{}, false, err
+	}
+
+	err = os.MkdirAll(vcsCacheLayer.Path, 0755)
+	if err != nil { // untested
+		return packit.Layer{}, false, err
+	}
+
+	vcsCacheLayer.Launch, vcsCacheLayer.Build, vcsCacheLayer.Cache = false, false, true
+
+	entries, err := os.ReadDir(vcsCacheLayer.Path)
+	if err != nil { // untested
+		return packit.Layer{}, false, err
+	}
+
+	logger.Process("Caching the VCS repositories in %s", vcsCacheLayer.Path)
+	logger.Subprocess("Restored %d cached repositories", len(entries))
+	logger.Break()
+
+	return vcsCacheLayer, true, nil
+}
+
+// FindVCSRepositories returns the URLs of the VCS repositories declared in
+// the given `composer.json`, see vcsRepositoryTypes.
+func FindVCSRepositories(composerJsonPath string) ([]string, error) {
+	var composerJson struct {
+		Repositories json.RawMessage `json:"repositories"`
+	}
+
+	content, err := readComposerFile(composerJsonPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	err = json.Unmarshal(content, &composerJson)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", composerJsonPath, err)
+	}
+
+	type repository struct {
+		Type string `json:"type"`
+		URL  string `json:"url"`
+	}
+
+	// repositories are either a list, or an object keyed by name, and may
+	// contain `false` to disable packagist.org
+	var entries []json.RawMessage
+	if json.Unmarshal(composerJson.Repositories, &entries) != nil {
+		var named map[string]json.RawMessage
+		_ = json.Unmarshal(composerJson.Repositories, &named)
+
+		var names []string
+		for name := range named {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			entries = append(entries, named[name])
+		}
+	}
+
+	var urls []string
+	for _, entry := range entries {
+		var r repository
+		if json.Unmarshal(entry, &r) == nil && vcsRepositoryTypes[r.Type] {
+			urls = append(urls, r.URL)
+		}
+	}
+
+	return urls, nil
+}
+
+// applyVCSCacheDir writes the given directory as "cache-vcs-dir" into
+// `config.json` of COMPOSER_HOME. The composer-home layer may be cached, so
+// the directory written by a previous build is removed if there is none.
+func applyVCSCacheDir(composerHomeLayer *packit.Layer, vcsCacheDir string) error {
+	_, previouslyApplied := composerHomeLayer.Metadata[vcsCacheDirMetadataKey]
+	if vcsCacheDir == "" && !previouslyApplied {
+		return nil
+	}
+
+	configPath := filepath.Join(composerHomeLayer.Path, "config.json")
+
+	document := map[string]interface{}{}
+	content, err := os.ReadFile(configPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) { // untested
+		return err
+	}
+
+	if len(content) > 0 {
+		err = json.Unmarshal(content, &document)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", configPath, err)
+		}
+	}
+
+	config, _ := document["config"].(map[string]interface{})
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+
+	if composerHomeLayer.Metadata == nil {
+		composerHomeLayer.Metadata = map[string]interface{}{}
+	}
+
+	if vcsCacheDir == "" {
+		delete(config, "cache-vcs-dir")
+		delete(composerHomeLayer.Metadata, vcsCacheDirMetadataKey)
+	} else {
+		config["cache-vcs-dir"] = vcsCacheDir
+		composerHomeLayer.Metadata[vcsCacheDirMetadataKey] = vcsCacheDir
+	}
+
+	if len(config) == 0 {
+		delete(document, "config")
+	} else {
+		document["config"] = config
+	}
+
+	content, err = json.MarshalIndent(document, "", "    ")
+	if err != nil { // untested
+		return err
+	}
+
+	return os.WriteFile(configPath, append(content, '\n'), 0644)
+}
@@ -0,0 +1,178 @@
+package composer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+// vendorBinInstallArgs are the arguments of `composer` installing the tools
+// of all namespaces of `bamarni/composer-bin-plugin`.
+// https://github.com/bamarni/composer-bin-plugin#usage
+var vendorBinInstallArgs = []string{"bin", "all", "install", "--no-progress"}
+
+// installVendorBinIfRequired will run `composer bin all install` if
+// "BP_COMPOSER_VENDOR_BIN_INSTALL" is set to true and the application has
+// `vendor-bin` namespaces. The vendor directories of the namespaces are cached
+// in the composer-vendor-bin layer, which is reused as long as the
+// `composer.json` and `composer.lock` files of the namespaces, the stack and
+// the distribution of the build target are unchanged.
+//
+// Returns false if no layer has been used.
+func installVendorBinIfRequired(
+	logger emitter,
+	context packit.BuildContext,
+	composerInstallExec Executable,
+	composerJsonPath string,
+	composerHome string,
+	composerPhpIniPath string,
+	path string,
+	calculator Calculator) (packit.Layer, bool, error) {
+
+	required, err := lookupBoolEnv(BpComposerVendorBinInstall, false)
+	if err != nil {
+		return packit.Layer{}, false, err
+	}
+
+	if !required {
+		return packit.Layer{}, false, nil
+	}
+
+	namespaces, err := findBamarniBinNamespaces(context.WorkingDir, composerJsonPath)
+	if err != nil {
+		return packit.Layer{}, false, err
+	}
+
+	if len(namespaces) == 0 {
+		logger.Debug.Process("Skipping 'composer %s', no namespaces found", strings.Join(vendorBinInstallArgs, " "))
+		logger.Debug.Break()
+		return packit.Layer{}, false, nil
+	}
+
+	locked, err := readLockedPackageNames(composerJsonPath)
+	if err != nil {
+		return packit.Layer{}, false, err
+	}
+
+	if !locked[BamarniBinPluginName] {
+		return packit.Layer{}, false, fmt.Errorf("%s requires %s in composer.lock, found namespaces %s",
+			BpComposerVendorBinInstall, BamarniBinPluginName, strings.Join(namespaces, ", "))
+	}
+
+	inputs, err := findBamarniBinComposerFiles(context.WorkingDir, composerJsonPath)
+	if err != nil {
+		return packit.Layer{}, false, err
+	}
+
+	checksum, err := calculator.Sum(inputs...)
+	if err != nil { // untested
+		return packit.Layer{}, false, err
+	}
+
+	logger.Debug.Process("Calculated checksum of %s for the vendor-bin namespaces", checksum)
+	for _, input := range inputs {
+		logger.Debug.Subprocess("- including %s", input)
+	}
+
+	vendorBinLayer, err := context.Layers.Get(ComposerVendorBinLayerName)
+	if err != nil { // untested
+		return packit.Layer{}, false, err
+	}
+
+	cachedSHA, _ := vendorBinLayer.Metadata["vendor-bin-sha"].(string)
+	stack, _ := vendorBinLayer.Metadata["stack"].(string)
+	distro, _ := vendorBinLayer.Metadata["distro"].(string)
+
+	target := LookupBuildTarget()
+
+	if cachedSHA == checksum && stack == context.Stack && distro == target.Distro() {
+		logger.Process("Reusing cached layer %s", vendorBinLayer.Path)
+
+		for _, namespace := range namespaces {
+			layerVendorDir := filepath.Join(vendorBinLayer.Path, namespace, "vendor")
+			if exists, err := fs.Exists(layerVendorDir); err != nil {
+				return packit.Layer{}, false, err
+			} else if !exists {
+				continue
+			}
+
+			workspaceVendorDir := filepath.Join(context.WorkingDir, namespace, "vendor")
+			err = os.RemoveAll(workspaceVendorDir)
+			if err != nil { // untested
+				return packit.Layer{}, false, err
+			}
+
+			logger.Subprocess("Copying from %s => to %s", layerVendorDir, workspaceVendorDir)
+			err = copyTree(logger, layerVendorDir, workspaceVendorDir)
+			if err != nil { // untested
+				return packit.Layer{}, false, err
+			}
+		}
+		logger.Break()
+
+		vendorBinLayer.Cache = true
+
+		return vendorBinLayer, true, nil
+	}
+
+	vendorBinLayer, err = vendorBinLayer.Reset()
+	if err != nil { // untested
+		return packit.Layer{}, false, err
+	}
+
+	logger.Process("Running 'composer %s'", strings.Join(vendorBinInstallArgs, " "))
+
+	err = composerInstallExec.Execute(pexec.Execution{
+		Args: vendorBinInstallArgs,
+		Dir:  context.WorkingDir,
+		Env: append(os.Environ(),
+			"COMPOSER_NO_INTERACTION=1", // https://getcomposer.org/doc/03-cli.md#composer-no-interaction
+			fmt.Sprintf("COMPOSER_HOME=%s", composerHome),
+			fmt.Sprintf("PHPRC=%s", composerPhpIniPath),
+			fmt.Sprintf("PATH=%s", path),
+		),
+		Stdout: logger.ActionWriter,
+		Stderr: logger.ActionWriter,
+	})
+	if err != nil {
+		return packit.Layer{}, false, err
+	}
+
+	for _, namespace := range namespaces {
+		workspaceVendorDir := filepath.Join(context.WorkingDir, namespace, "vendor")
+		if exists, err := fs.Exists(workspaceVendorDir); err != nil {
+			return packit.Layer{}, false, err
+		} else if !exists {
+			continue
+		}
+
+		layerVendorDir := filepath.Join(vendorBinLayer.Path, namespace, "vendor")
+		err = os.MkdirAll(filepath.Dir(layerVendorDir), 0755)
+		if err != nil { // untested
+			return packit.Layer{}, false, err
+		}
+
+		logger.Subprocess("Copying from %s => to %s", workspaceVendorDir, layerVendorDir)
+		err = copyTree(logger, workspaceVendorDir, layerVendorDir)
+		if err != nil { // untested
+			return packit.Layer{}, false, err
+		}
+	}
+	logger.Break()
+
+	vendorBinLayer.Metadata = map[string]interface{}{
+		"vendor-bin-sha": checksum,
+		"stack":          context.Stack,
+	}
+	if target.Distro() != "" {
+		vendorBinLayer.Metadata["distro"] = target.Distro()
+	}
+	vendorBinLayer.Cache = true
+
+	return vendorBinLayer, true, nil
+}
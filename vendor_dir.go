@@ -0,0 +1,66 @@
+package composer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultVendorDir is Composer's default vendor directory
+const defaultVendorDir = "vendor"
+
+// FindVendorDir determines the vendor directory in the workspace, into which
+// `composer install` installs the dependencies.
+//
+// The vendor directory is taken from the first of:
+//   - COMPOSER_VENDOR_DIR
+//   - the `config.vendor-dir` setting in `composer.json`
+//     https://getcomposer.org/doc/06-config.md#vendor-dir
+//   - `vendor`
+//
+// Like Composer, the vendor directory is resolved relative to the working
+// directory. The vendor directory from `composer.json` must be underneath the
+// working directory.
+func FindVendorDir(workingDir, composerJsonPath string) (string, error) {
+	if value, found := os.LookupEnv(ComposerVendorDir); found {
+		return filepath.Join(workingDir, value), nil
+	}
+
+	var composerJson struct {
+		Config struct {
+			VendorDir string `json:"vendor-dir"`
+		} `json:"config"`
+	}
+
+	content, err := readComposerFile(composerJsonPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return filepath.Join(workingDir, defaultVendorDir), nil
+		}
+		return "", err
+	}
+
+	err = json.Unmarshal(content, &composerJson)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", composerJsonPath, err)
+	}
+
+	vendorDir := normalizeComposerPath(composerJson.Config.VendorDir)
+	if vendorDir == "" {
+		return filepath.Join(workingDir, defaultVendorDir), nil
+	}
+
+	relativePath, err := filepath.Rel(workingDir, filepath.Join(workingDir, vendorDir))
+	if err != nil { // untested
+		return "", err
+	}
+
+	if filepath.IsAbs(vendorDir) || relativePath == "." || strings.HasPrefix(relativePath, "..") {
+		return "", fmt.Errorf("config.vendor-dir in %s must be a relative path underneath the project root, found %q", filepath.Base(composerJsonPath), vendorDir)
+	}
+
+	return filepath.Join(workingDir, relativePath), nil
+}
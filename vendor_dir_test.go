@@ -0,0 +1,116 @@
+package composer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testVendorDir(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir       string
+		composerJsonPath string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		composerJsonPath = filepath.Join(workingDir, "composer.json")
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+		Expect(os.Unsetenv("COMPOSER_VENDOR_DIR")).To(Succeed())
+	})
+
+	context("when composer.json does not set config.vendor-dir", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(composerJsonPath, []byte(`{"config": {"sort-packages": true}}`), os.ModePerm)).To(Succeed())
+		})
+
+		it("returns the default vendor dir", func() {
+			vendorDir, err := composer.FindVendorDir(workingDir, composerJsonPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(vendorDir).To(Equal(filepath.Join(workingDir, "vendor")))
+		})
+	})
+
+	context("when composer.json does not exist", func() {
+		it("returns the default vendor dir", func() {
+			vendorDir, err := composer.FindVendorDir(workingDir, composerJsonPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(vendorDir).To(Equal(filepath.Join(workingDir, "vendor")))
+		})
+	})
+
+	context("when composer.json is written on Windows", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(composerJsonPath, []byte("\xef\xbb\xbf{\r\n"+
+				`	"config": {"vendor-dir": "lib\\vendor"}`+"\r\n"+
+				"}\r\n"), os.ModePerm)).To(Succeed())
+		})
+
+		it("returns the vendor dir with slashes", func() {
+			vendorDir, err := composer.FindVendorDir(workingDir, composerJsonPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(vendorDir).To(Equal(filepath.Join(workingDir, "lib", "vendor")))
+		})
+	})
+
+	context("when composer.json sets config.vendor-dir", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(composerJsonPath, []byte(`{"config": {"vendor-dir": "./lib/vendor/"}}`), os.ModePerm)).To(Succeed())
+		})
+
+		it("returns the vendor dir from composer.json", func() {
+			vendorDir, err := composer.FindVendorDir(workingDir, composerJsonPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(vendorDir).To(Equal(filepath.Join(workingDir, "lib", "vendor")))
+		})
+
+		context("when COMPOSER_VENDOR_DIR is set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("COMPOSER_VENDOR_DIR", "from-env")).To(Succeed())
+			})
+
+			it("gives precedence to COMPOSER_VENDOR_DIR", func() {
+				vendorDir, err := composer.FindVendorDir(workingDir, composerJsonPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(vendorDir).To(Equal(filepath.Join(workingDir, "from-env")))
+			})
+		})
+	})
+
+	context("failure cases", func() {
+		context("when config.vendor-dir is not underneath the project root", func() {
+			it("returns an error", func() {
+				for _, invalidPath := range []string{"/usr/vendor", "../vendor", "lib/../../vendor", "."} {
+					Expect(os.WriteFile(composerJsonPath, []byte(`{"config": {"vendor-dir": "`+invalidPath+`"}}`), os.ModePerm)).To(Succeed())
+
+					_, err := composer.FindVendorDir(workingDir, composerJsonPath)
+					Expect(err).To(MatchError(ContainSubstring("config.vendor-dir in composer.json must be a relative path underneath the project root")))
+				}
+			})
+		})
+
+		context("when composer.json is not valid JSON", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(composerJsonPath, []byte(`{`), os.ModePerm)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := composer.FindVendorDir(workingDir, composerJsonPath)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse")))
+			})
+		})
+	})
+}
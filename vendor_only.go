@@ -0,0 +1,267 @@
+package composer
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/draft"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+// VendorMismatch is a package whose vendored version does not match the
+// version in `composer.lock`. Either version is empty if the package is not
+// locked or not vendored.
+type VendorMismatch struct {
+	Name      string
+	Locked    string
+	Installed string
+}
+
+func (m VendorMismatch) String() string {
+	switch {
+	case m.Installed == "":
+		return fmt.Sprintf("%s (locked %s, not vendored)", m.Name, m.Locked)
+	case m.Locked == "":
+		return fmt.Sprintf("%s (vendored %s, not locked)", m.Name, m.Installed)
+	default:
+		return fmt.Sprintf("%s (locked %s, vendored %s)", m.Name, m.Locked, m.Installed)
+	}
+}
+
+// VerifyVendorAgainstLock compares the packages listed in
+// `{vendorDir}/composer/installed.json` to the packages in `composer.lock`.
+// All packages must be vendored with their locked version. The dev packages
+// may be missing, as the vendor directory may have been installed with
+// `--no-dev`, but must match their locked version otherwise.
+//
+// Returns the mismatching packages sorted by name.
+func VerifyVendorAgainstLock(composerLockPath, vendorDir string) ([]VendorMismatch, error) {
+	content, err := readComposerFile(composerLockPath)
+	if err != nil {
+		return nil, err
+	}
+
+	type lockedPackage struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+
+	var composerLock struct {
+		Packages    []lockedPackage `json:"packages"`
+		PackagesDev []lockedPackage `json:"packages-dev"`
+	}
+
+	err = json.Unmarshal(content, &composerLock)
+	if err != nil {
+		return nil, err
+	}
+
+	installedPackages, err := ReadInstalledPackages(filepath.Join(vendorDir, "composer", "installed.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	installed := map[string]string{}
+	for _, p := range installedPackages {
+		installed[p.Name] = p.Version
+	}
+
+	locked := map[string]bool{}
+	var mismatches []VendorMismatch
+	for _, packages := range []struct {
+		list     []lockedPackage
+		optional bool
+	}{
+		{composerLock.Packages, false},
+		{composerLock.PackagesDev, true},
+	} {
+		for _, p := range packages.list {
+			locked[p.Name] = true
+
+			version, ok := installed[p.Name]
+			if (!ok && packages.optional) || (ok && version == p.Version) {
+				continue
+			}
+
+			mismatches = append(mismatches, VendorMismatch{Name: p.Name, Locked: p.Version, Installed: version})
+		}
+	}
+
+	for _, p := range installedPackages {
+		if !locked[p.Name] {
+			mismatches = append(mismatches, VendorMismatch{Name: p.Name, Installed: p.Version})
+		}
+	}
+
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].Name < mismatches[j].Name
+	})
+
+	return mismatches, nil
+}
+
+// vendorOnlyBuildRequested will check for env var
+// "BP_COMPOSER_VALIDATE_VENDOR_ONLY". If set to true and the application
+// contains its vendored packages, i.e. `{vendorDir}/composer/installed.json`,
+// the build does not run composer at all.
+func vendorOnlyBuildRequested(workingDir string) (bool, error) {
+	enabled, err := lookupBoolEnv(BpComposerValidateVendorOnly, false)
+	if err != nil {
+		return false, err
+	}
+
+	if !enabled {
+		return false, nil
+	}
+
+	composerJsonPath, _, _, _ := FindComposerFiles(workingDir)
+
+	vendorDir, err := FindVendorDir(workingDir, composerJsonPath)
+	if err != nil {
+		return false, err
+	}
+
+	return fs.Exists(filepath.Join(vendorDir, "composer", "installed.json"))
+}
+
+// buildVendorOnly verifies the vendored packages of the application against
+// `composer.lock` and copies them into the composer-packages layer, without
+// running composer. This allows to build vendored applications without a
+// working `composer` executable, e.g. without network access.
+func buildVendorOnly(
+	logger emitter,
+	context packit.BuildContext,
+	fileSystem FileSystem,
+	installOptions []InstallOption,
+	sbomGenerator SBOMGenerator,
+	calculator Calculator,
+	clock chronos.Clock,
+	tracer *Tracer,
+	hooks []Hook) (packit.BuildResult, error) {
+	composerJsonPath, composerLockPath, _, _ := FindComposerFiles(context.WorkingDir)
+
+	workspaceVendorDir, err := FindVendorDir(context.WorkingDir, composerJsonPath)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	logger.Process("Validating vendored packages in %s, skipping composer", workspaceVendorDir)
+
+	if exists, err := fs.Exists(composerLockPath); err != nil {
+		return packit.BuildResult{}, err
+	} else if !exists {
+		return packit.BuildResult{}, fmt.Errorf("%s requires a composer.lock to verify the vendored packages against", BpComposerValidateVendorOnly)
+	}
+
+	mismatches, err := VerifyVendorAgainstLock(composerLockPath, workspaceVendorDir)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	if len(mismatches) > 0 {
+		var descriptions []string
+		for _, m := range mismatches {
+			logger.Subprocess("- %s", m)
+			descriptions = append(descriptions, m.String())
+		}
+
+		return packit.BuildResult{}, fmt.Errorf("found %d vendored package(s) not matching composer.lock: %s", len(mismatches), strings.Join(descriptions, ", "))
+	}
+
+	logger.Subprocess("All vendored packages match composer.lock")
+	logger.Break()
+
+	err = checkAbandonedPackages(logger, composerLockPath)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	err = checkStabilityPolicyIfRequired(logger, composerJsonPath, composerLockPath)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	composerPackagesLayer, err := context.Layers.Get(ComposerPackagesLayerName)
+	if err != nil { // untested
+		return packit.BuildResult{}, err
+	}
+
+	composerLockChecksum, err := calculator.Sum(composerLockPath)
+	if err != nil { // untested
+		return packit.BuildResult{}, err
+	}
+
+	logger.Process("Building new layer %s", composerPackagesLayer.Path)
+
+	composerPackagesLayer, err = composerPackagesLayer.Reset()
+	if err != nil { // untested
+		return packit.BuildResult{}, err
+	}
+
+	composerPackagesLayer.Launch, composerPackagesLayer.Build = draft.NewPlanner().MergeLayerTypes(ComposerPackagesDependency, context.Plan.Entries)
+	// the layer is cached, so that a later build running composer can reuse it
+	composerPackagesLayer.Cache = true
+
+	composerPackagesLayer.Metadata = map[string]interface{}{
+		MetadataVersionKey:  ComposerPackagesMetadataVersion,
+		"stack":             context.Stack,
+		"composer-lock-sha": composerLockChecksum,
+		"cache-status":      string(CacheStatusVendorOnly),
+	}
+
+	layerIgnore, err := LoadLayerIgnore(context.WorkingDir)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	layerVendorDir := filepath.Join(composerPackagesLayer.Path, "vendor")
+	logger.Process("Copying from %s => to %s", workspaceVendorDir, layerVendorDir)
+	logLayerIgnore(logger, layerIgnore)
+
+	err = tracer.Trace("copy vendor", copyAttributes(workspaceVendorDir, layerVendorDir), func() error {
+		return copyTreeExcluding(logger, workspaceVendorDir, layerVendorDir, layerIgnore.Excludes)
+	})
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+	logger.Break()
+
+	configureLaunchEnv(logger, context, &composerPackagesLayer, workspaceVendorDir, installOptions)
+
+	processes, err := detectProcessesIfRequired(logger, context.WorkingDir, composerJsonPath, workspaceVendorDir, &composerPackagesLayer)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	hookContext := HookContext{
+		BuildContext:          context,
+		Logger:                contextLogger{emitter: logger},
+		InstallOptions:        installOptions,
+		WorkspaceVendorDir:    workspaceVendorDir,
+		ComposerPackagesLayer: &composerPackagesLayer,
+	}
+
+	err = tracer.Trace("generate SBOM", nil, func() error {
+		return generateSBOMIfRequired(logger, context, fileSystem, sbomGenerator, clock, hooks, hookContext, &composerPackagesLayer)
+	})
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	err = checkLicensePolicyIfRequired(logger, &composerPackagesLayer)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	return packit.BuildResult{
+		Layers: []packit.Layer{composerPackagesLayer},
+		Launch: packit.LaunchMetadata{
+			Processes: processes,
+		},
+	}, nil
+}
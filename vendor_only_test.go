@@ -0,0 +1,104 @@
+package composer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testVendorOnly(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "composer"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`{
+	"packages": [
+		{"name": "some/package", "version": "1.0.0"},
+		{"name": "some/other-package", "version": "v2.0.0"}
+	],
+	"packages-dev": [
+		{"name": "some/dev-package", "version": "3.0.0"},
+		{"name": "some/other-dev-package", "version": "4.0.0"}
+	]
+}`), os.ModePerm)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("VerifyVendorAgainstLock", func() {
+		it("allows dev packages to be missing", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "composer", "installed.json"), []byte(`{"packages": [
+	{"name": "some/package", "version": "1.0.0"},
+	{"name": "some/other-package", "version": "v2.0.0"},
+	{"name": "some/dev-package", "version": "3.0.0"}
+]}`), os.ModePerm)).To(Succeed())
+
+			mismatches, err := composer.VerifyVendorAgainstLock(filepath.Join(workingDir, "composer.lock"), filepath.Join(workingDir, "vendor"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mismatches).To(BeEmpty())
+		})
+
+		it("returns the packages which are missing, not locked, or vendored with another version", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "composer", "installed.json"), []byte(`{"packages": [
+	{"name": "some/package", "version": "1.0.1"},
+	{"name": "some/dev-package", "version": "2.9.0"},
+	{"name": "some/unlocked-package", "version": "5.0.0"}
+]}`), os.ModePerm)).To(Succeed())
+
+			mismatches, err := composer.VerifyVendorAgainstLock(filepath.Join(workingDir, "composer.lock"), filepath.Join(workingDir, "vendor"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mismatches).To(Equal([]composer.VendorMismatch{
+				{Name: "some/dev-package", Locked: "3.0.0", Installed: "2.9.0"},
+				{Name: "some/other-package", Locked: "v2.0.0"},
+				{Name: "some/package", Locked: "1.0.0", Installed: "1.0.1"},
+				{Name: "some/unlocked-package", Installed: "5.0.0"},
+			}))
+
+			var descriptions []string
+			for _, m := range mismatches {
+				descriptions = append(descriptions, m.String())
+			}
+			Expect(descriptions).To(Equal([]string{
+				"some/dev-package (locked 3.0.0, vendored 2.9.0)",
+				"some/other-package (locked v2.0.0, not vendored)",
+				"some/package (locked 1.0.0, vendored 1.0.1)",
+				"some/unlocked-package (vendored 5.0.0, not locked)",
+			}))
+		})
+
+		context("failure cases", func() {
+			context("when composer.lock is not valid JSON", func() {
+				it("returns an error", func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "composer.lock"), []byte(`%%%`), os.ModePerm)).To(Succeed())
+
+					_, err := composer.VerifyVendorAgainstLock(filepath.Join(workingDir, "composer.lock"), filepath.Join(workingDir, "vendor"))
+					Expect(err).To(MatchError(ContainSubstring("invalid character")))
+				})
+			})
+
+			context("when installed.json is not valid JSON", func() {
+				it("returns an error", func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "vendor", "composer", "installed.json"), []byte(`%%%`), os.ModePerm)).To(Succeed())
+
+					_, err := composer.VerifyVendorAgainstLock(filepath.Join(workingDir, "composer.lock"), filepath.Join(workingDir, "vendor"))
+					Expect(err).To(MatchError(ContainSubstring("invalid character")))
+				})
+			})
+		})
+	})
+}
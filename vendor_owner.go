@@ -0,0 +1,153 @@
+package composer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// VendorOwner is the user and group which own the vendor directory, as set
+// in "BP_COMPOSER_VENDOR_OWNER", or which run `composer install`, as set in
+// "BP_COMPOSER_RUN_AS".
+type VendorOwner struct {
+	UID int
+	GID int
+}
+
+// ParseVendorOwner parses the owner of the vendor directory in the form
+// "uid:gid". If the group is omitted, it defaults to the user, e.g. "1000"
+// is equivalent to "1000:1000".
+func ParseVendorOwner(value string) (VendorOwner, error) {
+	return parseOwner(BpComposerVendorOwner, value)
+}
+
+// parseOwner parses a user and group in the form "uid:gid", as set in the
+// env var of the given name.
+func parseOwner(name, value string) (VendorOwner, error) {
+	uidStr, gidStr, found := strings.Cut(value, ":")
+	if !found {
+		gidStr = uidStr
+	}
+
+	uid, err := strconv.Atoi(uidStr)
+	if err != nil || uid < 0 {
+		return VendorOwner{}, fmt.Errorf("%s must be of the form \"uid:gid\", found %q", name, value)
+	}
+
+	gid, err := strconv.Atoi(gidStr)
+	if err != nil || gid < 0 {
+		return VendorOwner{}, fmt.Errorf("%s must be of the form \"uid:gid\", found %q", name, value)
+	}
+
+	return VendorOwner{UID: uid, GID: gid}, nil
+}
+
+// normalizeVendorPermissionsIfRequired will check for env vars
+// "BP_COMPOSER_VENDOR_OWNER" and "BP_COMPOSER_VENDOR_NORMALIZE_PERMISSIONS".
+// If the owner is set, the given vendor directories are changed to be owned
+// by it, e.g. because the run image uses a different user than the build
+// image. If permissions are normalized, files and directories are made
+// readable by everyone, and no longer writable by everyone.
+//
+// Symlinks are neither followed nor changed, except for their owner.
+//
+// Paths already owned by the owner are left as they are, as changing the
+// owner requires privileges, which rootless builds do not have. If the build
+// lacks the privileges for the other paths, they are skipped with a warning.
+func normalizeVendorPermissionsIfRequired(logger emitter, vendorDirs ...string) error {
+	normalizePermissions, err := lookupBoolEnv(BpComposerVendorNormalizePermissions, false)
+	if err != nil {
+		return err
+	}
+
+	var owner *VendorOwner
+	if value, found := os.LookupEnv(BpComposerVendorOwner); found && value != "" {
+		parsed, err := ParseVendorOwner(value)
+		if err != nil {
+			return err
+		}
+		owner = &parsed
+	}
+
+	if owner == nil && !normalizePermissions {
+		return nil
+	}
+
+	logger.Process("Normalizing vendor directory permissions")
+	if owner != nil {
+		logger.Subprocess("Owner: %d:%d", owner.UID, owner.GID)
+	}
+
+	for _, vendorDir := range vendorDirs {
+		changed, unchangedOwners := 0, 0
+		err = filepath.WalkDir(vendorDir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			info, err := entry.Info()
+			if err != nil { // untested
+				return err
+			}
+
+			if owner != nil && !ownedBy(info, *owner) {
+				err = os.Lchown(path, owner.UID, owner.GID)
+				if errors.Is(err, fs.ErrPermission) {
+					unchangedOwners++
+				} else if err != nil {
+					return fmt.Errorf("failed to change owner of %s to %d:%d: %w", path, owner.UID, owner.GID, err)
+				}
+			}
+
+			if !normalizePermissions || entry.Type()&fs.ModeSymlink != 0 {
+				return nil
+			}
+
+			mode := normalizedMode(info.Mode())
+			if mode == info.Mode().Perm() {
+				return nil
+			}
+
+			changed++
+			return os.Chmod(path, mode)
+		})
+		if err != nil {
+			return err
+		}
+
+		if normalizePermissions {
+			logger.Subprocess("Changed permissions of %d file(s) in %s", changed, vendorDir)
+		}
+
+		if unchangedOwners > 0 {
+			logger.Subprocess("WARNING: Could not change the owner of %d file(s) in %s, the build lacks the privileges, e.g. in a rootless build", unchangedOwners, vendorDir)
+		}
+	}
+	logger.Break()
+
+	return nil
+}
+
+// ownedBy returns whether the file of the given info is owned by the given
+// owner.
+func ownedBy(info fs.FileInfo, owner VendorOwner) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(stat.Uid) == owner.UID && int(stat.Gid) == owner.GID
+}
+
+// normalizedMode returns the permissions of the given mode without write
+// permissions for others, and with read permissions for everyone. Directories
+// and executable files are executable for everyone.
+func normalizedMode(mode fs.FileMode) fs.FileMode {
+	perm := mode.Perm()&^0002 | 0444
+	if mode.IsDir() || perm&0111 != 0 {
+		perm |= 0111
+	}
+
+	return perm
+}
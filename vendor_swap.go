@@ -0,0 +1,80 @@
+package composer
+
+import (
+	"os"
+
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+const (
+	// vendorSwapNewSuffix is the suffix of the directory next to the
+	// workspace vendor directory, into which the cached vendor directory is
+	// copied before it is swapped in.
+	vendorSwapNewSuffix = ".new"
+
+	// vendorSwapOldSuffix is the suffix of the directory next to the
+	// workspace vendor directory, to which the previous vendor directory is
+	// moved while swapping, before it is removed.
+	vendorSwapOldSuffix = ".old"
+)
+
+// restoreVendorDir copies the vendor directory of a cached layer into the
+// workspace. Instead of removing the workspace vendor directory and copying
+// into its place, the copy is written next to it as `vendor.new` and then
+// swapped in with two renames, which also replace symlinks rather than their
+// targets. So the workspace only lacks a vendor directory between the
+// renames, rather than during the whole copy, e.g. if an incremental
+// builder interrupts the build. The previous vendor directory is removed
+// after the swap, so its removal does not delay the swap either. Leftovers
+// of an interrupted swap are removed first.
+func restoreVendorDir(logger emitter, fileSystem FileSystem, tracer *Tracer, layerVendorDir, workspaceVendorDir string) error {
+	newVendorDir := workspaceVendorDir + vendorSwapNewSuffix
+	oldVendorDir := workspaceVendorDir + vendorSwapOldSuffix
+
+	for _, leftover := range []string{newVendorDir, oldVendorDir} {
+		if exists, err := fs.Exists(leftover); err != nil {
+			return err
+		} else if exists {
+			logger.Debug.Subprocess("Removing %s of an interrupted build", leftover)
+			err = fileSystem.RemoveAll(leftover)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	logger.Process("Copying from %s => to %s", layerVendorDir, newVendorDir)
+	err := tracer.Trace("copy vendor", copyAttributes(layerVendorDir, newVendorDir), func() error {
+		return copyTree(logger, layerVendorDir, newVendorDir)
+	})
+	if err != nil { // untested
+		return err
+	}
+
+	existing := true
+	if _, err := os.Lstat(workspaceVendorDir); os.IsNotExist(err) {
+		existing = false
+	} else if err != nil { // untested
+		return err
+	}
+
+	if existing {
+		logger.Process("Detected existing vendored packages, replacing with cached vendored packages")
+		err = fileSystem.Rename(workspaceVendorDir, oldVendorDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	logger.Subprocess("Swapping %s => to %s", newVendorDir, workspaceVendorDir)
+	err = fileSystem.Rename(newVendorDir, workspaceVendorDir)
+	if err != nil { // untested
+		return err
+	}
+
+	if !existing {
+		return nil
+	}
+
+	return fileSystem.RemoveAll(oldVendorDir)
+}
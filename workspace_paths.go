@@ -0,0 +1,182 @@
+package composer
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+// WorkspacePathFinder returns the paths (relative to the working directory)
+// of files or directories which have been placed into the workspace during
+// `composer install` outside of the vendor directory.
+type WorkspacePathFinder func(workingDir, composerJsonPath, vendorDir string) ([]string, error)
+
+// ChecksumInputFinder returns the files determining the content of a set of
+// cached workspace paths. They are checksummed alongside `composer.lock`, so
+// that the cached layer is not reused once any of them changes.
+type ChecksumInputFinder func(workingDir, composerJsonPath string) ([]string, error)
+
+// cachedWorkspacePaths describes a set of workspace paths which are cached in
+// a dedicated directory of the composer-packages layer, so that they can be
+// restored when the cached layer is reused.
+type cachedWorkspacePaths struct {
+	// layerDir is the name of the directory in the composer-packages layer,
+	// it is also used to derive the name of the checksum metadata field
+	layerDir string
+
+	// description is used in log output
+	description string
+
+	find WorkspacePathFinder
+
+	// inputs is optional
+	inputs ChecksumInputFinder
+}
+
+func (c cachedWorkspacePaths) metadataKey() string {
+	return c.layerDir + "-sha"
+}
+
+// defaultCachedWorkspacePaths lists all sets of workspace paths which are
+// cached alongside the vendor directory.
+func defaultCachedWorkspacePaths() []cachedWorkspacePaths {
+	return []cachedWorkspacePaths{
+		{
+			layerDir:    drupalScaffoldLayerDir,
+			description: "Drupal scaffold",
+			find:        FindDrupalScaffoldFiles,
+		},
+		{
+			layerDir:    installerPathsLayerDir,
+			description: "installer-paths",
+			find:        FindInstallerPaths,
+		},
+		{
+			layerDir:    pluginOutputLayerDir,
+			description: "plugin output",
+			find:        FindPluginOutputPaths,
+			inputs:      FindPluginChecksumInputs,
+		},
+		{
+			layerDir:    magentoLayerDir,
+			description: "Magento generated",
+			find:        FindMagentoPaths,
+			inputs:      FindMagentoChecksumInputs,
+		},
+		{
+			layerDir:    extraCachePathsLayerDir,
+			description: "extra cache",
+			find:        FindExtraCachePaths,
+		},
+	}
+}
+
+// cacheWorkspacePaths copies the given paths (relative to the working
+// directory) into the given directory of the layer, except for the paths
+// excluded by the given LayerIgnore.
+func cacheWorkspacePaths(fileSystem FileSystem, paths []string, workingDir, layerDir string, ignore LayerIgnore) error {
+	for _, path := range paths {
+		source := filepath.Join(workingDir, path)
+		destination := filepath.Join(layerDir, path)
+
+		if ignore.Excludes(source) {
+			continue
+		}
+
+		err := fileSystem.MkdirAll(filepath.Dir(destination), 0755)
+		if err != nil { // untested
+			return err
+		}
+
+		if len(ignore.Patterns()) == 0 {
+			err = fs.Copy(source, destination)
+			if err != nil { // untested
+				return err
+			}
+			continue
+		}
+
+		err = copyPathExcluding(source, destination, ignore)
+		if err != nil { // untested
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyPathExcluding copies the file or directory tree at source to
+// destination, skipping the paths excluded by the given LayerIgnore. File
+// modes are preserved and symlinks are copied as symlinks.
+func copyPathExcluding(source, destination string, ignore LayerIgnore) error {
+	return filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if ignore.Excludes(path) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(source, path)
+		if err != nil { // untested
+			return err
+		}
+		target := filepath.Join(destination, relativePath)
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil { // untested
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		default:
+			return copyFile(path, target, info.Mode().Perm())
+		}
+	})
+}
+
+// restoreWorkspacePaths copies the cached files from the given directory of
+// the layer back into the working directory. Files which already exist in the
+// working directory are left untouched, so that files committed with the
+// application take precedence over the cached ones.
+func restoreWorkspacePaths(fileSystem FileSystem, layerDir, workingDir string) (restored []string, err error) {
+	err = filepath.Walk(layerDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(layerDir, path)
+		if err != nil { // untested
+			return err
+		}
+
+		destination := filepath.Join(workingDir, relativePath)
+		if _, err := os.Lstat(destination); err == nil {
+			return nil
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+
+		err = fileSystem.MkdirAll(filepath.Dir(destination), 0755)
+		if err != nil { // untested
+			return err
+		}
+
+		restored = append(restored, relativePath)
+		return fs.Copy(path, destination)
+	})
+
+	return restored, err
+}
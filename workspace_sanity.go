@@ -0,0 +1,175 @@
+package composer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// WorkspaceFinding is a common misconfiguration of the workspace, with the
+// steps to fix it.
+type WorkspaceFinding struct {
+	// Check identifies the misconfiguration, e.g. "auth-json-committed"
+	Check       string
+	Message     string
+	Remediation string
+}
+
+// AnalyzeWorkspace looks for common misconfigurations of the workspace:
+//   - "ignored-vendor-present": the vendor directory is listed in
+//     `.gitignore`, but is present, e.g. because it has been installed
+//     locally and is not excluded from the build
+//   - "composer-phar-committed": `composer.phar` is part of the application
+//   - "auth-json-committed": `auth.json` with credentials is part of the
+//     application, and therefore of the image
+//   - "lock-older-than-json": `composer.lock` has been modified before
+//     `composer.json`, and may be out of date
+func AnalyzeWorkspace(workingDir, composerJsonPath, composerLockPath, vendorDir string) ([]WorkspaceFinding, error) {
+	var findings []WorkspaceFinding
+
+	relativeVendorDir, err := filepath.Rel(workingDir, vendorDir)
+	if err != nil { // untested
+		return nil, err
+	}
+
+	vendorExists, err := pathExists(vendorDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if vendorExists {
+		ignored, err := gitIgnored(workingDir, filepath.ToSlash(relativeVendorDir))
+		if err != nil {
+			return nil, err
+		}
+
+		if ignored {
+			findings = append(findings, WorkspaceFinding{
+				Check:   "ignored-vendor-present",
+				Message: fmt.Sprintf("%s is listed in .gitignore, but is present in the workspace, it will be replaced by 'composer install'", relativeVendorDir),
+				Remediation: fmt.Sprintf("Exclude %s from the build, e.g. with `exclude = [%q]` in the [io.buildpacks] table of project.toml, "+
+					"so that locally installed packages are not uploaded", relativeVendorDir, relativeVendorDir),
+			})
+		}
+	}
+
+	if exists, err := pathExists(filepath.Join(workingDir, "composer.phar")); err != nil {
+		return nil, err
+	} else if exists {
+		findings = append(findings, WorkspaceFinding{
+			Check:   "composer-phar-committed",
+			Message: "composer.phar is part of the application, but is not used by the build",
+			Remediation: fmt.Sprintf("Remove composer.phar from the repository, composer is provided by another buildpack or with %s",
+				BpComposerFallbackVersion),
+		})
+	}
+
+	if exists, err := pathExists(filepath.Join(workingDir, "auth.json")); err != nil {
+		return nil, err
+	} else if exists {
+		findings = append(findings, WorkspaceFinding{
+			Check:   "auth-json-committed",
+			Message: "auth.json is part of the application, so its credentials end up in the image",
+			Remediation: fmt.Sprintf("Remove auth.json from the repository, rotate its credentials, and provide them with a %q binding or COMPOSER_AUTH instead",
+				ComposerRepositoriesBindingType),
+		})
+	}
+
+	jsonInfo, err := os.Stat(composerJsonPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	lockInfo, err := os.Stat(composerLockPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if jsonInfo != nil && lockInfo != nil && lockInfo.ModTime().Before(jsonInfo.ModTime()) {
+		findings = append(findings, WorkspaceFinding{
+			Check: "lock-older-than-json",
+			Message: fmt.Sprintf("%s has been modified before %s, it may not contain the latest changes to the requirements",
+				filepath.Base(composerLockPath), filepath.Base(composerJsonPath)),
+			Remediation: fmt.Sprintf("Run 'composer update --lock' and commit %s, or check that it is up to date with 'composer validate'",
+				filepath.Base(composerLockPath)),
+		})
+	}
+
+	return findings, nil
+}
+
+// gitIgnored returns whether the given path, relative to the working
+// directory and separated by slashes, is ignored by the `.gitignore` of the
+// working directory. Only the patterns matching the path as a whole are
+// considered, negated patterns are skipped.
+func gitIgnored(workingDir, relativePath string) (bool, error) {
+	file, err := os.Open(filepath.Join(workingDir, ".gitignore"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		pattern := strings.TrimSpace(scanner.Text())
+		if pattern == "" || strings.HasPrefix(pattern, "#") || strings.HasPrefix(pattern, "!") {
+			continue
+		}
+
+		pattern = strings.TrimSuffix(strings.TrimSuffix(pattern, "/**"), "/")
+
+		// patterns without a slash, other than a trailing one, match at any
+		// level
+		target := relativePath
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(relativePath)
+		}
+
+		if matched, _ := path.Match(strings.TrimPrefix(pattern, "/"), target); matched {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+// pathExists is like fs.Exists, but also returns true for broken symlinks.
+func pathExists(name string) (bool, error) {
+	_, err := os.Lstat(name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// logWorkspaceFindings logs a warning with remediation for each finding of
+// AnalyzeWorkspace. The findings never fail the build.
+func logWorkspaceFindings(logger emitter, workingDir, composerJsonPath, composerLockPath, vendorDir string) error {
+	findings, err := AnalyzeWorkspace(workingDir, composerJsonPath, composerLockPath, vendorDir)
+	if err != nil {
+		return err
+	}
+
+	if len(findings) == 0 {
+		return nil
+	}
+
+	logger.Process("Found %d possible misconfiguration(s) of the workspace", len(findings))
+	for _, finding := range findings {
+		logger.Subprocess("WARNING [%s]: %s", finding.Check, finding.Message)
+		logger.Action("Remediation: %s", finding.Remediation)
+	}
+	logger.Break()
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package composer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testWorkspaceSanity(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir       string
+		composerJsonPath string
+		composerLockPath string
+		vendorDir        string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		composerJsonPath = filepath.Join(workingDir, "composer.json")
+		composerLockPath = filepath.Join(workingDir, "composer.lock")
+		vendorDir = filepath.Join(workingDir, "vendor")
+
+		Expect(os.WriteFile(composerJsonPath, []byte("{}"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(composerLockPath, []byte("{}"), os.ModePerm)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("AnalyzeWorkspace", func() {
+		it("finds nothing in a clean workspace", func() {
+			findings, err := composer.AnalyzeWorkspace(workingDir, composerJsonPath, composerLockPath, vendorDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(findings).To(BeEmpty())
+		})
+
+		it("finds all misconfigurations", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, ".gitignore"), []byte("# dependencies\n/vendor/\n.env\n"), os.ModePerm)).To(Succeed())
+			Expect(os.MkdirAll(vendorDir, os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "composer.phar"), []byte(""), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "auth.json"), []byte("{}"), os.ModePerm)).To(Succeed())
+
+			lockTime := time.Now().Add(-time.Hour)
+			Expect(os.Chtimes(composerLockPath, lockTime, lockTime)).To(Succeed())
+
+			findings, err := composer.AnalyzeWorkspace(workingDir, composerJsonPath, composerLockPath, vendorDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			var checks []string
+			for _, finding := range findings {
+				checks = append(checks, finding.Check)
+				Expect(finding.Message).NotTo(BeEmpty())
+				Expect(finding.Remediation).NotTo(BeEmpty())
+			}
+			Expect(checks).To(Equal([]string{
+				"ignored-vendor-present",
+				"composer-phar-committed",
+				"auth-json-committed",
+				"lock-older-than-json",
+			}))
+			Expect(findings[0].Remediation).To(ContainSubstring(`exclude = ["vendor"]`))
+		})
+
+		it("matches .gitignore patterns without slash at any level", func() {
+			vendorDir = filepath.Join(workingDir, "lib", "vendor")
+			Expect(os.MkdirAll(vendorDir, os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, ".gitignore"), []byte("vendor\n"), os.ModePerm)).To(Succeed())
+
+			findings, err := composer.AnalyzeWorkspace(workingDir, composerJsonPath, composerLockPath, vendorDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(findings).To(HaveLen(1))
+			Expect(findings[0].Message).To(HavePrefix("lib/vendor is listed in .gitignore"))
+		})
+
+		it("does not report a vendor directory which is not ignored, or an ignored one which is absent", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, ".gitignore"), []byte("vendor/\n!vendor/\n"), os.ModePerm)).To(Succeed())
+
+			findings, err := composer.AnalyzeWorkspace(workingDir, composerJsonPath, composerLockPath, vendorDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(findings).To(BeEmpty())
+
+			Expect(os.WriteFile(filepath.Join(workingDir, ".gitignore"), []byte("node_modules/\n"), os.ModePerm)).To(Succeed())
+			Expect(os.MkdirAll(vendorDir, os.ModePerm)).To(Succeed())
+
+			findings, err = composer.AnalyzeWorkspace(workingDir, composerJsonPath, composerLockPath, vendorDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(findings).To(BeEmpty())
+		})
+	})
+}